@@ -115,14 +115,19 @@ func (m commandMap) process(cmdPrefix string, command string) (string, error) {
 	var cmdStr string
 
 	// Support command completion.
-	for k := range m {
-		if !strings.HasPrefix(k, cmdPrefix) {
-			continue
-		}
-		if len(cmdStr) > 0 {
-			return command, errAmbiguousCmd
+	// An exact match wins over other commands sharing the same prefix.
+	if _, ok := m[cmdPrefix]; ok {
+		cmdStr = cmdPrefix
+	} else {
+		for k := range m {
+			if !strings.HasPrefix(k, cmdPrefix) {
+				continue
+			}
+			if len(cmdStr) > 0 {
+				return command, errAmbiguousCmd
+			}
+			cmdStr = k
 		}
-		cmdStr = k
 	}
 
 	if cmdStr == "" {
@@ -138,6 +143,7 @@ func (m commandMap) process(cmdPrefix string, command string) (string, error) {
 
 	conf.OwnerPW = opw
 	conf.UserPW = upw
+	conf.Incremental = incremental
 
 	if m[cmdStr].handler != nil {
 		m[cmdStr].handler(conf)
@@ -18,6 +18,7 @@ package main
 
 import (
 	"flag"
+	"strings"
 
 	"github.com/pdfcpu/pdfcpu/pkg/log"
 )
@@ -169,6 +170,18 @@ func initPropertiesCmdMap() commandMap {
 	return m
 }
 
+func initSignaturesCmdMap() commandMap {
+	m := newCommandMap()
+	for k, v := range map[string]command{
+		"list":   {processListSignaturesCommand, nil, "", ""},
+		"verify": {processVerifySignaturesCommand, nil, "", ""},
+		"dss":    {processAddDSSCommand, nil, "", ""},
+	} {
+		m.register(k, v)
+	}
+	return m
+}
+
 func initStampCmdMap() commandMap {
 	m := newCommandMap()
 	for k, v := range map[string]command{
@@ -242,6 +255,7 @@ func initCommandMap() {
 	permissionsCmdMap := initPermissionsCmdMap()
 	portfolioCmdMap := initPortfolioCmdMap()
 	propertiesCmdMap := initPropertiesCmdMap()
+	signaturesCmdMap := initSignaturesCmdMap()
 	stampCmdMap := initStampCmdMap()
 	watermarkCmdMap := initWatermarkCmdMap()
 	pageModeCmdMap := initPageModeCmdMap()
@@ -266,6 +280,7 @@ func initCommandMap() {
 		"decrypt":       {processDecryptCommand, nil, usageDecrypt, usageLongDecrypt},
 		"dump":          {processDumpCommand, nil, "", ""},
 		"encrypt":       {processEncryptCommand, nil, usageEncrypt, usageLongEncrypt},
+		"export":        {processExportCommand, nil, usageExport, usageLongExport},
 		"extract":       {processExtractCommand, nil, usageExtract, usageLongExtract},
 		"fonts":         {nil, fontsCmdMap, usageFonts, usageLongFonts},
 		"form":          {nil, formCmdMap, usageForm, usageLongForm},
@@ -287,11 +302,19 @@ func initCommandMap() {
 		"portfolio":     {nil, portfolioCmdMap, usagePortfolio, usageLongPortfolio},
 		"poster":        {processPosterCommand, nil, usagePoster, usageLongPoster},
 		"properties":    {nil, propertiesCmdMap, usageProperties, usageLongProperties},
+		"redact":        {processRedactCommand, nil, usageRedact, usageLongRedact},
+		"render":        {processRenderCommand, nil, usageRender, usageLongRender},
+		"repair":        {processRepairCommand, nil, usageRepair, usageLongRepair},
 		"resize":        {processResizeCommand, nil, usageResize, usageLongResize},
 		"rotate":        {processRotateCommand, nil, usageRotate, usageLongRotate},
+		"sanitize":      {processSanitizeCommand, nil, usageSanitize, usageLongSanitize},
+		"search":        {processSearchCommand, nil, usageSearch, usageLongSearch},
 		"selectedpages": {printSelectedPages, nil, usageSelectedPages, usageLongSelectedPages},
+		"sign":          {processSignCommand, nil, usageSign, usageLongSign},
+		"signatures":    {nil, signaturesCmdMap, usageSignatures, usageLongSignatures},
 		"split":         {processSplitCommand, nil, usageSplit, usageLongSplit},
 		"stamp":         {nil, stampCmdMap, usageStamp, usageLongStamp},
+		"timestamp":     {processTimestampCommand, nil, usageTimestamp, usageLongTimestamp},
 		"trim":          {processTrimCommand, nil, usageTrim, usageLongTrim},
 		"validate":      {processValidateCommand, nil, usageValidate, usageLongValidate},
 		"watermark":     {nil, watermarkCmdMap, usageWatermark, usageLongWatermark},
@@ -303,6 +326,18 @@ func initCommandMap() {
 	}
 }
 
+// stringsFlag collects the values of a repeatable flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
 func initFlags() {
 	flag.BoolVar(&all, "all", false, "")
 	flag.BoolVar(&all, "a", false, "")
@@ -311,6 +346,13 @@ func initFlags() {
 	flag.BoolVar(&bookmarks, "bookmarks", true, bookmarksUsage)
 	flag.BoolVar(&bookmarks, "b", true, bookmarksUsage)
 
+	categoriesUsage := "sanitize: comma separated list of categories"
+	flag.StringVar(&categories, "categories", "", categoriesUsage)
+
+	certUsage := "sign: PKCS#12 key store, encrypt: recipient certificate (repeatable)"
+	flag.Var(&certs, "cert", certUsage)
+	flag.StringVar(&certPW, "certpw", "", "sign: PKCS#12 key store password")
+
 	confUsage := "the config directory path | skip | none"
 	flag.StringVar(&conf, "config", "", confUsage)
 	flag.StringVar(&conf, "conf", "", confUsage)
@@ -320,19 +362,43 @@ func initFlags() {
 	flag.BoolVar(&dividerPage, "dividerPage", false, dividerPageUsage)
 	flag.BoolVar(&dividerPage, "d", false, dividerPageUsage)
 
+	dpiUsage := "render: resolution in dots per inch"
+	flag.IntVar(&dpi, "dpi", 150, dpiUsage)
+
+	fieldUsage := "sign, timestamp: signature field name"
+	flag.StringVar(&field, "field", "", fieldUsage)
+
+	formatUsage := "render: png|jpg, export: svg"
+	flag.StringVar(&format, "format", "", formatUsage)
+
+	highlightUsage := "search: highlight matches"
+	flag.BoolVar(&highlight, "highlight", false, highlightUsage)
+
+	imagesUsage := "optimize: downsample and recompress images, e.g. dpi=150,jpeg=75"
+	flag.StringVar(&images, "images", "", imagesUsage)
+
+	incrUsage := "write changes as incremental update"
+	flag.BoolVar(&incremental, "incr", false, incrUsage)
+
 	jsonUsage := "produce JSON output"
 	flag.BoolVar(&json, "json", false, jsonUsage)
 	flag.BoolVar(&json, "j", false, jsonUsage)
 
-	keyUsage := "encrypt: 40|128|256"
+	keyUsage := "encrypt: 40|128|256, decrypt: private key file"
 	flag.StringVar(&key, "key", "256", keyUsage)
 	flag.StringVar(&key, "k", "256", keyUsage)
 
+	markUsage := "redact: add redact annotations instead of removing content"
+	flag.BoolVar(&mark, "mark", false, markUsage)
+
 	linksUsage := "check for broken links"
 	flag.BoolVar(&links, "links", false, linksUsage)
 	flag.BoolVar(&links, "l", false, linksUsage)
 
-	modeUsage := "validate: strict|relaxed; extract: image|font|content|page|meta; encrypt: rc4|aes, stamp:text|image/pdf"
+	metadataUsage := "encrypt: plain|encrypted"
+	flag.StringVar(&metadata, "metadata", "", metadataUsage)
+
+	modeUsage := "validate: strict|relaxed; extract: image|font|content|page|meta; encrypt: rc4|aes|attachments, stamp:text|image/pdf, sign: pades|pkcs7"
 	flag.StringVar(&mode, "mode", "", modeUsage)
 	flag.StringVar(&mode, "m", "", modeUsage)
 
@@ -346,6 +412,12 @@ func initFlags() {
 	flag.BoolVar(&quiet, "quiet", false, "")
 	flag.BoolVar(&quiet, "q", false, "")
 
+	rectUsage := "redact: rectangle \"llx lly urx ury\" (repeatable)"
+	flag.Var(&rects, "rect", rectUsage)
+
+	regExpUsage := "redact: regular expression"
+	flag.StringVar(&regExp, "regexp", "", regExpUsage)
+
 	replaceUsage := "replace existing bookmarks"
 	flag.BoolVar(&replaceBookmarks, "replace", false, replaceUsage)
 	flag.BoolVar(&replaceBookmarks, "r", false, replaceUsage)
@@ -357,6 +429,12 @@ func initFlags() {
 	statsUsage := "optimize: create a csv file for stats"
 	flag.StringVar(&fileStats, "stats", "", statsUsage)
 
+	trustUsage := "signatures verify: trust store directory"
+	flag.StringVar(&trust, "trust", "", trustUsage)
+
+	tsaUsage := "sign, timestamp: time stamping authority URL"
+	flag.StringVar(&tsa, "tsa", "", tsaUsage)
+
 	unitUsage := "info: po|in|cm|mm"
 	flag.StringVar(&unit, "unit", "", unitUsage)
 	flag.StringVar(&unit, "u", "", unitUsage)
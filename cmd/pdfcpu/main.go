@@ -28,6 +28,11 @@ var (
 	verbose, veryVerbose                     bool
 	links, quiet, sorted, bookmarks          bool
 	all, dividerPage, json, replaceBookmarks bool
+	incremental, highlight, mark             bool
+	certPW, field, trust, tsa, metadata      string
+	regExp, categories, format, images       string
+	dpi                                      int
+	certs, rects                             stringsFlag
 	needStackTrace                           = true
 	cmdMap                                   commandMap
 )
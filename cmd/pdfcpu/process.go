@@ -32,8 +32,10 @@ import (
 
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/cli"
+	"github.com/pdfcpu/pdfcpu/pkg/log"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/sign"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/validate"
 	"github.com/pkg/errors"
@@ -204,6 +206,13 @@ func processValidateCommand(conf *model.Configuration) {
 		conf.ValidateLinks = true
 	}
 
+	if json {
+		// The JSON report covers all violations.
+		log.SetCLILogger(nil)
+		process(cli.ValidateCommandJSON(inFiles, conf))
+		return
+	}
+
 	process(cli.ValidateCommand(inFiles, conf))
 }
 
@@ -229,9 +238,314 @@ func processOptimizeCommand(conf *model.Configuration) {
 		fmt.Fprintf(os.Stdout, "stats will be appended to %s\n", fileStats)
 	}
 
+	if images != "" {
+		opt, err := pdfcpu.ParseImageOptimization(images)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		conf.ImageOptimization = opt
+	}
+
 	process(cli.OptimizeCommand(inFile, outFile, conf))
 }
 
+func processRepairCommand(conf *model.Configuration) {
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || selectedPages != "" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageRepair)
+		os.Exit(1)
+	}
+
+	inFile := flag.Arg(0)
+	if conf.CheckFileNameExt {
+		ensurePDFExtension(inFile)
+	}
+
+	outFile := inFile
+	if len(flag.Args()) == 2 {
+		outFile = flag.Arg(1)
+		ensurePDFExtension(outFile)
+	}
+
+	if json {
+		// The JSON report covers all repairs.
+		log.SetCLILogger(nil)
+	}
+
+	process(cli.RepairCommand(inFile, outFile, json, conf))
+}
+
+func processSignCommand(conf *model.Configuration) {
+	if len(certs) != 1 || len(flag.Args()) == 0 || len(flag.Args()) > 3 || selectedPages != "" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageSign)
+		os.Exit(1)
+	}
+
+	processDiplayUnit(conf)
+
+	subFilter, err := model.ParseSubFilter(mode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	sig := model.DefaultSignature()
+	sig.Field, sig.SubFilter, sig.Unit = field, subFilter, conf.Unit
+	if tsa != "" {
+		sig.TSA = sign.NewHTTPTimestampAuthority(tsa)
+	}
+
+	args := flag.Args()
+	if len(args) == 3 || (len(args) == 2 && !hasPDFExtension(args[0])) {
+		if err := model.ParseSignatureDetails(args[0], sig); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		args = args[1:]
+	}
+
+	inFile := args[0]
+	if conf.CheckFileNameExt {
+		ensurePDFExtension(inFile)
+	}
+
+	outFile := inFile
+	if len(args) == 2 {
+		outFile = args[1]
+		ensurePDFExtension(outFile)
+	}
+
+	process(cli.SignCommand(inFile, outFile, certs[0], certPW, sig, conf))
+}
+
+func processTimestampCommand(conf *model.Configuration) {
+	if tsa == "" || len(flag.Args()) == 0 || len(flag.Args()) > 2 || selectedPages != "" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageTimestamp)
+		os.Exit(1)
+	}
+
+	sig := model.DefaultSignature()
+	sig.Field, sig.SubFilter = field, model.SubFilterRFC3161
+	sig.TSA = sign.NewHTTPTimestampAuthority(tsa)
+
+	inFile := flag.Arg(0)
+	if conf.CheckFileNameExt {
+		ensurePDFExtension(inFile)
+	}
+
+	outFile := inFile
+	if len(flag.Args()) == 2 {
+		outFile = flag.Arg(1)
+		ensurePDFExtension(outFile)
+	}
+
+	process(cli.TimestampCommand(inFile, outFile, sig, conf))
+}
+
+func processSearchCommand(conf *model.Configuration) {
+	if len(flag.Args()) < 2 || len(flag.Args()) > 3 || (len(flag.Args()) == 3 && !highlight) {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageSearch)
+		os.Exit(1)
+	}
+
+	pattern := flag.Arg(0)
+
+	inFile := flag.Arg(1)
+	if conf.CheckFileNameExt {
+		ensurePDFExtension(inFile)
+	}
+
+	pages, err := api.ParsePageSelection(selectedPages)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "problem with flag selectedPages: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !highlight {
+		process(cli.SearchTextCommand(inFile, pattern, pages, json, conf))
+		return
+	}
+
+	outFile := inFile
+	if len(flag.Args()) == 3 {
+		outFile = flag.Arg(2)
+		ensurePDFExtension(outFile)
+	}
+
+	process(cli.HighlightTextCommand(inFile, outFile, pattern, pages, conf))
+}
+
+func processRedactCommand(conf *model.Configuration) {
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || (mark && len(rects) == 0 && regExp == "") {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageRedact)
+		os.Exit(1)
+	}
+
+	inFile := flag.Arg(0)
+	if conf.CheckFileNameExt {
+		ensurePDFExtension(inFile)
+	}
+
+	outFile := inFile
+	if len(flag.Args()) == 2 {
+		outFile = flag.Arg(1)
+		ensurePDFExtension(outFile)
+	}
+
+	pages, err := api.ParsePageSelection(selectedPages)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "problem with flag selectedPages: %v\n", err)
+		os.Exit(1)
+	}
+
+	var rr []types.Rectangle
+	for _, s := range rects {
+		r, err := model.ParseRect(s, conf.Unit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "problem with flag rect: %v\n", err)
+			os.Exit(1)
+		}
+		rr = append(rr, *r)
+	}
+
+	process(cli.RedactCommand(inFile, outFile, pages, rr, regExp, mark, conf))
+}
+
+func processSanitizeCommand(conf *model.Configuration) {
+	if len(flag.Args()) == 0 || len(flag.Args()) > 2 || selectedPages != "" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageSanitize)
+		os.Exit(1)
+	}
+
+	inFile := flag.Arg(0)
+	if conf.CheckFileNameExt {
+		ensurePDFExtension(inFile)
+	}
+
+	outFile := inFile
+	if len(flag.Args()) == 2 {
+		outFile = flag.Arg(1)
+		ensurePDFExtension(outFile)
+	}
+
+	cats := pdfcpu.SanitizeAll
+	if categories != "" {
+		var err error
+		if cats, err = pdfcpu.ParseSanitizeCategories(categories); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	process(cli.SanitizeCommand(inFile, outFile, cats, json, conf))
+}
+
+func processRenderCommand(conf *model.Configuration) {
+	if format == "" {
+		format = "png"
+	}
+	if len(flag.Args()) != 2 || dpi <= 0 || (format != "png" && format != "jpg") {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageRender)
+		os.Exit(1)
+	}
+
+	inFile := flag.Arg(0)
+	if conf.CheckFileNameExt {
+		ensurePDFExtension(inFile)
+	}
+	outDir := flag.Arg(1)
+
+	pages, err := api.ParsePageSelection(selectedPages)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "problem with flag selectedPages: %v\n", err)
+		os.Exit(1)
+	}
+
+	process(cli.RenderCommand(inFile, outDir, pages, dpi, format, conf))
+}
+
+func processExportCommand(conf *model.Configuration) {
+	if format == "" {
+		format = "svg"
+	}
+	if len(flag.Args()) != 2 || format != "svg" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageExport)
+		os.Exit(1)
+	}
+
+	inFile := flag.Arg(0)
+	if conf.CheckFileNameExt {
+		ensurePDFExtension(inFile)
+	}
+	outDir := flag.Arg(1)
+
+	pages, err := api.ParsePageSelection(selectedPages)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "problem with flag selectedPages: %v\n", err)
+		os.Exit(1)
+	}
+
+	process(cli.ExportCommand(inFile, outDir, pages, format, conf))
+}
+
+func processListSignaturesCommand(conf *model.Configuration) {
+	if len(flag.Args()) != 1 || selectedPages != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageSignaturesList)
+		os.Exit(1)
+	}
+
+	inFile := flag.Arg(0)
+	if conf.CheckFileNameExt {
+		ensurePDFExtension(inFile)
+	}
+
+	process(cli.ListSignaturesCommand(inFile, json, conf))
+}
+
+func processVerifySignaturesCommand(conf *model.Configuration) {
+	if len(flag.Args()) != 1 || selectedPages != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageSignaturesVerify)
+		os.Exit(1)
+	}
+
+	inFile := flag.Arg(0)
+	if conf.CheckFileNameExt {
+		ensurePDFExtension(inFile)
+	}
+
+	if json {
+		log.SetCLILogger(nil)
+	}
+
+	process(cli.VerifySignaturesCommand(inFile, trust, json, conf))
+}
+
+func processAddDSSCommand(conf *model.Configuration) {
+	if len(flag.Args()) < 2 || selectedPages != "" {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageSignaturesDSS)
+		os.Exit(1)
+	}
+
+	inFile := flag.Arg(0)
+	if conf.CheckFileNameExt {
+		ensurePDFExtension(inFile)
+	}
+
+	outFile := inFile
+	files := flag.Args()[1:]
+	if hasPDFExtension(files[0]) {
+		outFile = files[0]
+		files = files[1:]
+	}
+
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: %s\n", usageSignaturesDSS)
+		os.Exit(1)
+	}
+
+	process(cli.AddDSSCommand(inFile, outFile, files, conf))
+}
+
 func processSplitByPageNumberCommand(inFile, outDir string, conf *model.Configuration) {
 	if len(flag.Args()) == 2 {
 		fmt.Fprintln(os.Stderr, "split: missing page numbers")
@@ -416,7 +730,7 @@ func modeCompletion(modePrefix string, modes []string) string {
 }
 
 func processExtractCommand(conf *model.Configuration) {
-	mode = modeCompletion(mode, []string{"image", "font", "page", "content", "meta"})
+	mode = modeCompletion(mode, []string{"image", "font", "page", "content", "meta", "text"})
 	if len(flag.Args()) != 2 || mode == "" {
 		fmt.Fprintf(os.Stderr, "%s\n\n", usageExtract)
 		os.Exit(1)
@@ -453,6 +767,9 @@ func processExtractCommand(conf *model.Configuration) {
 	case "meta":
 		cmd = cli.ExtractMetadataCommand(inFile, outDir, conf)
 
+	case "text":
+		cmd = cli.ExtractTextCommand(inFile, outDir, pages, json, conf)
+
 	default:
 		fmt.Fprintf(os.Stderr, "unknown extract mode: %s\n", mode)
 		os.Exit(1)
@@ -728,12 +1045,33 @@ func processDecryptCommand(conf *model.Configuration) {
 		ensurePDFExtension(outFile)
 	}
 
+	if flagSet("key", "k") {
+		// Open a file using public-key encryption.
+		k, err := api.ReadPrivateKeyFile(key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		conf.DecryptKey = k
+	}
+
 	process(cli.DecryptCommand(inFile, outFile, conf))
 }
 
+// flagSet returns true if one of the given flag names has been set on the command line.
+func flagSet(names ...string) bool {
+	found := false
+	flag.Visit(func(f *flag.Flag) {
+		if types.MemberOf(f.Name, names) {
+			found = true
+		}
+	})
+	return found
+}
+
 func validateEncryptModeFlag() {
-	if !types.MemberOf(mode, []string{"rc4", "aes", ""}) {
-		fmt.Fprintf(os.Stderr, "%s\n\n", "valid modes: rc4,aes default:aes")
+	if !types.MemberOf(mode, []string{"rc4", "aes", "attachments", ""}) {
+		fmt.Fprintf(os.Stderr, "%s\n\n", "valid modes: rc4,aes,attachments default:aes")
 		os.Exit(1)
 	}
 
@@ -760,6 +1098,13 @@ func validateEncryptModeFlag() {
 		}
 	}
 
+	if mode == "attachments" {
+		if key != "128" && key != "256" && key != "" {
+			fmt.Fprintf(os.Stderr, "%s\n\n", "supported attachment key lengths: 128,256 default:256")
+			os.Exit(1)
+		}
+	}
+
 }
 
 func validateEncryptFlags() {
@@ -768,6 +1113,14 @@ func validateEncryptFlags() {
 		fmt.Fprintf(os.Stderr, "%s\n\n", "supported permissions: none,print,all default:none (viewing always allowed!)")
 		os.Exit(1)
 	}
+	if metadata != "plain" && metadata != "encrypted" && metadata != "" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", "supported metadata: plain,encrypted default:encrypted")
+		os.Exit(1)
+	}
+	if metadata == "plain" && key == "40" {
+		fmt.Fprintf(os.Stderr, "%s\n\n", "plain metadata requires key length 128 or 256")
+		os.Exit(1)
+	}
 }
 
 func processEncryptCommand(conf *model.Configuration) {
@@ -780,18 +1133,42 @@ func processEncryptCommand(conf *model.Configuration) {
 		os.Exit(1)
 	}
 
-	if conf.OwnerPW == "" {
+	if len(certs) > 0 {
+		// Public-key encryption
+		if conf.OwnerPW != "" || conf.UserPW != "" {
+			fmt.Fprintln(os.Stderr, "please provide either recipient certificates or passwords!")
+			fmt.Fprintf(os.Stderr, "%s\n\n", usageEncrypt)
+			os.Exit(1)
+		}
+		recipients, err := api.ReadCertificatesFile(certs...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		conf.EncryptRecipients = recipients
+	} else if conf.OwnerPW == "" {
 		fmt.Fprintln(os.Stderr, "missing non-empty owner password!")
 		fmt.Fprintf(os.Stderr, "%s\n\n", usageEncrypt)
 		os.Exit(1)
 	}
 
+	if mode == "attachments" && conf.UserPW == "" {
+		fmt.Fprintln(os.Stderr, "missing non-empty user password protecting the attachments!")
+		fmt.Fprintf(os.Stderr, "%s\n\n", usageEncrypt)
+		os.Exit(1)
+	}
+
 	validateEncryptFlags()
 	if perm != "" {
 		perm = permCompletion(perm)
 	}
 
 	conf.EncryptUsingAES = mode != "rc4"
+	conf.EncryptAttachmentsOnly = mode == "attachments"
+
+	if metadata == "plain" {
+		conf.EncryptMetadata = false
+	}
 
 	kl, _ := strconv.Atoi(key)
 	conf.EncryptKeyLength = kl
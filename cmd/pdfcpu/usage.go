@@ -39,7 +39,8 @@ The commands are:
    cut           custom cut pages horizontally or vertically
    decrypt       remove password protection
    encrypt       set password protection		
-   extract       extract images, fonts, content, pages or metadata
+   export        export pages as SVG
+   extract       extract images, fonts, content, pages, metadata or text
    fonts         install, list supported fonts, create cheat sheets
    form          list, remove fields, lock, unlock, reset, export, fill form via JSON or CSV
    grid          rearrange pages or images for enhanced browsing experience
@@ -59,11 +60,19 @@ The commands are:
    portfolio     list, add, remove, extract portfolio entries with optional description
    poster        cut selected pages into poster by paper size or dimensions
    properties    list, add, remove document properties
+   render        render selected pages as images
+   repair        repair corrupt PDF and report all applied fixes
    resize        scale selected pages
    rotate        rotate selected pages
+   redact        remove text, images and graphics from page areas
+   sanitize      remove active and hidden content
+   search        search text for a regular expression, highlight matches
    selectedpages print definition of the -pages flag
+   sign          digitally sign PDF using a PKCS#12 key store
+   signatures    list, verify digital signatures, add long-term validation data
    split         split up a PDF by span or bookmark
    stamp         add, remove, update Unicode text, image or PDF stamps for selected pages
+   timestamp     add RFC 3161 document timestamp
    trim          create trimmed version of selected pages
    validate      validate PDF against PDF 32000-1:2008 (PDF 1.7) + basic PDF 2.0 validation
    version       print version
@@ -84,31 +93,138 @@ common flags: -v(erbose)  ... turn on logging
               -c(onf)     ... set or disable config dir: $path|disable
               -opw        ... owner password
               -upw        ... user password
+              -incr       ... append changes as incremental update keeping digital signatures valid
+                              (annotations, bookmarks, form, properties, stamp, watermark)
               -u(nit)     ... display unit: po(ints) ... points
                                             in(ches) ... inches
                                                   cm ... centimetres
                                                   mm ... millimetres`
 
-	usageValidate = "usage: pdfcpu validate [-m(ode) strict|relaxed] [-l(inks)] inFile..." + generalFlags
+	usageValidate = "usage: pdfcpu validate [-m(ode) strict|relaxed] [-l(inks)] [-j(son)] inFile..." + generalFlags
 
 	usageLongValidate = `Check inFile for specification compliance.
 
       mode ... validation mode
      links ... check for broken links
+      json ... produce a JSON report listing all spec violations instead of stopping at the first one
     inFile ... input PDF file
 		
 The validation modes are:
 
  strict ... validates against PDF 32000-1:2008 (PDF 1.7) and rudimentary against PDF 32000:2 (PDF 2.0)
-relaxed ... (default) like strict but doesn't complain about common seen spec violations.`
+relaxed ... (default) like strict but doesn't complain about common seen spec violations.
 
-	usageOptimize     = "usage: pdfcpu optimize [-stats csvFile] inFile [outFile]" + generalFlags
+The JSON report covers both modes. For each violation it lists
+the object number, the dictionary path eg. Root/Pages/Kids[3]/Annots[0],
+the required PDF version where applicable and the severity:
+
+ strict ... violation in strict mode only
+relaxed ... violation in both modes`
+
+	usageOptimize     = "usage: pdfcpu optimize [-stats csvFile] [-images settings] inFile [outFile]" + generalFlags
 	usageLongOptimize = `Read inFile, remove redundant page resources like embedded fonts and images and write the result to outFile.
 
      stats ... appends a stats line to a csv file with information about the usage of root and page entries.
                useful for batch optimization and debugging PDFs.
+    images ... downsample and recompress images, comma separated list of:
+                  dpi       ... target resolution (default: 150)
+                  threshold ... downsample images placed above this resolution (default: 1.5 * dpi)
+                  jpeg      ... JPEG quality 1..100 for photographic images (default: 75)
     inFile ... input PDF file
-   outFile ... output PDF file`
+   outFile ... output PDF file
+
+Images are recompressed as JPEG (photographic), CCITT (black and white) or Flate (anything else)
+and only replaced if the result is smaller.
+
+e.g. pdfcpu optimize in.pdf out.pdf
+     pdfcpu optimize -images dpi=150,jpeg=75 in.pdf out.pdf`
+
+	usageRepair     = "usage: pdfcpu repair [-j(son)] inFile [outFile]" + generalFlags
+	usageLongRepair = `Read inFile in relaxed mode, fix all known problems and write the result to outFile.
+
+      json ... produce a JSON report listing each fix with object number and category
+    inFile ... input PDF file
+   outFile ... output PDF file
+
+The fix categories are:
+
+ xref          ... missing or wrong xref table entries
+ streamLength  ... wrong stream length
+ freeObjectRef ... references to free objects
+ pageCount     ... missing or wrong page tree count
+ catalog       ... missing or wrong catalog reference
+ object        ... corrupt objects`
+
+	usageSign     = "usage: pdfcpu sign -cert p12File [-certpw password] [-field name] [-m(ode) pades|pkcs7] [-tsa url] [description] inFile [outFile]" + generalFlags
+	usageLongSign = `Digitally sign inFile and write the result to outFile.
+The signature gets appended as incremental update keeping existing signatures valid.
+
+        cert ... PKCS#12 key store containing the private key and certificate chain
+      certpw ... PKCS#12 key store password
+       field ... signature field name, the field gets created unless present (default: Signature1)
+        mode ... signature encoding:
+                    pades ... ETSI.CAdES.detached (default)
+                    pkcs7 ... adbe.pkcs7.detached
+         tsa ... URL of a RFC 3161 time stamping authority, adds a signature timestamp (PAdES B-T)
+ description ... comma separated configuration string
+      inFile ... input PDF file
+     outFile ... output PDF file
+
+A signature is invisible unless you specify a rect for the signature widget.
+
+<description> is a comma separated configuration string containing these optional entries:
+
+   (defaults: page:1, fontname:Helvetica, points:8)
+
+   reason:    reason for signing
+   location:  location of signing
+   contact:   contact info of the signer
+   name:      name of the signer, defaults to the common name of the signing certificate
+   page:      page of the signature widget
+   rect:      rectangle of the signature widget in display units: "llx lly urx ury"
+   fontname:  font for the signature widget
+   points:    font size for the signature widget
+
+All configuration string parameters support completion.
+
+e.g. pdfcpu sign -cert id.p12 in.pdf out.pdf
+     pdfcpu sign -cert id.p12 -field Signature1 -mode pkcs7 in.pdf out.pdf
+     pdfcpu sign -cert id.p12 -tsa http://tsa.example.com in.pdf out.pdf
+     pdfcpu sign -cert id.p12 "reason:approved, rect:400 50 580 100" in.pdf out.pdf`
+
+	usageSignaturesList   = "pdfcpu signatures list   [-j(son)] inFile"
+	usageSignaturesVerify = "pdfcpu signatures verify [-j(son)] [-trust dir] inFile"
+	usageSignaturesDSS    = "pdfcpu signatures dss    inFile [outFile] file..."
+
+	usageSignatures = "usage: " + usageSignaturesList +
+		"\n       " + usageSignaturesVerify +
+		"\n       " + usageSignaturesDSS + generalFlags
+
+	usageLongSignatures = `List or verify the digital signatures of inFile or add long-term validation data.
+
+       json ... produce JSON output
+      trust ... trust store directory containing PEM or DER encoded root certificates (default: system certificates)
+     inFile ... input PDF file
+    outFile ... output PDF file
+       file ... DER or PEM encoded certificate, CRL or OCSP response
+
+For each signature field and each signature referenced by the document permissions (DocMDP, UR3)
+the signer certificate, the signing time, the sub filter and the covered byte ranges are reported
+together with the number of incremental updates written after signing.
+
+verify checks the message digest of the signed byte ranges, the signature
+and whether the signer certificate chains up to a trusted root certificate.
+The certificate chain gets validated at the time of a verified signature timestamp,
+otherwise at the current time. The signing time claimed by the signer is informational only.
+
+dss adds certificates, CRLs and OCSP responses to the Document Security Store (PAdES B-LT)
+and registers them as validation related information for every signature.
+The DSS gets written as incremental update keeping existing signatures valid.
+
+e.g. pdfcpu signatures list signed.pdf
+     pdfcpu signatures verify -trust certs signed.pdf
+     pdfcpu signatures verify -j signed.pdf
+     pdfcpu signatures dss signed.pdf signed-lt.pdf ca.cer ca.crl signer.ocsp`
 
 	usageSplit     = "usage: pdfcpu split [-m(ode) span|bookmark|page] inFile outDir [span|pageNr...]" + generalFlags
 	usageLongSplit = `Generate a set of PDFs for the input file in outDir according to given span value or along bookmarks or page numbers.
@@ -195,11 +311,28 @@ Skip bookmark creation like so: -bookmarks=false`
 
         e.g. -3,5,7- or 4-7,!6 or 1-,!5 or odd,n1`
 
-	usageExtract     = "usage: pdfcpu extract -m(ode) i(mage)|f(ont)|c(ontent)|p(age)|m(eta) [-p(ages) selectedPages] inFile outDir" + generalFlags
-	usageLongExtract = `Export inFile's images, fonts, content or pages into outDir.
+	usageExport     = "usage: pdfcpu export [-p(ages) selectedPages] [-format svg] inFile outDir" + generalFlags
+	usageLongExport = `Export selected pages of inFile as vector graphics into outDir.
+
+     pages ... Please refer to "pdfcpu selectedpages"
+    format ... output format: svg (default: svg)
+    inFile ... input PDF file
+    outDir ... output directory
+
+Each page is written to <inFile>_page_<nr>.svg.
+Paths, images and text are converted to SVG elements,
+text is positioned glyph by glyph using fonts installed on the viewing system.
+Shadings, patterns and image masks are embedded as bitmaps.
+
+e.g. pdfcpu export in.pdf out
+     pdfcpu export -format svg -pages 1-3 in.pdf out`
+
+	usageExtract     = "usage: pdfcpu extract -m(ode) i(mage)|f(ont)|c(ontent)|p(age)|m(eta)|t(ext) [-p(ages) selectedPages] [-j(son)] inFile outDir" + generalFlags
+	usageLongExtract = `Export inFile's images, fonts, content, pages or text into outDir.
 
       mode ... extraction mode
      pages ... Please refer to "pdfcpu selectedpages"
+      json ... text mode only: produce JSON including fonts and bounding boxes
     inFile ... input PDF file
     outDir ... output directory
 
@@ -210,9 +343,110 @@ Skip bookmark creation like so: -bookmarks=false`
 content ... extract raw page content
    page ... extract single page PDFs
    meta ... extract all metadata (page selection does not apply)
+   text ... extract page text in reading order
    
 `
 
+	usageSanitize     = "usage: pdfcpu sanitize [-categories list] [-j(son)] inFile [outFile]" + generalFlags
+	usageLongSanitize = `Remove active and hidden content from inFile, write the result to outFile
+and report what was removed.
+
+ categories ... comma separated list of categories to remove (default: all)
+       json ... produce the report as JSON
+     inFile ... input PDF file
+    outFile ... output PDF file
+
+The categories are:
+
+    javascript ... document JavaScript, JavaScript actions of the document, pages, annotations and form fields
+       actions ... Launch, ImportData and SubmitForm actions
+   attachments ... embedded files and file attachment annotations
+           xfa ... XFA forms
+    thumbnails ... page thumbnails
+        layers ... optional content hidden by default along with the hidden layers
+     pieceinfo ... private application data
+      metadata ... XMP metadata streams and the document information dict
+           all ... all of the above
+
+The whole file gets rewritten, -incr does not apply.
+
+e.g. pdfcpu sanitize in.pdf out.pdf
+     pdfcpu sanitize -categories javascript,actions -j in.pdf`
+
+	usageRender     = "usage: pdfcpu render [-p(ages) selectedPages] [-dpi n] [-format png|jpg] inFile outDir" + generalFlags
+	usageLongRender = `Render selected pages of inFile as images into outDir.
+
+     pages ... Please refer to "pdfcpu selectedpages"
+       dpi ... resolution in dots per inch (default: 150)
+    format ... image file format: png|jpg (default: png)
+    inFile ... input PDF file
+    outDir ... output directory
+
+Each page is written to <inFile>_page_<nr>.png|jpg.
+The renderer is meant for previews and thumbnails.
+Fonts without embedded font program are substituted,
+transparency groups and blend modes are not supported.
+
+e.g. pdfcpu render in.pdf out
+     pdfcpu render -dpi 300 -pages 1-3 -format jpg in.pdf out`
+
+	usageSearch     = "usage: pdfcpu search [-p(ages) selectedPages] [-j(son)] [-highlight] regexp inFile [outFile]" + generalFlags
+	usageLongSearch = `Search the text of selected pages for a regular expression.
+Matches are listed along with their page and bounding box
+or highlighted by adding highlight annotations.
+
+     pages ... Please refer to "pdfcpu selectedpages"
+      json ... list matches as JSON including a bounding box per line
+ highlight ... add a highlight annotation for each match and write the result to outFile
+    regexp ... regular expression (RE2 syntax), use (?i) for case insensitive search
+    inFile ... input PDF file
+   outFile ... output PDF file (highlight only)
+
+Lines are separated by a single newline, a match may span lines.
+
+e.g. pdfcpu search "(?i)force majeure" contract.pdf
+     pdfcpu search -j -p 1-3 "\d+\.\d{2} EUR" invoice.pdf
+     pdfcpu search -highlight "Invoice No\.\s+\d+" in.pdf out.pdf`
+
+	usageRedact     = "usage: pdfcpu redact [-p(ages) selectedPages] [-rect \"llx lly urx ury\"]... [-regexp regexp] [-mark] inFile [outFile]" + generalFlags
+	usageLongRedact = `Remove all text, image samples and vector graphics intersecting redaction areas from selected pages
+and cover the areas with black boxes. Form XObjects get redacted as well.
+
+    pages ... Please refer to "pdfcpu selectedpages"
+     rect ... redaction area applying to each selected page in display unit (repeatable)
+   regexp ... redact all matches of a regular expression (RE2 syntax)
+     mark ... add redact annotations for review instead of removing content
+   inFile ... input PDF file
+  outFile ... output PDF file
+
+Redact annotations of selected pages are applied along with the given areas and removed afterwards.
+Without -rect and -regexp only existing redact annotations are applied.
+Glyphs get removed if their center lies within an area or if they are covered by at least 20%.
+Images that cannot be decoded (JPX, CCITT and CMYK JPEG) are removed as a whole.
+Other annotations intersecting an area are removed along with their popups and replies,
+widgets along with their form fields.
+The whole file gets rewritten, -incr does not apply.
+
+e.g. pdfcpu redact -regexp "\d{3}-\d{2}-\d{4}" in.pdf out.pdf
+     pdfcpu redact -p 1 -u cm -rect "2 25 10 27" in.pdf out.pdf
+     pdfcpu redact -mark -regexp "(?i)confidential" in.pdf
+     pdfcpu redact in.pdf`
+
+	usageTimestamp     = "usage: pdfcpu timestamp -tsa url [-field name] inFile [outFile]" + generalFlags
+	usageLongTimestamp = `Add a RFC 3161 document timestamp (ETSI.RFC3161) to inFile and write the result to outFile.
+The timestamp gets appended as incremental update keeping existing signatures valid.
+
+      tsa ... URL of a RFC 3161 time stamping authority
+    field ... signature field name, the field gets created unless present (default: Signature1)
+   inFile ... input PDF file
+  outFile ... output PDF file
+
+A document timestamp written after adding long-term validation data
+(see pdfcpu signatures dss) protects the DSS and all signatures (PAdES B-LTA).
+
+e.g. pdfcpu timestamp -tsa http://tsa.example.com signed.pdf
+     pdfcpu timestamp -tsa http://tsa.example.com -field DocTimeStamp1 signed-lt.pdf signed-lta.pdf`
+
 	usageTrim     = "usage: pdfcpu trim -p(ages) selectedPages inFile [outFile]" + generalFlags
 	usageLongTrim = `Generate a trimmed version of inFile for selected pages.
 
@@ -299,22 +533,37 @@ content ... extract raw page content
      11: Assemble document (security handlers >= rev.3)
      12: Print (security handlers >= rev.3)`
 
-	usageEncrypt     = "usage: pdfcpu encrypt [-m(ode) rc4|aes] [-key 40|128|256] [-perm none|print|all] [-upw userpw] -opw ownerpw inFile [outFile]" + generalFlags
-	usageLongEncrypt = `Setup password protection based on user and owner password.
+	usageEncrypt = "usage: pdfcpu encrypt [-m(ode) rc4|aes|attachments] [-key 40|128|256] [-perm none|print|all] [-metadata plain|encrypted] [-upw userpw] -opw ownerpw inFile [outFile]" +
+		"\n       pdfcpu encrypt [-key 128|256] [-perm none|print|all] [-metadata plain|encrypted] -cert certFile... inFile [outFile]" + generalFlags
+	usageLongEncrypt = `Setup password protection based on user and owner password
+or public-key encryption for the owners of the recipient certificates.
 
-      mode ... algorithm (default=aes)
+      mode ... algorithm (default=aes), attachments: aes for embedded files only
        key ... key length in bits (default=256)
       perm ... user access permissions
+  metadata ... plain: leave metadata streams unencrypted for search indexers (default=encrypted)
+      cert ... PEM or DER encoded X.509 certificate of a recipient, may be repeated
     inFile ... input PDF file
    outFile ... output PDF file
    
-   PDF 2.0 files have to be encrypted using aes/256.`
+   PDF 2.0 files have to be encrypted using aes/256.
+   Public-key encryption (Adobe.PubSec, adbe.pkcs7.s5) requires aes and RSA recipient keys.
+   Mode attachments leaves the document readable and protects embedded files
+   with the user password (PDF 1.6, key length 128 or 256).
+
+e.g. pdfcpu encrypt -opw secret in.pdf out.pdf
+     pdfcpu encrypt -cert alice.pem -cert bob.pem in.pdf out.pdf
+     pdfcpu encrypt -mode attachments -upw secret -opw owner in.pdf out.pdf`
 
-	usageDecrypt     = "usage: pdfcpu decrypt [-upw userpw] [-opw ownerpw] inFile [outFile]" + generalFlags
-	usageLongDecrypt = `Remove password protection and reset permissions.
+	usageDecrypt     = "usage: pdfcpu decrypt [-upw userpw] [-opw ownerpw] [-key keyFile] inFile [outFile]" + generalFlags
+	usageLongDecrypt = `Remove password protection or public-key encryption and reset permissions.
 
+       key ... PEM or DER encoded RSA private key of a recipient
     inFile ... input PDF file
-   outFile ... output PDF file`
+   outFile ... output PDF file
+
+e.g. pdfcpu decrypt -upw secret in.pdf out.pdf
+     pdfcpu decrypt -key alice.key in.pdf out.pdf`
 
 	usageChangeUserPW     = "usage: pdfcpu changeupw [-opw ownerpw] inFile upwOld upwNew" + generalFlags
 	usageLongChangeUserPW = `Change the user password also known as the open doc password.
@@ -564,6 +813,7 @@ description ... dimensions, formsize, position, offset, scale factor, boxes
   
   Only one of dimensions or formsize is allowed.
   position: full => image dimensions equal page dimensions.
+  Black and white PNG and TIFF images are stored CCITT Group 4 encoded.
   
   All configuration string parameters support completion.
 
@@ -76,13 +76,11 @@ func ReadContext(rs io.ReadSeeker, conf *model.Configuration) (*model.Context, e
 
 // ReadContextFile returns inFile's validated context.
 func ReadContextFile(inFile string) (*model.Context, error) {
-	f, err := os.Open(inFile)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
+	// Validation loads every single object anyway.
+	conf := model.NewDefaultConfiguration()
+	conf.LazyRead = false
 
-	ctx, err := ReadContext(f, model.NewDefaultConfiguration())
+	ctx, err := pdfcpu.ReadFile(inFile, conf)
 	if err != nil {
 		return nil, err
 	}
@@ -125,6 +123,71 @@ func WriteContext(ctx *model.Context, w io.Writer) error {
 	return pdfcpu.Write(ctx)
 }
 
+func copyOriginal(rs io.ReadSeeker, w io.Writer) (int64, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(w, rs)
+	if err != nil || n == 0 {
+		return n, err
+	}
+
+	// Make sure the increment starts on a new line.
+	if _, err := rs.Seek(-1, io.SeekEnd); err != nil {
+		return 0, err
+	}
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(rs, b); err != nil {
+		return 0, err
+	}
+	if b[0] != '\n' && b[0] != '\r' {
+		if _, err := w.Write([]byte{'\n'}); err != nil {
+			return 0, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// WriteContextIncrementally writes the unmodified input file of ctx followed by a PDF increment
+// containing all objects of ctx that got added, modified or freed since reading.
+// ctx needs to be read with conf.Incremental set in order to track changes.
+// The byte ranges of existing digital signatures remain intact.
+func WriteContextIncrementally(ctx *model.Context, w io.Writer) error {
+	rs := ctx.Read.RS
+	if rs == nil {
+		return errors.New("pdfcpu: WriteContextIncrementally: missing input file")
+	}
+
+	if ctx.Read.XRefReconstructed {
+		return errors.New("pdfcpu: WriteContextIncrementally: corrupt cross reference table (Hint: Use pdfcpu repair then try again)")
+	}
+
+	if *ctx.HeaderVersion < model.V14 {
+		return errors.New("Incremental writing not supported for PDF version < V1.4 (Hint: Use pdfcpu optimize then try again)")
+	}
+
+	n, err := pdfcpu.PrepareIncrement(ctx)
+	if err != nil {
+		return err
+	}
+
+	if log.CLIEnabled() {
+		log.CLI.Printf("writing increment with %d objects...\n", n)
+	}
+
+	offset, err := copyOriginal(rs, w)
+	if err != nil {
+		return err
+	}
+
+	ctx.Write.Offset = offset
+
+	return WriteIncrement(ctx, w)
+}
+
 // WriteIncrement writes a PDF increment for ctx to w.
 func WriteIncrement(ctx *model.Context, w io.Writer) error {
 	ctx.Write.Writer = bufio.NewWriter(w)
@@ -148,6 +211,14 @@ func ReadAndValidate(rs io.ReadSeeker, conf *model.Configuration) (ctx *model.Co
 		return nil, err
 	}
 
+	if ctx.LazyRead {
+		// Full validation would load every single object.
+		if err := validate.XRefTableLight(ctx.XRefTable); err != nil {
+			return nil, err
+		}
+		return ctx, nil
+	}
+
 	if err := ValidateContext(ctx); err != nil {
 		return nil, err
 	}
@@ -179,7 +250,9 @@ func ReadValidateAndOptimize(rs io.ReadSeeker, conf *model.Configuration) (ctx *
 	// With the exception of commands utilizing structs provided the Optimize step
 	// command optimization of the cross reference table is optional but usually recommended.
 	// For large or complex files it may make sense to skip optimization and set conf.Optimize = false.
-	if cmdAssumingOptimization(conf.Cmd) || conf.Optimize {
+	// Optional optimization is skipped for lazily read files since it would load every single object.
+	// Optional optimization is also skipped for incremental writing in order to keep the increment small.
+	if cmdAssumingOptimization(conf.Cmd) || (conf.Optimize && !conf.LazyRead && !conf.Incremental) {
 		if err = OptimizeContext(ctx); err != nil {
 			return nil, err
 		}
@@ -204,6 +277,10 @@ func Write(ctx *model.Context, w io.Writer, conf *model.Configuration) error {
 		log.Stats.Printf("XRefTable:\n%s\n", ctx)
 	}
 
+	if ctx.Incremental {
+		return WriteContextIncrementally(ctx, w)
+	}
+
 	// Note side effects of validation before writing!
 	// if conf.PostProcessValidate {
 	// 	if err := ValidateContext(ctx); err != nil {
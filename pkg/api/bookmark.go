@@ -142,7 +142,7 @@ func ImportBookmarks(rs io.ReadSeeker, rd io.Reader, w io.Writer, replace bool,
 		return ErrOutlines
 	}
 
-	return WriteContext(ctx, w)
+	return Write(ctx, w, conf)
 }
 
 // ImportBookmarks creates/replaces outlines in inFilePDF and writes the result to outFilePDF.
@@ -213,7 +213,7 @@ func AddBookmarks(rs io.ReadSeeker, w io.Writer, bms []pdfcpu.Bookmark, replace
 		return err
 	}
 
-	return WriteContext(ctx, w)
+	return Write(ctx, w, conf)
 }
 
 // AddBookmarksFile adds outlines to the PDF context read from inFile and writes the result to outFile.
@@ -280,7 +280,7 @@ func RemoveBookmarks(rs io.ReadSeeker, w io.Writer, conf *model.Configuration) e
 		return ErrNoOutlines
 	}
 
-	return WriteContext(ctx, w)
+	return Write(ctx, w, conf)
 }
 
 // RemoveBookmarksFile deletes outlines from inFile and writes the result to outFile.
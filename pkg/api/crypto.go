@@ -17,15 +17,19 @@
 package api
 
 import (
+	"crypto"
+	"crypto/x509"
 	"io"
 	"os"
 
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/pkg/errors"
 )
 
 // Encrypt reads a PDF stream from rs and writes the encrypted PDF stream to w.
 // A configuration containing at least the current passwords is required.
+// Public-key encryption (Adobe.PubSec) for conf.EncryptRecipients replaces the passwords.
 func Encrypt(rs io.ReadSeeker, w io.Writer, conf *model.Configuration) error {
 	if rs == nil {
 		return errors.New("pdfcpu: Encrypt: missing rs")
@@ -285,3 +289,38 @@ func ChangeOwnerPasswordFile(inFile, outFile string, pwOld, pwNew string, conf *
 
 	return ChangeOwnerPassword(f1, f2, pwOld, pwNew, conf)
 }
+
+// ReadCertificatesFile returns all PEM or DER encoded certificates contained in files,
+// eg. the recipients for public-key encryption.
+func ReadCertificatesFile(files ...string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for _, fn := range files {
+		bb, err := os.ReadFile(fn)
+		if err != nil {
+			return nil, err
+		}
+		cc, err := pdfcpu.ParseCertificates(bb)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s", fn)
+		}
+		if len(cc) == 0 {
+			return nil, errors.Errorf("pdfcpu: no certificate found in %s", fn)
+		}
+		certs = append(certs, cc...)
+	}
+	return certs, nil
+}
+
+// ReadPrivateKeyFile returns the PEM or DER encoded RSA private key of keyFile,
+// eg. for opening files using public-key encryption.
+func ReadPrivateKeyFile(keyFile string) (crypto.Decrypter, error) {
+	bb, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := pdfcpu.ParsePrivateKey(bb)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s", keyFile)
+	}
+	return key, nil
+}
@@ -18,6 +18,7 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -29,6 +30,7 @@ import (
 	"github.com/pdfcpu/pdfcpu/pkg/log"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/text"
 	"github.com/pkg/errors"
 )
 
@@ -368,6 +370,89 @@ func ExtractContentFile(inFile, outDir string, selectedPages []string, conf *mod
 	return ExtractContent(f, outDir, inFile, selectedPages, conf)
 }
 
+// ExtractTextRaw returns the text of selectedPages of rs in reading order including positions and fonts.
+func ExtractTextRaw(rs io.ReadSeeker, selectedPages []string, conf *model.Configuration) ([]text.Page, error) {
+	if rs == nil {
+		return nil, errors.New("pdfcpu: ExtractText: missing rs")
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.EXTRACTTEXT
+
+	ctx, err := ReadValidateAndOptimize(rs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := PagesForPageSelection(ctx.PageCount, selectedPages, true, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var pp []text.Page
+	for i := 1; i <= ctx.PageCount; i++ {
+		if !pages[i] {
+			continue
+		}
+		p, err := text.ExtractPage(ctx.XRefTable, i)
+		if err != nil {
+			return nil, err
+		}
+		pp = append(pp, *p)
+	}
+
+	return pp, nil
+}
+
+// ExtractText dumps the text of selected pages of rs into outDir.
+// The text is written either as plain text in reading order or as JSON including fonts and bounding boxes.
+func ExtractText(rs io.ReadSeeker, outDir, fileName string, selectedPages []string, jsonOut bool, conf *model.Configuration) error {
+	pp, err := ExtractTextRaw(rs, selectedPages, conf)
+	if err != nil {
+		return err
+	}
+
+	fileName = strings.TrimSuffix(filepath.Base(fileName), ".pdf")
+
+	for _, p := range pp {
+		var bb []byte
+		ext := "txt"
+		if jsonOut {
+			if bb, err = json.MarshalIndent(p, "", "\t"); err != nil {
+				return err
+			}
+			ext = "json"
+		} else {
+			bb = []byte(p.Text())
+		}
+
+		outFile := filepath.Join(outDir, fmt.Sprintf("%s_Text_page_%d.%s", fileName, p.Number, ext))
+		logWritingTo(outFile)
+		if err := os.WriteFile(outFile, bb, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExtractTextFile dumps the text of selected pages of inFile into outDir.
+func ExtractTextFile(inFile, outDir string, selectedPages []string, jsonOut bool, conf *model.Configuration) error {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if log.CLIEnabled() {
+		log.CLI.Printf("extracting text from %s into %s/ ...\n", inFile, outDir)
+	}
+
+	return ExtractText(f, outDir, inFile, selectedPages, jsonOut, conf)
+}
+
 // ExtractMetadata dumps all metadata dict entries for rs into outDir.
 func ExtractMetadata(rs io.ReadSeeker, outDir, fileName string, conf *model.Configuration) error {
 	if rs == nil {
@@ -0,0 +1,266 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package api
+
+import (
+	"io"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/log"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/color"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/text"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// redactionAreas returns the redaction areas for selected pages of ctx
+// given by rects applying to each selected page and the matches of pattern.
+func redactionAreas(ctx *model.Context, selectedPages []string, rects []types.Rectangle, pattern string, col *color.SimpleColor) (types.IntSet, map[int][]pdfcpu.RedactionArea, []text.Match, error) {
+	pages, err := PagesForPageSelection(ctx.PageCount, selectedPages, true, true)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	m := map[int][]pdfcpu.RedactionArea{}
+
+	for pageNr := range pages {
+		for _, r := range rects {
+			m[pageNr] = append(m[pageNr], pdfcpu.RedactionArea{Rect: r, Col: col})
+		}
+	}
+
+	var mm []text.Match
+
+	if pattern != "" {
+		if mm, err = searchText(ctx, selectedPages, pattern); err != nil {
+			return nil, nil, nil, err
+		}
+		for _, match := range mm {
+			for _, r := range match.Rects {
+				m[match.Page] = append(m[match.Page], pdfcpu.RedactionArea{Rect: r, Col: col})
+			}
+		}
+	}
+
+	return pages, m, mm, nil
+}
+
+// Redact removes all text, image samples and vector graphics intersecting redaction areas
+// from selected pages of rs, paints the overlay boxes and writes the result to w.
+// Redaction areas are given by rects applying to each selected page, by the matches of the regular expression pattern
+// and by the redact annotations of selected pages, which get removed.
+// Other annotations intersecting redaction areas get removed as well.
+// The default overlay color is black.
+func Redact(rs io.ReadSeeker, w io.Writer, selectedPages []string, rects []types.Rectangle, pattern string, col *color.SimpleColor, conf *model.Configuration) ([]text.Match, error) {
+	if rs == nil {
+		return nil, errors.New("pdfcpu: Redact: missing rs")
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.REDACT
+
+	// An increment would keep the redacted content in the original revision.
+	conf.Incremental = false
+
+	if col == nil {
+		col = &color.Black
+	}
+
+	ctx, err := ReadValidateAndOptimize(rs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	pages, m, mm, err := redactionAreas(ctx, selectedPages, rects, pattern, col)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pdfcpu.Redact(ctx, pages, m); err != nil {
+		return nil, err
+	}
+
+	return mm, Write(ctx, w, conf)
+}
+
+// RedactFile removes all text, image samples and vector graphics intersecting redaction areas
+// from selected pages of inFile, paints the overlay boxes and writes the result to outFile.
+func RedactFile(inFile, outFile string, selectedPages []string, rects []types.Rectangle, pattern string, col *color.SimpleColor, conf *model.Configuration) (mm []text.Match, err error) {
+	var f1, f2 *os.File
+
+	if f1, err = os.Open(inFile); err != nil {
+		return nil, err
+	}
+
+	tmpFile := inFile + ".tmp"
+	if outFile != "" && inFile != outFile {
+		tmpFile = outFile
+		logWritingTo(outFile)
+	} else {
+		logWritingTo(inFile)
+	}
+
+	if f2, err = os.Create(tmpFile); err != nil {
+		f1.Close()
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			f2.Close()
+			f1.Close()
+			os.Remove(tmpFile)
+			return
+		}
+		if err = f2.Close(); err != nil {
+			return
+		}
+		if err = f1.Close(); err != nil {
+			return
+		}
+		if outFile == "" || inFile == outFile {
+			err = os.Rename(tmpFile, inFile)
+		}
+	}()
+
+	if log.CLIEnabled() {
+		log.CLI.Printf("redacting %s ...\n", inFile)
+	}
+
+	return Redact(f1, f2, selectedPages, rects, pattern, col, conf)
+}
+
+func redactAnnotation(r types.Rectangle, contents string, col *color.SimpleColor) model.RedactAnnotation {
+	return model.NewRedactAnnotation(
+		r,              // rect
+		contents,       // contents
+		"",             // id
+		"",             // modDate
+		model.AnnPrint, // f
+		&color.Red,     // col
+		"",             // title
+		nil,            // popupIndRef
+		nil,            // ca
+		"",             // rc
+		"",             // subject
+		nil,            // quad points
+		col,            // fill color
+		"",             // overlay text
+	)
+}
+
+// MarkRedactions adds a redact annotation for each redaction area of selected pages of rs and writes the result to w.
+// Redaction areas are given by rects applying to each selected page and by the matches of the regular expression pattern.
+// No content gets removed until the annotations are applied using Redact.
+// The default overlay color is black.
+func MarkRedactions(rs io.ReadSeeker, w io.Writer, selectedPages []string, rects []types.Rectangle, pattern string, col *color.SimpleColor, conf *model.Configuration) ([]text.Match, error) {
+	if rs == nil {
+		return nil, errors.New("pdfcpu: MarkRedactions: missing rs")
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.REDACT
+
+	// An increment would keep the redacted content in the original revision.
+	conf.Incremental = false
+
+	if col == nil {
+		col = &color.Black
+	}
+
+	ctx, err := ReadValidateAndOptimize(rs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	pages, _, mm, err := redactionAreas(ctx, selectedPages, nil, pattern, col)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[int][]model.AnnotationRenderer{}
+
+	for pageNr := range pages {
+		for _, r := range rects {
+			m[pageNr] = append(m[pageNr], redactAnnotation(r, "", col))
+		}
+	}
+
+	for _, match := range mm {
+		ann := redactAnnotation(matchRect(match), match.Text, col)
+		ann.Quad = match.Quads
+		m[match.Page] = append(m[match.Page], ann)
+	}
+
+	if _, err := pdfcpu.AddAnnotationsMap(ctx, m, false); err != nil {
+		return nil, err
+	}
+
+	return mm, Write(ctx, w, conf)
+}
+
+// MarkRedactionsFile adds a redact annotation for each redaction area of selected pages of inFile and writes the result to outFile.
+func MarkRedactionsFile(inFile, outFile string, selectedPages []string, rects []types.Rectangle, pattern string, col *color.SimpleColor, conf *model.Configuration) (mm []text.Match, err error) {
+	var f1, f2 *os.File
+
+	if f1, err = os.Open(inFile); err != nil {
+		return nil, err
+	}
+
+	tmpFile := inFile + ".tmp"
+	if outFile != "" && inFile != outFile {
+		tmpFile = outFile
+		logWritingTo(outFile)
+	} else {
+		logWritingTo(inFile)
+	}
+
+	if f2, err = os.Create(tmpFile); err != nil {
+		f1.Close()
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			f2.Close()
+			f1.Close()
+			os.Remove(tmpFile)
+			return
+		}
+		if err = f2.Close(); err != nil {
+			return
+		}
+		if err = f1.Close(); err != nil {
+			return
+		}
+		if outFile == "" || inFile == outFile {
+			err = os.Rename(tmpFile, inFile)
+		}
+	}()
+
+	if log.CLIEnabled() {
+		log.CLI.Printf("marking redactions in %s ...\n", inFile)
+	}
+
+	return MarkRedactions(f1, f2, selectedPages, rects, pattern, col, conf)
+}
@@ -0,0 +1,218 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/log"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/render"
+	"github.com/pkg/errors"
+)
+
+// DefaultRenderDPI is the resolution used for rendering if none is given.
+const DefaultRenderDPI = 150
+
+// PageSVG writes page pageNr of ctx as SVG to w.
+func PageSVG(ctx *model.Context, pageNr int, w io.Writer) error {
+	if ctx == nil {
+		return errors.New("pdfcpu: PageSVG: missing ctx")
+	}
+	if pageNr < 1 || pageNr > ctx.PageCount {
+		return errors.Errorf("pdfcpu: PageSVG: invalid page number: %d", pageNr)
+	}
+	return render.SVG(ctx, pageNr, w)
+}
+
+// RenderPage rasterizes page pageNr of ctx at dpi dots per inch.
+func RenderPage(ctx *model.Context, pageNr, dpi int) (image.Image, error) {
+	if ctx == nil {
+		return nil, errors.New("pdfcpu: RenderPage: missing ctx")
+	}
+	if pageNr < 1 || pageNr > ctx.PageCount {
+		return nil, errors.Errorf("pdfcpu: RenderPage: invalid page number: %d", pageNr)
+	}
+	if dpi <= 0 {
+		dpi = DefaultRenderDPI
+	}
+	return render.Page(ctx, pageNr, dpi)
+}
+
+func encodeImage(w io.Writer, img image.Image, format string) error {
+	if format == "jpg" {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+	}
+	return png.Encode(w, img)
+}
+
+func writeRenderedPage(img image.Image, outDir, fileName string, pageNr int, format string) error {
+	outFile := filepath.Join(outDir, fmt.Sprintf("%s_page_%d.%s", fileName, pageNr, format))
+	logWritingTo(outFile)
+
+	w, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	if err := encodeImage(w, img, format); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Render rasterizes selected pages of rs at dpi dots per inch
+// and writes them as png or jpg files named after fileName into outDir.
+func Render(rs io.ReadSeeker, outDir, fileName string, selectedPages []string, dpi int, format string, conf *model.Configuration) error {
+	if rs == nil {
+		return errors.New("pdfcpu: Render: missing rs")
+	}
+
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "jpg" {
+		return errors.Errorf("pdfcpu: Render: unsupported image format: %s", format)
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.RENDER
+
+	ctx, err := ReadValidateAndOptimize(rs, conf)
+	if err != nil {
+		return err
+	}
+
+	pages, err := PagesForPageSelection(ctx.PageCount, selectedPages, true, true)
+	if err != nil {
+		return err
+	}
+
+	fileName = strings.TrimSuffix(filepath.Base(fileName), ".pdf")
+
+	for i, v := range pages {
+		if !v {
+			continue
+		}
+		img, err := RenderPage(ctx, i, dpi)
+		if err != nil {
+			return err
+		}
+		if err := writeRenderedPage(img, outDir, fileName, i, format); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RenderFile rasterizes selected pages of inFile at dpi dots per inch and writes them as png or jpg files into outDir.
+func RenderFile(inFile, outDir string, selectedPages []string, dpi int, format string, conf *model.Configuration) error {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if log.CLIEnabled() {
+		log.CLI.Printf("rendering %s into %s/ ...\n", inFile, outDir)
+	}
+
+	return Render(f, outDir, inFile, selectedPages, dpi, format, conf)
+}
+
+func writeSVG(ctx *model.Context, outDir, fileName string, pageNr int) error {
+	outFile := filepath.Join(outDir, fmt.Sprintf("%s_page_%d.svg", fileName, pageNr))
+	logWritingTo(outFile)
+
+	w, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	if err := PageSVG(ctx, pageNr, w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Export converts selected pages of rs into files of given format named after fileName in outDir.
+// The only supported format is svg.
+func Export(rs io.ReadSeeker, outDir, fileName string, selectedPages []string, format string, conf *model.Configuration) error {
+	if rs == nil {
+		return errors.New("pdfcpu: Export: missing rs")
+	}
+
+	if format == "" {
+		format = "svg"
+	}
+	if format != "svg" {
+		return errors.Errorf("pdfcpu: Export: unsupported format: %s", format)
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.EXPORT
+
+	ctx, err := ReadValidateAndOptimize(rs, conf)
+	if err != nil {
+		return err
+	}
+
+	pages, err := PagesForPageSelection(ctx.PageCount, selectedPages, true, true)
+	if err != nil {
+		return err
+	}
+
+	fileName = strings.TrimSuffix(filepath.Base(fileName), ".pdf")
+
+	for i, v := range pages {
+		if !v {
+			continue
+		}
+		if err := writeSVG(ctx, outDir, fileName, i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExportFile converts selected pages of inFile into files of given format in outDir.
+func ExportFile(inFile, outDir string, selectedPages []string, format string, conf *model.Configuration) error {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if log.CLIEnabled() {
+		log.CLI.Printf("exporting %s into %s/ ...\n", inFile, outDir)
+	}
+
+	return Export(f, outDir, inFile, selectedPages, format, conf)
+}
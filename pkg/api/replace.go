@@ -0,0 +1,36 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package api
+
+import (
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ReplaceText replaces all occurrences of old by new in text shown by selected pages of ctx
+// including stamps and watermarks and re-encodes the replacement using the font in effect.
+// Supported are simple fonts and Identity-H encoded composite fonts as created by pdfcpu.
+// Missing glyphs are added to embedded user font subsets.
+// ReplaceText returns the number of replacements along with all occurrences which could not be replaced.
+func ReplaceText(ctx *model.Context, selectedPages []string, old, new string) (int, []pdfcpu.UnreplacedText, error) {
+	pages, err := PagesForPageSelection(ctx.PageCount, selectedPages, true, true)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return pdfcpu.ReplaceText(ctx, pages, old, new)
+}
@@ -0,0 +1,106 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package api
+
+import (
+	"io"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/log"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pkg/errors"
+)
+
+// Sanitize removes active and hidden content of given categories from rs and writes the result to w.
+// All categories are removed if cats is 0.
+// The returned report lists all removed structures.
+func Sanitize(rs io.ReadSeeker, w io.Writer, cats pdfcpu.SanitizeCategory, conf *model.Configuration) ([]pdfcpu.SanitizeItem, error) {
+	if rs == nil {
+		return nil, errors.New("pdfcpu: Sanitize: missing rs")
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.SANITIZE
+
+	// An increment would keep the removed content in the original revision.
+	conf.Incremental = false
+
+	if cats == 0 {
+		cats = pdfcpu.SanitizeAll
+	}
+
+	ctx, err := ReadValidateAndOptimize(rs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := pdfcpu.Sanitize(ctx, cats)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, Write(ctx, w, conf)
+}
+
+// SanitizeFile removes active and hidden content of given categories from inFile and writes the result to outFile.
+func SanitizeFile(inFile, outFile string, cats pdfcpu.SanitizeCategory, conf *model.Configuration) (items []pdfcpu.SanitizeItem, err error) {
+	var f1, f2 *os.File
+
+	if f1, err = os.Open(inFile); err != nil {
+		return nil, err
+	}
+
+	tmpFile := inFile + ".tmp"
+	if outFile != "" && inFile != outFile {
+		tmpFile = outFile
+		logWritingTo(outFile)
+	} else {
+		logWritingTo(inFile)
+	}
+
+	if f2, err = os.Create(tmpFile); err != nil {
+		f1.Close()
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			f2.Close()
+			f1.Close()
+			os.Remove(tmpFile)
+			return
+		}
+		if err = f2.Close(); err != nil {
+			return
+		}
+		if err = f1.Close(); err != nil {
+			return
+		}
+		if outFile == "" || inFile == outFile {
+			err = os.Rename(tmpFile, inFile)
+		}
+	}()
+
+	if log.CLIEnabled() {
+		log.CLI.Printf("sanitizing %s ...\n", inFile)
+	}
+
+	return Sanitize(f1, f2, cats, conf)
+}
@@ -0,0 +1,202 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package api
+
+import (
+	"io"
+	"math"
+	"os"
+	"regexp"
+
+	"github.com/pdfcpu/pdfcpu/pkg/log"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/color"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/text"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+func searchText(ctx *model.Context, selectedPages []string, pattern string) ([]text.Match, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "pdfcpu: invalid search pattern")
+	}
+
+	pages, err := PagesForPageSelection(ctx.PageCount, selectedPages, true, true)
+	if err != nil {
+		return nil, err
+	}
+
+	mm := []text.Match{}
+	for i := 1; i <= ctx.PageCount; i++ {
+		if !pages[i] {
+			continue
+		}
+		p, err := text.ExtractPage(ctx.XRefTable, i)
+		if err != nil {
+			return nil, err
+		}
+		mm = append(mm, p.Search(re)...)
+	}
+
+	return mm, nil
+}
+
+// SearchText returns all matches of the regular expression pattern within the text of selected pages of rs.
+func SearchText(rs io.ReadSeeker, selectedPages []string, pattern string, conf *model.Configuration) ([]text.Match, error) {
+	if rs == nil {
+		return nil, errors.New("pdfcpu: SearchText: missing rs")
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.SEARCHTEXT
+
+	ctx, err := ReadValidateAndOptimize(rs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return searchText(ctx, selectedPages, pattern)
+}
+
+// SearchTextFile returns all matches of the regular expression pattern within the text of selected pages of inFile.
+func SearchTextFile(inFile string, selectedPages []string, pattern string, conf *model.Configuration) ([]text.Match, error) {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return SearchText(f, selectedPages, pattern, conf)
+}
+
+// matchRect returns the rectangle enclosing all lines covered by m.
+func matchRect(m text.Match) types.Rectangle {
+	r := m.Rects[0]
+	for _, r1 := range m.Rects[1:] {
+		r = *types.NewRectangle(
+			math.Min(r.LL.X, r1.LL.X), math.Min(r.LL.Y, r1.LL.Y),
+			math.Max(r.UR.X, r1.UR.X), math.Max(r.UR.Y, r1.UR.Y))
+	}
+	return r
+}
+
+func highlightAnnotation(m text.Match, col *color.SimpleColor) model.HighlightAnnotation {
+	return model.NewHighlightAnnotation(
+		matchRect(m),   // rect
+		m.Text,         // contents
+		"",             // id
+		"",             // modDate
+		model.AnnPrint, // f
+		col,            // col
+		"",             // title
+		nil,            // popupIndRef
+		nil,            // ca
+		"",             // rc
+		"",             // subject
+		m.Quads,        // quad points
+	)
+}
+
+// HighlightText adds a highlight annotation for each match of the regular expression pattern
+// within the text of selected pages of rs and writes the result to w.
+// The default highlight color is yellow.
+func HighlightText(rs io.ReadSeeker, w io.Writer, selectedPages []string, pattern string, col *color.SimpleColor, conf *model.Configuration) ([]text.Match, error) {
+	if rs == nil {
+		return nil, errors.New("pdfcpu: HighlightText: missing rs")
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.HIGHLIGHTTEXT
+
+	if col == nil {
+		col = &color.Yellow
+	}
+
+	ctx, err := ReadValidateAndOptimize(rs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	mm, err := searchText(ctx, selectedPages, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[int][]model.AnnotationRenderer{}
+	for _, match := range mm {
+		m[match.Page] = append(m[match.Page], highlightAnnotation(match, col))
+	}
+
+	if _, err := pdfcpu.AddAnnotationsMap(ctx, m, false); err != nil {
+		return nil, err
+	}
+
+	return mm, Write(ctx, w, conf)
+}
+
+// HighlightTextFile adds a highlight annotation for each match of the regular expression pattern
+// within the text of selected pages of inFile and writes the result to outFile.
+func HighlightTextFile(inFile, outFile string, selectedPages []string, pattern string, col *color.SimpleColor, conf *model.Configuration) (mm []text.Match, err error) {
+	var f1, f2 *os.File
+
+	if f1, err = os.Open(inFile); err != nil {
+		return nil, err
+	}
+
+	tmpFile := inFile + ".tmp"
+	if outFile != "" && inFile != outFile {
+		tmpFile = outFile
+		logWritingTo(outFile)
+	} else {
+		logWritingTo(inFile)
+	}
+
+	if f2, err = os.Create(tmpFile); err != nil {
+		f1.Close()
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			f2.Close()
+			f1.Close()
+			os.Remove(tmpFile)
+			return
+		}
+		if err = f2.Close(); err != nil {
+			return
+		}
+		if err = f1.Close(); err != nil {
+			return
+		}
+		if outFile == "" || inFile == outFile {
+			err = os.Rename(tmpFile, inFile)
+		}
+	}()
+
+	if log.CLIEnabled() {
+		log.CLI.Printf("highlighting %q in %s ...\n", pattern, inFile)
+	}
+
+	return HighlightText(f1, f2, selectedPages, pattern, col, conf)
+}
@@ -0,0 +1,368 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/log"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/sign"
+	"github.com/pkg/errors"
+)
+
+// Sign adds a digital signature to rs and writes the result to w.
+// signer creates the signature using the private key of the signing certificate chain[0]
+// and may be backed by some external signing device like a HSM.
+// chain should contain all intermediate certificates up to the root certificate.
+// If sig.TSA is set the signature gets timestamped (PAdES B-T).
+// Existing signatures remain valid since the signature gets written as incremental update.
+func Sign(rs io.ReadSeeker, w io.Writer, signer crypto.Signer, chain []*x509.Certificate, sig *model.Signature, conf *model.Configuration) error {
+	if rs == nil {
+		return errors.New("pdfcpu: Sign: missing rs")
+	}
+
+	if signer == nil || len(chain) == 0 {
+		return errors.New("pdfcpu: Sign: missing signer or certificate")
+	}
+
+	if sig == nil {
+		sig = model.DefaultSignature()
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.SIGN
+
+	return signIncrementally(rs, w, signer, chain, sig, conf)
+}
+
+func signIncrementally(rs io.ReadSeeker, w io.Writer, signer crypto.Signer, chain []*x509.Certificate, sig *model.Signature, conf *model.Configuration) error {
+	conf.Incremental = true
+
+	ctx, err := ReadValidateAndOptimize(rs, conf)
+	if err != nil {
+		return err
+	}
+
+	t := time.Now()
+
+	if err := sign.PrepareSignature(ctx, sig, chain, t); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := WriteContextIncrementally(ctx, &buf); err != nil {
+		return err
+	}
+
+	bb := buf.Bytes()
+
+	if err := sign.Finalize(bb, signer, chain, sig, t); err != nil {
+		return err
+	}
+
+	_, err = w.Write(bb)
+	return err
+}
+
+// SignFile adds a digital signature to inFile and writes the result to outFile.
+// If outFile is not provided then inFile gets overwritten.
+func SignFile(inFile, outFile string, signer crypto.Signer, chain []*x509.Certificate, sig *model.Signature, conf *model.Configuration) (err error) {
+	var f1, f2 *os.File
+
+	if f1, err = os.Open(inFile); err != nil {
+		return err
+	}
+
+	tmpFile := inFile + ".tmp"
+	if outFile != "" && inFile != outFile {
+		tmpFile = outFile
+		logWritingTo(outFile)
+	} else {
+		logWritingTo(inFile)
+	}
+
+	if f2, err = os.Create(tmpFile); err != nil {
+		f1.Close()
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			f2.Close()
+			f1.Close()
+			os.Remove(tmpFile)
+			return
+		}
+		if err = f2.Close(); err != nil {
+			return
+		}
+		if err = f1.Close(); err != nil {
+			return
+		}
+		if outFile == "" || inFile == outFile {
+			err = os.Rename(tmpFile, inFile)
+		}
+	}()
+
+	if log.CLIEnabled() {
+		log.CLI.Printf("signing %s\n", inFile)
+	}
+
+	return Sign(f1, f2, signer, chain, sig, conf)
+}
+
+// Timestamp adds a document timestamp (PAdES B-LTA) created by sig.TSA to rs and writes the result to w.
+// Existing signatures remain valid since the timestamp gets written as incremental update.
+func Timestamp(rs io.ReadSeeker, w io.Writer, sig *model.Signature, conf *model.Configuration) error {
+	if rs == nil {
+		return errors.New("pdfcpu: Timestamp: missing rs")
+	}
+
+	if sig == nil || sig.TSA == nil {
+		return errors.New("pdfcpu: Timestamp: missing time stamping authority")
+	}
+	sig.SubFilter = model.SubFilterRFC3161
+	if sig.PageNr == 0 {
+		// The invisible widget of the timestamp field.
+		sig.PageNr = 1
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.TIMESTAMP
+
+	return signIncrementally(rs, w, nil, nil, sig, conf)
+}
+
+// TimestampFile adds a document timestamp created by sig.TSA to inFile and writes the result to outFile.
+// If outFile is not provided then inFile gets overwritten.
+func TimestampFile(inFile, outFile string, sig *model.Signature, conf *model.Configuration) (err error) {
+	var f1, f2 *os.File
+
+	if f1, err = os.Open(inFile); err != nil {
+		return err
+	}
+
+	tmpFile := inFile + ".tmp"
+	if outFile != "" && inFile != outFile {
+		tmpFile = outFile
+		logWritingTo(outFile)
+	} else {
+		logWritingTo(inFile)
+	}
+
+	if f2, err = os.Create(tmpFile); err != nil {
+		f1.Close()
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			f2.Close()
+			f1.Close()
+			os.Remove(tmpFile)
+			return
+		}
+		if err = f2.Close(); err != nil {
+			return
+		}
+		if err = f1.Close(); err != nil {
+			return
+		}
+		if outFile == "" || inFile == outFile {
+			err = os.Rename(tmpFile, inFile)
+		}
+	}()
+
+	if log.CLIEnabled() {
+		log.CLI.Printf("timestamping %s\n", inFile)
+	}
+
+	return Timestamp(f1, f2, sig, conf)
+}
+
+// ReadPKCS12File returns the private key and certificate chain of the PKCS#12 key store certFile.
+func ReadPKCS12File(certFile, password string) (crypto.Signer, []*x509.Certificate, error) {
+	f, err := os.Open(certFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	return sign.ReadPKCS12(f, password)
+}
+
+// AddDSS adds the validation data dss to the Document Security Store of rs and writes the result to w.
+// All validation data gets registered for every signature of rs.
+// Existing signatures remain valid since the DSS gets written as incremental update.
+func AddDSS(rs io.ReadSeeker, w io.Writer, dss *sign.DSS, conf *model.Configuration) error {
+	if rs == nil {
+		return errors.New("pdfcpu: AddDSS: missing rs")
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.ADDDSS
+	conf.Incremental = true
+
+	ctx, err := ReadValidateAndOptimize(rs, conf)
+	if err != nil {
+		return err
+	}
+
+	if err := sign.AddDSS(ctx, dss, time.Now()); err != nil {
+		return err
+	}
+
+	return WriteContextIncrementally(ctx, w)
+}
+
+// AddDSSFile adds the certificates, CRLs and OCSP responses contained in files
+// to the Document Security Store of inFile and writes the result to outFile.
+// If outFile is not provided then inFile gets overwritten.
+func AddDSSFile(inFile, outFile string, files []string, conf *model.Configuration) (err error) {
+	dss := &sign.DSS{}
+	for _, fn := range files {
+		bb, err := os.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+		if err := dss.Add(bb); err != nil {
+			return errors.Wrapf(err, "%s", fn)
+		}
+	}
+
+	var f1, f2 *os.File
+
+	if f1, err = os.Open(inFile); err != nil {
+		return err
+	}
+
+	tmpFile := inFile + ".tmp"
+	if outFile != "" && inFile != outFile {
+		tmpFile = outFile
+		logWritingTo(outFile)
+	} else {
+		logWritingTo(inFile)
+	}
+
+	if f2, err = os.Create(tmpFile); err != nil {
+		f1.Close()
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			f2.Close()
+			f1.Close()
+			os.Remove(tmpFile)
+			return
+		}
+		if err = f2.Close(); err != nil {
+			return
+		}
+		if err = f1.Close(); err != nil {
+			return
+		}
+		if outFile == "" || inFile == outFile {
+			err = os.Rename(tmpFile, inFile)
+		}
+	}()
+
+	return AddDSS(f1, f2, dss, conf)
+}
+
+// Signatures returns all signature fields of rs including the signatures referenced by the document permissions.
+func Signatures(rs io.ReadSeeker, conf *model.Configuration) ([]sign.SignatureDetails, error) {
+	if rs == nil {
+		return nil, errors.New("pdfcpu: Signatures: missing rs")
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.LISTSIGNATURES
+
+	ctx, err := ReadAndValidate(rs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return sign.Signatures(ctx)
+}
+
+// SignaturesFile returns all signature fields of inFile including the signatures referenced by the document permissions.
+func SignaturesFile(inFile string, conf *model.Configuration) ([]sign.SignatureDetails, error) {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Signatures(f, conf)
+}
+
+// VerifySignatures returns all signatures of rs including their verification results.
+// Signer certificates are trusted if they chain up to one of the certificates found in trustDir.
+// If trustDir is not provided the system certificate pool is used.
+func VerifySignatures(rs io.ReadSeeker, trustDir string, conf *model.Configuration) ([]sign.SignatureDetails, error) {
+	if rs == nil {
+		return nil, errors.New("pdfcpu: VerifySignatures: missing rs")
+	}
+
+	roots, err := sign.LoadTrustStore(trustDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.VERIFYSIGNATURES
+
+	ctx, err := ReadAndValidate(rs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return sign.Verify(ctx, roots)
+}
+
+// VerifySignaturesFile returns all signatures of inFile including their verification results.
+func VerifySignaturesFile(inFile, trustDir string, conf *model.Configuration) ([]sign.SignatureDetails, error) {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if log.CLIEnabled() {
+		log.CLI.Printf("verifying signatures of %s\n", inFile)
+	}
+
+	return VerifySignatures(f, trustDir, conf)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package test
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -192,6 +193,64 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidateReport(t *testing.T) {
+	msg := "TestValidateReport"
+
+	// Valid file.
+	f, err := os.Open(filepath.Join(inDir, "go.pdf"))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	defer f.Close()
+
+	diags, err := api.ValidateReport(f, nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if len(diags) > 0 {
+		t.Fatalf("%s: go.pdf: unexpected violations: %v\n", msg, diags)
+	}
+
+	// Violations tolerated in relaxed mode.
+	f1, err := os.Open(filepath.Join(inDir, "5116.DCT_Filter.pdf"))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	defer f1.Close()
+
+	if diags, err = api.ValidateReport(f1, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if len(diags) == 0 {
+		t.Fatalf("%s: 5116.DCT_Filter.pdf: missing violations\n", msg)
+	}
+	for _, d := range diags {
+		if d.Severity != model.SeverityStrict {
+			t.Fatalf("%s: 5116.DCT_Filter.pdf: want strict violation, got: %v\n", msg, d)
+		}
+	}
+
+	// Remove the required MediaBox from all 64 pages.
+	bb, err := os.ReadFile(filepath.Join(inDir, "bookletTest.pdf"))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	bb = bytes.ReplaceAll(bb, []byte("/MediaBox"), []byte("/MediaBux"))
+
+	if diags, err = api.ValidateReport(bytes.NewReader(bb), nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if len(diags) != 64 {
+		t.Fatalf("%s: want 64 violations, got %d\n", msg, len(diags))
+	}
+	for _, d := range diags {
+		if d.Severity != model.SeverityRelaxed || d.ObjNr == 0 ||
+			!strings.HasPrefix(d.Path, "Root/Pages/Kids[") || !strings.HasSuffix(d.Path, "/MediaBox") {
+			t.Fatalf("%s: unexpected violation: %v\n", msg, d)
+		}
+	}
+}
+
 func TestManipulateContext(t *testing.T) {
 	msg := "TestManipulateContext"
 	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
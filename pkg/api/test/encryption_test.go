@@ -17,13 +17,22 @@ limitations under the License.
 package test
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 )
 
 func listPermissions(t *testing.T, fileName string) ([]string, error) {
@@ -230,3 +239,319 @@ func TestSetPermissions(t *testing.T) {
 		t.Fatalf("%s: got: %d want: %d", msg, uint16(*p), uint16(permNew))
 	}
 }
+
+func createRecipient(t *testing.T, cn string) (crypto.Decrypter, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment,
+	}
+
+	bb, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(bb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, cert
+}
+
+func TestPubSecEncryption(t *testing.T) {
+	msg := "TestPubSecEncryption"
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+	outFile := filepath.Join(outDir, "pubsec.pdf")
+
+	aliceKey, alice := createRecipient(t, "Alice")
+	bobKey, bob := createRecipient(t, "Bob")
+	eveKey, _ := createRecipient(t, "Eve")
+
+	for _, keyLength := range []int{128, 256} {
+
+		// Encrypt file for Alice and Bob.
+		conf := model.NewAESConfiguration("", "", keyLength)
+		conf.EncryptRecipients = []*x509.Certificate{alice, bob}
+		conf.Permissions = model.PermissionsNone | model.PermissionPrintRev2
+		if err := api.EncryptFile(inFile, outFile, conf); err != nil {
+			t.Fatalf("%s: encrypt %s: %v\n", msg, outFile, err)
+		}
+
+		// Reading the encrypted file w/o private key should fail.
+		if _, err := api.GetPermissionsFile(outFile, nil); err == nil {
+			t.Fatalf("%s: get permissions w/o key %s succeeded\n", msg, outFile)
+		}
+
+		// Reading the encrypted file using a foreign private key should fail.
+		conf = model.NewAESConfiguration("", "", keyLength)
+		conf.DecryptKey = eveKey
+		if _, err := api.GetPermissionsFile(outFile, conf); err == nil {
+			t.Fatalf("%s: get permissions using foreign key %s succeeded\n", msg, outFile)
+		}
+
+		// Each recipient gets the permissions granted.
+		for _, key := range []crypto.Decrypter{aliceKey, bobKey} {
+			conf = model.NewAESConfiguration("", "", keyLength)
+			conf.DecryptKey = key
+			p, err := api.GetPermissionsFile(outFile, conf)
+			if err != nil {
+				t.Fatalf("%s: get permissions %s: %v\n", msg, outFile, err)
+			}
+			if p == nil || uint16(*p) != uint16(model.PermissionsNone|model.PermissionPrintRev2) {
+				t.Fatalf("%s: unexpected permissions: %v\n", msg, p)
+			}
+		}
+
+		// Decrypt file using Bob's private key.
+		conf = model.NewAESConfiguration("", "", keyLength)
+		conf.DecryptKey = bobKey
+		if err := api.DecryptFile(outFile, "", conf); err != nil {
+			t.Fatalf("%s: decrypt %s: %v\n", msg, outFile, err)
+		}
+
+		if err := api.ValidateFile(outFile, nil); err != nil {
+			t.Fatalf("%s: validate %s: %v\n", msg, outFile, err)
+		}
+	}
+}
+
+func TestAttachmentsOnlyEncryption(t *testing.T) {
+	msg := "TestAttachmentsOnlyEncryption"
+	inFile := filepath.Join(inDir, "go.pdf")
+	outFile := filepath.Join(outDir, "invoice.pdf")
+	attFile := filepath.Join(outDir, "invoice.csv")
+	extractDir := filepath.Join(outDir, "invoice")
+
+	want := []byte("item;qty;price\ngopher;1;42.00\n")
+	if err := os.WriteFile(attFile, want, os.ModePerm); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if err := os.MkdirAll(extractDir, os.ModePerm); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// 40 bit keys do not support crypt filters.
+	conf := model.NewAESConfiguration("upw", "opw", 40)
+	conf.EncryptAttachmentsOnly = true
+	if err := api.EncryptFile(inFile, outFile, conf); err == nil {
+		t.Fatalf("%s: encrypt attachments using 40 bit key succeeded\n", msg)
+	}
+
+	for _, keyLength := range []int{128, 256} {
+
+		if err := api.AddAttachmentsFile(inFile, outFile, []string{attFile}, false, nil); err != nil {
+			t.Fatalf("%s: add attachment: %v\n", msg, err)
+		}
+
+		conf := model.NewAESConfiguration("upw", "opw", keyLength)
+		conf.EncryptAttachmentsOnly = true
+		if err := api.EncryptFile(outFile, "", conf); err != nil {
+			t.Fatalf("%s: encrypt %s: %v\n", msg, outFile, err)
+		}
+
+		bb, err := os.ReadFile(outFile)
+		if err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+		if !bytes.Contains(bb, []byte("/EFF/StdCF")) || !bytes.Contains(bb, []byte("/StmF/Identity")) {
+			t.Fatalf("%s: missing crypt filters\n", msg)
+		}
+		if bytes.Contains(bb, []byte("gopher;1;42.00")) {
+			t.Fatalf("%s: attachment not encrypted\n", msg)
+		}
+
+		// Anything but the attachment is accessible without a password.
+		if err := api.ValidateFile(outFile, nil); err != nil {
+			t.Fatalf("%s: validate %s without password: %v\n", msg, outFile, err)
+		}
+		if _, err := api.PageCountFile(outFile); err != nil {
+			t.Fatalf("%s: page count without password: %v\n", msg, err)
+		}
+		if err := api.ExtractAttachmentsFile(outFile, extractDir, nil, nil); err == nil {
+			t.Fatalf("%s: extracted attachment without password\n", msg)
+		}
+
+		// Modifying the file keeps the attachment encrypted.
+		if err := api.RotateFile(outFile, "", 90, nil, nil); err != nil {
+			t.Fatalf("%s: rotate %s without password: %v\n", msg, outFile, err)
+		}
+
+		// Extract the attachment using the owner password.
+		conf = model.NewAESConfiguration("", "opw", keyLength)
+		if err := api.ExtractAttachmentsFile(outFile, extractDir, nil, conf); err != nil {
+			t.Fatalf("%s: extract attachments: %v\n", msg, err)
+		}
+		got, err := os.ReadFile(filepath.Join(extractDir, "invoice.csv"))
+		if err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: got: %q want: %q\n", msg, got, want)
+		}
+
+		// Decrypt file using the user password.
+		conf = model.NewAESConfiguration("upw", "", keyLength)
+		if err := api.DecryptFile(outFile, "", conf); err != nil {
+			t.Fatalf("%s: decrypt %s: %v\n", msg, outFile, err)
+		}
+
+		if err := api.ValidateFile(outFile, nil); err != nil {
+			t.Fatalf("%s: validate %s: %v\n", msg, outFile, err)
+		}
+	}
+}
+
+// writeUntypedEmbeddedFile stores the embedded file streams of fileName uncompressed and without the optional Type entry.
+func writeUntypedEmbeddedFile(t *testing.T, fileName string) {
+	t.Helper()
+
+	ctx, err := api.ReadContextFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range ctx.Table {
+		sd, ok := entry.Object.(types.StreamDict)
+		if !ok || sd.Type() == nil || *sd.Type() != "EmbeddedFile" {
+			continue
+		}
+		if err := sd.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		sd.Delete("Type")
+		sd.Delete("Filter")
+		sd.Delete("DecodeParms")
+		sd.FilterPipeline = nil
+		if err := sd.Encode(); err != nil {
+			t.Fatal(err)
+		}
+		entry.Object = sd
+	}
+
+	if err := api.WriteContextFile(ctx, fileName); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAttachmentsOnlyEncryptionUntypedEmbeddedFile(t *testing.T) {
+	msg := "TestAttachmentsOnlyEncryptionUntypedEmbeddedFile"
+	inFile := filepath.Join(inDir, "go.pdf")
+	outFile := filepath.Join(outDir, "untypedEmbeddedFile.pdf")
+	attFile := filepath.Join(outDir, "secret.txt")
+	extractDir := filepath.Join(outDir, "untypedEmbeddedFile")
+
+	want := []byte("TOPSECRET")
+	if err := os.WriteFile(attFile, want, os.ModePerm); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if err := os.MkdirAll(extractDir, os.ModePerm); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if err := api.AddAttachmentsFile(inFile, outFile, []string{attFile}, false, nil); err != nil {
+		t.Fatalf("%s: add attachment: %v\n", msg, err)
+	}
+	writeUntypedEmbeddedFile(t, outFile)
+
+	bb, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if !bytes.Contains(bb, want) {
+		t.Fatalf("%s: missing plain attachment\n", msg)
+	}
+
+	conf := model.NewAESConfiguration("upw", "opw", 256)
+	conf.EncryptAttachmentsOnly = true
+	if err := api.EncryptFile(outFile, "", conf); err != nil {
+		t.Fatalf("%s: encrypt %s: %v\n", msg, outFile, err)
+	}
+
+	if bb, err = os.ReadFile(outFile); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if bytes.Contains(bb, want) {
+		t.Fatalf("%s: attachment not encrypted\n", msg)
+	}
+
+	conf = model.NewAESConfiguration("", "opw", 256)
+	if err := api.ExtractAttachmentsFile(outFile, extractDir, nil, conf); err != nil {
+		t.Fatalf("%s: extract attachments: %v\n", msg, err)
+	}
+	got, err := os.ReadFile(filepath.Join(extractDir, "secret.txt"))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s: got: %q want: %q\n", msg, got, want)
+	}
+}
+
+func TestEncryptMetadata(t *testing.T) {
+	msg := "TestEncryptMetadata"
+	inFile := filepath.Join(inDir, "CenterOfWhy.pdf")
+	outFile := filepath.Join(outDir, "plainMetadata.pdf")
+
+	// 40 bit keys do not support unencrypted metadata.
+	conf := confForAlgorithm(false, 40, "upw", "opw")
+	conf.EncryptMetadata = false
+	if err := api.EncryptFile(inFile, outFile, conf); err == nil {
+		t.Fatalf("%s: encrypt using 40 bit key succeeded\n", msg)
+	}
+
+	for _, tc := range []struct {
+		aes       bool
+		keyLength int
+	}{
+		{false, 128},
+		{true, 128},
+		{true, 256},
+	} {
+		conf := confForAlgorithm(tc.aes, tc.keyLength, "upw", "opw")
+		conf.EncryptMetadata = false
+		if err := api.EncryptFile(inFile, outFile, conf); err != nil {
+			t.Fatalf("%s: encrypt %s: %v\n", msg, outFile, err)
+		}
+
+		bb, err := os.ReadFile(outFile)
+		if err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+		if !bytes.Contains(bb, []byte("/EncryptMetadata false")) || !bytes.Contains(bb, []byte("<x:xmpmeta")) {
+			t.Fatalf("%s: metadata encrypted (aes:%t %d)\n", msg, tc.aes, tc.keyLength)
+		}
+
+		// Changing the user password retains plain metadata.
+		conf = confForAlgorithm(tc.aes, tc.keyLength, "upw", "opw")
+		if err = api.ChangeUserPasswordFile(outFile, "", "upw", "upwNew", conf); err != nil {
+			t.Fatalf("%s: change upw %s: %v\n", msg, outFile, err)
+		}
+
+		if bb, err = os.ReadFile(outFile); err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+		if !bytes.Contains(bb, []byte("<x:xmpmeta")) {
+			t.Fatalf("%s: metadata encrypted after changing upw (aes:%t %d)\n", msg, tc.aes, tc.keyLength)
+		}
+
+		conf = confForAlgorithm(tc.aes, tc.keyLength, "upwNew", "")
+		if err = api.DecryptFile(outFile, "", conf); err != nil {
+			t.Fatalf("%s: decrypt %s: %v\n", msg, outFile, err)
+		}
+
+		if err = api.ValidateFile(outFile, nil); err != nil {
+			t.Fatalf("%s: validate %s: %v\n", msg, outFile, err)
+		}
+	}
+}
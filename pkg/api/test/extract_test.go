@@ -318,3 +318,53 @@ func TestExtractMetadataLowLevel(t *testing.T) {
 			md.ObjNr, md.ParentObjNr, md.ParentType, string(bb))
 	}
 }
+
+func TestExtractText(t *testing.T) {
+	msg := "TestExtractText"
+	// Extract the text of pages 1-2 into outDir, once as plain text and once as JSON.
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+	for _, jsonOut := range []bool{false, true} {
+		if err := api.ExtractTextFile(inFile, outDir, []string{"1-2"}, jsonOut, nil); err != nil {
+			t.Fatalf("%s %s: %v\n", msg, inFile, err)
+		}
+	}
+}
+
+func TestExtractTextRaw(t *testing.T) {
+	msg := "TestExtractTextRaw"
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+
+	f, err := os.Open(inFile)
+	if err != nil {
+		t.Fatalf("%s open: %v\n", msg, err)
+	}
+	defer f.Close()
+
+	pp, err := api.ExtractTextRaw(f, []string{"21"}, nil)
+	if err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+	if len(pp) != 1 || pp[0].Number != 21 {
+		t.Fatalf("%s: want page 21, got %d pages\n", msg, len(pp))
+	}
+
+	s := pp[0].Text()
+	for _, want := range []string{"CHAPTER 1. TUTORIAL", "Go is a compiled language."} {
+		if !strings.Contains(s, want) {
+			t.Errorf("%s: missing %q in:\n%s\n", msg, want, s)
+		}
+	}
+
+	// Each run carries font, size and a bounding box within the media box.
+	mb := pp[0].MediaBox
+	for _, l := range pp[0].Lines {
+		for _, r := range l.Runs {
+			if r.Font == "" || r.FontSize <= 0 {
+				t.Errorf("%s: missing font info for %q\n", msg, r.Text)
+			}
+			if r.BBox.LL.X < mb.LL.X || r.BBox.UR.X > mb.UR.X || r.BBox.LL.Y < mb.LL.Y || r.BBox.UR.Y > mb.UR.Y {
+				t.Errorf("%s: bbox %v of %q outside media box\n", msg, r.BBox, r.Text)
+			}
+		}
+	}
+}
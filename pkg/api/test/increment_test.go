@@ -0,0 +1,212 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func checkIncrement(t *testing.T, msg, inFile, outFile string) {
+	t.Helper()
+
+	bb0, err := os.ReadFile(inFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	bb, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if len(bb) <= len(bb0) || !bytes.Equal(bb[:len(bb0)], bb0) {
+		t.Fatalf("%s: %s is no increment of %s\n", msg, outFile, inFile)
+	}
+
+	if err := api.ValidateFile(outFile, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+}
+
+func TestWatermarkIncremental(t *testing.T) {
+	msg := "TestWatermarkIncremental"
+
+	// Cover files using xref streams and xref tables.
+	for _, fn := range []string{"go.pdf", "read.go.pdf"} {
+		inFile := filepath.Join(inDir, fn)
+		outFile := filepath.Join(outDir, "incr_"+fn)
+
+		conf := model.NewDefaultConfiguration()
+		conf.Incremental = true
+
+		wm, err := api.TextWatermark("Demo", "", true, false, types.POINTS)
+		if err != nil {
+			t.Fatalf("%s %s: %v\n", msg, fn, err)
+		}
+
+		if err := api.AddWatermarksFile(inFile, outFile, nil, wm, conf); err != nil {
+			t.Fatalf("%s %s: %v\n", msg, fn, err)
+		}
+
+		checkIncrement(t, msg, inFile, outFile)
+	}
+}
+
+func TestFillFormIncremental(t *testing.T) {
+	msg := "TestFillFormIncremental"
+
+	// Mark the form as signed.
+	ctx, err := api.ReadContextFile(filepath.Join(samplesDir, "form", "lock", "person-unlocked.pdf"))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if err := api.ValidateContext(ctx); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	ctx.Form["SigFlags"] = types.Integer(3)
+
+	inFile := filepath.Join(outDir, "personSigned.pdf")
+	if err := api.WriteContextFile(ctx, inFile); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	f, err := os.Open(inFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	fg, err := api.ExportForm(f, inFile, nil)
+	f.Close()
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if len(fg.Forms) == 0 || len(fg.Forms[0].TextFields) == 0 {
+		t.Fatalf("%s: missing text fields\n", msg)
+	}
+	fg.Forms[0].TextFields[0].Value = "Received"
+
+	inFileJSON := filepath.Join(outDir, "personSigned.json")
+	bb, err := json.Marshal(fg)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if err := os.WriteFile(inFileJSON, bb, 0644); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.Incremental = true
+
+	outFile := filepath.Join(outDir, "personSignedFilled.pdf")
+	if err := api.FillFormFile(inFile, inFileJSON, outFile, conf); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	checkIncrement(t, msg, inFile, outFile)
+
+	// The signature flags survive an incremental update.
+	if ctx, err = api.ReadContextFile(outFile); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if err := api.ValidateContext(ctx); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if !ctx.SignatureExist || !ctx.AppendOnly {
+		t.Fatalf("%s: missing SigFlags\n", msg)
+	}
+}
+
+func TestExtractImagesIncremental(t *testing.T) {
+	msg := "TestExtractImagesIncremental"
+	inFile := filepath.Join(inDir, "testImage.pdf")
+
+	// Required optimization also takes place when writing incrementally.
+	var counts []int
+	for _, incr := range []bool{false, true} {
+		conf := model.NewDefaultConfiguration()
+		conf.Incremental = incr
+
+		f, err := os.Open(inFile)
+		if err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+		mm, err := api.ExtractImagesRaw(f, nil, conf)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+
+		n := 0
+		for _, m := range mm {
+			n += len(m)
+		}
+		counts = append(counts, n)
+	}
+
+	if counts[0] == 0 || counts[1] != counts[0] {
+		t.Fatalf("%s: want %d images, got %d\n", msg, counts[0], counts[1])
+	}
+}
+
+func TestWriteContextIncrementallyUnmodified(t *testing.T) {
+	msg := "TestWriteContextIncrementallyUnmodified"
+	inFile := filepath.Join(inDir, "go.pdf")
+
+	bb0, err := os.ReadFile(inFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.Incremental = true
+
+	ctx, err := api.ReadAndValidate(bytes.NewReader(bb0), conf)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContextIncrementally(ctx, &buf); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// Only the info dict carrying the modification date gets updated.
+	inc := buf.Bytes()[len(bb0):]
+	n := bytes.Count(inc, []byte(" obj"))
+	if bytes.Contains(inc, []byte("/Type/XRef")) {
+		n--
+	}
+	if n > 1 {
+		t.Fatalf("%s: want at most 1 object, got %d\n", msg, n)
+	}
+
+	// Changes are tracked only for contexts read for incremental writing.
+	if ctx, err = api.ReadAndValidate(bytes.NewReader(bb0), model.NewDefaultConfiguration()); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if err := api.WriteContextIncrementally(ctx, &buf); err == nil {
+		t.Fatalf("%s: missing error for untracked changes\n", msg)
+	}
+}
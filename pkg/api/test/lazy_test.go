@@ -0,0 +1,199 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func lazyConfiguration(cacheSize int) *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	conf.LazyRead = true
+	conf.ObjectCacheSize = cacheSize
+	return conf
+}
+
+func TestLazyPageCount(t *testing.T) {
+	msg := "TestLazyPageCount"
+
+	for _, fn := range []string{"5116.DCT_Filter.pdf", "Acroforms2.pdf", "go.pdf", "gobook.0.pdf"} {
+		inFile := filepath.Join(inDir, fn)
+
+		want, err := api.PageCountFile(inFile)
+		if err != nil {
+			t.Fatalf("%s %s: %v\n", msg, fn, err)
+		}
+
+		f, err := os.Open(inFile)
+		if err != nil {
+			t.Fatalf("%s %s: %v\n", msg, fn, err)
+		}
+
+		got, err := api.PageCount(f, lazyConfiguration(0))
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s %s: %v\n", msg, fn, err)
+		}
+
+		if got != want {
+			t.Fatalf("%s %s: pageCount want:%d got:%d\n", msg, fn, want, got)
+		}
+	}
+}
+
+func TestLazyExtractPage(t *testing.T) {
+	msg := "TestLazyExtractPage"
+	inFile := filepath.Join(inDir, "gobook.0.pdf")
+
+	f, err := os.Open(inFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	defer f.Close()
+
+	cacheSize := 50
+
+	ctx, err := api.ReadAndValidate(f, lazyConfiguration(cacheSize))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	r, err := api.ExtractPage(ctx, 3)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if n := ctx.ObjCache.Len(); n > cacheSize {
+		t.Fatalf("%s: object cache exceeded: want <= %d got %d\n", msg, cacheSize, n)
+	}
+
+	loaded := 0
+	for _, e := range ctx.Table {
+		if e.Object != nil {
+			loaded++
+		}
+	}
+	if loaded >= len(ctx.Table) {
+		t.Fatalf("%s: all %d objects loaded\n", msg, loaded)
+	}
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if err := api.Validate(bytes.NewReader(buf.Bytes()), nil); err != nil {
+		t.Fatalf("%s: validate extracted page: %v\n", msg, err)
+	}
+}
+
+func TestLazyReadFile(t *testing.T) {
+	msg := "TestLazyReadFile"
+	inFile := filepath.Join(inDir, "gobook.0.pdf")
+
+	ctx, err := pdfcpu.ReadFile(inFile, lazyConfiguration(20))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	defer ctx.Close()
+
+	if !ctx.IsLazy() {
+		t.Fatalf("%s: want lazy context\n", msg)
+	}
+
+	// Objects get loaded from the still open file.
+	if err := api.ValidateContext(ctx); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if _, err := api.ExtractPage(ctx, 3); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if err := ctx.Close(); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+}
+
+func TestLazyModifyAndWrite(t *testing.T) {
+	msg := "TestLazyModifyAndWrite"
+	inFile := filepath.Join(inDir, "gobook.0.pdf")
+
+	f, err := os.Open(inFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	defer f.Close()
+
+	ctx, err := api.ReadAndValidate(f, lazyConfiguration(5))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	d, _, _, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	d["Marker"] = types.Name("Modified")
+
+	// Load all other pages pushing page 1 out of the object cache.
+	for i := 2; i <= ctx.PageCount; i++ {
+		if _, _, _, err := ctx.PageDict(i, false); err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	ctx, err = api.ReadAndValidate(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if d, _, _, err = ctx.PageDict(1, false); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if got := d.NameEntry("Marker"); got == nil || *got != "Modified" {
+		t.Fatalf("%s: lost modification of page 1\n", msg)
+	}
+}
+
+func TestLazyProperties(t *testing.T) {
+	msg := "TestLazyProperties"
+	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
+
+	f, err := os.Open(inFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	defer f.Close()
+
+	if _, err := api.Properties(f, lazyConfiguration(10)); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+}
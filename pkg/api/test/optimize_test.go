@@ -17,10 +17,13 @@ limitations under the License.
 package test
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 )
 
 func TestOptimize(t *testing.T) {
@@ -41,3 +44,46 @@ func TestOptimize(t *testing.T) {
 		t.Fatalf("%s: %v\n", msg, err)
 	}
 }
+
+func TestOptimizeImages(t *testing.T) {
+	msg := "TestOptimizeImages"
+
+	// A photograph and a black and white scan.
+	for _, fileName := range []string{"mountain.pdf", "jphysiol01396-0132.pdf"} {
+		inFile := filepath.Join(inDir, fileName)
+		outFile := filepath.Join(outDir, "imagesOptimized_"+fileName)
+
+		conf := model.NewDefaultConfiguration()
+		opt, err := pdfcpu.ParseImageOptimization("dpi=150,jpeg=75")
+		if err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+		conf.ImageOptimization = opt
+
+		if err := api.OptimizeFile(inFile, outFile, conf); err != nil {
+			t.Fatalf("%s %s: %v\n", msg, inFile, err)
+		}
+
+		if err := api.ValidateFile(outFile, nil); err != nil {
+			t.Fatalf("%s %s: %v\n", msg, outFile, err)
+		}
+
+		fi1, err := os.Stat(inFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi2, err := os.Stat(outFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi2.Size()*2 > fi1.Size() {
+			t.Errorf("%s %s: size %d not reduced enough from %d\n", msg, fileName, fi2.Size(), fi1.Size())
+		}
+	}
+
+	for _, s := range []string{"dpi=0", "jpeg=101", "dpi=300,threshold=200", "quality=80", "dpi"} {
+		if _, err := pdfcpu.ParseImageOptimization(s); err == nil {
+			t.Errorf("%s: missing error for %q\n", msg, s)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package test
+
+import (
+	"bytes"
+	"image"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestRedactText(t *testing.T) {
+	msg := "TestRedactText"
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+	outFile := filepath.Join(outDir, "RedactText.pdf")
+
+	mm, err := api.RedactFile(inFile, outFile, []string{"21-22"}, nil, `(?i)unicode`, nil, nil)
+	if err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+	if len(mm) == 0 {
+		t.Fatalf("%s: no matches\n", msg)
+	}
+
+	if err := api.ValidateFile(outFile, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if mm, err = api.SearchTextFile(outFile, []string{"21-22"}, `(?i)unicode`, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if len(mm) != 0 {
+		t.Errorf("%s: %d matches left\n", msg, len(mm))
+	}
+
+	// Unrelated text stays in place.
+	mm1, err := api.SearchTextFile(inFile, []string{"21-22"}, `compiled\s+language`, nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	mm2, err := api.SearchTextFile(outFile, []string{"21-22"}, `compiled\s+language`, nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if len(mm1) != 1 || len(mm2) != 1 || mm1[0].Rects[0] != mm2[0].Rects[0] {
+		t.Errorf("%s: unexpected matches for unredacted text: %v %v\n", msg, mm1, mm2)
+	}
+}
+
+// pageContentRaw returns the raw content streams of page pageNr of fileName.
+func pageContentRaw(t *testing.T, fileName string, pageNr int) [][]byte {
+	t.Helper()
+
+	ctx, err := api.ReadContextFile(fileName)
+	if err != nil {
+		t.Fatalf("%s: %v\n", fileName, err)
+	}
+	d, _, _, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		t.Fatalf("%s: %v\n", fileName, err)
+	}
+
+	o, err := ctx.Dereference(d["Contents"])
+	if err != nil {
+		t.Fatalf("%s: %v\n", fileName, err)
+	}
+	a, ok := o.(types.Array)
+	if !ok {
+		a = types.Array{d["Contents"]}
+	}
+
+	var bbs [][]byte
+	for _, o := range a {
+		sd, _, err := ctx.DereferenceStreamDict(o)
+		if err != nil || sd == nil {
+			t.Fatalf("%s: corrupt page content: %v\n", fileName, err)
+		}
+		bbs = append(bbs, sd.Raw)
+	}
+	return bbs
+}
+
+func TestRedactIncremental(t *testing.T) {
+	msg := "TestRedactIncremental"
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+	outFile := filepath.Join(outDir, "RedactIncremental.pdf")
+
+	conf := model.NewDefaultConfiguration()
+	conf.Incremental = true
+
+	if _, err := api.RedactFile(inFile, outFile, []string{"21"}, nil, `(?i)unicode`, nil, conf); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	bb, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// The unredacted page content must not survive in some earlier revision.
+	for _, raw := range pageContentRaw(t, inFile, 21) {
+		if bytes.Contains(bb, raw) {
+			t.Fatalf("%s: original page content still present\n", msg)
+		}
+	}
+
+	if mm, err := api.SearchTextFile(outFile, []string{"21"}, `(?i)unicode`, nil); err != nil || len(mm) != 0 {
+		t.Errorf("%s: redaction failed: %v %v\n", msg, mm, err)
+	}
+}
+
+func TestMarkAndApplyRedactions(t *testing.T) {
+	msg := "TestMarkAndApplyRedactions"
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+	outFile := filepath.Join(outDir, "MarkRedactions.pdf")
+
+	mm, err := api.MarkRedactionsFile(inFile, outFile, []string{"21"}, nil, `(?i)go toolchain`, nil, nil)
+	if err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+	if len(mm) == 0 {
+		t.Fatalf("%s: no matches\n", msg)
+	}
+
+	countRedactAnnots := func() int {
+		ctx, err := api.ReadContextFile(outFile)
+		if err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+		n := 0
+		for _, pgAnnots := range ctx.PageAnnots {
+			n += len(pgAnnots[model.AnnRedact].Map)
+		}
+		return n
+	}
+
+	if n := countRedactAnnots(); n != len(mm) {
+		t.Fatalf("%s: want %d redact annotations, got %d\n", msg, len(mm), n)
+	}
+
+	// Marked content is still there.
+	if mm, err = api.SearchTextFile(outFile, []string{"21"}, `(?i)go toolchain`, nil); err != nil || len(mm) == 0 {
+		t.Fatalf("%s: marked content missing: %v\n", msg, err)
+	}
+
+	// Apply the redact annotations.
+	if _, err := api.RedactFile(outFile, "", nil, nil, "", nil, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if err := api.ValidateFile(outFile, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if n := countRedactAnnots(); n != 0 {
+		t.Errorf("%s: %d redact annotations left\n", msg, n)
+	}
+
+	if mm, err = api.SearchTextFile(outFile, []string{"21"}, `(?i)go toolchain`, nil); err != nil || len(mm) != 0 {
+		t.Errorf("%s: redaction failed: %v %v\n", msg, mm, err)
+	}
+}
+
+// pageImage returns the sole image of fileName.
+func pageImage(t *testing.T, fileName string) image.Image {
+	t.Helper()
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatalf("%s: %v\n", fileName, err)
+	}
+	defer f.Close()
+	mm, err := api.ExtractImagesRaw(f, nil, nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", fileName, err)
+	}
+	if len(mm) != 1 || len(mm[0]) != 1 {
+		t.Fatalf("%s: want 1 image\n", fileName)
+	}
+	for _, img := range mm[0] {
+		im, _, err := image.Decode(img)
+		if err != nil {
+			t.Fatalf("%s: %v\n", fileName, err)
+		}
+		return im
+	}
+	return nil
+}
+
+func TestRedactImage(t *testing.T) {
+	msg := "TestRedactImage"
+	inFile := filepath.Join(inDir, "mountain.pdf")
+	outFile := filepath.Join(outDir, "RedactImage.pdf")
+
+	// The image covers the page (1268 x 720), redact the left half.
+	rects := []types.Rectangle{*types.NewRectangle(0, 0, 634, 720)}
+	if _, err := api.RedactFile(inFile, outFile, nil, rects, "", nil, nil); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	if err := api.ValidateFile(outFile, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	img1, img2 := pageImage(t, inFile), pageImage(t, outFile)
+
+	if r, g, b, _ := img2.At(100, 360).RGBA(); r|g|b != 0 {
+		t.Errorf("%s: pixel within redaction area not cleared\n", msg)
+	}
+
+	if img1.At(1000, 360) != img2.At(1000, 360) {
+		t.Errorf("%s: pixel outside redaction area changed\n", msg)
+	}
+}
+
+func TestRedactDCTImage(t *testing.T) {
+	msg := "TestRedactDCTImage"
+	inFile := filepath.Join(outDir, "RedactDCTImageIn.pdf")
+	outFile := filepath.Join(outDir, "RedactDCTImage.pdf")
+
+	// The redacted samples of a DCT encoded image get Flate encoded.
+	if err := api.ImportImagesFile([]string{filepath.Join(resDir, "mountain.jpg")}, inFile, nil, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	rects := []types.Rectangle{*types.NewRectangle(0, 0, 100, 100)}
+	if _, err := api.RedactFile(inFile, outFile, nil, rects, "", nil, nil); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	img := pageImage(t, outFile)
+	b := img.Bounds()
+	if r, g, b, _ := img.At(b.Min.X, b.Max.Y-1).RGBA(); r|g|b != 0 {
+		t.Errorf("%s: pixel within redaction area not cleared\n", msg)
+	}
+}
+
+// pageAnnots returns the annotation dicts of page pageNr of fileName.
+func pageAnnots(t *testing.T, fileName string, pageNr int) []types.Dict {
+	t.Helper()
+
+	ctx, err := api.ReadContextFile(fileName)
+	if err != nil {
+		t.Fatalf("%s: %v\n", fileName, err)
+	}
+	d, _, _, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		t.Fatalf("%s: %v\n", fileName, err)
+	}
+	annots, err := ctx.DereferenceArray(d["Annots"])
+	if err != nil {
+		t.Fatalf("%s: %v\n", fileName, err)
+	}
+
+	var dd []types.Dict
+	for _, o := range annots {
+		ad, err := ctx.DereferenceDict(o)
+		if err != nil {
+			t.Fatalf("%s: %v\n", fileName, err)
+		}
+		dd = append(dd, ad)
+	}
+	return dd
+}
+
+// annotRect returns the rectangle of annotation dict ad.
+func annotRect(t *testing.T, ad types.Dict) types.Rectangle {
+	t.Helper()
+
+	a := ad.ArrayEntry("Rect")
+	if len(a) != 4 {
+		t.Fatalf("invalid annotation rect: %v\n", ad["Rect"])
+	}
+	ff := make([]float64, 4)
+	for i, o := range a {
+		switch v := o.(type) {
+		case types.Integer:
+			ff[i] = float64(v.Value())
+		case types.Float:
+			ff[i] = v.Value()
+		}
+	}
+	return *types.NewRectangle(ff[0], ff[1], ff[2], ff[3])
+}
+
+func annotIntersects(t *testing.T, ad types.Dict, r types.Rectangle) bool {
+	t.Helper()
+
+	ar := annotRect(t, ad)
+	return ar.LL.X < r.UR.X && r.LL.X < ar.UR.X && ar.LL.Y < r.UR.Y && r.LL.Y < ar.UR.Y
+}
+
+func countSubtype(dd []types.Dict, subtype string) int {
+	n := 0
+	for _, ad := range dd {
+		if st := ad.Subtype(); st != nil && *st == subtype {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRedactAnnotations(t *testing.T) {
+	msg := "TestRedactAnnotations"
+	inFile := filepath.Join(inDir, "annotTest.pdf")
+	outFile := filepath.Join(outDir, "RedactAnnotations.pdf")
+
+	// Covers a text annotation with popup and a FreeText annotation.
+	r := *types.NewRectangle(290, 412, 305, 430)
+	r1 := *types.NewRectangle(470, 435, 490, 450)
+
+	dd1 := pageAnnots(t, inFile, 1)
+	if countSubtype(dd1, "Popup") != 2 || countSubtype(dd1, "Text") != 2 {
+		t.Fatalf("%s: unexpected annotations in %s\n", msg, inFile)
+	}
+
+	if _, err := api.RedactFile(inFile, outFile, nil, []types.Rectangle{r, r1}, "", nil, nil); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	if err := api.ValidateFile(outFile, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	dd2 := pageAnnots(t, outFile, 1)
+	for _, ad := range dd2 {
+		if annotIntersects(t, ad, r) || annotIntersects(t, ad, r1) {
+			t.Errorf("%s: annotation intersecting redaction area left: %s\n", msg, *ad.Subtype())
+		}
+	}
+
+	// The popup of the removed text annotation is gone, the other text annotation and its popup remain.
+	if countSubtype(dd2, "Text") != 1 || countSubtype(dd2, "Popup") != 1 {
+		t.Errorf("%s: want 1 text annotation with popup\n", msg)
+	}
+
+	if len(dd2) == 0 || len(dd2) >= len(dd1) {
+		t.Errorf("%s: want fewer annotations, got %d of %d\n", msg, len(dd2), len(dd1))
+	}
+}
+
+func TestRedactFormFields(t *testing.T) {
+	msg := "TestRedactFormFields"
+	inFile := filepath.Join(outDir, "RedactFormFieldsIn.pdf")
+	outFile := filepath.Join(outDir, "RedactFormFields.pdf")
+
+	createPDF(t, msg, "", filepath.Join(inDir, "json", "form", "textfield.json"), inFile, nil)
+
+	f, err := os.Open(inFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	ff1, err := api.FormFields(f, nil)
+	f.Close()
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	var widget types.Dict
+	for _, ad := range pageAnnots(t, inFile, 1) {
+		if st := ad.Subtype(); st != nil && *st == "Widget" {
+			widget = ad
+			break
+		}
+	}
+	if widget == nil {
+		t.Fatalf("%s: missing widget\n", msg)
+	}
+
+	// Redact the center of the first widget.
+	wr := annotRect(t, widget)
+	x, y := (wr.LL.X+wr.UR.X)/2, (wr.LL.Y+wr.UR.Y)/2
+	r := *types.NewRectangle(x-1, y-1, x+1, y+1)
+
+	if _, err := api.RedactFile(inFile, outFile, nil, []types.Rectangle{r}, "", nil, nil); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	if err := api.ValidateFile(outFile, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	for _, ad := range pageAnnots(t, outFile, 1) {
+		if annotIntersects(t, ad, r) {
+			t.Errorf("%s: annotation intersecting redaction area left: %s\n", msg, *ad.Subtype())
+		}
+	}
+
+	f, err = os.Open(outFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	defer f.Close()
+	ff2, err := api.FormFields(f, nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if len(ff2) != len(ff1)-1 {
+		t.Errorf("%s: want %d form fields, got %d\n", msg, len(ff1)-1, len(ff2))
+	}
+}
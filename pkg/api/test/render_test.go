@@ -0,0 +1,208 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// inked returns the number of pixels of img that are not white.
+func inked(img image.Image) int {
+	n := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			if r < 0xF000 || g < 0xF000 || b < 0xF000 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestRenderPage(t *testing.T) {
+	msg := "TestRenderPage"
+
+	for _, fn := range []string{"testWithText.pdf", "mountain.pdf", "VectorApple.pdf", "testRot.pdf"} {
+		inFile := filepath.Join(inDir, fn)
+
+		ctx, err := api.ReadContextFile(inFile)
+		if err != nil {
+			t.Fatalf("%s %s: %v\n", msg, inFile, err)
+		}
+
+		img, err := api.RenderPage(ctx, 1, 72)
+		if err != nil {
+			t.Fatalf("%s %s: %v\n", msg, inFile, err)
+		}
+
+		// At 72 dpi one pixel corresponds to one user space unit of the rotated page.
+		dim, err := ctx.PageDims()
+		if err != nil {
+			t.Fatalf("%s %s: %v\n", msg, inFile, err)
+		}
+		w, h := float64(img.Bounds().Dx()), float64(img.Bounds().Dy())
+		if d := dim[0]; math.Abs(w-d.Width) > 1 || math.Abs(h-d.Height) > 1 {
+			t.Errorf("%s %s: want %.0fx%.0f pixels, got %.0fx%.0f\n", msg, fn, d.Width, d.Height, w, h)
+		}
+
+		if inked(img) == 0 {
+			t.Errorf("%s %s: blank page\n", msg, fn)
+		}
+	}
+}
+
+func TestRenderPageDPI(t *testing.T) {
+	msg := "TestRenderPageDPI"
+	inFile := filepath.Join(inDir, "testWithText.pdf")
+
+	ctx, err := api.ReadContextFile(inFile)
+	if err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	img1, err := api.RenderPage(ctx, 1, 72)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	img2, err := api.RenderPage(ctx, 1, 144)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if w1, w2 := img1.Bounds().Dx(), img2.Bounds().Dx(); math.Abs(float64(2*w1-w2)) > 2 {
+		t.Errorf("%s: want width %d at 144 dpi, got %d\n", msg, 2*w1, w2)
+	}
+
+	if _, err := api.RenderPage(ctx, ctx.PageCount+1, 72); err == nil {
+		t.Errorf("%s: missing error for invalid page number\n", msg)
+	}
+}
+
+func TestRenderFile(t *testing.T) {
+	msg := "TestRenderFile"
+	inFile := filepath.Join(inDir, "mountain.pdf")
+
+	for _, format := range []string{"png", "jpg"} {
+		dir := filepath.Join(outDir, "render_"+format)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+
+		if err := api.RenderFile(inFile, dir, []string{"1"}, 50, format, nil); err != nil {
+			t.Fatalf("%s %s: %v\n", msg, inFile, err)
+		}
+
+		for _, fn := range []string{"mountain_page_1." + format} {
+			f, err := os.Open(filepath.Join(dir, fn))
+			if err != nil {
+				t.Fatalf("%s: %v\n", msg, err)
+			}
+			_, gotFormat, err := image.DecodeConfig(f)
+			f.Close()
+			if err != nil {
+				t.Fatalf("%s %s: %v\n", msg, fn, err)
+			}
+			if want := map[string]string{"png": "png", "jpg": "jpeg"}[format]; gotFormat != want {
+				t.Errorf("%s %s: want %s, got %s\n", msg, fn, want, gotFormat)
+			}
+		}
+	}
+
+	if err := api.RenderFile(inFile, outDir, nil, 50, "gif", nil); err == nil {
+		t.Errorf("%s: missing error for unsupported format\n", msg)
+	}
+}
+
+func TestPageSVG(t *testing.T) {
+	msg := "TestPageSVG"
+	inFile := filepath.Join(inDir, "testWithText.pdf")
+
+	ctx, err := api.ReadContextFile(inFile)
+	if err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	var buf bytes.Buffer
+	if err := api.PageSVG(ctx, 1, &buf); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// The result is well-formed XML containing paths and text.
+	count := map[string]int{}
+	var sb strings.Builder
+	dec := xml.NewDecoder(&buf)
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%s: invalid SVG: %v\n", msg, err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			count[tok.Name.Local]++
+			inText = tok.Name.Local == "text"
+		case xml.EndElement:
+			inText = false
+		case xml.CharData:
+			if inText {
+				sb.Write(tok)
+			}
+		}
+	}
+
+	if count["svg"] != 1 || count["path"] == 0 || count["text"] == 0 {
+		t.Errorf("%s: unexpected elements: %v\n", msg, count)
+	}
+	if s := sb.String(); !strings.Contains(s, "This") {
+		t.Errorf("%s: missing text, got: %q\n", msg, s)
+	}
+}
+
+func TestExportFile(t *testing.T) {
+	msg := "TestExportFile"
+	inFile := filepath.Join(inDir, "mountain.pdf")
+
+	if err := api.ExportFile(inFile, outDir, []string{"1"}, "svg", nil); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	bb, err := os.ReadFile(filepath.Join(outDir, "mountain_page_1.svg"))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if !bytes.Contains(bb, []byte("data:image/png;base64,")) {
+		t.Errorf("%s: missing embedded image\n", msg)
+	}
+
+	if err := api.ExportFile(inFile, outDir, nil, "pdf", nil); err == nil {
+		t.Errorf("%s: missing error for unsupported format\n", msg)
+	}
+}
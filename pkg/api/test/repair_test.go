@@ -0,0 +1,147 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func hasRepair(repairs []model.Repair, cat model.RepairCategory, objNr int) bool {
+	for _, r := range repairs {
+		if r.Category == cat && (objNr < 0 || r.ObjNr == objNr) {
+			return true
+		}
+	}
+	return false
+}
+
+func testRepair(t *testing.T, msg, fileName string, bb []byte, cat model.RepairCategory, objNr int) {
+	t.Helper()
+
+	inFile := filepath.Join(outDir, fileName)
+	if err := os.WriteFile(inFile, bb, 0644); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	outFile := filepath.Join(outDir, "repaired_"+fileName)
+
+	repairs, err := api.RepairFile(inFile, outFile, nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if !hasRepair(repairs, cat, objNr) {
+		t.Fatalf("%s: missing %s repair for obj#%d: %v\n", msg, cat, objNr, repairs)
+	}
+
+	if err := api.ValidateFile(outFile, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// A repaired file needs no further repairs.
+	if repairs, err = api.RepairFile(outFile, "", nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if len(repairs) > 0 {
+		t.Fatalf("%s: unexpected repairs: %v\n", msg, repairs)
+	}
+}
+
+func TestRepairXRefTable(t *testing.T) {
+	msg := "TestRepairXRefTable"
+
+	bb, err := os.ReadFile(filepath.Join(inDir, "go.pdf"))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// Shift all objects in the second half of the file.
+	i := bytes.Index(bb[len(bb)/2:], []byte("endobj")) + len(bb)/2 + len("endobj")
+	bb = append(append(bb[:i:i], bytes.Repeat([]byte("\n%garbage"), 20)...), bb[i:]...)
+
+	testRepair(t, msg, "shifted.pdf", bb, model.RepairXRef, -1)
+}
+
+func TestRepairStreamLength(t *testing.T) {
+	msg := "TestRepairStreamLength"
+
+	bb, err := os.ReadFile(filepath.Join(inDir, "go.pdf"))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// Shorten the first stream length.
+	m := regexp.MustCompile(`/Length (\d+)`).FindSubmatchIndex(bb)
+	l, err := strconv.Atoi(string(bb[m[2]:m[3]]))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	s := strconv.Itoa(l - 5)
+	bb = append(append(bb[:m[2]:m[2]], s...), append(bytes.Repeat([]byte(" "), m[3]-m[2]-len(s)), bb[m[3]:]...)...)
+
+	testRepair(t, msg, "streamLength.pdf", bb, model.RepairStreamLength, -1)
+}
+
+func TestRepairIndirectStreamLength(t *testing.T) {
+	msg := "TestRepairIndirectStreamLength"
+
+	bb, err := os.ReadFile(filepath.Join(inDir, "Walden.pdf"))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// Let a stream length refer to the head of the free list.
+	bb = bytes.Replace(bb, []byte("/Length 15 0 R"), []byte("/Length 0 0 R "), 1)
+
+	testRepair(t, msg, "indirectStreamLength.pdf", bb, model.RepairStreamLength, -1)
+
+	// Only the repair command records repairs.
+	ctx, err := api.ReadContextFile(filepath.Join(outDir, "indirectStreamLength.pdf"))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if len(ctx.Repairs) > 0 {
+		t.Fatalf("%s: unexpected repairs: %v\n", msg, ctx.Repairs)
+	}
+}
+
+func TestRepairPageCount(t *testing.T) {
+	msg := "TestRepairPageCount"
+
+	bb, err := os.ReadFile(filepath.Join(inDir, "read.go.pdf"))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// Blank out "Count" of the first page tree node.
+	re := regexp.MustCompile(`/Type /Pages\s+/Count \d+`)
+	loc := re.FindIndex(bb)
+	if loc == nil {
+		t.Fatalf("%s: no page tree node found\n", msg)
+	}
+	i := loc[0] + len("/Type /Pages")
+	bb = append(append(bb[:i:i], bytes.Repeat([]byte(" "), loc[1]-i)...), bb[loc[1]:]...)
+
+	testRepair(t, msg, "pageCount.pdf", bb, model.RepairPageCount, -1)
+}
@@ -0,0 +1,96 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+func TestReplaceText(t *testing.T) {
+	msg := "TestReplaceText"
+	inFile := filepath.Join(inDir, "mountain.pdf")
+
+	for _, fontName := range []string{"Helvetica", "Roboto-Regular"} {
+
+		// Create some text using a core font and a user font subset.
+		stampFile := filepath.Join(outDir, "ReplaceText"+fontName+"Stamp.pdf")
+		desc := "font:" + fontName + ", points:48, scale:1 abs, rot:0"
+		if err := api.AddTextWatermarksFile(inFile, stampFile, nil, true, "Acme Corp", desc, nil); err != nil {
+			t.Fatalf("%s %s: %v\n", msg, fontName, err)
+		}
+
+		ctx, err := api.ReadContextFile(stampFile)
+		if err != nil {
+			t.Fatalf("%s %s: %v\n", msg, fontName, err)
+		}
+
+		// Replacement text with glyphs not used so far.
+		n, uu, err := api.ReplaceText(ctx, nil, "Acme", "Zyxw")
+		if err != nil {
+			t.Fatalf("%s %s: %v\n", msg, fontName, err)
+		}
+		if n != 1 || len(uu) > 0 {
+			t.Fatalf("%s %s: want 1 replacement, got %d %v\n", msg, fontName, n, uu)
+		}
+
+		outFile := filepath.Join(outDir, "ReplaceText"+fontName+".pdf")
+		if err := api.WriteContextFile(ctx, outFile); err != nil {
+			t.Fatalf("%s %s: %v\n", msg, fontName, err)
+		}
+		if err := api.ValidateFile(outFile, nil); err != nil {
+			t.Fatalf("%s %s: %v\n", msg, fontName, err)
+		}
+
+		mm, err := api.SearchTextFile(outFile, nil, "Zyxw Corp", nil)
+		if err != nil {
+			t.Fatalf("%s %s: %v\n", msg, fontName, err)
+		}
+		if len(mm) != 1 {
+			t.Errorf("%s %s: replacement text not found\n", msg, fontName)
+		}
+		if mm, _ = api.SearchTextFile(outFile, nil, "Acme", nil); len(mm) != 0 {
+			t.Errorf("%s %s: %d occurrences left\n", msg, fontName, len(mm))
+		}
+	}
+}
+
+func TestReplaceTextUnencodable(t *testing.T) {
+	msg := "TestReplaceTextUnencodable"
+	inFile := filepath.Join(inDir, "mountain.pdf")
+	stampFile := filepath.Join(outDir, "ReplaceTextUnencodable.pdf")
+
+	if err := api.AddTextWatermarksFile(inFile, stampFile, nil, true, "Acme Corp", "font:Helvetica", nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	ctx, err := api.ReadContextFile(stampFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// WinAnsiEncoding has no glyph for Japanese text.
+	n, uu, err := api.ReplaceText(ctx, nil, "Acme", "日本")
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if n != 0 || len(uu) != 1 || uu[0].PageNr != 1 {
+		t.Fatalf("%s: want 1 unreplaced occurrence, got %d %v\n", msg, n, uu)
+	}
+}
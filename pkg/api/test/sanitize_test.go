@@ -0,0 +1,239 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// writeActiveContentFile writes a copy of inFile carrying JavaScript, a launch action,
+// an attachment and page content on a layer hidden by default.
+func writeActiveContentFile(t *testing.T, inFile, outFile string) {
+	t.Helper()
+
+	ctx, err := api.ReadContextFile(inFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// JavaScript followed by a launch action on open.
+	launch := types.Dict{"Type": types.Name("Action"), "S": types.Name("Launch"), "F": types.StringLiteral("calc.exe")}
+	root["OpenAction"] = types.Dict{"Type": types.Name("Action"), "S": types.Name("JavaScript"), "JS": types.StringLiteral("app.alert(1);"), "Next": launch}
+
+	pageDict, _, inhPAttrs, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageDict["AA"] = types.Dict{"O": types.Dict{"S": types.Name("JavaScript"), "JS": types.StringLiteral("app.alert(2);")}}
+
+	// Move the page content onto a hidden layer.
+	ocg, err := ctx.IndRefForNewObject(types.Dict{"Type": types.Name("OCG"), "Name": types.StringLiteral("Hidden")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root["OCProperties"] = types.Dict{
+		"OCGs": types.Array{*ocg},
+		"D":    types.Dict{"OFF": types.Array{*ocg}, "Order": types.Array{*ocg}},
+	}
+
+	res := inhPAttrs.Resources.Clone().(types.Dict)
+	res["Properties"] = types.Dict{"OC0": *ocg}
+	pageDict["Resources"] = res
+
+	bb, err := ctx.PageContent(pageDict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb = append(append([]byte("/OC /OC0 BDC\n"), bb...), []byte("\nEMC\n")...)
+	indRef, err := ctx.StreamDictIndRef(bb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageDict["Contents"] = *indRef
+
+	if err := api.WriteContextFile(ctx, outFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := api.AddAttachmentsFile(outFile, "", []string{filepath.Join(resDir, "logoSmall.png")}, false, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	msg := "TestSanitize"
+	inFile := filepath.Join(outDir, "ActiveContent.pdf")
+	outFile := filepath.Join(outDir, "Sanitized.pdf")
+
+	writeActiveContentFile(t, filepath.Join(inDir, "Acroforms2.pdf"), inFile)
+
+	items, err := api.SanitizeFile(inFile, outFile, 0, nil)
+	if err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	count := map[string]int{}
+	for _, item := range items {
+		count[item.Category]++
+	}
+	for cat, want := range map[string]int{"javascript": 2, "actions": 1, "attachments": 1, "layers": 2} {
+		if count[cat] != want {
+			t.Errorf("%s: want %d items for %s, got %d: %v\n", msg, want, cat, count[cat], items)
+		}
+	}
+
+	if err := api.ValidateFile(outFile, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	ctx, err := api.ReadContextFile(outFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	root, _ := ctx.Catalog()
+	for _, k := range []string{"OpenAction", "Names", "Metadata"} {
+		if _, found := root.Find(k); found {
+			t.Errorf("%s: root entry %s not removed\n", msg, k)
+		}
+	}
+	pageDict, _, _, _ := ctx.PageDict(1, false)
+	if _, found := pageDict.Find("AA"); found {
+		t.Errorf("%s: page additional actions not removed\n", msg)
+	}
+
+	// The hidden page content is gone.
+	f, err := os.Open(outFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	defer f.Close()
+	pp, err := api.ExtractTextRaw(f, []string{"1"}, nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if len(pp) != 1 || len(pp[0].Lines) != 0 {
+		t.Errorf("%s: hidden text not removed\n", msg)
+	}
+}
+
+func TestSanitizeCategories(t *testing.T) {
+	msg := "TestSanitizeCategories"
+	inFile := filepath.Join(outDir, "ActiveContent.pdf")
+	outFile := filepath.Join(outDir, "SanitizedActions.pdf")
+
+	writeActiveContentFile(t, filepath.Join(inDir, "Acroforms2.pdf"), inFile)
+
+	cats, err := pdfcpu.ParseSanitizeCategories("actions, attachments")
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if _, err := api.SanitizeFile(inFile, outFile, cats, nil); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	if err := api.ValidateFile(outFile, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// JavaScript survives while the launch action in its sequel is gone.
+	ctx, err := api.ReadContextFile(outFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	root, _ := ctx.Catalog()
+	d, err := ctx.DereferenceDict(root["OpenAction"])
+	if err != nil || d == nil {
+		t.Fatalf("%s: missing open action\n", msg)
+	}
+	if s := d.NameEntry("S"); s == nil || *s != "JavaScript" {
+		t.Errorf("%s: unexpected open action: %v\n", msg, d)
+	}
+	if _, found := d.Find("Next"); found {
+		t.Errorf("%s: launch action not removed\n", msg)
+	}
+
+	if _, err := pdfcpu.ParseSanitizeCategories("scripts"); err == nil {
+		t.Errorf("%s: missing error for unknown category\n", msg)
+	}
+}
+
+func TestSanitizeIncremental(t *testing.T) {
+	msg := "TestSanitizeIncremental"
+	inFile := filepath.Join(outDir, "ActiveContentPlain.pdf")
+	outFile := filepath.Join(outDir, "SanitizedIncremental.pdf")
+
+	writeActiveContentFile(t, filepath.Join(inDir, "Acroforms2.pdf"), inFile)
+
+	// Keep the JavaScript readable in the input file.
+	ctx, err := api.ReadContextFile(inFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	ctx.WriteObjectStream = false
+	ctx.WriteXRefStream = false
+	if err := api.WriteContextFile(ctx, inFile); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	var attachment []byte
+	for _, entry := range ctx.Table {
+		if sd, ok := entry.Object.(types.StreamDict); ok && sd.Type() != nil && *sd.Type() == "EmbeddedFile" {
+			attachment = sd.Raw
+		}
+	}
+
+	bb, err := os.ReadFile(inFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	payload := []byte("app.alert(1);")
+	if !bytes.Contains(bb, payload) || len(attachment) == 0 || !bytes.Contains(bb, attachment) {
+		t.Fatalf("%s: missing active content in %s\n", msg, inFile)
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.Incremental = true
+
+	if _, err := api.SanitizeFile(inFile, outFile, 0, conf); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	// Removed content must not survive in some earlier revision.
+	if bb, err = os.ReadFile(outFile); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if bytes.Contains(bb, payload) {
+		t.Errorf("%s: JavaScript still present\n", msg)
+	}
+	if bytes.Contains(bb, attachment) {
+		t.Errorf("%s: attachment still present\n", msg)
+	}
+}
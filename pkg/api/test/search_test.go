@@ -0,0 +1,85 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func TestSearchText(t *testing.T) {
+	msg := "TestSearchText"
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+
+	// A match may span lines.
+	mm, err := api.SearchTextFile(inFile, []string{"21"}, `compiled\s+language`, nil)
+	if err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+	if len(mm) != 1 {
+		t.Fatalf("%s: want 1 match, got %d\n", msg, len(mm))
+	}
+
+	m := mm[0]
+	if m.Page != 21 || m.Text != "compiled language" || len(m.Rects) != 1 || len(m.Quads) != 1 {
+		t.Fatalf("%s: unexpected match: %+v\n", msg, m)
+	}
+
+	// Quad points: upper left, upper right, lower left, lower right.
+	ql, r := m.Quads[0], m.Rects[0]
+	if ql.P1.X != r.LL.X || ql.P1.Y != r.UR.Y || ql.P4.X != r.UR.X || ql.P4.Y != r.LL.Y {
+		t.Errorf("%s: quad %v does not match rect %v\n", msg, ql, r)
+	}
+
+	if _, err := api.SearchTextFile(inFile, nil, `(`, nil); err == nil {
+		t.Errorf("%s: missing error for invalid pattern\n", msg)
+	}
+}
+
+func TestHighlightText(t *testing.T) {
+	msg := "TestHighlightText"
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+	outFile := filepath.Join(samplesDir, "annotations", "HighlightText.pdf")
+
+	mm, err := api.HighlightTextFile(inFile, outFile, []string{"21-22"}, `(?i)go toolchain|unicode`, nil, nil)
+	if err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+	if len(mm) == 0 {
+		t.Fatalf("%s: no matches\n", msg)
+	}
+
+	if err := api.ValidateFile(outFile, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// Each match results in a highlight annotation.
+	ctx, err := api.ReadContextFile(outFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	n := 0
+	for _, pgAnnots := range ctx.PageAnnots {
+		n += len(pgAnnots[model.AnnHighLight].Map)
+	}
+	if n != len(mm) {
+		t.Errorf("%s: want %d highlight annotations, got %d\n", msg, len(mm), n)
+	}
+}
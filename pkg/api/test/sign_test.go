@@ -0,0 +1,586 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/sign"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func createTestCertificate(t *testing.T, cn string) (crypto.Signer, []*x509.Certificate) {
+	t.Helper()
+	return createTestCertificateValidity(t, cn, time.Now().Add(-time.Hour), time.Now().Add(24*time.Hour))
+}
+
+func createTestCertificateValidity(t *testing.T, cn string, notBefore, notAfter time.Time) (crypto.Signer, []*x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	bb, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(bb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, []*x509.Certificate{cert}
+}
+
+// checkByteRange verifies the ByteRange of the last signature of fileName covers the whole file.
+func checkByteRange(t *testing.T, msg, fileName string) {
+	t.Helper()
+
+	bb, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	mm := regexp.MustCompile(`/ByteRange\s*\[0 (\d+) (\d+) (\d+)\s*\]`).FindAllSubmatch(bb, -1)
+	if len(mm) == 0 {
+		t.Fatalf("%s: missing ByteRange\n", msg)
+	}
+
+	m := mm[len(mm)-1]
+	var br [3]int
+	for i := range br {
+		if br[i], err = strconv.Atoi(string(m[i+1])); err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+	}
+
+	if bb[br[0]] != '<' || bb[br[1]-1] != '>' || br[1]+br[2] != len(bb) {
+		t.Fatalf("%s: invalid ByteRange %v for file size %d\n", msg, br, len(bb))
+	}
+}
+
+func TestSign(t *testing.T) {
+	msg := "TestSign"
+
+	signer, chain := createTestCertificate(t, "Jane Signer")
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	outFile1 := filepath.Join(outDir, "signed1.pdf")
+	outFile2 := filepath.Join(outDir, "signed2.pdf")
+
+	// Invisible PAdES signature
+	if err := api.SignFile(inFile, outFile1, signer, chain, nil, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	checkIncrement(t, msg, inFile, outFile1)
+	checkByteRange(t, msg, outFile1)
+
+	// Visible PKCS#7 signature
+	sig := model.DefaultSignature()
+	sig.Field = "Approval"
+	sig.SubFilter = model.SubFilterPKCS7Detached
+	sig.Reason = "approved"
+	sig.Rect = types.NewRectangle(400, 50, 580, 100)
+
+	if err := api.SignFile(outFile1, outFile2, signer, chain, sig, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	checkIncrement(t, msg, outFile1, outFile2)
+	checkByteRange(t, msg, outFile2)
+
+	ctx, err := api.ReadContextFile(outFile2)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if err := api.ValidateContext(ctx); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if !ctx.SignatureExist || !ctx.AppendOnly {
+		t.Fatalf("%s: missing SigFlags\n", msg)
+	}
+
+	// A signature field may only be signed once.
+	sig.Field = "Signature1"
+	if err := api.SignFile(outFile2, filepath.Join(outDir, "signed3.pdf"), signer, chain, sig, nil); err == nil {
+		t.Fatalf("%s: signed field Signature1 twice\n", msg)
+	}
+}
+
+func TestSignPKCS12(t *testing.T) {
+	msg := "TestSignPKCS12"
+
+	key, chain := createTestCertificate(t, "John Signer")
+
+	bb, err := pkcs12.Modern2023.Encode(key, chain[0], nil, "secret")
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	certFile := filepath.Join(outDir, "id.p12")
+	if err := os.WriteFile(certFile, bb, 0644); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if _, _, err := api.ReadPKCS12File(certFile, "wrong"); err == nil {
+		t.Fatalf("%s: accepted wrong password\n", msg)
+	}
+
+	signer, chain, err := api.ReadPKCS12File(certFile, "secret")
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	inFile := filepath.Join(inDir, "read.go.pdf")
+	outFile := filepath.Join(outDir, "signedPKCS12.pdf")
+
+	if err := api.SignFile(inFile, outFile, signer, chain, nil, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	checkIncrement(t, msg, inFile, outFile)
+	checkByteRange(t, msg, outFile)
+}
+
+func writeTrustStore(t *testing.T, certs ...*x509.Certificate) string {
+	t.Helper()
+
+	dir := filepath.Join(outDir, "trust")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var bb []byte
+	for _, cert := range certs {
+		bb = append(bb, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "root.pem"), bb, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestVerifySignatures(t *testing.T) {
+	msg := "TestVerifySignatures"
+
+	signer, chain := createTestCertificate(t, "Jane Signer")
+	trustDir := writeTrustStore(t, chain[0])
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	outFile1 := filepath.Join(outDir, "verify1.pdf")
+	outFile2 := filepath.Join(outDir, "verify2.pdf")
+
+	sig := model.DefaultSignature()
+	sig.Reason = "approved"
+
+	if err := api.SignFile(inFile, outFile1, signer, chain, sig, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	sig = model.DefaultSignature()
+	sig.SubFilter = model.SubFilterPKCS7Detached
+	sig.Rect = types.NewRectangle(400, 50, 580, 100)
+
+	if err := api.SignFile(outFile1, outFile2, signer, chain, sig, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	sigs, err := api.SignaturesFile(outFile2, nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if len(sigs) != 2 {
+		t.Fatalf("%s: want 2 signatures, got %d\n", msg, len(sigs))
+	}
+
+	sd := sigs[0]
+	if !sd.Signed || sd.Reason != "approved" || sd.SubFilter != model.SubFilterCAdESDetached || sd.Visible || sd.Signer != "CN=Jane Signer" {
+		t.Fatalf("%s: unexpected signature details: %+v\n", msg, sd)
+	}
+	if sd.WholeDocument || sd.LaterRevisions != 1 {
+		t.Fatalf("%s: first signature should be followed by 1 revision: %+v\n", msg, sd)
+	}
+	if sd = sigs[1]; !sd.Visible || sd.PageNr != 1 || !sd.WholeDocument || sd.LaterRevisions != 0 {
+		t.Fatalf("%s: unexpected signature details: %+v\n", msg, sd)
+	}
+
+	if sigs, err = api.VerifySignaturesFile(outFile2, trustDir, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	for _, sd := range sigs {
+		if !sd.Verification.Valid() {
+			t.Fatalf("%s: %s: %v\n", msg, sd.Field, sd.Verification.Problems)
+		}
+	}
+
+	// The signer certificate is unknown to the system certificate pool.
+	if sigs, err = api.VerifySignaturesFile(outFile2, "", nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if v := sigs[0].Verification; !v.DigestOK || !v.SignatureOK || v.Trusted {
+		t.Fatalf("%s: unexpected verification result: %+v\n", msg, v)
+	}
+
+	// Modify the signing time of the first signature.
+	bb, err := os.ReadFile(outFile2)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	bb = bytes.Replace(bb, []byte("/M(D:2"), []byte("/M(D:1"), 1)
+
+	sigs, err = api.VerifySignatures(bytes.NewReader(bb), trustDir, nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if sigs[0].Verification.DigestOK || sigs[1].Verification.DigestOK {
+		t.Fatalf("%s: undetected modification\n", msg)
+	}
+}
+
+func TestVerifyByteRange(t *testing.T) {
+	msg := "TestVerifyByteRange"
+
+	signer, chain := createTestCertificate(t, "Jane Signer")
+	trustDir := writeTrustStore(t, chain[0])
+
+	outFile := filepath.Join(outDir, "verifyByteRange.pdf")
+
+	if err := api.SignFile(filepath.Join(inDir, "go.pdf"), outFile, signer, chain, nil, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	bb, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	m := regexp.MustCompile(`/ByteRange\s*(\[0 (\d+) (\d+) (\d+)\s*\])`).FindSubmatchIndex(bb)
+	if m == nil {
+		t.Fatalf("%s: missing ByteRange\n", msg)
+	}
+
+	var br [3]int
+	for i := range br {
+		if br[i], err = strconv.Atoi(string(bb[m[2*i+4]:m[2*i+5]])); err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+	}
+
+	for _, s := range []string{
+		fmt.Sprintf("[0 %d]", len(bb)),
+		"[0 10 20 10 40 10]",
+		fmt.Sprintf("[0 %d %d %d]", br[0]-1, br[1]+1, br[2]-1),
+		fmt.Sprintf("[0 %d %d %d]", br[0]+1, br[1]-1, br[2]+1),
+		fmt.Sprintf("[0 10 20 %d]", len(bb)-20),
+	} {
+		// Keep all offsets intact.
+		n := m[3] - m[2]
+		if len(s) > n {
+			t.Fatalf("%s: ByteRange %s too long\n", msg, s)
+		}
+		bb1 := append([]byte(nil), bb...)
+		copy(bb1[m[2]:m[3]], s+strings.Repeat(" ", n-len(s)))
+
+		sigs, err := api.VerifySignatures(bytes.NewReader(bb1), trustDir, nil)
+		if err != nil {
+			t.Fatalf("%s: %v\n", msg, err)
+		}
+		sd := sigs[0]
+		if sd.WholeDocument || len(sd.Problems) == 0 || sd.Verification.Valid() {
+			t.Fatalf("%s: undetected invalid ByteRange %s: %v\n", msg, s, sd.Problems)
+		}
+	}
+}
+
+func TestVerifyExpiredCertificate(t *testing.T) {
+	msg := "TestVerifyExpiredCertificate"
+
+	signer, chain := createTestCertificateValidity(t, "Jane Signer", time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC))
+	trustDir := writeTrustStore(t, chain[0])
+
+	outFile := filepath.Join(outDir, "verifyExpired.pdf")
+
+	if err := api.SignFile(filepath.Join(inDir, "go.pdf"), outFile, signer, chain, nil, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	bb, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// Claim a signing time within the validity period of the certificate.
+	bb = regexp.MustCompile(`/M\(D:\d{4}`).ReplaceAll(bb, []byte("/M(D:2000"))
+
+	sigs, err := api.VerifySignatures(bytes.NewReader(bb), trustDir, nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	sd := sigs[0]
+	if sd.SigningTime == nil || sd.SigningTime.Year() != 2000 {
+		t.Fatalf("%s: missing claimed signing time: %v\n", msg, sd.SigningTime)
+	}
+	if sd.Verification.Trusted {
+		t.Fatalf("%s: expired certificate trusted at the claimed signing time\n", msg)
+	}
+}
+
+func writeDSSTestData(t *testing.T) []string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{Number: big.NewInt(1), ThisUpdate: time.Now(), NextUpdate: time.Now().Add(time.Hour)}, ca, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A minimal OCSPResponse (RFC 6960) carrying a basic response.
+	type responseBytes struct {
+		ResponseType asn1.ObjectIdentifier
+		Response     []byte
+	}
+	ocspDER, err := asn1.Marshal(struct {
+		Status        asn1.Enumerated
+		ResponseBytes responseBytes `asn1:"explicit,tag:0"`
+	}{ResponseBytes: responseBytes{ResponseType: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}, Response: []byte{0x30, 0x00}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	for fn, bb := range map[string][]byte{
+		"ca.pem":    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		"ca.cer":    certDER,
+		"ca.crl":    crlDER,
+		"ca.ocsp":   ocspDER,
+		"dummy.txt": []byte("no validation data"),
+	} {
+		fn = filepath.Join(outDir, fn)
+		if err := os.WriteFile(fn, bb, 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, fn)
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+func TestAddDSS(t *testing.T) {
+	msg := "TestAddDSS"
+
+	signer, chain := createTestCertificate(t, "Jane Signer")
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	signedFile := filepath.Join(outDir, "dss.pdf")
+	outFile := filepath.Join(outDir, "dssLT.pdf")
+
+	if err := api.SignFile(inFile, signedFile, signer, chain, nil, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// ca.cer, ca.crl, ca.ocsp, ca.pem, dummy.txt
+	files := writeDSSTestData(t)
+
+	if err := api.AddDSSFile(signedFile, outFile, files, nil); err == nil {
+		t.Fatalf("%s: accepted dummy.txt\n", msg)
+	}
+
+	if err := api.AddDSSFile(signedFile, outFile, files[:4], nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	checkIncrement(t, msg, signedFile, outFile)
+
+	ctx, err := api.ReadContextFile(outFile)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if err := api.ValidateContext(ctx); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	d, err := ctx.DereferenceDict(ctx.RootDict["DSS"])
+	if err != nil || d == nil {
+		t.Fatalf("%s: missing DSS: %v\n", msg, err)
+	}
+
+	// The PEM and DER encoded CA certificate get stored once.
+	for k, n := range map[string]int{"Certs": 1, "CRLs": 1, "OCSPs": 1} {
+		arr, err := ctx.DereferenceArray(d[k])
+		if err != nil || len(arr) != n {
+			t.Fatalf("%s: want %d %s, got %v\n", msg, n, k, arr)
+		}
+	}
+
+	vri, err := ctx.DereferenceDict(d["VRI"])
+	if err != nil || len(vri) != 1 {
+		t.Fatalf("%s: want 1 VRI entry, got %v\n", msg, vri)
+	}
+
+	sigs, err := api.VerifySignaturesFile(outFile, "", nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if v := sigs[0].Verification; !v.DigestOK || !v.SignatureOK || sigs[0].LaterRevisions != 1 {
+		t.Fatalf("%s: broken signature: %+v\n", msg, sigs[0])
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	msg := "TestTimestamp"
+
+	signer, chain := createTestCertificate(t, "Jane Signer")
+	tsaSigner, tsaChain := createTestCertificate(t, "Test TSA")
+	trustDir := writeTrustStore(t, chain[0], tsaChain[0])
+
+	// A local stand-in for a RFC 3161 time stamping authority.
+	srv := httptest.NewServer(&testTSA{signer: tsaSigner, chain: tsaChain})
+	defer srv.Close()
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	signedFile := filepath.Join(outDir, "timestampBT.pdf")
+	outFile := filepath.Join(outDir, "timestampLTA.pdf")
+
+	// PAdES B-T
+	sig := model.DefaultSignature()
+	sig.TSA = sign.NewHTTPTimestampAuthority(srv.URL)
+
+	if err := api.SignFile(inFile, signedFile, signer, chain, sig, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	checkByteRange(t, msg, signedFile)
+
+	// PAdES B-LTA
+	sig = &model.Signature{TSA: sign.NewHTTPTimestampAuthority(srv.URL)}
+
+	if err := api.TimestampFile(signedFile, outFile, sig, nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	checkByteRange(t, msg, outFile)
+	checkIncrement(t, msg, signedFile, outFile)
+
+	sigs, err := api.VerifySignaturesFile(outFile, trustDir, nil)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if len(sigs) != 2 {
+		t.Fatalf("%s: want 2 signatures, got %d\n", msg, len(sigs))
+	}
+
+	sd := sigs[0]
+	if sd.Timestamp == nil || sd.Verification.TimestampOK == nil || !sd.Verification.Valid() {
+		t.Fatalf("%s: invalid signature timestamp: %+v %+v\n", msg, sd, sd.Verification)
+	}
+
+	sd = sigs[1]
+	if sd.Type != sign.SignatureTypeDocTimestamp || sd.SubFilter != model.SubFilterRFC3161 || sd.Signer != "CN=Test TSA" || sd.SigningTime == nil {
+		t.Fatalf("%s: unexpected document timestamp: %+v\n", msg, sd)
+	}
+	if !sd.Verification.Valid() {
+		t.Fatalf("%s: %v\n", msg, sd.Verification.Problems)
+	}
+
+	// The TSA certificate is unknown to the system certificate pool.
+	if sigs, err = api.VerifySignaturesFile(outFile, "", nil); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if v := sigs[0].Verification; v.TimestampOK == nil || *v.TimestampOK {
+		t.Fatalf("%s: trusted unknown TSA: %+v\n", msg, v)
+	}
+
+	// A document timestamp needs a TSA.
+	if err := api.TimestampFile(signedFile, outFile, &model.Signature{}, nil); err == nil {
+		t.Fatalf("%s: missing TSA accepted\n", msg)
+	}
+}
+
+func TestTimestampUnexpectedReply(t *testing.T) {
+	msg := "TestTimestampUnexpectedReply"
+
+	// Some proxy answering with an error page instead of a timestamp reply.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>Service unavailable</html>"))
+	}))
+	defer srv.Close()
+
+	inFile := filepath.Join(inDir, "go.pdf")
+	outFile := filepath.Join(outDir, "timestampUnexpectedReply.pdf")
+
+	sig := &model.Signature{TSA: sign.NewHTTPTimestampAuthority(srv.URL)}
+	if err := api.TimestampFile(inFile, outFile, sig, nil); err == nil {
+		t.Fatalf("%s: unexpected timestamp reply accepted\n", msg)
+	}
+}
@@ -0,0 +1,289 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A minimal RFC 3161 time stamping authority for testing.
+// It issues ECDSA signed timestamp tokens for the test certificates created by createTestCertificate.
+
+var (
+	oidSignedData             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidAttrContentType        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigest      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttrSigningCertV2      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidTSTInfo                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidDigestSHA256           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSignatureECDSAWithSHA2 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidAnyPolicy              = asn1.ObjectIdentifier{2, 5, 29, 32, 0}
+)
+
+type tsaMessageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type tsaRequest struct {
+	Version        int
+	MessageImprint tsaMessageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional,default:false"`
+}
+
+type tsaStatus struct {
+	Status       int
+	StatusString []string `asn1:"optional,utf8"`
+}
+
+type tsaResponse struct {
+	Status         tsaStatus
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type tsaTSTInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint tsaMessageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+type tsaAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+type tsaIssuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type tsaSignerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type tsaEncapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue
+}
+
+type tsaSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo tsaEncapsulatedContentInfo
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	SignerInfos      []tsaSignerInfo `asn1:"set"`
+}
+
+type tsaContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+// testTSA serves RFC 3161 timestamp requests via HTTP.
+type testTSA struct {
+	signer crypto.Signer
+	chain  []*x509.Certificate // chain[0] is the TSA certificate
+
+	mu     sync.Mutex
+	serial int64
+}
+
+func (tsa *testTSA) nextSerial() *big.Int {
+	tsa.mu.Lock()
+	defer tsa.mu.Unlock()
+	tsa.serial++
+	return big.NewInt(tsa.serial)
+}
+
+func tsaNewAttribute(oid asn1.ObjectIdentifier, val interface{}) (tsaAttribute, error) {
+	bb, err := asn1.Marshal(val)
+	if err != nil {
+		return tsaAttribute{}, err
+	}
+	return tsaAttribute{Type: oid, Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bb}}, nil
+}
+
+// tsaSignedAttributes returns the DER encoded SET OF attributes to be signed.
+func tsaSignedAttributes(digest []byte, cert *x509.Certificate) ([]byte, error) {
+	certHash := sha256.Sum256(cert.Raw)
+
+	type essCertIDv2 struct{ CertHash []byte }
+	type signingCertificateV2 struct{ Certs []essCertIDv2 }
+
+	var ss [][]byte
+	for _, attr := range []struct {
+		oid asn1.ObjectIdentifier
+		val interface{}
+	}{
+		{oidAttrContentType, oidTSTInfo},
+		{oidAttrMessageDigest, digest},
+		{oidAttrSigningCertV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:]}}}},
+	} {
+		a, err := tsaNewAttribute(attr.oid, attr.val)
+		if err != nil {
+			return nil, err
+		}
+		bb, err := asn1.Marshal(a)
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, bb)
+	}
+
+	// DER requires the elements of a SET OF to be sorted.
+	sort.Slice(ss, func(i, j int) bool { return bytes.Compare(ss[i], ss[j]) < 0 })
+
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(ss, nil)})
+}
+
+func (tsa *testTSA) token(imprint tsaMessageImprint, nonce *big.Int, withCerts bool) ([]byte, error) {
+	info, err := asn1.Marshal(tsaTSTInfo{
+		Version:        1,
+		Policy:         oidAnyPolicy,
+		MessageImprint: imprint,
+		SerialNumber:   tsa.nextSerial(),
+		GenTime:        time.Now().UTC().Truncate(time.Second),
+		Nonce:          nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cert := tsa.chain[0]
+	digest := sha256.Sum256(info)
+
+	attrs, err := tsaSignedAttributes(digest[:], cert)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature covers the DER encoded signed attributes.
+	attrsDigest := sha256.Sum256(attrs)
+	sig, err := tsa.signer.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	var signedAttrs asn1.RawValue
+	if _, err := asn1.Unmarshal(attrs, &signedAttrs); err != nil {
+		return nil, err
+	}
+	signedAttrs.Class, signedAttrs.Tag, signedAttrs.FullBytes = asn1.ClassContextSpecific, 0, nil
+
+	sid, err := asn1.Marshal(tsaIssuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, SerialNumber: cert.SerialNumber})
+	if err != nil {
+		return nil, err
+	}
+
+	eContent, err := asn1.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []byte
+	if withCerts {
+		for _, c := range tsa.chain {
+			certs = append(certs, c.Raw...)
+		}
+	}
+
+	digestAlg := pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256, Parameters: asn1.NullRawValue}
+
+	sd, err := asn1.Marshal(tsaSignedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlg},
+		EncapContentInfo: tsaEncapsulatedContentInfo{
+			EContentType: oidTSTInfo,
+			EContent:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: eContent},
+		},
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []tsaSignerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    digestAlg,
+			SignedAttrs:        signedAttrs,
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA2},
+			Signature:          sig,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(tsaContentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
+
+func (tsa *testTSA) respond(req []byte) ([]byte, error) {
+	var r tsaRequest
+	if _, err := asn1.Unmarshal(req, &r); err != nil {
+		return asn1.Marshal(tsaResponse{Status: tsaStatus{Status: 2, StatusString: []string{"bad request"}}})
+	}
+
+	token, err := tsa.token(r.MessageImprint, r.Nonce, r.CertReq)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(tsaResponse{Status: tsaStatus{Status: 0}, TimeStampToken: asn1.RawValue{FullBytes: token}})
+}
+
+func (tsa *testTSA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/timestamp-query" {
+		http.Error(w, "expected timestamp query", http.StatusBadRequest)
+		return
+	}
+
+	req, err := io.ReadAll(io.LimitReader(r.Body, 1<<16))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := tsa.respond(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/timestamp-reply")
+	w.Write(resp)
+}
@@ -118,6 +118,86 @@ func ValidateFiles(inFiles []string, conf *model.Configuration) error {
 	return nil
 }
 
+func validationDiagnostics(rs io.ReadSeeker, conf *model.Configuration, mode int) ([]model.Diagnostic, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	conf.ValidationMode = mode
+
+	ctx, err := ReadContext(rs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.CollectDiagnostics = true
+
+	if err = ValidateContext(ctx); err != nil {
+		return nil, err
+	}
+
+	return ctx.Diagnostics, nil
+}
+
+func diagnosticKey(d model.Diagnostic) string {
+	return d.Path + "\x00" + d.Msg
+}
+
+// ValidateReport validates a PDF stream read from rs and returns all spec violations found
+// instead of aborting on the first one.
+// rs gets validated in strict and in relaxed mode:
+// violations tolerated by relaxed validation are of severity model.SeverityStrict,
+// all other violations are of severity model.SeverityRelaxed.
+// An empty report means rs is valid in strict mode.
+func ValidateReport(rs io.ReadSeeker, conf *model.Configuration) ([]model.Diagnostic, error) {
+	if rs == nil {
+		return nil, errors.New("pdfcpu: ValidateReport: missing rs")
+	}
+
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.VALIDATE
+
+	mode := conf.ValidationMode
+	defer func() { conf.ValidationMode = mode }()
+
+	strict, err := validationDiagnostics(rs, conf, model.ValidationStrict)
+	if err != nil {
+		return nil, err
+	}
+
+	relaxed, err := validationDiagnostics(rs, conf, model.ValidationRelaxed)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string]bool{}
+	for _, d := range relaxed {
+		m[diagnosticKey(d)] = true
+	}
+
+	diags := []model.Diagnostic{}
+
+	for _, d := range strict {
+		k := diagnosticKey(d)
+		if m[k] {
+			d.Severity = model.SeverityRelaxed
+			delete(m, k)
+		}
+		diags = append(diags, d)
+	}
+
+	// Violations showing up in relaxed mode only.
+	for _, d := range relaxed {
+		if m[diagnosticKey(d)] {
+			diags = append(diags, d)
+		}
+	}
+
+	return diags, nil
+}
+
 // DumpObject writes an object from rs to stdout.
 func DumpObject(rs io.ReadSeeker, mode, objNr int, conf *model.Configuration) error {
 	if rs == nil {
@@ -19,11 +19,15 @@ package cli
 
 import (
 	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 )
 
 // Validate inFile against ISO-32000-1:2008.
 func Validate(cmd *Command) ([]string, error) {
+	if cmd.BoolVal1 {
+		return ValidateFilesJSON(cmd.InFiles, cmd.Conf)
+	}
 	return nil, api.ValidateFiles(cmd.InFiles, cmd.Conf)
 }
 
@@ -32,6 +36,40 @@ func Optimize(cmd *Command) ([]string, error) {
 	return nil, api.OptimizeFile(*cmd.InFile, *cmd.OutFile, cmd.Conf)
 }
 
+// Repair inFile and write result to outFile.
+func Repair(cmd *Command) ([]string, error) {
+	return RepairFile(*cmd.InFile, *cmd.OutFile, cmd.BoolVal1, cmd.Conf)
+}
+
+// Sign inFile using the PKCS#12 key store of cmd and write result to outFile.
+func Sign(cmd *Command) ([]string, error) {
+	signer, chain, err := api.ReadPKCS12File(cmd.StringVal, cmd.StringVals[0])
+	if err != nil {
+		return nil, err
+	}
+	return nil, api.SignFile(*cmd.InFile, *cmd.OutFile, signer, chain, cmd.Signature, cmd.Conf)
+}
+
+// Timestamp adds a document timestamp to inFile and writes the result to outFile.
+func Timestamp(cmd *Command) ([]string, error) {
+	return nil, api.TimestampFile(*cmd.InFile, *cmd.OutFile, cmd.Signature, cmd.Conf)
+}
+
+// ListSignatures returns all signatures of inFile.
+func ListSignatures(cmd *Command) ([]string, error) {
+	return ListSignaturesFile(*cmd.InFile, cmd.BoolVal1, cmd.Conf)
+}
+
+// VerifySignatures verifies all signatures of inFile.
+func VerifySignatures(cmd *Command) ([]string, error) {
+	return VerifySignaturesFile(*cmd.InFile, cmd.StringVal, cmd.BoolVal1, cmd.Conf)
+}
+
+// AddDSS adds validation data to the Document Security Store of inFile and writes the result to outFile.
+func AddDSS(cmd *Command) ([]string, error) {
+	return nil, api.AddDSSFile(*cmd.InFile, *cmd.OutFile, cmd.InFiles, cmd.Conf)
+}
+
 // Encrypt inFile and write result to outFile.
 func Encrypt(cmd *Command) ([]string, error) {
 	return nil, api.EncryptFile(*cmd.InFile, *cmd.OutFile, cmd.Conf)
@@ -158,6 +196,42 @@ func ExtractContent(cmd *Command) ([]string, error) {
 	return nil, api.ExtractContentFile(*cmd.InFile, *cmd.OutDir, cmd.PageSelection, cmd.Conf)
 }
 
+// ExtractText dumps the page text of inFile into outDir for selected pages.
+func ExtractText(cmd *Command) ([]string, error) {
+	return nil, api.ExtractTextFile(*cmd.InFile, *cmd.OutDir, cmd.PageSelection, cmd.BoolVal1, cmd.Conf)
+}
+
+// SearchText returns all matches of a regular expression within the text of selected pages of inFile.
+func SearchText(cmd *Command) ([]string, error) {
+	return SearchTextFile(*cmd.InFile, cmd.PageSelection, cmd.StringVal, cmd.BoolVal1, cmd.Conf)
+}
+
+// HighlightText highlights all matches of a regular expression within the text of selected pages of inFile
+// and writes the result to outFile.
+func HighlightText(cmd *Command) ([]string, error) {
+	return HighlightTextFile(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.StringVal, cmd.Conf)
+}
+
+// Redact removes all content of selected pages of inFile intersecting redaction areas and writes the result to outFile.
+func Redact(cmd *Command) ([]string, error) {
+	return RedactFile(*cmd.InFile, *cmd.OutFile, cmd.PageSelection, cmd.Rects, cmd.StringVal, cmd.BoolVal1, cmd.Conf)
+}
+
+// Sanitize removes active and hidden content from inFile and writes the result to outFile.
+func Sanitize(cmd *Command) ([]string, error) {
+	return SanitizeFile(*cmd.InFile, *cmd.OutFile, pdfcpu.SanitizeCategory(cmd.IntVal), cmd.BoolVal1, cmd.Conf)
+}
+
+// Render rasterizes selected pages of inFile into image files in outDir.
+func Render(cmd *Command) ([]string, error) {
+	return nil, api.RenderFile(*cmd.InFile, *cmd.OutDir, cmd.PageSelection, cmd.IntVal, cmd.StringVal, cmd.Conf)
+}
+
+// Export converts selected pages of inFile into files in outDir.
+func Export(cmd *Command) ([]string, error) {
+	return nil, api.ExportFile(*cmd.InFile, *cmd.OutDir, cmd.PageSelection, cmd.StringVal, cmd.Conf)
+}
+
 // ExtractMetadata dumps all metadata dict entries for inFile into outDir.
 func ExtractMetadata(cmd *Command) ([]string, error) {
 	return nil, api.ExtractMetadataFile(*cmd.InFile, *cmd.OutDir, cmd.Conf)
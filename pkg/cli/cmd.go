@@ -21,6 +21,7 @@ import (
 
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 )
 
 // Command represents an execution context.
@@ -47,6 +48,7 @@ type Command struct {
 	Inputs            []io.ReadSeeker
 	Output            io.Writer
 	Box               *model.Box
+	Rects             []types.Rectangle
 	Import            *pdfcpu.Import
 	NUp               *model.NUp
 	Cut               *model.Cut
@@ -55,6 +57,7 @@ type Command struct {
 	Zoom              *model.Zoom
 	Watermark         *model.Watermark
 	ViewerPreferences *model.ViewerPreferences
+	Signature         *model.Signature
 	PageConf          *pdfcpu.PageConfiguration
 	Conf              *model.Configuration
 }
@@ -62,6 +65,12 @@ type Command struct {
 var cmdMap = map[model.CommandMode]func(cmd *Command) ([]string, error){
 	model.VALIDATE:                Validate,
 	model.OPTIMIZE:                Optimize,
+	model.REPAIR:                  Repair,
+	model.SIGN:                    Sign,
+	model.LISTSIGNATURES:          processSignatures,
+	model.VERIFYSIGNATURES:        processSignatures,
+	model.ADDDSS:                  processSignatures,
+	model.TIMESTAMP:               Timestamp,
 	model.SPLIT:                   Split,
 	model.SPLITBYPAGENR:           SplitByPageNr,
 	model.MERGECREATE:             MergeCreate,
@@ -72,6 +81,13 @@ var cmdMap = map[model.CommandMode]func(cmd *Command) ([]string, error){
 	model.EXTRACTPAGES:            ExtractPages,
 	model.EXTRACTCONTENT:          ExtractContent,
 	model.EXTRACTMETADATA:         ExtractMetadata,
+	model.EXTRACTTEXT:             ExtractText,
+	model.SEARCHTEXT:              SearchText,
+	model.HIGHLIGHTTEXT:           HighlightText,
+	model.REDACT:                  Redact,
+	model.SANITIZE:                Sanitize,
+	model.RENDER:                  Render,
+	model.EXPORT:                  Export,
 	model.TRIM:                    Trim,
 	model.ADDWATERMARKS:           AddWatermarks,
 	model.REMOVEWATERMARKS:        RemoveWatermarks,
@@ -153,6 +169,13 @@ func ValidateCommand(inFiles []string, conf *model.Configuration) *Command {
 		Conf:    conf}
 }
 
+// ValidateCommandJSON creates a new command to validate files reporting the results as JSON.
+func ValidateCommandJSON(inFiles []string, conf *model.Configuration) *Command {
+	cmd := ValidateCommand(inFiles, conf)
+	cmd.BoolVal1 = true
+	return cmd
+}
+
 // OptimizeCommand creates a new command to optimize a file.
 func OptimizeCommand(inFile, outFile string, conf *model.Configuration) *Command {
 	if conf == nil {
@@ -166,6 +189,91 @@ func OptimizeCommand(inFile, outFile string, conf *model.Configuration) *Command
 		Conf:    conf}
 }
 
+// RepairCommand creates a new command to repair a file.
+func RepairCommand(inFile, outFile string, json bool, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.REPAIR
+	return &Command{
+		Mode:     model.REPAIR,
+		InFile:   &inFile,
+		OutFile:  &outFile,
+		BoolVal1: json,
+		Conf:     conf}
+}
+
+// SignCommand creates a new command to digitally sign a file using the PKCS#12 key store certFile.
+func SignCommand(inFile, outFile, certFile, certPW string, sig *model.Signature, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.SIGN
+	return &Command{
+		Mode:       model.SIGN,
+		InFile:     &inFile,
+		OutFile:    &outFile,
+		StringVal:  certFile,
+		StringVals: []string{certPW},
+		Signature:  sig,
+		Conf:       conf}
+}
+
+// TimestampCommand creates a new command to add a document timestamp to a file.
+func TimestampCommand(inFile, outFile string, sig *model.Signature, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.TIMESTAMP
+	return &Command{
+		Mode:      model.TIMESTAMP,
+		InFile:    &inFile,
+		OutFile:   &outFile,
+		Signature: sig,
+		Conf:      conf}
+}
+
+// ListSignaturesCommand creates a new command to list all signatures of a file.
+func ListSignaturesCommand(inFile string, json bool, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.LISTSIGNATURES
+	return &Command{
+		Mode:     model.LISTSIGNATURES,
+		InFile:   &inFile,
+		BoolVal1: json,
+		Conf:     conf}
+}
+
+// VerifySignaturesCommand creates a new command to verify all signatures of a file against the trust store trustDir.
+func VerifySignaturesCommand(inFile, trustDir string, json bool, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.VERIFYSIGNATURES
+	return &Command{
+		Mode:      model.VERIFYSIGNATURES,
+		InFile:    &inFile,
+		StringVal: trustDir,
+		BoolVal1:  json,
+		Conf:      conf}
+}
+
+// AddDSSCommand creates a new command to add certificates, CRLs and OCSP responses to the Document Security Store of a file.
+func AddDSSCommand(inFile, outFile string, files []string, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.ADDDSS
+	return &Command{
+		Mode:    model.ADDDSS,
+		InFile:  &inFile,
+		OutFile: &outFile,
+		InFiles: files,
+		Conf:    conf}
+}
+
 // SplitCommand creates a new command to split a file according to span or along bookmarks..
 func SplitCommand(inFile, dirNameOut string, span int, conf *model.Configuration) *Command {
 	if conf == nil {
@@ -296,6 +404,115 @@ func ExtractContentCommand(inFile string, outDir string, pageSelection []string,
 		Conf:          conf}
 }
 
+// ExtractTextCommand creates a new command to extract page text either as plain text or as JSON.
+func ExtractTextCommand(inFile string, outDir string, pageSelection []string, json bool, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.EXTRACTTEXT
+	return &Command{
+		Mode:          model.EXTRACTTEXT,
+		InFile:        &inFile,
+		OutDir:        &outDir,
+		PageSelection: pageSelection,
+		BoolVal1:      json,
+		Conf:          conf}
+}
+
+// SearchTextCommand creates a new command to search the text of selected pages for a regular expression.
+func SearchTextCommand(inFile, pattern string, pageSelection []string, json bool, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.SEARCHTEXT
+	return &Command{
+		Mode:          model.SEARCHTEXT,
+		InFile:        &inFile,
+		PageSelection: pageSelection,
+		StringVal:     pattern,
+		BoolVal1:      json,
+		Conf:          conf}
+}
+
+// HighlightTextCommand creates a new command to add highlight annotations for all matches of a regular expression.
+func HighlightTextCommand(inFile, outFile, pattern string, pageSelection []string, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.HIGHLIGHTTEXT
+	return &Command{
+		Mode:          model.HIGHLIGHTTEXT,
+		InFile:        &inFile,
+		OutFile:       &outFile,
+		PageSelection: pageSelection,
+		StringVal:     pattern,
+		Conf:          conf}
+}
+
+// RedactCommand creates a new command to redact selected pages by rectangles, matches of a regular expression and redact annotations.
+// If mark is true redact annotations are added instead.
+func RedactCommand(inFile, outFile string, pageSelection []string, rects []types.Rectangle, pattern string, mark bool, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.REDACT
+	return &Command{
+		Mode:          model.REDACT,
+		InFile:        &inFile,
+		OutFile:       &outFile,
+		PageSelection: pageSelection,
+		Rects:         rects,
+		StringVal:     pattern,
+		BoolVal1:      mark,
+		Conf:          conf}
+}
+
+// SanitizeCommand creates a new command to remove active and hidden content.
+func SanitizeCommand(inFile, outFile string, cats pdfcpu.SanitizeCategory, json bool, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.SANITIZE
+	return &Command{
+		Mode:     model.SANITIZE,
+		InFile:   &inFile,
+		OutFile:  &outFile,
+		IntVal:   int(cats),
+		BoolVal1: json,
+		Conf:     conf}
+}
+
+// RenderCommand creates a new command to render selected pages as images.
+func RenderCommand(inFile, outDir string, pageSelection []string, dpi int, format string, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.RENDER
+	return &Command{
+		Mode:          model.RENDER,
+		InFile:        &inFile,
+		OutDir:        &outDir,
+		PageSelection: pageSelection,
+		IntVal:        dpi,
+		StringVal:     format,
+		Conf:          conf}
+}
+
+// ExportCommand creates a new command to convert selected pages into another format.
+func ExportCommand(inFile, outDir string, pageSelection []string, format string, conf *model.Configuration) *Command {
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.Cmd = model.EXPORT
+	return &Command{
+		Mode:          model.EXPORT,
+		InFile:        &inFile,
+		OutDir:        &outDir,
+		PageSelection: pageSelection,
+		StringVal:     format,
+		Conf:          conf}
+}
+
 // ExtractMetadataCommand creates a new command to extract metadata streams.
 func ExtractMetadataCommand(inFile string, outDir string, conf *model.Configuration) *Command {
 	if conf == nil {
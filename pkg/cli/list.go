@@ -32,6 +32,8 @@ import (
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/form"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/sign"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/text"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 	"github.com/pkg/errors"
 )
@@ -394,6 +396,259 @@ func listInfoFilesJSON(inFiles []string, selectedPages []string, conf *model.Con
 	return []string{string(bb)}, nil
 }
 
+func repairReportJSON(inFile string, repairs []model.Repair) ([]string, error) {
+	if repairs == nil {
+		repairs = []model.Repair{}
+	}
+
+	s := struct {
+		Header  pdfcpu.Header  `json:"header"`
+		Repairs []model.Repair `json:"repairs"`
+	}{
+		Header:  pdfcpu.Header{Source: inFile, Version: "pdfcpu " + model.VersionStr, Creation: time.Now().Format("2006-01-02 15:04:05 MST")},
+		Repairs: repairs,
+	}
+
+	bb, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{string(bb)}, nil
+}
+
+// RepairFile repairs inFile, writes the result to outFile and returns a report of all applied fixes.
+func RepairFile(inFile, outFile string, json bool, conf *model.Configuration) ([]string, error) {
+	repairs, err := api.RepairFile(inFile, outFile, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if json {
+		return repairReportJSON(inFile, repairs)
+	}
+
+	if len(repairs) == 0 {
+		return []string{"no repairs necessary"}, nil
+	}
+
+	return []string{fmt.Sprintf("%d repairs applied", len(repairs))}, nil
+}
+
+func signaturesJSON(inFile string, sigs []sign.SignatureDetails) ([]string, error) {
+	if sigs == nil {
+		sigs = []sign.SignatureDetails{}
+	}
+
+	s := struct {
+		Header     pdfcpu.Header           `json:"header"`
+		Signatures []sign.SignatureDetails `json:"signatures"`
+	}{
+		Header:     pdfcpu.Header{Source: inFile, Version: "pdfcpu " + model.VersionStr, Creation: time.Now().Format("2006-01-02 15:04:05 MST")},
+		Signatures: sigs,
+	}
+
+	bb, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{string(bb)}, nil
+}
+
+// ListSignaturesFile returns a list of all signatures of inFile.
+func ListSignaturesFile(inFile string, json bool, conf *model.Configuration) ([]string, error) {
+	sigs, err := api.SignaturesFile(inFile, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if json {
+		return signaturesJSON(inFile, sigs)
+	}
+
+	return sign.ListSignatures(sigs), nil
+}
+
+// VerifySignaturesFile returns the verification results for all signatures of inFile.
+func VerifySignaturesFile(inFile, trustDir string, json bool, conf *model.Configuration) ([]string, error) {
+	sigs, err := api.VerifySignaturesFile(inFile, trustDir, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if json {
+		return signaturesJSON(inFile, sigs)
+	}
+
+	return sign.ListSignatures(sigs), nil
+}
+
+func matchesJSON(inFile string, mm []text.Match) ([]string, error) {
+	s := struct {
+		Header  pdfcpu.Header `json:"header"`
+		Matches []text.Match  `json:"matches"`
+	}{
+		Header:  pdfcpu.Header{Source: inFile, Version: "pdfcpu " + model.VersionStr, Creation: time.Now().Format("2006-01-02 15:04:05 MST")},
+		Matches: mm,
+	}
+
+	bb, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{string(bb)}, nil
+}
+
+// SearchTextFile returns all matches of the regular expression pattern within the text of selected pages of inFile.
+func SearchTextFile(inFile string, selectedPages []string, pattern string, json bool, conf *model.Configuration) ([]string, error) {
+	log.SetCLILogger(nil)
+
+	mm, err := api.SearchTextFile(inFile, selectedPages, pattern, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if json {
+		return matchesJSON(inFile, mm)
+	}
+
+	ss := []string{fmt.Sprintf("%d matches", len(mm))}
+	for _, m := range mm {
+		ss = append(ss, fmt.Sprintf("page %d %s: %s", m.Page, m.Rects[0].ShortString(), m.Text))
+	}
+
+	return ss, nil
+}
+
+// HighlightTextFile adds highlight annotations for all matches of the regular expression pattern
+// within the text of selected pages of inFile and writes the result to outFile.
+func HighlightTextFile(inFile, outFile string, selectedPages []string, pattern string, conf *model.Configuration) ([]string, error) {
+	mm, err := api.HighlightTextFile(inFile, outFile, selectedPages, pattern, nil, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{fmt.Sprintf("%d matches highlighted", len(mm))}, nil
+}
+
+// RedactFile removes all content of selected pages of inFile intersecting redaction areas and writes the result to outFile.
+// If mark is true redact annotations are added instead.
+func RedactFile(inFile, outFile string, selectedPages []string, rects []types.Rectangle, pattern string, mark bool, conf *model.Configuration) ([]string, error) {
+	if mark {
+		mm, err := api.MarkRedactionsFile(inFile, outFile, selectedPages, rects, pattern, nil, conf)
+		if err != nil || pattern == "" {
+			return nil, err
+		}
+		return []string{fmt.Sprintf("%d matches marked for redaction", len(mm))}, nil
+	}
+
+	mm, err := api.RedactFile(inFile, outFile, selectedPages, rects, pattern, nil, conf)
+	if err != nil || pattern == "" {
+		return nil, err
+	}
+
+	return []string{fmt.Sprintf("%d matches redacted", len(mm))}, nil
+}
+
+// SanitizeFile removes active and hidden content from inFile, writes the result to outFile
+// and returns a report of what was removed.
+func SanitizeFile(inFile, outFile string, cats pdfcpu.SanitizeCategory, json bool, conf *model.Configuration) ([]string, error) {
+	if json {
+		log.SetCLILogger(nil)
+	}
+
+	items, err := api.SanitizeFile(inFile, outFile, cats, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if json {
+		return sanitizeJSON(inFile, items)
+	}
+
+	if len(items) == 0 {
+		return []string{"nothing removed"}, nil
+	}
+
+	ss := []string{fmt.Sprintf("%d items removed:", len(items))}
+	for _, item := range items {
+		ss = append(ss, fmt.Sprintf("%-12s %-12s %s", item.Category, item.Location, item.Detail))
+	}
+
+	return ss, nil
+}
+
+func sanitizeJSON(inFile string, items []pdfcpu.SanitizeItem) ([]string, error) {
+	s := struct {
+		Header  pdfcpu.Header         `json:"header"`
+		Removed []pdfcpu.SanitizeItem `json:"removed"`
+	}{
+		Header:  pdfcpu.Header{Source: inFile, Version: "pdfcpu " + model.VersionStr, Creation: time.Now().Format("2006-01-02 15:04:05 MST")},
+		Removed: items,
+	}
+
+	if s.Removed == nil {
+		s.Removed = []pdfcpu.SanitizeItem{}
+	}
+
+	bb, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{string(bb)}, nil
+}
+
+func validationReport(inFile string, conf *model.Configuration) ([]model.Diagnostic, error) {
+	f, err := os.Open(inFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return api.ValidateReport(f, conf)
+}
+
+// ValidateFilesJSON validates inFiles and returns a JSON report listing all spec violations.
+// Files that cannot be read are reported with their error and do not stop processing.
+func ValidateFilesJSON(inFiles []string, conf *model.Configuration) ([]string, error) {
+	type report struct {
+		Source      string             `json:"source"`
+		Error       string             `json:"error,omitempty"`
+		Diagnostics []model.Diagnostic `json:"diagnostics"`
+	}
+
+	reports := []report{}
+
+	for _, fn := range inFiles {
+		r := report{Source: fn, Diagnostics: []model.Diagnostic{}}
+		diags, err := validationReport(fn, conf)
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.Diagnostics = diags
+		}
+		reports = append(reports, r)
+	}
+
+	s := struct {
+		Header  pdfcpu.Header `json:"header"`
+		Reports []report      `json:"reports"`
+	}{
+		Header:  pdfcpu.Header{Version: "pdfcpu " + model.VersionStr, Creation: time.Now().Format("2006-01-02 15:04:05 MST")},
+		Reports: reports,
+	}
+
+	bb, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{string(bb)}, nil
+}
+
 // ListInfoFiles returns formatted information about inFiles.
 func ListInfoFiles(inFiles []string, selectedPages []string, json bool, conf *model.Configuration) ([]string, error) {
 
@@ -275,3 +275,19 @@ func processViewerPreferences(cmd *Command) (out []string, err error) {
 
 	return nil, nil
 }
+
+func processSignatures(cmd *Command) (out []string, err error) {
+	switch cmd.Mode {
+
+	case model.LISTSIGNATURES:
+		out, err = ListSignatures(cmd)
+
+	case model.VERIFYSIGNATURES:
+		out, err = VerifySignatures(cmd)
+
+	case model.ADDDSS:
+		out, err = AddDSS(cmd)
+	}
+
+	return out, err
+}
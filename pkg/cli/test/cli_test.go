@@ -16,6 +16,7 @@ limitations under the License.
 package test
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -149,6 +150,44 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidateCommandJSON(t *testing.T) {
+	msg := "TestValidateCommandJSON"
+	inFiles := []string{
+		filepath.Join(inDir, "go.pdf"),
+		filepath.Join(inDir, "5116.DCT_Filter.pdf"),
+		filepath.Join(inDir, "missing.pdf"),
+	}
+
+	out, err := cli.Process(cli.ValidateCommandJSON(inFiles, conf))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	var report struct {
+		Reports []struct {
+			Source      string             `json:"source"`
+			Error       string             `json:"error"`
+			Diagnostics []model.Diagnostic `json:"diagnostics"`
+		} `json:"reports"`
+	}
+	if err := json.Unmarshal([]byte(out[0]), &report); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	if len(report.Reports) != 3 {
+		t.Fatalf("%s: want 3 reports, got %d\n", msg, len(report.Reports))
+	}
+	if r := report.Reports[0]; r.Error != "" || len(r.Diagnostics) > 0 {
+		t.Fatalf("%s: %s: unexpected report: %v\n", msg, r.Source, r)
+	}
+	if r := report.Reports[1]; r.Error != "" || len(r.Diagnostics) == 0 {
+		t.Fatalf("%s: %s: missing violations\n", msg, r.Source)
+	}
+	if r := report.Reports[2]; r.Error == "" {
+		t.Fatalf("%s: %s: missing error\n", msg, r.Source)
+	}
+}
+
 func TestInfoCommand(t *testing.T) {
 	msg := "TestInfoCommand"
 	inFile := filepath.Join(inDir, "5116.DCT_Filter.pdf")
@@ -96,3 +96,31 @@ func TestExtractMetadataCommand(t *testing.T) {
 		t.Fatalf("%s %s: %v\n", msg, inFile, err)
 	}
 }
+
+func TestExtractTextCommand(t *testing.T) {
+	msg := "TestExtractTextCommand"
+	// Extract the text of page 1 as JSON into outDir.
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+	cmd := cli.ExtractTextCommand(inFile, outDir, []string{"1"}, true, conf)
+	if _, err := cli.Process(cmd); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+}
+
+func TestSearchTextCommand(t *testing.T) {
+	msg := "TestSearchTextCommand"
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+
+	// List all matches as JSON.
+	cmd := cli.SearchTextCommand(inFile, `(?i)go toolchain`, nil, true, conf)
+	if _, err := cli.Process(cmd); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	// Highlight all matches.
+	outFile := filepath.Join(outDir, "highlight.pdf")
+	cmd = cli.HighlightTextCommand(inFile, outFile, `(?i)go toolchain`, nil, conf)
+	if _, err := cli.Process(cmd); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/cli"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestRedactCommand(t *testing.T) {
+	msg := "TestRedactCommand"
+	inFile := filepath.Join(inDir, "TheGoProgrammingLanguageCh1.pdf")
+	outFile := filepath.Join(outDir, "redact.pdf")
+
+	// Mark matches for redaction.
+	cmd := cli.RedactCommand(inFile, outFile, []string{"21-22"}, nil, `(?i)go toolchain`, true, conf)
+	if _, err := cli.Process(cmd); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	// Apply the redact annotations along with a rectangle.
+	rects := []types.Rectangle{*types.NewRectangle(50, 50, 200, 100)}
+	cmd = cli.RedactCommand(outFile, "", []string{"21-22"}, rects, "", false, conf)
+	if _, err := cli.Process(cmd); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, outFile, err)
+	}
+
+	if err := validateFile(t, outFile, conf); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+}
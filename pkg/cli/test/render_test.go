@@ -0,0 +1,53 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/cli"
+)
+
+func TestRenderCommand(t *testing.T) {
+	msg := "TestRenderCommand"
+	inFile := filepath.Join(inDir, "testWithText.pdf")
+
+	cmd := cli.RenderCommand(inFile, outDir, []string{"1"}, 50, "png", conf)
+	if _, err := cli.Process(cmd); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outDir, "testWithText_page_1.png")); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+}
+
+func TestExportCommand(t *testing.T) {
+	msg := "TestExportCommand"
+	inFile := filepath.Join(inDir, "testWithText.pdf")
+
+	cmd := cli.ExportCommand(inFile, outDir, nil, "svg", conf)
+	if _, err := cli.Process(cmd); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outDir, "testWithText_page_1.svg")); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+}
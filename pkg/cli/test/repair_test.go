@@ -0,0 +1,64 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/cli"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func TestRepairCommand(t *testing.T) {
+	msg := "TestRepairCommand"
+
+	bb, err := os.ReadFile(filepath.Join(inDir, "go.pdf"))
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// Truncate the file right after the last object.
+	inFile := filepath.Join(outDir, "truncated.pdf")
+	if err := os.WriteFile(inFile, bb[:len(bb)-100], 0644); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	outFile := filepath.Join(outDir, "repaired.pdf")
+
+	cmd := cli.RepairCommand(inFile, outFile, true, conf)
+	out, err := cli.Process(cmd)
+	if err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	var report struct {
+		Repairs []model.Repair `json:"repairs"`
+	}
+	if err := json.Unmarshal([]byte(out[0]), &report); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+	if len(report.Repairs) == 0 || report.Repairs[0].Category != model.RepairXRef {
+		t.Fatalf("%s: unexpected report: %v\n", msg, report.Repairs)
+	}
+
+	cmd = cli.ValidateCommand([]string{outFile}, conf)
+	if _, err := cli.Process(cmd); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/cli"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+)
+
+func TestSanitizeCommand(t *testing.T) {
+	msg := "TestSanitizeCommand"
+	inFile := filepath.Join(inDir, "Acroforms2.pdf")
+	outFile := filepath.Join(outDir, "sanitize.pdf")
+
+	cmd := cli.SanitizeCommand(inFile, outFile, pdfcpu.SanitizeAll, false, conf)
+	if _, err := cli.Process(cmd); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, inFile, err)
+	}
+
+	if err := validateFile(t, outFile, conf); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+
+	// Sanitize in place and report as JSON.
+	cmd = cli.SanitizeCommand(outFile, "", pdfcpu.SanitizeJavaScript|pdfcpu.SanitizeActions, true, conf)
+	if _, err := cli.Process(cmd); err != nil {
+		t.Fatalf("%s %s: %v\n", msg, outFile, err)
+	}
+
+	if err := validateFile(t, outFile, conf); err != nil {
+		t.Fatalf("%s: %v\n", msg, err)
+	}
+}
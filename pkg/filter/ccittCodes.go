@@ -0,0 +1,283 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// CCITT facsimile code tables, see ITU-T T.4 4.1.2 and 4.2.1.3.
+
+var (
+	errCCITTEOD     = errors.New("pdfcpu: ccitt: unexpected end of data")
+	errCCITTInvalid = errors.New("pdfcpu: ccitt: invalid code")
+)
+
+// Two-dimensional coding modes (Table 4/T.4).
+const (
+	ccittPass = iota
+	ccittHorizontal
+	ccittV0
+	ccittVR1
+	ccittVR2
+	ccittVR3
+	ccittVL1
+	ccittVL2
+	ccittVL3
+	ccittExtension
+)
+
+var ccittModeCodes = [...]string{
+	ccittPass:       "0001",
+	ccittHorizontal: "001",
+	ccittV0:         "1",
+	ccittVR1:        "011",
+	ccittVR2:        "000011",
+	ccittVR3:        "0000011",
+	ccittVL1:        "010",
+	ccittVL2:        "000010",
+	ccittVL3:        "0000010",
+	ccittExtension:  "0000001",
+}
+
+// ccittVertical maps vertical modes to the offset of a1 relative to b1.
+var ccittVertical = [...]int{
+	ccittV0:  0,
+	ccittVR1: 1,
+	ccittVR2: 2,
+	ccittVR3: 3,
+	ccittVL1: -1,
+	ccittVL2: -2,
+	ccittVL3: -3,
+}
+
+// ccittVerticalModes maps offsets -3..3 of a1 relative to b1 to vertical modes.
+var ccittVerticalModes = [...]int{ccittVL3, ccittVL2, ccittVL1, ccittV0, ccittVR1, ccittVR2, ccittVR3}
+
+const ccittEOL = "000000000001"
+
+// ccittWhiteTerminating holds the white terminating codes for run lengths 0-63.
+var ccittWhiteTerminating = [64]string{
+	"00110101", "000111", "0111", "1000", "1011", "1100", "1110", "1111",
+	"10011", "10100", "00111", "01000", "001000", "000011", "110100", "110101",
+	"101010", "101011", "0100111", "0001100", "0001000", "0010111", "0000011", "0000100",
+	"0101000", "0101011", "0010011", "0100100", "0011000", "00000010", "00000011", "00011010",
+	"00011011", "00010010", "00010011", "00010100", "00010101", "00010110", "00010111", "00101000",
+	"00101001", "00101010", "00101011", "00101100", "00101101", "00000100", "00000101", "00001010",
+	"00001011", "01010010", "01010011", "01010100", "01010101", "00100100", "00100101", "01011000",
+	"01011001", "01011010", "01011011", "01001010", "01001011", "00110010", "00110011", "00110100",
+}
+
+// ccittWhiteMakeUp holds the white make-up codes for run lengths 64-1728.
+var ccittWhiteMakeUp = [27]string{
+	"11011", "10010", "010111", "0110111", "00110110", "00110111", "01100100", "01100101",
+	"01101000", "01100111", "011001100", "011001101", "011010010", "011010011", "011010100", "011010101",
+	"011010110", "011010111", "011011000", "011011001", "011011010", "011011011", "010011000", "010011001",
+	"010011010", "011000", "010011011",
+}
+
+// ccittBlackTerminating holds the black terminating codes for run lengths 0-63.
+var ccittBlackTerminating = [64]string{
+	"0000110111", "010", "11", "10", "011", "0011", "0010", "00011",
+	"000101", "000100", "0000100", "0000101", "0000111", "00000100", "00000111", "000011000",
+	"0000010111", "0000011000", "0000001000", "00001100111", "00001101000", "00001101100", "00000110111", "00000101000",
+	"00000010111", "00000011000", "000011001010", "000011001011", "000011001100", "000011001101", "000001101000", "000001101001",
+	"000001101010", "000001101011", "000011010010", "000011010011", "000011010100", "000011010101", "000011010110", "000011010111",
+	"000001101100", "000001101101", "000011011010", "000011011011", "000001010100", "000001010101", "000001010110", "000001010111",
+	"000001100100", "000001100101", "000001010010", "000001010011", "000000100100", "000000110111", "000000111000", "000000100111",
+	"000000101000", "000001011000", "000001011001", "000000101011", "000000101100", "000001011010", "000001100110", "000001100111",
+}
+
+// ccittBlackMakeUp holds the black make-up codes for run lengths 64-1728.
+var ccittBlackMakeUp = [27]string{
+	"0000001111", "000011001000", "000011001001", "000001011011", "000000110011", "000000110100", "000000110101", "0000001101100",
+	"0000001101101", "0000001001010", "0000001001011", "0000001001100", "0000001001101", "0000001110010", "0000001110011", "0000001110100",
+	"0000001110101", "0000001110110", "0000001110111", "0000001010010", "0000001010011", "0000001010100", "0000001010101", "0000001011010",
+	"0000001011011", "0000001100100", "0000001100101",
+}
+
+// ccittExtMakeUp holds the make-up codes for run lengths 1792-2560 shared by both colours.
+var ccittExtMakeUp = [13]string{
+	"00000001000", "00000001100", "00000001101", "000000010010", "000000010011", "000000010100", "000000010101", "000000010110",
+	"000000010111", "000000011100", "000000011101", "000000011110", "000000011111",
+}
+
+// ccittTable maps codes to values.
+// Keys combine code length and code bits.
+type ccittTable map[int]int
+
+func ccittKey(n, bits int) int {
+	return n<<16 | bits
+}
+
+func (t ccittTable) add(code string, v int) {
+	bits := 0
+	for _, c := range code {
+		bits = bits<<1 | int(c-'0')
+	}
+	t[ccittKey(len(code), bits)] = v
+}
+
+func newCCITTRunTable(terminating [64]string, makeUp [27]string) ccittTable {
+	t := ccittTable{}
+	for i, code := range terminating {
+		t.add(code, i)
+	}
+	for i, code := range makeUp {
+		t.add(code, (i+1)*64)
+	}
+	for i, code := range ccittExtMakeUp {
+		t.add(code, 1792+i*64)
+	}
+	return t
+}
+
+func newCCITTModeTable() ccittTable {
+	t := ccittTable{}
+	for mode, code := range ccittModeCodes {
+		t.add(code, mode)
+	}
+	return t
+}
+
+var (
+	ccittWhiteTable = newCCITTRunTable(ccittWhiteTerminating, ccittWhiteMakeUp)
+	ccittBlackTable = newCCITTRunTable(ccittBlackTerminating, ccittBlackMakeUp)
+	ccittModeTable  = newCCITTModeTable()
+)
+
+// ccittBitReader reads bits most significant bit first.
+type ccittBitReader struct {
+	data []byte
+	pos  int // bit position
+}
+
+func (r *ccittBitReader) readBit() (int, error) {
+	if r.pos >= 8*len(r.data) {
+		return 0, errCCITTEOD
+	}
+	b := int(r.data[r.pos>>3]>>(7-r.pos&7)) & 1
+	r.pos++
+	return b, nil
+}
+
+// align skips to the next byte boundary.
+func (r *ccittBitReader) align() {
+	r.pos = (r.pos + 7) &^ 7
+}
+
+func (r *ccittBitReader) eod() bool {
+	return r.pos >= 8*len(r.data)
+}
+
+// eol consumes an EOL code including any preceding fill bits.
+// Trailing 0 bits are consumed as well.
+func (r *ccittBitReader) eol() bool {
+	i := r.pos
+	for i < 8*len(r.data) && r.data[i>>3]>>(7-i&7)&1 == 0 {
+		i++
+	}
+	if i == 8*len(r.data) {
+		r.pos = i
+		return false
+	}
+	if i-r.pos < 11 {
+		return false
+	}
+	r.pos = i + 1
+	return true
+}
+
+// decode reads the next code of table t.
+func (r *ccittBitReader) decode(t ccittTable) (int, error) {
+	bits := 0
+	for n := 1; n <= 13; n++ {
+		b, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		bits = bits<<1 | b
+		if v, ok := t[ccittKey(n, bits)]; ok {
+			return v, nil
+		}
+	}
+	return 0, errCCITTInvalid
+}
+
+// readRun reads a run length made up of optional make-up codes and a terminating code.
+func (r *ccittBitReader) readRun(white bool) (int, error) {
+	t := ccittBlackTable
+	if white {
+		t = ccittWhiteTable
+	}
+	run := 0
+	for {
+		v, err := r.decode(t)
+		if err != nil {
+			return 0, err
+		}
+		run += v
+		if v < 64 {
+			return run, nil
+		}
+	}
+}
+
+// ccittBitWriter writes bits most significant bit first.
+type ccittBitWriter struct {
+	data []byte
+	n    int // number of bits written
+}
+
+func (w *ccittBitWriter) writeBit(b int) {
+	if w.n&7 == 0 {
+		w.data = append(w.data, 0)
+	}
+	if b == 1 {
+		w.data[len(w.data)-1] |= 0x80 >> (w.n & 7)
+	}
+	w.n++
+}
+
+func (w *ccittBitWriter) writeCode(code string) {
+	for _, c := range code {
+		w.writeBit(int(c - '0'))
+	}
+}
+
+// align pads with 0 bits up to the next byte boundary.
+func (w *ccittBitWriter) align() {
+	w.n = (w.n + 7) &^ 7
+}
+
+// writeRun writes a run length using make-up codes as needed followed by a terminating code.
+func (w *ccittBitWriter) writeRun(run int, white bool) {
+	terminating, makeUp := ccittBlackTerminating, ccittBlackMakeUp
+	if white {
+		terminating, makeUp = ccittWhiteTerminating, ccittWhiteMakeUp
+	}
+	for run >= 2560 {
+		w.writeCode(ccittExtMakeUp[len(ccittExtMakeUp)-1])
+		run -= 2560
+	}
+	if run >= 1792 {
+		w.writeCode(ccittExtMakeUp[(run-1792)/64])
+	} else if run >= 64 {
+		w.writeCode(makeUp[run/64-1])
+	}
+	w.writeCode(terminating[run%64])
+}
@@ -29,10 +29,49 @@ type ccittDecode struct {
 	baseFilter
 }
 
+// ccittParms returns the filter parameters.
+func (f ccittDecode) ccittParms() ccittParms {
+	// <0 : Pure two-dimensional encoding (Group 4)
+	// =0 : Pure one-dimensional encoding (Group 3, 1-D)
+	// >0 : Mixed one- and two-dimensional encoding (Group 3, 2-D)
+	p := ccittParms{k: f.parms["K"], cols: 1728, rows: f.parms["Rows"], eob: true}
+
+	if col, ok := f.parms["Columns"]; ok {
+		p.cols = col
+	}
+
+	p.blackIs1 = f.parms["BlackIs1"] == 1
+	p.byteAlign = f.parms["EncodedByteAlign"] == 1
+	p.eol = f.parms["EndOfLine"] == 1
+
+	if v, ok := f.parms["EndOfBlock"]; ok {
+		p.eob = v == 1
+	}
+
+	return p
+}
+
 // Encode implements encoding for a CCITTDecode filter.
 func (f ccittDecode) Encode(r io.Reader) (io.Reader, error) {
-	// TODO
-	return nil, nil
+	if log.TraceEnabled() {
+		log.Trace.Println("EncodeCCITT begin")
+	}
+
+	bb, err := getReaderBytes(r)
+	if err != nil {
+		return nil, err
+	}
+
+	bb, err = encodeCCITT(bb, f.ccittParms())
+	if err != nil {
+		return nil, err
+	}
+
+	if log.TraceEnabled() {
+		log.Trace.Printf("EncodeCCITT end: %d bytes\n", len(bb))
+	}
+
+	return bytes.NewBuffer(bb), nil
 }
 
 // Decode implements decoding for a CCITTDecode filter.
@@ -45,47 +84,34 @@ func (f ccittDecode) DecodeLength(r io.Reader, maxLen int64) (io.Reader, error)
 		log.Trace.Println("DecodeCCITT begin")
 	}
 
-	var ok bool
+	p := f.ccittParms()
 
-	// <0 : Pure two-dimensional encoding (Group 4)
-	// =0 : Pure one-dimensional encoding (Group 3, 1-D)
-	// >0 : Mixed one- and two-dimensional encoding (Group 3, 2-D)
-	k := 0
-	k, ok = f.parms["K"]
-	if ok && k > 0 {
-		return nil, errors.New("pdfcpu: filter CCITTFax k > 0 currently unsupported")
+	if _, ok := f.parms["Rows"]; !ok {
+		return nil, errors.New("pdfcpu: ccitt: missing DecodeParam \"Rows\"")
 	}
 
-	cols := 1728
-	col, ok := f.parms["Columns"]
-	if ok {
-		cols = col
-	}
+	if p.k >= 0 {
+		// x/image/ccitt only supports Group 3 1-D data with EOL codes.
+		bb, err := getReaderBytes(r)
+		if err != nil {
+			return nil, err
+		}
 
-	rows, ok := f.parms["Rows"]
-	if !ok {
-		return nil, errors.New("pdfcpu: ccitt: missing DecodeParam \"Rows\"")
-	}
+		bb, err = decodeCCITT(bb, p, maxLen)
+		if err != nil {
+			return nil, err
+		}
 
-	blackIs1 := false
-	v, ok := f.parms["BlackIs1"]
-	if ok && v == 1 {
-		blackIs1 = true
-	}
+		if log.TraceEnabled() {
+			log.Trace.Printf("DecodeCCITT: decoded %d bytes.\n", len(bb))
+		}
 
-	encodedByteAlign := false
-	v, ok = f.parms["EncodedByteAlign"]
-	if ok && v == 1 {
-		encodedByteAlign = true
+		return bytes.NewBuffer(bb), nil
 	}
 
-	opts := &ccitt.Options{Invert: blackIs1, Align: encodedByteAlign}
+	opts := &ccitt.Options{Invert: p.blackIs1, Align: p.byteAlign}
 
-	mode := ccitt.Group3
-	if k < 0 {
-		mode = ccitt.Group4
-	}
-	rd := ccitt.NewReader(r, ccitt.MSB, mode, cols, rows, opts)
+	rd := ccitt.NewReader(r, ccitt.MSB, ccitt.Group4, p.cols, p.rows, opts)
 
 	var b bytes.Buffer
 	written, err := io.Copy(&b, rd)
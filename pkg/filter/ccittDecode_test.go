@@ -0,0 +1,162 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/ccitt"
+)
+
+// testBilevel returns rows of packed pixels showing a few shapes and some noise, 0 bits are black.
+func testBilevel(w, h int) []byte {
+	rnd := rand.New(rand.NewSource(int64(w * h)))
+	rowBytes := (w + 7) / 8
+	bb := bytes.Repeat([]byte{0xFF}, rowBytes*h)
+	black := func(x, y int) {
+		bb[y*rowBytes+x>>3] &^= 0x80 >> (x & 7)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := x-w/2, y-h/2
+			switch {
+			case dx*dx+dy*dy < h*h/9 && dx*dx+dy*dy > h*h/16:
+				black(x, y)
+			case x > w-w/8 && y%7 < 3:
+				black(x, y)
+			case rnd.Intn(50) == 0:
+				black(x, y)
+			}
+		}
+	}
+	return bb
+}
+
+// equalRows compares rows of packed pixels ignoring the padding bits.
+func equalRows(a, b []byte, w int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	rowBytes := (w + 7) / 8
+	mask := byte(0xFF << uint(rowBytes*8-w))
+	for i := range a {
+		if d := a[i] ^ b[i]; i%rowBytes == rowBytes-1 && d&mask != 0 || i%rowBytes < rowBytes-1 && d != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCCITTEncodeDecode(t *testing.T) {
+	for _, tt := range []struct {
+		w, h  int
+		parms map[string]int
+	}{
+		{203, 97, map[string]int{"K": -1}},
+		{203, 97, map[string]int{"K": -1, "EncodedByteAlign": 1}},
+		{3000, 20, map[string]int{"K": -1}},
+		{203, 97, map[string]int{"K": 0}},
+		{203, 97, map[string]int{"K": 0, "EndOfLine": 1, "EncodedByteAlign": 1}},
+		{203, 97, map[string]int{"K": 2}},
+		{203, 97, map[string]int{"K": 4, "EndOfLine": 1}},
+		{203, 97, map[string]int{"K": 4, "EncodedByteAlign": 1}},
+		{203, 97, map[string]int{"K": 4, "EndOfLine": 1, "EncodedByteAlign": 1, "EndOfBlock": 0}},
+		{3000, 20, map[string]int{"K": 3, "EndOfLine": 1}},
+		{64, 64, map[string]int{"K": 2, "BlackIs1": 1}},
+	} {
+		name := fmt.Sprintf("%dx%d %v", tt.w, tt.h, tt.parms)
+
+		want := testBilevel(tt.w, tt.h)
+
+		tt.parms["Columns"], tt.parms["Rows"] = tt.w, tt.h
+
+		f, err := NewFilter(CCITTFax, tt.parms)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		r, err := f.Encode(bytes.NewReader(want))
+		if err != nil {
+			t.Fatalf("%s: encode: %v", name, err)
+		}
+		enc, _ := io.ReadAll(r)
+
+		r, err = f.Decode(bytes.NewReader(enc))
+		if err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		got, _ := io.ReadAll(r)
+
+		if !equalRows(got, want, tt.w) {
+			t.Errorf("%s: decoded rows differ", name)
+		}
+	}
+}
+
+// TestCCITTEncodeXImage checks encoded data using golang.org/x/image/ccitt.
+func TestCCITTEncodeXImage(t *testing.T) {
+	w, h := 203, 97
+	want := testBilevel(w, h)
+
+	for _, tt := range []struct {
+		parms map[string]int
+		sf    ccitt.SubFormat
+	}{
+		{map[string]int{"K": -1}, ccitt.Group4},
+		{map[string]int{"K": 0, "EndOfLine": 1}, ccitt.Group3},
+	} {
+		p := ccittDecode{baseFilter{parms: tt.parms}}.ccittParms()
+		p.cols, p.rows = w, h
+
+		enc, err := encodeCCITT(want, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := io.ReadAll(ccitt.NewReader(bytes.NewReader(enc), ccitt.MSB, tt.sf, w, h, nil))
+		if err != nil {
+			t.Fatalf("%v: %v", tt.parms, err)
+		}
+
+		if !equalRows(got, want, w) {
+			t.Errorf("%v: decoded rows differ", tt.parms)
+		}
+	}
+}
+
+func TestCCITTDecodeTruncated(t *testing.T) {
+	w, h := 203, 97
+	p := ccittParms{k: 2, cols: w, rows: h}
+
+	enc, err := encodeCCITT(testBilevel(w, h), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := decodeCCITT(enc[:len(enc)/2], p, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != h*p.rowBytes() {
+		t.Errorf("got %d bytes, want %d", len(got), h*p.rowBytes())
+	}
+}
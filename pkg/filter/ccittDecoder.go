@@ -0,0 +1,218 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// CCITT facsimile decoding, see ITU-T T.4 and T.6.
+//
+// Rows are represented by their changing elements,
+// the positions of pixels whose colour differs from the colour of the preceding pixel.
+// Each row starts white and is terminated by 2 changing elements at cols.
+
+const maxCCITTBytes = 1 << 28
+
+// ccittParms holds the CCITTFaxDecode filter parameters.
+type ccittParms struct {
+	k         int
+	cols      int
+	rows      int
+	byteAlign bool // EncodedByteAlign
+	eol       bool // EndOfLine
+	eob       bool // EndOfBlock
+	blackIs1  bool
+}
+
+func (p ccittParms) rowBytes() int {
+	return (p.cols + 7) / 8
+}
+
+// decode1D decodes a row using one-dimensional coding (T.4 4.1).
+func (r *ccittBitReader) decode1D(cols int) ([]int, error) {
+	var cur []int
+	a0, white := 0, true
+	for a0 < cols {
+		run, err := r.readRun(white)
+		if err != nil {
+			return nil, err
+		}
+		a0 += run
+		if a0 > cols {
+			return nil, errCCITTInvalid
+		}
+		cur = append(cur, a0)
+		white = !white
+	}
+	return append(cur, cols, cols), nil
+}
+
+// b1b2 returns the changing elements b1 and b2 of the reference line ref (T.4 4.2.1.3.1).
+// i is the index of the previous b1.
+func b1b2(ref []int, i, a0 int, white bool) (int, int, int) {
+	for i > 0 && ref[i-1] > a0 {
+		i--
+	}
+	for ref[i] <= a0 || (i&1 == 0) != white {
+		i++
+	}
+	b2 := ref[len(ref)-1]
+	if i+1 < len(ref) {
+		b2 = ref[i+1]
+	}
+	return i, ref[i], b2
+}
+
+// decode2D decodes a row using two-dimensional coding with reference line ref (T.4 4.2).
+func (r *ccittBitReader) decode2D(ref []int, cols int) ([]int, error) {
+	cur := make([]int, 0, len(ref))
+	a0, white, i := -1, true, 0
+
+	for a0 < cols {
+		var b1, b2 int
+		i, b1, b2 = b1b2(ref, i, a0, white)
+
+		mode, err := r.decode(ccittModeTable)
+		if err != nil {
+			return nil, err
+		}
+
+		switch mode {
+
+		case ccittPass:
+			a0 = b2
+
+		case ccittHorizontal:
+			r1, err := r.readRun(white)
+			if err != nil {
+				return nil, err
+			}
+			r2, err := r.readRun(!white)
+			if err != nil {
+				return nil, err
+			}
+			a1 := maxInt(a0, 0) + r1
+			a2 := a1 + r2
+			if a2 > cols {
+				return nil, errCCITTInvalid
+			}
+			cur = append(cur, a1, a2)
+			a0 = a2
+
+		case ccittExtension:
+			return nil, errors.New("pdfcpu: ccitt: extensions not supported")
+
+		default:
+			a1 := b1 + ccittVertical[mode]
+			if a1 < a0 || a1 < 0 || a1 > cols {
+				return nil, errCCITTInvalid
+			}
+			cur = append(cur, a1)
+			a0, white = a1, !white
+		}
+	}
+
+	return append(cur, cols, cols), nil
+}
+
+// appendCCITTRow appends the pixels of a row given by its changing elements.
+func appendCCITTRow(bb []byte, cur []int, cols int, blackIs1 bool) []byte {
+	n := len(bb)
+	var white byte
+	if !blackIs1 {
+		white = 0xFF
+	}
+	for i := 0; i < (cols+7)/8; i++ {
+		bb = append(bb, white)
+	}
+	row := bb[n:]
+	for i := 0; i+1 < len(cur); i += 2 {
+		for x := cur[i]; x < cur[i+1] && x < cols; x++ {
+			row[x>>3] ^= 0x80 >> (x & 7)
+		}
+	}
+	return bb
+}
+
+// decodeCCITT decodes CCITT encoded data into rows of packed pixels.
+// Decoding stops once at least maxLen bytes are available unless maxLen < 0.
+func decodeCCITT(data []byte, p ccittParms, maxLen int64) ([]byte, error) {
+	if p.cols <= 0 || p.cols > maxCCITTBytes || p.rows > maxCCITTBytes/p.rowBytes() {
+		return nil, errors.New("pdfcpu: ccitt: invalid image size")
+	}
+
+	r := &ccittBitReader{data: data}
+	ref := []int{p.cols, p.cols}
+
+	var bb []byte
+
+	for row := 0; p.rows <= 0 || row < p.rows; row++ {
+
+		if maxLen >= 0 && int64(len(bb)) >= maxLen {
+			break
+		}
+
+		if p.byteAlign && (p.k < 0 || !p.eol) {
+			r.align()
+		}
+
+		eol := r.eol()
+
+		twoD := p.k < 0
+		if p.k > 0 {
+			// Tag bit: 1 for one-dimensional coding, 0 for two-dimensional coding.
+			b, err := r.readBit()
+			if err != nil {
+				break
+			}
+			twoD = b == 0
+		}
+
+		// End of facsimile block (EOFB) or return to control (RTC).
+		if eol && (p.k < 0 || r.eol()) || r.eod() {
+			break
+		}
+
+		var (
+			cur []int
+			err error
+		)
+		if twoD {
+			cur, err = r.decode2D(ref, p.cols)
+		} else {
+			cur, err = r.decode1D(p.cols)
+		}
+		if err == errCCITTEOD {
+			// Truncated data, decode what is available.
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		bb = appendCCITTRow(bb, cur, p.cols, p.blackIs1)
+		ref = cur
+	}
+
+	// Fill missing rows with white.
+	for len(bb) < p.rows*p.rowBytes() {
+		bb = appendCCITTRow(bb, nil, p.cols, p.blackIs1)
+	}
+
+	return bb, nil
+}
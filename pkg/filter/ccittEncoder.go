@@ -0,0 +1,172 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// CCITT facsimile encoding, see ITU-T T.4 and T.6.
+
+// ccittChanges returns the changing elements of a row of packed pixels.
+func ccittChanges(row []byte, cols int, blackIs1 bool) []int {
+	var cur []int
+
+	var whiteByte byte
+	if !blackIs1 {
+		whiteByte = 0xFF
+	}
+
+	white := true
+	for x := 0; x < cols; x++ {
+		b := row[x>>3]
+		if x&7 == 0 && x+8 <= cols && (white && b == whiteByte || !white && b == ^whiteByte) {
+			x += 7
+			continue
+		}
+		black := (b>>(7-x&7)&1 == 1) == blackIs1
+		if black == white {
+			cur = append(cur, x)
+			white = !white
+		}
+	}
+
+	return append(cur, cols, cols)
+}
+
+// encode1D encodes a row using one-dimensional coding (T.4 4.1).
+func (w *ccittBitWriter) encode1D(cur []int, cols int) {
+	a0, white := 0, true
+	for _, a1 := range cur {
+		w.writeRun(a1-a0, white)
+		if a1 >= cols {
+			break
+		}
+		a0, white = a1, !white
+	}
+}
+
+// encode2D encodes a row using two-dimensional coding with reference line ref (T.4 4.2).
+func (w *ccittBitWriter) encode2D(cur, ref []int, cols int) {
+	a0, white := -1, true
+	i, j := 0, 0
+
+	for a0 < cols {
+		for cur[j] <= a0 {
+			j++
+		}
+		a1, a2 := cur[j], cols
+		if j+1 < len(cur) {
+			a2 = cur[j+1]
+		}
+
+		var b1, b2 int
+		i, b1, b2 = b1b2(ref, i, a0, white)
+
+		if b2 < a1 {
+			w.writeCode(ccittModeCodes[ccittPass])
+			a0 = b2
+			continue
+		}
+
+		if d := a1 - b1; d >= -3 && d <= 3 {
+			w.writeCode(ccittModeCodes[ccittVerticalModes[d+3]])
+			a0, white = a1, !white
+			continue
+		}
+
+		w.writeCode(ccittModeCodes[ccittHorizontal])
+		w.writeRun(a1-maxInt(a0, 0), white)
+		w.writeRun(a2-a1, !white)
+		a0 = a2
+	}
+}
+
+// writeEOL writes an EOL code.
+// For byte aligned encoding the EOL code is preceded by fill bits so that it ends on a byte boundary.
+func (w *ccittBitWriter) writeEOL(byteAlign bool) {
+	for byteAlign && (w.n+len(ccittEOL))&7 != 0 {
+		w.writeBit(0)
+	}
+	w.writeCode(ccittEOL)
+}
+
+// encodeCCITT encodes rows of packed pixels.
+func encodeCCITT(data []byte, p ccittParms) ([]byte, error) {
+	if p.cols <= 0 {
+		return nil, errors.New("pdfcpu: ccitt: invalid Columns")
+	}
+
+	rows := p.rows
+	if rows <= 0 {
+		rows = len(data) / p.rowBytes()
+	}
+	if len(data) < rows*p.rowBytes() {
+		return nil, errors.New("pdfcpu: ccitt: insufficient image data")
+	}
+
+	w := &ccittBitWriter{}
+	ref := []int{p.cols, p.cols}
+
+	for row := 0; row < rows; row++ {
+		cur := ccittChanges(data[row*p.rowBytes():], p.cols, p.blackIs1)
+
+		if p.k >= 0 && p.eol {
+			w.writeEOL(p.byteAlign)
+		} else if p.byteAlign {
+			w.align()
+		}
+
+		twoD := p.k < 0 || p.k > 0 && row%p.k != 0
+		if p.k > 0 {
+			// Tag bit: 1 for one-dimensional coding, 0 for two-dimensional coding.
+			if twoD {
+				w.writeBit(0)
+			} else {
+				w.writeBit(1)
+			}
+		}
+
+		if twoD {
+			w.encode2D(cur, ref, p.cols)
+		} else {
+			w.encode1D(cur, p.cols)
+		}
+
+		ref = cur
+	}
+
+	if p.eob {
+		if p.k < 0 {
+			// End of facsimile block (T.6 2.4.1.1)
+			if p.byteAlign {
+				w.align()
+			}
+			w.writeCode(ccittEOL + ccittEOL)
+		} else {
+			// Return to control (T.4 4.1.4)
+			for i := 0; i < 6; i++ {
+				w.writeEOL(p.byteAlign && i == 0)
+				if p.k > 0 {
+					w.writeBit(1)
+				}
+			}
+		}
+	}
+
+	return w.data, nil
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filter
+
+import (
+	"io"
+)
+
+// crypt represents a Crypt filter.
+// Encryption and decryption are the responsibility of the security handler
+// which takes care of this filter before any decoding takes place, see 7.4.10 in the PDF spec.
+type crypt struct {
+	baseFilter
+}
+
+// Encode implements encoding for a Crypt filter.
+func (f crypt) Encode(r io.Reader) (io.Reader, error) {
+	return r, nil
+}
+
+// Decode implements decoding for a Crypt filter.
+func (f crypt) Decode(r io.Reader) (io.Reader, error) {
+	return r, nil
+}
+
+func (f crypt) DecodeLength(r io.Reader, maxLen int64) (io.Reader, error) {
+	return r, nil
+}
@@ -21,7 +21,6 @@ import (
 	"bytes"
 	"io"
 
-	"github.com/pdfcpu/pdfcpu/pkg/log"
 	"github.com/pkg/errors"
 )
 
@@ -36,6 +35,7 @@ const (
 	JBIG2     = "JBIG2Decode"
 	DCT       = "DCTDecode"
 	JPX       = "JPXDecode"
+	Crypt     = "Crypt"
 )
 
 // ErrUnsupportedFilter signals unsupported filter encountered.
@@ -53,6 +53,12 @@ type Filter interface {
 
 // NewFilter returns a filter for given filterName and an optional parameter dictionary.
 func NewFilter(filterName string, parms map[string]int) (filter Filter, err error) {
+	return NewFilterWithStreams(filterName, parms, nil)
+}
+
+// NewFilterWithStreams returns a filter for given filterName, an optional parameter dictionary
+// and the decoded content of stream valued parameters like JBIG2Globals.
+func NewFilterWithStreams(filterName string, parms map[string]int, streams map[string][]byte) (filter Filter, err error) {
 	switch filterName {
 
 	case ASCII85:
@@ -62,30 +68,28 @@ func NewFilter(filterName string, parms map[string]int) (filter Filter, err erro
 		filter = asciiHexDecode{baseFilter{}}
 
 	case RunLength:
-		filter = runLengthDecode{baseFilter{parms}}
+		filter = runLengthDecode{baseFilter{parms: parms}}
 
 	case LZW:
-		filter = lzwDecode{baseFilter{parms}}
+		filter = lzwDecode{baseFilter{parms: parms}}
 
 	case Flate:
-		filter = flate{baseFilter{parms}}
+		filter = flate{baseFilter{parms: parms}}
 
 	case CCITTFax:
-		filter = ccittDecode{baseFilter{parms}}
+		filter = ccittDecode{baseFilter{parms: parms}}
 
 	case DCT:
-		filter = dctDecode{baseFilter{parms}}
+		filter = dctDecode{baseFilter{parms: parms}}
+
+	case Crypt:
+		filter = crypt{baseFilter{}}
 
 	case JBIG2:
-		// Unsupported
-		fallthrough
+		filter = jbig2Decode{baseFilter{parms: parms, streams: streams}}
 
 	case JPX:
-		// Unsupported
-		if log.InfoEnabled() {
-			log.Info.Printf("Filter not supported: <%s>", filterName)
-		}
-		err = ErrUnsupportedFilter
+		filter = jpxDecode{baseFilter{}}
 
 	default:
 		err = errors.Errorf("Invalid filter: <%s>", filterName)
@@ -101,7 +105,8 @@ func List() []string {
 }
 
 type baseFilter struct {
-	parms map[string]int
+	parms   map[string]int
+	streams map[string][]byte
 }
 
 func getReaderBytes(r io.Reader) ([]byte, error) {
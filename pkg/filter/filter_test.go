@@ -17,6 +17,7 @@ limitations under the License.
 package filter_test
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"os"
@@ -38,8 +39,9 @@ func TestFilterSupport(t *testing.T) {
 		{filter.Flate, nil},
 		{filter.CCITTFax, nil},
 		{filter.DCT, nil},
-		{filter.JBIG2, filter.ErrUnsupportedFilter},
-		{filter.JPX, filter.ErrUnsupportedFilter},
+		{filter.Crypt, nil},
+		{filter.JBIG2, nil},
+		{filter.JPX, nil},
 		{"INVALID_FILTER", errors.New("Invalid filter: <INVALID_FILTER>")},
 	}
 	for _, tt := range filtersTests {
@@ -271,3 +273,80 @@ func TestEncodeDecodeFilterPipeline(t *testing.T) {
 		encodeDecodeFilterPipeline(t, filename, []string{filter.ASCII85, filter.Flate})
 	}
 }
+
+// gradient returns rows of pixel bytes showing smooth color gradients.
+func gradient(colors, bpc, columns, rows int) []byte {
+	rowSize := (bpc*colors*columns + 7) / 8
+	bb := make([]byte, rowSize*rows)
+	for y := 0; y < rows; y++ {
+		for i := 0; i < rowSize; i++ {
+			bb[y*rowSize+i] = byte(3*i + 5*y + i*y/16)
+		}
+	}
+	return bb
+}
+
+func TestFlatePredictors(t *testing.T) {
+	for _, tt := range []struct {
+		colors, bpc, columns int
+	}{
+		{1, 8, 100},
+		{3, 8, 97},
+		{4, 8, 33},
+		{1, 16, 50},
+		{3, 16, 21},
+		{1, 1, 203},
+		{1, 4, 17},
+	} {
+		want := gradient(tt.colors, tt.bpc, tt.columns, 40)
+
+		var sizeNo int
+
+		for _, p := range []int{
+			filter.PredictorNo,
+			filter.PredictorTIFF,
+			filter.PredictorNone,
+			filter.PredictorSub,
+			filter.PredictorUp,
+			filter.PredictorAverage,
+			filter.PredictorPaeth,
+			filter.PredictorOptimum,
+		} {
+			if p == filter.PredictorTIFF && tt.bpc != 8 {
+				continue
+			}
+
+			parms := map[string]int{"Predictor": p, "Colors": tt.colors, "BitsPerComponent": tt.bpc, "Columns": tt.columns}
+
+			f, err := filter.NewFilter(filter.Flate, parms)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := f.Encode(bytes.NewReader(want))
+			if err != nil {
+				t.Fatalf("%v: encode: %v", parms, err)
+			}
+			enc, _ := io.ReadAll(r)
+
+			switch p {
+			case filter.PredictorNo:
+				sizeNo = len(enc)
+			case filter.PredictorOptimum:
+				if len(enc) >= sizeNo {
+					t.Errorf("%v: no size reduction: %d >= %d", parms, len(enc), sizeNo)
+				}
+			}
+
+			r, err = f.Decode(bytes.NewReader(enc))
+			if err != nil {
+				t.Fatalf("%v: decode: %v", parms, err)
+			}
+			got, _ := io.ReadAll(r)
+
+			if !bytes.Equal(got, want) {
+				t.Errorf("%v: decoded data differs", parms)
+			}
+		}
+	}
+}
@@ -61,7 +61,11 @@ func (f flate) Encode(r io.Reader) (io.Reader, error) {
 		log.Trace.Println("EncodeFlate begin")
 	}
 
-	// TODO Optional decode parameters may need predictor preprocessing.
+	// Optional decode parameters need predictor preprocessing.
+	r, err := f.encodePreProcess(r)
+	if err != nil {
+		return nil, err
+	}
 
 	var b bytes.Buffer
 	w := zlib.NewWriter(&b)
@@ -173,6 +177,19 @@ func intMemberOf(i int, list []int) bool {
 // 	return nil
 // }
 
+func validPredictor(p int) bool {
+	return intMemberOf(
+		p,
+		[]int{PredictorTIFF,
+			PredictorNone,
+			PredictorSub,
+			PredictorUp,
+			PredictorAverage,
+			PredictorPaeth,
+			PredictorOptimum,
+		})
+}
+
 func applyHorDiff(row []byte, colors int) ([]byte, error) {
 	// This works for 8 bits per color only.
 	for i := 1; i < len(row)/colors; i++ {
@@ -289,16 +306,7 @@ func (f flate) decodePostProcess(r io.Reader, maxLen int64) (io.Reader, error) {
 		return passThru(r, maxLen)
 	}
 
-	if !intMemberOf(
-		predictor,
-		[]int{PredictorTIFF,
-			PredictorNone,
-			PredictorSub,
-			PredictorUp,
-			PredictorAverage,
-			PredictorPaeth,
-			PredictorOptimum,
-		}) {
+	if !validPredictor(predictor) {
 		return nil, errors.Errorf("pdfcpu: filter FlateDecode: undefined \"Predictor\" %d", predictor)
 	}
 
@@ -359,3 +367,114 @@ func (f flate) decodePostProcess(r io.Reader, maxLen int64) (io.Reader, error) {
 
 	return &b, nil
 }
+
+// filterRow applies PNG row filter f to cdat using the previous row pdat and writes the result into dst.
+func filterRow(dst, cdat, pdat []byte, f, bytesPerPixel int) {
+	for i, c := range cdat {
+		var a, b, x byte
+		if i >= bytesPerPixel {
+			a, x = cdat[i-bytesPerPixel], pdat[i-bytesPerPixel]
+		}
+		b = pdat[i]
+
+		switch f {
+		case PNGNone:
+			dst[i] = c
+		case PNGSub:
+			dst[i] = c - a
+		case PNGUp:
+			dst[i] = c - b
+		case PNGAverage:
+			dst[i] = c - uint8((int(a)+int(b))/2)
+		case PNGPaeth:
+			dst[i] = c - paeth(a, b, x)
+		}
+	}
+}
+
+// rowCost estimates the compressibility of a filtered row (minimum sum of absolute differences).
+func rowCost(row []byte) int {
+	sum := 0
+	for _, v := range row {
+		sum += abs(int(int8(v)))
+	}
+	return sum
+}
+
+// encodePreProcess applies the TIFF or PNG predictor in effect to rows of pixel bytes.
+// PredictorOptimum selects the PNG filter minimizing the sum of absolute differences for each row.
+func (f flate) encodePreProcess(r io.Reader) (io.Reader, error) {
+	predictor, found := f.parms["Predictor"]
+	if !found || predictor == PredictorNo {
+		return r, nil
+	}
+
+	if !validPredictor(predictor) {
+		return nil, errors.Errorf("pdfcpu: filter FlateDecode: undefined \"Predictor\" %d", predictor)
+	}
+
+	colors, bpc, columns, err := f.parameters()
+	if err != nil {
+		return nil, err
+	}
+
+	if predictor == PredictorTIFF && bpc != 8 {
+		return nil, errors.Errorf("pdfcpu: filter FlateDecode: TIFF predictor unsupported for \"BitsPerComponent\" %d", bpc)
+	}
+
+	bytesPerPixel := (bpc*colors + 7) / 8
+	rowSize := (bpc*colors*columns + 7) / 8
+
+	bb, err := getReaderBytes(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if rowSize == 0 || len(bb)%rowSize > 0 {
+		return nil, errors.New("pdfcpu: filter FlateDecode: preprocessing failed")
+	}
+
+	if predictor == PredictorTIFF {
+		out := make([]byte, len(bb))
+		copy(out, bb)
+		for i := 0; i < len(out); i += rowSize {
+			row := out[i : i+rowSize]
+			for j := len(row) - 1; j >= colors; j-- {
+				row[j] -= row[j-colors]
+			}
+		}
+		return bytes.NewReader(out), nil
+	}
+
+	var b bytes.Buffer
+	b.Grow(len(bb) + len(bb)/rowSize)
+
+	pr := make([]byte, rowSize)
+	buf := make([]byte, rowSize)
+	best := make([]byte, rowSize)
+
+	for i := 0; i < len(bb); i += rowSize {
+		cr := bb[i : i+rowSize]
+
+		rf := predictor - PredictorNone
+		if predictor == PredictorOptimum {
+			minCost := -1
+			for pf := PNGNone; pf <= PNGPaeth; pf++ {
+				filterRow(buf, cr, pr, pf, bytesPerPixel)
+				if c := rowCost(buf); minCost < 0 || c < minCost {
+					minCost, rf = c, pf
+					buf, best = best, buf
+				}
+			}
+		} else {
+			filterRow(best, cr, pr, rf, bytesPerPixel)
+		}
+
+		b.WriteByte(byte(rf))
+		b.Write(best)
+
+		pr = cr
+	}
+
+	return &b, nil
+}
@@ -0,0 +1,913 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pdfcpu/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// JBIG2 segment types (7.3).
+const (
+	jbig2SymbolDict                  = 0
+	jbig2IntermediateTextRegion      = 4
+	jbig2ImmediateTextRegion         = 6
+	jbig2ImmediateLosslessTextRegion = 7
+	jbig2PatternDict                 = 16
+	jbig2IntermediateHalftoneRegion  = 20
+	jbig2ImmediateHalftoneRegion     = 22
+	jbig2ImmediateLosslessHalftone   = 23
+	jbig2IntermediateGenericRegion   = 36
+	jbig2ImmediateGenericRegion      = 38
+	jbig2ImmediateLosslessGeneric    = 39
+	jbig2IntermediateRefinement      = 40
+	jbig2ImmediateRefinement         = 42
+	jbig2ImmediateLosslessRefinement = 43
+	jbig2PageInfo                    = 48
+	jbig2EndOfPage                   = 49
+	jbig2EndOfStripe                 = 50
+	jbig2EndOfFile                   = 51
+	jbig2Tables                      = 53
+)
+
+var jbig2FileHeaderID = []byte{0x97, 0x4A, 0x42, 0x32, 0x0D, 0x0A, 0x1A, 0x0A}
+
+func be32(bb []byte) uint32 {
+	return uint32(bb[0])<<24 | uint32(bb[1])<<16 | uint32(bb[2])<<8 | uint32(bb[3])
+}
+
+func be16(bb []byte) int {
+	return int(bb[0])<<8 | int(bb[1])
+}
+
+// jbig2Segment is a segment (7.2) along with its decoding results needed by referring segments.
+type jbig2Segment struct {
+	number   uint32
+	typ      int
+	refs     []uint32
+	data     []byte
+	dataLen  uint32
+	symbols  []*jbig2Bitmap // exported symbols or patterns
+	table    *huffmanTable
+	bitmap   *jbig2Bitmap   // intermediate region
+	contexts *jbig2Contexts // retained contexts of a symbol dictionary
+}
+
+// jbig2Page is the page buffer (8.2).
+type jbig2Page struct {
+	bm            *jbig2Bitmap
+	defPixel      byte
+	unknownHeight bool
+}
+
+// grow enlarges a page of unknown height to h rows.
+func (p *jbig2Page) grow(h int) error {
+	if !p.unknownHeight || h <= p.bm.h {
+		return nil
+	}
+	bm, err := newJBIG2Bitmap(p.bm.w, h)
+	if err != nil {
+		return err
+	}
+	copy(bm.pix, p.bm.pix)
+	if p.defPixel == 1 {
+		for i := len(p.bm.pix); i < len(bm.pix); i++ {
+			bm.pix[i] = 1
+		}
+	}
+	p.bm = bm
+	return nil
+}
+
+// jbig2Decoder decodes the segments of an embedded JBIG2 stream and its globals (D.3).
+type jbig2Decoder struct {
+	segments map[uint32]*jbig2Segment
+	page     *jbig2Page
+}
+
+func newJBIG2Decoder() *jbig2Decoder {
+	return &jbig2Decoder{segments: map[uint32]*jbig2Segment{}}
+}
+
+// parseSegmentHeader parses the segment header at the start of bb and returns the header length (7.2).
+func parseSegmentHeader(bb []byte) (*jbig2Segment, int, error) {
+	if len(bb) < 11 {
+		return nil, 0, errJBIG2EOD
+	}
+
+	s := &jbig2Segment{number: be32(bb), typ: int(bb[4] & 0x3F)}
+	pageAssoc4 := bb[4]&0x40 != 0
+
+	i := 5
+	refCount := int(bb[i] >> 5)
+	if refCount == 7 {
+		refCount = int(be32(bb[i:]) & 0x1FFFFFFF)
+		i += 4 + (refCount+8)/8
+	} else {
+		i++
+	}
+
+	refSize := 1
+	if s.number > 65536 {
+		refSize = 4
+	} else if s.number > 256 {
+		refSize = 2
+	}
+
+	if refCount > len(bb) || i+refCount*refSize > len(bb) {
+		return nil, 0, errJBIG2EOD
+	}
+
+	for j := 0; j < refCount; j++ {
+		var ref uint32
+		switch refSize {
+		case 1:
+			ref = uint32(bb[i])
+		case 2:
+			ref = uint32(be16(bb[i:]))
+		default:
+			ref = be32(bb[i:])
+		}
+		s.refs = append(s.refs, ref)
+		i += refSize
+	}
+
+	if pageAssoc4 {
+		i += 4
+	} else {
+		i++
+	}
+
+	if i+4 > len(bb) {
+		return nil, 0, errJBIG2EOD
+	}
+	s.dataLen = be32(bb[i:])
+
+	return s, i + 4, nil
+}
+
+// unknownDataLength determines the data length of an immediate generic region segment
+// whose length is not given in the segment header (7.2.7).
+func unknownDataLength(bb []byte) (int, error) {
+	if len(bb) < 18 {
+		return 0, errJBIG2EOD
+	}
+
+	flags := bb[17]
+	mmr, template := flags&1 == 1, flags>>1&3
+
+	i := 18
+	marker := []byte{0x00, 0x00}
+	if !mmr {
+		marker = []byte{0xFF, 0xAC}
+		i += 2
+		if template == 0 {
+			i += 6
+		}
+	}
+
+	// The data ends with the marker followed by the row count.
+	for ; i+6 <= len(bb); i++ {
+		if bb[i] == marker[0] && bb[i+1] == marker[1] {
+			return i + 6, nil
+		}
+	}
+
+	return 0, errors.New("pdfcpu: jbig2: unable to determine segment length")
+}
+
+// parseSegments parses the segments of bb, either embedded stream data or a JBIG2 file (D.3, D.4).
+func parseSegments(bb []byte) ([]*jbig2Segment, error) {
+	randomAccess := false
+
+	if bytes.HasPrefix(bb, jbig2FileHeaderID) && len(bb) > 8 {
+		flags := bb[8]
+		randomAccess = flags&1 == 0
+		bb = bb[9:]
+		if flags&2 == 0 {
+			// Number of pages known.
+			if len(bb) < 4 {
+				return nil, errJBIG2EOD
+			}
+			bb = bb[4:]
+		}
+	}
+
+	var ss []*jbig2Segment
+
+	for len(bb) > 0 {
+		s, n, err := parseSegmentHeader(bb)
+		if err != nil {
+			return nil, err
+		}
+		bb = bb[n:]
+		ss = append(ss, s)
+
+		if randomAccess {
+			if s.typ == jbig2EndOfFile {
+				break
+			}
+			continue
+		}
+
+		if err := s.readData(&bb); err != nil {
+			return nil, err
+		}
+
+		if s.typ == jbig2EndOfFile {
+			break
+		}
+	}
+
+	if randomAccess {
+		for _, s := range ss {
+			if err := s.readData(&bb); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return ss, nil
+}
+
+// readData consumes the data of s from bb.
+func (s *jbig2Segment) readData(bb *[]byte) error {
+	n := int(s.dataLen)
+	if s.dataLen == 0xFFFFFFFF {
+		if s.typ != jbig2ImmediateGenericRegion {
+			return errors.New("pdfcpu: jbig2: unknown segment length")
+		}
+		var err error
+		if n, err = unknownDataLength(*bb); err != nil {
+			return err
+		}
+	}
+	if n > len(*bb) {
+		return errJBIG2EOD
+	}
+	s.data = (*bb)[:n]
+	*bb = (*bb)[n:]
+	return nil
+}
+
+// decode decodes the segments of bb.
+func (d *jbig2Decoder) decode(bb []byte) error {
+	ss, err := parseSegments(bb)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range ss {
+		if err := d.decodeSegment(s); err != nil {
+			return err
+		}
+		d.segments[s.number] = s
+		if s.typ == jbig2EndOfPage || s.typ == jbig2EndOfFile {
+			break
+		}
+	}
+
+	return nil
+}
+
+// referred returns the referred segments of s of given types.
+func (d *jbig2Decoder) referred(s *jbig2Segment, types ...int) []*jbig2Segment {
+	var ss []*jbig2Segment
+	for _, nr := range s.refs {
+		s1, ok := d.segments[nr]
+		if !ok {
+			continue
+		}
+		for _, t := range types {
+			if s1.typ == t {
+				ss = append(ss, s1)
+				break
+			}
+		}
+	}
+	return ss
+}
+
+// referredSymbols returns the symbols exported by symbol dictionaries referred to by s.
+func (d *jbig2Decoder) referredSymbols(s *jbig2Segment) []*jbig2Bitmap {
+	var syms []*jbig2Bitmap
+	for _, s1 := range d.referred(s, jbig2SymbolDict) {
+		syms = append(syms, s1.symbols...)
+	}
+	return syms
+}
+
+func (d *jbig2Decoder) decodeSegment(s *jbig2Segment) error {
+	switch s.typ {
+
+	case jbig2SymbolDict:
+		return d.decodeSymbolDictSegment(s)
+
+	case jbig2IntermediateTextRegion, jbig2ImmediateTextRegion, jbig2ImmediateLosslessTextRegion:
+		return d.decodeTextRegionSegment(s)
+
+	case jbig2PatternDict:
+		pp, err := decodePatternDict(s.data)
+		if err != nil {
+			return err
+		}
+		s.symbols = pp
+
+	case jbig2IntermediateHalftoneRegion, jbig2ImmediateHalftoneRegion, jbig2ImmediateLosslessHalftone:
+		return d.decodeHalftoneRegionSegment(s)
+
+	case jbig2IntermediateGenericRegion, jbig2ImmediateGenericRegion, jbig2ImmediateLosslessGeneric:
+		return d.decodeGenericRegionSegment(s)
+
+	case jbig2IntermediateRefinement, jbig2ImmediateRefinement, jbig2ImmediateLosslessRefinement:
+		return d.decodeRefinementRegionSegment(s)
+
+	case jbig2PageInfo:
+		return d.decodePageInfo(s)
+
+	case jbig2EndOfStripe:
+		if len(s.data) < 4 || d.page == nil {
+			return errJBIG2EOD
+		}
+		return d.page.grow(int(be32(s.data)) + 1)
+
+	case jbig2Tables:
+		t, err := parseHuffmanTable(s.data)
+		if err != nil {
+			return err
+		}
+		s.table = t
+
+	default:
+		// End of page, end of file, profiles, extensions and unknown segment types.
+		if log.TraceEnabled() {
+			log.Trace.Printf("jbig2: ignoring segment %d of type %d\n", s.number, s.typ)
+		}
+	}
+
+	return nil
+}
+
+func (d *jbig2Decoder) decodePageInfo(s *jbig2Segment) error {
+	if len(s.data) < 19 {
+		return errJBIG2EOD
+	}
+
+	w, h := be32(s.data), be32(s.data[4:])
+	flags := s.data[16]
+
+	p := &jbig2Page{defPixel: flags >> 2 & 1}
+	if h == 0xFFFFFFFF {
+		p.unknownHeight = true
+		h = 0
+	}
+	if w > maxJBIG2Pixels || h > maxJBIG2Pixels {
+		return errors.New("pdfcpu: jbig2: invalid page size")
+	}
+
+	bm, err := newJBIG2Bitmap(int(w), int(h))
+	if err != nil {
+		return err
+	}
+	if p.defPixel == 1 {
+		bm.fill(1)
+	}
+	p.bm = bm
+	d.page = p
+
+	return nil
+}
+
+// regionInfo is the region segment information field (7.4.1).
+type regionInfo struct {
+	w, h, x, y int
+	op         int
+}
+
+func parseRegionInfo(bb []byte) (regionInfo, error) {
+	if len(bb) < 17 {
+		return regionInfo{}, errJBIG2EOD
+	}
+	ri := regionInfo{
+		w:  int(be32(bb)),
+		h:  int(be32(bb[4:])),
+		x:  int(int32(be32(bb[8:]))),
+		y:  int(int32(be32(bb[12:]))),
+		op: int(bb[16] & 7),
+	}
+	if ri.w > maxJBIG2Pixels || ri.h > maxJBIG2Pixels {
+		return ri, errors.New("pdfcpu: jbig2: invalid region size")
+	}
+	return ri, nil
+}
+
+// placeRegion keeps the result of an intermediate region segment
+// or draws the result of an immediate region segment onto the page (7.4.*.5).
+func (d *jbig2Decoder) placeRegion(s *jbig2Segment, ri regionInfo, bm *jbig2Bitmap) error {
+	switch s.typ {
+	case jbig2IntermediateTextRegion, jbig2IntermediateHalftoneRegion, jbig2IntermediateGenericRegion, jbig2IntermediateRefinement:
+		s.bitmap = bm
+		return nil
+	}
+
+	if d.page == nil {
+		return errors.New("pdfcpu: jbig2: missing page information")
+	}
+
+	if err := d.page.grow(ri.y + bm.h); err != nil {
+		return err
+	}
+
+	d.page.bm.compose(bm, ri.x, ri.y, ri.op)
+
+	return nil
+}
+
+// atPixels parses n adaptive template pixel pairs.
+func atPixels(bb []byte, n int) ([4][2]int, error) {
+	var at [4][2]int
+	if len(bb) < 2*n {
+		return at, errJBIG2EOD
+	}
+	for i := 0; i < n; i++ {
+		at[i] = [2]int{int(int8(bb[2*i])), int(int8(bb[2*i+1]))}
+	}
+	return at, nil
+}
+
+func (d *jbig2Decoder) decodeGenericRegionSegment(s *jbig2Segment) error {
+	ri, err := parseRegionInfo(s.data)
+	if err != nil {
+		return err
+	}
+	if len(s.data) < 18 {
+		return errJBIG2EOD
+	}
+
+	flags := s.data[17]
+	bb := s.data[18:]
+
+	g := &genericRegion{w: ri.w, h: ri.h, template: int(flags >> 1 & 3), tpgdon: flags&8 != 0}
+	mmr := flags&1 == 1
+
+	if s.dataLen == 0xFFFFFFFF && len(bb) >= 4 {
+		// The row count follows the end of the data.
+		g.h = int(be32(bb[len(bb)-4:]))
+		ri.h = g.h
+		bb = bb[:len(bb)-4]
+		if mmr && len(bb) >= 2 {
+			bb = bb[:len(bb)-2]
+		}
+	}
+
+	var bm *jbig2Bitmap
+
+	if mmr {
+		if bm, err = decodeMMR(bb, g.w, g.h); err != nil {
+			return err
+		}
+		return d.placeRegion(s, ri, bm)
+	}
+
+	n := 1
+	if g.template == 0 {
+		n = 4
+	}
+	if g.at, err = atPixels(bb, n); err != nil {
+		return err
+	}
+
+	if bm, err = g.decode(newMQDecoder(bb[2*n:]), make([]uint8, 1<<16)); err != nil {
+		return err
+	}
+
+	return d.placeRegion(s, ri, bm)
+}
+
+func (d *jbig2Decoder) decodeRefinementRegionSegment(s *jbig2Segment) error {
+	ri, err := parseRegionInfo(s.data)
+	if err != nil {
+		return err
+	}
+	if len(s.data) < 18 {
+		return errJBIG2EOD
+	}
+
+	flags := s.data[17]
+	bb := s.data[18:]
+
+	g := &refinementRegion{w: ri.w, h: ri.h, template: int(flags & 1), tpgron: flags&2 != 0}
+
+	if g.template == 0 {
+		at, err := atPixels(bb, 2)
+		if err != nil {
+			return err
+		}
+		g.at = [2][2]int{at[0], at[1]}
+		bb = bb[4:]
+	}
+
+	if ss := d.referred(s, jbig2IntermediateTextRegion, jbig2IntermediateHalftoneRegion,
+		jbig2IntermediateGenericRegion, jbig2IntermediateRefinement); len(ss) > 0 && ss[0].bitmap != nil {
+		g.ref = ss[0].bitmap
+	} else {
+		// Refine the page region.
+		if d.page == nil {
+			return errors.New("pdfcpu: jbig2: missing page information")
+		}
+		if g.ref, err = d.page.bm.sub(ri.x, ri.y, ri.w, ri.h); err != nil {
+			return err
+		}
+	}
+
+	bm, err := g.decode(newMQDecoder(bb), make([]uint8, 1<<13))
+	if err != nil {
+		return err
+	}
+
+	return d.placeRegion(s, ri, bm)
+}
+
+// huffmanTableSelector returns a function providing the Huffman table for a table selection,
+// custom tables are taken from the referred table segments in order.
+func (d *jbig2Decoder) huffmanTableSelector(s *jbig2Segment) func(sel int, standard ...int) (*huffmanTable, error) {
+	custom := d.referred(s, jbig2Tables)
+	return func(sel int, standard ...int) (*huffmanTable, error) {
+		if sel < len(standard) {
+			return standardHuffmanTables[standard[sel]], nil
+		}
+		if len(custom) == 0 {
+			return nil, errors.New("pdfcpu: jbig2: missing custom Huffman table")
+		}
+		t := custom[0].table
+		custom = custom[1:]
+		return t, nil
+	}
+}
+
+func (d *jbig2Decoder) decodeSymbolDictSegment(s *jbig2Segment) error {
+	bb := s.data
+	if len(bb) < 2 {
+		return errJBIG2EOD
+	}
+
+	flags := be16(bb)
+	bb = bb[2:]
+
+	huff, refAgg := flags&1 == 1, flags&2 != 0
+	contextUsed, contextRetained := flags&0x100 != 0, flags&0x200 != 0
+
+	sd := &symbolDict{
+		refAgg:    refAgg,
+		template:  flags >> 10 & 3,
+		rTemplate: flags >> 12 & 1,
+		inSyms:    d.referredSymbols(s),
+	}
+
+	var err error
+
+	if !huff {
+		n := 1
+		if sd.template == 0 {
+			n = 4
+		}
+		if sd.at, err = atPixels(bb, n); err != nil {
+			return err
+		}
+		bb = bb[2*n:]
+	}
+
+	if refAgg && sd.rTemplate == 0 {
+		at, err := atPixels(bb, 2)
+		if err != nil {
+			return err
+		}
+		sd.rat = [2][2]int{at[0], at[1]}
+		bb = bb[4:]
+	}
+
+	if len(bb) < 8 {
+		return errJBIG2EOD
+	}
+	sd.numExSyms, sd.numNewSyms = int(be32(bb)), int(be32(bb[4:]))
+	bb = bb[8:]
+
+	if sd.numNewSyms > maxJBIG2Pixels || sd.numExSyms > len(sd.inSyms)+sd.numNewSyms {
+		return errors.New("pdfcpu: jbig2: invalid number of symbols")
+	}
+
+	dec := &jbig2IntDecoder{cx: newJBIG2Contexts()}
+
+	if contextUsed {
+		// Reuse the contexts retained by the last referred symbol dictionary (7.4.2.2).
+		if ss := d.referred(s, jbig2SymbolDict); len(ss) > 0 && ss[len(ss)-1].contexts != nil {
+			last := ss[len(ss)-1]
+			cx := *last.contexts
+			cx.gb = append([]uint8{}, cx.gb...)
+			cx.gr = append([]uint8{}, cx.gr...)
+			dec.cx = &cx
+		}
+	}
+
+	if huff {
+		sel := d.huffmanTableSelector(s)
+		if sd.dh, err = sel(flags>>2&3, 4, 5); err != nil {
+			return err
+		}
+		if sd.dw, err = sel(flags>>4&3, 2, 3); err != nil {
+			return err
+		}
+		if sd.bmSize, err = sel(flags>>6&1, 1); err != nil {
+			return err
+		}
+		if sd.aggInst, err = sel(flags>>7&1, 1); err != nil {
+			return err
+		}
+		dec.hr = &jbig2BitReader{data: bb}
+	} else {
+		dec.ad = newMQDecoder(bb)
+	}
+
+	if s.symbols, err = sd.decode(dec); err != nil {
+		return err
+	}
+
+	if contextRetained {
+		s.contexts = dec.cx
+	}
+
+	return nil
+}
+
+func (d *jbig2Decoder) decodeTextRegionSegment(s *jbig2Segment) error {
+	ri, err := parseRegionInfo(s.data)
+	if err != nil {
+		return err
+	}
+
+	bb := s.data[17:]
+	if len(bb) < 2 {
+		return errJBIG2EOD
+	}
+
+	flags := be16(bb)
+	bb = bb[2:]
+
+	huff := flags&1 == 1
+
+	tr := &textRegion{
+		w:          ri.w,
+		h:          ri.h,
+		refine:     flags&2 != 0,
+		logStrips:  flags >> 2 & 3,
+		refCorner:  flags >> 4 & 3,
+		transposed: flags&0x40 != 0,
+		op:         flags >> 7 & 3,
+		defPixel:   byte(flags >> 9 & 1),
+		dsOffset:   flags >> 10 & 0x1F,
+		rTemplate:  flags >> 15 & 1,
+		syms:       d.referredSymbols(s),
+	}
+	if tr.dsOffset > 0x0F {
+		tr.dsOffset -= 0x20
+	}
+
+	var hflags int
+	if huff {
+		if len(bb) < 2 {
+			return errJBIG2EOD
+		}
+		hflags = be16(bb)
+		bb = bb[2:]
+	}
+
+	if tr.refine && tr.rTemplate == 0 {
+		at, err := atPixels(bb, 2)
+		if err != nil {
+			return err
+		}
+		tr.rat = [2][2]int{at[0], at[1]}
+		bb = bb[4:]
+	}
+
+	if len(bb) < 4 {
+		return errJBIG2EOD
+	}
+	tr.numInstances = int(be32(bb))
+	bb = bb[4:]
+
+	dec := &jbig2IntDecoder{cx: newJBIG2Contexts()}
+
+	if !huff {
+		tr.symCodeLen = ceilLog2(len(tr.syms))
+		if tr.symCodeLen > 24 {
+			return errors.New("pdfcpu: jbig2: too many symbols")
+		}
+		dec.ad = newMQDecoder(bb)
+		bm, err := tr.decode(dec)
+		if err != nil {
+			return err
+		}
+		return d.placeRegion(s, ri, bm)
+	}
+
+	sel := d.huffmanTableSelector(s)
+	for _, t := range []struct {
+		t        **huffmanTable
+		sel      int
+		standard []int
+	}{
+		{&tr.fs, hflags & 3, []int{6, 7}},
+		{&tr.ds, hflags >> 2 & 3, []int{8, 9, 10}},
+		{&tr.dt, hflags >> 4 & 3, []int{11, 12, 13}},
+		{&tr.rdw, hflags >> 6 & 3, []int{14, 15}},
+		{&tr.rdh, hflags >> 8 & 3, []int{14, 15}},
+		{&tr.rdx, hflags >> 10 & 3, []int{14, 15}},
+		{&tr.rdy, hflags >> 12 & 3, []int{14, 15}},
+		{&tr.rsize, hflags >> 14 & 1, []int{1}},
+	} {
+		if *t.t, err = sel(t.sel, t.standard...); err != nil {
+			return err
+		}
+	}
+
+	dec.hr = &jbig2BitReader{data: bb}
+
+	if tr.symCodes, err = decodeSymbolIDTable(dec.hr, len(tr.syms)); err != nil {
+		return err
+	}
+
+	bm, err := tr.decode(dec)
+	if err != nil {
+		return err
+	}
+
+	return d.placeRegion(s, ri, bm)
+}
+
+// decodeSymbolIDTable decodes the symbol ID Huffman table of a text region segment (7.4.3.1.7).
+func decodeSymbolIDTable(r *jbig2BitReader, numSyms int) (*huffmanTable, error) {
+	runCodeLines := make([]huffmanLine, 35)
+	for i := range runCodeLines {
+		n, err := r.readBits(4)
+		if err != nil {
+			return nil, err
+		}
+		runCodeLines[i] = huffmanLine{rangeLow: i, prefLen: n}
+	}
+
+	runCodes, err := newHuffmanTable(runCodeLines)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]huffmanLine, 0, numSyms)
+
+	for len(lines) < numSyms {
+		code, err := (&jbig2IntDecoder{hr: r}).decodeValue(nil, runCodes)
+		if err != nil {
+			return nil, err
+		}
+
+		prefLen, n := 0, 1
+
+		switch {
+		case code < 32:
+			prefLen = code
+		case code == 32:
+			if len(lines) == 0 {
+				return nil, errors.New("pdfcpu: jbig2: invalid symbol ID table")
+			}
+			prefLen = lines[len(lines)-1].prefLen
+			v, err := r.readBits(2)
+			if err != nil {
+				return nil, err
+			}
+			n = v + 3
+		case code == 33:
+			v, err := r.readBits(3)
+			if err != nil {
+				return nil, err
+			}
+			n = v + 3
+		default:
+			v, err := r.readBits(7)
+			if err != nil {
+				return nil, err
+			}
+			n = v + 11
+		}
+
+		for i := 0; i < n && len(lines) < numSyms; i++ {
+			lines = append(lines, huffmanLine{rangeLow: len(lines), prefLen: prefLen})
+		}
+	}
+
+	r.align()
+
+	return newHuffmanTable(lines)
+}
+
+func (d *jbig2Decoder) decodeHalftoneRegionSegment(s *jbig2Segment) error {
+	ri, err := parseRegionInfo(s.data)
+	if err != nil {
+		return err
+	}
+
+	bb := s.data[17:]
+	if len(bb) < 21 {
+		return errJBIG2EOD
+	}
+
+	flags := bb[0]
+
+	hr := &halftoneRegion{
+		w:          ri.w,
+		h:          ri.h,
+		mmr:        flags&1 == 1,
+		template:   int(flags >> 1 & 3),
+		enableSkip: flags&8 != 0,
+		op:         int(flags >> 4 & 7),
+		defPixel:   flags >> 7 & 1,
+		gw:         int(be32(bb[1:])),
+		gh:         int(be32(bb[5:])),
+		gx:         int(int32(be32(bb[9:]))),
+		gy:         int(int32(be32(bb[13:]))),
+		rx:         be16(bb[17:]),
+		ry:         be16(bb[19:]),
+	}
+
+	if ss := d.referred(s, jbig2PatternDict); len(ss) > 0 {
+		hr.patterns = ss[0].symbols
+	}
+
+	bm, err := hr.decode(bb[21:])
+	if err != nil {
+		return err
+	}
+
+	return d.placeRegion(s, ri, bm)
+}
+
+type jbig2Decode struct {
+	baseFilter
+}
+
+// Encode implements encoding for a JBIG2Decode filter.
+func (f jbig2Decode) Encode(r io.Reader) (io.Reader, error) {
+	return nil, errors.New("pdfcpu: filter JBIG2Decode: encoding not supported")
+}
+
+// Decode implements decoding for a JBIG2Decode filter.
+func (f jbig2Decode) Decode(r io.Reader) (io.Reader, error) {
+	return f.DecodeLength(r, -1)
+}
+
+// DecodeLength implements decoding for a JBIG2Decode filter.
+// The embedded stream is decoded as a whole along with the optional JBIG2Globals stream.
+// The result are 1 bit samples where 0 means black.
+func (f jbig2Decode) DecodeLength(r io.Reader, maxLen int64) (io.Reader, error) {
+	if log.TraceEnabled() {
+		log.Trace.Println("DecodeJBIG2 begin")
+	}
+
+	bb, err := getReaderBytes(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newJBIG2Decoder()
+
+	if globals := f.streams["JBIG2Globals"]; len(globals) > 0 {
+		if err := d.decode(globals); err != nil {
+			return nil, errors.Wrap(err, "pdfcpu: jbig2: globals")
+		}
+	}
+
+	if err := d.decode(bb); err != nil {
+		return nil, err
+	}
+
+	if d.page == nil {
+		return nil, errors.New("pdfcpu: jbig2: missing page information")
+	}
+
+	return bytes.NewBuffer(d.page.bm.bytes()), nil
+}
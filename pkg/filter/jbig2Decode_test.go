@@ -0,0 +1,419 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// mqEncoder implements the MQ arithmetic encoder (E.2) for creating test data.
+type mqEncoder struct {
+	out []byte // out[0] is the byte preceding the encoded data.
+	a   uint32
+	c   uint32
+	ct  int
+}
+
+func newMQEncoder() *mqEncoder {
+	return &mqEncoder{out: []byte{0}, a: 0x8000, ct: 12}
+}
+
+func (e *mqEncoder) byteOut() {
+	b := &e.out[len(e.out)-1]
+	if *b == 0xFF {
+		e.out = append(e.out, byte(e.c>>20))
+		e.c &= 0xFFFFF
+		e.ct = 7
+		return
+	}
+	if e.c >= 0x8000000 {
+		*b++
+		if *b == 0xFF {
+			e.c &= 0x7FFFFFF
+			e.out = append(e.out, byte(e.c>>20))
+			e.c &= 0xFFFFF
+			e.ct = 7
+			return
+		}
+	}
+	e.out = append(e.out, byte(e.c>>19))
+	e.c &= 0x7FFFF
+	e.ct = 8
+}
+
+func (e *mqEncoder) renorm() {
+	for {
+		e.a <<= 1
+		e.c <<= 1
+		if e.ct--; e.ct == 0 {
+			e.byteOut()
+		}
+		if e.a&0x8000 != 0 {
+			break
+		}
+	}
+}
+
+func (e *mqEncoder) encode(cx []uint8, i int, bit int) {
+	index, mps := cx[i]>>1, int(cx[i]&1)
+	q := qeTable[index]
+
+	e.a -= q.qe
+
+	if bit == mps {
+		if e.a&0x8000 != 0 {
+			e.c += q.qe
+			return
+		}
+		if e.a < q.qe {
+			e.a = q.qe
+		} else {
+			e.c += q.qe
+		}
+		index = q.nmps
+	} else {
+		if e.a < q.qe {
+			e.c += q.qe
+		} else {
+			e.a = q.qe
+		}
+		if q.switchMPS {
+			mps = 1 - mps
+		}
+		index = q.nlps
+	}
+
+	cx[i] = index<<1 | uint8(mps)
+	e.renorm()
+}
+
+func (e *mqEncoder) flush() []byte {
+	tmp := e.c + e.a
+	e.c |= 0xFFFF
+	if e.c >= tmp {
+		e.c -= 0x8000
+	}
+	e.c <<= uint(e.ct)
+	e.byteOut()
+	e.c <<= uint(e.ct)
+	e.byteOut()
+	if e.out[len(e.out)-1] != 0xFF {
+		e.out = append(e.out, 0xFF)
+	}
+	e.out = append(e.out, 0xAC)
+	return e.out[1:]
+}
+
+// encodeInt encodes v or OOB using the procedure of A.2.
+func (e *mqEncoder) encodeInt(cx *intContexts, v int, oob bool) {
+	prev := 1
+	bit := func(b int) {
+		e.encode(cx[:], prev, b)
+		if prev < 256 {
+			prev = prev<<1 | b
+		} else {
+			prev = (prev<<1|b)&511 | 256
+		}
+	}
+	bits := func(v, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bit(v >> uint(i) & 1)
+		}
+	}
+
+	if oob {
+		bit(1)
+		bits(0, 3)
+		return
+	}
+
+	s := 0
+	if v < 0 {
+		s, v = 1, -v
+	}
+	bit(s)
+
+	switch {
+	case v < 4:
+		bits(0, 1)
+		bits(v, 2)
+	case v < 20:
+		bits(2, 2)
+		bits(v-4, 4)
+	case v < 84:
+		bits(6, 3)
+		bits(v-20, 6)
+	case v < 340:
+		bits(14, 4)
+		bits(v-84, 8)
+	case v < 4436:
+		bits(30, 5)
+		bits(v-340, 12)
+	default:
+		bits(31, 5)
+		bits(v-4436, 32)
+	}
+}
+
+func (e *mqEncoder) encodeIAID(cx []uint8, codeLen, v int) {
+	prev := 1
+	for i := codeLen - 1; i >= 0; i-- {
+		b := v >> uint(i) & 1
+		e.encode(cx, prev, b)
+		prev = prev<<1 | b
+	}
+}
+
+func (e *mqEncoder) encodeGeneric(g *genericRegion, b *jbig2Bitmap, cx []uint8) {
+	ltp := 0
+	for y := 0; y < b.h; y++ {
+		if g.tpgdon {
+			sltp := 0
+			if y > 0 && bytes.Equal(b.pix[y*b.w:(y+1)*b.w], b.pix[(y-1)*b.w:y*b.w]) {
+				sltp = 1
+			}
+			e.encode(cx, tpgdonContexts[g.template], ltp^sltp)
+			if ltp = sltp; ltp == 1 {
+				continue
+			}
+		}
+		for x := 0; x < b.w; x++ {
+			e.encode(cx, g.context(b, x, y), b.at(x, y))
+		}
+	}
+}
+
+func testBitmap(w, h int, f func(x, y int) bool) *jbig2Bitmap {
+	b, _ := newJBIG2Bitmap(w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if f(x, y) {
+				b.set(x, y, 1)
+			}
+		}
+	}
+	return b
+}
+
+func jbig2SegmentBytes(nr uint32, typ byte, refs []byte, data []byte) []byte {
+	bb := []byte{byte(nr >> 24), byte(nr >> 16), byte(nr >> 8), byte(nr), typ, byte(len(refs) << 5)}
+	bb = append(bb, refs...)
+	bb = append(bb, 1) // page association
+	n := len(data)
+	bb = append(bb, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	return append(bb, data...)
+}
+
+func be32Bytes(v int) []byte {
+	return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+func pageInfo(w, h int) []byte {
+	bb := append(be32Bytes(w), be32Bytes(h)...)
+	return append(bb, make([]byte, 11)...)
+}
+
+func regionInfoBytes(w, h, x, y int) []byte {
+	bb := append(be32Bytes(w), be32Bytes(h)...)
+	bb = append(bb, be32Bytes(x)...)
+	bb = append(bb, be32Bytes(y)...)
+	return append(bb, jbig2OpOr)
+}
+
+func decodeJBIG2(t *testing.T, data, globals []byte) []byte {
+	t.Helper()
+	var streams map[string][]byte
+	if globals != nil {
+		streams = map[string][]byte{"JBIG2Globals": globals}
+	}
+	f, err := NewFilterWithStreams(JBIG2, nil, streams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := f.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bb
+}
+
+func TestJBIG2GenericRegion(t *testing.T) {
+	w, h := 67, 41
+	b := testBitmap(w, h, func(x, y int) bool {
+		dx, dy := x-30, y-20
+		return dx*dx+dy*dy < 225 || (y > 30 && y < 35) || (x*y)%7 == 0
+	})
+
+	nominalAT := [4][4][2]int{
+		{{3, -1}, {-3, -1}, {2, -2}, {-2, -2}},
+		{{3, -1}},
+		{{2, -1}},
+		{{2, -1}},
+	}
+
+	for template := 0; template < 4; template++ {
+		for _, tpgdon := range []bool{false, true} {
+			g := &genericRegion{w: w, h: h, template: template, tpgdon: tpgdon, at: nominalAT[template]}
+
+			e := newMQEncoder()
+			e.encodeGeneric(g, b, make([]uint8, 1<<16))
+
+			flags := byte(template << 1)
+			if tpgdon {
+				flags |= 8
+			}
+			data := append(regionInfoBytes(w, h, 0, 0), flags)
+			n := 1
+			if template == 0 {
+				n = 4
+			}
+			for _, at := range g.at[:n] {
+				data = append(data, byte(int8(at[0])), byte(int8(at[1])))
+			}
+			data = append(data, e.flush()...)
+
+			var bb []byte
+			bb = append(bb, jbig2SegmentBytes(0, jbig2PageInfo, nil, pageInfo(w, h))...)
+			bb = append(bb, jbig2SegmentBytes(1, jbig2ImmediateLosslessGeneric, nil, data)...)
+			bb = append(bb, jbig2SegmentBytes(2, jbig2EndOfPage, nil, nil)...)
+
+			compare(t, decodeJBIG2(t, bb, nil), b.bytes())
+		}
+	}
+}
+
+func TestJBIG2SymbolDictAndTextRegion(t *testing.T) {
+	// Three symbols of two height classes.
+	syms := []*jbig2Bitmap{
+		testBitmap(5, 7, func(x, y int) bool { return x == 0 || y == 0 || x == 4 || y == 6 }),
+		testBitmap(3, 7, func(x, y int) bool { return x == 1 }),
+		testBitmap(6, 9, func(x, y int) bool { return x == y || x+y == 5 }),
+	}
+
+	// Symbol dictionary, generic region coded using template 2.
+	at := [4][2]int{{2, -1}}
+	e := newMQEncoder()
+	cx := newJBIG2Contexts()
+	g := &genericRegion{template: 2, at: at}
+
+	e.encodeInt(&cx.iadh, 7, false)
+	e.encodeInt(&cx.iadw, 5, false)
+	e.encodeGeneric(g, syms[0], cx.gb)
+	e.encodeInt(&cx.iadw, -2, false)
+	e.encodeGeneric(g, syms[1], cx.gb)
+	e.encodeInt(&cx.iadw, 0, true)
+	e.encodeInt(&cx.iadh, 2, false)
+	e.encodeInt(&cx.iadw, 6, false)
+	e.encodeGeneric(g, syms[2], cx.gb)
+	e.encodeInt(&cx.iadw, 0, true)
+	// Export all symbols.
+	e.encodeInt(&cx.iaex, 0, false)
+	e.encodeInt(&cx.iaex, 3, false)
+
+	sdData := []byte{0x08, 0x00, 2, 0xFF} // SDTEMPLATE 2, AT pixel
+	sdData = append(sdData, be32Bytes(3)...)
+	sdData = append(sdData, be32Bytes(3)...)
+	sdData = append(sdData, e.flush()...)
+	globals := jbig2SegmentBytes(0, jbig2SymbolDict, nil, sdData)
+
+	// Text region with one strip of symbol instances using reference corner top left.
+	type instance struct{ id, s, t int }
+	ii := []instance{{0, 2, 3}, {1, 9, 3}, {2, 15, 3}, {0, 4, 14}}
+
+	e = newMQEncoder()
+	cx = newJBIG2Contexts()
+	iaid := cx.iaidContexts(2)
+
+	e.encodeInt(&cx.iadt, 0, false) // STRIPT
+	e.encodeInt(&cx.iadt, 3, false)
+	e.encodeInt(&cx.iafs, 2, false)
+	e.encodeIAID(iaid, 2, 0)
+	e.encodeInt(&cx.iads, 9-(2+5-1), false)
+	e.encodeIAID(iaid, 2, 1)
+	e.encodeInt(&cx.iads, 15-(9+3-1), false)
+	e.encodeIAID(iaid, 2, 2)
+	e.encodeInt(&cx.iads, 0, true)
+	e.encodeInt(&cx.iadt, 11, false)
+	e.encodeInt(&cx.iafs, 2, false)
+	e.encodeIAID(iaid, 2, 0)
+	e.encodeInt(&cx.iads, 0, true)
+
+	w, h := 30, 24
+	trData := regionInfoBytes(w, h, 0, 0)
+	trData = append(trData, 0x00, byte(jbig2TopLeft<<4))
+	trData = append(trData, be32Bytes(len(ii))...)
+	trData = append(trData, e.flush()...)
+
+	var bb []byte
+	bb = append(bb, jbig2SegmentBytes(1, jbig2PageInfo, nil, pageInfo(w, h))...)
+	bb = append(bb, jbig2SegmentBytes(2, jbig2ImmediateTextRegion, []byte{0}, trData)...)
+	bb = append(bb, jbig2SegmentBytes(3, jbig2EndOfPage, nil, nil)...)
+
+	want, _ := newJBIG2Bitmap(w, h)
+	for _, i := range ii {
+		want.compose(syms[i.id], i.s, i.t, jbig2OpOr)
+	}
+
+	compare(t, decodeJBIG2(t, bb, globals), want.bytes())
+}
+
+func TestJBIG2StandardHuffmanTables(t *testing.T) {
+	for _, tt := range []struct {
+		table int
+		code  string
+		want  int
+		oob   bool
+	}{
+		{1, "0" + "0101", 5, false},
+		{1, "10" + "00000001", 17, false},
+		{2, "111111", 0, true},
+		{2, "11110" + "000001", 12, false},
+		{3, "11111110" + "00000001", -255, false},
+		{3, "11111111" + "00000000000000000000000000000010", -259, false},
+		{8, "01", 0, true},
+		{8, "00" + "1", 1, false},
+		{14, "0", 0, false},
+		{14, "100", -2, false},
+		{15, "0", 0, false},
+		{15, "1111111" + "00000000000000000000000000000000", 25, false},
+	} {
+		var bb []byte
+		for i := 0; i < len(tt.code); i += 8 {
+			var b byte
+			for j := 0; j < 8; j++ {
+				b <<= 1
+				if i+j < len(tt.code) && tt.code[i+j] == '1' {
+					b |= 1
+				}
+			}
+			bb = append(bb, b)
+		}
+		v, ok, err := standardHuffmanTables[tt.table].decode(&jbig2BitReader{data: bb})
+		if err != nil {
+			t.Fatalf("table B.%d code %s: %v", tt.table, tt.code, err)
+		}
+		if ok == tt.oob || v != tt.want {
+			t.Errorf("table B.%d code %s: got %d (oob=%t), want %d (oob=%t)", tt.table, tt.code, v, !ok, tt.want, tt.oob)
+		}
+	}
+}
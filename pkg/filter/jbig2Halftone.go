@@ -0,0 +1,194 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// decodePatternDict decodes the patterns of a pattern dictionary segment (6.7.5).
+func decodePatternDict(data []byte) ([]*jbig2Bitmap, error) {
+	if len(data) < 7 {
+		return nil, errJBIG2EOD
+	}
+
+	flags := data[0]
+	mmr := flags&1 == 1
+	template := int(flags >> 1 & 3)
+	pw, ph := int(data[1]), int(data[2])
+	grayMax := int(be32(data[3:]))
+
+	if pw == 0 || ph == 0 || grayMax > maxJBIG2Pixels/pw {
+		return nil, errors.New("pdfcpu: jbig2: invalid pattern dictionary")
+	}
+
+	w := (grayMax + 1) * pw
+
+	var (
+		bm  *jbig2Bitmap
+		err error
+	)
+
+	if mmr {
+		bm, err = decodeMMR(data[7:], w, ph)
+	} else {
+		g := &genericRegion{w: w, h: ph, template: template, at: [4][2]int{{-pw, 0}, {-3, -1}, {2, -2}, {-2, -2}}}
+		bm, err = g.decode(newMQDecoder(data[7:]), make([]uint8, 1<<16))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := make([]*jbig2Bitmap, grayMax+1)
+	for i := range patterns {
+		if patterns[i], err = bm.sub(i*pw, 0, pw, ph); err != nil {
+			return nil, err
+		}
+	}
+
+	return patterns, nil
+}
+
+// halftoneRegion holds the parameters of the halftone region decoding procedure (6.6.2).
+type halftoneRegion struct {
+	w, h       int
+	mmr        bool
+	template   int
+	enableSkip bool
+	op         int
+	defPixel   byte
+	gw, gh     int
+	gx, gy     int
+	rx, ry     int
+	patterns   []*jbig2Bitmap
+}
+
+// gridPos returns the location of the pattern at grid position mg, ng.
+func (hr *halftoneRegion) gridPos(mg, ng int) (int, int) {
+	return (hr.gx + mg*hr.ry + ng*hr.rx) >> 8, (hr.gy + mg*hr.rx - ng*hr.ry) >> 8
+}
+
+// decode decodes a halftone region (6.6.5).
+func (hr *halftoneRegion) decode(data []byte) (*jbig2Bitmap, error) {
+	if len(hr.patterns) == 0 {
+		return nil, errors.New("pdfcpu: jbig2: halftone region without patterns")
+	}
+
+	b, err := newJBIG2Bitmap(hr.w, hr.h)
+	if err != nil {
+		return nil, err
+	}
+	if hr.defPixel == 1 {
+		b.fill(1)
+	}
+
+	pw, ph := hr.patterns[0].w, hr.patterns[0].h
+
+	var skip *jbig2Bitmap
+	if hr.enableSkip {
+		if skip, err = newJBIG2Bitmap(hr.gw, hr.gh); err != nil {
+			return nil, err
+		}
+		for mg := 0; mg < hr.gh; mg++ {
+			for ng := 0; ng < hr.gw; ng++ {
+				x, y := hr.gridPos(mg, ng)
+				if x+pw <= 0 || x >= hr.w || y+ph <= 0 || y >= hr.h {
+					skip.set(ng, mg, 1)
+				}
+			}
+		}
+	}
+
+	gray, err := hr.decodeGrayScaleImage(data, ceilLog2(len(hr.patterns)), skip)
+	if err != nil {
+		return nil, err
+	}
+
+	for mg := 0; mg < hr.gh; mg++ {
+		for ng := 0; ng < hr.gw; ng++ {
+			i := gray[mg*hr.gw+ng]
+			if i >= len(hr.patterns) {
+				i = len(hr.patterns) - 1
+			}
+			x, y := hr.gridPos(mg, ng)
+			b.compose(hr.patterns[i], x, y, hr.op)
+		}
+	}
+
+	return b, nil
+}
+
+// decodeGrayScaleImage decodes the gray-scale image of the pattern indices (C.5).
+func (hr *halftoneRegion) decodeGrayScaleImage(data []byte, bpp int, skip *jbig2Bitmap) ([]int, error) {
+	if hr.gw < 0 || hr.gh < 0 || (hr.gw > 0 && hr.gh > maxJBIG2Pixels/hr.gw) {
+		return nil, errors.New("pdfcpu: jbig2: invalid halftone grid")
+	}
+
+	gray := make([]int, hr.gw*hr.gh)
+	if bpp == 0 {
+		return gray, nil
+	}
+
+	if hr.mmr && bpp > 1 {
+		return nil, errors.New("pdfcpu: jbig2: MMR coded gray-scale images with more than one bitplane are unsupported")
+	}
+
+	at := [4][2]int{{3, -1}, {-3, -1}, {2, -2}, {-2, -2}}
+	if hr.template > 1 {
+		at[0][0] = 2
+	}
+
+	var (
+		d  *mqDecoder
+		cx []uint8
+	)
+	if !hr.mmr {
+		d, cx = newMQDecoder(data), make([]uint8, 1<<16)
+	}
+
+	var prev *jbig2Bitmap
+
+	for j := bpp - 1; j >= 0; j-- {
+		var (
+			plane *jbig2Bitmap
+			err   error
+		)
+		if hr.mmr {
+			plane, err = decodeMMR(data, hr.gw, hr.gh)
+		} else {
+			g := &genericRegion{w: hr.gw, h: hr.gh, template: hr.template, at: at, skip: skip}
+			plane, err = g.decode(d, cx)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Gray code decoding
+		if prev != nil {
+			for i := range plane.pix {
+				plane.pix[i] ^= prev.pix[i]
+			}
+		}
+		prev = plane
+
+		for i, v := range plane.pix {
+			gray[i] |= int(v) << uint(j)
+		}
+	}
+
+	return gray, nil
+}
@@ -0,0 +1,282 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// JBIG2 Huffman decoding, see ITU-T T.88 Annex B.
+
+var errJBIG2EOD = errors.New("pdfcpu: jbig2: unexpected end of data")
+
+// jbig2BitReader reads bits most significant bit first.
+type jbig2BitReader struct {
+	data []byte
+	pos  int  // byte position
+	bit  uint // bit position within current byte
+}
+
+func (r *jbig2BitReader) readBit() (int, error) {
+	if r.pos >= len(r.data) {
+		return 0, errJBIG2EOD
+	}
+	b := int(r.data[r.pos]>>(7-r.bit)) & 1
+	if r.bit++; r.bit == 8 {
+		r.bit = 0
+		r.pos++
+	}
+	return b, nil
+}
+
+func (r *jbig2BitReader) readBits(n int) (int, error) {
+	v := 0
+	for i := 0; i < n; i++ {
+		b, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | b
+	}
+	return v, nil
+}
+
+// align skips to the next byte boundary.
+func (r *jbig2BitReader) align() {
+	if r.bit > 0 {
+		r.bit = 0
+		r.pos++
+	}
+}
+
+// bytes returns the next n bytes after aligning to a byte boundary.
+func (r *jbig2BitReader) bytes(n int) ([]byte, error) {
+	r.align()
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, errJBIG2EOD
+	}
+	bb := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return bb, nil
+}
+
+// huffmanLine is a line of a Huffman table (B.2).
+type huffmanLine struct {
+	rangeLow, prefLen, rangeLen int
+	lower                       bool // lower range line, values are rangeLow - offset
+	oob                         bool // out-of-band line
+}
+
+type huffmanCode struct {
+	len  int
+	code int
+}
+
+// huffmanTable is a Huffman table with assigned prefix codes.
+type huffmanTable struct {
+	codes map[huffmanCode]*huffmanLine
+	max   int // maximum prefix length
+}
+
+// newHuffmanTable assigns the prefix codes for lines (B.3).
+func newHuffmanTable(lines []huffmanLine) (*huffmanTable, error) {
+	t := &huffmanTable{codes: map[huffmanCode]*huffmanLine{}}
+
+	var lenCount [33]int
+	for _, l := range lines {
+		if l.prefLen < 0 || l.prefLen > 32 {
+			return nil, errors.Errorf("pdfcpu: jbig2: invalid Huffman prefix length %d", l.prefLen)
+		}
+		lenCount[l.prefLen]++
+		if l.prefLen > t.max {
+			t.max = l.prefLen
+		}
+	}
+	lenCount[0] = 0
+
+	firstCode := 0
+	for curLen := 1; curLen <= t.max; curLen++ {
+		firstCode = (firstCode + lenCount[curLen-1]) << 1
+		code := firstCode
+		for i := range lines {
+			if lines[i].prefLen == curLen {
+				t.codes[huffmanCode{curLen, code}] = &lines[i]
+				code++
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// decode decodes a value. ok is false for OOB.
+func (t *huffmanTable) decode(r *jbig2BitReader) (v int, ok bool, err error) {
+	code := 0
+	for n := 1; n <= t.max; n++ {
+		b, err := r.readBit()
+		if err != nil {
+			return 0, false, err
+		}
+		code = code<<1 | b
+		l, found := t.codes[huffmanCode{n, code}]
+		if !found {
+			continue
+		}
+		if l.oob {
+			return 0, false, nil
+		}
+		offset, err := r.readBits(l.rangeLen)
+		if err != nil {
+			return 0, false, err
+		}
+		if l.lower {
+			return l.rangeLow - offset, true, nil
+		}
+		return l.rangeLow + offset, true, nil
+	}
+	return 0, false, errors.New("pdfcpu: jbig2: invalid Huffman code")
+}
+
+// standardHuffmanLines returns the lines of a standard table given as rangeLow, prefLen, rangeLen triples,
+// followed by the optional lower and upper range lines and the OOB line.
+func standardHuffmanLines(ll [][3]int, lower, upper *[3]int, oob int) []huffmanLine {
+	var lines []huffmanLine
+	for _, l := range ll {
+		lines = append(lines, huffmanLine{rangeLow: l[0], prefLen: l[1], rangeLen: l[2]})
+	}
+	if lower != nil {
+		lines = append(lines, huffmanLine{rangeLow: lower[0], prefLen: lower[1], rangeLen: lower[2], lower: true})
+	}
+	if upper != nil {
+		lines = append(lines, huffmanLine{rangeLow: upper[0], prefLen: upper[1], rangeLen: upper[2]})
+	}
+	if oob > 0 {
+		lines = append(lines, huffmanLine{prefLen: oob, oob: true})
+	}
+	return lines
+}
+
+// standardHuffmanTables are the Huffman tables B.1 - B.15 (B.5).
+var standardHuffmanTables = func() [16]*huffmanTable {
+	var tt [16]*huffmanTable
+
+	add := func(i int, ll [][3]int, lower, upper *[3]int, oob int) {
+		t, err := newHuffmanTable(standardHuffmanLines(ll, lower, upper, oob))
+		if err != nil {
+			panic(err)
+		}
+		tt[i] = t
+	}
+
+	add(1, [][3]int{{0, 1, 4}, {16, 2, 8}, {272, 3, 16}},
+		nil, &[3]int{65808, 3, 32}, 0)
+	add(2, [][3]int{{0, 1, 0}, {1, 2, 0}, {2, 3, 0}, {3, 4, 3}, {11, 5, 6}},
+		nil, &[3]int{75, 6, 32}, 6)
+	add(3, [][3]int{{-256, 8, 8}, {0, 1, 0}, {1, 2, 0}, {2, 3, 0}, {3, 4, 3}, {11, 5, 6}},
+		&[3]int{-257, 8, 32}, &[3]int{75, 7, 32}, 6)
+	add(4, [][3]int{{1, 1, 0}, {2, 2, 0}, {3, 3, 0}, {4, 4, 3}, {12, 5, 6}},
+		nil, &[3]int{76, 5, 32}, 0)
+	add(5, [][3]int{{-255, 7, 8}, {1, 1, 0}, {2, 2, 0}, {3, 3, 0}, {4, 4, 3}, {12, 5, 6}},
+		&[3]int{-256, 7, 32}, &[3]int{76, 6, 32}, 0)
+	add(6, [][3]int{{-2048, 5, 10}, {-1024, 4, 9}, {-512, 4, 8}, {-256, 4, 7}, {-128, 5, 6}, {-64, 5, 5},
+		{-32, 4, 5}, {0, 2, 7}, {128, 3, 7}, {256, 3, 8}, {512, 4, 9}, {1024, 4, 10}},
+		&[3]int{-2049, 6, 32}, &[3]int{2048, 6, 32}, 0)
+	add(7, [][3]int{{-1024, 4, 9}, {-512, 3, 8}, {-256, 4, 7}, {-128, 5, 6}, {-64, 5, 5}, {-32, 4, 5},
+		{0, 4, 5}, {32, 5, 5}, {64, 5, 6}, {128, 4, 7}, {256, 3, 8}, {512, 3, 9}, {1024, 3, 10}},
+		&[3]int{-1025, 5, 32}, &[3]int{2048, 5, 32}, 0)
+	add(8, [][3]int{{-15, 8, 3}, {-7, 9, 1}, {-5, 8, 1}, {-3, 9, 0}, {-2, 7, 0}, {-1, 4, 0}, {0, 2, 1},
+		{2, 5, 0}, {3, 6, 0}, {4, 3, 4}, {20, 6, 1}, {22, 4, 4}, {38, 4, 5}, {70, 5, 6}, {134, 5, 7},
+		{262, 6, 7}, {390, 7, 8}, {646, 6, 10}},
+		&[3]int{-16, 9, 32}, &[3]int{1670, 9, 32}, 2)
+	add(9, [][3]int{{-31, 8, 4}, {-15, 9, 2}, {-11, 8, 2}, {-7, 9, 1}, {-5, 7, 1}, {-3, 4, 1}, {-1, 3, 1},
+		{1, 3, 1}, {3, 5, 1}, {5, 6, 1}, {7, 3, 5}, {39, 6, 2}, {43, 4, 5}, {75, 4, 6}, {139, 5, 7},
+		{267, 5, 8}, {523, 6, 8}, {779, 7, 9}, {1291, 6, 11}},
+		&[3]int{-32, 9, 32}, &[3]int{3339, 9, 32}, 2)
+	add(10, [][3]int{{-21, 7, 4}, {-5, 8, 0}, {-4, 7, 0}, {-3, 5, 0}, {-2, 2, 2}, {2, 5, 0}, {3, 6, 0},
+		{4, 7, 0}, {5, 8, 0}, {6, 2, 6}, {70, 5, 5}, {102, 6, 5}, {134, 6, 6}, {198, 6, 7}, {326, 6, 8},
+		{582, 6, 9}, {1094, 6, 10}, {2118, 7, 11}},
+		&[3]int{-22, 8, 32}, &[3]int{4166, 8, 32}, 2)
+	add(11, [][3]int{{1, 1, 0}, {2, 2, 1}, {4, 4, 0}, {5, 4, 1}, {7, 5, 1}, {9, 5, 2}, {13, 6, 2},
+		{17, 7, 2}, {21, 7, 3}, {29, 7, 4}, {45, 7, 5}, {77, 7, 6}},
+		nil, &[3]int{141, 7, 32}, 0)
+	add(12, [][3]int{{1, 1, 0}, {2, 2, 0}, {3, 3, 1}, {5, 5, 0}, {6, 5, 1}, {8, 6, 1}, {10, 7, 0},
+		{11, 7, 1}, {13, 7, 2}, {17, 7, 3}, {25, 7, 4}, {41, 8, 5}},
+		nil, &[3]int{73, 8, 32}, 0)
+	add(13, [][3]int{{1, 1, 0}, {2, 3, 0}, {3, 4, 0}, {4, 5, 0}, {5, 4, 1}, {7, 3, 3}, {15, 6, 1},
+		{17, 6, 2}, {21, 6, 3}, {29, 6, 4}, {45, 6, 5}, {77, 7, 6}},
+		nil, &[3]int{141, 7, 32}, 0)
+	add(14, [][3]int{{-2, 3, 0}, {-1, 3, 0}, {0, 1, 0}, {1, 3, 0}, {2, 3, 0}},
+		nil, nil, 0)
+	add(15, [][3]int{{-24, 7, 4}, {-8, 6, 2}, {-4, 5, 1}, {-2, 4, 0}, {-1, 3, 0}, {0, 1, 0}, {1, 3, 0},
+		{2, 4, 0}, {3, 5, 1}, {5, 6, 2}, {9, 7, 4}},
+		&[3]int{-25, 7, 32}, &[3]int{25, 7, 32}, 0)
+
+	return tt
+}()
+
+// parseHuffmanTable parses a table segment (7.4.13) using the procedure of B.2.
+func parseHuffmanTable(data []byte) (*huffmanTable, error) {
+	if len(data) < 9 {
+		return nil, errJBIG2EOD
+	}
+
+	flags := data[0]
+	hasOOB := flags&1 == 1
+	htps := int(flags>>1&7) + 1
+	htrs := int(flags>>4&7) + 1
+	low, high := int(int32(be32(data[1:]))), int(int32(be32(data[5:])))
+
+	r := &jbig2BitReader{data: data[9:]}
+
+	var lines []huffmanLine
+
+	for cur := low; cur < high; {
+		prefLen, err := r.readBits(htps)
+		if err != nil {
+			return nil, err
+		}
+		rangeLen, err := r.readBits(htrs)
+		if err != nil {
+			return nil, err
+		}
+		if rangeLen > 32 {
+			return nil, errors.New("pdfcpu: jbig2: invalid Huffman table")
+		}
+		lines = append(lines, huffmanLine{rangeLow: cur, prefLen: prefLen, rangeLen: rangeLen})
+		cur += 1 << uint(rangeLen)
+	}
+
+	prefLen, err := r.readBits(htps)
+	if err != nil {
+		return nil, err
+	}
+	lines = append(lines, huffmanLine{rangeLow: low - 1, prefLen: prefLen, rangeLen: 32, lower: true})
+
+	if prefLen, err = r.readBits(htps); err != nil {
+		return nil, err
+	}
+	lines = append(lines, huffmanLine{rangeLow: high, prefLen: prefLen, rangeLen: 32})
+
+	if hasOOB {
+		if prefLen, err = r.readBits(htps); err != nil {
+			return nil, err
+		}
+		lines = append(lines, huffmanLine{prefLen: prefLen, oob: true})
+	}
+
+	return newHuffmanTable(lines)
+}
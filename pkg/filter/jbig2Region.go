@@ -0,0 +1,323 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/image/ccitt"
+)
+
+// maxJBIG2Pixels limits the size of bitmaps allocated while decoding corrupt or malicious data.
+const maxJBIG2Pixels = 1 << 28
+
+// JBIG2 combination operators (7.4.8.5).
+const (
+	jbig2OpOr = iota
+	jbig2OpAnd
+	jbig2OpXor
+	jbig2OpXnor
+	jbig2OpReplace
+)
+
+// jbig2Bitmap is a bilevel image using one byte per pixel where 1 means black.
+type jbig2Bitmap struct {
+	w, h int
+	pix  []byte
+}
+
+func newJBIG2Bitmap(w, h int) (*jbig2Bitmap, error) {
+	if w < 0 || h < 0 || (w > 0 && h > maxJBIG2Pixels/w) {
+		return nil, errors.Errorf("pdfcpu: jbig2: invalid bitmap size %dx%d", w, h)
+	}
+	return &jbig2Bitmap{w: w, h: h, pix: make([]byte, w*h)}, nil
+}
+
+// at returns the pixel at x,y or 0 for coordinates outside the bitmap.
+func (b *jbig2Bitmap) at(x, y int) int {
+	if x < 0 || y < 0 || x >= b.w || y >= b.h {
+		return 0
+	}
+	return int(b.pix[y*b.w+x])
+}
+
+func (b *jbig2Bitmap) set(x, y int, v int) {
+	if x < 0 || y < 0 || x >= b.w || y >= b.h {
+		return
+	}
+	b.pix[y*b.w+x] = byte(v)
+}
+
+func (b *jbig2Bitmap) fill(v byte) {
+	for i := range b.pix {
+		b.pix[i] = v
+	}
+}
+
+// copyRow copies row y-1 to row y.
+func (b *jbig2Bitmap) copyRow(y int) {
+	if y > 0 {
+		copy(b.pix[y*b.w:(y+1)*b.w], b.pix[(y-1)*b.w:y*b.w])
+	}
+}
+
+// compose combines src into b at x,y using op.
+func (b *jbig2Bitmap) compose(src *jbig2Bitmap, x, y, op int) {
+	for sy := 0; sy < src.h; sy++ {
+		dy := y + sy
+		if dy < 0 || dy >= b.h {
+			continue
+		}
+		for sx := 0; sx < src.w; sx++ {
+			dx := x + sx
+			if dx < 0 || dx >= b.w {
+				continue
+			}
+			s, d := src.pix[sy*src.w+sx], &b.pix[dy*b.w+dx]
+			switch op {
+			case jbig2OpOr:
+				*d |= s
+			case jbig2OpAnd:
+				*d &= s
+			case jbig2OpXor:
+				*d ^= s
+			case jbig2OpXnor:
+				*d = 1 ^ (*d ^ s)
+			default:
+				*d = s
+			}
+		}
+	}
+}
+
+// sub returns the w x h area of b at x,y.
+func (b *jbig2Bitmap) sub(x, y, w, h int) (*jbig2Bitmap, error) {
+	b1, err := newJBIG2Bitmap(w, h)
+	if err != nil {
+		return nil, err
+	}
+	for j := 0; j < h; j++ {
+		for i := 0; i < w; i++ {
+			b1.pix[j*w+i] = byte(b.at(x+i, y+j))
+		}
+	}
+	return b1, nil
+}
+
+// bytes returns the packed rows of b, most significant bit first, with 0 meaning black
+// as expected for PDF image samples.
+func (b *jbig2Bitmap) bytes() []byte {
+	stride := (b.w + 7) / 8
+	bb := make([]byte, stride*b.h)
+	for y := 0; y < b.h; y++ {
+		row := bb[y*stride : (y+1)*stride]
+		for x, v := range b.pix[y*b.w : (y+1)*b.w] {
+			if v != 0 {
+				row[x>>3] |= 0x80 >> uint(x&7)
+			}
+		}
+		for i := range row {
+			row[i] ^= 0xFF
+		}
+	}
+	return bb
+}
+
+// decodeMMR decodes a w x h bitmap using CCITT Group 4 (6.2.6).
+func decodeMMR(data []byte, w, h int) (*jbig2Bitmap, error) {
+	b, err := newJBIG2Bitmap(w, h)
+	if err != nil || w == 0 || h == 0 {
+		return b, err
+	}
+
+	stride := (w + 7) / 8
+	bb := make([]byte, stride*h)
+
+	r := ccitt.NewReader(bytes.NewReader(data), ccitt.MSB, ccitt.Group4, w, h, &ccitt.Options{Invert: true})
+	if _, err := io.ReadFull(r, bb); err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: jbig2: MMR")
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			b.pix[y*w+x] = (bb[y*stride+x>>3] >> (7 - uint(x&7))) & 1
+		}
+	}
+
+	return b, nil
+}
+
+// genericRegion holds the parameters of the generic region decoding procedure (6.2.2).
+type genericRegion struct {
+	w, h     int
+	template int
+	tpgdon   bool
+	at       [4][2]int
+	skip     *jbig2Bitmap
+}
+
+// tpgdonContexts are the contexts used for decoding SLTP per template (6.2.5.7).
+var tpgdonContexts = [4]int{0x9B25, 0x0795, 0x00E5, 0x0195}
+
+// context returns the context of pixel x,y of b (6.2.5.3).
+func (g *genericRegion) context(b *jbig2Bitmap, x, y int) int {
+	at := g.at
+
+	switch g.template {
+
+	case 0:
+		return b.at(x-1, y) | b.at(x-2, y)<<1 | b.at(x-3, y)<<2 | b.at(x-4, y)<<3 |
+			b.at(x+at[0][0], y+at[0][1])<<4 |
+			b.at(x+2, y-1)<<5 | b.at(x+1, y-1)<<6 | b.at(x, y-1)<<7 | b.at(x-1, y-1)<<8 | b.at(x-2, y-1)<<9 |
+			b.at(x+at[1][0], y+at[1][1])<<10 | b.at(x+at[2][0], y+at[2][1])<<11 |
+			b.at(x+1, y-2)<<12 | b.at(x, y-2)<<13 | b.at(x-1, y-2)<<14 |
+			b.at(x+at[3][0], y+at[3][1])<<15
+
+	case 1:
+		return b.at(x-1, y) | b.at(x-2, y)<<1 | b.at(x-3, y)<<2 |
+			b.at(x+at[0][0], y+at[0][1])<<3 |
+			b.at(x+2, y-1)<<4 | b.at(x+1, y-1)<<5 | b.at(x, y-1)<<6 | b.at(x-1, y-1)<<7 | b.at(x-2, y-1)<<8 |
+			b.at(x+2, y-2)<<9 | b.at(x+1, y-2)<<10 | b.at(x, y-2)<<11 | b.at(x-1, y-2)<<12
+
+	case 2:
+		return b.at(x-1, y) | b.at(x-2, y)<<1 |
+			b.at(x+at[0][0], y+at[0][1])<<2 |
+			b.at(x+1, y-1)<<3 | b.at(x, y-1)<<4 | b.at(x-1, y-1)<<5 | b.at(x-2, y-1)<<6 |
+			b.at(x+1, y-2)<<7 | b.at(x, y-2)<<8 | b.at(x-1, y-2)<<9
+
+	default:
+		return b.at(x-1, y) | b.at(x-2, y)<<1 | b.at(x-3, y)<<2 | b.at(x-4, y)<<3 |
+			b.at(x+at[0][0], y+at[0][1])<<4 |
+			b.at(x+1, y-1)<<5 | b.at(x, y-1)<<6 | b.at(x-1, y-1)<<7 | b.at(x-2, y-1)<<8 | b.at(x-3, y-1)<<9
+	}
+}
+
+// decode decodes a generic region using arithmetic decoding (6.2.5).
+func (g *genericRegion) decode(d *mqDecoder, cx []uint8) (*jbig2Bitmap, error) {
+	b, err := newJBIG2Bitmap(g.w, g.h)
+	if err != nil {
+		return nil, err
+	}
+
+	ltp := 0
+
+	for y := 0; y < g.h; y++ {
+
+		if g.tpgdon {
+			ltp ^= d.decode(cx, tpgdonContexts[g.template])
+			if ltp == 1 {
+				b.copyRow(y)
+				continue
+			}
+		}
+
+		for x := 0; x < g.w; x++ {
+
+			if g.skip != nil && g.skip.at(x, y) == 1 {
+				continue
+			}
+
+			if d.decode(cx, g.context(b, x, y)) == 1 {
+				b.pix[y*g.w+x] = 1
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// refinementRegion holds the parameters of the generic refinement region decoding procedure (6.3.2).
+type refinementRegion struct {
+	w, h     int
+	template int
+	ref      *jbig2Bitmap
+	dx, dy   int
+	tpgron   bool
+	at       [2][2]int
+}
+
+// refinementContext returns the context of pixel x,y of b (6.3.5.3).
+func (g *refinementRegion) context(b *jbig2Bitmap, x, y int) int {
+	r, rx, ry, at := g.ref, x-g.dx, y-g.dy, g.at
+
+	if g.template == 0 {
+		return b.at(x-1, y) | b.at(x+1, y-1)<<1 | b.at(x, y-1)<<2 |
+			b.at(x+at[0][0], y+at[0][1])<<3 |
+			r.at(rx+1, ry+1)<<4 | r.at(rx, ry+1)<<5 | r.at(rx-1, ry+1)<<6 |
+			r.at(rx+1, ry)<<7 | r.at(rx, ry)<<8 | r.at(rx-1, ry)<<9 |
+			r.at(rx+1, ry-1)<<10 | r.at(rx, ry-1)<<11 |
+			r.at(rx+at[1][0], ry+at[1][1])<<12
+	}
+
+	return b.at(x-1, y) | b.at(x+1, y-1)<<1 | b.at(x, y-1)<<2 | b.at(x-1, y-1)<<3 |
+		r.at(rx+1, ry+1)<<4 | r.at(rx, ry+1)<<5 |
+		r.at(rx+1, ry)<<6 | r.at(rx, ry)<<7 | r.at(rx-1, ry)<<8 |
+		r.at(rx, ry-1)<<9
+}
+
+// typicalPixel returns the value of the 3x3 neighbourhood of rx,ry in the reference bitmap
+// if all its pixels are equal (6.3.5.6).
+func (g *refinementRegion) typicalPixel(rx, ry int) (int, bool) {
+	v := g.ref.at(rx, ry)
+	for j := -1; j <= 1; j++ {
+		for i := -1; i <= 1; i++ {
+			if g.ref.at(rx+i, ry+j) != v {
+				return 0, false
+			}
+		}
+	}
+	return v, true
+}
+
+// decode decodes a refinement region using arithmetic decoding (6.3.5).
+func (g *refinementRegion) decode(d *mqDecoder, cx []uint8) (*jbig2Bitmap, error) {
+	b, err := newJBIG2Bitmap(g.w, g.h)
+	if err != nil {
+		return nil, err
+	}
+
+	// The SLTP context has only the reference pixel corresponding to the current pixel set.
+	sltp := 0x100
+	if g.template == 1 {
+		sltp = 0x080
+	}
+
+	ltp := 0
+
+	for y := 0; y < g.h; y++ {
+
+		if g.tpgron {
+			ltp ^= d.decode(cx, sltp)
+		}
+
+		for x := 0; x < g.w; x++ {
+			if ltp == 1 {
+				if v, ok := g.typicalPixel(x-g.dx, y-g.dy); ok {
+					b.pix[y*g.w+x] = byte(v)
+					continue
+				}
+			}
+			if d.decode(cx, g.context(b, x, y)) == 1 {
+				b.pix[y*g.w+x] = 1
+			}
+		}
+	}
+
+	return b, nil
+}
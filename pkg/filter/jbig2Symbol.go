@@ -0,0 +1,502 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Reference corners of text region symbol instances (7.4.3.1.1).
+const (
+	jbig2BottomLeft = iota
+	jbig2TopLeft
+	jbig2BottomRight
+	jbig2TopRight
+)
+
+// jbig2Contexts holds the arithmetic decoding contexts of a segment.
+type jbig2Contexts struct {
+	gb, gr, iaid                                                                     []uint8
+	iadh, iadw, iaex, iaai, iadt, iafs, iads, iait, iari, iardw, iardh, iardx, iardy intContexts
+}
+
+func newJBIG2Contexts() *jbig2Contexts {
+	return &jbig2Contexts{gb: make([]uint8, 1<<16), gr: make([]uint8, 1<<13)}
+}
+
+func (cx *jbig2Contexts) iaidContexts(codeLen int) []uint8 {
+	if len(cx.iaid) != 1<<uint(codeLen+1) {
+		cx.iaid = make([]uint8, 1<<uint(codeLen+1))
+	}
+	return cx.iaid
+}
+
+// jbig2IntDecoder decodes integers using either arithmetic or Huffman decoding.
+type jbig2IntDecoder struct {
+	ad *mqDecoder
+	hr *jbig2BitReader
+	cx *jbig2Contexts
+}
+
+func (d *jbig2IntDecoder) huffman() bool {
+	return d.hr != nil
+}
+
+// decode decodes an integer. ok is false for OOB.
+func (d *jbig2IntDecoder) decode(acx *intContexts, t *huffmanTable) (int, bool, error) {
+	if d.hr != nil {
+		if t == nil {
+			return 0, false, errors.New("pdfcpu: jbig2: missing Huffman table")
+		}
+		return t.decode(d.hr)
+	}
+	v, ok := d.ad.decodeInt(acx)
+	return v, ok, nil
+}
+
+// decodeValue decodes an integer treating OOB as an error.
+func (d *jbig2IntDecoder) decodeValue(acx *intContexts, t *huffmanTable) (int, error) {
+	v, ok, err := d.decode(acx, t)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, errors.New("pdfcpu: jbig2: unexpected OOB")
+	}
+	return v, nil
+}
+
+// decodeID decodes a symbol ID using codeLen bits or the symbol ID Huffman table t.
+func (d *jbig2IntDecoder) decodeID(codeLen int, t *huffmanTable) (int, error) {
+	if d.hr == nil {
+		return d.ad.decodeIAID(d.cx.iaidContexts(codeLen), codeLen), nil
+	}
+	if t != nil {
+		return d.decodeValue(nil, t)
+	}
+	return d.hr.readBits(codeLen)
+}
+
+// refine decodes a refinement of ref with size w x h.
+// In Huffman mode the arithmetically coded refinement data is embedded into the bit stream.
+func (d *jbig2IntDecoder) refine(ref *jbig2Bitmap, w, h, dx, dy, template int, at [2][2]int, rsize *huffmanTable) (*jbig2Bitmap, error) {
+	g := &refinementRegion{w: w, h: h, template: template, ref: ref, dx: dx, dy: dy, at: at}
+
+	if d.hr == nil {
+		return g.decode(d.ad, d.cx.gr)
+	}
+
+	n, err := d.decodeValue(nil, rsize)
+	if err != nil {
+		return nil, err
+	}
+	bb, err := d.hr.bytes(n)
+	if err != nil {
+		return nil, err
+	}
+	return g.decode(newMQDecoder(bb), d.cx.gr)
+}
+
+func ceilLog2(n int) int {
+	l := 0
+	for 1<<uint(l) < n {
+		l++
+	}
+	return l
+}
+
+// textRegion holds the parameters of the text region decoding procedure (6.4.2).
+type textRegion struct {
+	w, h         int
+	refine       bool
+	numInstances int
+	logStrips    int
+	syms         []*jbig2Bitmap
+	symCodeLen   int
+	symCodes     *huffmanTable
+	defPixel     byte
+	op           int
+	transposed   bool
+	refCorner    int
+	dsOffset     int
+	rTemplate    int
+	rat          [2][2]int
+
+	// Huffman tables
+	fs, ds, dt, rdw, rdh, rdx, rdy, rsize *huffmanTable
+}
+
+// decode decodes a text region (6.4.5).
+func (tr *textRegion) decode(d *jbig2IntDecoder) (*jbig2Bitmap, error) {
+	b, err := newJBIG2Bitmap(tr.w, tr.h)
+	if err != nil {
+		return nil, err
+	}
+	if tr.defPixel == 1 {
+		b.fill(1)
+	}
+
+	cx := d.cx
+	strips := 1 << uint(tr.logStrips)
+
+	stripT, err := d.decodeValue(&cx.iadt, tr.dt)
+	if err != nil {
+		return nil, err
+	}
+	stripT *= -strips
+
+	firstS, n := 0, 0
+
+	for n < tr.numInstances {
+
+		dt, err := d.decodeValue(&cx.iadt, tr.dt)
+		if err != nil {
+			return nil, err
+		}
+		stripT += dt * strips
+
+		var curS int
+
+		for first := true; ; first = false {
+
+			if first {
+				dfs, err := d.decodeValue(&cx.iafs, tr.fs)
+				if err != nil {
+					return nil, err
+				}
+				firstS += dfs
+				curS = firstS
+			} else {
+				ids, ok, err := d.decode(&cx.iads, tr.ds)
+				if err != nil {
+					return nil, err
+				}
+				if !ok || n >= tr.numInstances {
+					break
+				}
+				curS += ids + tr.dsOffset
+			}
+
+			curT := 0
+			if strips > 1 {
+				if d.huffman() {
+					curT, err = d.hr.readBits(tr.logStrips)
+				} else {
+					curT, err = d.decodeValue(&cx.iait, nil)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+			t := stripT + curT
+
+			id, err := d.decodeID(tr.symCodeLen, tr.symCodes)
+			if err != nil {
+				return nil, err
+			}
+			if id < 0 || id >= len(tr.syms) {
+				return nil, errors.Errorf("pdfcpu: jbig2: invalid symbol ID %d", id)
+			}
+
+			ib := tr.syms[id]
+
+			ri := 0
+			if tr.refine {
+				if d.huffman() {
+					ri, err = d.hr.readBit()
+				} else {
+					ri, err = d.decodeValue(&cx.iari, nil)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if ri != 0 {
+				if ib, err = tr.refineSymbol(d, ib); err != nil {
+					return nil, err
+				}
+			}
+
+			if !tr.transposed && (tr.refCorner == jbig2TopRight || tr.refCorner == jbig2BottomRight) {
+				curS += ib.w - 1
+			}
+			if tr.transposed && (tr.refCorner == jbig2BottomLeft || tr.refCorner == jbig2BottomRight) {
+				curS += ib.h - 1
+			}
+
+			x, y := curS, t
+			if tr.transposed {
+				x, y = t, curS
+			}
+			if tr.refCorner == jbig2TopRight || tr.refCorner == jbig2BottomRight {
+				x -= ib.w - 1
+			}
+			if tr.refCorner == jbig2BottomLeft || tr.refCorner == jbig2BottomRight {
+				y -= ib.h - 1
+			}
+
+			b.compose(ib, x, y, tr.op)
+
+			if !tr.transposed && (tr.refCorner == jbig2TopLeft || tr.refCorner == jbig2BottomLeft) {
+				curS += ib.w - 1
+			}
+			if tr.transposed && (tr.refCorner == jbig2TopLeft || tr.refCorner == jbig2TopRight) {
+				curS += ib.h - 1
+			}
+
+			n++
+		}
+	}
+
+	return b, nil
+}
+
+// refineSymbol decodes a refinement of symbol ib (6.4.11).
+func (tr *textRegion) refineSymbol(d *jbig2IntDecoder, ib *jbig2Bitmap) (*jbig2Bitmap, error) {
+	cx := d.cx
+	var rd [4]int
+	for i, c := range []struct {
+		acx *intContexts
+		t   *huffmanTable
+	}{{&cx.iardw, tr.rdw}, {&cx.iardh, tr.rdh}, {&cx.iardx, tr.rdx}, {&cx.iardy, tr.rdy}} {
+		v, err := d.decodeValue(c.acx, c.t)
+		if err != nil {
+			return nil, err
+		}
+		rd[i] = v
+	}
+	rdw, rdh, rdx, rdy := rd[0], rd[1], rd[2], rd[3]
+
+	return d.refine(ib, ib.w+rdw, ib.h+rdh, rdw>>1+rdx, rdh>>1+rdy, tr.rTemplate, tr.rat, tr.rsize)
+}
+
+// symbolDict holds the parameters of the symbol dictionary decoding procedure (6.5.2).
+type symbolDict struct {
+	refAgg     bool
+	inSyms     []*jbig2Bitmap
+	numNewSyms int
+	numExSyms  int
+	template   int
+	at         [4][2]int
+	rTemplate  int
+	rat        [2][2]int
+
+	// Huffman tables
+	dh, dw, bmSize, aggInst *huffmanTable
+}
+
+// decode decodes a symbol dictionary and returns the exported symbols (6.5.5).
+func (sd *symbolDict) decode(d *jbig2IntDecoder) ([]*jbig2Bitmap, error) {
+	cx := d.cx
+	numSyms := len(sd.inSyms) + sd.numNewSyms
+	symCodeLen := ceilLog2(numSyms)
+	if symCodeLen > 24 {
+		return nil, errors.New("pdfcpu: jbig2: too many symbols")
+	}
+
+	newSyms := make([]*jbig2Bitmap, 0, sd.numNewSyms)
+	hcHeight := 0
+
+	for len(newSyms) < sd.numNewSyms {
+
+		dh, err := d.decodeValue(&cx.iadh, sd.dh)
+		if err != nil {
+			return nil, err
+		}
+		if hcHeight += dh; hcHeight < 0 {
+			return nil, errors.New("pdfcpu: jbig2: invalid height class")
+		}
+
+		symWidth, totWidth, hcFirst := 0, 0, len(newSyms)
+		var widths []int
+
+		for {
+			dw, ok, err := d.decode(&cx.iadw, sd.dw)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			if len(newSyms) >= sd.numNewSyms {
+				return nil, errors.New("pdfcpu: jbig2: too many symbols in height class")
+			}
+			if symWidth += dw; symWidth < 0 {
+				return nil, errors.New("pdfcpu: jbig2: invalid symbol width")
+			}
+			totWidth += symWidth
+
+			if d.huffman() && !sd.refAgg {
+				// Symbols of this height class are stored in a collective bitmap.
+				widths = append(widths, symWidth)
+				newSyms = append(newSyms, nil)
+				continue
+			}
+
+			var bm *jbig2Bitmap
+
+			if !sd.refAgg {
+				g := &genericRegion{w: symWidth, h: hcHeight, template: sd.template, at: sd.at}
+				if bm, err = g.decode(d.ad, cx.gb); err != nil {
+					return nil, err
+				}
+			} else if bm, err = sd.decodeAggregate(d, symWidth, hcHeight, symCodeLen, newSyms); err != nil {
+				return nil, err
+			}
+
+			newSyms = append(newSyms, bm)
+		}
+
+		if d.huffman() && !sd.refAgg {
+			if err := sd.decodeCollectiveBitmap(d, newSyms[hcFirst:], widths, totWidth, hcHeight); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Export flags
+	exSyms := make([]*jbig2Bitmap, 0, sd.numExSyms)
+	for i, runs, export := 0, 0, false; i < numSyms; export = !export {
+		if runs++; runs > 2*numSyms+1 {
+			return nil, errors.New("pdfcpu: jbig2: invalid export flags")
+		}
+		run, err := d.decodeValue(&cx.iaex, standardHuffmanTables[1])
+		if err != nil {
+			return nil, err
+		}
+		if run < 0 || i+run > numSyms {
+			return nil, errors.New("pdfcpu: jbig2: invalid export run length")
+		}
+		if export {
+			for j := i; j < i+run; j++ {
+				if j < len(sd.inSyms) {
+					exSyms = append(exSyms, sd.inSyms[j])
+				} else {
+					exSyms = append(exSyms, newSyms[j-len(sd.inSyms)])
+				}
+			}
+		}
+		i += run
+	}
+
+	return exSyms, nil
+}
+
+// decodeAggregate decodes a symbol bitmap using refinement/aggregate coding (6.5.8.2).
+func (sd *symbolDict) decodeAggregate(d *jbig2IntDecoder, w, h, symCodeLen int, newSyms []*jbig2Bitmap) (*jbig2Bitmap, error) {
+	cx := d.cx
+
+	n, err := d.decodeValue(&cx.iaai, sd.aggInst)
+	if err != nil {
+		return nil, err
+	}
+
+	syms := append(append([]*jbig2Bitmap{}, sd.inSyms...), newSyms...)
+
+	if n > 1 {
+		tr := &textRegion{
+			w:            w,
+			h:            h,
+			refine:       true,
+			numInstances: n,
+			syms:         syms,
+			symCodeLen:   symCodeLen,
+			op:           jbig2OpOr,
+			refCorner:    jbig2TopLeft,
+			rTemplate:    sd.rTemplate,
+			rat:          sd.rat,
+			fs:           standardHuffmanTables[6],
+			ds:           standardHuffmanTables[8],
+			dt:           standardHuffmanTables[11],
+			rdw:          standardHuffmanTables[15],
+			rdh:          standardHuffmanTables[15],
+			rdx:          standardHuffmanTables[15],
+			rdy:          standardHuffmanTables[15],
+			rsize:        standardHuffmanTables[1],
+		}
+		return tr.decode(d)
+	}
+
+	if n != 1 {
+		return nil, errors.Errorf("pdfcpu: jbig2: invalid number of aggregate instances %d", n)
+	}
+
+	id, err := d.decodeID(symCodeLen, nil)
+	if err != nil {
+		return nil, err
+	}
+	if id < 0 || id >= len(syms) {
+		return nil, errors.Errorf("pdfcpu: jbig2: invalid symbol ID %d", id)
+	}
+
+	rdx, err := d.decodeValue(&cx.iardx, standardHuffmanTables[15])
+	if err != nil {
+		return nil, err
+	}
+	rdy, err := d.decodeValue(&cx.iardy, standardHuffmanTables[15])
+	if err != nil {
+		return nil, err
+	}
+
+	return d.refine(syms[id], w, h, rdx, rdy, sd.rTemplate, sd.rat, standardHuffmanTables[1])
+}
+
+// decodeCollectiveBitmap decodes the collective bitmap of a height class and splits it into syms (6.5.9).
+func (sd *symbolDict) decodeCollectiveBitmap(d *jbig2IntDecoder, syms []*jbig2Bitmap, widths []int, totWidth, h int) error {
+	bmSize, err := d.decodeValue(nil, sd.bmSize)
+	if err != nil {
+		return err
+	}
+
+	var bm *jbig2Bitmap
+
+	if bmSize == 0 {
+		// Uncompressed
+		stride := (totWidth + 7) / 8
+		bb, err := d.hr.bytes(stride * h)
+		if err != nil {
+			return err
+		}
+		if bm, err = newJBIG2Bitmap(totWidth, h); err != nil {
+			return err
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < totWidth; x++ {
+				bm.pix[y*totWidth+x] = (bb[y*stride+x>>3] >> (7 - uint(x&7))) & 1
+			}
+		}
+	} else {
+		bb, err := d.hr.bytes(bmSize)
+		if err != nil {
+			return err
+		}
+		if bm, err = decodeMMR(bb, totWidth, h); err != nil {
+			return err
+		}
+	}
+
+	x := 0
+	for i, w := range widths {
+		if syms[i], err = bm.sub(x, 0, w, h); err != nil {
+			return err
+		}
+		x += w
+	}
+
+	return nil
+}
@@ -0,0 +1,353 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+// JPEG 2000 code-block decoding (tier-1), see ITU-T T.800 Annex D.
+
+// Context labels (D.3).
+const (
+	jpxCtxMR      = 14
+	jpxCtxRL      = 17
+	jpxCtxUniform = 18
+)
+
+// Coefficient state flags.
+const (
+	jpxSig     = 1
+	jpxVisited = 2
+	jpxRefined = 4
+	jpxNeg     = 8
+)
+
+// jpxZCContexts maps the significance of neighbours to zero coding contexts (Table D.1).
+// The index holds the number of significant horizontal neighbours in bits 0-1,
+// vertical neighbours in bits 2-3 and diagonal neighbours in bits 4-6.
+// Table 0 is used for LL and LH, table 1 for HL and table 2 for HH subbands.
+var jpxZCContexts = func() (t [3][128]uint8) {
+	zc := func(h, v, d int) uint8 {
+		switch {
+		case h == 2:
+			return 8
+		case h == 1 && v >= 1:
+			return 7
+		case h == 1 && d >= 1:
+			return 6
+		case h == 1:
+			return 5
+		case v == 2:
+			return 4
+		case v == 1:
+			return 3
+		case d >= 2:
+			return 2
+		}
+		return uint8(d)
+	}
+	for i := range t[0] {
+		h, v, d := i&3, (i>>2)&3, i>>4
+		if h > 2 || v > 2 || d > 4 {
+			continue
+		}
+		t[0][i], t[1][i] = zc(h, v, d), zc(v, h, d)
+		hv := h + v
+		switch {
+		case d >= 3:
+			t[2][i] = 8
+		case d == 2 && hv >= 1:
+			t[2][i] = 7
+		case d == 2:
+			t[2][i] = 6
+		case d == 1 && hv >= 2:
+			t[2][i] = 5
+		case d == 1:
+			t[2][i] = 3 + uint8(hv)
+		case hv >= 2:
+			t[2][i] = 2
+		default:
+			t[2][i] = uint8(hv)
+		}
+	}
+	return t
+}()
+
+// jpxSCContexts maps horizontal and vertical sign contributions (+1) to sign coding context and XOR bit (Table D.3).
+var jpxSCContexts = [3][3][2]int{
+	{{13, 1}, {12, 1}, {11, 1}},
+	{{10, 1}, {9, 0}, {10, 0}},
+	{{11, 0}, {12, 0}, {13, 0}},
+}
+
+// jpxBlock is the decoding state of a code-block.
+type jpxBlock struct {
+	w, h   int
+	stride int
+	vsc    bool
+	zc     *[128]uint8
+	flags  []uint8
+	nb     []uint8  // significant neighbours
+	mag    []uint32 // twice the reconstructed magnitude
+	cx     [19]uint8
+	mq     *mqDecoder
+}
+
+func (b *jpxBlock) resetContexts() {
+	b.cx = [19]uint8{}
+	b.cx[0] = 4 << 1
+	b.cx[jpxCtxRL] = 3 << 1
+	b.cx[jpxCtxUniform] = 46 << 1
+}
+
+// setSignificant marks the coefficient at index i in row y significant and updates its neighbours.
+func (b *jpxBlock) setSignificant(i, y int, neg bool, p uint) {
+	b.flags[i] |= jpxSig
+	if neg {
+		b.flags[i] |= jpxNeg
+	}
+	b.mag[i] = 3 << p
+
+	s := b.stride
+	b.nb[i-1]++
+	b.nb[i+1]++
+	if !b.vsc || y%4 != 0 {
+		b.nb[i-s] += 4
+		b.nb[i-s-1] += 16
+		b.nb[i-s+1] += 16
+	}
+	b.nb[i+s] += 4
+	b.nb[i+s-1] += 16
+	b.nb[i+s+1] += 16
+}
+
+func (b *jpxBlock) signContribution(i int) int {
+	switch b.flags[i] & (jpxSig | jpxNeg) {
+	case jpxSig:
+		return 1
+	case jpxSig | jpxNeg:
+		return -1
+	}
+	return 0
+}
+
+func clampSign(v int) int {
+	if v > 1 {
+		return 1
+	}
+	if v < -1 {
+		return -1
+	}
+	return v
+}
+
+// decodeSign decodes the sign of the coefficient at index i in row y (D.3.2).
+func (b *jpxBlock) decodeSign(i, y int, raw *jpxBitReader) bool {
+	if raw != nil {
+		return raw.bit() == 1
+	}
+	s := b.stride
+	h := clampSign(b.signContribution(i-1) + b.signContribution(i+1))
+	v := b.signContribution(i - s)
+	if !b.vsc || y%4 != 3 {
+		v += b.signContribution(i + s)
+	}
+	c := jpxSCContexts[h+1][clampSign(v)+1]
+	return b.mq.decode(b.cx[:], c[0])^c[1] == 1
+}
+
+// significancePass is the significance propagation pass for bit-plane p (D.3.1).
+func (b *jpxBlock) significancePass(p uint, raw *jpxBitReader) {
+	for y0 := 0; y0 < b.h; y0 += 4 {
+		y1 := minInt(y0+4, b.h)
+		for x := 0; x < b.w; x++ {
+			for y := y0; y < y1; y++ {
+				i := (y+1)*b.stride + x + 1
+				if b.flags[i]&jpxSig != 0 || b.nb[i] == 0 {
+					continue
+				}
+				var bit int
+				if raw != nil {
+					bit = raw.bit()
+				} else {
+					bit = b.mq.decode(b.cx[:], int(b.zc[b.nb[i]]))
+				}
+				b.flags[i] |= jpxVisited
+				if bit == 1 {
+					b.setSignificant(i, y, b.decodeSign(i, y, raw), p)
+				}
+			}
+		}
+	}
+}
+
+// refinementPass is the magnitude refinement pass for bit-plane p (D.3.3).
+func (b *jpxBlock) refinementPass(p uint, raw *jpxBitReader) {
+	for y0 := 0; y0 < b.h; y0 += 4 {
+		y1 := minInt(y0+4, b.h)
+		for x := 0; x < b.w; x++ {
+			for y := y0; y < y1; y++ {
+				i := (y+1)*b.stride + x + 1
+				if b.flags[i]&(jpxSig|jpxVisited) != jpxSig {
+					continue
+				}
+				var bit int
+				if raw != nil {
+					bit = raw.bit()
+				} else {
+					c := jpxCtxMR + 2
+					if b.flags[i]&jpxRefined == 0 {
+						c = jpxCtxMR
+						if b.nb[i] != 0 {
+							c++
+						}
+					}
+					bit = b.mq.decode(b.cx[:], c)
+				}
+				if bit == 1 {
+					b.mag[i] += 1 << p
+				} else {
+					b.mag[i] -= 1 << p
+				}
+				b.flags[i] |= jpxRefined
+			}
+		}
+	}
+}
+
+// cleanupPass is the cleanup pass for bit-plane p (D.3.4).
+func (b *jpxBlock) cleanupPass(p uint, segSymbol bool) {
+	s := b.stride
+	for y0 := 0; y0 < b.h; y0 += 4 {
+		y1 := minInt(y0+4, b.h)
+		for x := 0; x < b.w; x++ {
+			i := (y0+1)*s + x + 1
+			y := y0
+
+			if y1-y0 == 4 &&
+				b.flags[i]|b.flags[i+s]|b.flags[i+2*s]|b.flags[i+3*s] == 0 &&
+				b.nb[i]|b.nb[i+s]|b.nb[i+2*s]|b.nb[i+3*s] == 0 {
+				// Run-length mode
+				if b.mq.decode(b.cx[:], jpxCtxRL) == 0 {
+					continue
+				}
+				y += b.mq.decode(b.cx[:], jpxCtxUniform) << 1
+				y += b.mq.decode(b.cx[:], jpxCtxUniform)
+				j := (y+1)*s + x + 1
+				b.setSignificant(j, y, b.decodeSign(j, y, nil), p)
+				y++
+			}
+
+			for ; y < y1; y++ {
+				j := (y+1)*s + x + 1
+				if b.flags[j]&(jpxSig|jpxVisited) == 0 {
+					if b.mq.decode(b.cx[:], int(b.zc[b.nb[j]])) == 1 {
+						b.setSignificant(j, y, b.decodeSign(j, y, nil), p)
+					}
+				}
+				b.flags[j] &^= jpxVisited
+			}
+		}
+	}
+
+	if segSymbol {
+		for i := 0; i < 4; i++ {
+			b.mq.decode(b.cx[:], jpxCtxUniform)
+		}
+	}
+}
+
+// decodeCodeBlock decodes the coding passes of a code-block into dequantized subband coefficients (D, E.1).
+func (tc *jpxTileComponent) decodeCodeBlock(cb *jpxCodeBlock, band *jpxBand) error {
+	p0 := band.mb - 1 - cb.zbp
+	if len(cb.segs) == 0 || p0 < 0 || band.coeffs == nil {
+		return nil
+	}
+
+	style := tc.cs.cbStyle
+
+	w, h := cb.x1-cb.x0, cb.y1-cb.y0
+	n := (w + 2) * (h + 2)
+	b := &jpxBlock{
+		w:      w,
+		h:      h,
+		stride: w + 2,
+		vsc:    style&jpxVCausal != 0,
+		zc:     &jpxZCContexts[[4]int{0, 1, 0, 2}[band.orientation]],
+		flags:  make([]uint8, n),
+		nb:     make([]uint8, n),
+		mag:    make([]uint32, n),
+	}
+	b.resetContexts()
+
+	pass := 0
+
+	for _, seg := range cb.segs {
+		// In selective arithmetic coding bypass mode significance and refinement passes
+		// following the first 10 passes are raw coded.
+		var raw *jpxBitReader
+		if style&jpxBypass != 0 && pass >= 10 && (pass+2)%3 != 2 {
+			raw = &jpxBitReader{data: seg.data}
+		} else {
+			b.mq = newMQDecoder(seg.data)
+		}
+
+		for k := 0; k < seg.passes; k, pass = k+1, pass+1 {
+			p := p0 - (pass+2)/3
+			if p < 0 {
+				break
+			}
+			switch (pass + 2) % 3 {
+			case 0:
+				b.significancePass(uint(p), raw)
+			case 1:
+				b.refinementPass(uint(p), raw)
+			case 2:
+				b.cleanupPass(uint(p), style&jpxSegSymbol != 0)
+			}
+			if style&jpxReset != 0 {
+				b.resetContexts()
+			}
+		}
+	}
+
+	bw := band.x1 - band.x0
+	delta := float32(band.delta)
+
+	for y := 0; y < h; y++ {
+		row := band.coeffs[(cb.y0-band.y0+y)*bw+cb.x0-band.x0:]
+		for x := 0; x < w; x++ {
+			i := (y+1)*b.stride + x + 1
+			m := b.mag[i]
+			if m == 0 {
+				continue
+			}
+			if tc.roi > 0 && m >= 2<<uint(tc.roi) {
+				m >>= uint(tc.roi)
+			}
+			var v float32
+			if tc.cs.reversible {
+				v = float32(m >> 1)
+			} else {
+				v = float32(m) / 2 * delta
+			}
+			if b.flags[i]&jpxNeg != 0 {
+				v = -v
+			}
+			row[x] = v
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,536 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// JPEG 2000 codestream syntax, see ITU-T T.800 Annex A.
+
+// JPEG 2000 marker codes (A.2).
+const (
+	jpxSOC = 0xFF4F
+	jpxSOT = 0xFF90
+	jpxSOD = 0xFF93
+	jpxEOC = 0xFFD9
+	jpxSIZ = 0xFF51
+	jpxCOD = 0xFF52
+	jpxCOC = 0xFF53
+	jpxRGN = 0xFF5E
+	jpxQCD = 0xFF5C
+	jpxQCC = 0xFF5D
+	jpxPOC = 0xFF5F
+	jpxPPM = 0xFF60
+	jpxPPT = 0xFF61
+)
+
+// Progression orders (A.6.1).
+const (
+	jpxLRCP = iota
+	jpxRLCP
+	jpxRPCL
+	jpxPCRL
+	jpxCPRL
+)
+
+// Code-block styles (Table A.19).
+const (
+	jpxBypass    = 0x01
+	jpxReset     = 0x02
+	jpxTermAll   = 0x04
+	jpxVCausal   = 0x08
+	jpxSegSymbol = 0x20
+)
+
+// maxJPXSamples limits the number of samples allocated while decoding corrupt or malicious data.
+const maxJPXSamples = 1 << 28
+
+var errJPXEOD = errors.New("pdfcpu: jpx: unexpected end of data")
+
+type jpxComponent struct {
+	prec   int
+	signed bool
+	dx, dy int
+}
+
+// jpxSize is the image and tile size (SIZ).
+type jpxSize struct {
+	xsiz, ysiz, xosiz, yosiz     int
+	xtsiz, ytsiz, xtosiz, ytosiz int
+	comps                        []jpxComponent
+}
+
+// jpxCodingStyle holds the coding style of a component (COD, COC).
+type jpxCodingStyle struct {
+	levels     int
+	xcb, ycb   int
+	cbStyle    int
+	reversible bool
+	ppx, ppy   []int // precinct size exponents per resolution
+}
+
+// jpxTileCodingStyle holds the coding style parameters applying to all components of a tile (COD).
+type jpxTileCodingStyle struct {
+	sop, eph    bool
+	progression int
+	layers      int
+	mct         bool
+}
+
+// jpxQuantization holds the quantization of a component (QCD, QCC).
+type jpxQuantization struct {
+	style     int // 0: none, 1: scalar derived, 2: scalar expounded
+	guardBits int
+	steps     [][2]int // exponent, mantissa
+}
+
+// jpxProgressionChange is a progression order change (POC).
+type jpxProgressionChange struct {
+	rs, cs, lye, re, ce, progression int
+}
+
+// jpxHeader holds the marker segments of the main header or a tile header.
+type jpxHeader struct {
+	cod  *jpxTileCodingStyle
+	cs   *jpxCodingStyle // COD
+	coc  map[int]*jpxCodingStyle
+	qcd  *jpxQuantization
+	qcc  map[int]*jpxQuantization
+	rgn  map[int]int
+	poc  []jpxProgressionChange
+	ppx  []byte // PPM or PPT data
+	ppts map[int][]byte
+}
+
+func newJPXHeader() *jpxHeader {
+	return &jpxHeader{coc: map[int]*jpxCodingStyle{}, qcc: map[int]*jpxQuantization{}, rgn: map[int]int{}, ppts: map[int][]byte{}}
+}
+
+// jpxTilePart is the data of a tile-part.
+type jpxTilePart struct {
+	tile    int
+	header  *jpxHeader
+	data    []byte
+	headers []byte // packed packet headers (PPM, PPT)
+}
+
+// jpxCodestream is a parsed JPEG 2000 codestream.
+type jpxCodestream struct {
+	siz    jpxSize
+	main   *jpxHeader
+	tiles  map[int][]*jpxTilePart
+	parts  []*jpxTilePart
+	ppm    []byte
+	ntiles int
+}
+
+type jpxReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *jpxReader) u8() (int, error) {
+	if r.pos+1 > len(r.data) {
+		return 0, errJPXEOD
+	}
+	r.pos++
+	return int(r.data[r.pos-1]), nil
+}
+
+func (r *jpxReader) u16() (int, error) {
+	if r.pos+2 > len(r.data) {
+		return 0, errJPXEOD
+	}
+	r.pos += 2
+	return int(r.data[r.pos-2])<<8 | int(r.data[r.pos-1]), nil
+}
+
+func (r *jpxReader) u32() (int, error) {
+	if r.pos+4 > len(r.data) {
+		return 0, errJPXEOD
+	}
+	r.pos += 4
+	return int(be32(r.data[r.pos-4:])), nil
+}
+
+// segment returns the data of a marker segment.
+func (r *jpxReader) segment() ([]byte, error) {
+	l, err := r.u16()
+	if err != nil {
+		return nil, err
+	}
+	if l < 2 || r.pos+l-2 > len(r.data) {
+		return nil, errJPXEOD
+	}
+	bb := r.data[r.pos : r.pos+l-2]
+	r.pos += l - 2
+	return bb, nil
+}
+
+func parseJPXSize(bb []byte) (jpxSize, error) {
+	var s jpxSize
+	if len(bb) < 36 {
+		return s, errJPXEOD
+	}
+	r := &jpxReader{data: bb, pos: 2}
+	vv := make([]int, 8)
+	for i := range vv {
+		vv[i], _ = r.u32()
+	}
+	s.xsiz, s.ysiz, s.xosiz, s.yosiz, s.xtsiz, s.ytsiz, s.xtosiz, s.ytosiz = vv[0], vv[1], vv[2], vv[3], vv[4], vv[5], vv[6], vv[7]
+	n, _ := r.u16()
+	if n == 0 || len(bb) < 36+3*n {
+		return s, errJPXEOD
+	}
+	for i := 0; i < n; i++ {
+		ssiz, _ := r.u8()
+		dx, _ := r.u8()
+		dy, _ := r.u8()
+		if dx == 0 || dy == 0 || ssiz&0x7F > 37 {
+			return s, errors.New("pdfcpu: jpx: invalid SIZ")
+		}
+		s.comps = append(s.comps, jpxComponent{prec: ssiz&0x7F + 1, signed: ssiz&0x80 != 0, dx: dx, dy: dy})
+	}
+	w, h := s.xsiz-s.xosiz, s.ysiz-s.yosiz
+	if w <= 0 || h <= 0 || h > maxJPXSamples/w/len(s.comps) || s.xtsiz <= 0 || s.ytsiz <= 0 ||
+		s.xtosiz > s.xosiz || s.ytosiz > s.yosiz || s.xtosiz+s.xtsiz <= s.xosiz || s.ytosiz+s.ytsiz <= s.yosiz {
+		return s, errors.New("pdfcpu: jpx: invalid SIZ")
+	}
+	return s, nil
+}
+
+// parseJPXCodingStyle parses SPcod or SPcoc.
+func parseJPXCodingStyle(r *jpxReader, precincts bool) (*jpxCodingStyle, error) {
+	cs := &jpxCodingStyle{}
+	var err error
+	if cs.levels, err = r.u8(); err != nil {
+		return nil, err
+	}
+	xcb, _ := r.u8()
+	ycb, _ := r.u8()
+	cs.cbStyle, _ = r.u8()
+	t, err := r.u8()
+	if err != nil {
+		return nil, err
+	}
+	cs.xcb, cs.ycb, cs.reversible = xcb+2, ycb+2, t == 1
+	if cs.levels > 32 || cs.xcb > 10 || cs.ycb > 10 || cs.xcb+cs.ycb > 12 {
+		return nil, errors.New("pdfcpu: jpx: invalid coding style")
+	}
+	for i := 0; i <= cs.levels; i++ {
+		ppx, ppy := 15, 15
+		if precincts {
+			v, err := r.u8()
+			if err != nil {
+				return nil, err
+			}
+			ppx, ppy = v&0x0F, v>>4
+		}
+		cs.ppx, cs.ppy = append(cs.ppx, ppx), append(cs.ppy, ppy)
+	}
+	return cs, nil
+}
+
+func parseJPXQuantization(r *jpxReader) (*jpxQuantization, error) {
+	sq, err := r.u8()
+	if err != nil {
+		return nil, err
+	}
+	q := &jpxQuantization{style: sq & 0x1F, guardBits: sq >> 5}
+	for r.pos < len(r.data) {
+		if q.style == 0 {
+			v, _ := r.u8()
+			q.steps = append(q.steps, [2]int{v >> 3, 0})
+			continue
+		}
+		v, err := r.u16()
+		if err != nil {
+			return nil, err
+		}
+		q.steps = append(q.steps, [2]int{v >> 11, v & 0x7FF})
+	}
+	if len(q.steps) == 0 || q.style > 2 {
+		return nil, errors.New("pdfcpu: jpx: invalid quantization")
+	}
+	return q, nil
+}
+
+// componentIndex reads a component index of a COC, QCC or RGN segment.
+func (s *jpxSize) componentIndex(r *jpxReader) (int, error) {
+	if len(s.comps) < 257 {
+		return r.u8()
+	}
+	return r.u16()
+}
+
+// parseMarkerSegment parses a header marker segment into h.
+func (cs *jpxCodestream) parseMarkerSegment(h *jpxHeader, marker int, bb []byte) error {
+	r := &jpxReader{data: bb}
+
+	switch marker {
+
+	case jpxCOD:
+		scod, err := r.u8()
+		if err != nil {
+			return err
+		}
+		progression, _ := r.u8()
+		layers, _ := r.u16()
+		mct, err := r.u8()
+		if err != nil {
+			return err
+		}
+		if layers == 0 || progression > jpxCPRL {
+			return errors.New("pdfcpu: jpx: invalid COD")
+		}
+		h.cod = &jpxTileCodingStyle{sop: scod&2 != 0, eph: scod&4 != 0, progression: progression, layers: layers, mct: mct == 1}
+		c, err := parseJPXCodingStyle(r, scod&1 != 0)
+		if err != nil {
+			return err
+		}
+		h.cs = c
+
+	case jpxCOC:
+		i, err := cs.siz.componentIndex(r)
+		if err != nil {
+			return err
+		}
+		scoc, err := r.u8()
+		if err != nil {
+			return err
+		}
+		c, err := parseJPXCodingStyle(r, scoc&1 != 0)
+		if err != nil {
+			return err
+		}
+		h.coc[i] = c
+
+	case jpxQCD:
+		q, err := parseJPXQuantization(r)
+		if err != nil {
+			return err
+		}
+		h.qcd = q
+
+	case jpxQCC:
+		i, err := cs.siz.componentIndex(r)
+		if err != nil {
+			return err
+		}
+		q, err := parseJPXQuantization(r)
+		if err != nil {
+			return err
+		}
+		h.qcc[i] = q
+
+	case jpxRGN:
+		i, err := cs.siz.componentIndex(r)
+		if err != nil {
+			return err
+		}
+		r.u8()
+		shift, err := r.u8()
+		if err != nil {
+			return err
+		}
+		h.rgn[i] = shift
+
+	case jpxPOC:
+		for r.pos < len(r.data) {
+			var p jpxProgressionChange
+			p.rs, _ = r.u8()
+			p.cs, _ = cs.siz.componentIndex(r)
+			p.lye, _ = r.u16()
+			p.re, _ = r.u8()
+			p.ce, _ = cs.siz.componentIndex(r)
+			var err error
+			if p.progression, err = r.u8(); err != nil {
+				return err
+			}
+			if p.ce == 0 {
+				p.ce = 256
+			}
+			h.poc = append(h.poc, p)
+		}
+
+	case jpxPPM:
+		if len(bb) > 0 {
+			cs.ppm = append(cs.ppm, bb[1:]...)
+		}
+
+	case jpxPPT:
+		if len(bb) > 0 {
+			h.ppts[int(bb[0])] = bb[1:]
+		}
+	}
+
+	return nil
+}
+
+// parseJPXCodestream parses the main header and the tile-parts of a codestream (A.3, A.4).
+// With headerOnly only the main header is parsed.
+func parseJPXCodestream(bb []byte, headerOnly bool) (*jpxCodestream, error) {
+	r := &jpxReader{data: bb}
+
+	if m, err := r.u16(); err != nil || m != jpxSOC {
+		return nil, errors.New("pdfcpu: jpx: missing SOC marker")
+	}
+
+	m, err := r.u16()
+	if err != nil || m != jpxSIZ {
+		return nil, errors.New("pdfcpu: jpx: missing SIZ marker")
+	}
+	seg, err := r.segment()
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &jpxCodestream{main: newJPXHeader(), tiles: map[int][]*jpxTilePart{}}
+	if cs.siz, err = parseJPXSize(seg); err != nil {
+		return nil, err
+	}
+
+	nx := (cs.siz.xsiz - cs.siz.xtosiz + cs.siz.xtsiz - 1) / cs.siz.xtsiz
+	ny := (cs.siz.ysiz - cs.siz.ytosiz + cs.siz.ytsiz - 1) / cs.siz.ytsiz
+	if nx <= 0 || ny <= 0 || nx*ny > 65535 {
+		return nil, errors.New("pdfcpu: jpx: invalid tiling")
+	}
+	cs.ntiles = nx * ny
+
+	// Main header
+	for {
+		m, err := r.u16()
+		if err != nil {
+			return nil, err
+		}
+		if m == jpxSOT {
+			r.pos -= 2
+			break
+		}
+		if m == jpxEOC {
+			return nil, errors.New("pdfcpu: jpx: missing tiles")
+		}
+		seg, err := r.segment()
+		if err != nil {
+			return nil, err
+		}
+		if err := cs.parseMarkerSegment(cs.main, m, seg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cs.main.cod == nil || cs.main.qcd == nil {
+		return nil, errors.New("pdfcpu: jpx: missing COD or QCD")
+	}
+
+	if headerOnly {
+		return cs, nil
+	}
+
+	// Tile-parts
+	for r.pos < len(bb) {
+		m, err := r.u16()
+		if err != nil || m == jpxEOC {
+			break
+		}
+		if m != jpxSOT {
+			return nil, errors.Errorf("pdfcpu: jpx: unexpected marker 0x%04X", m)
+		}
+		start := r.pos - 2
+		seg, err := r.segment()
+		if err != nil || len(seg) < 8 {
+			return nil, errJPXEOD
+		}
+		tile, psot := be16(seg), int(be32(seg[2:]))
+		if tile >= cs.ntiles {
+			return nil, errors.New("pdfcpu: jpx: invalid tile index")
+		}
+
+		end := start + psot
+		if psot == 0 || end > len(bb) {
+			// Last tile-part or truncated data.
+			end = len(bb)
+			if end >= 2 && be16(bb[end-2:]) == jpxEOC {
+				end -= 2
+			}
+		}
+
+		tp := &jpxTilePart{tile: tile, header: newJPXHeader()}
+
+		for {
+			m, err := r.u16()
+			if err != nil {
+				return nil, err
+			}
+			if m == jpxSOD {
+				break
+			}
+			seg, err := r.segment()
+			if err != nil {
+				return nil, err
+			}
+			if err := cs.parseMarkerSegment(tp.header, m, seg); err != nil {
+				return nil, err
+			}
+		}
+
+		if r.pos > end {
+			return nil, errJPXEOD
+		}
+		tp.data = bb[r.pos:end]
+		r.pos = end
+
+		if len(tp.header.ppts) > 0 {
+			var zz []int
+			for z := range tp.header.ppts {
+				zz = append(zz, z)
+			}
+			sort.Ints(zz)
+			tp.headers = []byte{}
+			for _, z := range zz {
+				tp.headers = append(tp.headers, tp.header.ppts[z]...)
+			}
+		}
+
+		cs.tiles[tile] = append(cs.tiles[tile], tp)
+		cs.parts = append(cs.parts, tp)
+	}
+
+	cs.assignPackedHeaders()
+
+	return cs, nil
+}
+
+// assignPackedHeaders distributes the packet headers of PPM segments to the tile-parts (A.7.4).
+func (cs *jpxCodestream) assignPackedHeaders() {
+	if cs.ppm == nil {
+		return
+	}
+	r := &jpxReader{data: cs.ppm}
+	for _, tp := range cs.parts {
+		n, err := r.u32()
+		if err != nil {
+			return
+		}
+		end := minInt(r.pos+n, len(r.data))
+		tp.headers = append([]byte{}, r.data[r.pos:end]...)
+		r.pos = end
+	}
+}
@@ -0,0 +1,496 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/pdfcpu/pdfcpu/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// JPEG 2000 decoding of JP2 files and raw codestreams, see ITU-T T.800 and Annex I.
+
+// JP2 enumerated colour spaces (I.5.3.3).
+const (
+	jp2CMYK      = 12
+	jp2SRGB      = 16
+	jp2Greyscale = 17
+	jp2SYCC      = 18
+	jp2ESRGB     = 20
+	jp2ROMMRGB   = 21
+)
+
+// JPXImage is a decoded JPEG 2000 image.
+type JPXImage struct {
+	Width, Height int
+	ColorSpace    string // DeviceGray, DeviceRGB, DeviceCMYK or empty if unknown.
+	Comps         int    // Number of colour components.
+	BPC           int    // Bits per component of the encoded data.
+	Pix           []byte // Interleaved 8 bit colour components.
+	Alpha         []byte // 8 bit opacity or nil.
+}
+
+// jp2Channel maps a channel to a codestream component and an optional palette column.
+type jp2Channel struct {
+	comp int
+	col  int
+}
+
+type jp2Palette struct {
+	bits   []int
+	values [][]int // column, entry
+}
+
+// jp2File holds the relevant boxes of a JP2 file.
+type jp2File struct {
+	codestream []byte
+	enumCS     int
+	palette    *jp2Palette
+	cmap       []jp2Channel
+	cdef       [][3]int // channel, type, association
+}
+
+func parseJP2Palette(bb []byte) (*jp2Palette, error) {
+	if len(bb) < 3 {
+		return nil, errJPXEOD
+	}
+	ne, npc := be16(bb), int(bb[2])
+	p := &jp2Palette{values: make([][]int, npc)}
+	for i := 0; i < npc; i++ {
+		if 3+i >= len(bb) {
+			return nil, errJPXEOD
+		}
+		p.bits = append(p.bits, int(bb[3+i]&0x7F)+1)
+		p.values[i] = make([]int, ne)
+	}
+	r := &jpxReader{data: bb, pos: 3 + npc}
+	for j := 0; j < ne; j++ {
+		for i := 0; i < npc; i++ {
+			v := 0
+			for k := 0; k < (p.bits[i]+7)/8; k++ {
+				b, err := r.u8()
+				if err != nil {
+					return nil, err
+				}
+				v = v<<8 | b
+			}
+			p.values[i][j] = v
+		}
+	}
+	return p, nil
+}
+
+// parseBoxes parses the boxes of a JP2 file or superbox (I.4).
+func (f *jp2File) parseBoxes(bb []byte) error {
+	for len(bb) >= 8 {
+		l, t, h := int(be32(bb)), string(bb[4:8]), 8
+		if l == 1 {
+			if len(bb) < 16 || be32(bb[8:]) != 0 {
+				return errJPXEOD
+			}
+			l, h = int(be32(bb[12:])), 16
+		}
+		if l == 0 {
+			l = len(bb)
+		}
+		if l < h || l > len(bb) {
+			return errJPXEOD
+		}
+		data := bb[h:l]
+		bb = bb[l:]
+
+		switch t {
+
+		case "jp2h":
+			if err := f.parseBoxes(data); err != nil {
+				return err
+			}
+
+		case "colr":
+			if len(data) >= 7 && data[0] == 1 && f.enumCS < 0 {
+				f.enumCS = int(be32(data[3:]))
+			}
+
+		case "pclr":
+			p, err := parseJP2Palette(data)
+			if err != nil {
+				return err
+			}
+			f.palette = p
+
+		case "cmap":
+			for i := 0; i+4 <= len(data); i += 4 {
+				ch := jp2Channel{comp: be16(data[i:]), col: -1}
+				if data[i+2] == 1 {
+					ch.col = int(data[i+3])
+				}
+				f.cmap = append(f.cmap, ch)
+			}
+
+		case "cdef":
+			if len(data) < 2 {
+				return errJPXEOD
+			}
+			for i := 2; i+6 <= len(data); i += 6 {
+				f.cdef = append(f.cdef, [3]int{be16(data[i:]), be16(data[i+2:]), be16(data[i+4:])})
+			}
+
+		case "jp2c":
+			if f.codestream == nil {
+				f.codestream = data
+			}
+		}
+	}
+	return nil
+}
+
+func parseJP2(bb []byte) (*jp2File, error) {
+	f := &jp2File{enumCS: -1}
+
+	if len(bb) >= 4 && be16(bb) == jpxSOC && be16(bb[2:]) == jpxSIZ {
+		// Raw codestream
+		f.codestream = bb
+		return f, nil
+	}
+
+	if err := f.parseBoxes(bb); err != nil {
+		return nil, err
+	}
+
+	if f.codestream == nil {
+		return nil, errors.New("pdfcpu: jpx: missing codestream")
+	}
+
+	return f, nil
+}
+
+// channels returns the image channels and their number of bits.
+func (f *jp2File) channels(cs *jpxCodestream) ([]jp2Channel, []int, error) {
+	var cc []jp2Channel
+	var bits []int
+
+	if f.palette != nil && len(f.cmap) > 0 {
+		for _, ch := range f.cmap {
+			if ch.comp >= len(cs.siz.comps) || ch.col >= len(f.palette.bits) {
+				return nil, nil, errors.New("pdfcpu: jpx: invalid component mapping")
+			}
+			b := cs.siz.comps[ch.comp].prec
+			if ch.col >= 0 {
+				b = f.palette.bits[ch.col]
+			}
+			cc, bits = append(cc, ch), append(bits, b)
+		}
+		return cc, bits, nil
+	}
+
+	for i, c := range cs.siz.comps {
+		cc, bits = append(cc, jp2Channel{comp: i, col: -1}), append(bits, c.prec)
+	}
+
+	return cc, bits, nil
+}
+
+// colorSpaceComponents returns the number of colour components of the JP2 colour space.
+func (f *jp2File) colorSpaceComponents() int {
+	switch f.enumCS {
+	case jp2Greyscale:
+		return 1
+	case jp2SRGB, jp2SYCC, jp2ESRGB, jp2ROMMRGB:
+		return 3
+	case jp2CMYK:
+		return 4
+	}
+	return 0
+}
+
+// colorAndAlphaChannels returns the indices of the colour channels in colour order and the opacity channel or -1 (I.5.3.6).
+func (f *jp2File) colorAndAlphaChannels(n int) ([]int, int) {
+	alpha := -1
+
+	if len(f.cdef) == 0 {
+		var cc []int
+		m := f.colorSpaceComponents()
+		if m == 0 || m > n {
+			m = n
+			if m > 4 || m == 2 {
+				m = 1
+			}
+		}
+		for i := 0; i < m; i++ {
+			cc = append(cc, i)
+		}
+		return cc, alpha
+	}
+
+	type color struct{ ch, asoc int }
+	var cc []color
+	for _, d := range f.cdef {
+		ch, typ, asoc := d[0], d[1], d[2]
+		if ch >= n {
+			continue
+		}
+		switch typ {
+		case 0:
+			cc = append(cc, color{ch, asoc})
+		case 1, 2:
+			if alpha < 0 && (asoc == 0 || asoc == 0xFFFF) {
+				alpha = ch
+			}
+		}
+	}
+
+	sort.SliceStable(cc, func(i, j int) bool { return cc[i].asoc < cc[j].asoc })
+
+	var ii []int
+	for _, c := range cc {
+		ii = append(ii, c.ch)
+	}
+
+	return ii, alpha
+}
+
+func jpxColorSpace(n int) string {
+	switch n {
+	case 1:
+		return "DeviceGray"
+	case 3:
+		return "DeviceRGB"
+	case 4:
+		return "DeviceCMYK"
+	}
+	return ""
+}
+
+// jpxPlane holds the samples of an image component.
+type jpxPlane struct {
+	x0, y0, w, h int
+	prec         int
+	samples      []uint16
+}
+
+// decode decodes all tiles of the codestream into image component planes.
+func (cs *jpxCodestream) decode() ([]*jpxPlane, error) {
+	s := cs.siz
+
+	var pp []*jpxPlane
+	for _, c := range s.comps {
+		p := &jpxPlane{
+			x0:   ceilDiv(s.xosiz, c.dx),
+			y0:   ceilDiv(s.yosiz, c.dy),
+			prec: minInt(c.prec, 16),
+		}
+		p.w, p.h = ceilDiv(s.xsiz, c.dx)-p.x0, ceilDiv(s.ysiz, c.dy)-p.y0
+		p.samples = make([]uint16, p.w*p.h)
+		pp = append(pp, p)
+	}
+
+	for i := 0; i < cs.ntiles; i++ {
+		t, err := cs.decodeTile(i)
+		if err != nil {
+			return nil, err
+		}
+
+		for c, tc := range t.comps {
+			comp, p := s.comps[c], pp[c]
+
+			// DC level shift (G.1.2)
+			shift := math.Ldexp(1, comp.prec-1)
+			max := math.Ldexp(1, comp.prec) - 1
+			scale := math.Ldexp(1, p.prec-comp.prec)
+
+			w := tc.x1 - tc.x0
+			for y := tc.y0; y < tc.y1; y++ {
+				src := tc.data[(y-tc.y0)*w:]
+				dst := p.samples[(y-p.y0)*p.w+tc.x0-p.x0:]
+				for x := 0; x < w; x++ {
+					v := math.Floor(float64(src[x]) + shift + .5)
+					if v < 0 {
+						v = 0
+					} else if v > max {
+						v = max
+					}
+					dst[x] = uint16(v * scale)
+				}
+			}
+		}
+	}
+
+	return pp, nil
+}
+
+// sample returns the sample of the plane covering image position x, y on the reference grid.
+func (p *jpxPlane) sample(x, y int, c jpxComponent) int {
+	i, j := x/c.dx-p.x0, y/c.dy-p.y0
+	if i >= p.w {
+		i = p.w - 1
+	}
+	if j >= p.h {
+		j = p.h - 1
+	}
+	if i < 0 || j < 0 {
+		return 0
+	}
+	return int(p.samples[j*p.w+i])
+}
+
+// fillChannel writes 8 bit samples of channel ch into every n-th byte of bb starting at offset off.
+func (f *jp2File) fillChannel(cs *jpxCodestream, pp []*jpxPlane, ch jp2Channel, bb []byte, off, n int) {
+	s := cs.siz
+	p, comp := pp[ch.comp], s.comps[ch.comp]
+
+	prec := p.prec
+	var pal []int
+	if ch.col >= 0 {
+		pal, prec = f.palette.values[ch.col], f.palette.bits[ch.col]
+	}
+	var shift uint
+	if prec > 16 {
+		shift, prec = uint(prec-16), 16
+	}
+	max := 1<<uint(prec) - 1
+
+	for y := s.yosiz; y < s.ysiz; y++ {
+		for x := s.xosiz; x < s.xsiz; x++ {
+			v := p.sample(x, y, comp)
+			if pal != nil {
+				if v >= len(pal) {
+					v = len(pal) - 1
+				}
+				v = pal[v]
+			}
+			v >>= shift
+			bb[off] = byte((v*255 + max/2) / max)
+			off += n
+		}
+	}
+}
+
+// sYCCToRGB converts sYCC samples to sRGB.
+func sYCCToRGB(bb []byte) {
+	clamp := func(v float64) byte {
+		return byte(math.Max(0, math.Min(255, math.Round(v))))
+	}
+	for i := 0; i+2 < len(bb); i += 3 {
+		y, cb, cr := float64(bb[i]), float64(bb[i+1])-128, float64(bb[i+2])-128
+		bb[i], bb[i+1], bb[i+2] = clamp(y+1.402*cr), clamp(y-0.34413*cb-0.71414*cr), clamp(y+1.772*cb)
+	}
+}
+
+func decodeJPX(bb []byte, headerOnly bool) (*JPXImage, error) {
+	f, err := parseJP2(bb)
+	if err != nil {
+		return nil, err
+	}
+
+	cs, err := parseJPXCodestream(f.codestream, headerOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	s := cs.siz
+	img := &JPXImage{Width: s.xsiz - s.xosiz, Height: s.ysiz - s.yosiz}
+
+	cc, bits, err := f.channels(cs)
+	if err != nil {
+		return nil, err
+	}
+
+	colors, alpha := f.colorAndAlphaChannels(len(cc))
+	if len(colors) == 0 {
+		return nil, errors.New("pdfcpu: jpx: missing colour channels")
+	}
+
+	img.Comps = len(colors)
+	img.ColorSpace = jpxColorSpace(img.Comps)
+	img.BPC = bits[colors[0]]
+
+	if headerOnly {
+		return img, nil
+	}
+
+	pp, err := cs.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	img.Pix = make([]byte, img.Width*img.Height*img.Comps)
+	for i, ch := range colors {
+		f.fillChannel(cs, pp, cc[ch], img.Pix, i, img.Comps)
+	}
+
+	if f.enumCS == jp2SYCC && img.Comps == 3 {
+		sYCCToRGB(img.Pix)
+	}
+
+	if alpha >= 0 {
+		img.Alpha = make([]byte, img.Width*img.Height)
+		f.fillChannel(cs, pp, cc[alpha], img.Alpha, 0, 1)
+	}
+
+	return img, nil
+}
+
+// DecodeJPX decodes a JPEG 2000 image from a JP2 file or a raw codestream.
+func DecodeJPX(r io.Reader) (*JPXImage, error) {
+	bb, err := getReaderBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJPX(bb, false)
+}
+
+// DecodeJPXConfig returns the dimensions, colour space and component information of a JPEG 2000 image without decoding it.
+func DecodeJPXConfig(r io.Reader) (*JPXImage, error) {
+	bb, err := getReaderBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJPX(bb, true)
+}
+
+type jpxDecode struct {
+	baseFilter
+}
+
+// Encode implements encoding for a JPXDecode filter.
+func (f jpxDecode) Encode(r io.Reader) (io.Reader, error) {
+	return nil, errors.New("pdfcpu: filter JPXDecode: encoding not supported")
+}
+
+// Decode implements decoding for a JPXDecode filter.
+func (f jpxDecode) Decode(r io.Reader) (io.Reader, error) {
+	return f.DecodeLength(r, -1)
+}
+
+// DecodeLength implements decoding for a JPXDecode filter.
+// The result are interleaved 8 bit colour components excluding any opacity channel.
+func (f jpxDecode) DecodeLength(r io.Reader, maxLen int64) (io.Reader, error) {
+	if log.TraceEnabled() {
+		log.Trace.Println("DecodeJPX begin")
+	}
+
+	img, err := DecodeJPX(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBuffer(img.Pix), nil
+}
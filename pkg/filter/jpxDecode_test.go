@@ -0,0 +1,161 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"image"
+	"image/png"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJPXDecode(t *testing.T) {
+	dir := filepath.Join("..", "testdata", "resources")
+
+	f, err := os.Open(filepath.Join(dir, "mountain.jpx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	img, err := DecodeJPX(f)
+	if err != nil {
+		t.Fatalf("decode: %v\n", err)
+	}
+
+	if img.Width != 1667 || img.Height != 2646 || img.Comps != 3 || img.ColorSpace != "DeviceRGB" || img.BPC != 8 {
+		t.Fatalf("got %dx%d %s comps=%d bpc=%d\n", img.Width, img.Height, img.ColorSpace, img.Comps, img.BPC)
+	}
+
+	if img.Alpha == nil {
+		t.Fatal("missing opacity channel")
+	}
+
+	f1, err := os.Open(filepath.Join(dir, "mountain.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f1.Close()
+
+	ref, err := png.Decode(f1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nrgba, ok := ref.(*image.NRGBA)
+	if !ok {
+		t.Fatalf("unexpected reference image type %T\n", ref)
+	}
+
+	// Skip the top rows containing a caption that differs between both images.
+	var se float64
+	var n int
+	for y := 300; y < img.Height; y++ {
+		for x := 0; x < img.Width; x++ {
+			c := nrgba.NRGBAAt(x, y)
+			if a := img.Alpha[y*img.Width+x]; math.Abs(float64(a)-float64(c.A)) > 2 {
+				t.Fatalf("opacity at %d,%d: got %d want %d\n", x, y, a, c.A)
+			}
+			if c.A == 0 {
+				continue
+			}
+			i := (y*img.Width + x) * 3
+			for k, v := range []uint8{c.R, c.G, c.B} {
+				d := float64(img.Pix[i+k]) - float64(v)
+				se += d * d
+				n++
+			}
+		}
+	}
+
+	if rms := math.Sqrt(se / float64(n)); rms > 1 {
+		t.Errorf("rms error %.2f\n", rms)
+	}
+}
+
+func TestJPXDecodeConfig(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "testdata", "resources", "mountain.jpx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	img, err := DecodeJPXConfig(f)
+	if err != nil {
+		t.Fatalf("decode config: %v\n", err)
+	}
+
+	if img.Width != 1667 || img.Height != 2646 || img.Comps != 3 || img.BPC != 8 || img.Pix != nil {
+		t.Fatalf("got %dx%d comps=%d bpc=%d\n", img.Width, img.Height, img.Comps, img.BPC)
+	}
+}
+
+// fdwt53 is the forward 5-3 reversible transformation of samples located at i0, i0+1, ... (F.4.8.1).
+func fdwt53(x []int, i0 int) []int {
+	n := len(x)
+	y := append([]int{}, x...)
+	if n == 1 {
+		if i0&1 == 1 {
+			y[0] *= 2
+		}
+		return y
+	}
+	at := func(j int) int {
+		if j < 0 {
+			j = -j
+		}
+		if j >= n {
+			j = 2*(n-1) - j
+		}
+		return y[j]
+	}
+	floorDiv := func(a, b int) int {
+		return int(math.Floor(float64(a) / float64(b)))
+	}
+	for j := 1 - i0&1; j < n; j += 2 {
+		y[j] -= floorDiv(at(j-1)+at(j+1), 2)
+	}
+	for j := i0 & 1; j < n; j += 2 {
+		y[j] += floorDiv(at(j-1)+at(j+1)+2, 4)
+	}
+	return y
+}
+
+func TestJPXInverseDWT53(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	for n := 1; n <= 12; n++ {
+		for i0 := 0; i0 < 2; i0++ {
+			x := make([]int, n)
+			for i := range x {
+				x[i] = r.Intn(512) - 256
+			}
+			y := fdwt53(x, i0)
+			f := make([]float32, n)
+			for i, v := range y {
+				f[i] = float32(v)
+			}
+			idwt1D(f, i0, true)
+			for i := range x {
+				if int(f[i]) != x[i] {
+					t.Fatalf("n=%d i0=%d: got %v want %v\n", n, i0, f, x)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,352 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"sort"
+)
+
+// JPEG 2000 packet decoding (tier-2), see ITU-T T.800 Annex B.
+
+// jpxBitReader reads packet header bits (B.10.1).
+// After a 0xFF byte only 7 bits of the next byte are used.
+type jpxBitReader struct {
+	data []byte
+	pos  int
+	buf  int
+	bits int
+	ff   bool
+	eod  bool
+}
+
+func (r *jpxBitReader) bit() int {
+	if r.bits == 0 {
+		if r.pos >= len(r.data) {
+			r.eod = true
+			return 0
+		}
+		r.buf, r.bits = int(r.data[r.pos]), 8
+		if r.ff {
+			r.bits = 7
+		}
+		r.ff = r.buf == 0xFF
+		r.pos++
+	}
+	r.bits--
+	return (r.buf >> r.bits) & 1
+}
+
+func (r *jpxBitReader) readBits(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		v = v<<1 | r.bit()
+	}
+	return v
+}
+
+// align skips to the end of a packet header including a stuffed byte following 0xFF.
+func (r *jpxBitReader) align() {
+	r.bits = 0
+	if r.ff {
+		r.pos++
+		r.ff = false
+	}
+}
+
+// skipMarker skips marker m of length n at the current byte position.
+func (r *jpxBitReader) skipMarker(m, n int) {
+	if r.pos+1 < len(r.data) && be16(r.data[r.pos:]) == m {
+		r.pos += n
+	}
+}
+
+type jpxTagNode struct {
+	parent int
+	low    int
+	known  bool
+}
+
+// jpxTagTree is a tag tree (B.10.2).
+type jpxTagTree struct {
+	nodes []jpxTagNode
+}
+
+func newJPXTagTree(w, h int) *jpxTagTree {
+	t := &jpxTagTree{}
+	if w == 0 || h == 0 {
+		return t
+	}
+	type level struct{ w, h, off int }
+	var levels []level
+	for n := 0; ; {
+		levels = append(levels, level{w, h, n})
+		n += w * h
+		if w == 1 && h == 1 {
+			break
+		}
+		w, h = (w+1)/2, (h+1)/2
+	}
+	for i, l := range levels {
+		for y := 0; y < l.h; y++ {
+			for x := 0; x < l.w; x++ {
+				p := -1
+				if i+1 < len(levels) {
+					pl := levels[i+1]
+					p = pl.off + (y/2)*pl.w + x/2
+				}
+				t.nodes = append(t.nodes, jpxTagNode{parent: p})
+			}
+		}
+	}
+	return t
+}
+
+// decode decodes whether the value of leaf i is below threshold and returns the lower bound of the value known so far.
+func (t *jpxTagTree) decode(r *jpxBitReader, i, threshold int) (bool, int) {
+	var path []int
+	for n := i; n >= 0; n = t.nodes[n].parent {
+		path = append(path, n)
+	}
+	low := 0
+	for j := len(path) - 1; j >= 0; j-- {
+		n := &t.nodes[path[j]]
+		if n.low < low {
+			n.low = low
+		}
+		for !n.known && n.low < threshold && !r.eod {
+			if r.bit() == 1 {
+				n.known = true
+			} else {
+				n.low++
+			}
+		}
+		low = n.low
+	}
+	n := t.nodes[i]
+	return n.known && n.low < threshold, n.low
+}
+
+// readPasses reads the number of coding passes (Table B.4).
+func (r *jpxBitReader) readPasses() int {
+	if r.bit() == 0 {
+		return 1
+	}
+	if r.bit() == 0 {
+		return 2
+	}
+	if v := r.readBits(2); v < 3 {
+		return 3 + v
+	}
+	if v := r.readBits(5); v < 31 {
+		return 6 + v
+	}
+	return 37 + r.readBits(7)
+}
+
+// maxPasses returns the number of coding passes of codeword segment i (D.4.1).
+func maxPasses(i, style int) int {
+	if style&jpxTermAll != 0 {
+		return 1
+	}
+	if style&jpxBypass != 0 {
+		if i == 0 {
+			return 10
+		}
+		if i%2 == 1 {
+			return 2
+		}
+		return 1
+	}
+	return 1 << 30
+}
+
+func floorLog2(n int) int {
+	l := 0
+	for n > 1 {
+		n >>= 1
+		l++
+	}
+	return l
+}
+
+type jpxSegmentLength struct {
+	seg *jpxSegment
+	len int
+}
+
+// readPacket reads the packet for layer l of precinct p (B.9, B.10).
+// Packet headers are read from hr, packet bodies from br.
+func (t *jpxTile) readPacket(hr, br *jpxBitReader, tc *jpxTileComponent, res *jpxResolution, p, l int) {
+	br.skipMarker(0xFF91, 6)
+	if hr == br {
+		hr.bits, hr.ff = 0, false
+	}
+
+	prec := res.precincts[p]
+	style := tc.cs.cbStyle
+
+	var lengths []jpxSegmentLength
+
+	if hr.bit() == 1 {
+		for _, pb := range prec.bands {
+			for i, cb := range pb.cbs {
+				var incl bool
+				if !cb.included {
+					incl, _ = pb.incl.decode(hr, i, l+1)
+				} else {
+					incl = hr.bit() == 1
+				}
+				if !incl {
+					continue
+				}
+				if !cb.included {
+					_, cb.zbp = pb.zbp.decode(hr, i, 1<<30)
+					cb.included, cb.lblock = true, 3
+				}
+				n := hr.readPasses()
+				for hr.bit() == 1 && !hr.eod {
+					cb.lblock++
+				}
+				for n > 0 && !hr.eod {
+					var s *jpxSegment
+					if k := len(cb.segs); k > 0 && cb.segs[k-1].passes < cb.segs[k-1].maxPasses {
+						s = cb.segs[k-1]
+					} else {
+						s = &jpxSegment{maxPasses: maxPasses(k, style)}
+						cb.segs = append(cb.segs, s)
+					}
+					k := n
+					if m := s.maxPasses - s.passes; k > m {
+						k = m
+					}
+					lengths = append(lengths, jpxSegmentLength{s, hr.readBits(cb.lblock + floorLog2(k))})
+					s.passes += k
+					n -= k
+				}
+			}
+		}
+	}
+
+	hr.align()
+	hr.skipMarker(0xFF92, 2)
+
+	for _, sl := range lengths {
+		end := br.pos + sl.len
+		if end > len(br.data) {
+			end = len(br.data)
+			br.eod = true
+		}
+		sl.seg.data = append(sl.seg.data, br.data[br.pos:end]...)
+		br.pos = end
+	}
+}
+
+type jpxPacket struct {
+	key     [5]int
+	c, r, p int
+	l       int
+}
+
+// packets returns up to about maxPackets packets of a tile in progression order (B.12).
+func (t *jpxTile) packets(maxPackets int) []jpxPacket {
+	np := 1
+	for _, tc := range t.comps {
+		for _, res := range tc.res {
+			np += len(res.precincts)
+		}
+	}
+
+	pocs := t.poc
+	if len(pocs) == 0 {
+		pocs = []jpxProgressionChange{{lye: t.cod.layers, re: 33, ce: len(t.comps), progression: t.cod.progression}}
+	}
+
+	next := map[*jpxPrecinct]int{}
+
+	var pp []jpxPacket
+
+	for _, poc := range pocs {
+		var qq []jpxPacket
+		lye := minInt(poc.lye, t.cod.layers)
+		lye = minInt(lye, maxPackets/np+1)
+		for c := poc.cs; c < poc.ce && c < len(t.comps); c++ {
+			tc := t.comps[c]
+			for r := poc.rs; r < poc.re && r < len(tc.res); r++ {
+				for p, prec := range tc.res[r].precincts {
+					x, y := prec.x, prec.y
+					for l := 0; l < lye; l++ {
+						q := jpxPacket{c: c, r: r, p: p, l: l}
+						switch poc.progression {
+						case jpxLRCP:
+							q.key = [5]int{l, r, c, p, 0}
+						case jpxRLCP:
+							q.key = [5]int{r, l, c, p, 0}
+						case jpxRPCL:
+							q.key = [5]int{r, y, x, c, l}
+						case jpxPCRL:
+							q.key = [5]int{y, x, c, r, l}
+						case jpxCPRL:
+							q.key = [5]int{c, y, x, r, l}
+						}
+						qq = append(qq, q)
+					}
+				}
+			}
+		}
+
+		sort.SliceStable(qq, func(i, j int) bool {
+			a, b := qq[i].key, qq[j].key
+			for k := range a {
+				if a[k] != b[k] {
+					return a[k] < b[k]
+				}
+			}
+			return false
+		})
+
+		for _, q := range qq {
+			prec := t.comps[q.c].res[q.r].precincts[q.p]
+			if q.l < next[prec] {
+				continue
+			}
+			next[prec] = q.l + 1
+			pp = append(pp, q)
+		}
+	}
+
+	return pp
+}
+
+// decodePackets reads all packets of a tile.
+// Packet headers are either part of data or packed into headers (PPM, PPT).
+func (t *jpxTile) decodePackets(data, headers []byte) {
+	br := &jpxBitReader{data: data}
+	hr := br
+	if headers != nil {
+		hr = &jpxBitReader{data: headers}
+	}
+
+	// Every packet header takes at least one bit.
+	for _, q := range t.packets(8*len(hr.data) + 1) {
+		if hr.eod || br.eod || br.pos >= len(br.data) && hr == br {
+			// Truncated data, decode what is available.
+			break
+		}
+		tc := t.comps[q.c]
+		t.readPacket(hr, br, tc, tc.res[q.r], q.p, q.l)
+	}
+}
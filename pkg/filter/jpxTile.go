@@ -0,0 +1,346 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// JPEG 2000 tile structure, see ITU-T T.800 Annex B.
+
+// Subband orientations.
+const (
+	jpxLL = iota
+	jpxHL
+	jpxLH
+	jpxHH
+)
+
+// jpxSegment is a codeword segment of a code-block.
+type jpxSegment struct {
+	data      []byte
+	passes    int
+	maxPasses int
+}
+
+type jpxCodeBlock struct {
+	x0, y0, x1, y1 int
+	included       bool
+	lblock         int
+	zbp            int
+	segs           []*jpxSegment
+}
+
+// jpxPrecinctBand holds the code-blocks of a subband within a precinct.
+type jpxPrecinctBand struct {
+	band      *jpxBand
+	cbs       []*jpxCodeBlock
+	incl, zbp *jpxTagTree
+}
+
+type jpxPrecinct struct {
+	x, y  int // position on the reference grid
+	bands []*jpxPrecinctBand
+}
+
+type jpxBand struct {
+	orientation    int
+	x0, y0, x1, y1 int
+	mb             int // number of magnitude bit-planes
+	delta          float64
+	coeffs         []float32
+}
+
+type jpxResolution struct {
+	x0, y0, x1, y1 int
+	bands          []*jpxBand
+	precincts      []*jpxPrecinct
+}
+
+type jpxTileComponent struct {
+	x0, y0, x1, y1 int
+	cs             *jpxCodingStyle
+	q              *jpxQuantization
+	roi            int
+	res            []*jpxResolution
+	data           []float32
+}
+
+type jpxTile struct {
+	x0, y0, x1, y1 int
+	cod            *jpxTileCodingStyle
+	poc            []jpxProgressionChange
+	comps          []*jpxTileComponent
+}
+
+// ceilShift returns ceil(a / 2^n).
+func ceilShift(a, n int) int {
+	return -((-a) >> uint(n))
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// step returns exponent and mantissa of the quantization step size of subband b with nb decomposition levels (E.1.1).
+func (q *jpxQuantization) step(b, nb, levels int) (int, int) {
+	if q.style == 1 {
+		return q.steps[0][0] - levels + nb, q.steps[0][1]
+	}
+	if b >= len(q.steps) {
+		b = len(q.steps) - 1
+	}
+	return q.steps[b][0], q.steps[b][1]
+}
+
+func (cs *jpxCodestream) tileHeader(i int) *jpxHeader {
+	if parts := cs.tiles[i]; len(parts) > 0 {
+		return parts[0].header
+	}
+	return newJPXHeader()
+}
+
+func (cs *jpxCodestream) newTile(i int) (*jpxTile, error) {
+	s := cs.siz
+	nx := ceilDiv(s.xsiz-s.xtosiz, s.xtsiz)
+	p, q := i%nx, i/nx
+
+	t := &jpxTile{
+		x0: maxInt(s.xtosiz+p*s.xtsiz, s.xosiz),
+		y0: maxInt(s.ytosiz+q*s.ytsiz, s.yosiz),
+		x1: minInt(s.xtosiz+(p+1)*s.xtsiz, s.xsiz),
+		y1: minInt(s.ytosiz+(q+1)*s.ytsiz, s.ysiz),
+	}
+
+	th, mh := cs.tileHeader(i), cs.main
+
+	t.cod = mh.cod
+	if th.cod != nil {
+		t.cod = th.cod
+	}
+
+	t.poc = mh.poc
+	if len(th.poc) > 0 {
+		t.poc = th.poc
+	}
+
+	for c, comp := range s.comps {
+
+		cstyle := mh.cs
+		if v, ok := mh.coc[c]; ok {
+			cstyle = v
+		}
+		if th.cs != nil {
+			cstyle = th.cs
+		}
+		if v, ok := th.coc[c]; ok {
+			cstyle = v
+		}
+
+		quant := mh.qcd
+		if v, ok := mh.qcc[c]; ok {
+			quant = v
+		}
+		if th.qcd != nil {
+			quant = th.qcd
+		}
+		if v, ok := th.qcc[c]; ok {
+			quant = v
+		}
+
+		roi := mh.rgn[c]
+		if v, ok := th.rgn[c]; ok {
+			roi = v
+		}
+
+		tc := &jpxTileComponent{
+			x0: ceilDiv(t.x0, comp.dx),
+			y0: ceilDiv(t.y0, comp.dy),
+			x1: ceilDiv(t.x1, comp.dx),
+			y1: ceilDiv(t.y1, comp.dy),
+			cs: cstyle, q: quant, roi: roi,
+		}
+
+		if err := tc.init(comp, t); err != nil {
+			return nil, err
+		}
+
+		t.comps = append(t.comps, tc)
+	}
+
+	return t, nil
+}
+
+// init sets up resolutions, subbands, precincts and code-blocks of a tile-component (B.5 - B.7).
+func (tc *jpxTileComponent) init(comp jpxComponent, t *jpxTile) error {
+	nl := tc.cs.levels
+
+	for r := 0; r <= nl; r++ {
+		shift := nl - r
+		res := &jpxResolution{
+			x0: ceilShift(tc.x0, shift),
+			y0: ceilShift(tc.y0, shift),
+			x1: ceilShift(tc.x1, shift),
+			y1: ceilShift(tc.y1, shift),
+		}
+
+		if r == 0 {
+			res.bands = []*jpxBand{{orientation: jpxLL, x0: res.x0, y0: res.y0, x1: res.x1, y1: res.y1}}
+		} else {
+			nb := nl - r + 1
+			for o := jpxHL; o <= jpxHH; o++ {
+				xo, yo := o&1, o>>1
+				res.bands = append(res.bands, &jpxBand{
+					orientation: o,
+					x0:          ceilShift(tc.x0-(xo<<uint(nb-1)), nb),
+					y0:          ceilShift(tc.y0-(yo<<uint(nb-1)), nb),
+					x1:          ceilShift(tc.x1-(xo<<uint(nb-1)), nb),
+					y1:          ceilShift(tc.y1-(yo<<uint(nb-1)), nb),
+				})
+			}
+		}
+
+		for _, b := range res.bands {
+			nb, i := nl, 0
+			if r > 0 {
+				nb, i = nl-r+1, 3*(r-1)+b.orientation
+			}
+			eps, mu := tc.q.step(i, nb, nl)
+			b.mb = tc.q.guardBits + eps - 1 + tc.roi
+			if b.mb > 31 || b.mb < 0 {
+				return errors.New("pdfcpu: jpx: unsupported number of bit-planes")
+			}
+			b.delta = 1
+			if !tc.cs.reversible {
+				gain := [4]int{0, 1, 1, 2}[b.orientation]
+				b.delta = math.Ldexp(1+float64(mu)/2048, comp.prec+gain-eps)
+			}
+			if w, h := b.x1-b.x0, b.y1-b.y0; w > 0 && h > 0 {
+				b.coeffs = make([]float32, w*h)
+			}
+		}
+
+		ppx, ppy := tc.cs.ppx[r], tc.cs.ppy[r]
+		xcb, ycb := minInt(tc.cs.xcb, ppx), minInt(tc.cs.ycb, ppy)
+		bpx, bpy := ppx, ppy
+		if r > 0 {
+			bpx, bpy = maxInt(ppx-1, 0), maxInt(ppy-1, 0)
+			xcb, ycb = minInt(tc.cs.xcb, bpx), minInt(tc.cs.ycb, bpy)
+		}
+
+		var pw, ph int
+		if res.x1 > res.x0 && res.y1 > res.y0 {
+			pw = ceilShift(res.x1, ppx) - res.x0>>uint(ppx)
+			ph = ceilShift(res.y1, ppy) - res.y0>>uint(ppy)
+		}
+		if pw*ph > maxJPXSamples/64 {
+			return errors.New("pdfcpu: jpx: too many precincts")
+		}
+
+		for py := 0; py < ph; py++ {
+			for px := 0; px < pw; px++ {
+				kx, ky := res.x0>>uint(ppx)+px, res.y0>>uint(ppy)+py
+				prec := &jpxPrecinct{
+					x: maxInt(t.x0, (kx<<uint(ppx+shift))*comp.dx),
+					y: maxInt(t.y0, (ky<<uint(ppy+shift))*comp.dy),
+				}
+				for _, b := range res.bands {
+					pb := &jpxPrecinctBand{band: b}
+					x0, x1 := maxInt(b.x0, kx<<uint(bpx)), minInt(b.x1, (kx+1)<<uint(bpx))
+					y0, y1 := maxInt(b.y0, ky<<uint(bpy)), minInt(b.y1, (ky+1)<<uint(bpy))
+					var cw, ch int
+					if x1 > x0 && y1 > y0 {
+						cx0, cy0 := x0>>uint(xcb), y0>>uint(ycb)
+						cw, ch = ceilShift(x1, xcb)-cx0, ceilShift(y1, ycb)-cy0
+						for cy := cy0; cy < cy0+ch; cy++ {
+							for cx := cx0; cx < cx0+cw; cx++ {
+								pb.cbs = append(pb.cbs, &jpxCodeBlock{
+									x0: maxInt(x0, cx<<uint(xcb)),
+									y0: maxInt(y0, cy<<uint(ycb)),
+									x1: minInt(x1, (cx+1)<<uint(xcb)),
+									y1: minInt(y1, (cy+1)<<uint(ycb)),
+								})
+							}
+						}
+					}
+					pb.incl, pb.zbp = newJPXTagTree(cw, ch), newJPXTagTree(cw, ch)
+					prec.bands = append(prec.bands, pb)
+				}
+				res.precincts = append(res.precincts, prec)
+			}
+		}
+
+		tc.res = append(tc.res, res)
+	}
+
+	return nil
+}
+
+// decodeTile decodes tile i into reconstructed tile-component samples.
+func (cs *jpxCodestream) decodeTile(i int) (*jpxTile, error) {
+	t, err := cs.newTile(i)
+	if err != nil {
+		return nil, err
+	}
+
+	var data, headers []byte
+	for _, tp := range cs.tiles[i] {
+		data = append(data, tp.data...)
+		if tp.headers != nil {
+			headers = append(headers, tp.headers...)
+		}
+	}
+
+	t.decodePackets(data, headers)
+
+	for _, tc := range t.comps {
+		for _, res := range tc.res {
+			for _, prec := range res.precincts {
+				for _, pb := range prec.bands {
+					for _, cb := range pb.cbs {
+						if err := tc.decodeCodeBlock(cb, pb.band); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}
+		}
+		tc.reconstruct()
+	}
+
+	if t.cod.mct && len(t.comps) >= 3 {
+		t.inverseMCT()
+	}
+
+	return t, nil
+}
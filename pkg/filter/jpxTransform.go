@@ -0,0 +1,177 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package filter
+
+import "math"
+
+// JPEG 2000 inverse wavelet and component transformations, see ITU-T T.800 Annex F and G.
+
+// Lifting parameters of the 9-7 irreversible filter (Table F.4).
+const (
+	jpxAlpha = -1.586134342059924
+	jpxBeta  = -0.052980118572961
+	jpxGamma = 0.882911075530934
+	jpxDelta = 0.443506852043971
+	jpxK     = 1.230174104914001
+)
+
+// neighbours returns the samples left and right of x[j] using symmetric extension.
+func neighbours(x []float32, j int) (float32, float32) {
+	n := len(x)
+	l, r := j-1, j+1
+	if l < 0 {
+		l = 1
+	}
+	if r >= n {
+		r = n - 2
+	}
+	return x[l], x[r]
+}
+
+// lift adds c times the sum of both neighbours to every second sample starting at j0.
+func lift(x []float32, j0 int, c float32) {
+	for j := j0; j < len(x); j += 2 {
+		l, r := neighbours(x, j)
+		x[j] += c * (l + r)
+	}
+}
+
+// idwt1D is the 1D sub-band reconstruction of the samples x located at i0, i0+1, ... (F.3.6).
+func idwt1D(x []float32, i0 int, reversible bool) {
+	if len(x) == 1 {
+		if i0&1 == 1 {
+			x[0] /= 2
+		}
+		return
+	}
+
+	even, odd := i0&1, 1-i0&1
+
+	if reversible {
+		// F.3.8.1
+		for j := even; j < len(x); j += 2 {
+			l, r := neighbours(x, j)
+			x[j] -= float32(math.Floor(float64(l+r+2) / 4))
+		}
+		for j := odd; j < len(x); j += 2 {
+			l, r := neighbours(x, j)
+			x[j] += float32(math.Floor(float64(l+r) / 2))
+		}
+		return
+	}
+
+	// F.3.8.2
+	for j := even; j < len(x); j += 2 {
+		x[j] *= jpxK
+	}
+	for j := odd; j < len(x); j += 2 {
+		x[j] *= 1 / jpxK
+	}
+	lift(x, even, -jpxDelta)
+	lift(x, odd, -jpxGamma)
+	lift(x, even, -jpxBeta)
+	lift(x, odd, -jpxAlpha)
+}
+
+// idwt2D reconstructs resolution res from the samples ll of the next lower resolution and its subbands (F.3.2).
+func (tc *jpxTileComponent) idwt2D(ll []float32, low, res *jpxResolution) []float32 {
+	w, h := res.x1-res.x0, res.y1-res.y0
+	if w <= 0 || h <= 0 {
+		return nil
+	}
+
+	a := make([]float32, w*h)
+
+	// 2D_INTERLEAVE
+	interleave := func(coeffs []float32, x0, y0, x1, y1, xo, yo int) {
+		if coeffs == nil {
+			return
+		}
+		bw := x1 - x0
+		for y := y0; y < y1; y++ {
+			row := a[(2*y+yo-res.y0)*w:]
+			src := coeffs[(y-y0)*bw:]
+			for x := x0; x < x1; x++ {
+				row[2*x+xo-res.x0] = src[x-x0]
+			}
+		}
+	}
+
+	interleave(ll, low.x0, low.y0, low.x1, low.y1, 0, 0)
+	for _, b := range res.bands {
+		if b.x1 > b.x0 && b.y1 > b.y0 {
+			interleave(b.coeffs, b.x0, b.y0, b.x1, b.y1, b.orientation&1, b.orientation>>1)
+		}
+	}
+
+	reversible := tc.cs.reversible
+
+	// HOR_SR
+	for y := 0; y < h; y++ {
+		idwt1D(a[y*w:(y+1)*w], res.x0, reversible)
+	}
+
+	// VER_SR
+	col := make([]float32, h)
+	for x := 0; x < w; x++ {
+		for y := range col {
+			col[y] = a[y*w+x]
+		}
+		idwt1D(col, res.y0, reversible)
+		for y, v := range col {
+			a[y*w+x] = v
+		}
+	}
+
+	return a
+}
+
+// reconstruct computes the tile-component samples from the subband coefficients (F.3.1).
+func (tc *jpxTileComponent) reconstruct() {
+	a := tc.res[0].bands[0].coeffs
+	for r := 1; r < len(tc.res); r++ {
+		a = tc.idwt2D(a, tc.res[r-1], tc.res[r])
+	}
+	if a == nil {
+		a = make([]float32, (tc.x1-tc.x0)*(tc.y1-tc.y0))
+	}
+	tc.data = a
+}
+
+// inverseMCT applies the inverse multiple component transformation to the first 3 tile-components (G.2, G.3).
+func (t *jpxTile) inverseMCT() {
+	c0, c1, c2 := t.comps[0].data, t.comps[1].data, t.comps[2].data
+	if len(c0) != len(c1) || len(c0) != len(c2) {
+		return
+	}
+
+	if t.comps[0].cs.reversible {
+		for i, y0 := range c0 {
+			y1, y2 := c1[i], c2[i]
+			g := y0 - float32(math.Floor(float64(y1+y2)/4))
+			c0[i], c1[i], c2[i] = y2+g, g, y1+g
+		}
+		return
+	}
+
+	for i, y := range c0 {
+		cb, cr := c1[i], c2[i]
+		c0[i] = y + 1.402*cr
+		c1[i] = y - 0.34413*cb - 0.71414*cr
+		c2[i] = y + 1.772*cb
+	}
+}
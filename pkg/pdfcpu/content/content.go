@@ -0,0 +1,162 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+// Package content provides parsing and serialization of content streams on operator level.
+package content
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// InlineImage represents an inline image (BI ... ID ... EI).
+type InlineImage struct {
+	Dict types.Dict // abbreviated keys and values as found in the content stream
+	Data []byte
+}
+
+// Operation represents an operator along with its operands.
+// String literals are kept escaped, see types.Unescape.
+// A nil operand represents the null object.
+type Operation struct {
+	Operator string
+	Operands []types.Object
+	Image    *InlineImage // BI only
+}
+
+// Operand returns the operand of op at index i or nil.
+func (op Operation) Operand(i int) types.Object {
+	if i < 0 || i >= len(op.Operands) {
+		return nil
+	}
+	return op.Operands[i]
+}
+
+func writeObject(sb *strings.Builder, o types.Object) {
+	switch o := o.(type) {
+
+	case nil:
+		sb.WriteString("null")
+
+	case types.Float:
+		s := strconv.FormatFloat(o.Value(), 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			// Preserve the type.
+			s += ".0"
+		}
+		sb.WriteString(s)
+
+	case types.Array:
+		sb.WriteByte('[')
+		for i, o1 := range o {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			writeObject(sb, o1)
+		}
+		sb.WriteByte(']')
+
+	case types.Dict:
+		keys := make([]string, 0, len(o))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sb.WriteString("<<")
+		for _, k := range keys {
+			sb.WriteString(types.Name(k).PDFString())
+			sb.WriteByte(' ')
+			writeObject(sb, o[k])
+		}
+		sb.WriteString(">>")
+
+	default:
+		sb.WriteString(o.PDFString())
+	}
+}
+
+func (op Operation) write(sb *strings.Builder) {
+	if op.Operator == "BI" && op.Image != nil {
+		sb.WriteString("BI")
+		keys := make([]string, 0, len(op.Image.Dict))
+		for k := range op.Image.Dict {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sb.WriteByte(' ')
+			sb.WriteString(types.Name(k).PDFString())
+			sb.WriteByte(' ')
+			writeObject(sb, op.Image.Dict[k])
+		}
+		sb.WriteString(" ID ")
+		sb.Write(op.Image.Data)
+		sb.WriteString("\nEI")
+		return
+	}
+
+	for i, o := range op.Operands {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		writeObject(sb, o)
+	}
+	if op.Operator == "" {
+		return
+	}
+	if len(op.Operands) > 0 {
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(op.Operator)
+}
+
+// String returns op in PDF syntax.
+func (op Operation) String() string {
+	var sb strings.Builder
+	op.write(&sb)
+	return sb.String()
+}
+
+// Bytes serializes ops into a content stream, one operation per line.
+func Bytes(ops []Operation) []byte {
+	var sb strings.Builder
+	for _, op := range ops {
+		op.write(&sb)
+		sb.WriteByte('\n')
+	}
+	return []byte(sb.String())
+}
+
+// Write serializes ops into a content stream written to w.
+func Write(w io.Writer, ops []Operation) error {
+	_, err := w.Write(Bytes(ops))
+	return err
+}
+
+// Filter returns the operations of ops for which keep returns true.
+func Filter(ops []Operation, keep func(op Operation) bool) []Operation {
+	res := make([]Operation, 0, len(ops))
+	for _, op := range ops {
+		if keep(op) {
+			res = append(res, op)
+		}
+	}
+	return res
+}
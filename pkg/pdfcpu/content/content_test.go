@@ -0,0 +1,118 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package content
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestParse(t *testing.T) {
+	s := `q 1 0 0 1 72.5 -10 cm % comment
+/GS0 gs /F1 12 Tf
+[(Hello) -250 <576f726c64>] TJ
+/OC /MC0 BDC (a\)b) Tj EMC
+/Span << /ActualText (x) /MCID 3 >> BDC EMC
+BI /W 2 /H 1 /BPC 8 /CS /G ID ` + "\xffE" + `
+EI Q`
+
+	ops, err := Parse([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Operation{
+		{Operator: "q"},
+		{Operator: "cm", Operands: []types.Object{types.Integer(1), types.Integer(0), types.Integer(0), types.Integer(1), types.Float(72.5), types.Integer(-10)}},
+		{Operator: "gs", Operands: []types.Object{types.Name("GS0")}},
+		{Operator: "Tf", Operands: []types.Object{types.Name("F1"), types.Integer(12)}},
+		{Operator: "TJ", Operands: []types.Object{types.Array{types.StringLiteral("Hello"), types.Integer(-250), types.HexLiteral("576f726c64")}}},
+		{Operator: "BDC", Operands: []types.Object{types.Name("OC"), types.Name("MC0")}},
+		{Operator: "Tj", Operands: []types.Object{types.StringLiteral(`a\)b`)}},
+		{Operator: "EMC"},
+		{Operator: "BDC", Operands: []types.Object{types.Name("Span"), types.Dict{"ActualText": types.StringLiteral("x"), "MCID": types.Integer(3)}}},
+		{Operator: "EMC"},
+		{Operator: "BI", Image: &InlineImage{
+			Dict: types.Dict{"W": types.Integer(2), "H": types.Integer(1), "BPC": types.Integer(8), "CS": types.Name("G")},
+			Data: []byte("\xffE"),
+		}},
+		{Operator: "Q"},
+	}
+
+	if !reflect.DeepEqual(ops, want) {
+		t.Fatalf("got:\n%v\nwant:\n%v", ops, want)
+	}
+
+	// Serialize and parse again.
+	ops2, err := Parse(Bytes(ops))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ops, ops2) {
+		t.Fatalf("roundtrip:\n%s", Bytes(ops))
+	}
+}
+
+func TestOperationString(t *testing.T) {
+	for _, tt := range []struct {
+		op   Operation
+		want string
+	}{
+		{Operation{Operator: "Q"}, "Q"},
+		{Operation{Operator: "re", Operands: []types.Object{types.Float(0.5), types.Float(1), types.Integer(100), types.Integer(50)}}, "0.5 1.0 100 50 re"},
+		{Operation{Operator: "Do", Operands: []types.Object{types.Name("Im 1")}}, "/Im#201 Do"},
+		{Operation{Operator: "d", Operands: []types.Object{types.Array{types.Integer(3)}, types.Integer(0)}}, "[3] 0 d"},
+		{Operation{Operator: "BDC", Operands: []types.Object{types.Name("P"), types.Dict{"MCID": types.Integer(0), "E": nil}}}, "/P <</E null/MCID 0>> BDC"},
+		{Operation{Operands: []types.Object{types.Boolean(true)}}, "true"},
+	} {
+		if got := tt.op.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	ops, err := Parse([]byte("q /Im0 Do Q q /Im1 Do Q /Im0 Do"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop all Do operations for Im0.
+	ops = Filter(ops, func(op Operation) bool {
+		return op.Operator != "Do" || op.Operand(0) != types.Name("Im0")
+	})
+
+	if got, want := string(Bytes(ops)), "q\nQ\nq\n/Im1 Do\nQ\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseCorrupt(t *testing.T) {
+	for _, s := range []string{
+		"(unterminated Tj",
+		"<414 Tj",
+		"[(a) 1 TJ",
+		"<< /MCID 0 BDC",
+		"BI /W 1 /H 1 ID xyz",
+	} {
+		if _, err := Parse([]byte(s)); err == nil {
+			t.Errorf("%q: missing error", s)
+		}
+	}
+}
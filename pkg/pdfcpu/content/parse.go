@@ -0,0 +1,425 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package content
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+var (
+	errStringLiteralCorrupt = errors.New("pdfcpu: corrupt string literal")
+	errHexLiteralCorrupt    = errors.New("pdfcpu: corrupt hex literal")
+	errArrayCorrupt         = errors.New("pdfcpu: corrupt array")
+	errDictCorrupt          = errors.New("pdfcpu: corrupt dict")
+	errInlineImageCorrupt   = errors.New("pdfcpu: corrupt inline image")
+)
+
+// Parser splits a content stream into operations.
+//
+// Parsing is lenient the way PDF viewers are:
+// Stray closing delimiters and operators within arrays and dicts get skipped,
+// malformed numbers are read as 0.
+// The parser also copes with PostScript like syntax as found in CMap streams.
+type Parser struct {
+	bb  []byte
+	pos int
+}
+
+// NewParser returns a parser for the content stream bb.
+func NewParser(bb []byte) *Parser {
+	return &Parser{bb: bb}
+}
+
+// Parse returns all operations of the content stream bb.
+func Parse(bb []byte) ([]Operation, error) {
+	var ops []Operation
+	p := NewParser(bb)
+	for {
+		op, err := p.Next()
+		if err == io.EOF {
+			return ops, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, *op)
+	}
+}
+
+func whitespace(c byte) bool {
+	return c == 0x00 || c == 0x09 || c == 0x0A || c == 0x0C || c == 0x0D || c == 0x20
+}
+
+func delimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (p *Parser) skipWhitespaceAndComments() {
+	for p.pos < len(p.bb) {
+		c := p.bb[p.pos]
+		if whitespace(c) {
+			p.pos++
+			continue
+		}
+		if c != '%' {
+			return
+		}
+		for p.pos < len(p.bb) && p.bb[p.pos] != 0x0A && p.bb[p.pos] != 0x0D {
+			p.pos++
+		}
+	}
+}
+
+// Next returns the next operation.
+// Operands trailing the last operator are returned as an operation without operator.
+// io.EOF signals the end of the content stream.
+func (p *Parser) Next() (*Operation, error) {
+	var operands []types.Object
+	for {
+		o, op, err := p.next()
+		if err == io.EOF && len(operands) > 0 {
+			return &Operation{Operands: operands}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if op == "" {
+			operands = append(operands, o)
+			continue
+		}
+		if op == "BI" {
+			img, err := p.inlineImage()
+			if err != nil {
+				return nil, err
+			}
+			return &Operation{Operator: op, Image: img}, nil
+		}
+		return &Operation{Operator: op, Operands: operands}, nil
+	}
+}
+
+// next returns either an operand or an operator.
+func (p *Parser) next() (types.Object, string, error) {
+	for {
+		p.skipWhitespaceAndComments()
+		if p.pos >= len(p.bb) {
+			return nil, "", io.EOF
+		}
+
+		c := p.bb[p.pos]
+
+		switch c {
+		case '(':
+			o, err := p.stringLiteral()
+			return o, "", err
+		case '<':
+			if p.pos+1 < len(p.bb) && p.bb[p.pos+1] == '<' {
+				p.pos += 2
+				o, err := p.dict()
+				return o, "", err
+			}
+			o, err := p.hexLiteral()
+			return o, "", err
+		case '[':
+			p.pos++
+			o, err := p.array()
+			return o, "", err
+		case '/':
+			return p.name(), "", nil
+		case '{', '}':
+			p.pos++
+			return nil, string(c), nil
+		case ')', '>', ']':
+			// Unbalanced delimiter, skip.
+			p.pos++
+			continue
+		}
+
+		kw := p.keyword()
+		if kw == "" {
+			p.pos++
+			continue
+		}
+
+		if o, ok := literal(kw); ok {
+			return o, "", nil
+		}
+
+		return nil, kw, nil
+	}
+}
+
+func (p *Parser) keyword() string {
+	i := p.pos
+	for p.pos < len(p.bb) && !whitespace(p.bb[p.pos]) && !delimiter(p.bb[p.pos]) {
+		p.pos++
+	}
+	return string(p.bb[i:p.pos])
+}
+
+func literal(s string) (types.Object, bool) {
+	switch s {
+	case "true":
+		return types.Boolean(true), true
+	case "false":
+		return types.Boolean(false), true
+	case "null":
+		return nil, true
+	}
+
+	c := s[0]
+	if !(c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.') {
+		return nil, false
+	}
+
+	if !strings.Contains(s, ".") {
+		if i, err := strconv.Atoi(s); err == nil {
+			return types.Integer(i), true
+		}
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		// Be tolerant with malformed numbers like "--1" or "0.0.1".
+		return types.Integer(0), true
+	}
+
+	return types.Float(f), true
+}
+
+// stringLiteral returns the escaped string literal at the current position.
+func (p *Parser) stringLiteral() (types.Object, error) {
+	p.pos++
+	i := p.pos
+	depth := 1
+	for p.pos < len(p.bb) {
+		c := p.bb[p.pos]
+		switch c {
+		case '\\':
+			p.pos++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				s := string(p.bb[i:p.pos])
+				p.pos++
+				return types.StringLiteral(s), nil
+			}
+		}
+		p.pos++
+	}
+	return nil, errStringLiteralCorrupt
+}
+
+func (p *Parser) hexLiteral() (types.Object, error) {
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.bb) {
+		c := p.bb[p.pos]
+		p.pos++
+		if c == '>' {
+			s := sb.String()
+			if len(s)%2 == 1 {
+				s += "0"
+			}
+			return types.HexLiteral(s), nil
+		}
+		if whitespace(c) {
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return nil, errHexLiteralCorrupt
+}
+
+func (p *Parser) name() types.Object {
+	p.pos++
+	i := p.pos
+	for p.pos < len(p.bb) && !whitespace(p.bb[p.pos]) && !delimiter(p.bb[p.pos]) {
+		p.pos++
+	}
+	s := string(p.bb[i:p.pos])
+	if strings.IndexByte(s, '#') < 0 {
+		return types.Name(s)
+	}
+
+	var sb strings.Builder
+	for j := 0; j < len(s); j++ {
+		if s[j] == '#' && j+2 < len(s) {
+			if b, err := strconv.ParseUint(s[j+1:j+3], 16, 8); err == nil {
+				sb.WriteByte(byte(b))
+				j += 2
+				continue
+			}
+		}
+		sb.WriteByte(s[j])
+	}
+	return types.Name(sb.String())
+}
+
+func (p *Parser) array() (types.Object, error) {
+	a := types.Array{}
+	for {
+		p.skipWhitespaceAndComments()
+		if p.pos >= len(p.bb) {
+			return nil, errArrayCorrupt
+		}
+		if p.bb[p.pos] == ']' {
+			p.pos++
+			return a, nil
+		}
+		o, op, err := p.next()
+		if err != nil {
+			if err == io.EOF {
+				err = errArrayCorrupt
+			}
+			return nil, err
+		}
+		if op != "" {
+			// Operators are not allowed within arrays.
+			continue
+		}
+		a = append(a, o)
+	}
+}
+
+// dictEntry returns the next key value pair of a dict or inline image dict.
+// end signals the operator terminating an inline image dict.
+func (p *Parser) dictEntry() (key string, val types.Object, end string, err error) {
+	k, op, err := p.next()
+	if err != nil || op != "" {
+		return "", nil, op, err
+	}
+	p.skipWhitespaceAndComments()
+	if p.pos < len(p.bb) && p.bb[p.pos] == '>' {
+		// Missing value.
+		return "", nil, "", nil
+	}
+	v, op, err := p.next()
+	if err != nil || op != "" {
+		return "", nil, op, err
+	}
+	n, ok := k.(types.Name)
+	if !ok {
+		return "", nil, "", nil
+	}
+	return n.Value(), v, "", nil
+}
+
+func (p *Parser) dict() (types.Object, error) {
+	d := types.Dict{}
+	for {
+		p.skipWhitespaceAndComments()
+		if p.pos >= len(p.bb) {
+			return nil, errDictCorrupt
+		}
+		if p.bb[p.pos] == '>' {
+			p.pos++
+			if p.pos < len(p.bb) && p.bb[p.pos] == '>' {
+				p.pos++
+			}
+			return d, nil
+		}
+		k, v, _, err := p.dictEntry()
+		if err != nil {
+			if err == io.EOF {
+				err = errDictCorrupt
+			}
+			return nil, err
+		}
+		if k != "" {
+			d[k] = v
+		}
+	}
+}
+
+// inlineImageLength returns the length of the image data as declared by the inline image dict.
+func inlineImageLength(d types.Dict) int {
+	for _, k := range []string{"L", "Length"} {
+		if i, ok := d[k].(types.Integer); ok {
+			return i.Value()
+		}
+	}
+	return -1
+}
+
+// endOfInlineImage returns true if the inline image data ends at position i.
+func (p *Parser) endOfInlineImage(i int) bool {
+	j := i
+	for j < len(p.bb) && whitespace(p.bb[j]) {
+		j++
+	}
+	return j > i && bytes.HasPrefix(p.bb[j:], []byte("EI")) && (j+2 == len(p.bb) || whitespace(p.bb[j+2]) || delimiter(p.bb[j+2]))
+}
+
+// inlineImage parses the inline image dict following BI and the image data up to and including EI.
+func (p *Parser) inlineImage() (*InlineImage, error) {
+	img := &InlineImage{Dict: types.Dict{}}
+	for {
+		k, v, op, err := p.dictEntry()
+		if err != nil {
+			return nil, errInlineImageCorrupt
+		}
+		if op == "ID" {
+			break
+		}
+		if k != "" {
+			img.Dict[k] = v
+		}
+	}
+
+	// A single whitespace character separates ID from the image data.
+	if p.pos < len(p.bb) && whitespace(p.bb[p.pos]) {
+		p.pos++
+	}
+	start := p.pos
+
+	// Rely on a declared length if it is consistent.
+	if n := inlineImageLength(img.Dict); n >= 0 && start+n <= len(p.bb) && p.endOfInlineImage(start+n) {
+		img.Data = p.bb[start : start+n]
+		p.pos = bytes.Index(p.bb[start+n:], []byte("EI")) + start + n + 2
+		return img, nil
+	}
+
+	// Otherwise look for EI preceded by whitespace.
+	for i := start; i < len(p.bb); i++ {
+		j := bytes.Index(p.bb[i:], []byte("EI"))
+		if j < 0 {
+			break
+		}
+		i += j
+		if (i == start || whitespace(p.bb[i-1])) && (i+2 == len(p.bb) || whitespace(p.bb[i+2]) || delimiter(p.bb[i+2])) {
+			// The whitespace preceding EI is not part of the image data.
+			end := i
+			if end > start {
+				end--
+			}
+			img.Data = p.bb[start:end]
+			p.pos = i + 2
+			return img, nil
+		}
+	}
+
+	return nil, errInlineImageCorrupt
+}
@@ -35,6 +35,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
 	"github.com/pdfcpu/pdfcpu/pkg/log"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
@@ -44,6 +45,9 @@ import (
 	"golang.org/x/text/unicode/norm"
 )
 
+// identityCryptFilter passes data through without encryption.
+const identityCryptFilter = "Identity"
+
 var (
 	pad = []byte{
 		0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41, 0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
@@ -67,6 +71,13 @@ var (
 		model.EXTRACTPAGES:            {1, 0},
 		model.EXTRACTCONTENT:          {1, 0},
 		model.EXTRACTMETADATA:         {1, 0},
+		model.EXTRACTTEXT:             {1, 0},
+		model.SEARCHTEXT:              {1, 0},
+		model.HIGHLIGHTTEXT:           {1, 1},
+		model.REDACT:                  {0, 1},
+		model.SANITIZE:                {0, 1},
+		model.RENDER:                  {1, 0},
+		model.EXPORT:                  {1, 0},
 		model.TRIM:                    {0, 1},
 		model.LISTATTACHMENTS:         {0, 0},
 		model.EXTRACTATTACHMENTS:      {1, 0},
@@ -128,7 +139,9 @@ var (
 )
 
 // NewEncryptDict creates a new EncryptDict using the standard security handler.
-func newEncryptDict(v model.Version, needAES bool, keyLength int, permissions int16) types.Dict {
+// If attachmentsOnly is true, encryption is restricted to embedded files (requires a key length of 128 or 256).
+// If encryptMetadata is false, metadata streams remain unencrypted (requires a key length of 128 or 256).
+func newEncryptDict(v model.Version, needAES bool, keyLength int, permissions int16, attachmentsOnly, encryptMetadata bool) types.Dict {
 	d := types.NewDict()
 
 	d.Insert("Filter", types.Name("Standard"))
@@ -152,11 +165,21 @@ func newEncryptDict(v model.Version, needAES bool, keyLength int, permissions in
 	// Set user access permission flags.
 	d.Insert("P", types.Integer(permissions))
 
-	d.Insert("StmF", types.Name("StdCF"))
-	d.Insert("StrF", types.Name("StdCF"))
+	authEvent := "DocOpen"
+
+	if attachmentsOnly {
+		// Leave the document as is and encrypt embedded files only (PDF 1.6).
+		d.Insert("StmF", types.Name(identityCryptFilter))
+		d.Insert("StrF", types.Name(identityCryptFilter))
+		d.Insert("EFF", types.Name("StdCF"))
+		authEvent = "EFOpen"
+	} else {
+		d.Insert("StmF", types.Name("StdCF"))
+		d.Insert("StrF", types.Name("StdCF"))
+	}
 
 	d1 := types.NewDict()
-	d1.Insert("AuthEvent", types.Name("DocOpen"))
+	d1.Insert("AuthEvent", types.Name(authEvent))
 
 	if needAES {
 		n := "AESV2"
@@ -175,6 +198,10 @@ func newEncryptDict(v model.Version, needAES bool, keyLength int, permissions in
 
 	d.Insert("CF", d2)
 
+	if !encryptMetadata {
+		d.Insert("EncryptMetadata", types.Boolean(false))
+	}
+
 	if keyLength == 256 {
 		d.Insert("U", types.NewHexLiteral(make([]byte, 48)))
 		d.Insert("O", types.NewHexLiteral(make([]byte, 48)))
@@ -739,12 +766,12 @@ func supportedCFEntry(d types.Dict) (bool, error) {
 	}
 
 	ae := d.NameEntry("AuthEvent")
-	if ae != nil && *ae != "DocOpen" {
+	if ae != nil && *ae != "DocOpen" && *ae != "EFOpen" {
 		return false, errors.New("pdfcpu: supportedCFEntry: invalid entry \"AuthEvent\"")
 	}
 
 	l := d.IntEntry("Length")
-	if l != nil && (*l < 5 || *l > 16) && *l != 32 && *l != 256 {
+	if l != nil && (*l < 5 || *l > 16) && *l != 32 && *l != 128 && *l != 256 {
 		return false, errors.New("pdfcpu: supportedCFEntry: invalid entry \"Length\"")
 	}
 
@@ -786,7 +813,7 @@ func Permissions(ctx *model.Context) (list []string) {
 func validatePermissions(ctx *model.Context) (bool, error) {
 	// Algorithm 3.2a 5.
 
-	if ctx.E.R != 5 && ctx.E.R != 6 {
+	if ctx.E.PubSec || ctx.E.R != 5 && ctx.E.R != 6 {
 		return true, nil
 	}
 
@@ -808,7 +835,7 @@ func validatePermissions(ctx *model.Context) (bool, error) {
 func writePermissions(ctx *model.Context, d types.Dict) error {
 	// Algorithm 3.10
 
-	if ctx.E.R != 5 && ctx.E.R != 6 {
+	if ctx.E.PubSec || ctx.E.R != 5 && ctx.E.R != 6 {
 		return nil
 	}
 
@@ -926,22 +953,55 @@ func getV(ctx *model.Context, d types.Dict, l int) (*int, error) {
 
 	return v, nil
 }
-func checkStmf(ctx *model.Context, stmf *string, cfDict types.Dict) error {
-	if stmf != nil && *stmf != "Identity" {
 
-		d := cfDict.DictEntry(*stmf)
-		if d == nil {
-			return errors.Errorf("pdfcpu: checkStmf: entry \"%s\" missing in \"CF\"", *stmf)
-		}
+// cryptFilter returns the crypt filter name of entry and whether it uses AES.
+func cryptFilter(ctx *model.Context, d types.Dict, entry, defaultName string) (string, bool, error) {
+	name := defaultName
+	if n := d.NameEntry(entry); n != nil {
+		name = *n
+	}
+
+	if name == identityCryptFilter {
+		return name, false, nil
+	}
 
-		aes, err := supportedCFEntry(d)
+	aes, ok := ctx.CryptFilters[name]
+	if !ok {
+		return "", false, errors.Errorf("pdfcpu: checkV: entry \"%s\" missing in \"CF\"", name)
+	}
+
+	return name, aes, nil
+}
+
+func checkCryptFilters(ctx *model.Context, d, cfDict types.Dict) (err error) {
+	ctx.CryptFilters = map[string]bool{}
+
+	for k, v := range cfDict {
+		d1, ok := v.(types.Dict)
+		if !ok {
+			return errors.Errorf("pdfcpu: checkV: corrupt entry \"%s\" in \"CF\"", k)
+		}
+		aes, err := supportedCFEntry(d1)
 		if err != nil {
-			return errors.Wrapf(err, "pdfcpu: checkStmv: unsupported \"%s\" entry in \"CF\"", *stmf)
+			return errors.Wrapf(err, "checkV: unsupported \"%s\" entry in \"CF\"", k)
 		}
-		ctx.AES4Streams = aes
+		ctx.CryptFilters[k] = aes
 	}
 
-	return nil
+	// StmF
+	if ctx.StmF, ctx.AES4Streams, err = cryptFilter(ctx, d, "StmF", identityCryptFilter); err != nil {
+		return err
+	}
+
+	// StrF
+	if ctx.StrF, ctx.AES4Strings, err = cryptFilter(ctx, d, "StrF", identityCryptFilter); err != nil {
+		return err
+	}
+
+	// EFF
+	ctx.EFF, ctx.AES4EmbeddedStreams, err = cryptFilter(ctx, d, "EFF", ctx.StmF)
+
+	return err
 }
 
 func checkV(ctx *model.Context, d types.Dict, l int) (*int, error) {
@@ -961,42 +1021,230 @@ func checkV(ctx *model.Context, d types.Dict, l int) (*int, error) {
 		return nil, errors.Errorf("pdfcpu: checkV: required entry \"CF\" missing.")
 	}
 
-	// StmF
-	stmf := d.NameEntry("StmF")
-	err = checkStmf(ctx, stmf, cfDict)
+	if err := checkCryptFilters(ctx, d, cfDict); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// encryptStrings returns true if strings are subject to encryption.
+func encryptStrings(ctx *model.Context) bool {
+	return ctx.EncKey != nil && ctx.StrF != identityCryptFilter
+}
+
+// embeddedFileStreams returns the object numbers of all streams referenced by the EF entry of a file specification.
+// The Type entry of an embedded file stream is optional.
+// File specifications are located via the EmbeddedFiles name tree and via file attachment annotations.
+func embeddedFileStreams(xRefTable *model.XRefTable) (types.IntSet, error) {
+	objNrs, visited := types.IntSet{}, types.IntSet{}
+
+	if xRefTable.Root == nil {
+		return objNrs, nil
+	}
+
+	rootDict, err := xRefTable.DereferenceDict(*xRefTable.Root)
+	if err != nil || rootDict == nil {
+		return nil, err
+	}
+
+	names, err := xRefTable.DereferenceDict(rootDict["Names"])
 	if err != nil {
 		return nil, err
 	}
 
-	// StrF
-	strf := d.NameEntry("StrF")
-	if strf != nil && *strf != "Identity" {
-		d1 := cfDict.DictEntry(*strf)
-		if d1 == nil {
-			return nil, errors.Errorf("pdfcpu: checkV: entry \"%s\" missing in \"CF\"", *strf)
+	if names != nil {
+		if err := collectEmbeddedFilesNameTree(xRefTable, names["EmbeddedFiles"], objNrs, visited); err != nil {
+			return nil, err
 		}
-		aes, err := supportedCFEntry(d1)
-		if err != nil {
-			return nil, errors.Wrapf(err, "checkV: unsupported \"%s\" entry in \"CF\"", *strf)
+	}
+
+	if err := collectFileAttachments(xRefTable, rootDict["Pages"], objNrs, visited); err != nil {
+		return nil, err
+	}
+
+	return objNrs, nil
+}
+
+// firstVisit returns false for indirect references already visited in order to prevent cycles.
+func firstVisit(o types.Object, visited types.IntSet) bool {
+	ir, ok := o.(types.IndirectRef)
+	if !ok {
+		return true
+	}
+	objNr := ir.ObjectNumber.Value()
+	if visited[objNr] {
+		return false
+	}
+	visited[objNr] = true
+	return true
+}
+
+func collectEmbeddedFileStreams(xRefTable *model.XRefTable, fileSpec types.Object, objNrs types.IntSet) error {
+	d, err := xRefTable.DereferenceDict(fileSpec)
+	if err != nil || d == nil {
+		// File specification strings do not embed files.
+		return nil
+	}
+
+	ef, err := xRefTable.DereferenceDict(d["EF"])
+	if err != nil {
+		return err
+	}
+
+	for _, v := range ef {
+		if ir, ok := v.(types.IndirectRef); ok {
+			objNrs[ir.ObjectNumber.Value()] = true
 		}
-		ctx.AES4Strings = aes
 	}
 
-	// EFF
-	eff := d.NameEntry("EFF")
-	if eff != nil && *eff != "Identity" {
-		d := cfDict.DictEntry(*eff)
-		if d == nil {
-			return nil, errors.Errorf("pdfcpu: checkV: entry \"%s\" missing in \"CF\"", *eff)
+	return nil
+}
+
+func collectEmbeddedFilesNameTree(xRefTable *model.XRefTable, o types.Object, objNrs, visited types.IntSet) error {
+	if !firstVisit(o, visited) {
+		return nil
+	}
+
+	d, err := xRefTable.DereferenceDict(o)
+	if err != nil || d == nil {
+		return err
+	}
+
+	kids, err := xRefTable.DereferenceArray(d["Kids"])
+	if err != nil {
+		return err
+	}
+
+	for _, kid := range kids {
+		if err := collectEmbeddedFilesNameTree(xRefTable, kid, objNrs, visited); err != nil {
+			return err
 		}
-		aes, err := supportedCFEntry(d)
+	}
+
+	names, err := xRefTable.DereferenceArray(d["Names"])
+	if err != nil {
+		return err
+	}
+
+	// Names holds key value pairs.
+	for i := 1; i < len(names); i += 2 {
+		if err := collectEmbeddedFileStreams(xRefTable, names[i], objNrs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func collectFileAttachments(xRefTable *model.XRefTable, o types.Object, objNrs, visited types.IntSet) error {
+	if !firstVisit(o, visited) {
+		return nil
+	}
+
+	d, err := xRefTable.DereferenceDict(o)
+	if err != nil || d == nil {
+		return err
+	}
+
+	kids, err := xRefTable.DereferenceArray(d["Kids"])
+	if err != nil {
+		return err
+	}
+
+	for _, kid := range kids {
+		if err := collectFileAttachments(xRefTable, kid, objNrs, visited); err != nil {
+			return err
+		}
+	}
+
+	annots, err := xRefTable.DereferenceArray(d["Annots"])
+	if err != nil {
+		return err
+	}
+
+	for _, o := range annots {
+		annot, err := xRefTable.DereferenceDict(o)
 		if err != nil {
-			return nil, errors.Wrapf(err, "checkV: unsupported \"%s\" entry in \"CF\"", *eff)
+			return err
+		}
+		if annot == nil || annot.Subtype() == nil || *annot.Subtype() != "FileAttachment" {
+			continue
+		}
+		if err := collectEmbeddedFileStreams(xRefTable, annot["FS"], objNrs); err != nil {
+			return err
 		}
-		ctx.AES4EmbeddedStreams = aes
 	}
 
-	return v, nil
+	return nil
+}
+
+// ownEmbeddedFileCryptFilter returns true if embedded file streams use a crypt filter other than streams in general.
+func ownEmbeddedFileCryptFilter(ctx *model.Context) bool {
+	return (ctx.EncKey != nil || ctx.EmbeddedFilesLocked) && ctx.EFF != ctx.StmF
+}
+
+// embeddedFilesOnlyEncryption returns true if encryption is restricted to embedded file streams.
+func embeddedFilesOnlyEncryption(ctx *model.Context) bool {
+	return ctx.StmF == identityCryptFilter && ctx.StrF == identityCryptFilter && ctx.EFF != "" && ctx.EFF != identityCryptFilter
+}
+
+func isEmbeddedFileStream(ctx *model.Context, sd *types.StreamDict, objNr int) bool {
+	return (sd.Type() != nil && *sd.Type() == "EmbeddedFile") || ctx.EmbeddedFileStreams[objNr]
+}
+
+// streamCryptFilterPending returns true if the crypt filter of sd depends on sd being an embedded file stream
+// and the embedded file streams are not known yet.
+func streamCryptFilterPending(ctx *model.Context, sd *types.StreamDict) bool {
+	if !ownEmbeddedFileCryptFilter(ctx) || ctx.EmbeddedFileStreams != nil {
+		return false
+	}
+
+	if sd.Type() != nil {
+		// Only embedded file streams may go without a type.
+		return false
+	}
+
+	return len(sd.FilterPipeline) == 0 || sd.FilterPipeline[0].Name != filter.Crypt
+}
+
+// streamCryptFilter returns true if sd is subject to encryption and whether AES applies.
+func streamCryptFilter(ctx *model.Context, sd *types.StreamDict, objNr int) (bool, bool, error) {
+	if ctx.EncKey == nil {
+		return false, false, nil
+	}
+
+	if sd.Type() != nil && *sd.Type() == "XRef" {
+		// XRefStreams are not encrypted.
+		return false, false, nil
+	}
+
+	if len(sd.FilterPipeline) > 0 && sd.FilterPipeline[0].Name == filter.Crypt {
+		// A Crypt filter takes precedence over StmF and EFF.
+		name := identityCryptFilter
+		if d := sd.FilterPipeline[0].DecodeParms; d != nil && d.NameEntry("Name") != nil {
+			name = *d.NameEntry("Name")
+		}
+		if name == identityCryptFilter {
+			return false, false, nil
+		}
+		aes, ok := ctx.CryptFilters[name]
+		if !ok {
+			return false, false, errors.Errorf("pdfcpu: unknown crypt filter: %s", name)
+		}
+		return true, aes, nil
+	}
+
+	if sd.Type() != nil && *sd.Type() == "Metadata" && !ctx.E.Emd {
+		// EncryptMetadata false
+		return false, false, nil
+	}
+
+	if isEmbeddedFileStream(ctx, sd, objNr) && ctx.EFF != "" {
+		return ctx.EFF != identityCryptFilter, ctx.AES4EmbeddedStreams, nil
+	}
+
+	return ctx.StmF != identityCryptFilter, ctx.AES4Streams, nil
 }
 
 func length(d types.Dict) (int, error) {
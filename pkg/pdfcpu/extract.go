@@ -240,6 +240,14 @@ func imageHeight(ctx *model.Context, sd *types.StreamDict, objNr int) (int, erro
 	return i.Value(), nil
 }
 
+// jpxImageConfig returns the dimensions, color space and components of the JPEG 2000 data of sd.
+func jpxImageConfig(sd *types.StreamDict) (*filter.JPXImage, error) {
+	if err := sd.Decode(); err != nil {
+		return nil, err
+	}
+	return filter.DecodeJPXConfig(bytes.NewReader(sd.Content))
+}
+
 func imageStub(
 	ctx *model.Context,
 	sd *types.StreamDict,
@@ -267,7 +275,7 @@ func imageStub(
 	if err != nil {
 		return nil, err
 	}
-	if lastFilter == filter.CCITTFax {
+	if lastFilter == filter.CCITTFax || lastFilter == filter.JBIG2 {
 		comp = 1
 	}
 
@@ -275,7 +283,6 @@ func imageStub(
 	if i := sd.IntEntry("BitsPerComponent"); i != nil {
 		bpc = *i
 	}
-	// if jpx, bpc is undefined
 	if imgMask {
 		bpc = 1
 	}
@@ -285,6 +292,24 @@ func imageStub(
 		sMask = true
 	}
 
+	if lastFilter == filter.JPX {
+		// The JPX data defines the image properties, bpc is undefined in the image dict.
+		jpx, err := jpxImageConfig(sd)
+		if err != nil {
+			if log.InfoEnabled() {
+				log.Info.Printf("imageStub: obj#%d - ignoring corrupt JPX data: %v\n", objNr, err)
+			}
+		} else {
+			w, h, bpc = jpx.Width, jpx.Height, jpx.BPC
+			if cs == "" {
+				cs, comp = jpx.ColorSpace, jpx.Comps
+			}
+			if i := sd.IntEntry("SMaskInData"); i != nil && *i > 0 {
+				sMask = true
+			}
+		}
+	}
+
 	var mask bool
 	if sm, _ := sd.Find("Mask"); sm != nil {
 		mask = true
@@ -354,8 +379,8 @@ func prepareExtractImage(sd *types.StreamDict) (string, string, types.Dict, bool
 	return filters, lastFilter, d, imgMask
 }
 func decodeImage(ctx *model.Context, sd *types.StreamDict, filters, lastFilter string, objNr int) error {
-	// CCITTDecoded images / (bit) masks don't have a ColorSpace attribute, but we render image files.
-	if lastFilter == filter.CCITTFax {
+	// CCITT/JBIG2 decoded images / (bit) masks don't have a ColorSpace attribute, but we render image files.
+	if lastFilter == filter.CCITTFax || lastFilter == filter.JBIG2 {
 		if _, err := ctx.DereferenceDictEntry(sd.Dict, "ColorSpace"); err != nil {
 			sd.InsertName("ColorSpace", model.DeviceGrayCS)
 		}
@@ -371,7 +396,7 @@ func decodeImage(ctx *model.Context, sd *types.StreamDict, filters, lastFilter s
 
 	switch lastFilter {
 
-	case filter.DCT, filter.JPX, filter.Flate, filter.CCITTFax, filter.RunLength:
+	case filter.DCT, filter.JPX, filter.Flate, filter.CCITTFax, filter.JBIG2, filter.RunLength:
 		if err := sd.Decode(); err != nil {
 			return err
 		}
@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/log"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+func updateInfoDictForIncrement(ctx *model.Context) error {
+	if ctx.Info == nil {
+		return nil
+	}
+
+	d, err := ctx.DereferenceDict(*ctx.Info)
+	if err != nil || d == nil {
+		return err
+	}
+
+	d.Update("ModDate", types.StringLiteral(types.DateString(time.Now())))
+
+	if ctx.ID == nil {
+		return nil
+	}
+
+	return ensureFileID(ctx)
+}
+
+// PrepareIncrement marks all objects of ctx that got added, modified or freed
+// since reading for writing as PDF increment.
+// It returns the number of objects to be written.
+func PrepareIncrement(ctx *model.Context) (int, error) {
+	if !ctx.TracksChanges() {
+		return 0, errors.New("pdfcpu: PrepareIncrement: changes have not been tracked (Hint: set conf.Incremental before reading)")
+	}
+
+	if err := updateInfoDictForIncrement(ctx); err != nil {
+		return 0, err
+	}
+
+	ctx.Write.Increment = true
+	ctx.Write.ObjNrs = []int{}
+
+	// Keep the cross reference format of the original file.
+	ctx.WriteObjectStream = false
+	ctx.WriteXRefStream = ctx.Read.UsingXRefStreams
+
+	freed := false
+
+	objNrs := make([]int, 0, len(ctx.Table))
+	for objNr := range ctx.Table {
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	for _, objNr := range objNrs {
+		if objNr == 0 {
+			continue
+		}
+
+		e := ctx.Table[objNr]
+		if !e.Dirty() {
+			continue
+		}
+
+		ctx.Write.ObjNrs = append(ctx.Write.ObjNrs, objNr)
+		if e.Free {
+			freed = true
+		}
+	}
+
+	if freed {
+		if err := ctx.EnsureValidFreeList(); err != nil {
+			return 0, err
+		}
+		// Update the head of the free list.
+		ctx.Write.ObjNrs = append(ctx.Write.ObjNrs, 0)
+	}
+
+	if log.WriteEnabled() {
+		log.Write.Printf("PrepareIncrement: objects: %v\n", ctx.Write.ObjNrs)
+	}
+
+	return len(ctx.Write.ObjNrs), nil
+}
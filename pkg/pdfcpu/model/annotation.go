@@ -927,3 +927,65 @@ func NewStrikeOutAnnotation(
 		NewTextMarkupAnnotation(AnnStrikeOut, rect, contents, id, modDate, f, col, title, popupIndRef, ca, rc, subject, quad),
 	}
 }
+
+// RedactAnnotation marks content to be removed from the document (PDF 1.7).
+type RedactAnnotation struct {
+	MarkupAnnotation
+	Quad        types.QuadPoints   // areas to be removed, defaults to Rect.
+	FillCol     *color.SimpleColor // overlay color of the redacted areas.
+	OverlayText string             // text to be shown within the overlay areas.
+}
+
+// NewRedactAnnotation returns a new redact annotation.
+func NewRedactAnnotation(
+	rect types.Rectangle,
+	contents, id string,
+	modDate string,
+	f AnnotationFlags,
+	col *color.SimpleColor,
+	title string,
+	popupIndRef *types.IndirectRef,
+	ca *float64,
+	rc, subject string,
+
+	quad types.QuadPoints,
+	fillCol *color.SimpleColor,
+	overlayText string) RedactAnnotation {
+
+	ma := NewMarkupAnnotation(AnnRedact, rect, contents, id, modDate, f, col, title, popupIndRef, ca, rc, subject)
+
+	return RedactAnnotation{
+		MarkupAnnotation: ma,
+		Quad:             quad,
+		FillCol:          fillCol,
+		OverlayText:      overlayText,
+	}
+}
+
+// RenderDict renders ann into a page annotation dict.
+func (ann RedactAnnotation) RenderDict(xRefTable *XRefTable, pageIndRef *types.IndirectRef) (types.Dict, error) {
+	d, err := ann.MarkupAnnotation.RenderDict(xRefTable, pageIndRef)
+	if err != nil {
+		return nil, err
+	}
+
+	if ann.Quad != nil {
+		d.Insert("QuadPoints", ann.Quad.Array())
+	}
+
+	if ann.FillCol != nil {
+		d["IC"] = ann.FillCol.Array()
+	}
+
+	if ann.OverlayText != "" {
+		s, err := types.EscapeUTF16String(ann.OverlayText)
+		if err != nil {
+			return nil, err
+		}
+		d.InsertString("OverlayText", *s)
+	}
+
+	d.InsertString("DA", "/Helv 0 Tf 0 g")
+
+	return d, nil
+}
@@ -346,6 +346,9 @@ func (ctx *Context) RemoveAttachment(a Attachment) (bool, error) {
 // ExtractAttachments extracts attachments with id.
 func (ctx *Context) ExtractAttachments(ids []string) ([]Attachment, error) {
 	xRefTable := ctx.XRefTable
+	if xRefTable.EmbeddedFilesLocked {
+		return nil, errors.New("pdfcpu: please provide the password protecting the attachments")
+	}
 	if !xRefTable.Valid {
 		if err := xRefTable.LocateNameTree("EmbeddedFiles", false); err != nil {
 			return nil, err
@@ -295,6 +295,17 @@ func parseBoxByRectangle(s string, u types.DisplayUnit) (*Box, error) {
 	return &Box{Rect: types.NewRectangle(xmin, ymin, xmax, ymax)}, nil
 }
 
+// ParseRect parses a rectangle given by "llx lly urx ury" in display unit u.
+// Enclosing brackets are optional.
+func ParseRect(s string, u types.DisplayUnit) (*types.Rectangle, error) {
+	s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "["), "]")
+	b, err := parseBoxByRectangle(s, u)
+	if err != nil {
+		return nil, err
+	}
+	return b.Rect, nil
+}
+
 func parseBoxPercentage(s string) (float64, error) {
 	pct, err := strconv.ParseFloat(s, 64)
 	if err != nil {
@@ -17,6 +17,8 @@ limitations under the License.
 package model
 
 import (
+	"crypto"
+	"crypto/x509"
 	_ "embed"
 	"fmt"
 	"os"
@@ -152,8 +154,33 @@ const (
 	SETVIEWERPREFERENCES
 	RESETVIEWERPREFERENCES
 	ZOOM
+	REPAIR
+	SIGN
+	LISTSIGNATURES
+	VERIFYSIGNATURES
+	ADDDSS
+	TIMESTAMP
+	EXTRACTTEXT
+	SEARCHTEXT
+	HIGHLIGHTTEXT
+	REDACT
+	SANITIZE
+	RENDER
+	EXPORT
 )
 
+// ImageOptimization controls downsampling and recompression of images during optimization.
+type ImageOptimization struct {
+	// Target resolution in dots per inch of downsampled images.
+	DPI int
+
+	// Images exceeding this effective resolution get downsampled to DPI.
+	Threshold int
+
+	// Quality (1-100) of recompressed photographic images.
+	JPEGQuality int
+}
+
 // Configuration of a Context.
 type Configuration struct {
 	// Location of corresponding config.yml
@@ -168,6 +195,13 @@ type Configuration struct {
 	// Enables decoding of all streams (fontfiles, images..) for logging purposes.
 	DecodeAllStreams bool
 
+	// Read objects on demand instead of loading the whole file into memory.
+	// Skips upfront validation and optimization, recommended for read only or single page processing of large files.
+	LazyRead bool
+
+	// Max. number of lazily read objects held in memory (0 = unlimited).
+	ObjectCacheSize int
+
 	// Validate against ISO-32000: strict or relaxed.
 	ValidationMode int
 
@@ -189,6 +223,10 @@ type Configuration struct {
 	// Switches between xRefSection (<=V1.4) and objectStream/xRefStream (>=V1.5) writing.
 	WriteXRefStream bool
 
+	// Append all changes as incremental update to the unmodified input file.
+	// Leaves the byte ranges of existing digital signatures intact and skips optimization.
+	Incremental bool
+
 	// Turns on stats collection.
 	// TODO Decision - unused.
 	CollectStats bool
@@ -212,6 +250,20 @@ type Configuration struct {
 	// AES:40,128,256 RC4:40,128
 	EncryptKeyLength int
 
+	// EncryptAttachmentsOnly restricts encryption to embedded files (AES:128,256).
+	// The document itself remains accessible without a password.
+	EncryptAttachmentsOnly bool
+
+	// EncryptMetadata toggles the encryption of metadata streams (AES/RC4:128,256).
+	// false: Metadata streams remain readable for search indexers.
+	EncryptMetadata bool
+
+	// Recipients for public-key encryption (Adobe.PubSec) replacing the passwords.
+	EncryptRecipients []*x509.Certificate
+
+	// Private key of a recipient for opening files using public-key encryption.
+	DecryptKey crypto.Decrypter
+
 	// Supplied user access permissions, see Table 22.
 	Permissions PermissionFlags // int16
 
@@ -236,6 +288,14 @@ type Configuration struct {
 	// Optimize duplicate content streams across pages.
 	OptimizeDuplicateContentStreams bool
 
+	// Apply PNG prediction (Predictor 15) when Flate encoding created images and xref streams
+	// unless this results in larger streams.
+	FlatePredictor bool
+
+	// Downsample and recompress images when optimizing.
+	// nil leaves image data untouched.
+	ImageOptimization *ImageOptimization
+
 	// Merge creates bookmarks
 	CreateBookmarks bool
 
@@ -325,6 +385,8 @@ func newDefaultConfiguration() *Configuration {
 		CheckFileNameExt:                true,
 		Reader15:                        true,
 		DecodeAllStreams:                false,
+		LazyRead:                        false,
+		ObjectCacheSize:                 0,
 		ValidationMode:                  ValidationRelaxed,
 		ValidateLinks:                   false,
 		Eol:                             types.EolLF,
@@ -332,12 +394,14 @@ func newDefaultConfiguration() *Configuration {
 		WriteXRefStream:                 true,
 		EncryptUsingAES:                 true,
 		EncryptKeyLength:                256,
+		EncryptMetadata:                 true,
 		Permissions:                     PermissionsPrint,
 		TimestampFormat:                 "2006-01-02 15:04",
 		DateFormat:                      "2006-01-02",
 		Optimize:                        true,
 		OptimizeResourceDicts:           true,
 		OptimizeDuplicateContentStreams: false,
+		FlatePredictor:                  true,
 		CreateBookmarks:                 true,
 		NeedAppearances:                 false,
 	}
@@ -395,6 +459,8 @@ func (c Configuration) String() string {
 		"CheckFileNameExt:    %t\n"+
 		"Reader15:            %t\n"+
 		"DecodeAllStreams:    %t\n"+
+		"LazyRead:            %t\n"+
+		"ObjectCacheSize:     %d\n"+
 		"ValidationMode:      %s\n"+
 		"PostProcessValidate: %t\n"+
 		"ValidateLinks:       %t\n"+
@@ -403,6 +469,7 @@ func (c Configuration) String() string {
 		"WriteXrefStream:     %t\n"+
 		"EncryptUsingAES:     %t\n"+
 		"EncryptKeyLength:    %d\n"+
+		"EncryptMetadata:     %t\n"+
 		"Permissions:         %d\n"+
 		"Unit :               %s\n"+
 		"TimestampFormat:	  %s\n"+
@@ -410,12 +477,15 @@ func (c Configuration) String() string {
 		"Optimize %t\n"+
 		"OptimizeResourceDicts %t\n"+
 		"OptimizeDuplicateContentStreams %t\n"+
+		"FlatePredictor %t\n"+
 		"CreateBookmarks %t\n"+
 		"NeedAppearances %t\n",
 		path,
 		c.CheckFileNameExt,
 		c.Reader15,
 		c.DecodeAllStreams,
+		c.LazyRead,
+		c.ObjectCacheSize,
 		c.ValidationModeString(),
 		c.PostProcessValidate,
 		c.ValidateLinks,
@@ -424,6 +494,7 @@ func (c Configuration) String() string {
 		c.WriteXRefStream,
 		c.EncryptUsingAES,
 		c.EncryptKeyLength,
+		c.EncryptMetadata,
 		c.Permissions,
 		c.UnitString(),
 		c.TimestampFormat,
@@ -431,6 +502,7 @@ func (c Configuration) String() string {
 		c.Optimize,
 		c.OptimizeResourceDicts,
 		c.OptimizeDuplicateContentStreams,
+		c.FlatePredictor,
 		c.CreateBookmarks,
 		c.NeedAppearances,
 	)
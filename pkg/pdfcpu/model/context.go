@@ -69,6 +69,17 @@ func (ctx *Context) ResetWriteContext() {
 	ctx.Write = NewWriteContext(ctx.Write.Eol)
 }
 
+// Close releases the input file kept open for loading objects on demand.
+// Objects not loaded by then can't be accessed anymore.
+func (ctx *Context) Close() error {
+	if ctx.Read == nil || ctx.Read.Closer == nil {
+		return nil
+	}
+	err := ctx.Read.Closer.Close()
+	ctx.Read.Closer = nil
+	return err
+}
+
 func (rc *ReadContext) logReadContext(logStr *[]string) {
 	if rc.UsingObjectStreams {
 		*logStr = append(*logStr, "using object streams\n")
@@ -182,6 +193,7 @@ type ReadContext struct {
 	FileName            string        // Input PDF-File.
 	FileSize            int64         // Input file size.
 	RS                  io.ReadSeeker // Input read seeker.
+	Closer              io.Closer     // Input file owned by a lazily read context, see Context.Close.
 	EolCount            int           // 1 or 2 characters used for eol.
 	BinaryTotalSize     int64         // total stream data
 	BinaryImageSize     int64         // total image stream data
@@ -194,14 +206,17 @@ type ReadContext struct {
 	ObjectStreams       types.IntSet  // All object numbers of any object streams found which need to be decoded.
 	UsingXRefStreams    bool          // File is using xref streams.
 	XRefStreams         types.IntSet  // All object numbers of any xref streams found.
+	XRefReconstructed   bool          // The xref table has been reconstructed by scanning the file body.
+	PendingStreams      types.IntSet  // Streams to be decrypted once all embedded file streams are known.
 }
 
 func newReadContext(rs io.ReadSeeker) (*ReadContext, error) {
 
 	rdCtx := &ReadContext{
-		RS:            rs,
-		ObjectStreams: types.IntSet{},
-		XRefStreams:   types.IntSet{},
+		RS:             rs,
+		ObjectStreams:  types.IntSet{},
+		XRefStreams:    types.IntSet{},
+		PendingStreams: types.IntSet{},
 	}
 
 	fileSize, err := rs.Seek(0, io.SeekEnd)
@@ -76,6 +76,10 @@ func (xRefTable *XRefTable) indRefToObject(ir *types.IndirectRef, decodeLazy boo
 
 	xRefTable.CurObj = int(ir.ObjectNumber)
 
+	if err := xRefTable.ensureLoaded(int(ir.ObjectNumber), entry); err != nil {
+		return nil, err
+	}
+
 	if l, ok := entry.Object.(types.LazyObjectStreamObject); ok && decodeLazy {
 		ob, err := l.DecodedObject(context.TODO())
 		if err != nil {
@@ -84,6 +88,7 @@ func (xRefTable *XRefTable) indRefToObject(ir *types.IndirectRef, decodeLazy boo
 
 		ProcessRefCounts(xRefTable, ob)
 		entry.Object = ob
+		xRefTable.loaded(entry)
 	}
 
 	// return dereferenced object
@@ -0,0 +1,107 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Severity classifies a spec violation found during validation.
+type Severity string
+
+// The available severities.
+const (
+	SeverityStrict  Severity = "strict"  // violation in strict validation mode only
+	SeverityRelaxed Severity = "relaxed" // violation in both strict and relaxed validation mode
+)
+
+// Diagnostic represents a spec violation found during validation.
+type Diagnostic struct {
+	ObjNr    int      `json:"objNr,omitempty"`   // the offending object, 0 if unknown
+	Path     string   `json:"path"`              // the dictionary path eg. Root/Pages/Kids[3]/Annots[0]
+	Version  string   `json:"version,omitempty"` // the required PDF version for version violations
+	Severity Severity `json:"severity"`
+	Msg      string   `json:"msg"`
+}
+
+// PushPath descends into the dictionary path element elem.
+func (xRefTable *XRefTable) PushPath(elem string) {
+	xRefTable.validationPath = append(xRefTable.validationPath, elem)
+}
+
+// PushPathf descends into the formatted dictionary path element.
+func (xRefTable *XRefTable) PushPathf(format string, a ...interface{}) {
+	xRefTable.PushPath(fmt.Sprintf(format, a...))
+}
+
+// PopPath ascends from the current dictionary path element.
+func (xRefTable *XRefTable) PopPath() {
+	if n := len(xRefTable.validationPath); n > 0 {
+		xRefTable.validationPath = xRefTable.validationPath[:n-1]
+	}
+}
+
+// Path returns the current dictionary path.
+func (xRefTable *XRefTable) Path() string {
+	return strings.Join(xRefTable.validationPath, "/")
+}
+
+func (xRefTable *XRefTable) severity() Severity {
+	if xRefTable.ValidationMode == ValidationStrict {
+		return SeverityStrict
+	}
+	return SeverityRelaxed
+}
+
+func (xRefTable *XRefTable) recordDiagnostic(objNr int, version, msg string) {
+	if objNr == 0 {
+		objNr = xRefTable.CurObj
+	}
+	msg = strings.TrimSpace(msg)
+
+	// Shared objects like fonts get reported for their first path only.
+	k := fmt.Sprintf("%d %s", objNr, msg)
+	if xRefTable.diagnosed == nil {
+		xRefTable.diagnosed = types.StringSet{}
+	}
+	if xRefTable.diagnosed[k] {
+		return
+	}
+	xRefTable.diagnosed[k] = true
+
+	xRefTable.Diagnostics = append(xRefTable.Diagnostics, Diagnostic{
+		ObjNr:    objNr,
+		Path:     xRefTable.Path(),
+		Version:  version,
+		Severity: xRefTable.severity(),
+		Msg:      msg,
+	})
+}
+
+// RecordViolation records err as a diagnostic for obj#objNr at the current dictionary path.
+// Use objNr 0 for the last dereferenced object.
+// RecordViolation returns err unless collecting diagnostics, in which case validation may go on.
+func (xRefTable *XRefTable) RecordViolation(objNr int, err error) error {
+	if err == nil || !xRefTable.CollectDiagnostics {
+		return err
+	}
+	xRefTable.recordDiagnostic(objNr, "", err.Error())
+	return nil
+}
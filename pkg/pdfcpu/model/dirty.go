@@ -0,0 +1,137 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package model
+
+import (
+	"bytes"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// objectState records an object in use at the time its xref table entry started being tracked.
+type objectState struct {
+	object     types.Object // A snapshot of the object, nil unless loaded.
+	generation int
+}
+
+// TrackChanges records the state of all objects of xRefTable in use
+// so Dirty is able to tell which objects got added, modified or freed later on.
+func (xRefTable *XRefTable) TrackChanges() {
+	xRefTable.trackChanges = true
+	for _, entry := range xRefTable.Table {
+		if entry.state == nil && !entry.Free {
+			entry.track()
+		}
+	}
+}
+
+// TracksChanges returns true if changes of objects are being tracked.
+func (xRefTable *XRefTable) TracksChanges() bool {
+	return xRefTable.trackChanges
+}
+
+// loaded takes a snapshot of the object of entry which just got loaded or decoded.
+func (xRefTable *XRefTable) loaded(entry *XRefTableEntry) {
+	if xRefTable.trackChanges {
+		entry.track()
+	}
+}
+
+func (entry *XRefTableEntry) track() {
+	s := &objectState{}
+	if entry.Generation != nil {
+		s.generation = *entry.Generation
+	}
+	if !unloaded(entry.Object) {
+		s.object = entry.Object.Clone()
+	}
+	entry.state = s
+}
+
+// unloaded returns true for objects not yet loaded and for structural objects,
+// none of which are subject to change.
+func unloaded(o types.Object) bool {
+	switch o.(type) {
+	case nil, types.LazyObjectStreamObject, types.ObjectStreamDict, types.XRefStreamDict:
+		return true
+	}
+	return false
+}
+
+// Dirty returns true if the object of entry got added, replaced, modified or freed since changes are being tracked.
+func (entry *XRefTableEntry) Dirty() bool {
+	if entry.state == nil {
+		return !entry.Free && entry.Object != nil
+	}
+
+	if entry.Free || entry.Generation != nil && *entry.Generation != entry.state.generation {
+		return true
+	}
+
+	if unloaded(entry.Object) {
+		return false
+	}
+
+	return entry.state.object == nil || !sameObject(entry.Object, entry.state.object)
+}
+
+func sameDict(d1, d2 types.Dict) bool {
+	if len(d1) != len(d2) {
+		return false
+	}
+	for k, v1 := range d1 {
+		v2, found := d2[k]
+		if !found || !sameObject(v1, v2) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameObject returns true if o1 and o2 are identical without resolving any indirect references.
+func sameObject(o1, o2 types.Object) bool {
+	switch o1 := o1.(type) {
+
+	case nil:
+		return o2 == nil
+
+	case types.Dict:
+		d2, ok := o2.(types.Dict)
+		return ok && sameDict(o1, d2)
+
+	case types.StreamDict:
+		sd2, ok := o2.(types.StreamDict)
+		return ok && sameDict(o1.Dict, sd2.Dict) && bytes.Equal(o1.Raw, sd2.Raw) && bytes.Equal(o1.Content, sd2.Content)
+
+	case types.Array:
+		a2, ok := o2.(types.Array)
+		if !ok || len(o1) != len(a2) {
+			return false
+		}
+		for i := range o1 {
+			if !sameObject(o1[i], a2[i]) {
+				return false
+			}
+		}
+		return true
+
+	case types.Boolean, types.Integer, types.Float, types.Name, types.StringLiteral, types.HexLiteral, types.IndirectRef:
+		return o1 == o2
+	}
+
+	return o2 != nil && o1.PDFString() == o2.PDFString()
+}
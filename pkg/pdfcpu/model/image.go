@@ -104,7 +104,7 @@ func createSMaskObject(xRefTable *XRefTable, buf []byte, w, h, bpc int) (*types.
 
 	sd.InsertName("Filter", filter.Flate)
 
-	if err := sd.Encode(); err != nil {
+	if err := encodeFlateImage(xRefTable, sd, 1, bpc, w); err != nil {
 		return nil, err
 	}
 
@@ -138,13 +138,31 @@ func createFlateImageObject(xRefTable *XRefTable, buf, sm []byte, w, h, bpc int,
 		sd.Insert("Interpolate", types.Boolean(true))
 	}
 
-	if err := sd.Encode(); err != nil {
+	if err := encodeFlateImage(xRefTable, sd, colorSpaceComponents(cs), bpc, w); err != nil {
 		return nil, err
 	}
 
 	return sd, nil
 }
 
+// encodeFlateImage encodes the Flate image stream sd applying PNG prediction if configured.
+func encodeFlateImage(xRefTable *XRefTable, sd *types.StreamDict, colors, bpc, w int) error {
+	if xRefTable.Conf != nil && xRefTable.Conf.FlatePredictor {
+		return sd.EncodeFlatePredicted(colors, bpc, w)
+	}
+	return sd.Encode()
+}
+
+func colorSpaceComponents(cs string) int {
+	switch cs {
+	case DeviceRGBCS:
+		return 3
+	case DeviceCMYKCS:
+		return 4
+	}
+	return 1
+}
+
 // CreateDCTImageObject returns a DCT encoded stream dict.
 func CreateDCTImageObject(xRefTable *XRefTable, buf []byte, w, h, bpc int, cs string) (*types.StreamDict, error) {
 	sd := &types.StreamDict{
@@ -184,6 +202,42 @@ func CreateDCTImageObject(xRefTable *XRefTable, buf []byte, w, h, bpc int, cs st
 	return sd, nil
 }
 
+// CreateCCITTImageObject returns a CCITT Group 4 encoded stream dict for a black and white image.
+// buf holds the pixels packed 1 bit per pixel with 0 representing black.
+func CreateCCITTImageObject(buf []byte, w, h int) (*types.StreamDict, error) {
+	parms := types.Dict(
+		map[string]types.Object{
+			"K":       types.Integer(-1),
+			"Columns": types.Integer(w),
+			"Rows":    types.Integer(h),
+		},
+	)
+
+	sd := &types.StreamDict{
+		Dict: types.Dict(
+			map[string]types.Object{
+				"Type":             types.Name("XObject"),
+				"Subtype":          types.Name("Image"),
+				"Width":            types.Integer(w),
+				"Height":           types.Integer(h),
+				"BitsPerComponent": types.Integer(1),
+				"ColorSpace":       types.Name(DeviceGrayCS),
+				"DecodeParms":      parms,
+			},
+		),
+		Content:        buf,
+		FilterPipeline: []types.PDFFilter{{Name: filter.CCITTFax, DecodeParms: parms}},
+	}
+
+	sd.InsertName("Filter", filter.CCITTFax)
+
+	if err := sd.Encode(); err != nil {
+		return nil, err
+	}
+
+	return sd, nil
+}
+
 func writeRGBAImageBuf(img image.Image) ([]byte, []byte) {
 	w := img.Bounds().Dx()
 	h := img.Bounds().Dy()
@@ -388,6 +442,64 @@ func writeCMYKImageBuf(img image.Image) []byte {
 	return buf
 }
 
+// writeBilevelImageBuf returns the pixels of a black and white image packed 1 bit per pixel with 0 representing black.
+func writeBilevelImageBuf(img image.Image) ([]byte, bool) {
+	var white func(x, y int) (bool, bool)
+
+	switch img := img.(type) {
+
+	case *image.Gray:
+		white = func(x, y int) (bool, bool) {
+			v := img.GrayAt(x, y).Y
+			return v == 0xFF, v == 0 || v == 0xFF
+		}
+
+	case *image.Paletted:
+		// 0: black, 1: white, 2: any other color
+		lut := make([]uint8, len(img.Palette))
+		for i, c := range img.Palette {
+			r, g, b, a := c.RGBA()
+			switch {
+			case a == 0xFFFF && r == 0 && g == 0 && b == 0:
+				lut[i] = 0
+			case a == 0xFFFF && r == 0xFFFF && g == 0xFFFF && b == 0xFFFF:
+				lut[i] = 1
+			default:
+				lut[i] = 2
+			}
+		}
+		white = func(x, y int) (bool, bool) {
+			i := int(img.ColorIndexAt(x, y))
+			if i >= len(lut) {
+				return false, false
+			}
+			return lut[i] == 1, lut[i] < 2
+		}
+
+	default:
+		return nil, false
+	}
+
+	b := img.Bounds()
+	rowBytes := (b.Dx() + 7) / 8
+	buf := make([]byte, rowBytes*b.Dy())
+
+	for y := 0; y < b.Dy(); y++ {
+		row := buf[y*rowBytes:]
+		for x := 0; x < b.Dx(); x++ {
+			w, ok := white(b.Min.X+x, b.Min.Y+y)
+			if !ok {
+				return nil, false
+			}
+			if w {
+				row[x>>3] |= 0x80 >> (x & 7)
+			}
+		}
+	}
+
+	return buf, true
+}
+
 func convertToRGBA(img image.Image) *image.RGBA {
 	b := img.Bounds()
 	m := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
@@ -607,13 +719,21 @@ func CreateImageStreamDict(xRefTable *XRefTable, r io.Reader, gray, sepia bool)
 		}
 	}
 
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+
+	if format == "png" || format == "tiff" {
+		// Black and white scans compress much better using CCITT Group 4.
+		if buf, ok := writeBilevelImageBuf(img); ok {
+			sd, err := CreateCCITTImageObject(buf, w, h)
+			return sd, w, h, err
+		}
+	}
+
 	imgBuf, softMask, bpc, cs, err := createImageBuf(xRefTable, img, format)
 	if err != nil {
 		return nil, 0, 0, err
 	}
 
-	w, h := img.Bounds().Dx(), img.Bounds().Dy()
-
 	return createImageDict(xRefTable, imgBuf, softMask, w, h, bpc, format, cs)
 }
 
@@ -0,0 +1,199 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"container/list"
+
+	"github.com/pdfcpu/pdfcpu/pkg/log"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// LoadObjectFunc loads the object for an xref table entry from the underlying file.
+type LoadObjectFunc func(objNr int, entry *XRefTableEntry) (types.Object, error)
+
+// ObjectCache keeps track of lazily loaded objects.
+// Once more than Size objects are in memory the least recently used object gets evicted
+// and will be reloaded from file on its next access.
+// Objects modified since loading are never evicted.
+// A Size of 0 disables eviction.
+type ObjectCache struct {
+	Size  int
+	lru   *list.List
+	elems map[int]*list.Element
+}
+
+// NewObjectCache returns a cache for lazily loaded objects holding at most size objects.
+func NewObjectCache(size int) *ObjectCache {
+	return &ObjectCache{
+		Size:  size,
+		lru:   list.New(),
+		elems: map[int]*list.Element{},
+	}
+}
+
+// Len returns the number of lazily loaded objects currently held in memory.
+func (oc *ObjectCache) Len() int {
+	return oc.lru.Len()
+}
+
+func (oc *ObjectCache) touch(objNr int) {
+	if e, ok := oc.elems[objNr]; ok {
+		oc.lru.MoveToFront(e)
+	}
+}
+
+func (oc *ObjectCache) add(objNr int) {
+	if e, ok := oc.elems[objNr]; ok {
+		oc.lru.MoveToFront(e)
+		return
+	}
+	oc.elems[objNr] = oc.lru.PushFront(objNr)
+}
+
+func (oc *ObjectCache) remove(objNr int) {
+	if e, ok := oc.elems[objNr]; ok {
+		oc.lru.Remove(e)
+		delete(oc.elems, objNr)
+	}
+}
+
+func (oc *ObjectCache) evict(xRefTable *XRefTable) {
+	if oc.Size <= 0 {
+		return
+	}
+
+	for oc.lru.Len() > oc.Size {
+		e := oc.lru.Back()
+		objNr := e.Value.(int)
+		oc.remove(objNr)
+
+		entry, found := xRefTable.Find(objNr)
+		if !found || entry.Free {
+			continue
+		}
+
+		if _, ok := entry.Object.(types.ObjectStreamDict); ok {
+			// Object streams are needed for resolving compressed objects.
+			continue
+		}
+
+		if entry.Dirty() {
+			// Rereading would lose the modification, so keep this object in memory for good.
+			if log.ReadEnabled() {
+				log.Read.Printf("ObjectCache: keeping modified obj#%d\n", objNr)
+			}
+			continue
+		}
+
+		if log.ReadEnabled() {
+			log.Read.Printf("ObjectCache: evicting obj#%d\n", objNr)
+		}
+
+		entry.Object = nil
+		if entry.state != nil {
+			// Release the snapshot as well.
+			entry.state.object = nil
+		}
+		if entry.ObjectStream != nil {
+			// Restore the compressed state so the object gets resolved via its object stream again.
+			entry.Compressed = true
+		}
+	}
+}
+
+// IsLazy returns true if objects of xRefTable are being loaded on demand.
+func (xRefTable *XRefTable) IsLazy() bool {
+	return xRefTable.LoadObject != nil
+}
+
+// EnableLazyLoading turns on loading objects on demand using loadObject.
+// cacheSize limits the number of lazily loaded objects held in memory, 0 means unlimited.
+func (xRefTable *XRefTable) EnableLazyLoading(loadObject LoadObjectFunc, cacheSize int) {
+	xRefTable.LoadObject = loadObject
+	xRefTable.ObjCache = NewObjectCache(cacheSize)
+	// Modified objects must not be evicted.
+	xRefTable.trackChanges = true
+}
+
+// KeepLoadedObjects stops evicting lazily loaded objects while still loading objects on demand.
+// Writing relies on this since it rewrites the xref table entries of the objects written.
+func (xRefTable *XRefTable) KeepLoadedObjects() {
+	if xRefTable.IsLazy() {
+		xRefTable.ObjCache.Size = 0
+	}
+}
+
+// DisableLazyLoading loads all remaining objects into memory and turns off loading objects on demand.
+func (xRefTable *XRefTable) DisableLazyLoading() error {
+	if !xRefTable.IsLazy() {
+		return nil
+	}
+
+	xRefTable.KeepLoadedObjects()
+
+	for _, objNr := range xRefTable.sortedKeys() {
+		entry := xRefTable.Table[objNr]
+		if err := xRefTable.ensureLoaded(objNr, entry); err != nil {
+			return err
+		}
+	}
+
+	xRefTable.LoadObject = nil
+	xRefTable.ObjCache = nil
+
+	return nil
+}
+
+func (xRefTable *XRefTable) needsLoading(entry *XRefTableEntry) bool {
+	if entry == nil || entry.Free || entry.Object != nil {
+		return false
+	}
+	return entry.Compressed || (entry.Offset != nil && *entry.Offset > 0)
+}
+
+// ensureLoaded loads the object of entry from file unless it is already in memory.
+func (xRefTable *XRefTable) ensureLoaded(objNr int, entry *XRefTableEntry) error {
+	if !xRefTable.IsLazy() {
+		return nil
+	}
+
+	if !xRefTable.needsLoading(entry) {
+		if entry != nil && entry.Object != nil {
+			xRefTable.ObjCache.touch(objNr)
+		}
+		return nil
+	}
+
+	if log.ReadEnabled() {
+		log.Read.Printf("ensureLoaded: loading obj#%d\n", objNr)
+	}
+
+	o, err := xRefTable.LoadObject(objNr, entry)
+	if err != nil {
+		return errors.Wrapf(err, "pdfcpu: ensureLoaded: problem loading obj#%d", objNr)
+	}
+
+	entry.Object = o
+	xRefTable.loaded(entry)
+
+	xRefTable.ObjCache.add(objNr)
+	xRefTable.ObjCache.evict(xRefTable)
+
+	return nil
+}
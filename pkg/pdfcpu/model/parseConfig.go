@@ -32,6 +32,8 @@ type configuration struct {
 	CheckFileNameExt                bool   `yaml:"checkFileNameExt"`
 	Reader15                        bool   `yaml:"reader15"`
 	DecodeAllStreams                bool   `yaml:"decodeAllStreams"`
+	LazyRead                        bool   `yaml:"lazyRead"`
+	ObjectCacheSize                 int    `yaml:"objectCacheSize"`
 	ValidationMode                  string `yaml:"validationMode"`
 	PostProcessValidate             bool   `yaml:"postProcessValidate"`
 	Eol                             string `yaml:"eol"`
@@ -39,6 +41,7 @@ type configuration struct {
 	WriteXRefStream                 bool   `yaml:"writeXRefStream"`
 	EncryptUsingAES                 bool   `yaml:"encryptUsingAES"`
 	EncryptKeyLength                int    `yaml:"encryptKeyLength"`
+	EncryptMetadata                 bool   `yaml:"encryptMetadata"`
 	Permissions                     int    `yaml:"permissions"`
 	Unit                            string `yaml:"unit"`
 	Units                           string `yaml:"units"` // Be flexible if version < v0.3.8
@@ -47,6 +50,7 @@ type configuration struct {
 	Optimize                        bool   `yaml:"optimize"`
 	OptimizeResourceDicts           bool   `yaml:"optimizeResourceDicts"`
 	OptimizeDuplicateContentStreams bool   `yaml:"optimizeDuplicateContentStreams"`
+	FlatePredictor                  bool   `yaml:"flatePredictor"`
 	CreateBookmarks                 bool   `yaml:"createBookmarks"`
 	NeedAppearances                 bool   `yaml:"needAppearances"`
 }
@@ -58,10 +62,13 @@ func loadedConfig(c configuration, configPath string) *Configuration {
 	conf.CheckFileNameExt = c.CheckFileNameExt
 	conf.Reader15 = c.Reader15
 	conf.DecodeAllStreams = c.DecodeAllStreams
+	conf.LazyRead = c.LazyRead
+	conf.ObjectCacheSize = c.ObjectCacheSize
 	conf.WriteObjectStream = c.WriteObjectStream
 	conf.WriteXRefStream = c.WriteXRefStream
 	conf.EncryptUsingAES = c.EncryptUsingAES
 	conf.EncryptKeyLength = c.EncryptKeyLength
+	conf.EncryptMetadata = c.EncryptMetadata
 	conf.Permissions = PermissionFlags(c.Permissions)
 
 	switch c.ValidationMode {
@@ -98,6 +105,7 @@ func loadedConfig(c configuration, configPath string) *Configuration {
 	conf.Optimize = c.Optimize
 	conf.OptimizeResourceDicts = c.OptimizeResourceDicts
 	conf.OptimizeDuplicateContentStreams = c.OptimizeDuplicateContentStreams
+	conf.FlatePredictor = c.FlatePredictor
 	conf.CreateBookmarks = c.CreateBookmarks
 	conf.NeedAppearances = c.NeedAppearances
 
@@ -109,6 +117,8 @@ func parseConfigFile(r io.Reader, configPath string) error {
 
 	// Enforce default for old config files.
 	c.CheckFileNameExt = true
+	c.EncryptMetadata = true
+	c.FlatePredictor = true
 
 	var buf bytes.Buffer
 	if _, err := io.Copy(&buf, r); err != nil {
@@ -55,6 +55,24 @@ func handleConfDecodeAllStreams(k, v string, c *Configuration) error {
 	return nil
 }
 
+func handleConfLazyRead(k, v string, c *Configuration) error {
+	v = strings.ToLower(v)
+	if v != "true" && v != "false" {
+		return errors.Errorf("config key %s is boolean", k)
+	}
+	c.LazyRead = v == "true"
+	return nil
+}
+
+func handleConfObjectCacheSize(v string, c *Configuration) error {
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return errors.Errorf("objectCacheSize is a non negative number, got: %s", v)
+	}
+	c.ObjectCacheSize = i
+	return nil
+}
+
 func handleConfPostProcessValidate(k, v string, c *Configuration) error {
 	v = strings.ToLower(v)
 	if v != "true" && v != "false" {
@@ -131,6 +149,15 @@ func handleConfEncryptKeyLength(v string, c *Configuration) error {
 	return nil
 }
 
+func handleConfEncryptMetadata(k, v string, c *Configuration) error {
+	v = strings.ToLower(v)
+	if v != "true" && v != "false" {
+		return errors.Errorf("config key %s is boolean", k)
+	}
+	c.EncryptMetadata = v == "true"
+	return nil
+}
+
 func handleConfPermissions(v string, c *Configuration) error {
 	i, err := strconv.Atoi(v)
 	if err != nil {
@@ -194,6 +221,15 @@ func handleOptimizeDuplicateContentStreams(k, v string, c *Configuration) error
 	return nil
 }
 
+func handleFlatePredictor(k, v string, c *Configuration) error {
+	v = strings.ToLower(v)
+	if v != "true" && v != "false" {
+		return errors.Errorf("config key %s is boolean", k)
+	}
+	c.FlatePredictor = v == "true"
+	return nil
+}
+
 func handleCreateBookmarks(k, v string, c *Configuration) error {
 	v = strings.ToLower(v)
 	if v != "true" && v != "false" {
@@ -224,6 +260,12 @@ func parseKeysPart1(k, v string, c *Configuration) (bool, error) {
 	case "decodeAllStreams":
 		return true, handleConfDecodeAllStreams(k, v, c)
 
+	case "lazyRead":
+		return true, handleConfLazyRead(k, v, c)
+
+	case "objectCacheSize":
+		return true, handleConfObjectCacheSize(v, c)
+
 	case "validationMode":
 		return true, handleConfValidationMode(v, c)
 
@@ -252,6 +294,9 @@ func parseKeysPart2(k, v string, c *Configuration) error {
 	case "encryptKeyLength":
 		return handleConfEncryptKeyLength(v, c)
 
+	case "encryptMetadata":
+		return handleConfEncryptMetadata(k, v, c)
+
 	case "permissions":
 		return handleConfPermissions(v, c)
 
@@ -273,6 +318,9 @@ func parseKeysPart2(k, v string, c *Configuration) error {
 	case "optimizeDuplicateContentStreams":
 		return handleOptimizeDuplicateContentStreams(k, v, c)
 
+	case "flatePredictor":
+		return handleFlatePredictor(k, v, c)
+
 	case "createBookmarks":
 		return handleCreateBookmarks(k, v, c)
 
@@ -299,6 +347,10 @@ func parseConfigFile(r io.Reader, configPath string) error {
 	var conf Configuration
 	conf.Path = configPath
 
+	// Enforce default for old config files.
+	conf.EncryptMetadata = true
+	conf.FlatePredictor = true
+
 	s := bufio.NewScanner(r)
 	for s.Scan() {
 		t := s.Text()
@@ -22,6 +22,33 @@ import (
 	"github.com/pdfcpu/pdfcpu/pkg/log"
 )
 
+// RepairCategory classifies a fix applied to a corrupt PDF file.
+type RepairCategory string
+
+// The available repair categories.
+const (
+	RepairXRef          RepairCategory = "xref"          // missing or wrong xref table entries
+	RepairStreamLength  RepairCategory = "streamLength"  // wrong stream "Length"
+	RepairFreeObjectRef RepairCategory = "freeObjectRef" // references to free objects
+	RepairPageCount     RepairCategory = "pageCount"     // missing or wrong page tree "Count"
+	RepairCatalog       RepairCategory = "catalog"       // missing or wrong catalog reference
+	RepairObject        RepairCategory = "object"        // corrupt objects
+)
+
+// Repair represents a fix applied to a corrupt PDF file.
+type Repair struct {
+	ObjNr    int            `json:"objNr"`
+	Category RepairCategory `json:"category"`
+	Msg      string         `json:"msg"`
+}
+
+// RecordRepair records a fix for the object objNr and logs it.
+// Use objNr 0 for fixes not related to a specific object.
+func (xRefTable *XRefTable) RecordRepair(objNr int, cat RepairCategory, msg string) {
+	xRefTable.Repairs = append(xRefTable.Repairs, Repair{ObjNr: objNr, Category: cat, Msg: msg})
+	ShowRepaired(msg)
+}
+
 func ReportSpecViolation(xRefTable *XRefTable, err error) {
 	// TODO Apply across code base.
 	pre := fmt.Sprintf("digesting spec violation around obj#(%d)", xRefTable.CurObj)
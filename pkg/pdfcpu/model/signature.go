@@ -0,0 +1,193 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"crypto"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// The supported signature encodings.
+const (
+	SubFilterPKCS7Detached = "adbe.pkcs7.detached"
+	SubFilterCAdESDetached = "ETSI.CAdES.detached" // PAdES
+	SubFilterRFC3161       = "ETSI.RFC3161"        // PAdES document timestamp
+)
+
+// TimestampAuthority represents a RFC 3161 time stamping authority (TSA).
+type TimestampAuthority interface {
+	// Timestamp returns a DER encoded RFC 3161 timestamp token for digest created using h.
+	Timestamp(digest []byte, h crypto.Hash) ([]byte, error)
+}
+
+// Signature represents the configuration of a digital signature.
+type Signature struct {
+	Field       string             // name of the signature field, created unless present
+	SubFilter   string             // SubFilterPKCS7Detached, SubFilterCAdESDetached (default) or SubFilterRFC3161
+	Name        string             // name of the signer, defaults to the common name of the signing certificate
+	Reason      string             // reason for signing
+	Location    string             // location of signing
+	ContactInfo string             // contact info of the signer
+	PageNr      int                // page of the signature widget, defaults to 1
+	Rect        *types.Rectangle   // rectangle of a visible signature widget, nil for an invisible signature
+	FontName    string             // font for the visible signature widget
+	FontSize    int                // font size for the visible signature widget
+	Unit        types.DisplayUnit  // display unit
+	TSA         TimestampAuthority // optional time stamping authority for signature timestamps (PAdES B-T) and document timestamps
+}
+
+// DefaultSignature returns the default configuration for an invisible PAdES signature.
+func DefaultSignature() *Signature {
+	return &Signature{
+		SubFilter: SubFilterCAdESDetached,
+		PageNr:    1,
+		FontName:  "Helvetica",
+		FontSize:  8,
+	}
+}
+
+// Visible returns true if sig has a signature widget on some page.
+func (sig Signature) Visible() bool {
+	return sig.Rect != nil && sig.Rect.Width() > 0 && sig.Rect.Height() > 0
+}
+
+// ParseSubFilter parses a signature mode into a signature SubFilter.
+func ParseSubFilter(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "", "pades", "cades":
+		return SubFilterCAdESDetached, nil
+	case "pkcs7":
+		return SubFilterPKCS7Detached, nil
+	}
+	return "", errors.Errorf("pdfcpu: invalid signature mode: %s, please use one of: pades, pkcs7", s)
+}
+
+func parseSignatureReason(s string, sig *Signature) error {
+	sig.Reason = s
+	return nil
+}
+
+func parseSignatureLocation(s string, sig *Signature) error {
+	sig.Location = s
+	return nil
+}
+
+func parseSignatureContactInfo(s string, sig *Signature) error {
+	sig.ContactInfo = s
+	return nil
+}
+
+func parseSignatureName(s string, sig *Signature) error {
+	sig.Name = s
+	return nil
+}
+
+func parseSignaturePage(s string, sig *Signature) error {
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 1 {
+		return errors.Errorf("pdfcpu: signature page must be a numeric value >= 1, got %s\n", s)
+	}
+	sig.PageNr = i
+	return nil
+}
+
+func parseSignatureRect(s string, sig *Signature) error {
+	r, err := ParseRect(s, sig.Unit)
+	if err != nil {
+		return err
+	}
+	sig.Rect = r
+	return nil
+}
+
+func parseSignatureFontName(s string, sig *Signature) error {
+	sig.FontName = s
+	return nil
+}
+
+func parseSignatureFontSize(s string, sig *Signature) error {
+	i, err := strconv.Atoi(s)
+	if err != nil || i <= 0 {
+		return errors.Errorf("pdfcpu: signature font size must be a numeric value > 0, got %s\n", s)
+	}
+	sig.FontSize = i
+	return nil
+}
+
+type signatureParameterMap map[string]func(string, *Signature) error
+
+// SignatureParamMap maps signature parameters to their parse functions.
+var SignatureParamMap = signatureParameterMap{
+	"reason":   parseSignatureReason,
+	"location": parseSignatureLocation,
+	"contact":  parseSignatureContactInfo,
+	"name":     parseSignatureName,
+	"page":     parseSignaturePage,
+	"rect":     parseSignatureRect,
+	"fontname": parseSignatureFontName,
+	"points":   parseSignatureFontSize,
+}
+
+// Handle applies parameter completion and on success parse parameter values into sig.
+func (m signatureParameterMap) Handle(paramPrefix, paramValueStr string, sig *Signature) error {
+	var param string
+
+	// Completion support
+	for k := range m {
+		if !strings.HasPrefix(k, strings.ToLower(paramPrefix)) {
+			continue
+		}
+		if len(param) > 0 {
+			return errors.Errorf("pdfcpu: ambiguous parameter prefix \"%s\"", paramPrefix)
+		}
+		param = k
+	}
+
+	if param == "" {
+		return errors.Errorf("pdfcpu: unknown parameter prefix \"%s\"", paramPrefix)
+	}
+
+	return m[param](paramValueStr, sig)
+}
+
+// ParseSignatureDetails parses a signature configuration string into sig.
+func ParseSignatureDetails(s string, sig *Signature) error {
+	if s == "" {
+		return nil
+	}
+
+	for _, s := range strings.Split(s, ",") {
+
+		ss := strings.SplitN(s, ":", 2)
+		if len(ss) != 2 {
+			return errors.New("pdfcpu: Invalid signature configuration string. Please consult pdfcpu help sign")
+		}
+
+		paramPrefix := strings.TrimSpace(ss[0])
+		paramValueStr := strings.TrimSpace(ss[1])
+
+		if err := SignatureParamMap.Handle(paramPrefix, paramValueStr, sig); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
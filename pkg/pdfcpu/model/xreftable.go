@@ -55,6 +55,7 @@ type XRefTableEntry struct {
 	ObjectStream    *int
 	ObjectStreamInd *int
 	Valid           bool
+	state           *objectState // The state of the object when tracking changes started.
 }
 
 // NewXRefTableEntryGen0 returns a cross reference table entry for an object with generation 0.
@@ -83,6 +84,7 @@ type Enc struct {
 	L, P, R, V int
 	Emd        bool // encrypt meta data
 	ID         []byte
+	PubSec     bool // public-key security handler (Adobe.PubSec)
 }
 
 // AnnotMap represents annotations by object number of the corresponding annotation dict.
@@ -111,6 +113,12 @@ type XRefTable struct {
 	AES4Strings         bool
 	AES4Streams         bool
 	AES4EmbeddedStreams bool
+	StmF                string          // Crypt filter for streams, "Identity" for no encryption.
+	StrF                string          // Crypt filter for strings, "Identity" for no encryption.
+	EFF                 string          // Crypt filter for embedded file streams, defaults to StmF.
+	CryptFilters        map[string]bool // Named crypt filters and whether they use AES.
+	EmbeddedFileStreams types.IntSet    // Streams referenced by file specifications, needed if EFF differs from StmF.
+	EmbeddedFilesLocked bool            // Embedded file streams remain encrypted for lack of a password (AuthEvent EFOpen).
 
 	// PDF Version
 	HeaderVersion *Version // The PDF version the source is claiming to us as per its header.
@@ -162,6 +170,12 @@ type XRefTable struct {
 	ValidateLinks  bool                      // check for broken links in LinkAnnotations/URIDicts.
 	Valid          bool                      // true means successful validated against ISO 32000.
 	URIs           map[int]map[string]string // URIs for link checking
+	Repairs        []Repair                  // Fixes applied while reading, validating and optimizing.
+
+	CollectDiagnostics bool         // Validation accumulates all violations in Diagnostics instead of aborting on the first one.
+	Diagnostics        []Diagnostic // Violations found during validation.
+	validationPath     []string     // Dictionary path of the object being validated.
+	diagnosed          types.StringSet
 
 	Optimized      bool
 	Watermarked    bool
@@ -173,6 +187,12 @@ type XRefTable struct {
 	// Fonts
 	UsedGIDs  map[string]map[uint16]bool
 	FillFonts map[string]types.IndirectRef
+
+	// Lazy loading
+	LoadObject LoadObjectFunc // Loads objects on demand, nil unless reading lazily.
+	ObjCache   *ObjectCache   // Lazily loaded objects currently in memory.
+
+	trackChanges bool // Changes of objects are being tracked, see TrackChanges.
 }
 
 // NewXRefTable creates a new XRefTable.
@@ -228,6 +248,11 @@ func (xRefTable *XRefTable) ParseRootVersion() (v *string, err error) {
 // ValidateVersion validates against the xRefTable's version.
 func (xRefTable *XRefTable) ValidateVersion(element string, sinceVersion Version) error {
 	if xRefTable.Version() < sinceVersion {
+		if xRefTable.CollectDiagnostics {
+			msg := fmt.Sprintf("%s: unsupported in version %s", element, xRefTable.VersionString())
+			xRefTable.recordDiagnostic(0, sinceVersion.String(), msg)
+			return nil
+		}
 		return errors.Errorf("%s: unsupported in version %s\n", element, xRefTable.VersionString())
 	}
 
@@ -295,6 +320,9 @@ func (xRefTable *XRefTable) FindObject(objNr int) (types.Object, error) {
 	if !ok {
 		return nil, errors.Errorf("FindObject: obj#%d not registered in xRefTable", objNr)
 	}
+	if err := xRefTable.ensureLoaded(objNr, entry); err != nil {
+		return nil, err
+	}
 	return entry.Object, nil
 }
 
@@ -526,6 +554,10 @@ func (xRefTable *XRefTable) FreeObject(objNr int) error {
 		return nil
 	}
 
+	if xRefTable.IsLazy() {
+		xRefTable.ObjCache.remove(objNr)
+	}
+
 	*entry.Generation++
 	entry.Free = true
 	entry.Compressed = false
@@ -940,7 +972,13 @@ func (xRefTable *XRefTable) DereferenceStreamDict(o types.Object) (*types.Stream
 	// An indirect reference to an undefined object shall not be considered an error by a conforming reader;
 	// it shall be treated as a reference to the null object.
 	entry, found := xRefTable.FindTableEntry(indRef.ObjectNumber.Value(), indRef.GenerationNumber.Value())
-	if !found || entry.Object == nil || entry.Free {
+	if !found || entry.Free {
+		return nil, false, nil
+	}
+	if err := xRefTable.ensureLoaded(indRef.ObjectNumber.Value(), entry); err != nil {
+		return nil, false, err
+	}
+	if entry.Object == nil {
 		return nil, false, nil
 	}
 	ev := entry.Valid
@@ -2699,8 +2737,11 @@ func (xRefTable *XRefTable) NameRef(nameType string) NameMap {
 }
 
 func (xRefTable *XRefTable) RemoveSignature() {
+	if xRefTable.Conf != nil && xRefTable.Conf.Incremental {
+		// Signatures remain valid for incremental updates.
+		return
+	}
 	if xRefTable.SignatureExist || xRefTable.AppendOnly {
-		// TODO enable incremental writing
 		if log.CLIEnabled() {
 			log.CLI.Println("removing signature...")
 		}
@@ -18,6 +18,7 @@ package pdfcpu
 
 import (
 	"bytes"
+	"fmt"
 	"sort"
 
 	"github.com/pdfcpu/pdfcpu/pkg/log"
@@ -1289,11 +1290,6 @@ func fixDirectObject(ctx *model.Context, o types.Object) error {
 func fixIndirectObject(ctx *model.Context, ir *types.IndirectRef) error {
 	objNr := int(ir.ObjectNumber)
 
-	if ctx.Optimize.Cache[objNr] {
-		return nil
-	}
-	ctx.Optimize.Cache[objNr] = true
-
 	entry, found := ctx.Find(objNr)
 	if !found {
 		return nil
@@ -1314,8 +1310,18 @@ func fixIndirectObject(ctx *model.Context, ir *types.IndirectRef) error {
 
 		ir.ObjectNumber = types.Integer(*ctx.Optimize.NullObjNr)
 
+		if !ctx.Optimize.Cache[objNr] {
+			ctx.Optimize.Cache[objNr] = true
+			ctx.RecordRepair(objNr, model.RepairFreeObjectRef, fmt.Sprintf("references to free obj#%d replaced by null object", objNr))
+		}
+
+		return nil
+	}
+
+	if ctx.Optimize.Cache[objNr] {
 		return nil
 	}
+	ctx.Optimize.Cache[objNr] = true
 
 	var err error
 
@@ -1464,6 +1470,13 @@ func OptimizeXRefTable(ctx *model.Context) error {
 		return err
 	}
 
+	if ctx.Conf.ImageOptimization != nil {
+		// Downsample and recompress images.
+		if err := optimizeImages(ctx); err != nil {
+			return err
+		}
+	}
+
 	ctx.Optimized = true
 
 	if log.OptimizeEnabled() {
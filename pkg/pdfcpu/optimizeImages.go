@@ -0,0 +1,656 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/log"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/content"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+const maxImagePlacementDepth = 16 // nesting of form XObjects
+
+// ParseImageOptimization parses a comma separated list of image optimization settings like "dpi=150,jpeg=75".
+// Supported keys are dpi (default 150), threshold (default 1.5 * dpi) and jpeg (default quality 75).
+func ParseImageOptimization(s string) (*model.ImageOptimization, error) {
+	opt := &model.ImageOptimization{DPI: 150, JPEGQuality: 75}
+
+	for _, s := range strings.Split(s, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(s, "=")
+		if !ok {
+			return nil, errors.Errorf("pdfcpu: invalid image optimization setting: %q", s)
+		}
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || i <= 0 {
+			return nil, errors.Errorf("pdfcpu: invalid image optimization value: %q", s)
+		}
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "dpi":
+			opt.DPI = i
+		case "threshold":
+			opt.Threshold = i
+		case "jpeg":
+			opt.JPEGQuality = i
+		default:
+			return nil, errors.Errorf("pdfcpu: unknown image optimization setting: %q", k)
+		}
+	}
+
+	if opt.JPEGQuality > 100 {
+		return nil, errors.Errorf("pdfcpu: jpeg quality must be between 1 and 100: %d", opt.JPEGQuality)
+	}
+
+	if opt.Threshold == 0 {
+		opt.Threshold = opt.DPI * 3 / 2
+	}
+	if opt.Threshold < opt.DPI {
+		return nil, errors.Errorf("pdfcpu: image optimization threshold %d below dpi %d", opt.Threshold, opt.DPI)
+	}
+
+	return opt, nil
+}
+
+// imagePlacements collects the lowest effective resolution of every image XObject drawn by page content,
+// form XObjects and annotation appearances.
+type imagePlacements struct {
+	xRefTable *model.XRefTable
+	dpi       map[int]float64 // by image object number
+	smasks    types.IntSet    // soft mask images
+	keep      types.IntSet    // images whose samples need to stay untouched
+}
+
+// addImage records a placement of image sd drawn using ctm.
+func (ip *imagePlacements) addImage(objNr int, sd *types.StreamDict, ctm matrix.Matrix) {
+	w, h := sd.IntEntry("Width"), sd.IntEntry("Height")
+	if w == nil || h == nil || *w <= 0 || *h <= 0 {
+		ip.keep[objNr] = true
+		return
+	}
+
+	// The unit square gets mapped onto the page, in inches.
+	sx := math.Hypot(ctm[0][0], ctm[0][1]) / 72
+	sy := math.Hypot(ctm[1][0], ctm[1][1]) / 72
+	if sx == 0 || sy == 0 {
+		return
+	}
+
+	dpi := math.Min(float64(*w)/sx, float64(*h)/sy)
+	if d, ok := ip.dpi[objNr]; !ok || dpi < d {
+		ip.dpi[objNr] = dpi
+	}
+
+	// A color key mask relies on exact sample values, a stencil mask on its dimensions.
+	if _, found := sd.Find("Mask"); found {
+		ip.keep[objNr] = true
+		if indRef := sd.IndirectRefEntry("Mask"); indRef != nil {
+			ip.keep[indRef.ObjectNumber.Value()] = true
+		}
+	}
+
+	indRef := sd.IndirectRefEntry("SMask")
+	if indRef == nil {
+		return
+	}
+	sdMask, _, err := ip.xRefTable.DereferenceStreamDict(*indRef)
+	if err != nil || sdMask == nil {
+		return
+	}
+	nr := indRef.ObjectNumber.Value()
+	if _, found := sdMask.Find("Matte"); found {
+		// Preserve the dimensions of image and preblended soft mask.
+		ip.keep[objNr], ip.keep[nr] = true, true
+	}
+	ip.smasks[nr] = true
+	ip.addImage(nr, sdMask, ctm)
+}
+
+func (ip *imagePlacements) keepImages(res types.Dict) {
+	d, err := ip.xRefTable.DereferenceDict(res["XObject"])
+	if err != nil || d == nil {
+		return
+	}
+	for _, o := range d {
+		if indRef, ok := o.(types.IndirectRef); ok {
+			ip.keep[indRef.ObjectNumber.Value()] = true
+		}
+	}
+}
+
+func (ip *imagePlacements) scanContent(bb []byte, res types.Dict, ctm matrix.Matrix, depth int) error {
+	ops, err := content.Parse(bb)
+	if err != nil {
+		// Placements remain unknown.
+		ip.keepImages(res)
+		return nil
+	}
+
+	var stack []matrix.Matrix
+
+	for _, op := range ops {
+		switch op.Operator {
+
+		case "q":
+			stack = append(stack, ctm)
+
+		case "Q":
+			if n := len(stack); n > 0 {
+				ctm, stack = stack[n-1], stack[:n-1]
+			}
+
+		case "cm":
+			if m, ok := matrixForOperands(op.Operands); ok {
+				ctm = m.Multiply(ctm)
+			}
+
+		case "Do":
+			name, ok := op.Operand(0).(types.Name)
+			if !ok {
+				continue
+			}
+			if err := ip.scanXObject(name.Value(), res, ctm, depth); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ip *imagePlacements) scanXObject(name string, res types.Dict, ctm matrix.Matrix, depth int) error {
+	rs := &resources{d: res}
+	indRef, err := rs.xObject(ip.xRefTable, name)
+	if err != nil || indRef == nil {
+		return err
+	}
+
+	sd, _, err := ip.xRefTable.DereferenceStreamDict(*indRef)
+	if err != nil || sd == nil {
+		return err
+	}
+
+	switch st := sd.Subtype(); {
+
+	case st != nil && *st == "Image":
+		ip.addImage(indRef.ObjectNumber.Value(), sd, ctm)
+
+	case st != nil && *st == "Form" && depth < maxImagePlacementDepth:
+		return ip.scanForm(sd, res, ctm, depth+1)
+	}
+
+	return nil
+}
+
+// scanForm scans form sd drawn using ctm, res are the resources in effect.
+func (ip *imagePlacements) scanForm(sd *types.StreamDict, res types.Dict, ctm matrix.Matrix, depth int) error {
+	ctm = matrixForArray(ip.xRefTable, sd.Dict["Matrix"]).Multiply(ctm)
+
+	if o, found := sd.Find("Resources"); found {
+		d, err := ip.xRefTable.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		res = d
+	}
+
+	if err := sd.Decode(); err != nil {
+		ip.keepImages(res)
+		return nil
+	}
+
+	return ip.scanContent(sd.Content, res, ctm, depth)
+}
+
+// scanAppearance scans the appearance stream sd of an annotation occupying r, see 12.5.5.
+func (ip *imagePlacements) scanAppearance(sd *types.StreamDict, r types.Rectangle) error {
+	a, err := ip.xRefTable.DereferenceArray(sd.Dict["BBox"])
+	if err != nil || len(a) != 4 {
+		return err
+	}
+	bbox, err := ip.xRefTable.RectForArray(a)
+	if err != nil {
+		return err
+	}
+
+	m := matrixForArray(ip.xRefTable, sd.Dict["Matrix"])
+	t := transformRect(m, bbox.LL.X, bbox.LL.Y, bbox.UR.X, bbox.UR.Y)
+	if t.Width() == 0 || t.Height() == 0 {
+		return nil
+	}
+
+	// Map the transformed bounding box onto the annotation rectangle.
+	sx, sy := r.Width()/t.Width(), r.Height()/t.Height()
+	ctm := matrix.Matrix{{sx, 0, 0}, {0, sy, 0}, {r.LL.X - t.LL.X*sx, r.LL.Y - t.LL.Y*sy, 1}}
+
+	return ip.scanForm(sd, nil, ctm, 1)
+}
+
+func (ip *imagePlacements) scanAnnotations(pageDict types.Dict) error {
+	a, err := ip.xRefTable.DereferenceArray(pageDict["Annots"])
+	if err != nil || a == nil {
+		return err
+	}
+
+	for _, o := range a {
+		d, err := ip.xRefTable.DereferenceDict(o)
+		if err != nil || d == nil {
+			continue
+		}
+
+		arr, err := ip.xRefTable.DereferenceArray(d["Rect"])
+		if err != nil || len(arr) != 4 {
+			continue
+		}
+		r, err := ip.xRefTable.RectForArray(arr)
+		if err != nil {
+			continue
+		}
+
+		ap, err := ip.xRefTable.DereferenceDict(d["AP"])
+		if err != nil || ap == nil {
+			continue
+		}
+
+		o, err := ip.xRefTable.Dereference(ap["N"])
+		if err != nil {
+			continue
+		}
+
+		var sds []types.StreamDict
+		switch o := o.(type) {
+		case types.StreamDict:
+			sds = append(sds, o)
+		case types.Dict:
+			for _, o1 := range o {
+				if sd, _, err := ip.xRefTable.DereferenceStreamDict(o1); err == nil && sd != nil {
+					sds = append(sds, *sd)
+				}
+			}
+		}
+
+		for _, sd := range sds {
+			if err := ip.scanAppearance(&sd, *r); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ip *imagePlacements) scanPage(ctx *model.Context, pageNr int) error {
+	d, _, inhPAttrs, err := ctx.PageDict(pageNr, false)
+	if err != nil || d == nil {
+		return err
+	}
+
+	bb, err := ctx.PageContent(d)
+	if err != nil && err != model.ErrNoContent {
+		return err
+	}
+
+	ctm := matrix.IdentMatrix
+	if f, err := ctx.DereferenceNumber(d["UserUnit"]); err == nil && f > 0 {
+		ctm = matrix.Matrix{{f, 0, 0}, {0, f, 0}, {0, 0, 1}}
+	}
+
+	if err := ip.scanContent(bb, inhPAttrs.Resources, ctm, 0); err != nil {
+		return err
+	}
+
+	return ip.scanAnnotations(d)
+}
+
+// samples8 returns w x h samples of comps color components with bpc bits each scaled to 8 bits per component.
+func samples8(bb []byte, w, h, comps, bpc int) ([]byte, bool) {
+	switch bpc {
+	case 1, 2, 4, 8, 16:
+	default:
+		return nil, false
+	}
+
+	n := w * comps
+	stride := (n*bpc + 7) / 8
+	if len(bb) < stride*h {
+		return nil, false
+	}
+
+	if bpc == 8 {
+		return bb[:n*h], true
+	}
+
+	max := 1<<bpc - 1
+	out := make([]byte, n*h)
+	for y := 0; y < h; y++ {
+		row := bb[y*stride:]
+		for i := 0; i < n; i++ {
+			if bpc == 16 {
+				out[y*n+i] = row[2*i]
+				continue
+			}
+			bit := i * bpc
+			v := int(row[bit/8]>>(8-bpc-bit%8)) & max
+			out[y*n+i] = byte(v * 255 / max)
+		}
+	}
+
+	return out, true
+}
+
+// downsample scales w x h samples of comps 8 bit color components down to nw x nh by averaging.
+func downsample(bb []byte, w, h, comps, nw, nh int) []byte {
+	out := make([]byte, nw*nh*comps)
+	sums := make([]int, nw*comps)
+
+	for y := 0; y < nh; y++ {
+		y0, y1 := y*h/nh, (y+1)*h/nh
+		for i := range sums {
+			sums[i] = 0
+		}
+		for sy := y0; sy < y1; sy++ {
+			row := bb[sy*w*comps:]
+			for x := 0; x < nw; x++ {
+				for sx := x * w / nw; sx < (x+1)*w/nw; sx++ {
+					for c := 0; c < comps; c++ {
+						sums[x*comps+c] += int(row[sx*comps+c])
+					}
+				}
+			}
+		}
+		for x := 0; x < nw; x++ {
+			n := (y1 - y0) * ((x+1)*w/nw - x*w/nw)
+			for c := 0; c < comps; c++ {
+				out[(y*nw+x)*comps+c] = byte((sums[x*comps+c] + n/2) / n)
+			}
+		}
+	}
+
+	return out
+}
+
+// bilevel packs 8 bit gray samples into 1 bit per pixel.
+func bilevel(bb []byte, w, h int) []byte {
+	stride := (w + 7) / 8
+	out := make([]byte, stride*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if bb[y*w+x] >= 128 {
+				out[y*stride+x/8] |= 0x80 >> (x % 8)
+			}
+		}
+	}
+	return out
+}
+
+// photographic returns true for images using lots of colors not dominated by a few of them as found in drawings and screenshots.
+func photographic(bb []byte, comps int) bool {
+	counts := map[uint32]int{}
+	for i := 0; i+comps <= len(bb); i += comps {
+		var k uint32
+		for c := 0; c < comps; c++ {
+			k = k<<8 | uint32(bb[i+c])
+		}
+		counts[k]++
+		if len(counts) > 1<<16 {
+			return true
+		}
+	}
+
+	cc := make([]int, 0, len(counts))
+	for _, c := range counts {
+		cc = append(cc, c)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(cc)))
+
+	var n int
+	for i := 0; i < len(cc) && i < 16; i++ {
+		n += cc[i]
+	}
+
+	return n*10 < len(bb)/comps*9
+}
+
+func encodeJPEG(bb []byte, w, h, comps, quality int) ([]byte, error) {
+	r := image.Rect(0, 0, w, h)
+
+	var img image.Image = &image.Gray{Pix: bb, Stride: w, Rect: r}
+	if comps == 3 {
+		rgba := image.NewRGBA(r)
+		for i, j := 0, 0; i < len(bb); i, j = i+3, j+4 {
+			rgba.Pix[j], rgba.Pix[j+1], rgba.Pix[j+2], rgba.Pix[j+3] = bb[i], bb[i+1], bb[i+2], 0xFF
+		}
+		img = rgba
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// resampleable returns true for images whose samples may be averaged.
+func resampleable(xRefTable *model.XRefTable, sd *types.StreamDict) bool {
+	if mask := sd.BooleanEntry("ImageMask"); mask != nil && *mask {
+		return true
+	}
+
+	o, err := xRefTable.Dereference(sd.Dict["ColorSpace"])
+	if err != nil {
+		return false
+	}
+
+	var cs types.Name
+	switch o := o.(type) {
+	case types.Name:
+		cs = o
+	case types.Array:
+		if len(o) > 0 {
+			cs, _ = o[0].(types.Name)
+		}
+	}
+
+	switch cs {
+	case model.DeviceGrayCS, model.DeviceRGBCS, model.DeviceCMYKCS, model.CalGrayCS, model.CalRGBCS, model.ICCBasedCS:
+		return true
+	}
+
+	return false
+}
+
+// optimizeImage returns a downsampled and recompressed copy of image sd placed at an effective resolution of dpi
+// or nil if this does not result in a smaller stream.
+func optimizeImage(ctx *model.Context, sd *types.StreamDict, dpi float64, smask bool) (*types.StreamDict, error) {
+	opt := ctx.Conf.ImageOptimization
+
+	for _, f := range sd.FilterPipeline {
+		if f.Name == filter.JPX {
+			return nil, nil
+		}
+	}
+
+	if !resampleable(ctx.XRefTable, sd) {
+		return nil, nil
+	}
+
+	w, h := *sd.IntEntry("Width"), *sd.IntEntry("Height")
+
+	bb, bpc, comps, cs, ok, err := imageSamples(ctx.XRefTable, sd)
+	if err != nil || !ok || comps <= 0 {
+		return nil, nil
+	}
+
+	mono := bpc == 1 && comps == 1
+	dct := sd.HasSoleFilterNamed(filter.DCT)
+
+	if bb, ok = samples8(bb, w, h, comps, bpc); !ok {
+		return nil, nil
+	}
+
+	w0, h0 := w, h
+	if dpi > float64(opt.Threshold) {
+		f := float64(opt.DPI) / dpi
+		nw, nh := int(math.Max(1, math.Round(float64(w)*f))), int(math.Max(1, math.Round(float64(h)*f)))
+		bb = downsample(bb, w, h, comps, nw, nh)
+		w, h = nw, nh
+	}
+
+	sd1 := sd.Clone().(types.StreamDict)
+	sd1.Update("Width", types.Integer(w))
+	sd1.Update("Height", types.Integer(h))
+	sd1.Delete("DecodeParms")
+	if cs != "" {
+		if n, err := ColorSpaceComponents(ctx.XRefTable, sd); err != nil || n != comps {
+			sd1.Update("ColorSpace", cs)
+			sd1.Delete("Decode")
+		}
+	}
+
+	var fName string
+
+	switch {
+
+	case mono:
+		fName = filter.CCITTFax
+		parms := types.Dict(
+			map[string]types.Object{
+				"K":       types.Integer(-1),
+				"Columns": types.Integer(w),
+				"Rows":    types.Integer(h),
+			},
+		)
+		sd1.Content = bilevel(bb, w, h)
+		sd1.FilterPipeline = []types.PDFFilter{{Name: filter.CCITTFax, DecodeParms: parms}}
+		sd1.Update("Filter", types.Name(filter.CCITTFax))
+		sd1.Update("DecodeParms", parms)
+		if err := sd1.Encode(); err != nil {
+			return nil, err
+		}
+
+	case !smask && comps != 4 && (dct || photographic(bb, comps)):
+		fName = filter.DCT
+		buf, err := encodeJPEG(bb, w, h, comps, opt.JPEGQuality)
+		if err != nil {
+			return nil, err
+		}
+		sd1.Update("BitsPerComponent", types.Integer(8))
+		sd1.Content = buf
+		sd1.FilterPipeline = nil
+		sd1.Update("Filter", types.Name(filter.DCT))
+		// Calling Encode without FilterPipeline ensures an encoded stream in sd.Raw.
+		if err := sd1.Encode(); err != nil {
+			return nil, err
+		}
+		sd1.Content = nil
+		sd1.FilterPipeline = []types.PDFFilter{{Name: filter.DCT, DecodeParms: nil}}
+
+	default:
+		fName = filter.Flate
+		sd1.Update("BitsPerComponent", types.Integer(8))
+		sd1.Content = bb
+		sd1.FilterPipeline = []types.PDFFilter{{Name: filter.Flate}}
+		sd1.Update("Filter", types.Name(filter.Flate))
+		if ctx.FlatePredictor {
+			err = sd1.EncodeFlatePredicted(comps, 8, w)
+		} else {
+			err = sd1.Encode()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if log.OptimizeEnabled() {
+		log.Optimize.Printf("optimizeImage: %dx%d %.0f dpi %d bytes -> %dx%d %s %d bytes\n", w0, h0, dpi, len(sd.Raw), w, h, fName, len(sd1.Raw))
+	}
+
+	if len(sd1.Raw) >= len(sd.Raw) {
+		return nil, nil
+	}
+
+	return &sd1, nil
+}
+
+// optimizeImages downsamples images exceeding the configured resolution threshold
+// and recompresses images either as DCT (photographic), CCITT (black and white) or Flate (anything else).
+// Images only drawn by patterns, Type 3 glyphs or soft mask groups remain untouched.
+func optimizeImages(ctx *model.Context) error {
+	if log.OptimizeEnabled() {
+		log.Optimize.Println("optimizeImages begin")
+	}
+
+	ip := &imagePlacements{
+		xRefTable: ctx.XRefTable,
+		dpi:       map[int]float64{},
+		smasks:    types.IntSet{},
+		keep:      types.IntSet{},
+	}
+
+	for i := 1; i <= ctx.PageCount; i++ {
+		if err := ip.scanPage(ctx, i); err != nil {
+			return err
+		}
+	}
+
+	objNrs := make([]int, 0, len(ip.dpi))
+	for objNr := range ip.dpi {
+		if !ip.keep[objNr] {
+			objNrs = append(objNrs, objNr)
+		}
+	}
+	sort.Ints(objNrs)
+
+	for _, objNr := range objNrs {
+		entry, found := ctx.FindTableEntryLight(objNr)
+		if !found || entry.Free {
+			continue
+		}
+		sd, ok := entry.Object.(types.StreamDict)
+		if !ok {
+			continue
+		}
+		sd1, err := optimizeImage(ctx, &sd, ip.dpi[objNr], ip.smasks[objNr])
+		if err != nil {
+			return err
+		}
+		if sd1 != nil {
+			entry.Object = *sd1
+		}
+	}
+
+	if log.OptimizeEnabled() {
+		log.Optimize.Println("optimizeImages end")
+	}
+
+	return nil
+}
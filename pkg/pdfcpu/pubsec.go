@@ -0,0 +1,565 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+// Functions dealing with the public-key security handler Adobe.PubSec, see 7.6.5.
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"hash"
+	"io"
+	"math/big"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	filterPubSec       = "Adobe.PubSec"
+	subFilterPKCS7S5   = "adbe.pkcs7.s5"
+	defaultCryptFilter = "DefaultCryptFilter"
+	pubSecSeedLength   = 20
+)
+
+var (
+	oidData               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEnvelopedData      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidRSAEncryption      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidRSAESOAEP          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 7}
+	oidDESEDE3CBC         = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES128CBC          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	errPubSecNoRecipient  = errors.New("pdfcpu: the private key does not match any recipient of this file")
+	errPubSecMissingKey   = errors.New("pdfcpu: this file is encrypted for recipients, please provide a private key")
+	errPubSecNoPasswords  = errors.New("pdfcpu: passwords are not supported for public-key encryption")
+	errPubSecUnsupportedV = errors.New("pdfcpu: public-key encryption requires AES-128 or AES-256")
+)
+
+// PKCS#7 EnvelopedData, see RFC 5652
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT
+}
+
+type envelopedData struct {
+	Version              int
+	RecipientInfos       []asn1.RawValue `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"optional,tag:0"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type keyTransRecipientInfo struct {
+	Version                int
+	RID                    asn1.RawValue // IssuerAndSerialNumber or [0] SubjectKeyIdentifier
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+func pkcs7Pad(b []byte, blockSize int) []byte {
+	n := blockSize - len(b)%blockSize
+	return append(b, bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func pkcs7Unpad(b []byte, blockSize int) ([]byte, error) {
+	if len(b) == 0 || len(b)%blockSize != 0 {
+		return nil, errors.New("pdfcpu: invalid padding")
+	}
+	n := int(b[len(b)-1])
+	if n == 0 || n > blockSize || n > len(b) {
+		return nil, errors.New("pdfcpu: invalid padding")
+	}
+	for _, c := range b[len(b)-n:] {
+		if int(c) != n {
+			return nil, errors.New("pdfcpu: invalid padding")
+		}
+	}
+	return b[:len(b)-n], nil
+}
+
+// envelope encrypts content for recipients and returns the DER encoded PKCS#7 EnvelopedData.
+func envelope(content []byte, recipients []*x509.Certificate) ([]byte, error) {
+	cek := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, cek); err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+
+	cb, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := pkcs7Pad(append([]byte(nil), content...), aes.BlockSize)
+	cipher.NewCBCEncrypter(cb, iv).CryptBlocks(encrypted, encrypted)
+
+	params, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+
+	var ris []asn1.RawValue
+
+	for _, cert := range recipients {
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.Errorf("pdfcpu: unsupported recipient key type %T, need RSA: %s", cert.PublicKey, cert.Subject)
+		}
+
+		ek, err := rsa.EncryptPKCS1v15(rand.Reader, pub, cek)
+		if err != nil {
+			return nil, err
+		}
+
+		rid, err := asn1.Marshal(issuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, SerialNumber: cert.SerialNumber})
+		if err != nil {
+			return nil, err
+		}
+
+		ri, err := asn1.Marshal(keyTransRecipientInfo{
+			RID:                    asn1.RawValue{FullBytes: rid},
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+			EncryptedKey:           ek,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ris = append(ris, asn1.RawValue{FullBytes: ri})
+	}
+
+	ed, err := asn1.Marshal(envelopedData{
+		RecipientInfos: ris,
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: params}},
+			EncryptedContent:           encrypted,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkcs7ContentInfo{
+		ContentType: oidEnvelopedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: ed},
+	})
+}
+
+func decryptContentKey(ri keyTransRecipientInfo, key crypto.Decrypter) ([]byte, error) {
+	if _, ok := key.Public().(*rsa.PublicKey); !ok {
+		return nil, errors.Errorf("pdfcpu: unsupported private key type %T, need RSA", key.Public())
+	}
+
+	alg := ri.KeyEncryptionAlgorithm.Algorithm
+
+	switch {
+	case alg.Equal(oidRSAEncryption):
+		return key.Decrypt(rand.Reader, ri.EncryptedKey, &rsa.PKCS1v15DecryptOptions{})
+	case alg.Equal(oidRSAESOAEP):
+		// Default RSAES-OAEP parameters
+		return key.Decrypt(rand.Reader, ri.EncryptedKey, &rsa.OAEPOptions{Hash: crypto.SHA1})
+	}
+
+	return nil, errors.Errorf("pdfcpu: unsupported key encryption algorithm: %s", alg)
+}
+
+func decryptContent(eci encryptedContentInfo, cek []byte) ([]byte, error) {
+	var iv []byte
+	if _, err := asn1.Unmarshal(eci.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: corrupt content encryption parameters")
+	}
+
+	var (
+		cb  cipher.Block
+		err error
+	)
+
+	alg := eci.ContentEncryptionAlgorithm.Algorithm
+
+	switch {
+	case alg.Equal(oidAES128CBC), alg.Equal(oidAES192CBC), alg.Equal(oidAES256CBC):
+		cb, err = aes.NewCipher(cek)
+	case alg.Equal(oidDESEDE3CBC):
+		cb, err = des.NewTripleDESCipher(cek)
+	default:
+		return nil, errors.Errorf("pdfcpu: unsupported content encryption algorithm: %s", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bb := eci.EncryptedContent
+	if len(iv) != cb.BlockSize() || len(bb) == 0 || len(bb)%cb.BlockSize() != 0 {
+		return nil, errors.New("pdfcpu: corrupt encrypted content")
+	}
+
+	bb = append([]byte(nil), bb...)
+	cipher.NewCBCDecrypter(cb, iv).CryptBlocks(bb, bb)
+
+	return pkcs7Unpad(bb, cb.BlockSize())
+}
+
+// openEnvelope returns the content of the DER encoded PKCS#7 EnvelopedData bb
+// if one of its recipients matches key.
+func openEnvelope(bb []byte, key crypto.Decrypter) ([]byte, error) {
+	var ci pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(bb, &ci); err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: corrupt recipient")
+	}
+
+	if !ci.ContentType.Equal(oidEnvelopedData) {
+		return nil, errors.Errorf("pdfcpu: unexpected recipient content type: %s", ci.ContentType)
+	}
+
+	var ed envelopedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: corrupt recipient")
+	}
+
+	for _, raw := range ed.RecipientInfos {
+		if raw.Class != asn1.ClassUniversal || raw.Tag != asn1.TagSequence {
+			// Skip all but key transport recipients.
+			continue
+		}
+
+		var ri keyTransRecipientInfo
+		if _, err := asn1.Unmarshal(raw.FullBytes, &ri); err != nil {
+			continue
+		}
+
+		cek, err := decryptContentKey(ri, key)
+		if err != nil {
+			continue
+		}
+
+		if content, err := decryptContent(ed.EncryptedContentInfo, cek); err == nil {
+			return content, nil
+		}
+	}
+
+	return nil, errPubSecNoRecipient
+}
+
+// pubSecKey computes the file encryption key, see 7.6.5.3 Public-key encryption algorithms.
+func pubSecKey(seed []byte, recipients [][]byte, encryptMetadata, aes256 bool) []byte {
+	var h hash.Hash = sha1.New()
+	n := 16
+	if aes256 {
+		h, n = sha256.New(), 32
+	}
+
+	h.Write(seed)
+	for _, bb := range recipients {
+		h.Write(bb)
+	}
+	if !encryptMetadata {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+
+	return h.Sum(nil)[:n]
+}
+
+func pubSecCryptFilter(d types.Dict) (types.Dict, error) {
+	stmf := d.NameEntry("StmF")
+	if stmf == nil {
+		return nil, errors.New("pdfcpu: unsupported encryption: missing \"StmF\"")
+	}
+
+	cf := d.DictEntry("CF")
+	if cf == nil {
+		return nil, errors.New("pdfcpu: unsupported encryption: missing \"CF\"")
+	}
+
+	d1 := cf.DictEntry(*stmf)
+	if d1 == nil {
+		return nil, errors.Errorf("pdfcpu: unsupported encryption: entry \"%s\" missing in \"CF\"", *stmf)
+	}
+
+	return d1, nil
+}
+
+func pubSecRecipients(d types.Dict) ([][]byte, error) {
+	arr := d.ArrayEntry("Recipients")
+	if len(arr) == 0 {
+		return nil, errors.New("pdfcpu: unsupported encryption: missing \"Recipients\"")
+	}
+
+	var ss [][]byte
+
+	for _, o := range arr {
+		var (
+			bb  []byte
+			err error
+		)
+		switch s := o.(type) {
+		case types.StringLiteral:
+			bb, err = types.Unescape(s.Value())
+		case types.HexLiteral:
+			bb, err = s.Bytes()
+		default:
+			err = errors.New("pdfcpu: unsupported encryption: invalid \"Recipients\"")
+		}
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, bb)
+	}
+
+	return ss, nil
+}
+
+func encryptMetadata(d ...types.Dict) bool {
+	for _, d := range d {
+		if emd := d.BooleanEntry("EncryptMetadata"); emd != nil {
+			return *emd
+		}
+	}
+	return true
+}
+
+// supportedPubSecEncryption returns the encryption parameters of the public-key security handler
+// without the permissions, which are only available to recipients.
+func supportedPubSecEncryption(ctx *model.Context, d types.Dict) (*model.Enc, types.Dict, error) {
+	if sf := d.NameEntry("SubFilter"); sf == nil || *sf != subFilterPKCS7S5 {
+		return nil, nil, errors.Errorf("pdfcpu: unsupported encryption: \"SubFilter\" must be \"%s\"", subFilterPKCS7S5)
+	}
+
+	l, err := length(d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v, err := checkV(ctx, d, l)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if *v != 4 && *v != 5 {
+		return nil, nil, errPubSecUnsupportedV
+	}
+
+	cf, err := pubSecCryptFilter(d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfm := cf.NameEntry("CFM")
+	if cfm == nil || (*v == 4 && *cfm != "AESV2") || (*v == 5 && *cfm != "AESV3") {
+		return nil, nil, errPubSecUnsupportedV
+	}
+
+	// The standard security handler revision using the same algorithms for encrypting objects.
+	r, l := 4, 128
+	if *v == 5 {
+		r, l = 5, 256
+	}
+
+	return &model.Enc{L: l, R: r, V: *v, Emd: encryptMetadata(d, cf), PubSec: true}, cf, nil
+}
+
+func setupPubSecEncryptionKey(ctx *model.Context, d types.Dict) (err error) {
+	if ctx.DecryptKey == nil {
+		if ctx.OwnerPW != "" || ctx.UserPW != "" {
+			return errPubSecNoPasswords
+		}
+		return errPubSecMissingKey
+	}
+
+	var cf types.Dict
+	if ctx.E, cf, err = supportedPubSecEncryption(ctx, d); err != nil {
+		return err
+	}
+
+	recipients, err := pubSecRecipients(cf)
+	if err != nil {
+		return err
+	}
+
+	for _, bb := range recipients {
+		content, err := openEnvelope(bb, ctx.DecryptKey)
+		if err == errPubSecNoRecipient {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(content) < pubSecSeedLength+4 {
+			return errors.New("pdfcpu: corrupt recipient seed")
+		}
+
+		seed := content[:pubSecSeedLength]
+		ctx.E.P = int(int32(binary.BigEndian.Uint32(content[pubSecSeedLength:])))
+		ctx.EncKey = pubSecKey(seed, recipients, ctx.E.Emd, ctx.E.V == 5)
+
+		if !hasNeededPermissions(ctx.Cmd, ctx.E) {
+			return errors.New("pdfcpu: operation restriced via pdfcpu's permission bits setting")
+		}
+
+		return nil
+	}
+
+	return errPubSecNoRecipient
+}
+
+func newPubSecEncryptDict(keyLength int, recipients []byte, encryptMetadata bool) types.Dict {
+	v, cfm := 4, "AESV2"
+	if keyLength == 256 {
+		v, cfm = 5, "AESV3"
+	}
+
+	d1 := types.Dict(map[string]types.Object{
+		"CFM":        types.Name(cfm),
+		"AuthEvent":  types.Name("DocOpen"),
+		"Length":     types.Integer(keyLength / 8),
+		"Recipients": types.Array{types.HexLiteral(hex.EncodeToString(recipients))},
+	})
+
+	if !encryptMetadata {
+		d1["EncryptMetadata"] = types.Boolean(false)
+	}
+
+	return types.Dict(map[string]types.Object{
+		"Filter":    types.Name(filterPubSec),
+		"SubFilter": types.Name(subFilterPKCS7S5),
+		"V":         types.Integer(v),
+		"Length":    types.Integer(keyLength),
+		"CF":        types.Dict(map[string]types.Object{defaultCryptFilter: d1}),
+		"StmF":      types.Name(defaultCryptFilter),
+		"StrF":      types.Name(defaultCryptFilter),
+	})
+}
+
+// setupPubSecEncryption creates the encryption dict and the file encryption key
+// for ctx.EncryptRecipients being granted the access permissions ctx.Permissions.
+func setupPubSecEncryption(ctx *model.Context) (types.Dict, error) {
+	if !ctx.EncryptUsingAES || (ctx.EncryptKeyLength != 128 && ctx.EncryptKeyLength != 256) {
+		return nil, errPubSecUnsupportedV
+	}
+
+	if ctx.OwnerPW != "" || ctx.UserPW != "" {
+		return nil, errPubSecNoPasswords
+	}
+
+	seed := make([]byte, pubSecSeedLength+4)
+	if _, err := io.ReadFull(rand.Reader, seed[:pubSecSeedLength]); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint32(seed[pubSecSeedLength:], uint32(int32(ctx.Permissions)))
+
+	recipients, err := envelope(seed, ctx.EncryptRecipients)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newPubSecEncryptDict(ctx.EncryptKeyLength, recipients, ctx.EncryptMetadata)
+
+	if ctx.E, _, err = supportedPubSecEncryption(ctx, d); err != nil {
+		return nil, err
+	}
+
+	ctx.E.P = int(ctx.Permissions)
+	ctx.EncKey = pubSecKey(seed[:pubSecSeedLength], [][]byte{recipients}, ctx.E.Emd, ctx.E.V == 5)
+
+	return d, nil
+}
+
+// ParseCertificates returns all PEM or DER encoded certificates of bb.
+func ParseCertificates(bb []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	for {
+		var block *pem.Block
+		if block, bb = pem.Decode(bb); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) > 0 {
+		return certs, nil
+	}
+
+	return x509.ParseCertificates(bb)
+}
+
+// ParsePrivateKey returns the PEM or DER encoded PKCS#1 or PKCS#8 RSA private key bb.
+func ParsePrivateKey(bb []byte) (crypto.Decrypter, error) {
+	for rest := bb; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type == "PRIVATE KEY" || block.Type == "RSA PRIVATE KEY" {
+			bb = block.Bytes
+			break
+		}
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(bb); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(bb)
+	if err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: corrupt private key")
+	}
+
+	k, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf("pdfcpu: unsupported private key type %T, need RSA", key)
+	}
+
+	return k, nil
+}
@@ -20,6 +20,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"sort"
@@ -53,6 +54,7 @@ func ReadFile(inFile string, conf *model.Configuration) (*model.Context, error)
 
 // ReadFileContext reads in a PDF file and builds an internal structure holding its cross reference table aka the PDF model context.
 // If the passed Go context is cancelled, reading will be interrupted.
+// For lazy reading inFile stays open for loading objects on demand until ctx.Close gets called.
 func ReadFileWithContext(c context.Context, inFile string, conf *model.Configuration) (*model.Context, error) {
 	if log.InfoEnabled() {
 		log.Info.Printf("reading %s..\n", inFile)
@@ -63,11 +65,20 @@ func ReadFileWithContext(c context.Context, inFile string, conf *model.Configura
 		return nil, errors.Wrapf(err, "can't open %q", inFile)
 	}
 
-	defer func() {
+	ctx, err := ReadWithContext(c, f, conf)
+	if err != nil {
 		f.Close()
-	}()
+		return nil, err
+	}
 
-	return ReadWithContext(c, f, conf)
+	if ctx.IsLazy() {
+		ctx.Read.Closer = f
+		return ctx, nil
+	}
+
+	f.Close()
+
+	return ctx, nil
 }
 
 // Read takes a readSeeker and generates a PDF model context,
@@ -102,10 +113,16 @@ func ReadWithContext(c context.Context, rs io.ReadSeeker, conf *model.Configurat
 		return nil, errors.Wrap(err, "Read: xRefTable failed")
 	}
 
-	// Make all objects explicitly available (load into memory) in corresponding xRefTable entries.
-	// Also decode any involved object streams.
-	if err = dereferenceXRefTable(c, ctx, conf); err != nil {
-		return nil, err
+	if err = dereferenceXRefTableUsingConf(c, ctx, conf); err != nil {
+		if ctx.Read.XRefReconstructed || errors.Is(err, ErrWrongPassword) || errors.Is(err, errPubSecNoRecipient) || errors.Is(err, errPubSecMissingKey) || c.Err() != nil {
+			return nil, err
+		}
+		// The xref table seemed fine but points to garbage.
+		ctx1, err1 := readWithReconstructedXRefTable(c, rs, conf, ctx.Table, err)
+		if err1 != nil {
+			return nil, err
+		}
+		ctx = ctx1
 	}
 
 	// Some PDFWriters write an incorrect Size into trailer.
@@ -113,6 +130,12 @@ func ReadWithContext(c context.Context, rs io.ReadSeeker, conf *model.Configurat
 		*ctx.XRefTable.Size = len(ctx.XRefTable.Table)
 	}
 
+	if ctx.Incremental || ctx.IsLazy() {
+		// An increment consists of the objects changed from here on
+		// and lazily loaded objects get evicted unless changed.
+		ctx.TrackChanges()
+	}
+
 	if log.ReadEnabled() {
 		log.Read.Println("Read: end")
 	}
@@ -383,21 +406,20 @@ func compressedObject(c context.Context, s string) (types.Object, error) {
 	return nil, errors.New("pdfcpu: compressedObject: stream objects are not to be stored in an object stream")
 }
 
-// Parse all objects of an object stream and save them into objectStreamDict.ObjArray.
-func parseObjectStream(c context.Context, osd *types.ObjectStreamDict) error {
-	if log.ReadEnabled() {
-		log.Read.Printf("parseObjectStream begin: decoding %d objects.\n", osd.ObjCount)
-	}
-
+// objectStreamProlog returns the pairs of object numbers and offsets making up the prolog of an object stream.
+func objectStreamProlog(osd *types.ObjectStreamDict) ([]string, error) {
 	decodedContent := osd.Content
 	if decodedContent == nil {
 		// The actual content will be decoded lazily, only decode the prolog here.
 		var err error
 		decodedContent, err = osd.DecodeLength(int64(osd.FirstObjOffset))
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
+	if len(decodedContent) < osd.FirstObjOffset {
+		return nil, errors.New("pdfcpu: objectStreamProlog: corrupt object stream")
+	}
 	prolog := decodedContent[:osd.FirstObjOffset]
 
 	// The separator used in the prolog shall be white space
@@ -406,7 +428,21 @@ func parseObjectStream(c context.Context, osd *types.ObjectStreamDict) error {
 
 	objs := strings.Fields(string(prolog))
 	if len(objs)%2 > 0 {
-		return errors.New("pdfcpu: parseObjectStream: corrupt object stream dict")
+		return nil, errors.New("pdfcpu: parseObjectStream: corrupt object stream dict")
+	}
+
+	return objs, nil
+}
+
+// Parse all objects of an object stream and save them into objectStreamDict.ObjArray.
+func parseObjectStream(c context.Context, osd *types.ObjectStreamDict) error {
+	if log.ReadEnabled() {
+		log.Read.Printf("parseObjectStream begin: decoding %d objects.\n", osd.ObjCount)
+	}
+
+	objs, err := objectStreamProlog(osd)
+	if err != nil {
+		return err
 	}
 
 	// e.g., 10 0 11 25 = 2 Objects: #10 @ offset 0, #11 @ offset 25
@@ -603,7 +639,7 @@ func xRefStreamDict(c context.Context, ctx *model.Context, o types.Object, objNr
 	}
 	sd := types.NewStreamDict(d, streamOffset, streamLength, streamLengthObjNr, filterPipeline)
 
-	if err = loadEncodedStreamContent(c, ctx, &sd, false); err != nil {
+	if err = loadEncodedStreamContent(c, ctx, &sd, objNr, false); err != nil {
 		return nil, err
 	}
 
@@ -1381,7 +1417,7 @@ func bypassXrefSection(c context.Context, ctx *model.Context, offExtra int64, wa
 				if i >= 0 {
 					_, err = processTrailer(c, ctx, s, string(bb), nil, offExtra)
 					if err == nil {
-						model.ShowRepaired("xreftable")
+						ctx.RecordRepair(0, model.RepairXRef, "xreftable")
 					}
 					return err
 				}
@@ -1576,18 +1612,25 @@ func readXRefTable(c context.Context, ctx *model.Context) (err error) {
 
 	offset, err := offsetLastXRefSection(ctx, 0)
 	if err != nil {
-		return
+		return reconstructXRefTable(c, ctx, nil, err)
 	}
 
 	ctx.Write.OffsetPrevXRef = offset
 
 	err = buildXRefTableStartingAt(c, ctx, offset)
 	if err == io.EOF {
-		return errors.Wrap(err, "readXRefTable: unexpected eof")
+		err = errors.Wrap(err, "readXRefTable: unexpected eof")
 	}
-	if err != nil {
+	if c.Err() != nil {
 		return
 	}
+	if err != nil {
+		// Last resort.
+		return reconstructXRefTable(c, ctx, nil, err)
+	}
+	if ctx.Root == nil {
+		return reconstructXRefTable(c, ctx, ctx.Table, errors.New("pdfcpu: readXRefTable: missing root"))
+	}
 
 	//Log list of free objects (not the "free list").
 	//log.Read.Printf("freelist: %v\n", ctx.freeObjects())
@@ -1904,7 +1947,7 @@ func streamDictForObject(c context.Context, ctx *model.Context, d types.Dict, ob
 }
 
 func dict(ctx *model.Context, d1 types.Dict, objNr, genNr, endInd, streamInd int) (d2 types.Dict, err error) {
-	if ctx.EncKey != nil {
+	if encryptStrings(ctx) {
 		if _, err := decryptDeepObject(d1, objNr, genNr, ctx.EncKey, ctx.AES4Strings, ctx.E.R); err != nil {
 			return nil, err
 		}
@@ -1990,6 +2033,10 @@ func object(c context.Context, ctx *model.Context, offset int64, objNr, genNr in
 		if log.ReadEnabled() {
 			log.Read.Printf("object %d: non matching objNr(%d) or generationNumber(%d) tags found.\n", objNr, *objectNr, *generationNr)
 		}
+		if ctx.Cmd == model.REPAIR && !ctx.Read.XRefReconstructed && objNr != *objectNr {
+			// Trigger xref table reconstruction.
+			return nil, 0, 0, 0, errors.Errorf("pdfcpu: object: xref entry for obj#%d points to obj#%d", objNr, *objectNr)
+		}
 	}
 
 	l = strings.TrimSpace(l)
@@ -2032,7 +2079,7 @@ func ParseObjectWithContext(c context.Context, ctx *model.Context, offset int64,
 		return streamDictForObject(c, ctx, o, objNr, streamInd, streamOffset, offset)
 
 	case types.Array:
-		if ctx.EncKey != nil {
+		if encryptStrings(ctx) {
 			if _, err = decryptDeepObject(o, objNr, genNr, ctx.EncKey, ctx.AES4Strings, ctx.E.R); err != nil {
 				return nil, err
 			}
@@ -2040,7 +2087,7 @@ func ParseObjectWithContext(c context.Context, ctx *model.Context, offset int64,
 		return o, nil
 
 	case types.StringLiteral:
-		if ctx.EncKey != nil {
+		if encryptStrings(ctx) {
 			sl, err := decryptStringLiteral(o, objNr, genNr, ctx.EncKey, ctx.AES4Strings, ctx.E.R)
 			if err != nil {
 				return nil, err
@@ -2050,7 +2097,7 @@ func ParseObjectWithContext(c context.Context, ctx *model.Context, offset int64,
 		return o, nil
 
 	case types.HexLiteral:
-		if ctx.EncKey != nil {
+		if encryptStrings(ctx) {
 			hl, err := decryptHexLiteral(o, objNr, genNr, ctx.EncKey, ctx.AES4Strings, ctx.E.R)
 			if err != nil {
 				return nil, err
@@ -2241,8 +2288,14 @@ func ensureStreamLength(sd *types.StreamDict, rawContent []byte, fixLength bool)
 	}
 }
 
-// loadEncodedStreamContent loads the encoded stream content into sd.
-func loadEncodedStreamContent(c context.Context, ctx *model.Context, sd *types.StreamDict, fixLength bool) error {
+// endstreamFollows returns true if the keyword "endstream" follows the stream data just read from rd.
+func endstreamFollows(rd *bufio.Reader) bool {
+	bb, _ := rd.Peek(32)
+	return bytes.HasPrefix(bytes.TrimLeft(bb, "\x00\t\n\f\r "), []byte("endstream"))
+}
+
+// loadEncodedStreamContent loads the encoded stream content of object objNr into sd.
+func loadEncodedStreamContent(c context.Context, ctx *model.Context, sd *types.StreamDict, objNr int, fixLength bool) error {
 	if log.ReadEnabled() {
 		log.Read.Printf("loadEncodedStreamContent: begin\n%v\n", sd)
 	}
@@ -2288,6 +2341,20 @@ func loadEncodedStreamContent(c context.Context, ctx *model.Context, sd *types.S
 		return err
 	}
 
+	if ctx.Cmd == model.REPAIR && l1 > 0 && !endstreamFollows(rd) {
+		// Wrong stream length, read until "endstream" instead.
+		if rd, err = newPositionedReader(ctx.Read.RS, &newOffset); err != nil {
+			return err
+		}
+		if rawContent, err = readStreamContentBlindly(rd); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Cmd == model.REPAIR && !fixLength && (sd.StreamLength == nil || int64(len(rawContent)) != *sd.StreamLength) {
+		ctx.RecordRepair(objNr, model.RepairStreamLength, fmt.Sprintf("obj#%d: stream length %d", objNr, len(rawContent)))
+	}
+
 	ensureStreamLength(sd, rawContent, fixLength)
 
 	sd.Raw = rawContent
@@ -2299,20 +2366,44 @@ func loadEncodedStreamContent(c context.Context, ctx *model.Context, sd *types.S
 	return nil
 }
 
+// decryptPendingStreams decrypts and decodes all streams waiting for the embedded file streams to be identified.
+func decryptPendingStreams(ctx *model.Context) error {
+	if len(ctx.Read.PendingStreams) == 0 {
+		return nil
+	}
+
+	objNrs, err := embeddedFileStreams(ctx.XRefTable)
+	if err != nil {
+		return err
+	}
+	ctx.EmbeddedFileStreams = objNrs
+
+	for objNr := range ctx.Read.PendingStreams {
+		entry, found := ctx.Find(objNr)
+		if !found {
+			continue
+		}
+		sd, ok := entry.Object.(types.StreamDict)
+		if !ok {
+			continue
+		}
+		if err := saveDecodedStreamContent(ctx, &sd, objNr, *entry.Generation, ctx.DecodeAllStreams); err != nil {
+			return err
+		}
+		entry.Object = sd
+	}
+
+	ctx.Read.PendingStreams = types.IntSet{}
+
+	return nil
+}
+
 // Decodes the raw encoded stream content and saves it to streamDict.Content.
 func saveDecodedStreamContent(ctx *model.Context, sd *types.StreamDict, objNr, genNr int, decode bool) (err error) {
 	if log.ReadEnabled() {
 		log.Read.Printf("saveDecodedStreamContent: begin decode=%t\n", decode)
 	}
 
-	// If the "Identity" crypt filter is used we do not need to decrypt.
-	if ctx != nil && ctx.EncKey != nil {
-		if len(sd.FilterPipeline) == 1 && sd.FilterPipeline[0].Name == "Crypt" {
-			sd.Content = sd.Raw
-			return nil
-		}
-	}
-
 	// Special case: If the length of the encoded data is 0, we do not need to decode anything.
 	if len(sd.Raw) == 0 {
 		sd.Content = sd.Raw
@@ -2321,12 +2412,28 @@ func saveDecodedStreamContent(ctx *model.Context, sd *types.StreamDict, objNr, g
 
 	// ctx gets created after XRefStream parsing.
 	// XRefStreams are not encrypted.
-	if ctx != nil && ctx.EncKey != nil {
-		if sd.Raw, err = decryptStream(sd.Raw, objNr, genNr, ctx.EncKey, ctx.AES4Streams, ctx.E.R); err != nil {
+	// If the "Identity" crypt filter is used we do not need to decrypt.
+	if ctx != nil {
+		if streamCryptFilterPending(ctx, sd) {
+			// Decrypt and decode later on, see decryptPendingStreams.
+			ctx.Read.PendingStreams[objNr] = true
+			return nil
+		}
+		if ctx.EmbeddedFilesLocked && isEmbeddedFileStream(ctx, sd, objNr) {
+			// Leave encrypted embedded files alone.
+			return nil
+		}
+		decrypt, aes, err := streamCryptFilter(ctx, sd, objNr)
+		if err != nil {
 			return err
 		}
-		l := int64(len(sd.Raw))
-		sd.StreamLength = &l
+		if decrypt {
+			if sd.Raw, err = decryptStream(sd.Raw, objNr, genNr, ctx.EncKey, aes, ctx.E.R); err != nil {
+				return err
+			}
+			l := int64(len(sd.Raw))
+			sd.StreamLength = &l
+		}
 	}
 
 	if !decode {
@@ -2478,7 +2585,7 @@ func decodeObjectStream(c context.Context, ctx *model.Context, objNr int) error
 	}
 
 	// Load encoded stream content to xRefTable.
-	if err = loadEncodedStreamContent(c, ctx, &sd, false); err != nil {
+	if err = loadEncodedStreamContent(c, ctx, &sd, objNr, false); err != nil {
 		return errors.Wrapf(err, "decodeObjectStream: problem dereferencing object stream %d", objNr)
 	}
 
@@ -2597,7 +2704,7 @@ func handleLinearizationParmDict(ctx *model.Context, obj types.Object, objNr int
 
 func loadStreamDict(c context.Context, ctx *model.Context, sd *types.StreamDict, objNr, genNr int, fixLength bool) error {
 	// Load encoded stream content for stream dicts into xRefTable entry.
-	if err := loadEncodedStreamContent(c, ctx, sd, fixLength); err != nil {
+	if err := loadEncodedStreamContent(c, ctx, sd, objNr, fixLength); err != nil {
 		return errors.Wrapf(err, "dereferenceObject: problem dereferencing stream %d", objNr)
 	}
 
@@ -2618,6 +2725,46 @@ func updateBinaryTotalSize(ctx *model.Context, o types.Object) {
 	}
 }
 
+// loadFilterStreams resolves stream valued decode parameters of sd's filter pipeline.
+// For now this applies to JBIG2Globals only.
+func loadFilterStreams(c context.Context, ctx *model.Context, sd *types.StreamDict) error {
+	for i, f := range sd.FilterPipeline {
+		if f.Name != filter.JBIG2 || f.DecodeParms == nil {
+			continue
+		}
+
+		indRef := f.DecodeParms.IndirectRefEntry("JBIG2Globals")
+		if indRef == nil {
+			continue
+		}
+
+		objNr := indRef.ObjectNumber.Value()
+		entry, found := ctx.Find(objNr)
+		if !found || entry.Free {
+			continue
+		}
+
+		if entry.Object == nil && entry.Offset != nil {
+			if err := dereferenceAndLoad(c, ctx, objNr, entry); err != nil {
+				return err
+			}
+		}
+
+		gsd, ok := entry.Object.(types.StreamDict)
+		if !ok {
+			continue
+		}
+
+		if err := gsd.Decode(); err != nil {
+			return err
+		}
+
+		sd.FilterPipeline[i].Streams = map[string][]byte{"JBIG2Globals": gsd.Content}
+	}
+
+	return nil
+}
+
 func dereferenceAndLoad(c context.Context, ctx *model.Context, objNr int, entry *model.XRefTableEntry) error {
 	if log.ReadEnabled() {
 		log.Read.Printf("dereferenceAndLoad: dereferencing object %d\n", objNr)
@@ -2650,6 +2797,9 @@ func dereferenceAndLoad(c context.Context, ctx *model.Context, objNr int, entry
 		if err = loadStreamDict(c, ctx, &sd, objNr, *entry.Generation, false); err != nil {
 			return err
 		}
+		if err = loadFilterStreams(c, ctx, &sd); err != nil {
+			return err
+		}
 		entry.Object = sd
 	}
 
@@ -2838,6 +2988,25 @@ func identifyRootVersion(xRefTable *model.XRefTable) error {
 	return nil
 }
 
+func decodeObjectStreamsForXRefTable(c context.Context, ctx *model.Context) error {
+	if ctx.Read.XRefReconstructed {
+		// Also registers all objects within object streams.
+		return completeReconstructedXRefTable(c, ctx)
+	}
+	return decodeObjectStreams(c, ctx)
+}
+
+func dereferenceXRefTableUsingConf(c context.Context, ctx *model.Context, conf *model.Configuration) error {
+	if ctx.LazyRead {
+		// Objects will be loaded into memory on first access.
+		return dereferenceXRefTableLazily(c, ctx)
+	}
+
+	// Make all objects explicitly available (load into memory) in corresponding xRefTable entries.
+	// Also decode any involved object streams.
+	return dereferenceXRefTable(c, ctx, conf)
+}
+
 // Parse all Objects including stream content from file and save to the corresponding xRefTableEntries.
 // This includes processing of object streams and linearization dicts.
 func dereferenceXRefTable(c context.Context, ctx *model.Context, conf *model.Configuration) error {
@@ -2857,7 +3026,7 @@ func dereferenceXRefTable(c context.Context, ctx *model.Context, conf *model.Con
 	//fmt.Println("pw authenticated")
 
 	// Prepare decompressed objects.
-	if err := decodeObjectStreams(c, ctx); err != nil {
+	if err := decodeObjectStreamsForXRefTable(c, ctx); err != nil {
 		return err
 	}
 
@@ -2866,6 +3035,10 @@ func dereferenceXRefTable(c context.Context, ctx *model.Context, conf *model.Con
 		return err
 	}
 
+	if err := decryptPendingStreams(ctx); err != nil {
+		return err
+	}
+
 	// Identify an optional Version entry in the root object/catalog.
 	if err := identifyRootVersion(xRefTable); err != nil {
 		return err
@@ -2878,6 +3051,93 @@ func dereferenceXRefTable(c context.Context, ctx *model.Context, conf *model.Con
 	return nil
 }
 
+func ensureObjectStream(c context.Context, ctx *model.Context, objNr int) error {
+	entry, ok := ctx.Find(objNr)
+	if !ok {
+		return errors.Errorf("pdfcpu: ensureObjectStream: missing entry for obj#%d", objNr)
+	}
+
+	if _, ok := entry.Object.(types.ObjectStreamDict); ok {
+		return nil
+	}
+
+	return decodeObjectStream(c, ctx, objNr)
+}
+
+// loadObjectLazily parses the object for entry from file including any stream content.
+func loadObjectLazily(c context.Context, ctx *model.Context, objNr int, entry *model.XRefTableEntry) (types.Object, error) {
+	if entry.Compressed {
+		if err := ensureObjectStream(c, ctx, *entry.ObjectStream); err != nil {
+			return nil, err
+		}
+		if err := decompressXRefTableEntry(ctx.XRefTable, objNr, entry); err != nil {
+			return nil, err
+		}
+		if l, ok := entry.Object.(types.LazyObjectStreamObject); ok {
+			return l.DecodedObject(c)
+		}
+		return entry.Object, nil
+	}
+
+	if ctx.Read.IsObjectStreamObject(objNr) {
+		if err := decodeObjectStream(c, ctx, objNr); err != nil {
+			return nil, err
+		}
+		return entry.Object, nil
+	}
+
+	if err := dereferenceAndLoad(c, ctx, objNr, entry); err != nil {
+		return nil, err
+	}
+
+	return entry.Object, nil
+}
+
+// Set up the xRefTable for loading objects on demand.
+// Only the objects needed for decryption and the catalog get loaded right away.
+func dereferenceXRefTableLazily(c context.Context, ctx *model.Context) error {
+	if log.ReadEnabled() {
+		log.Read.Println("dereferenceXRefTableLazily: begin")
+	}
+
+	load := func(objNr int, entry *model.XRefTableEntry) (types.Object, error) {
+		return loadObjectLazily(c, ctx, objNr, entry)
+	}
+
+	ctx.EnableLazyLoading(load, ctx.ObjectCacheSize)
+
+	if err := checkForEncryption(c, ctx); err != nil {
+		return err
+	}
+
+	if ctx.Read.XRefReconstructed {
+		// Objects within object streams have yet to be registered.
+		if err := completeReconstructedXRefTable(c, ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := identifyRootVersion(ctx.XRefTable); err != nil {
+		return err
+	}
+
+	if ownEmbeddedFileCryptFilter(ctx) {
+		// Identifying embedded file streams takes all file specifications.
+		if err := ctx.DisableLazyLoading(); err != nil {
+			return err
+		}
+		if err := decryptPendingStreams(ctx); err != nil {
+			return err
+		}
+	}
+
+	if log.ReadEnabled() {
+		log.Read.Println("dereferenceXRefTableLazily: end")
+	}
+
+	return nil
+}
+
 func handleUnencryptedFile(ctx *model.Context) error {
 	if ctx.Cmd == model.DECRYPT || ctx.Cmd == model.SETPERMISSIONS {
 		return errors.New("pdfcpu: this file is not encrypted")
@@ -2889,8 +3149,12 @@ func handleUnencryptedFile(ctx *model.Context) error {
 
 	// Encrypt subcommand found.
 
-	if ctx.OwnerPW == "" {
-		return errors.New("pdfcpu: please provide owner password and optional user password")
+	if ctx.OwnerPW == "" && len(ctx.EncryptRecipients) == 0 {
+		return errors.New("pdfcpu: please provide owner password and optional user password or recipient certificates")
+	}
+
+	if ctx.EncryptAttachmentsOnly && ctx.UserPW == "" {
+		return errors.New("pdfcpu: please provide the user password protecting the attachments")
 	}
 
 	return nil
@@ -2924,6 +3188,10 @@ func handlePermissions(ctx *model.Context) error {
 }
 
 func setupEncryptionKey(ctx *model.Context, d types.Dict) (err error) {
+	if filter := d.NameEntry("Filter"); filter != nil && *filter == filterPubSec {
+		return setupPubSecEncryptionKey(ctx, d)
+	}
+
 	if ctx.E, err = supportedEncryption(ctx, d); err != nil {
 		return err
 	}
@@ -2965,6 +3233,12 @@ func setupEncryptionKey(ctx *model.Context, d types.Dict) (err error) {
 		return err
 	}
 	if !ok {
+		if embeddedFilesOnlyEncryption(ctx) && ctx.Cmd != model.DECRYPT {
+			// Anything but the embedded files is accessible without a password (AuthEvent EFOpen).
+			ctx.EncKey = nil
+			ctx.EmbeddedFilesLocked = true
+			return nil
+		}
 		return ErrWrongPassword
 	}
 
@@ -17,10 +17,12 @@ limitations under the License.
 package pdfcpu
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"os"
 	"path/filepath"
+	"regexp"
 	"testing"
 )
 
@@ -55,3 +57,56 @@ func TestReadContext(t *testing.T) {
 		t.Errorf("should have failed with timeout, got %s", err)
 	}
 }
+
+func TestReadReconstructXRefTable(t *testing.T) {
+	corrupt := map[string]func([]byte) []byte{
+		"startxref": func(b []byte) []byte {
+			return regexp.MustCompile(`startxref\s+\d+`).ReplaceAll(b, []byte("startxref\n999999999"))
+		},
+		"truncated": func(b []byte) []byte {
+			return b[:bytes.LastIndex(b, []byte("endobj"))+len("endobj")]
+		},
+		"shifted": func(b []byte) []byte {
+			i := bytes.Index(b[len(b)/2:], []byte("endobj")) + len(b)/2 + len("endobj")
+			return append(append(b[:i:i], bytes.Repeat([]byte("\n%garbage"), 20)...), b[i:]...)
+		},
+	}
+
+	// go.pdf uses a classic xref table, 5116.DCT_Filter.pdf uses xref and object streams.
+	for _, fn := range []string{"go.pdf", "5116.DCT_Filter.pdf"} {
+		inFile := filepath.Join("..", "testdata", fn)
+
+		bb, err := os.ReadFile(inFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := Read(bytes.NewReader(bb), nil)
+		if err != nil {
+			t.Fatalf("%s: %v\n", fn, err)
+		}
+
+		for name, f := range corrupt {
+			ctx, err := Read(bytes.NewReader(f(bb)), nil)
+			if err != nil {
+				t.Fatalf("%s %s: %v\n", fn, name, err)
+			}
+
+			if !ctx.Read.XRefReconstructed {
+				t.Fatalf("%s %s: xref table not reconstructed\n", fn, name)
+			}
+
+			if err := ctx.EnsurePageCount(); err != nil {
+				t.Fatalf("%s %s: %v\n", fn, name, err)
+			}
+
+			if err := want.EnsurePageCount(); err != nil {
+				t.Fatalf("%s %s: %v\n", fn, name, err)
+			}
+
+			if ctx.PageCount != want.PageCount {
+				t.Fatalf("%s %s: pageCount want:%d got:%d\n", fn, name, want.PageCount, ctx.PageCount)
+			}
+		}
+	}
+}
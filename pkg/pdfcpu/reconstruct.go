@@ -0,0 +1,550 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/log"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	reconstructBufSize     = 1024 * 1024
+	reconstructBufOverlap  = 64
+	reconstructTrailerSize = 4096
+)
+
+// Matches object headers like "12 0 obj" and the keywords relevant for reconstructing the xref table.
+var reconstructRegExp = regexp.MustCompile(`(\d{1,10})[\x00\t\n\f\r ]+(\d{1,5})[\x00\t\n\f\r ]+obj\b|\bendstream\b|\bendobj\b|\bstream(?:\r\n|\r|\n)|\btrailer\b`)
+
+type objectHeader struct {
+	genNr  int
+	offset int64
+}
+
+type trailerCandidate struct {
+	offset int64
+	d      types.Dict
+}
+
+func isObjectHeaderPrefix(c byte) bool {
+	switch c {
+	case 0x00, 0x09, 0x0A, 0x0C, 0x0D, 0x20, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// scanObjectHeaders scans the whole file for object headers and trailers.
+// For multiply defined objects the last definition wins.
+// Anything within stream data is ignored.
+func scanObjectHeaders(c context.Context, ctx *model.Context) (map[int]objectHeader, []int64, error) {
+	rs := ctx.Read.RS
+	fileSize := ctx.Read.FileSize
+
+	objs := map[int]objectHeader{}
+	trailerOffs := []int64{}
+
+	buf := make([]byte, reconstructBufSize)
+
+	var (
+		pos, next int64
+		inStream  bool
+	)
+
+	for pos < fileSize {
+
+		if err := c.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		if _, err := rs.Seek(pos, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+
+		n, err := fillBuffer(rs, buf)
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+		if n == 0 {
+			break
+		}
+
+		b := buf[:n]
+
+		// Leave room for tokens crossing the buffer boundary, they will be picked up by the next buffer.
+		limit := n
+		if pos+int64(n) < fileSize {
+			limit = n - reconstructBufOverlap
+		}
+
+		for _, m := range reconstructRegExp.FindAllSubmatchIndex(b, -1) {
+
+			if m[0] >= limit {
+				break
+			}
+
+			off := pos + int64(m[0])
+			if off < next {
+				// Already processed.
+				continue
+			}
+			next = pos + int64(m[1])
+
+			tok := b[m[0]:m[1]]
+
+			switch {
+
+			case m[2] >= 0:
+				if inStream || (m[0] > 0 && !isObjectHeaderPrefix(b[m[0]-1])) {
+					continue
+				}
+				objNr, err := strconv.Atoi(string(b[m[2]:m[3]]))
+				if err != nil || objNr == 0 {
+					continue
+				}
+				genNr, err := strconv.Atoi(string(b[m[4]:m[5]]))
+				if err != nil {
+					continue
+				}
+				objs[objNr] = objectHeader{genNr: genNr, offset: off}
+
+			case bytes.HasPrefix(tok, []byte("end")):
+				inStream = false
+
+			case bytes.HasPrefix(tok, []byte("stream")):
+				inStream = true
+
+			default:
+				if !inStream {
+					trailerOffs = append(trailerOffs, off)
+				}
+			}
+		}
+
+		pos += int64(limit)
+	}
+
+	return objs, trailerOffs, nil
+}
+
+// trailerDict parses the trailer dict following the keyword "trailer" at offset.
+func trailerDict(ctx *model.Context, offset int64) (types.Dict, error) {
+	rd, err := newPositionedReader(ctx.Read.RS, &offset)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, reconstructTrailerSize)
+	n, err := fillBuffer(rd, buf)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	i := bytes.Index(buf[:n], []byte("<<"))
+	if i < 0 {
+		return nil, errors.New("pdfcpu: trailerDict: missing trailer dict")
+	}
+
+	s := string(buf[i:n])
+	o, err := model.ParseObject(&s)
+	if err != nil {
+		return nil, err
+	}
+
+	d, ok := o.(types.Dict)
+	if !ok {
+		return nil, errors.New("pdfcpu: trailerDict: corrupt trailer dict")
+	}
+
+	return d, nil
+}
+
+func resetXRefTable(ctx *model.Context) {
+	ctx.Table = map[int]*model.XRefTableEntry{}
+	ctx.Table[0] = model.NewFreeHeadXRefTableEntry()
+	ctx.Size = nil
+	ctx.Root = nil
+	ctx.Info = nil
+	ctx.ID = nil
+	ctx.Encrypt = nil
+	ctx.Read.Linearized = false
+	ctx.Read.Hybrid = false
+	ctx.Read.UsingObjectStreams = false
+	ctx.Read.ObjectStreams = types.IntSet{}
+	ctx.Read.UsingXRefStreams = false
+	ctx.Read.XRefStreams = types.IntSet{}
+	ctx.Write.OffsetPrevXRef = nil
+}
+
+// applyTrailers takes Root, Info, ID and Encrypt from the most recent trailer providing them.
+func applyTrailers(ctx *model.Context, trailers []trailerCandidate) {
+	sort.Slice(trailers, func(i, j int) bool { return trailers[i].offset > trailers[j].offset })
+
+	for _, t := range trailers {
+		if ctx.Encrypt == nil {
+			ctx.Encrypt = t.d.IndirectRefEntry("Encrypt")
+		}
+		if ctx.Root == nil {
+			ctx.Root = t.d.IndirectRefEntry("Root")
+		}
+		if ctx.Info == nil {
+			ctx.Info = t.d.IndirectRefEntry("Info")
+		}
+		if ctx.ID == nil {
+			ctx.ID = t.d.ArrayEntry("ID")
+		}
+	}
+}
+
+func updateSize(ctx *model.Context) {
+	maxObjNr := 0
+	for objNr := range ctx.Table {
+		if objNr > maxObjNr {
+			maxObjNr = objNr
+		}
+	}
+	size := maxObjNr + 1
+	ctx.Size = &size
+}
+
+// recordXRefRepairs records the differences between the reconstructed and the previous xref table.
+func recordXRefRepairs(ctx *model.Context, prev map[int]*model.XRefTableEntry, objNrs []int) {
+	if len(prev) == 0 {
+		// There is no usable xref table to compare with.
+		return
+	}
+	for _, objNr := range objNrs {
+		e, found := ctx.Table[objNr]
+		if !found {
+			continue
+		}
+		p, found := prev[objNr]
+		switch {
+		case !found:
+			ctx.RecordRepair(objNr, model.RepairXRef, fmt.Sprintf("xreftable: added missing obj#%d", objNr))
+		case p.Free || p.Compressed || p.Offset == nil:
+			// Nothing to compare.
+		case *p.Offset != *e.Offset:
+			ctx.RecordRepair(objNr, model.RepairXRef, fmt.Sprintf("xreftable: obj#%d at offset %d instead of %d", objNr, *e.Offset, *p.Offset))
+		}
+	}
+}
+
+// reconstructXRefTable rebuilds the xref table from scratch by scanning the file body
+// for indirect objects and is used as a last resort whenever the xref table is corrupt or missing.
+// prev holds any entries gathered from the corrupt xref table.
+// Objects stored within object streams are registered later on
+// by completeReconstructedXRefTable once any encryption has been set up.
+func reconstructXRefTable(c context.Context, ctx *model.Context, prev map[int]*model.XRefTableEntry, wasErr error) error {
+	if log.ReadEnabled() {
+		log.Read.Printf("reconstructXRefTable after %v\n", wasErr)
+	}
+
+	hv, eolCount, _, err := headerVersion(ctx.Read.RS)
+	if err != nil {
+		return err
+	}
+	ctx.HeaderVersion = hv
+	ctx.Read.EolCount = eolCount
+
+	objs, trailerOffs, err := scanObjectHeaders(c, ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(objs) == 0 {
+		if wasErr != nil {
+			return wasErr
+		}
+		return errors.New("pdfcpu: reconstructXRefTable: no objects found")
+	}
+
+	resetXRefTable(ctx)
+	ctx.Read.XRefReconstructed = true
+
+	objNrs := make([]int, 0, len(objs))
+	for objNr, h := range objs {
+		off, g := h.offset, h.genNr
+		if p, found := prev[objNr]; found && p.Free && p.Generation != nil && *p.Generation > g {
+			// This object has been deleted by an incremental update.
+			ctx.Table[objNr] = &model.XRefTableEntry{Free: true, Offset: &zero, Generation: p.Generation}
+			continue
+		}
+		ctx.Table[objNr] = &model.XRefTableEntry{Offset: &off, Generation: &g}
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+
+	trailers := []trailerCandidate{}
+	for _, off := range trailerOffs {
+		d, err := trailerDict(ctx, off)
+		if err != nil {
+			if log.ReadEnabled() {
+				log.Read.Printf("reconstructXRefTable: skipping trailer at offset %d: %v\n", off, err)
+			}
+			continue
+		}
+		trailers = append(trailers, trailerCandidate{offset: off, d: d})
+	}
+
+	var (
+		catalogs   = types.IntSet{}
+		catalogNr  int
+		catalogOff int64 = -1
+	)
+
+	for _, objNr := range objNrs {
+
+		if err := c.Err(); err != nil {
+			return err
+		}
+
+		h := objs[objNr]
+
+		o, _, _, _, err := object(c, ctx, h.offset, objNr, h.genNr)
+		if err != nil {
+			delete(ctx.Table, objNr)
+			ctx.RecordRepair(objNr, model.RepairObject, fmt.Sprintf("xreftable: skipped corrupt obj#%d", objNr))
+			continue
+		}
+
+		d, ok := o.(types.Dict)
+		if !ok || d.Type() == nil {
+			continue
+		}
+
+		switch *d.Type() {
+
+		case "Catalog":
+			catalogs[objNr] = true
+			if h.offset > catalogOff {
+				catalogNr, catalogOff = objNr, h.offset
+			}
+
+		case "ObjStm":
+			ctx.Read.ObjectStreams[objNr] = true
+
+		case "XRef":
+			// Any xref stream is obsolete now but its dict serves as trailer.
+			trailers = append(trailers, trailerCandidate{offset: h.offset, d: d})
+			ctx.Table[objNr] = &model.XRefTableEntry{Free: true, Offset: &zero, Generation: &h.genNr}
+		}
+	}
+
+	applyTrailers(ctx, trailers)
+
+	if ctx.Root != nil {
+		if e, found := ctx.Table[ctx.Root.ObjectNumber.Value()]; found && !e.Free && !catalogs[ctx.Root.ObjectNumber.Value()] {
+			// Root does not point to a catalog.
+			ctx.Root = nil
+		}
+	}
+
+	if ctx.Root == nil && catalogOff >= 0 {
+		ctx.Root = types.NewIndirectRef(catalogNr, *ctx.Table[catalogNr].Generation)
+		ctx.RecordRepair(catalogNr, model.RepairCatalog, fmt.Sprintf("catalog: using obj#%d", catalogNr))
+	}
+
+	updateSize(ctx)
+
+	if err := ctx.EnsureValidFreeList(); err != nil {
+		return err
+	}
+
+	ctx.RecordRepair(0, model.RepairXRef, fmt.Sprintf("xreftable: reconstructed %d objects", len(objNrs)))
+	recordXRefRepairs(ctx, prev, objNrs)
+
+	return nil
+}
+
+// entryOffset returns the file offset of the object for entry or of its object stream.
+func entryOffset(ctx *model.Context, entry *model.XRefTableEntry) int64 {
+	if entry.Compressed {
+		if e, found := ctx.Table[*entry.ObjectStream]; found && e.Offset != nil {
+			return *e.Offset
+		}
+		return -1
+	}
+	if entry.Offset == nil {
+		return -1
+	}
+	return *entry.Offset
+}
+
+func registerObjectStreamObjects(ctx *model.Context, objStmNr int, osd *types.ObjectStreamDict) error {
+	objStmOff := *ctx.Table[objStmNr].Offset
+
+	objs, err := objectStreamProlog(osd)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(objs); i += 2 {
+
+		objNr, err := strconv.Atoi(objs[i])
+		if err != nil {
+			return err
+		}
+
+		if objNr == 0 || ctx.Read.ObjectStreams[objNr] {
+			continue
+		}
+
+		if e, found := ctx.Table[objNr]; found && !e.Free && entryOffset(ctx, e) > objStmOff {
+			// Superseded by a more recent definition.
+			continue
+		}
+
+		objStreamNr, objStreamInd := objStmNr, i/2
+		ctx.Table[objNr] = &model.XRefTableEntry{
+			Compressed:      true,
+			ObjectStream:    &objStreamNr,
+			ObjectStreamInd: &objStreamInd}
+	}
+
+	return nil
+}
+
+func findCatalogInObjectStreams(c context.Context, ctx *model.Context) (*types.IndirectRef, error) {
+	var (
+		catalogNr  int
+		catalogOff int64 = -1
+	)
+
+	for objNr, e := range ctx.Table {
+
+		if !e.Compressed {
+			continue
+		}
+
+		osd, ok := ctx.Table[*e.ObjectStream].Object.(types.ObjectStreamDict)
+		if !ok {
+			continue
+		}
+
+		o, err := osd.IndexedObject(*e.ObjectStreamInd)
+		if err != nil {
+			continue
+		}
+
+		if l, ok := o.(types.LazyObjectStreamObject); ok {
+			if o, err = l.DecodedObject(c); err != nil {
+				continue
+			}
+		}
+
+		d, ok := o.(types.Dict)
+		if !ok || d.Type() == nil || *d.Type() != "Catalog" {
+			continue
+		}
+
+		if off := entryOffset(ctx, e); off > catalogOff {
+			catalogNr, catalogOff = objNr, off
+		}
+	}
+
+	if catalogOff < 0 {
+		return nil, errors.New("pdfcpu: reconstructXRefTable: missing catalog")
+	}
+
+	return types.NewIndirectRef(catalogNr, 0), nil
+}
+
+// completeReconstructedXRefTable decodes all object streams found during xref table reconstruction
+// and registers their objects.
+func completeReconstructedXRefTable(c context.Context, ctx *model.Context) error {
+	if log.ReadEnabled() {
+		log.Read.Println("completeReconstructedXRefTable: begin")
+	}
+
+	objStmNrs := []int{}
+	for objNr := range ctx.Read.ObjectStreams {
+		objStmNrs = append(objStmNrs, objNr)
+	}
+	sort.Ints(objStmNrs)
+
+	for _, objNr := range objStmNrs {
+
+		if err := c.Err(); err != nil {
+			return err
+		}
+
+		if err := decodeObjectStream(c, ctx, objNr); err != nil {
+			delete(ctx.Read.ObjectStreams, objNr)
+			delete(ctx.Table, objNr)
+			ctx.RecordRepair(objNr, model.RepairObject, fmt.Sprintf("xreftable: skipped corrupt object stream obj#%d", objNr))
+			continue
+		}
+
+		osd := ctx.Table[objNr].Object.(types.ObjectStreamDict)
+		if err := registerObjectStreamObjects(ctx, objNr, &osd); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Root != nil {
+		if _, found := ctx.Table[ctx.Root.ObjectNumber.Value()]; !found {
+			ctx.Root = nil
+		}
+	}
+
+	if ctx.Root == nil {
+		indRef, err := findCatalogInObjectStreams(c, ctx)
+		if err != nil {
+			return err
+		}
+		ctx.Root = indRef
+		objNr := indRef.ObjectNumber.Value()
+		ctx.RecordRepair(objNr, model.RepairCatalog, fmt.Sprintf("catalog: using obj#%d", objNr))
+	}
+
+	updateSize(ctx)
+
+	if log.ReadEnabled() {
+		log.Read.Println("completeReconstructedXRefTable: end")
+	}
+
+	return nil
+}
+
+// readWithReconstructedXRefTable reads rs from scratch based on a reconstructed xref table.
+func readWithReconstructedXRefTable(c context.Context, rs io.ReadSeeker, conf *model.Configuration, prev map[int]*model.XRefTableEntry, wasErr error) (*model.Context, error) {
+	ctx, err := model.NewContext(rs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := reconstructXRefTable(c, ctx, prev, wasErr); err != nil {
+		return nil, err
+	}
+
+	if err := dereferenceXRefTableUsingConf(c, ctx, conf); err != nil {
+		return nil, err
+	}
+
+	return ctx, nil
+}
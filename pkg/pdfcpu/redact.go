@@ -0,0 +1,1244 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math"
+
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/color"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/content"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/text"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// A glyph gets removed if its center lies within a redaction area
+	// or if at least this fraction of its bounding box is covered.
+	minGlyphOverlap = 0.2
+
+	// Max. nesting level of form XObjects subject to redaction.
+	maxRedactionDepth = 16
+)
+
+// RedactionArea represents a page area whose content is to be removed.
+type RedactionArea struct {
+	Rect types.Rectangle    // in default user space
+	Col  *color.SimpleColor // overlay color, nil for no overlay
+}
+
+type redactor struct {
+	xRefTable *model.XRefTable
+	rects     []types.Rectangle
+}
+
+// intersects returns true if r intersects any of the redaction areas.
+// Degenerate rectangles like the bounding box of a horizontal line are taken into account.
+func (rd *redactor) intersects(r types.Rectangle) bool {
+	for _, a := range rd.rects {
+		if r.LL.X < a.UR.X && a.LL.X < r.UR.X && r.LL.Y < a.UR.Y && a.LL.Y < r.UR.Y {
+			return true
+		}
+	}
+	return false
+}
+
+func overlap(r1, r2 types.Rectangle) float64 {
+	w := math.Min(r1.UR.X, r2.UR.X) - math.Max(r1.LL.X, r2.LL.X)
+	h := math.Min(r1.UR.Y, r2.UR.Y) - math.Max(r1.LL.Y, r2.LL.Y)
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	return w * h
+}
+
+// glyphRedacted returns true if a glyph with bounding box bb is to be removed.
+func (rd *redactor) glyphRedacted(bb types.Rectangle) bool {
+	c := types.Point{X: (bb.LL.X + bb.UR.X) / 2, Y: (bb.LL.Y + bb.UR.Y) / 2}
+	area := bb.Width() * bb.Height()
+	for _, a := range rd.rects {
+		if c.X >= a.LL.X && c.X <= a.UR.X && c.Y >= a.LL.Y && c.Y <= a.UR.Y {
+			return true
+		}
+		if area > 0 && overlap(bb, a)/area >= minGlyphOverlap {
+			return true
+		}
+	}
+	return false
+}
+
+func roundCoord(f float64) float64 {
+	return math.Round(f*10000) / 10000
+}
+
+func number(o types.Object) (float64, bool) {
+	switch o := o.(type) {
+	case types.Integer:
+		return float64(o.Value()), true
+	case types.Float:
+		return o.Value(), true
+	}
+	return 0, false
+}
+
+func matrixForOperands(oo []types.Object) (matrix.Matrix, bool) {
+	if len(oo) != 6 {
+		return matrix.IdentMatrix, false
+	}
+	var f [6]float64
+	for i, o := range oo {
+		n, ok := number(o)
+		if !ok {
+			return matrix.IdentMatrix, false
+		}
+		f[i] = n
+	}
+	return matrix.Matrix{{f[0], f[1], 0}, {f[2], f[3], 0}, {f[4], f[5], 1}}, true
+}
+
+func matrixForArray(xRefTable *model.XRefTable, o types.Object) matrix.Matrix {
+	a, err := xRefTable.DereferenceArray(o)
+	if err != nil || len(a) != 6 {
+		return matrix.IdentMatrix
+	}
+	m, _ := matrixForOperands(a)
+	return m
+}
+
+// invert returns the inverse of the affine transformation m.
+func invert(m matrix.Matrix) (matrix.Matrix, bool) {
+	det := m[0][0]*m[1][1] - m[0][1]*m[1][0]
+	if det == 0 {
+		return matrix.IdentMatrix, false
+	}
+	a, b := m[1][1]/det, -m[0][1]/det
+	c, d := -m[1][0]/det, m[0][0]/det
+	e := -(m[2][0]*a + m[2][1]*c)
+	f := -(m[2][0]*b + m[2][1]*d)
+	return matrix.Matrix{{a, b, 0}, {c, d, 0}, {e, f, 1}}, true
+}
+
+// axisAligned returns true if m maps axis aligned rectangles onto axis aligned rectangles.
+func axisAligned(m matrix.Matrix) bool {
+	return m[0][1] == 0 && m[1][0] == 0 || m[0][0] == 0 && m[1][1] == 0
+}
+
+// transformRect returns the bounding box of r transformed by m.
+func transformRect(m matrix.Matrix, llx, lly, urx, ury float64) types.Rectangle {
+	var r types.Rectangle
+	for i, p := range []types.Point{{X: llx, Y: lly}, {X: urx, Y: lly}, {X: llx, Y: ury}, {X: urx, Y: ury}} {
+		p = m.Transform(p)
+		if i == 0 {
+			r = types.Rectangle{LL: p, UR: p}
+			continue
+		}
+		r.LL.X, r.LL.Y = math.Min(r.LL.X, p.X), math.Min(r.LL.Y, p.Y)
+		r.UR.X, r.UR.Y = math.Max(r.UR.X, p.X), math.Max(r.UR.Y, p.Y)
+	}
+	return r
+}
+
+// subtractRect returns the parts of r not covered by a.
+func subtractRect(r, a types.Rectangle) []types.Rectangle {
+	if overlap(r, a) == 0 {
+		return []types.Rectangle{r}
+	}
+	var rr []types.Rectangle
+	if a.LL.Y > r.LL.Y {
+		rr = append(rr, *types.NewRectangle(r.LL.X, r.LL.Y, r.UR.X, a.LL.Y))
+	}
+	if a.UR.Y < r.UR.Y {
+		rr = append(rr, *types.NewRectangle(r.LL.X, a.UR.Y, r.UR.X, r.UR.Y))
+	}
+	lly, ury := math.Max(r.LL.Y, a.LL.Y), math.Min(r.UR.Y, a.UR.Y)
+	if a.LL.X > r.LL.X {
+		rr = append(rr, *types.NewRectangle(r.LL.X, lly, a.LL.X, ury))
+	}
+	if a.UR.X < r.UR.X {
+		rr = append(rr, *types.NewRectangle(a.UR.X, lly, r.UR.X, ury))
+	}
+	return rr
+}
+
+// resources tracks the resources of a content stream and copies them on first modification.
+type resources struct {
+	d      types.Dict
+	copied bool
+}
+
+func (rs *resources) xObject(xRefTable *model.XRefTable, name string) (*types.IndirectRef, error) {
+	if rs.d == nil {
+		return nil, nil
+	}
+	o, found := rs.d.Find("XObject")
+	if !found {
+		return nil, nil
+	}
+	d, err := xRefTable.DereferenceDict(o)
+	if err != nil || d == nil {
+		return nil, err
+	}
+	indRef := d.IndirectRefEntry(name)
+	return indRef, nil
+}
+
+func (rs *resources) copy(xRefTable *model.XRefTable) error {
+	if rs.copied {
+		return nil
+	}
+	d := types.NewDict()
+	if rs.d != nil {
+		d = rs.d.Clone().(types.Dict)
+	}
+	xo := types.NewDict()
+	if o, found := d.Find("XObject"); found {
+		d1, err := xRefTable.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		if d1 != nil {
+			xo = d1.Clone().(types.Dict)
+		}
+	}
+	d["XObject"] = xo
+	rs.d, rs.copied = d, true
+	return nil
+}
+
+// addXObject adds indRef under a new name derived from name.
+func (rs *resources) addXObject(xRefTable *model.XRefTable, indRef types.IndirectRef, name string) (string, error) {
+	if err := rs.copy(xRefTable); err != nil {
+		return "", err
+	}
+
+	xo := rs.d["XObject"].(types.Dict)
+	for i := 1; ; i++ {
+		s := fmt.Sprintf("%sR%d", name, i)
+		if _, found := xo.Find(s); !found {
+			xo[s] = indRef
+			return s, nil
+		}
+	}
+}
+
+// removeXObject removes the XObject name, which might refer to unredacted content.
+func (rs *resources) removeXObject(xRefTable *model.XRefTable, name string) error {
+	if err := rs.copy(xRefTable); err != nil {
+		return err
+	}
+	delete(rs.d["XObject"].(types.Dict), name)
+	return nil
+}
+
+// path collects the path construction operators of a path object.
+type path struct {
+	ops       []content.Operation
+	bbox      *types.Rectangle // in default user space
+	rectsOnly bool
+	clip      bool
+}
+
+func (p *path) extend(ctm matrix.Matrix, oo []types.Object) {
+	for i := 0; i+1 < len(oo); i += 2 {
+		x, _ := number(oo[i])
+		y, _ := number(oo[i+1])
+		q := ctm.Transform(types.Point{X: x, Y: y})
+		if p.bbox == nil {
+			p.bbox = &types.Rectangle{LL: q, UR: q}
+			continue
+		}
+		p.bbox.LL.X, p.bbox.LL.Y = math.Min(p.bbox.LL.X, q.X), math.Min(p.bbox.LL.Y, q.Y)
+		p.bbox.UR.X, p.bbox.UR.Y = math.Max(p.bbox.UR.X, q.X), math.Max(p.bbox.UR.Y, q.Y)
+	}
+}
+
+func (p *path) add(op content.Operation, ctm matrix.Matrix) {
+	if len(p.ops) == 0 {
+		p.rectsOnly = true
+	}
+	p.ops = append(p.ops, op)
+
+	switch op.Operator {
+	case "W", "W*":
+		p.clip = true
+		return
+	case "re":
+		if len(op.Operands) == 4 {
+			x, _ := number(op.Operands[0])
+			y, _ := number(op.Operands[1])
+			w, _ := number(op.Operands[2])
+			h, _ := number(op.Operands[3])
+			p.extend(ctm, []types.Object{types.Float(x), types.Float(y), types.Float(x + w), types.Float(y), types.Float(x), types.Float(y + h), types.Float(x + w), types.Float(y + h)})
+		}
+		return
+	}
+
+	// Control points of Bézier curves are included.
+	p.rectsOnly = false
+	p.extend(ctm, op.Operands)
+}
+
+func stroking(op string) bool {
+	switch op {
+	case "S", "s", "B", "B*", "b", "b*":
+		return true
+	}
+	return false
+}
+
+// subtractRects redraws a path consisting of rectangles only minus the redaction areas.
+// The orientation of rectangles is retained so the result does not depend on the fill rule.
+func (rd *redactor) subtractRects(p *path, op content.Operation, ctm matrix.Matrix) []content.Operation {
+	inv, ok := invert(ctm)
+	if !ok {
+		return nil
+	}
+
+	var ops []content.Operation
+
+	for _, re := range p.ops {
+		if len(re.Operands) != 4 {
+			continue
+		}
+		var f [4]float64
+		for i, o := range re.Operands {
+			f[i], _ = number(o)
+		}
+		x, y, w, h := f[0], f[1], f[2], f[3]
+
+		rr := []types.Rectangle{transformRect(ctm, x, y, x+w, y+h)}
+		for _, a := range rd.rects {
+			var rr1 []types.Rectangle
+			for _, r := range rr {
+				rr1 = append(rr1, subtractRect(r, a)...)
+			}
+			rr = rr1
+		}
+
+		for _, r := range rr {
+			// Back into the local coordinate system.
+			l := transformRect(inv, r.LL.X, r.LL.Y, r.UR.X, r.UR.Y)
+			x1, w1 := l.LL.X, l.Width()
+			if w < 0 {
+				x1, w1 = l.UR.X, -w1
+			}
+			y1, h1 := l.LL.Y, l.Height()
+			if h < 0 {
+				y1, h1 = l.UR.Y, -h1
+			}
+			ops = append(ops, content.Operation{Operator: "re", Operands: []types.Object{
+				types.Float(roundCoord(x1)), types.Float(roundCoord(y1)), types.Float(roundCoord(w1)), types.Float(roundCoord(h1)),
+			}})
+		}
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	return append(ops, op)
+}
+
+// redactPath returns the operations for painting path p using op with respect to the redaction areas.
+func (rd *redactor) redactPath(p *path, op content.Operation, gs gState) ([]content.Operation, bool) {
+	if len(p.ops) == 0 || p.bbox == nil || op.Operator == "n" {
+		// Clipping paths are not painted.
+		return append(p.ops, op), false
+	}
+
+	bb := *p.bbox
+	if stroking(op.Operator) {
+		scale := math.Sqrt(math.Abs(gs.ctm[0][0]*gs.ctm[1][1] - gs.ctm[0][1]*gs.ctm[1][0]))
+		d := math.Max(gs.lineWidth*scale, 1) / 2
+		bb = *types.NewRectangle(bb.LL.X-d, bb.LL.Y-d, bb.UR.X+d, bb.UR.Y+d)
+	}
+
+	if !rd.intersects(bb) {
+		return append(p.ops, op), false
+	}
+
+	switch op.Operator {
+	case "f", "F", "f*":
+		if p.rectsOnly && !p.clip && axisAligned(gs.ctm) {
+			return rd.subtractRects(p, op, gs.ctm), true
+		}
+	}
+
+	if p.clip {
+		// Retain the clipping path.
+		return append(p.ops, content.Operation{Operator: "n"}), true
+	}
+
+	return nil, true
+}
+
+// redactText returns the replacement for the text showing operation op
+// along with true if any of the characters cc shown by op are to be removed.
+func (rd *redactor) redactText(op content.Operation, cc []text.Char) ([]content.Operation, bool) {
+	redacted := make([]bool, len(cc))
+	var found bool
+	for i, c := range cc {
+		if rd.glyphRedacted(c.BBox) {
+			redacted[i], found = true, true
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	var elems types.Array
+	if op.Operator == "TJ" {
+		elems, _ = op.Operand(0).(types.Array)
+	} else {
+		elems = types.Array{op.Operand(len(op.Operands) - 1)}
+	}
+
+	// Replace removed characters by equivalent displacements.
+	a := types.Array{}
+	var bb []byte
+
+	flush := func() {
+		if len(bb) > 0 {
+			a = append(a, types.HexLiteral(hex.EncodeToString(bb)))
+			bb = nil
+		}
+	}
+
+	adjust := func(f float64) {
+		flush()
+		if n := len(a); n > 0 {
+			if f0, ok := a[n-1].(types.Float); ok {
+				a[n-1] = types.Float(roundCoord(f0.Value() + f))
+				return
+			}
+		}
+		a = append(a, types.Float(roundCoord(f)))
+	}
+
+	k := 0
+	for i, o := range elems {
+		if f, ok := number(o); ok {
+			adjust(f)
+			continue
+		}
+		for ; k < len(cc) && cc[k].Elem == i; k++ {
+			if redacted[k] {
+				adjust(cc[k].Adjust)
+				continue
+			}
+			bb = append(bb, cc[k].Code...)
+		}
+	}
+	flush()
+
+	var ops []content.Operation
+
+	switch op.Operator {
+	case "'":
+		ops = append(ops, content.Operation{Operator: "T*"})
+	case "\"":
+		ops = append(ops,
+			content.Operation{Operator: "Tw", Operands: []types.Object{op.Operand(0)}},
+			content.Operation{Operator: "Tc", Operands: []types.Object{op.Operand(1)}},
+			content.Operation{Operator: "T*"})
+	}
+
+	return append(ops, content.Operation{Operator: "TJ", Operands: []types.Object{a}}), true
+}
+
+// clearBits sets n bits of bb starting at bit offset from to 0.
+func clearBits(bb []byte, from, n int) {
+	i, to := from, from+n
+	for ; i < to && i%8 != 0; i++ {
+		bb[i/8] &^= 0x80 >> (i % 8)
+	}
+	for ; i+8 <= to; i += 8 {
+		bb[i/8] = 0
+	}
+	for ; i < to; i++ {
+		bb[i/8] &^= 0x80 >> (i % 8)
+	}
+}
+
+// imageSamples returns the decoded samples of image sd along with bits per component and color components.
+// DCT encoded images are converted to DeviceGray or DeviceRGB.
+// ok is false for images that cannot be redacted on sample level.
+func (rd *redactor) imageSamples(sd *types.StreamDict) (bb []byte, bpc, comps int, cs types.Name, ok bool, err error) {
+	for _, f := range sd.FilterPipeline {
+		switch f.Name {
+		case filter.JPX, filter.CCITTFax:
+			return nil, 0, 0, "", false, nil
+		}
+	}
+	return imageSamples(rd.xRefTable, sd)
+}
+
+// imageSamples returns the decoded samples of image sd along with bits per component and color components.
+// DCT encoded images are converted to DeviceGray or DeviceRGB.
+// ok is false for images whose samples are not accessible.
+func imageSamples(xRefTable *model.XRefTable, sd *types.StreamDict) (bb []byte, bpc, comps int, cs types.Name, ok bool, err error) {
+	if mask := sd.BooleanEntry("ImageMask"); mask != nil && *mask {
+		bpc, comps = 1, 1
+	} else {
+		if i := sd.IntEntry("BitsPerComponent"); i != nil {
+			bpc = *i
+		}
+		if comps, err = ColorSpaceComponents(xRefTable, sd); err != nil {
+			return nil, 0, 0, "", false, err
+		}
+	}
+
+	if len(sd.FilterPipeline) == 1 && sd.FilterPipeline[0].Name == filter.DCT {
+		img, err := jpeg.Decode(bytes.NewReader(sd.Raw))
+		if err != nil {
+			return nil, 0, 0, "", false, nil
+		}
+		b := img.Bounds()
+		switch img := img.(type) {
+		case *image.CMYK:
+			return nil, 0, 0, "", false, nil
+		case *image.Gray:
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				i := img.PixOffset(b.Min.X, y)
+				bb = append(bb, img.Pix[i:i+b.Dx()]...)
+			}
+			return bb, 8, 1, model.DeviceGrayCS, true, nil
+		}
+		bb = make([]byte, 0, b.Dx()*b.Dy()*3)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				bb = append(bb, byte(r>>8), byte(g>>8), byte(b>>8))
+			}
+		}
+		return bb, 8, 3, model.DeviceRGBCS, true, nil
+	}
+
+	for _, f := range sd.FilterPipeline {
+		if f.Name == filter.DCT {
+			return nil, 0, 0, "", false, nil
+		}
+	}
+
+	sd1 := sd.Clone().(types.StreamDict)
+	if err := sd1.Decode(); err != nil {
+		return nil, 0, 0, "", false, nil
+	}
+
+	return sd1.Content, bpc, comps, "", true, nil
+}
+
+// redactImage returns a copy of image sd drawn using ctm with all samples within redaction areas set to 0.
+// The returned indirect reference is nil for images which need to be removed as a whole.
+func (rd *redactor) redactImage(sd *types.StreamDict, ctm matrix.Matrix) (*types.IndirectRef, error) {
+	w, h := sd.IntEntry("Width"), sd.IntEntry("Height")
+	if w == nil || h == nil || *w <= 0 || *h <= 0 {
+		return nil, nil
+	}
+
+	inv, ok := invert(ctm)
+	if !ok {
+		return nil, nil
+	}
+
+	bb, bpc, comps, cs, ok, err := rd.imageSamples(sd)
+	if err != nil || !ok || bpc <= 0 || comps <= 0 {
+		return nil, err
+	}
+
+	stride := (*w*comps*bpc + 7) / 8
+	if len(bb) < stride**h {
+		return nil, nil
+	}
+
+	clamp := func(f float64, max int) int {
+		return int(math.Max(0, math.Min(float64(max), f)))
+	}
+
+	for _, a := range rd.rects {
+		// The redaction area in image space, the unit square.
+		u := transformRect(inv, a.LL.X, a.LL.Y, a.UR.X, a.UR.Y)
+		x0, x1 := clamp(math.Floor(u.LL.X*float64(*w)), *w), clamp(math.Ceil(u.UR.X*float64(*w)), *w)
+		y0, y1 := clamp(math.Floor((1-u.UR.Y)*float64(*h)), *h), clamp(math.Ceil((1-u.LL.Y)*float64(*h)), *h)
+		for y := y0; y < y1; y++ {
+			clearBits(bb[y*stride:(y+1)*stride], x0*comps*bpc, (x1-x0)*comps*bpc)
+		}
+	}
+
+	sd1 := sd.Clone().(types.StreamDict)
+	sd1.Content = bb
+	sd1.FilterPipeline = []types.PDFFilter{{Name: filter.Flate}}
+	sd1.Update("Filter", types.Name(filter.Flate))
+	sd1.Delete("DecodeParms")
+	if cs != "" {
+		sd1.Update("BitsPerComponent", types.Integer(8))
+		if n, err := ColorSpaceComponents(rd.xRefTable, sd); err != nil || n != comps {
+			sd1.Update("ColorSpace", cs)
+			sd1.Delete("Decode")
+		}
+	}
+
+	// Redact soft masks and stencil masks the same way.
+	for _, k := range []string{"SMask", "Mask"} {
+		indRef := sd.IndirectRefEntry(k)
+		if indRef == nil {
+			continue
+		}
+		sdMask, _, err := rd.xRefTable.DereferenceStreamDict(*indRef)
+		if err != nil || sdMask == nil {
+			sd1.Delete(k)
+			continue
+		}
+		ir, err := rd.redactImage(sdMask, ctm)
+		if err != nil {
+			return nil, err
+		}
+		if ir == nil {
+			sd1.Delete(k)
+			continue
+		}
+		sd1.Update(k, *ir)
+	}
+
+	if err := sd1.Encode(); err != nil {
+		return nil, err
+	}
+
+	return rd.xRefTable.IndRefForNewObject(sd1)
+}
+
+// redactForm returns a redacted copy of form sd drawn using ctm or nil if nothing changed.
+func (rd *redactor) redactForm(sd *types.StreamDict, rs *resources, ctm matrix.Matrix, depth int) (*types.IndirectRef, error) {
+	ctm = matrixForArray(rd.xRefTable, sd.Dict["Matrix"]).Multiply(ctm)
+
+	if a, err := rd.xRefTable.DereferenceArray(sd.Dict["BBox"]); err == nil && len(a) == 4 {
+		var f [4]float64
+		for i, o := range a {
+			f[i], _ = rd.xRefTable.DereferenceNumber(o)
+		}
+		if !rd.intersects(transformRect(ctm, f[0], f[1], f[2], f[3])) {
+			return nil, nil
+		}
+	}
+
+	if err := sd.Decode(); err != nil {
+		return nil, err
+	}
+
+	ops, err := content.Parse(sd.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	res := rs.d
+	if o, found := sd.Find("Resources"); found {
+		if res, err = rd.xRefTable.DereferenceDict(o); err != nil {
+			return nil, err
+		}
+	}
+
+	ops, rs1, changed, err := rd.redactOps(ops, res, ctm, depth+1)
+	if err != nil || !changed {
+		return nil, err
+	}
+
+	sd1 := sd.Clone().(types.StreamDict)
+	sd1.Content = content.Bytes(ops)
+	sd1.FilterPipeline = []types.PDFFilter{{Name: filter.Flate}}
+	sd1.Update("Filter", types.Name(filter.Flate))
+	sd1.Delete("DecodeParms")
+	if rs1.copied {
+		sd1.Update("Resources", rs1.d)
+	}
+
+	if err := sd1.Encode(); err != nil {
+		return nil, err
+	}
+
+	return rd.xRefTable.IndRefForNewObject(sd1)
+}
+
+// redactXObject returns the replacement for the XObject operation op along with true if anything changed.
+// A nil operation signals an XObject to be removed.
+func (rd *redactor) redactXObject(op content.Operation, rs *resources, ctm matrix.Matrix, depth int) (*content.Operation, bool, error) {
+	name, ok := op.Operand(0).(types.Name)
+	if !ok {
+		return &op, false, nil
+	}
+
+	indRef, err := rs.xObject(rd.xRefTable, name.Value())
+	if err != nil || indRef == nil {
+		return &op, false, err
+	}
+
+	sd, _, err := rd.xRefTable.DereferenceStreamDict(*indRef)
+	if err != nil || sd == nil {
+		return &op, false, err
+	}
+
+	var ir *types.IndirectRef
+
+	switch st := sd.Subtype(); {
+
+	case st != nil && *st == "Image":
+		if !rd.intersects(transformRect(ctm, 0, 0, 1, 1)) {
+			return &op, false, nil
+		}
+		if ir, err = rd.redactImage(sd, ctm); err != nil || ir == nil {
+			return nil, true, err
+		}
+
+	case st != nil && *st == "Form" && depth < maxRedactionDepth:
+		if ir, err = rd.redactForm(sd, rs, ctm, depth); err != nil || ir == nil {
+			return &op, false, err
+		}
+
+	default:
+		return &op, false, nil
+	}
+
+	s, err := rs.addXObject(rd.xRefTable, *ir, name.Value())
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &content.Operation{Operator: "Do", Operands: []types.Object{types.Name(s)}}, true, nil
+}
+
+// gState is the part of the graphics state relevant for redaction.
+type gState struct {
+	ctm       matrix.Matrix
+	lineWidth float64
+}
+
+// withoutAlternates removes alternate descriptions which might reveal redacted content from a marked content operation.
+func withoutAlternates(op content.Operation) content.Operation {
+	d, ok := op.Operand(1).(types.Dict)
+	if op.Operator != "BDC" || !ok {
+		return op
+	}
+	d = d.Clone().(types.Dict)
+	for _, k := range []string{"ActualText", "Alt", "E"} {
+		delete(d, k)
+	}
+	op.Operands = []types.Object{op.Operands[0], d}
+	return op
+}
+
+// redactOps removes all content of ops intersecting redaction areas.
+// It returns the resulting operations, the resources in effect and true if anything changed.
+func (rd *redactor) redactOps(ops []content.Operation, res types.Dict, ctm matrix.Matrix, depth int) ([]content.Operation, *resources, bool, error) {
+	chars, err := text.Chars(rd.xRefTable, ops, res, ctm)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	var (
+		out      []content.Operation
+		changed  bool
+		p        path
+		stack    []gState
+		mc       []int               // open marked content sequences as indices into out
+		touched  = map[int]bool{}    // marked content sequences with redacted content
+		replaced = map[string]bool{} // XObjects replaced by redacted copies or removed
+	)
+
+	rs := &resources{d: res}
+	gs := gState{ctm: ctm, lineWidth: 1}
+
+	redacted := func() {
+		changed = true
+		for _, i := range mc {
+			touched[i] = true
+		}
+	}
+
+	for i, op := range ops {
+
+		switch op.Operator {
+
+		case "q":
+			stack = append(stack, gs)
+
+		case "Q":
+			if n := len(stack); n > 0 {
+				gs, stack = stack[n-1], stack[:n-1]
+			}
+
+		case "cm":
+			if m, ok := matrixForOperands(op.Operands); ok {
+				gs.ctm = m.Multiply(gs.ctm)
+			}
+
+		case "w":
+			if f, ok := number(op.Operand(0)); ok {
+				gs.lineWidth = f
+			}
+
+		case "BMC", "BDC":
+			mc = append(mc, len(out))
+
+		case "EMC":
+			if n := len(mc); n > 0 {
+				mc = mc[:n-1]
+			}
+
+		case "m", "l", "c", "v", "y", "h", "re", "W", "W*":
+			p.add(op, gs.ctm)
+			continue
+
+		case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*", "n":
+			ops1, ok := rd.redactPath(&p, op, gs)
+			if ok {
+				redacted()
+			}
+			out = append(out, ops1...)
+			p = path{}
+			continue
+
+		case "Tj", "TJ", "'", "\"":
+			if ops1, ok := rd.redactText(op, chars[i]); ok {
+				redacted()
+				out = append(out, ops1...)
+				continue
+			}
+
+		case "BI":
+			if rd.intersects(transformRect(gs.ctm, 0, 0, 1, 1)) {
+				redacted()
+				continue
+			}
+
+		case "Do":
+			op1, ok, err := rd.redactXObject(op, rs, gs.ctm, depth)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			if ok {
+				redacted()
+				if n, ok := op.Operand(0).(types.Name); ok {
+					replaced[n.Value()] = true
+				}
+				if op1 != nil {
+					out = append(out, *op1)
+				}
+				continue
+			}
+		}
+
+		out = append(out, op)
+	}
+
+	// Retain an unpainted trailing path.
+	out = append(out, p.ops...)
+
+	for i := range touched {
+		out[i] = withoutAlternates(out[i])
+	}
+
+	// Drop references to unredacted XObjects no longer in use.
+	for _, op := range out {
+		if n, ok := op.Operand(0).(types.Name); ok && op.Operator == "Do" {
+			delete(replaced, n.Value())
+		}
+	}
+	for name := range replaced {
+		if err := rs.removeXObject(rd.xRefTable, name); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	return out, rs, changed, nil
+}
+
+// annotationRemoved returns true for popups and replies of removed annotations.
+func annotationRemoved(ad types.Dict, removed types.IntSet) bool {
+	for _, k := range []string{"Parent", "IRT"} {
+		if ir, ok := ad[k].(types.IndirectRef); ok && removed[ir.ObjectNumber.Value()] {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAnnotations removes all annotations but redact annotations intersecting the redaction areas of page dict d
+// including popups and replies since their appearances and contents are not subject to redaction.
+// The object numbers of removed annotations are added to removed.
+func (rd *redactor) redactAnnotations(ctx *model.Context, pageNr int, d types.Dict, removed types.IntSet) error {
+	o, found := d.Find("Annots")
+	if !found {
+		return nil
+	}
+
+	annots, err := ctx.DereferenceArray(o)
+	if err != nil || len(annots) == 0 {
+		return err
+	}
+
+	dd := make([]types.Dict, len(annots))
+	drop := make([]bool, len(annots))
+	dropped := false
+
+	for i, o1 := range annots {
+		ad, err := ctx.DereferenceDict(o1)
+		if err != nil {
+			return err
+		}
+		if ad == nil {
+			continue
+		}
+		st := ad.Subtype()
+		if st != nil && *st == "Redact" {
+			// Redact annotations get removed once applied.
+			continue
+		}
+		dd[i] = ad
+		if st != nil && *st == "Popup" {
+			// Popups show the contents of their parent.
+			continue
+		}
+		a, err := ctx.DereferenceArray(ad["Rect"])
+		if err != nil {
+			return err
+		}
+		if len(a) != 4 {
+			continue
+		}
+		r, err := ctx.RectForArray(a)
+		if err != nil {
+			return err
+		}
+		if !rd.intersects(transformRect(matrix.IdentMatrix, r.LL.X, r.LL.Y, r.UR.X, r.UR.Y)) {
+			continue
+		}
+		drop[i], dropped = true, true
+		if ir, ok := o1.(types.IndirectRef); ok {
+			removed[ir.ObjectNumber.Value()] = true
+		}
+	}
+
+	if !dropped {
+		return nil
+	}
+
+	// Popups and replies may refer to each other.
+	for more := true; more; {
+		more = false
+		for i, ad := range dd {
+			if drop[i] || ad == nil || !annotationRemoved(ad, removed) {
+				continue
+			}
+			drop[i], more = true, true
+			if ir, ok := annots[i].(types.IndirectRef); ok {
+				removed[ir.ObjectNumber.Value()] = true
+			}
+		}
+	}
+
+	var kept types.Array
+	for i, o1 := range annots {
+		if !drop[i] {
+			kept = append(kept, o1)
+			continue
+		}
+		if ir, ok := o1.(types.IndirectRef); ok {
+			// Annotations not known to the annotation cache need no update.
+			_ = removeAnnotationFromCache(ctx, pageNr, ir.ObjectNumber.Value())
+		}
+	}
+
+	if len(kept) == 0 {
+		d.Delete("Annots")
+		return nil
+	}
+
+	if ir, ok := o.(types.IndirectRef); ok {
+		entry, found := ctx.FindTableEntryForIndRef(&ir)
+		if found {
+			entry.Object = kept
+			return nil
+		}
+	}
+	d.Update("Annots", kept)
+
+	return nil
+}
+
+// removeFields removes the fields for removed widget annotations from fields
+// together with non terminal fields left without kids.
+func removeFields(ctx *model.Context, fields types.Array, removed types.IntSet) (types.Array, bool, error) {
+	var (
+		kept    types.Array
+		changed bool
+	)
+
+	for _, o := range fields {
+		if ir, ok := o.(types.IndirectRef); ok && removed[ir.ObjectNumber.Value()] {
+			changed = true
+			continue
+		}
+		d, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return nil, false, err
+		}
+		if d == nil || d["Kids"] == nil {
+			kept = append(kept, o)
+			continue
+		}
+		kids, err := ctx.DereferenceArray(d["Kids"])
+		if err != nil {
+			return nil, false, err
+		}
+		kids, ok, err := removeFields(ctx, kids, removed)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			kept = append(kept, o)
+			continue
+		}
+		changed = true
+		if len(kids) == 0 {
+			continue
+		}
+		d.Update("Kids", kids)
+		kept = append(kept, o)
+	}
+
+	return kept, changed, nil
+}
+
+// redactFormFields removes the form fields of removed widget annotations.
+func redactFormFields(ctx *model.Context, removed types.IntSet) error {
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+
+	acroForm, err := ctx.DereferenceDict(rootDict["AcroForm"])
+	if err != nil || acroForm == nil {
+		return err
+	}
+
+	fields, err := ctx.DereferenceArray(acroForm["Fields"])
+	if err != nil {
+		return err
+	}
+
+	fields, changed, err := removeFields(ctx, fields, removed)
+	if err != nil || !changed {
+		return err
+	}
+
+	acroForm.Update("Fields", fields)
+
+	// XFA might repeat the values of removed fields.
+	acroForm.Delete("XFA")
+
+	return nil
+}
+
+func redactPage(ctx *model.Context, pageNr int, areas []RedactionArea, removed types.IntSet) error {
+	d, _, inhPAttrs, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		return errors.Errorf("pdfcpu: redact: unknown page %d", pageNr)
+	}
+
+	bb, err := ctx.PageContent(d)
+	if err != nil && err != model.ErrNoContent {
+		return err
+	}
+
+	ops, err := content.Parse(bb)
+	if err != nil {
+		return errors.Wrapf(err, "pdfcpu: redact: page %d", pageNr)
+	}
+
+	rd := &redactor{xRefTable: ctx.XRefTable}
+	for _, a := range areas {
+		rd.rects = append(rd.rects, a.Rect)
+	}
+
+	ops, rs, _, err := rd.redactOps(ops, inhPAttrs.Resources, matrix.IdentMatrix, 0)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("q\n")
+	buf.Write(content.Bytes(ops))
+	buf.WriteString("Q\n")
+
+	for _, a := range areas {
+		if a.Col == nil {
+			continue
+		}
+		r := a.Rect
+		fmt.Fprintf(&buf, "q %.2f %.2f %.2f rg %.2f %.2f %.2f %.2f re f Q\n",
+			a.Col.R, a.Col.G, a.Col.B, r.LL.X, r.LL.Y, r.Width(), r.Height())
+	}
+
+	indRef, err := ctx.StreamDictIndRef(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	d.Update("Contents", *indRef)
+
+	if rs.copied {
+		d.Update("Resources", rs.d)
+	}
+
+	// The thumbnail image might reveal redacted content.
+	d.Delete("Thumb")
+
+	return rd.redactAnnotations(ctx, pageNr, d, removed)
+}
+
+// RedactAnnotationAreas returns the areas marked by the redact annotations of page pageNr.
+func RedactAnnotationAreas(ctx *model.Context, pageNr int) ([]RedactionArea, error) {
+	d, _, _, err := ctx.PageDict(pageNr, false)
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	annots, err := ctx.DereferenceArray(d["Annots"])
+	if err != nil || annots == nil {
+		return nil, err
+	}
+
+	numbers := func(o types.Object) ([]float64, error) {
+		a, err := ctx.DereferenceArray(o)
+		if err != nil || a == nil {
+			return nil, err
+		}
+		ff := make([]float64, len(a))
+		for i, o := range a {
+			if ff[i], err = ctx.DereferenceNumber(o); err != nil {
+				return nil, err
+			}
+		}
+		return ff, nil
+	}
+
+	var areas []RedactionArea
+
+	for _, o := range annots {
+		ad, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+		if ad == nil {
+			continue
+		}
+		if st := ad.Subtype(); st == nil || *st != "Redact" {
+			continue
+		}
+
+		var col *color.SimpleColor
+		ic, err := numbers(ad["IC"])
+		if err != nil {
+			return nil, err
+		}
+		if len(ic) == 3 {
+			col = &color.SimpleColor{R: float32(ic[0]), G: float32(ic[1]), B: float32(ic[2])}
+		}
+
+		qp, err := numbers(ad["QuadPoints"])
+		if err != nil {
+			return nil, err
+		}
+		if len(qp) == 0 {
+			if qp, err = numbers(ad["Rect"]); err != nil {
+				return nil, err
+			}
+			if len(qp) != 4 {
+				continue
+			}
+			qp = []float64{qp[0], qp[1], qp[2], qp[1], qp[0], qp[3], qp[2], qp[3]}
+		}
+
+		for i := 0; i+8 <= len(qp); i += 8 {
+			r := types.NewRectangle(qp[i], qp[i+1], qp[i], qp[i+1])
+			for j := i + 2; j < i+8; j += 2 {
+				r.LL.X, r.LL.Y = math.Min(r.LL.X, qp[j]), math.Min(r.LL.Y, qp[j+1])
+				r.UR.X, r.UR.Y = math.Max(r.UR.X, qp[j]), math.Max(r.UR.Y, qp[j+1])
+			}
+			areas = append(areas, RedactionArea{Rect: *r, Col: col})
+		}
+	}
+
+	return areas, nil
+}
+
+// Redact removes text, image samples and paths intersecting redaction areas from selected pages
+// including form XObjects used by them and paints the overlay boxes.
+// Redaction areas are taken from m keyed by page number and from the redact annotations of selected pages
+// which are removed afterwards.
+// Any other annotations intersecting redaction areas are removed including popups, replies
+// and the form fields of widget annotations.
+func Redact(ctx *model.Context, selectedPages types.IntSet, m map[int][]RedactionArea) error {
+	var pageNrs []int
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		if selectedPages == nil || selectedPages[pageNr] {
+			pageNrs = append(pageNrs, pageNr)
+		}
+	}
+
+	pages, removed := types.IntSet{}, types.IntSet{}
+
+	for _, pageNr := range pageNrs {
+		areas, err := RedactAnnotationAreas(ctx, pageNr)
+		if err != nil {
+			return err
+		}
+		if len(areas) > 0 {
+			pages[pageNr] = true
+		}
+		areas = append(m[pageNr], areas...)
+		if len(areas) == 0 {
+			continue
+		}
+		if err := redactPage(ctx, pageNr, areas, removed); err != nil {
+			return err
+		}
+	}
+
+	if len(removed) > 0 {
+		if err := redactFormFields(ctx, removed); err != nil {
+			return err
+		}
+	}
+
+	if len(pages) > 0 {
+		if _, err := RemoveAnnotations(ctx, pages, []string{"Redact"}, nil, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,917 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/text"
+	"github.com/pkg/errors"
+)
+
+const (
+	maxCharStringDepth = 10
+	maxCharStringStack = 48
+	maxCharStringOps   = 1 << 16
+)
+
+var errCorruptCFF = errors.New("pdfcpu: corrupt CFF font")
+
+// cff is a parsed Compact Font Format font program (see Adobe Technical Note #5176).
+type cff struct {
+	charStrings [][]byte
+	gsubrs      [][]byte
+	subrs       [][]byte   // local subroutines of non CID-keyed fonts
+	fdSubrs     [][][]byte // local subroutines per font dict of CID-keyed fonts
+	fdSelect    []byte     // font dict index per glyph
+	matrix      matrix.Matrix
+	cidKeyed    bool
+	charset     []int          // gid -> SID or CID
+	names       map[string]int // glyph name -> gid
+	encoding    [256]int       // code -> gid, -1 if undefined
+	cids        map[int]int    // CID -> gid
+}
+
+func cffIndex(bb []byte, off int) ([][]byte, int, error) {
+	count := u16(bb, off)
+	if count == 0 {
+		return nil, off + 2, nil
+	}
+	if off+3 > len(bb) {
+		return nil, 0, errCorruptCFF
+	}
+	offSize := int(bb[off+2])
+	if offSize < 1 || offSize > 4 {
+		return nil, 0, errCorruptCFF
+	}
+
+	offset := func(i int) int {
+		v := 0
+		p := off + 3 + i*offSize
+		for j := 0; j < offSize; j++ {
+			if p+j >= len(bb) {
+				return -1
+			}
+			v = v<<8 | int(bb[p+j])
+		}
+		return v
+	}
+
+	data := off + 3 + (count+1)*offSize - 1
+	items := make([][]byte, count)
+	for i := range items {
+		o1, o2 := offset(i), offset(i+1)
+		if o1 < 1 || o2 < o1 || data+o2 > len(bb) {
+			return nil, 0, errCorruptCFF
+		}
+		items[i] = bb[data+o1 : data+o2]
+	}
+
+	return items, data + offset(count), nil
+}
+
+// cffDict parses a Top or Private DICT into operands keyed by operator, escaped operators are 1200+op.
+func cffDict(bb []byte) map[int][]float64 {
+	d := map[int][]float64{}
+	var operands []float64
+	for i := 0; i < len(bb); {
+		b := int(bb[i])
+		switch {
+		case b <= 21:
+			op := b
+			i++
+			if b == 12 && i < len(bb) {
+				op = 1200 + int(bb[i])
+				i++
+			}
+			d[op] = operands
+			operands = nil
+		case b == 28:
+			operands = append(operands, float64(i16(bb, i+1)))
+			i += 3
+		case b == 29:
+			operands = append(operands, float64(int32(u32(bb, i+1))))
+			i += 5
+		case b == 30:
+			f, n := cffReal(bb[i+1:])
+			operands = append(operands, f)
+			i += 1 + n
+		case b >= 32 && b <= 246:
+			operands = append(operands, float64(b-139))
+			i++
+		case b >= 247 && b <= 250 && i+1 < len(bb):
+			operands = append(operands, float64((b-247)*256+int(bb[i+1])+108))
+			i += 2
+		case b >= 251 && b <= 254 && i+1 < len(bb):
+			operands = append(operands, float64(-(b-251)*256-int(bb[i+1])-108))
+			i += 2
+		default:
+			i++
+		}
+	}
+	return d
+}
+
+func cffReal(bb []byte) (float64, int) {
+	var s []byte
+	for i, b := range bb {
+		for _, nib := range []byte{b >> 4, b & 0x0F} {
+			switch {
+			case nib <= 9:
+				s = append(s, '0'+nib)
+			case nib == 0xA:
+				s = append(s, '.')
+			case nib == 0xB:
+				s = append(s, 'E')
+			case nib == 0xC:
+				s = append(s, 'E', '-')
+			case nib == 0xE:
+				s = append(s, '-')
+			case nib == 0xF:
+				f, err := strconv.ParseFloat(string(s), 64)
+				if err != nil {
+					return 0, i + 1
+				}
+				return f, i + 1
+			}
+		}
+	}
+	return 0, len(bb)
+}
+
+func dictInt(d map[int][]float64, op int, def int) int {
+	if v, ok := d[op]; ok && len(v) > 0 {
+		return int(v[len(v)-1])
+	}
+	return def
+}
+
+func localSubrs(bb []byte, private []float64) [][]byte {
+	if len(private) < 2 {
+		return nil
+	}
+	size, off := int(private[0]), int(private[1])
+	if off < 0 || size < 0 || off+size > len(bb) {
+		return nil
+	}
+	pd := cffDict(bb[off : off+size])
+	subrsOff := dictInt(pd, 19, 0)
+	if subrsOff == 0 {
+		return nil
+	}
+	subrs, _, err := cffIndex(bb, off+subrsOff)
+	if err != nil {
+		return nil
+	}
+	return subrs
+}
+
+// parseCFF parses the first font of a CFF font set.
+func parseCFF(bb []byte) (*cff, error) {
+	if len(bb) < 4 {
+		return nil, errCorruptCFF
+	}
+
+	_, off, err := cffIndex(bb, int(bb[2])) // Name INDEX
+	if err != nil {
+		return nil, err
+	}
+	topDicts, off, err := cffIndex(bb, off)
+	if err != nil || len(topDicts) == 0 {
+		return nil, errCorruptCFF
+	}
+	strs, off, err := cffIndex(bb, off)
+	if err != nil {
+		return nil, err
+	}
+	gsubrs, _, err := cffIndex(bb, off)
+	if err != nil {
+		return nil, err
+	}
+
+	top := cffDict(topDicts[0])
+
+	f := &cff{gsubrs: gsubrs, matrix: matrix.Matrix{{0.001, 0, 0}, {0, 0.001, 0}, {0, 0, 1}}}
+
+	if fm, ok := top[1207]; ok && len(fm) == 6 {
+		f.matrix = matrix.Matrix{{fm[0], fm[1], 0}, {fm[2], fm[3], 0}, {fm[4], fm[5], 1}}
+	}
+
+	csOff := dictInt(top, 17, 0)
+	if csOff <= 0 {
+		return nil, errCorruptCFF
+	}
+	if f.charStrings, _, err = cffIndex(bb, csOff); err != nil {
+		return nil, err
+	}
+
+	_, f.cidKeyed = top[1230]
+
+	if f.cidKeyed {
+		if err := f.parseFDs(bb, top); err != nil {
+			return nil, err
+		}
+	} else {
+		f.subrs = localSubrs(bb, top[18])
+	}
+
+	f.parseCharset(bb, dictInt(top, 15, 0), strs)
+	f.parseEncoding(bb, dictInt(top, 16, 0))
+
+	return f, nil
+}
+
+func (f *cff) parseFDs(bb []byte, top map[int][]float64) error {
+	fdArray, _, err := cffIndex(bb, dictInt(top, 1236, 0))
+	if err != nil {
+		return err
+	}
+	for _, fd := range fdArray {
+		f.fdSubrs = append(f.fdSubrs, localSubrs(bb, cffDict(fd)[18]))
+	}
+
+	off := dictInt(top, 1237, 0)
+	if off <= 0 || off >= len(bb) {
+		return nil
+	}
+
+	n := len(f.charStrings)
+	f.fdSelect = make([]byte, n)
+
+	switch bb[off] {
+	case 0:
+		for i := 0; i < n && off+1+i < len(bb); i++ {
+			f.fdSelect[i] = bb[off+1+i]
+		}
+	case 3:
+		ranges := u16(bb, off+1)
+		for i := 0; i < ranges; i++ {
+			r := off + 3 + 3*i
+			if r+5 > len(bb) {
+				break
+			}
+			first, fd, next := u16(bb, r), bb[r+2], u16(bb, r+3)
+			for gid := first; gid < next && gid < n; gid++ {
+				f.fdSelect[gid] = fd
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *cff) sidName(sid int, strs [][]byte) string {
+	if sid < len(cffStandardStrings) {
+		return cffStandardStrings[sid]
+	}
+	if i := sid - len(cffStandardStrings); i < len(strs) {
+		return string(strs[i])
+	}
+	return ""
+}
+
+func (f *cff) parseCharset(bb []byte, off int, strs [][]byte) {
+	n := len(f.charStrings)
+	f.charset = make([]int, n)
+
+	switch {
+	case off == 0:
+		// ISOAdobe
+		for gid := range f.charset {
+			f.charset[gid] = gid
+		}
+	case off > 2 && off < len(bb):
+		gid := 1
+		format := bb[off]
+		p := off + 1
+		for gid < n && p < len(bb) {
+			switch format {
+			case 0:
+				f.charset[gid] = u16(bb, p)
+				gid++
+				p += 2
+			case 1, 2:
+				first := u16(bb, p)
+				var nLeft int
+				if format == 1 {
+					if p+2 >= len(bb) {
+						p = len(bb)
+						continue
+					}
+					nLeft = int(bb[p+2])
+					p += 3
+				} else {
+					nLeft = u16(bb, p+2)
+					p += 4
+				}
+				for i := 0; i <= nLeft && gid < n; i++ {
+					f.charset[gid] = first + i
+					gid++
+				}
+			default:
+				p = len(bb)
+			}
+		}
+	}
+
+	if f.cidKeyed {
+		f.cids = map[int]int{}
+		for gid, cid := range f.charset {
+			if _, ok := f.cids[cid]; !ok {
+				f.cids[cid] = gid
+			}
+		}
+		return
+	}
+
+	f.names = map[string]int{}
+	for gid, sid := range f.charset {
+		name := f.sidName(sid, strs)
+		if _, ok := f.names[name]; !ok && name != "" {
+			f.names[name] = gid
+		}
+	}
+}
+
+func (f *cff) parseEncoding(bb []byte, off int) {
+	for i := range f.encoding {
+		f.encoding[i] = -1
+	}
+	if f.cidKeyed {
+		return
+	}
+
+	if off <= 1 || off >= len(bb) {
+		// Standard or Expert encoding
+		for code, name := range text.EncodingNames("StandardEncoding") {
+			if gid, ok := f.names[name]; ok && name != "" {
+				f.encoding[code] = gid
+			}
+		}
+		return
+	}
+
+	format := bb[off]
+	p := off + 1
+	switch format & 0x7F {
+	case 0:
+		if p >= len(bb) {
+			return
+		}
+		n := int(bb[p])
+		for gid := 1; gid <= n && p+gid < len(bb); gid++ {
+			f.encoding[bb[p+gid]] = gid
+		}
+		p += 1 + n
+	case 1:
+		if p >= len(bb) {
+			return
+		}
+		ranges := int(bb[p])
+		gid := 1
+		for i := 0; i < ranges && p+2+2*i < len(bb); i++ {
+			first, nLeft := int(bb[p+1+2*i]), int(bb[p+2+2*i])
+			for c := first; c <= first+nLeft && c < 256; c++ {
+				f.encoding[c] = gid
+				gid++
+			}
+		}
+		p += 1 + 2*ranges
+	}
+
+	if format&0x80 != 0 && p < len(bb) {
+		// Supplements
+		n := int(bb[p])
+		for i := 0; i < n; i++ {
+			s := p + 1 + 3*i
+			if s+3 > len(bb) {
+				break
+			}
+			code, sid := bb[s], u16(bb, s+1)
+			for gid, sid1 := range f.charset {
+				if sid1 == sid {
+					f.encoding[code] = gid
+					break
+				}
+			}
+		}
+	}
+}
+
+func (f *cff) fontMatrix() matrix.Matrix {
+	return f.matrix
+}
+
+func (f *cff) gidForName(name string) (int, bool) {
+	gid, ok := f.names[name]
+	return gid, ok
+}
+
+func (f *cff) gidForCode(code byte) (int, bool) {
+	if gid := f.encoding[code]; gid >= 0 {
+		return gid, true
+	}
+	return 0, false
+}
+
+func (f *cff) gidForRune(r rune) (int, bool) {
+	return 0, false
+}
+
+func (f *cff) gidForCID(cid int) (int, bool) {
+	if !f.cidKeyed {
+		return cid, cid >= 0 && cid < len(f.charStrings)
+	}
+	gid, ok := f.cids[cid]
+	return gid, ok
+}
+
+func (f *cff) advance(gid int) float64 {
+	return 0
+}
+
+func (f *cff) outline(gid int) *path {
+	p := &path{}
+	if gid < 0 || gid >= len(f.charStrings) {
+		return p
+	}
+	subrs := f.subrs
+	if f.cidKeyed && gid < len(f.fdSelect) && int(f.fdSelect[gid]) < len(f.fdSubrs) {
+		subrs = f.fdSubrs[f.fdSelect[gid]]
+	}
+	cs := &type2Interpreter{f: f, p: p, subrs: subrs}
+	cs.run(f.charStrings[gid], 0)
+	p.close()
+	return p
+}
+
+func subrBias(n int) int {
+	switch {
+	case n < 1240:
+		return 107
+	case n < 33900:
+		return 1131
+	}
+	return 32768
+}
+
+// type2Interpreter executes Type 2 charstrings.
+type type2Interpreter struct {
+	f         *cff
+	p         *path
+	subrs     [][]byte
+	stack     []float64
+	x, y      float64
+	nStems    int
+	haveWidth bool
+	open      bool
+	ops       int
+	transient [32]float64
+	seac      bool
+}
+
+func (cs *type2Interpreter) moveTo(dx, dy float64) {
+	if cs.open {
+		cs.p.close()
+	}
+	cs.x += dx
+	cs.y += dy
+	cs.p.moveTo(point{cs.x, cs.y})
+	cs.open = true
+}
+
+func (cs *type2Interpreter) lineTo(dx, dy float64) {
+	cs.x += dx
+	cs.y += dy
+	cs.p.lineTo(point{cs.x, cs.y})
+}
+
+func (cs *type2Interpreter) curveTo(dx1, dy1, dx2, dy2, dx3, dy3 float64) {
+	x1, y1 := cs.x+dx1, cs.y+dy1
+	x2, y2 := x1+dx2, y1+dy2
+	cs.x, cs.y = x2+dx3, y2+dy3
+	cs.p.curveTo(point{x1, y1}, point{x2, y2}, point{cs.x, cs.y})
+}
+
+// stems consumes hint operands, an odd number of operands includes the width.
+func (cs *type2Interpreter) stems() {
+	if len(cs.stack)%2 != 0 && !cs.haveWidth {
+		cs.stack = cs.stack[1:]
+	}
+	cs.haveWidth = true
+	cs.nStems += len(cs.stack) / 2
+	cs.stack = cs.stack[:0]
+}
+
+// width drops the optional width operand given n expected operands.
+func (cs *type2Interpreter) width(n int) {
+	if !cs.haveWidth && len(cs.stack) > n {
+		cs.stack = cs.stack[1:]
+	}
+	cs.haveWidth = true
+}
+
+func (cs *type2Interpreter) arg(i int) float64 {
+	if i < len(cs.stack) {
+		return cs.stack[i]
+	}
+	return 0
+}
+
+func (cs *type2Interpreter) push(f float64) {
+	if len(cs.stack) < maxCharStringStack {
+		cs.stack = append(cs.stack, f)
+	}
+}
+
+func (cs *type2Interpreter) pop() float64 {
+	n := len(cs.stack)
+	if n == 0 {
+		return 0
+	}
+	f := cs.stack[n-1]
+	cs.stack = cs.stack[:n-1]
+	return f
+}
+
+// run executes the charstring bb and returns true on endchar.
+func (cs *type2Interpreter) run(bb []byte, depth int) bool {
+	if depth > maxCharStringDepth {
+		return true
+	}
+
+	for i := 0; i < len(bb); {
+		cs.ops++
+		if cs.ops > maxCharStringOps {
+			return true
+		}
+
+		b := int(bb[i])
+		i++
+
+		switch {
+		case b == 28:
+			cs.push(float64(i16(bb, i)))
+			i += 2
+			continue
+		case b >= 32 && b <= 246:
+			cs.push(float64(b - 139))
+			continue
+		case b >= 247 && b <= 250:
+			if i < len(bb) {
+				cs.push(float64((b-247)*256 + int(bb[i]) + 108))
+			}
+			i++
+			continue
+		case b >= 251 && b <= 254:
+			if i < len(bb) {
+				cs.push(float64(-(b-251)*256 - int(bb[i]) - 108))
+			}
+			i++
+			continue
+		case b == 255:
+			cs.push(float64(int32(u32(bb, i))) / 65536)
+			i += 4
+			continue
+		}
+
+		switch b {
+
+		case 1, 3, 18, 23: // hstem, vstem, hstemhm, vstemhm
+			cs.stems()
+
+		case 19, 20: // hintmask, cntrmask
+			cs.stems()
+			i += (cs.nStems + 7) / 8
+
+		case 21: // rmoveto
+			cs.width(2)
+			cs.moveTo(cs.arg(0), cs.arg(1))
+			cs.stack = cs.stack[:0]
+
+		case 22: // hmoveto
+			cs.width(1)
+			cs.moveTo(cs.arg(0), 0)
+			cs.stack = cs.stack[:0]
+
+		case 4: // vmoveto
+			cs.width(1)
+			cs.moveTo(0, cs.arg(0))
+			cs.stack = cs.stack[:0]
+
+		case 5: // rlineto
+			for j := 0; j+1 < len(cs.stack); j += 2 {
+				cs.lineTo(cs.stack[j], cs.stack[j+1])
+			}
+			cs.stack = cs.stack[:0]
+
+		case 6, 7: // hlineto, vlineto
+			horizontal := b == 6
+			for _, d := range cs.stack {
+				if horizontal {
+					cs.lineTo(d, 0)
+				} else {
+					cs.lineTo(0, d)
+				}
+				horizontal = !horizontal
+			}
+			cs.stack = cs.stack[:0]
+
+		case 8: // rrcurveto
+			s := cs.stack
+			for j := 0; j+5 < len(s); j += 6 {
+				cs.curveTo(s[j], s[j+1], s[j+2], s[j+3], s[j+4], s[j+5])
+			}
+			cs.stack = cs.stack[:0]
+
+		case 24: // rcurveline
+			s := cs.stack
+			j := 0
+			for ; j+7 < len(s); j += 6 {
+				cs.curveTo(s[j], s[j+1], s[j+2], s[j+3], s[j+4], s[j+5])
+			}
+			if j+1 < len(s) {
+				cs.lineTo(s[j], s[j+1])
+			}
+			cs.stack = cs.stack[:0]
+
+		case 25: // rlinecurve
+			s := cs.stack
+			j := 0
+			for ; j+7 < len(s); j += 2 {
+				cs.lineTo(s[j], s[j+1])
+			}
+			if j+5 < len(s) {
+				cs.curveTo(s[j], s[j+1], s[j+2], s[j+3], s[j+4], s[j+5])
+			}
+			cs.stack = cs.stack[:0]
+
+		case 26: // vvcurveto
+			s := cs.stack
+			dx1 := 0.
+			if len(s)%2 == 1 {
+				dx1, s = s[0], s[1:]
+			}
+			for j := 0; j+3 < len(s); j += 4 {
+				cs.curveTo(dx1, s[j], s[j+1], s[j+2], 0, s[j+3])
+				dx1 = 0
+			}
+			cs.stack = cs.stack[:0]
+
+		case 27: // hhcurveto
+			s := cs.stack
+			dy1 := 0.
+			if len(s)%2 == 1 {
+				dy1, s = s[0], s[1:]
+			}
+			for j := 0; j+3 < len(s); j += 4 {
+				cs.curveTo(s[j], dy1, s[j+1], s[j+2], s[j+3], 0)
+				dy1 = 0
+			}
+			cs.stack = cs.stack[:0]
+
+		case 30, 31: // vhcurveto, hvcurveto
+			cs.alternatingCurves(b == 31)
+			cs.stack = cs.stack[:0]
+
+		case 10, 29: // callsubr, callgsubr
+			subrs := cs.subrs
+			if b == 29 {
+				subrs = cs.f.gsubrs
+			}
+			idx := int(cs.pop()) + subrBias(len(subrs))
+			if idx >= 0 && idx < len(subrs) {
+				if cs.run(subrs[idx], depth+1) {
+					return true
+				}
+			}
+
+		case 11: // return
+			return false
+
+		case 14: // endchar
+			if len(cs.stack) >= 4 && !cs.seac {
+				cs.width(4)
+				cs.accented()
+			}
+			if cs.open {
+				cs.p.close()
+				cs.open = false
+			}
+			return true
+
+		case 12:
+			if i >= len(bb) {
+				return true
+			}
+			b2 := int(bb[i])
+			i++
+			cs.escape(b2)
+		}
+	}
+
+	return false
+}
+
+// accented composes a glyph from two StandardEncoding glyphs (endchar with seac arguments).
+func (cs *type2Interpreter) accented() {
+	adx, ady := cs.arg(0), cs.arg(1)
+	bchar, achar := int(cs.arg(2)), int(cs.arg(3))
+	cs.stack = cs.stack[:0]
+
+	std := text.EncodingNames("StandardEncoding")
+	if bchar < 0 || bchar > 255 || achar < 0 || achar > 255 {
+		return
+	}
+
+	compose := func(name string, dx, dy float64) {
+		gid, ok := cs.f.gidForName(name)
+		if !ok {
+			return
+		}
+		cs1 := &type2Interpreter{f: cs.f, p: &path{}, subrs: cs.subrs, seac: true}
+		cs1.run(cs.f.charStrings[gid], 0)
+		cs1.p.close()
+		cs.p.append(cs1.p, translation(dx, dy))
+	}
+
+	compose(std[bchar], 0, 0)
+	compose(std[achar], adx, ady)
+}
+
+func (cs *type2Interpreter) alternatingCurves(horizontal bool) {
+	s := cs.stack
+	for j := 0; j+3 < len(s); j += 4 {
+		last := j+5 == len(s)
+		var extra float64
+		if last {
+			extra = s[j+4]
+		}
+		if horizontal {
+			cs.curveTo(s[j], 0, s[j+1], s[j+2], extra, s[j+3])
+		} else {
+			cs.curveTo(0, s[j], s[j+1], s[j+2], s[j+3], extra)
+		}
+		horizontal = !horizontal
+	}
+}
+
+func (cs *type2Interpreter) escape(op int) {
+	s := cs.stack
+
+	switch op {
+
+	case 35: // flex
+		if len(s) >= 12 {
+			cs.curveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+			cs.curveTo(s[6], s[7], s[8], s[9], s[10], s[11])
+		}
+		cs.stack = cs.stack[:0]
+
+	case 34: // hflex
+		if len(s) >= 7 {
+			y := cs.y
+			cs.curveTo(s[0], 0, s[1], s[2], s[3], 0)
+			cs.curveTo(s[4], 0, s[5], y-cs.y, s[6], 0)
+		}
+		cs.stack = cs.stack[:0]
+
+	case 36: // hflex1
+		if len(s) >= 9 {
+			y := cs.y
+			cs.curveTo(s[0], s[1], s[2], s[3], s[4], 0)
+			cs.curveTo(s[5], 0, s[6], s[7], s[8], y-cs.y-s[7])
+		}
+		cs.stack = cs.stack[:0]
+
+	case 37: // flex1
+		if len(s) >= 11 {
+			dx := s[0] + s[2] + s[4] + s[6] + s[8]
+			dy := s[1] + s[3] + s[5] + s[7] + s[9]
+			x0, y0 := cs.x, cs.y
+			cs.curveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+			cs.curveTo(s[6], s[7], s[8], s[9], 0, 0)
+			if math.Abs(dx) > math.Abs(dy) {
+				cs.x, cs.y = x0+dx+s[10], y0
+			} else {
+				cs.x, cs.y = x0, y0+dy+s[10]
+			}
+			// Adjust the end point of the last curve.
+			n := len(cs.p.pts)
+			cs.p.pts[n-1] = point{cs.x, cs.y}
+		}
+		cs.stack = cs.stack[:0]
+
+	case 3, 4, 10, 11, 12, 15, 24: // and, or, add, sub, div, eq, mul
+		b, a := cs.pop(), cs.pop()
+		var r float64
+		switch op {
+		case 3:
+			r = boolean(a != 0 && b != 0)
+		case 4:
+			r = boolean(a != 0 || b != 0)
+		case 10:
+			r = a + b
+		case 11:
+			r = a - b
+		case 12:
+			if b != 0 {
+				r = a / b
+			}
+		case 15:
+			r = boolean(a == b)
+		case 24:
+			r = a * b
+		}
+		cs.push(r)
+
+	case 5: // not
+		cs.push(boolean(cs.pop() == 0))
+
+	case 9: // abs
+		cs.push(math.Abs(cs.pop()))
+
+	case 14: // neg
+		cs.push(-cs.pop())
+
+	case 26: // sqrt
+		cs.push(math.Sqrt(math.Max(cs.pop(), 0)))
+
+	case 18: // drop
+		cs.pop()
+
+	case 27: // dup
+		v := cs.pop()
+		cs.push(v)
+		cs.push(v)
+
+	case 28: // exch
+		b, a := cs.pop(), cs.pop()
+		cs.push(b)
+		cs.push(a)
+
+	case 20: // put
+		i, v := int(cs.pop()), cs.pop()
+		if i >= 0 && i < len(cs.transient) {
+			cs.transient[i] = v
+		}
+
+	case 21: // get
+		i := int(cs.pop())
+		v := 0.
+		if i >= 0 && i < len(cs.transient) {
+			v = cs.transient[i]
+		}
+		cs.push(v)
+
+	case 22: // ifelse
+		v2, v1, s2, s1 := cs.pop(), cs.pop(), cs.pop(), cs.pop()
+		if v1 <= v2 {
+			cs.push(s1)
+		} else {
+			cs.push(s2)
+		}
+
+	case 23: // random
+		cs.push(0.5)
+
+	case 29: // index
+		i := int(cs.pop())
+		if i < 0 {
+			i = 0
+		}
+		v := 0.
+		if n := len(cs.stack); i < n {
+			v = cs.stack[n-1-i]
+		}
+		cs.push(v)
+
+	case 30: // roll
+		j, n := int(cs.pop()), int(cs.pop())
+		if n > 0 && n <= len(cs.stack) {
+			top := cs.stack[len(cs.stack)-n:]
+			j = ((j % n) + n) % n
+			rolled := append(append([]float64{}, top[n-j:]...), top[:n-j]...)
+			copy(top, rolled)
+		}
+
+	default:
+		cs.stack = cs.stack[:0]
+	}
+}
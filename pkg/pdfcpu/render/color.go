@@ -0,0 +1,368 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+const maxColorSpaceDepth = 8
+
+// colorSpace converts color components to RGB.
+type colorSpace interface {
+	components() int
+	rgb(c []float64) (r, g, b float64)
+	initialColor() []float64
+}
+
+type deviceGray struct{}
+
+func (deviceGray) components() int { return 1 }
+
+func (deviceGray) rgb(c []float64) (float64, float64, float64) {
+	g := component(c, 0)
+	return g, g, g
+}
+
+func (deviceGray) initialColor() []float64 { return []float64{0} }
+
+type deviceRGB struct{}
+
+func (deviceRGB) components() int { return 3 }
+
+func (deviceRGB) rgb(c []float64) (float64, float64, float64) {
+	return component(c, 0), component(c, 1), component(c, 2)
+}
+
+func (deviceRGB) initialColor() []float64 { return []float64{0, 0, 0} }
+
+type deviceCMYK struct{}
+
+func (deviceCMYK) components() int { return 4 }
+
+func (deviceCMYK) rgb(c []float64) (float64, float64, float64) {
+	k := component(c, 3)
+	return (1 - component(c, 0)) * (1 - k), (1 - component(c, 1)) * (1 - k), (1 - component(c, 2)) * (1 - k)
+}
+
+func (deviceCMYK) initialColor() []float64 { return []float64{0, 0, 0, 1} }
+
+// lab is a CIE L*a*b* color space.
+type lab struct {
+	wp  [3]float64
+	rng [4]float64
+}
+
+func (lab) components() int { return 3 }
+
+func (cs lab) rgb(c []float64) (float64, float64, float64) {
+	l := clip(componentRaw(c, 0), 0, 100)
+	a := clip(componentRaw(c, 1), cs.rng[0], cs.rng[1])
+	b := clip(componentRaw(c, 2), cs.rng[2], cs.rng[3])
+
+	g := func(x float64) float64 {
+		if x >= 6./29 {
+			return x * x * x
+		}
+		return 108. / 841 * (x - 4./29)
+	}
+
+	m := (l + 16) / 116
+	x := cs.wp[0] * g(m+a/500)
+	y := cs.wp[1] * g(m)
+	z := cs.wp[2] * g(m-b/200)
+
+	// XYZ to linear sRGB.
+	r := 3.2406*x - 1.5372*y - 0.4986*z
+	gr := -0.9689*x + 1.8758*y + 0.0415*z
+	bl := 0.0557*x - 0.2040*y + 1.0570*z
+
+	gamma := func(v float64) float64 {
+		v = clip(v, 0, 1)
+		if v <= 0.0031308 {
+			return 12.92 * v
+		}
+		return 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+
+	return gamma(r), gamma(gr), gamma(bl)
+}
+
+func (cs lab) initialColor() []float64 {
+	return []float64{0, clip(0, cs.rng[0], cs.rng[1]), clip(0, cs.rng[2], cs.rng[3])}
+}
+
+// indexed maps color table indices to colors of a base color space.
+type indexed struct {
+	base   colorSpace
+	hival  int
+	lookup []byte
+}
+
+func (indexed) components() int { return 1 }
+
+func (cs indexed) rgb(c []float64) (float64, float64, float64) {
+	i := int(clip(math.Round(componentRaw(c, 0)), 0, float64(cs.hival)))
+	n := cs.base.components()
+	cc := make([]float64, n)
+	for j := range cc {
+		if k := i*n + j; k < len(cs.lookup) {
+			cc[j] = float64(cs.lookup[k]) / 255
+		}
+	}
+	if l, ok := cs.base.(lab); ok {
+		// Lookup values are scaled to the ranges of the components.
+		cc[0] *= 100
+		cc[1] = l.rng[0] + cc[1]*(l.rng[1]-l.rng[0])
+		cc[2] = l.rng[2] + cc[2]*(l.rng[3]-l.rng[2])
+	}
+	return cs.base.rgb(cc)
+}
+
+func (indexed) initialColor() []float64 { return []float64{0} }
+
+// tinted represents Separation and DeviceN color spaces.
+type tinted struct {
+	n   int
+	alt colorSpace
+	fn  function
+}
+
+func (cs tinted) components() int { return cs.n }
+
+func (cs tinted) rgb(c []float64) (float64, float64, float64) {
+	if cs.fn == nil {
+		g := 1 - component(c, 0)
+		return g, g, g
+	}
+	return cs.alt.rgb(cs.fn.eval(c))
+}
+
+func (cs tinted) initialColor() []float64 {
+	c := make([]float64, cs.n)
+	for i := range c {
+		c[i] = 1
+	}
+	return c
+}
+
+// patternCS is the Pattern color space along with the color space for uncolored patterns.
+type patternCS struct {
+	base colorSpace
+}
+
+func (cs patternCS) components() int {
+	if cs.base == nil {
+		return 0
+	}
+	return cs.base.components()
+}
+
+func (cs patternCS) rgb(c []float64) (float64, float64, float64) {
+	if cs.base == nil {
+		return 0, 0, 0
+	}
+	return cs.base.rgb(c)
+}
+
+func (cs patternCS) initialColor() []float64 {
+	if cs.base == nil {
+		return nil
+	}
+	return cs.base.initialColor()
+}
+
+func componentRaw(c []float64, i int) float64 {
+	if i < len(c) {
+		return c[i]
+	}
+	return 0
+}
+
+func component(c []float64, i int) float64 {
+	return clip(componentRaw(c, i), 0, 1)
+}
+
+func rgba(cs colorSpace, c []float64) color.RGBA {
+	r, g, b := cs.rgb(c)
+	return color.RGBA{uint8(clip(r, 0, 1)*255 + 0.5), uint8(clip(g, 0, 1)*255 + 0.5), uint8(clip(b, 0, 1)*255 + 0.5), 0xFF}
+}
+
+func deviceColorSpace(name string) colorSpace {
+	switch name {
+	case "DeviceGray", "G", "CalGray":
+		return deviceGray{}
+	case "DeviceRGB", "RGB", "CalRGB":
+		return deviceRGB{}
+	case "DeviceCMYK", "CMYK":
+		return deviceCMYK{}
+	case "Pattern":
+		return patternCS{}
+	}
+	return nil
+}
+
+func deviceColorSpaceForComponents(n int) colorSpace {
+	switch n {
+	case 1:
+		return deviceGray{}
+	case 4:
+		return deviceCMYK{}
+	}
+	return deviceRGB{}
+}
+
+// colorSpace returns the color space o using resources for named color spaces.
+func (r *renderer) colorSpace(o types.Object, resources types.Dict, depth int) colorSpace {
+	if depth > maxColorSpaceDepth {
+		return deviceGray{}
+	}
+
+	if n, ok := o.(types.Name); ok {
+		if cs := deviceColorSpace(n.Value()); cs != nil {
+			return cs
+		}
+		if o = r.resource(resources, "ColorSpace", n.Value()); o == nil {
+			return deviceGray{}
+		}
+	}
+
+	indRef, isIndRef := o.(types.IndirectRef)
+	if isIndRef {
+		if cs, ok := r.colorSpaces[indRef.ObjectNumber.Value()]; ok {
+			return cs
+		}
+	}
+
+	cs := r.loadColorSpace(o, resources, depth)
+	if isIndRef {
+		r.colorSpaces[indRef.ObjectNumber.Value()] = cs
+	}
+	return cs
+}
+
+func (r *renderer) loadColorSpace(o types.Object, resources types.Dict, depth int) colorSpace {
+	xRefTable := r.ctx.XRefTable
+
+	o, err := xRefTable.Dereference(o)
+	if err != nil {
+		return deviceGray{}
+	}
+
+	switch o := o.(type) {
+	case types.Name:
+		if cs := deviceColorSpace(o.Value()); cs != nil {
+			return cs
+		}
+	case types.Array:
+		if len(o) > 0 {
+			if n, ok := o[0].(types.Name); ok {
+				if cs := r.colorSpaceArray(n.Value(), o, resources, depth); cs != nil {
+					return cs
+				}
+			}
+		}
+	}
+
+	return deviceGray{}
+}
+
+func (r *renderer) colorSpaceArray(name string, a types.Array, resources types.Dict, depth int) colorSpace {
+	xRefTable := r.ctx.XRefTable
+
+	arg := func(i int) types.Object {
+		if i < len(a) {
+			return a[i]
+		}
+		return nil
+	}
+
+	switch name {
+
+	case "Lab":
+		cs := lab{wp: [3]float64{0.9505, 1, 1.089}, rng: [4]float64{-100, 100, -100, 100}}
+		if d, err := xRefTable.DereferenceDict(arg(1)); err == nil && d != nil {
+			if wp := numberArray(xRefTable, d, "WhitePoint"); len(wp) == 3 {
+				copy(cs.wp[:], wp)
+			}
+			if rng := numberArray(xRefTable, d, "Range"); len(rng) == 4 {
+				copy(cs.rng[:], rng)
+			}
+		}
+		return cs
+
+	case "ICCBased":
+		sd, _, err := xRefTable.DereferenceStreamDict(arg(1))
+		if err != nil || sd == nil {
+			return nil
+		}
+		if alt, found := sd.Find("Alternate"); found {
+			return r.colorSpace(alt, resources, depth+1)
+		}
+		n := 3
+		if i := sd.IntEntry("N"); i != nil {
+			n = *i
+		}
+		return deviceColorSpaceForComponents(n)
+
+	case "Indexed", "I":
+		base := r.colorSpace(arg(1), resources, depth+1)
+		hival, err := xRefTable.DereferenceNumber(arg(2))
+		if err != nil {
+			return nil
+		}
+		cs := indexed{base: base, hival: int(hival)}
+		switch o, _ := xRefTable.Dereference(arg(3)); o := o.(type) {
+		case types.StringLiteral:
+			cs.lookup, _ = types.Unescape(o.Value())
+		case types.HexLiteral:
+			cs.lookup, _ = o.Bytes()
+		case types.StreamDict:
+			if err := o.Decode(); err == nil {
+				cs.lookup = o.Content
+			}
+		}
+		return cs
+
+	case "Separation", "DeviceN":
+		n := 1
+		if name == "DeviceN" {
+			names, err := xRefTable.DereferenceArray(arg(1))
+			if err != nil || len(names) == 0 {
+				return nil
+			}
+			n = len(names)
+		}
+		alt := r.colorSpace(arg(2), resources, depth+1)
+		fn, err := loadFunction(xRefTable, arg(3), 0)
+		if err != nil {
+			fn = nil
+		}
+		return tinted{n: n, alt: alt, fn: fn}
+
+	case "Pattern":
+		if len(a) > 1 {
+			return patternCS{base: r.colorSpace(a[1], resources, depth+1)}
+		}
+		return patternCS{}
+	}
+
+	return deviceColorSpace(name)
+}
@@ -0,0 +1,362 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/text"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Font descriptor flags.
+const (
+	flagFixedPitch = 1
+	flagSerif      = 1 << 1
+	flagSymbolic   = 1 << 2
+	flagItalic     = 1 << 6
+	flagForceBold  = 1 << 18
+)
+
+// substitutes are the fonts used for fonts without embedded font program.
+var (
+	substitutesOnce sync.Once
+	substitutes     map[string]*trueType
+)
+
+func substitute(name string) fontProgram {
+	substitutesOnce.Do(func() {
+		substitutes = map[string]*trueType{}
+		for k, ttf := range map[string][]byte{
+			"regular":        goregular.TTF,
+			"bold":           gobold.TTF,
+			"italic":         goitalic.TTF,
+			"bolditalic":     gobolditalic.TTF,
+			"mono":           gomono.TTF,
+			"monobold":       gomonobold.TTF,
+			"monoitalic":     gomonoitalic.TTF,
+			"monobolditalic": gomonobolditalic.TTF,
+		} {
+			if tt, err := parseTrueType(ttf); err == nil {
+				substitutes[k] = tt
+			}
+		}
+	})
+	if tt, ok := substitutes[name]; ok {
+		return tt
+	}
+	return nil
+}
+
+// type3Font holds the glyph procedures of a Type 3 font.
+type type3Font struct {
+	matrix    matrix.Matrix
+	charProcs types.Dict
+	resources types.Dict
+}
+
+// renderFont provides glyph outlines for the codes of strings shown using a font dict.
+type renderFont struct {
+	dec         *text.Font
+	name        string // font name without subset tag
+	flags       int    // font descriptor flags
+	prog        fontProgram
+	subtype     string // of the descendant font for composite fonts
+	symbolic    bool
+	hasEncoding bool
+	cidToGID    []byte // nil for Identity
+	subst       bool
+	type3       *type3Font
+	glyphs      map[string]*path
+}
+
+func fontDescriptorName(fd types.Dict, d types.Dict) string {
+	if s := fd.NameEntry("FontName"); s != nil {
+		return *s
+	}
+	if s := d.NameEntry("BaseFont"); s != nil {
+		return *s
+	}
+	return ""
+}
+
+func (r *renderer) font(o types.Object) *renderFont {
+	indRef, isIndRef := o.(types.IndirectRef)
+	if isIndRef {
+		if f, ok := r.fonts[indRef.ObjectNumber.Value()]; ok {
+			return f
+		}
+	}
+
+	f := r.loadFont(o)
+	if isIndRef {
+		r.fonts[indRef.ObjectNumber.Value()] = f
+	}
+	return f
+}
+
+func (r *renderer) loadFont(o types.Object) *renderFont {
+	xRefTable := r.ctx.XRefTable
+
+	d, err := xRefTable.DereferenceDict(o)
+	if err != nil || d == nil {
+		return nil
+	}
+
+	dec, err := text.LoadFont(xRefTable, d)
+	if err != nil || dec == nil {
+		return nil
+	}
+
+	f := &renderFont{dec: dec, glyphs: map[string]*path{}}
+	if s := d.Subtype(); s != nil {
+		f.subtype = *s
+	}
+	_, f.hasEncoding = d.Find("Encoding")
+
+	if f.subtype == "Type3" {
+		f.type3 = r.loadType3(d)
+		return f
+	}
+
+	if f.subtype == "Type0" {
+		a, err := xRefTable.DereferenceArray(d["DescendantFonts"])
+		if err != nil || len(a) == 0 {
+			return f
+		}
+		if d, err = xRefTable.DereferenceDict(a[0]); err != nil || d == nil {
+			return f
+		}
+		if s := d.Subtype(); s != nil {
+			f.subtype = *s
+		}
+		if sd, _, err := xRefTable.DereferenceStreamDict(d["CIDToGIDMap"]); err == nil && sd != nil {
+			if err := sd.Decode(); err == nil {
+				f.cidToGID = sd.Content
+			}
+		}
+	}
+
+	fd, _ := xRefTable.DereferenceDict(d["FontDescriptor"])
+	if fd == nil {
+		fd = types.Dict{}
+	}
+
+	if i := fd.IntEntry("Flags"); i != nil {
+		f.flags = *i
+	}
+	f.symbolic = f.flags&flagSymbolic > 0
+
+	f.name = fontDescriptorName(fd, d)
+	if i := strings.IndexByte(f.name, '+'); i == 6 {
+		f.name = f.name[7:]
+	}
+
+	f.prog = loadFontProgram(xRefTable, fd)
+	if f.prog == nil {
+		f.subst = true
+		f.prog = substituteFor(f.name, f.flags)
+	}
+
+	return f
+}
+
+func loadFontProgram(xRefTable *model.XRefTable, fd types.Dict) fontProgram {
+	for _, key := range []string{"FontFile", "FontFile2", "FontFile3"} {
+		sd, _, err := xRefTable.DereferenceStreamDict(fd[key])
+		if err != nil || sd == nil {
+			continue
+		}
+		if err := sd.Decode(); err != nil {
+			return nil
+		}
+
+		switch key {
+		case "FontFile":
+			if f, err := parseType1(sd.Content); err == nil {
+				return f
+			}
+		case "FontFile2":
+			if f, err := parseTrueType(sd.Content); err == nil {
+				return f
+			}
+		case "FontFile3":
+			if s := sd.Subtype(); s != nil && *s == "OpenType" {
+				if f, err := parseTrueType(sd.Content); err == nil {
+					return f
+				}
+				return nil
+			}
+			if f, err := parseCFF(sd.Content); err == nil {
+				return f
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+func substituteFor(name string, flags int) fontProgram {
+	name = strings.ToLower(name)
+
+	var k string
+	if flags&flagFixedPitch > 0 || strings.Contains(name, "courier") || strings.Contains(name, "mono") {
+		k = "mono"
+	}
+	bold := flags&flagForceBold > 0 || strings.Contains(name, "bold") || strings.Contains(name, "black") || strings.Contains(name, "heavy")
+	italic := flags&flagItalic > 0 || strings.Contains(name, "italic") || strings.Contains(name, "oblique")
+	if bold {
+		k += "bold"
+	}
+	if italic {
+		k += "italic"
+	}
+	if k == "" {
+		k = "regular"
+	}
+	return substitute(k)
+}
+
+func (r *renderer) loadType3(d types.Dict) *type3Font {
+	xRefTable := r.ctx.XRefTable
+
+	f := &type3Font{matrix: matrix.Matrix{{0.001, 0, 0}, {0, 0.001, 0}, {0, 0, 1}}}
+	if fm := numberArray(xRefTable, d, "FontMatrix"); len(fm) == 6 {
+		f.matrix = matrix.Matrix{{fm[0], fm[1], 0}, {fm[2], fm[3], 0}, {fm[4], fm[5], 1}}
+	}
+	f.charProcs, _ = xRefTable.DereferenceDict(d["CharProcs"])
+	f.resources, _ = xRefTable.DereferenceDict(d["Resources"])
+	return f
+}
+
+// gid returns the glyph of the font program for code c.
+func (f *renderFont) gid(c text.Code) (int, bool) {
+	prog := f.prog
+
+	if f.subst {
+		s := c.Text
+		if s == "" && c.Name != "" {
+			s = text.GlyphText(c.Name)
+		}
+		if r, _ := utf8.DecodeRuneInString(s); r != utf8.RuneError {
+			return prog.gidForRune(r)
+		}
+		return 0, false
+	}
+
+	switch f.subtype {
+
+	case "CIDFontType2":
+		gid := c.CID
+		if f.cidToGID != nil {
+			if 2*c.CID+1 >= len(f.cidToGID) {
+				return 0, false
+			}
+			gid = int(f.cidToGID[2*c.CID])<<8 | int(f.cidToGID[2*c.CID+1])
+		}
+		return gid, true
+
+	case "CIDFontType0":
+		return prog.gidForCID(c.CID)
+	}
+
+	if len(c.Bytes) != 1 {
+		return 0, false
+	}
+	code := c.Bytes[0]
+
+	if _, ok := prog.(*trueType); ok && (f.symbolic || !f.hasEncoding) && f.subtype == "TrueType" {
+		if gid, ok := prog.gidForCode(code); ok {
+			return gid, true
+		}
+	}
+
+	if f.hasEncoding && c.Name != "" {
+		if gid, ok := prog.gidForName(c.Name); ok {
+			return gid, true
+		}
+		if r, _ := utf8.DecodeRuneInString(text.GlyphText(c.Name)); r != utf8.RuneError {
+			if gid, ok := prog.gidForRune(r); ok {
+				return gid, true
+			}
+		}
+	}
+
+	if gid, ok := prog.gidForCode(code); ok {
+		return gid, true
+	}
+
+	if c.Name != "" {
+		return prog.gidForName(c.Name)
+	}
+
+	return 0, false
+}
+
+// glyph returns the outline of code c in text space units.
+func (f *renderFont) glyph(c text.Code) *path {
+	if f.prog == nil {
+		return nil
+	}
+
+	k := string(c.Bytes)
+	if p, ok := f.glyphs[k]; ok {
+		return p
+	}
+
+	var p *path
+	if gid, ok := f.gid(c); ok {
+		m := f.prog.fontMatrix()
+		if f.subst {
+			// Scale the substitute glyph horizontally to the width of the replaced glyph.
+			if adv := f.prog.advance(gid); adv > 0 && c.Width > 0 {
+				sx := clip(c.Width/1000/adv, 0.5, 1.5)
+				m = matrix.Matrix{{sx, 0, 0}, {0, 1, 0}, {0, 0, 1}}.Multiply(m)
+			}
+		}
+		p = &path{}
+		p.append(f.prog.outline(gid), m)
+		if f.dec.Vertical() {
+			p = translated(p, -c.Width/2000, -0.88)
+		}
+	}
+
+	f.glyphs[k] = p
+	return p
+}
+
+func translated(p *path, dx, dy float64) *path {
+	q := &path{}
+	q.append(p, translation(dx, dy))
+	return q
+}
+
+func translation(dx, dy float64) matrix.Matrix {
+	return matrix.Matrix{{1, 0, 0}, {0, 1, 0}, {dx, dy, 1}}
+}
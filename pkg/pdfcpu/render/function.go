@@ -0,0 +1,648 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	maxFunctionDepth = 8
+	maxSamples       = 1 << 20
+	maxPSStack       = 100
+)
+
+// function is a PDF function.
+type function interface {
+	eval(in []float64) []float64
+}
+
+func clip(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
+
+func interpolate(x, xmin, xmax, ymin, ymax float64) float64 {
+	if xmax == xmin {
+		return ymin
+	}
+	return ymin + (x-xmin)*(ymax-ymin)/(xmax-xmin)
+}
+
+func numberArray(xRefTable *model.XRefTable, d types.Dict, key string) []float64 {
+	a, err := xRefTable.DereferenceArray(d[key])
+	if err != nil {
+		return nil
+	}
+	ff := make([]float64, 0, len(a))
+	for _, o := range a {
+		f, err := xRefTable.DereferenceNumber(o)
+		if err != nil {
+			return nil
+		}
+		ff = append(ff, f)
+	}
+	return ff
+}
+
+type baseFunction struct {
+	domain []float64
+	rng    []float64
+}
+
+func (f baseFunction) clipInput(in []float64) []float64 {
+	res := make([]float64, len(in))
+	for i, v := range in {
+		if 2*i+1 < len(f.domain) {
+			v = clip(v, f.domain[2*i], f.domain[2*i+1])
+		}
+		res[i] = v
+	}
+	return res
+}
+
+func (f baseFunction) clipOutput(out []float64) []float64 {
+	for i := range out {
+		if 2*i+1 < len(f.rng) {
+			out[i] = clip(out[i], f.rng[2*i], f.rng[2*i+1])
+		}
+	}
+	return out
+}
+
+// sampledFunction is a function of type 0.
+type sampledFunction struct {
+	baseFunction
+	size    []int
+	encode  []float64
+	decode  []float64
+	samples []float64 // normalized to 0..1
+	n       int       // number of outputs
+}
+
+func readSamples(bb []byte, bps, count int) []float64 {
+	ss := make([]float64, count)
+	max := float64(uint64(1)<<uint(bps) - 1)
+	var bitPos uint
+	for i := range ss {
+		var v uint64
+		for b := 0; b < bps; b++ {
+			byteIdx := bitPos >> 3
+			if int(byteIdx) >= len(bb) {
+				return ss
+			}
+			bit := (bb[byteIdx] >> (7 - bitPos&7)) & 1
+			v = v<<1 | uint64(bit)
+			bitPos++
+		}
+		ss[i] = float64(v) / max
+	}
+	return ss
+}
+
+func loadSampledFunction(xRefTable *model.XRefTable, sd *types.StreamDict, bf baseFunction) (function, error) {
+	m, n := len(bf.domain)/2, len(bf.rng)/2
+	if m == 0 || n == 0 {
+		return nil, errors.New("pdfcpu: sampled function: missing domain or range")
+	}
+
+	f := &sampledFunction{baseFunction: bf, n: n}
+
+	count := n
+	for _, s := range numberArray(xRefTable, sd.Dict, "Size") {
+		f.size = append(f.size, int(s))
+		count *= int(s)
+	}
+	if len(f.size) != m || count <= 0 || count > maxSamples {
+		return nil, errors.New("pdfcpu: sampled function: invalid size")
+	}
+
+	bps := 8
+	if i := sd.IntEntry("BitsPerSample"); i != nil {
+		bps = *i
+	}
+	if bps < 1 || bps > 32 {
+		return nil, errors.Errorf("pdfcpu: sampled function: invalid BitsPerSample %d", bps)
+	}
+
+	f.encode = numberArray(xRefTable, sd.Dict, "Encode")
+	if len(f.encode) != 2*m {
+		f.encode = make([]float64, 2*m)
+		for i, s := range f.size {
+			f.encode[2*i+1] = float64(s - 1)
+		}
+	}
+
+	f.decode = numberArray(xRefTable, sd.Dict, "Decode")
+	if len(f.decode) != 2*n {
+		f.decode = f.rng
+	}
+
+	if err := sd.Decode(); err != nil {
+		return nil, err
+	}
+	f.samples = readSamples(sd.Content, bps, count)
+
+	return f, nil
+}
+
+func (f *sampledFunction) eval(in []float64) []float64 {
+	in = f.clipInput(in)
+	m := len(f.size)
+	if len(in) < m {
+		return make([]float64, f.n)
+	}
+
+	// Multilinear interpolation between the surrounding samples.
+	e := make([]float64, m)
+	i0 := make([]int, m)
+	for i := 0; i < m; i++ {
+		e[i] = interpolate(in[i], f.domain[2*i], f.domain[2*i+1], f.encode[2*i], f.encode[2*i+1])
+		e[i] = clip(e[i], 0, float64(f.size[i]-1))
+		i0[i] = int(e[i])
+		if i0[i] == f.size[i]-1 && i0[i] > 0 {
+			i0[i]--
+		}
+		e[i] -= float64(i0[i])
+	}
+
+	out := make([]float64, f.n)
+	for corner := 0; corner < 1<<uint(m); corner++ {
+		w := 1.
+		idx, stride := 0, 1
+		for i := 0; i < m; i++ {
+			k := i0[i]
+			if corner&(1<<uint(i)) != 0 {
+				if f.size[i] > 1 {
+					k++
+				}
+				w *= e[i]
+			} else {
+				w *= 1 - e[i]
+			}
+			idx += k * stride
+			stride *= f.size[i]
+		}
+		if w == 0 {
+			continue
+		}
+		for j := 0; j < f.n; j++ {
+			if k := idx*f.n + j; k < len(f.samples) {
+				out[j] += w * f.samples[k]
+			}
+		}
+	}
+
+	for j := range out {
+		out[j] = f.decode[2*j] + out[j]*(f.decode[2*j+1]-f.decode[2*j])
+	}
+
+	return f.clipOutput(out)
+}
+
+// exponentialFunction is a function of type 2.
+type exponentialFunction struct {
+	baseFunction
+	c0, c1 []float64
+	n      float64
+}
+
+func (f *exponentialFunction) eval(in []float64) []float64 {
+	in = f.clipInput(in)
+	x := 0.
+	if len(in) > 0 {
+		x = in[0]
+	}
+	xn := math.Pow(x, f.n)
+	out := make([]float64, len(f.c0))
+	for i := range out {
+		out[i] = f.c0[i] + xn*(f.c1[i]-f.c0[i])
+	}
+	return f.clipOutput(out)
+}
+
+// stitchingFunction is a function of type 3.
+type stitchingFunction struct {
+	baseFunction
+	functions []function
+	bounds    []float64
+	encode    []float64
+}
+
+func (f *stitchingFunction) eval(in []float64) []float64 {
+	in = f.clipInput(in)
+	x := 0.
+	if len(in) > 0 {
+		x = in[0]
+	}
+
+	k := 0
+	for k < len(f.bounds) && x >= f.bounds[k] {
+		k++
+	}
+
+	lo, hi := f.domain[0], f.domain[1]
+	if k > 0 {
+		lo = f.bounds[k-1]
+	}
+	if k < len(f.bounds) {
+		hi = f.bounds[k]
+	}
+
+	x = interpolate(x, lo, hi, f.encode[2*k], f.encode[2*k+1])
+	return f.clipOutput(f.functions[k].eval([]float64{x}))
+}
+
+// psFunction is a function of type 4 given by a PostScript calculator program.
+type psFunction struct {
+	baseFunction
+	prog []psOp
+}
+
+// psOp is either a number, an operator or a procedure for if and ifelse.
+type psOp struct {
+	op   string
+	num  float64
+	proc []psOp
+}
+
+func psTokens(s string) []string {
+	s = strings.NewReplacer("{", " { ", "}", " } ").Replace(s)
+	return strings.Fields(s)
+}
+
+func parsePSProc(tt []string, i int) ([]psOp, int, error) {
+	var ops []psOp
+	for i < len(tt) {
+		t := tt[i]
+		i++
+		switch t {
+		case "{":
+			proc, j, err := parsePSProc(tt, i)
+			if err != nil {
+				return nil, 0, err
+			}
+			ops = append(ops, psOp{proc: proc})
+			i = j
+		case "}":
+			return ops, i, nil
+		default:
+			if f, err := strconv.ParseFloat(t, 64); err == nil {
+				ops = append(ops, psOp{num: f})
+				continue
+			}
+			ops = append(ops, psOp{op: t})
+		}
+	}
+	return nil, 0, errors.New("pdfcpu: PostScript function: missing }")
+}
+
+func loadPSFunction(sd *types.StreamDict, bf baseFunction) (function, error) {
+	if err := sd.Decode(); err != nil {
+		return nil, err
+	}
+	tt := psTokens(string(sd.Content))
+	if len(tt) == 0 || tt[0] != "{" {
+		return nil, errors.New("pdfcpu: PostScript function: missing {")
+	}
+	prog, _, err := parsePSProc(tt, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &psFunction{baseFunction: bf, prog: prog}, nil
+}
+
+type psStack []float64
+
+func (s *psStack) push(f float64) {
+	if len(*s) < maxPSStack {
+		*s = append(*s, f)
+	}
+}
+
+func (s *psStack) pop() float64 {
+	n := len(*s)
+	if n == 0 {
+		return 0
+	}
+	f := (*s)[n-1]
+	*s = (*s)[:n-1]
+	return f
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (s *psStack) exec(ops []psOp) {
+	for i := 0; i < len(ops); i++ {
+		o := ops[i]
+		if o.proc != nil {
+			// Procedures are operands of if and ifelse.
+			if i+1 < len(ops) && ops[i+1].op == "if" {
+				if s.pop() != 0 {
+					s.exec(o.proc)
+				}
+				i++
+				continue
+			}
+			if i+2 < len(ops) && ops[i+1].proc != nil && ops[i+2].op == "ifelse" {
+				if s.pop() != 0 {
+					s.exec(o.proc)
+				} else {
+					s.exec(ops[i+1].proc)
+				}
+				i += 2
+			}
+			continue
+		}
+		if o.op == "" {
+			s.push(o.num)
+			continue
+		}
+		s.operator(o.op)
+	}
+}
+
+func (s *psStack) operator(op string) {
+	switch op {
+	case "abs", "neg", "ceiling", "floor", "round", "truncate", "cvi", "cvr", "sqrt", "sin", "cos", "ln", "log", "not":
+		x := s.pop()
+		var r float64
+		switch op {
+		case "abs":
+			r = math.Abs(x)
+		case "neg":
+			r = -x
+		case "ceiling":
+			r = math.Ceil(x)
+		case "floor":
+			r = math.Floor(x)
+		case "round":
+			r = math.Floor(x + 0.5)
+		case "truncate", "cvi":
+			r = math.Trunc(x)
+		case "cvr":
+			r = x
+		case "sqrt":
+			r = math.Sqrt(math.Max(x, 0))
+		case "sin":
+			r = math.Sin(x * math.Pi / 180)
+		case "cos":
+			r = math.Cos(x * math.Pi / 180)
+		case "ln":
+			r = math.Log(x)
+		case "log":
+			r = math.Log10(x)
+		case "not":
+			// Booleans are represented as 0 and 1.
+			if x == 0 || x == 1 {
+				r = 1 - x
+			} else {
+				r = float64(^int64(x))
+			}
+		}
+		s.push(r)
+
+	case "add", "sub", "mul", "div", "idiv", "mod", "exp", "atan", "eq", "ne", "gt", "ge", "lt", "le", "and", "or", "xor", "bitshift":
+		y, x := s.pop(), s.pop()
+		var r float64
+		switch op {
+		case "add":
+			r = x + y
+		case "sub":
+			r = x - y
+		case "mul":
+			r = x * y
+		case "div":
+			if y != 0 {
+				r = x / y
+			}
+		case "idiv":
+			if int64(y) != 0 {
+				r = float64(int64(x) / int64(y))
+			}
+		case "mod":
+			if int64(y) != 0 {
+				r = float64(int64(x) % int64(y))
+			}
+		case "exp":
+			r = math.Pow(x, y)
+		case "atan":
+			r = math.Atan2(x, y) * 180 / math.Pi
+			if r < 0 {
+				r += 360
+			}
+		case "eq":
+			r = boolean(x == y)
+		case "ne":
+			r = boolean(x != y)
+		case "gt":
+			r = boolean(x > y)
+		case "ge":
+			r = boolean(x >= y)
+		case "lt":
+			r = boolean(x < y)
+		case "le":
+			r = boolean(x <= y)
+		case "and":
+			r = float64(int64(x) & int64(y))
+		case "or":
+			r = float64(int64(x) | int64(y))
+		case "xor":
+			r = float64(int64(x) ^ int64(y))
+		case "bitshift":
+			if y >= 0 {
+				r = float64(int64(x) << uint(y))
+			} else {
+				r = float64(int64(x) >> uint(-y))
+			}
+		}
+		s.push(r)
+
+	case "true":
+		s.push(1)
+
+	case "false":
+		s.push(0)
+
+	case "pop":
+		s.pop()
+
+	case "dup":
+		x := s.pop()
+		s.push(x)
+		s.push(x)
+
+	case "exch":
+		y, x := s.pop(), s.pop()
+		s.push(y)
+		s.push(x)
+
+	case "copy":
+		n := int(s.pop())
+		if n > 0 && n <= len(*s) {
+			for _, f := range (*s)[len(*s)-n:] {
+				s.push(f)
+			}
+		}
+
+	case "index":
+		n := int(s.pop())
+		if n >= 0 && n < len(*s) {
+			s.push((*s)[len(*s)-1-n])
+		}
+
+	case "roll":
+		j, n := int(s.pop()), int(s.pop())
+		if n > 0 && n <= len(*s) {
+			top := (*s)[len(*s)-n:]
+			j = ((j % n) + n) % n
+			rolled := append(append([]float64{}, top[n-j:]...), top[:n-j]...)
+			copy(top, rolled)
+		}
+	}
+}
+
+func (f *psFunction) eval(in []float64) []float64 {
+	in = f.clipInput(in)
+	s := psStack(append([]float64{}, in...))
+	s.exec(f.prog)
+	n := len(f.rng) / 2
+	out := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		out[i] = s.pop()
+	}
+	return f.clipOutput(out)
+}
+
+// functionArray combines n functions with one output each.
+type functionArray []function
+
+func (ff functionArray) eval(in []float64) []float64 {
+	out := make([]float64, 0, len(ff))
+	for _, f := range ff {
+		if v := f.eval(in); len(v) > 0 {
+			out = append(out, v[0])
+			continue
+		}
+		out = append(out, 0)
+	}
+	return out
+}
+
+func loadFunction(xRefTable *model.XRefTable, o types.Object, depth int) (function, error) {
+	if depth > maxFunctionDepth {
+		return nil, errors.New("pdfcpu: function nesting too deep")
+	}
+
+	o, err := xRefTable.Dereference(o)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		d  types.Dict
+		sd *types.StreamDict
+	)
+
+	switch o1 := o.(type) {
+	case types.Array:
+		var ff functionArray
+		for _, o2 := range o1 {
+			f, err := loadFunction(xRefTable, o2, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			ff = append(ff, f)
+		}
+		return ff, nil
+	case types.Dict:
+		d = o1
+	case types.StreamDict:
+		sd = &o1
+		d = o1.Dict
+	default:
+		return nil, errors.New("pdfcpu: invalid function")
+	}
+
+	bf := baseFunction{domain: numberArray(xRefTable, d, "Domain"), rng: numberArray(xRefTable, d, "Range")}
+
+	ft := d.IntEntry("FunctionType")
+	if ft == nil {
+		return nil, errors.New("pdfcpu: function: missing FunctionType")
+	}
+
+	switch *ft {
+
+	case 0:
+		if sd == nil {
+			return nil, errors.New("pdfcpu: sampled function: missing stream")
+		}
+		return loadSampledFunction(xRefTable, sd, bf)
+
+	case 2:
+		f := &exponentialFunction{baseFunction: bf, c0: numberArray(xRefTable, d, "C0"), c1: numberArray(xRefTable, d, "C1"), n: 1}
+		if n, err := xRefTable.DereferenceNumber(d["N"]); err == nil {
+			f.n = n
+		}
+		if f.c0 == nil {
+			f.c0 = []float64{0}
+		}
+		if f.c1 == nil {
+			f.c1 = []float64{1}
+		}
+		if len(f.c0) != len(f.c1) {
+			return nil, errors.New("pdfcpu: exponential function: C0 and C1 differ in size")
+		}
+		return f, nil
+
+	case 3:
+		a, err := xRefTable.DereferenceArray(d["Functions"])
+		if err != nil || len(a) == 0 {
+			return nil, errors.New("pdfcpu: stitching function: missing functions")
+		}
+		f := &stitchingFunction{baseFunction: bf, bounds: numberArray(xRefTable, d, "Bounds"), encode: numberArray(xRefTable, d, "Encode")}
+		for _, o := range a {
+			f1, err := loadFunction(xRefTable, o, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			f.functions = append(f.functions, f1)
+		}
+		if len(f.domain) < 2 || len(f.bounds) != len(f.functions)-1 || len(f.encode) != 2*len(f.functions) {
+			return nil, errors.New("pdfcpu: stitching function: inconsistent entries")
+		}
+		return f, nil
+
+	case 4:
+		if sd == nil {
+			return nil, errors.New("pdfcpu: PostScript function: missing stream")
+		}
+		return loadPSFunction(sd, bf)
+	}
+
+	return nil, errors.Errorf("pdfcpu: unsupported function type %d", *ft)
+}
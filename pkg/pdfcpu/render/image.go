@@ -0,0 +1,603 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	"github.com/hhrutter/tiff"
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/content"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/image/draw"
+)
+
+// decodedImage is an image XObject ready for painting.
+type decodedImage struct {
+	img  *image.RGBA  // premultiplied colors
+	mask *image.Alpha // stencil masks only
+}
+
+func (di *decodedImage) bounds() image.Rectangle {
+	if di.mask != nil {
+		return di.mask.Rect
+	}
+	return di.img.Rect
+}
+
+// invert returns the inverse of the affine transformation m.
+func invert(m matrix.Matrix) (matrix.Matrix, bool) {
+	det := m[0][0]*m[1][1] - m[0][1]*m[1][0]
+	if math.Abs(det) < 1e-12 {
+		return matrix.Matrix{}, false
+	}
+	a, b, c, d, e, f := m[0][0], m[0][1], m[1][0], m[1][1], m[2][0], m[2][1]
+	return matrix.Matrix{
+		{d / det, -b / det, 0},
+		{-c / det, a / det, 0},
+		{(c*f - d*e) / det, (b*e - a*f) / det, 1},
+	}, true
+}
+
+// imagePaint samples an image mapped to device space.
+type imagePaint struct {
+	img    *image.RGBA
+	mask   *image.Alpha
+	color  paint // stencil masks only
+	inv    matrix.Matrix
+	smooth bool
+}
+
+func (p *imagePaint) sample(x, y float64) color.RGBA {
+	r := p.img.Rect
+	ix := int(math.Max(0, math.Min(math.Floor(x), float64(r.Max.X-1))))
+	iy := int(math.Max(0, math.Min(math.Floor(y), float64(r.Max.Y-1))))
+	if !p.smooth {
+		return p.img.RGBAAt(ix, iy)
+	}
+
+	x0, y0 := math.Floor(x-0.5), math.Floor(y-0.5)
+	fx, fy := x-0.5-x0, y-0.5-y0
+	at := func(x, y float64) color.RGBA {
+		return p.img.RGBAAt(
+			int(math.Max(0, math.Min(x, float64(r.Max.X-1)))),
+			int(math.Max(0, math.Min(y, float64(r.Max.Y-1)))))
+	}
+	c00, c10, c01, c11 := at(x0, y0), at(x0+1, y0), at(x0, y0+1), at(x0+1, y0+1)
+	mix := func(a, b, c, d uint8) uint8 {
+		v := (float64(a)*(1-fx)+float64(b)*fx)*(1-fy) + (float64(c)*(1-fx)+float64(d)*fx)*fy
+		return uint8(v + 0.5)
+	}
+	return color.RGBA{
+		mix(c00.R, c10.R, c01.R, c11.R),
+		mix(c00.G, c10.G, c01.G, c11.G),
+		mix(c00.B, c10.B, c01.B, c11.B),
+		mix(c00.A, c10.A, c01.A, c11.A),
+	}
+}
+
+func (p *imagePaint) at(x, y int) color.RGBA {
+	q := transform(p.inv, point{float64(x) + 0.5, float64(y) + 0.5})
+	if p.mask == nil {
+		return p.sample(q.x, q.y)
+	}
+
+	r := p.mask.Rect
+	ix := int(math.Max(0, math.Min(math.Floor(q.x), float64(r.Max.X-1))))
+	iy := int(math.Max(0, math.Min(math.Floor(q.y), float64(r.Max.Y-1))))
+	m := uint32(p.mask.AlphaAt(ix, iy).A)
+	c := p.color.at(x, y)
+	return color.RGBA{uint8(uint32(c.R) * m / 0xFF), uint8(uint32(c.G) * m / 0xFF), uint8(uint32(c.B) * m / 0xFF), uint8(uint32(c.A) * m / 0xFF)}
+}
+
+// paintImage paints di into the unit square of user space.
+func (in *interpreter) paintImage(di *decodedImage, interpolate bool, resources types.Dict) {
+	gs := in.gs
+	if gs.clip.rect.Empty() {
+		return
+	}
+
+	if in.svg != nil && di.img != nil {
+		in.svgImage(di, interpolate)
+		return
+	}
+
+	var p path
+	p.rect(gs.ctm, 0, 0, 1, 1)
+	a := rasterize(p.flatten(), false, gs.clip.rect)
+	if a == nil {
+		return
+	}
+
+	b := di.bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+
+	// Reduce the resolution of images much larger than their device space area.
+	devW := math.Hypot(gs.ctm[0][0], gs.ctm[0][1])
+	devH := math.Hypot(gs.ctm[1][0], gs.ctm[1][1])
+	if di.img != nil && (w > 2*devW || h > 2*devH) {
+		dw, dh := int(math.Ceil(math.Min(w, 2*devW))), int(math.Ceil(math.Min(h, 2*devH)))
+		if dw > 0 && dh > 0 {
+			img := image.NewRGBA(image.Rect(0, 0, dw, dh))
+			draw.ApproxBiLinear.Scale(img, img.Rect, di.img, b, draw.Src, nil)
+			di = &decodedImage{img: img}
+			w, h = float64(dw), float64(dh)
+		}
+	}
+
+	m := matrix.Matrix{{1 / w, 0, 0}, {0, -1 / h, 0}, {0, 1, 1}}.Multiply(gs.ctm)
+	inv, ok := invert(m)
+	if !ok {
+		return
+	}
+
+	ip := &imagePaint{img: di.img, mask: di.mask, inv: inv}
+
+	// Smooth when requested or when reducing.
+	ip.smooth = interpolate || devW < w || devH < h
+
+	if di.mask != nil {
+		if ip.color = in.paint(false, resources); ip.color == nil {
+			return
+		}
+	}
+
+	if in.svg != nil {
+		in.svgRaster(a, gs.fillAlpha, ip)
+		return
+	}
+
+	composite(in.dst, a, gs.clip, gs.fillAlpha, ip)
+}
+
+func imageSize(sd *types.StreamDict) (int, int, bool) {
+	w, h := sd.IntEntry("Width"), sd.IntEntry("Height")
+	if w == nil || h == nil || *w <= 0 || *h <= 0 || *w > 1<<15 || *h > 1<<15 {
+		return 0, 0, false
+	}
+	return *w, *h, true
+}
+
+func lastFilter(sd *types.StreamDict) string {
+	if n := len(sd.FilterPipeline); n > 0 {
+		return sd.FilterPipeline[n-1].Name
+	}
+	return ""
+}
+
+// stencil decodes an image mask, painted samples are opaque.
+func stencil(sd *types.StreamDict, decode []float64) *image.Alpha {
+	w, h, ok := imageSize(sd)
+	if !ok || sd.Decode() != nil {
+		return nil
+	}
+	bb := sd.Content
+
+	paint := byte(0)
+	if len(decode) == 2 && decode[0] > decode[1] {
+		paint = 1
+	}
+
+	a := image.NewAlpha(image.Rect(0, 0, w, h))
+	stride := (w + 7) / 8
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*stride + x/8
+			if i >= len(bb) {
+				return a
+			}
+			if (bb[i]>>(7-uint(x%8)))&1 == paint {
+				a.Pix[y*a.Stride+x] = 0xFF
+			}
+		}
+	}
+	return a
+}
+
+// samples decodes image samples using the color space cs.
+func samples(sd *types.StreamDict, cs colorSpace, decode []float64) *image.RGBA {
+	w, h, ok := imageSize(sd)
+	if !ok {
+		return nil
+	}
+	if f := lastFilter(sd); f == filter.DCT || f == filter.JPX || sd.Decode() != nil {
+		return nil
+	}
+
+	bpc := 8
+	if i := sd.IntEntry("BitsPerComponent"); i != nil {
+		bpc = *i
+	}
+	if bpc != 1 && bpc != 2 && bpc != 4 && bpc != 8 && bpc != 16 {
+		return nil
+	}
+
+	n := cs.components()
+	if n == 0 {
+		return nil
+	}
+	maxVal := float64(int(1)<<uint(bpc) - 1)
+
+	if len(decode) < 2*n {
+		decode = make([]float64, 2*n)
+		for i := 0; i < n; i++ {
+			decode[2*i+1] = 1
+		}
+		if _, ok := cs.(indexed); ok {
+			decode[1] = maxVal
+		}
+	}
+
+	bb := sd.Content
+	stride := (w*n*bpc + 7) / 8
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	// Cache colors for single component images.
+	var lut []color.RGBA
+	if n == 1 && bpc <= 8 {
+		lut = make([]color.RGBA, 1<<uint(bpc))
+		for v := range lut {
+			lut[v] = rgba(cs, []float64{decode[0] + float64(v)*(decode[1]-decode[0])/maxVal})
+		}
+	}
+
+	c := make([]float64, n)
+	for y := 0; y < h; y++ {
+		row := y * stride
+		if row+stride > len(bb) {
+			break
+		}
+		bit := 0
+		for x := 0; x < w; x++ {
+			for i := 0; i < n; i++ {
+				var v int
+				switch bpc {
+				case 8:
+					v = int(bb[row+bit/8])
+				case 16:
+					v = int(bb[row+bit/8])<<8 | int(bb[row+bit/8+1])
+				default:
+					v = int(bb[row+bit/8]>>(8-uint(bit%8)-uint(bpc))) & (1<<uint(bpc) - 1)
+				}
+				bit += bpc
+				if lut != nil {
+					img.SetRGBA(x, y, lut[v])
+					continue
+				}
+				c[i] = decode[2*i] + float64(v)*(decode[2*i+1]-decode[2*i])/maxVal
+			}
+			if lut == nil {
+				img.SetRGBA(x, y, rgba(cs, c))
+			}
+		}
+	}
+
+	return img
+}
+
+// cloneStream returns a copy of sd for decoding without side effects on the document.
+func cloneStream(sd *types.StreamDict) *types.StreamDict {
+	sd1 := sd.Clone().(types.StreamDict)
+	if len(sd1.FilterPipeline) == 0 {
+		sd1.FilterPipeline = nil
+	}
+	return &sd1
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if img, ok := img.(*image.RGBA); ok {
+		return img
+	}
+	b := img.Bounds()
+	res := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(res, res.Rect, img, b.Min, draw.Src)
+	return res
+}
+
+// extractImage decodes an image XObject the way image extraction does.
+func (r *renderer) extractImage(sd *types.StreamDict, objNr int) image.Image {
+	// BitsPerComponent is undefined for JPX images.
+	if sd.IntEntry("BitsPerComponent") == nil && lastFilter(sd) != filter.JPX {
+		return nil
+	}
+
+	img, err := pdfcpu.ExtractImage(r.ctx, sd, false, "", objNr, false)
+	if err != nil || img == nil || img.Reader == nil {
+		return nil
+	}
+
+	var i image.Image
+	switch img.FileType {
+	case "png":
+		i, err = png.Decode(img)
+	case "jpg":
+		i, err = jpeg.Decode(img)
+	case "tif":
+		i, err = tiff.Decode(img)
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	return i
+}
+
+func (r *renderer) decodeImage(sd *types.StreamDict, objNr int, resources types.Dict, depth int) *image.RGBA {
+	if depth > 1 {
+		return nil
+	}
+
+	xRefTable := r.ctx.XRefTable
+	decode := numberArray(xRefTable, sd.Dict, "Decode")
+
+	var img *image.RGBA
+
+	sd1 := cloneStream(sd)
+	if i := r.extractImage(sd1, objNr); i != nil {
+		img = toRGBA(i)
+	} else {
+		img = samples(cloneStream(sd), r.colorSpace(sd.Dict["ColorSpace"], resources, 0), decode)
+	}
+	if img == nil || !img.Opaque() {
+		return img
+	}
+
+	// Apply a soft mask or stencil mask.
+	var alpha func(x, y int) uint8
+
+	if sm, _, err := xRefTable.DereferenceStreamDict(sd.Dict["SMask"]); err == nil && sm != nil {
+		if a := r.decodeImage(sm, 0, resources, depth+1); a != nil {
+			alpha = func(x, y int) uint8 {
+				return a.RGBAAt(x*a.Rect.Dx()/img.Rect.Dx(), y*a.Rect.Dy()/img.Rect.Dy()).R
+			}
+		}
+	} else if m, _, err := xRefTable.DereferenceStreamDict(sd.Dict["Mask"]); err == nil && m != nil {
+		if a := stencil(cloneStream(m), numberArray(xRefTable, m.Dict, "Decode")); a != nil {
+			// Mask samples painted by image masks are those shown by the image.
+			alpha = func(x, y int) uint8 {
+				return a.AlphaAt(x*a.Rect.Dx()/img.Rect.Dx(), y*a.Rect.Dy()/img.Rect.Dy()).A
+			}
+		}
+	}
+
+	if alpha != nil {
+		for y := 0; y < img.Rect.Dy(); y++ {
+			for x := 0; x < img.Rect.Dx(); x++ {
+				i := img.PixOffset(x, y)
+				a := uint32(alpha(x, y))
+				for j := 0; j < 4; j++ {
+					img.Pix[i+j] = uint8(uint32(img.Pix[i+j]) * a / 0xFF)
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// image returns the decoded image XObject sd.
+func (r *renderer) image(sd *types.StreamDict, objNr int, resources types.Dict) *decodedImage {
+	if objNr > 0 {
+		if di, ok := r.images[objNr]; ok {
+			return di
+		}
+	}
+
+	var di *decodedImage
+	if im := sd.BooleanEntry("ImageMask"); im != nil && *im {
+		if a := stencil(cloneStream(sd), numberArray(r.ctx.XRefTable, sd.Dict, "Decode")); a != nil {
+			di = &decodedImage{mask: a}
+		}
+	} else if img := r.decodeImage(sd, objNr, resources, 0); img != nil {
+		di = &decodedImage{img: img}
+	}
+
+	if objNr > 0 {
+		r.images[objNr] = di
+	}
+	return di
+}
+
+func (in *interpreter) drawImage(sd *types.StreamDict, objNr int, resources types.Dict) {
+	di := in.r.image(sd, objNr, resources)
+	if di == nil {
+		return
+	}
+	interpolate := false
+	if b := sd.BooleanEntry("Interpolate"); b != nil {
+		interpolate = *b
+	}
+	in.paintImage(di, interpolate, resources)
+}
+
+// Abbreviations used in inline image dicts.
+var (
+	inlineImageKeys = map[string]string{
+		"BPC": "BitsPerComponent",
+		"CS":  "ColorSpace",
+		"D":   "Decode",
+		"DP":  "DecodeParms",
+		"F":   "Filter",
+		"H":   "Height",
+		"IM":  "ImageMask",
+		"I":   "Interpolate",
+		"W":   "Width",
+	}
+	inlineImageNames = map[string]string{
+		"AHx":  filter.ASCIIHex,
+		"A85":  filter.ASCII85,
+		"LZW":  filter.LZW,
+		"Fl":   filter.Flate,
+		"RL":   filter.RunLength,
+		"CCF":  filter.CCITTFax,
+		"DCT":  filter.DCT,
+		"G":    "DeviceGray",
+		"RGB":  "DeviceRGB",
+		"CMYK": "DeviceCMYK",
+		"I":    "Indexed",
+	}
+)
+
+func expandInlineImageObject(o types.Object) types.Object {
+	switch o := o.(type) {
+	case types.Name:
+		if s, ok := inlineImageNames[o.Value()]; ok {
+			return types.Name(s)
+		}
+	case types.Array:
+		a := make(types.Array, len(o))
+		for i, o := range o {
+			a[i] = expandInlineImageObject(o)
+		}
+		return a
+	}
+	return o
+}
+
+func (in *interpreter) inlineImage(img *content.InlineImage, resources types.Dict) {
+	d := types.Dict{}
+	for k, v := range img.Dict {
+		if s, ok := inlineImageKeys[k]; ok {
+			k = s
+		}
+		d[k] = expandInlineImageObject(v)
+	}
+
+	// Resolve named color spaces.
+	if n, ok := d["ColorSpace"].(types.Name); ok && deviceColorSpace(n.Value()) == nil {
+		if o := in.r.resource(resources, "ColorSpace", n.Value()); o != nil {
+			d["ColorSpace"] = o
+		}
+	}
+
+	var pipeline []types.PDFFilter
+	var parms []types.Dict
+	switch p := d["DecodeParms"].(type) {
+	case types.Dict:
+		parms = []types.Dict{p}
+	case types.Array:
+		for _, o := range p {
+			dp, _ := o.(types.Dict)
+			parms = append(parms, dp)
+		}
+	}
+	var filters types.Array
+	switch f := d["Filter"].(type) {
+	case types.Name:
+		filters = types.Array{f}
+	case types.Array:
+		filters = f
+	}
+	for i, o := range filters {
+		n, ok := o.(types.Name)
+		if !ok {
+			return
+		}
+		f := types.PDFFilter{Name: n.Value()}
+		if i < len(parms) {
+			f.DecodeParms = parms[i]
+		}
+		pipeline = append(pipeline, f)
+	}
+
+	sd := types.NewStreamDict(d, 0, nil, nil, pipeline)
+	sd.Raw = img.Data
+
+	interpolate := false
+	if b, ok := d["Interpolate"].(types.Boolean); ok {
+		interpolate = b.Value()
+	}
+
+	if di := in.r.image(&sd, 0, resources); di != nil {
+		in.paintImage(di, interpolate, resources)
+	}
+}
+
+// doXObject paints the image or form XObject name.
+func (in *interpreter) doXObject(resources types.Dict, name string, depth int) {
+	indRef, ok := in.r.resource(resources, "XObject", name).(types.IndirectRef)
+	if !ok {
+		return
+	}
+	objNr := indRef.ObjectNumber.Value()
+
+	sd, _, err := in.r.ctx.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return
+	}
+
+	if oc, found := sd.Find("OC"); found && in.r.hiddenContent(oc) {
+		return
+	}
+
+	switch s := sd.Subtype(); {
+	case s == nil:
+	case *s == "Image":
+		in.drawImage(sd, objNr, resources)
+	case *s == "Form":
+		in.drawForm(sd, objNr, resources, depth)
+	}
+}
+
+// drawForm paints a form XObject.
+func (in *interpreter) drawForm(sd *types.StreamDict, objNr int, resources types.Dict, depth int) {
+	if depth >= maxFormDepth || in.r.forms[objNr] {
+		return
+	}
+	if err := sd.Decode(); err != nil {
+		return
+	}
+
+	xRefTable := in.r.ctx.XRefTable
+
+	formResources, _ := xRefTable.DereferenceDict(sd.Dict["Resources"])
+	if formResources == nil {
+		formResources = resources
+	}
+
+	saved, n, mc, hidden := in.gs, len(in.stack), len(in.mc), in.hidden
+
+	if m := numberArray(xRefTable, sd.Dict, "Matrix"); len(m) == 6 {
+		in.gs.ctm = newMatrix(m).Multiply(in.gs.ctm)
+	}
+	in.gs.base = in.gs.ctm
+
+	if bb := numberArray(xRefTable, sd.Dict, "BBox"); len(bb) == 4 {
+		var p path
+		p.rect(in.gs.ctm, bb[0], bb[1], bb[2]-bb[0], bb[3]-bb[1])
+		in.intersectClip(&p, false)
+	}
+
+	in.r.forms[objNr] = true
+	in.process(sd.Content, formResources, depth+1)
+	delete(in.r.forms, objNr)
+
+	in.gs, in.hidden, in.path = saved, hidden, path{}
+	if len(in.stack) > n {
+		in.stack = in.stack[:n]
+	}
+	if len(in.mc) > mc {
+		in.mc = in.mc[:mc]
+	}
+}
@@ -0,0 +1,580 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"image"
+	"math"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/content"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// Max. nesting level for form XObjects, patterns and Type 3 glyphs.
+const maxFormDepth = 16
+
+// Text rendering modes.
+const (
+	textFill = iota
+	textStroke
+	textFillStroke
+	textInvisible
+	textFillClip
+	textStrokeClip
+	textFillStrokeClip
+	textClip
+)
+
+type graphicsState struct {
+	ctm     matrix.Matrix
+	base    matrix.Matrix // maps pattern space to device space
+	clip    *clipRegion   // bounding box only for SVG output
+	svgClip string        // id of the SVG clipping path
+
+	fillCS, strokeCS           colorSpace
+	fillColor, strokeColor     []float64
+	fillPattern, strokePattern types.Object // current patterns for the Pattern color space
+
+	lineWidth  float64
+	lineCap    int
+	lineJoin   int
+	miterLimit float64
+	dash       []float64
+	dashPhase  float64
+
+	fillAlpha, strokeAlpha float64
+
+	font       *renderFont
+	fontSize   float64
+	charSpace  float64
+	wordSpace  float64
+	hScale     float64
+	leading    float64
+	rise       float64
+	renderMode int
+}
+
+// interpreter paints the content streams of a page, form, pattern or glyph onto dst
+// or writes them as SVG.
+type interpreter struct {
+	r         *renderer
+	dst       *image.RGBA
+	svg       *svgWriter // SVG output instead of dst
+	gs        graphicsState
+	stack     []graphicsState
+	path      path
+	clipMode  int // pending clipping operation: 0 none, 1 nonzero, 2 even-odd
+	tm, tlm   matrix.Matrix
+	textClip  path   // glyph outlines of text rendering modes adding to the clipping path
+	mc        []bool // marked content sequences, true for hidden optional content
+	hidden    int    // nesting level of hidden optional content
+	uncolored bool   // Type 3 glyph or uncolored tiling pattern, ignore color operators
+}
+
+func newGraphicsState(ctm matrix.Matrix, clip *clipRegion) graphicsState {
+	return graphicsState{
+		ctm:         ctm,
+		base:        ctm,
+		clip:        clip,
+		fillCS:      deviceGray{},
+		strokeCS:    deviceGray{},
+		fillColor:   []float64{0},
+		strokeColor: []float64{0},
+		lineWidth:   1,
+		miterLimit:  10,
+		fillAlpha:   1,
+		strokeAlpha: 1,
+		hScale:      1,
+	}
+}
+
+func (r *renderer) newInterpreter(dst *image.RGBA, ctm matrix.Matrix, clip *clipRegion) *interpreter {
+	return &interpreter{
+		r:   r,
+		dst: dst,
+		gs:  newGraphicsState(ctm, clip),
+		tm:  matrix.IdentMatrix,
+		tlm: matrix.IdentMatrix,
+	}
+}
+
+func number(o types.Object) float64 {
+	switch o := o.(type) {
+	case types.Integer:
+		return float64(o.Value())
+	case types.Float:
+		return o.Value()
+	}
+	return 0
+}
+
+func numberOperands(oo []types.Object, n int) ([]float64, bool) {
+	if len(oo) < n {
+		return nil, false
+	}
+	ff := make([]float64, n)
+	for i, o := range oo[len(oo)-n:] {
+		ff[i] = number(o)
+	}
+	return ff, true
+}
+
+func newMatrix(f []float64) matrix.Matrix {
+	return matrix.Matrix{{f[0], f[1], 0}, {f[2], f[3], 0}, {f[4], f[5], 1}}
+}
+
+func stringBytes(o types.Object) []byte {
+	switch o := o.(type) {
+	case types.StringLiteral:
+		bb, err := types.Unescape(o.Value())
+		if err != nil {
+			return nil
+		}
+		return bb
+	case types.HexLiteral:
+		bb, err := o.Bytes()
+		if err != nil {
+			return nil
+		}
+		return bb
+	}
+	return nil
+}
+
+func lastName(oo []types.Object) (string, bool) {
+	if len(oo) == 0 {
+		return "", false
+	}
+	n, ok := oo[len(oo)-1].(types.Name)
+	return n.Value(), ok
+}
+
+// scale returns the mean scale factor of m.
+func scale(m matrix.Matrix) float64 {
+	return math.Sqrt(math.Abs(m[0][0]*m[1][1] - m[0][1]*m[1][0]))
+}
+
+func (in *interpreter) point(x, y float64) point {
+	return transform(in.gs.ctm, point{x, y})
+}
+
+func (in *interpreter) processPathOp(op string, oo []types.Object) {
+	p := &in.path
+
+	switch op {
+
+	case "m":
+		if f, ok := numberOperands(oo, 2); ok {
+			p.moveTo(in.point(f[0], f[1]))
+		}
+
+	case "l":
+		if f, ok := numberOperands(oo, 2); ok {
+			p.lineTo(in.point(f[0], f[1]))
+		}
+
+	case "c":
+		if f, ok := numberOperands(oo, 6); ok {
+			p.curveTo(in.point(f[0], f[1]), in.point(f[2], f[3]), in.point(f[4], f[5]))
+		}
+
+	case "v":
+		if f, ok := numberOperands(oo, 4); ok {
+			if p0, ok := p.currentPoint(); ok {
+				p.curveTo(p0, in.point(f[0], f[1]), in.point(f[2], f[3]))
+			}
+		}
+
+	case "y":
+		if f, ok := numberOperands(oo, 4); ok {
+			p3 := in.point(f[2], f[3])
+			p.curveTo(in.point(f[0], f[1]), p3, p3)
+		}
+
+	case "h":
+		p.close()
+
+	case "re":
+		if f, ok := numberOperands(oo, 4); ok {
+			p.rect(in.gs.ctm, f[0], f[1], f[2], f[3])
+		}
+	}
+}
+
+func (in *interpreter) strokeStyle() strokeStyle {
+	gs := in.gs
+	s := scale(gs.ctm)
+	st := strokeStyle{
+		width:      gs.lineWidth * s,
+		cap:        gs.lineCap,
+		join:       gs.lineJoin,
+		miterLimit: gs.miterLimit,
+		phase:      gs.dashPhase * s,
+	}
+	var total float64
+	for _, d := range gs.dash {
+		st.dash = append(st.dash, d*s)
+		total += d * s
+	}
+	if total <= 0 {
+		st.dash = nil
+	}
+	return st
+}
+
+func (in *interpreter) fill(pls []polyline, evenOdd bool, resources types.Dict) {
+	if in.hidden > 0 || in.gs.clip.rect.Empty() {
+		return
+	}
+	p := in.paint(false, resources)
+	if p == nil {
+		return
+	}
+	composite(in.dst, rasterize(pls, evenOdd, in.gs.clip.rect), in.gs.clip, in.gs.fillAlpha, p)
+}
+
+func (in *interpreter) stroke(pls []polyline, resources types.Dict) {
+	if in.hidden > 0 || in.gs.clip.rect.Empty() {
+		return
+	}
+	p := in.paint(true, resources)
+	if p == nil {
+		return
+	}
+	a := rasterize(in.strokeStyle().stroke(pls), false, in.gs.clip.rect)
+	composite(in.dst, a, in.gs.clip, in.gs.strokeAlpha, p)
+}
+
+// intersectClip intersects the clipping region with the area enclosed by p.
+func (in *interpreter) intersectClip(p *path, evenOdd bool) {
+	if in.svg != nil {
+		in.svgIntersectClip(p, evenOdd)
+		return
+	}
+	in.gs.clip = in.gs.clip.intersect(p.flatten(), evenOdd)
+}
+
+func (in *interpreter) processPaintOp(op string, resources types.Dict) {
+	switch {
+	case op == "n":
+	case in.svg != nil:
+		in.svgPaint(op, resources)
+	default:
+		in.paintPath(op, in.path.flatten(), resources)
+	}
+
+	if in.clipMode > 0 {
+		in.intersectClip(&in.path, in.clipMode == 2)
+		in.clipMode = 0
+	}
+
+	in.path = path{}
+}
+
+func (in *interpreter) paintPath(op string, pls []polyline, resources types.Dict) {
+	switch op {
+	case "S":
+		in.stroke(pls, resources)
+	case "s":
+		for i := range pls {
+			pls[i].closed = true
+		}
+		in.stroke(pls, resources)
+	case "f", "F":
+		in.fill(pls, false, resources)
+	case "f*":
+		in.fill(pls, true, resources)
+	case "B", "B*", "b", "b*":
+		if op == "b" || op == "b*" {
+			for i := range pls {
+				pls[i].closed = true
+			}
+		}
+		in.fill(pls, op == "B*" || op == "b*", resources)
+		in.stroke(pls, resources)
+	}
+}
+
+func (in *interpreter) setColorSpace(stroke bool, name string, resources types.Dict) {
+	cs := in.r.colorSpace(types.Name(name), resources, 0)
+	if stroke {
+		in.gs.strokeCS, in.gs.strokeColor, in.gs.strokePattern = cs, cs.initialColor(), nil
+		return
+	}
+	in.gs.fillCS, in.gs.fillColor, in.gs.fillPattern = cs, cs.initialColor(), nil
+}
+
+func (in *interpreter) setColor(stroke bool, oo []types.Object, resources types.Dict) {
+	var pattern types.Object
+	if n, ok := lastName(oo); ok {
+		pattern = in.r.resource(resources, "Pattern", n)
+		oo = oo[:len(oo)-1]
+	}
+
+	c := make([]float64, len(oo))
+	for i, o := range oo {
+		c[i] = number(o)
+	}
+
+	if stroke {
+		in.gs.strokeColor, in.gs.strokePattern = c, pattern
+		return
+	}
+	in.gs.fillColor, in.gs.fillPattern = c, pattern
+}
+
+func (in *interpreter) setDeviceColor(stroke bool, cs colorSpace, oo []types.Object) {
+	c, ok := numberOperands(oo, cs.components())
+	if !ok {
+		return
+	}
+	if stroke {
+		in.gs.strokeCS, in.gs.strokeColor, in.gs.strokePattern = cs, c, nil
+		return
+	}
+	in.gs.fillCS, in.gs.fillColor, in.gs.fillPattern = cs, c, nil
+}
+
+func (in *interpreter) processColorOp(op string, oo []types.Object, resources types.Dict) {
+	if in.uncolored {
+		return
+	}
+
+	switch op {
+
+	case "CS", "cs":
+		if n, ok := lastName(oo); ok {
+			in.setColorSpace(op == "CS", n, resources)
+		}
+
+	case "SC", "SCN", "sc", "scn":
+		in.setColor(op == "SC" || op == "SCN", oo, resources)
+
+	case "G", "g":
+		in.setDeviceColor(op == "G", deviceGray{}, oo)
+
+	case "RG", "rg":
+		in.setDeviceColor(op == "RG", deviceRGB{}, oo)
+
+	case "K", "k":
+		in.setDeviceColor(op == "K", deviceCMYK{}, oo)
+	}
+}
+
+func (in *interpreter) setDash(o types.Object, phase float64) {
+	a, err := in.r.ctx.DereferenceArray(o)
+	if err != nil {
+		return
+	}
+	in.gs.dash = nil
+	for _, o := range a {
+		if o, err := in.r.ctx.Dereference(o); err == nil {
+			in.gs.dash = append(in.gs.dash, number(o))
+		}
+	}
+	in.gs.dashPhase = phase
+}
+
+func (in *interpreter) setExtGState(resources types.Dict, name string) {
+	xRefTable := in.r.ctx.XRefTable
+
+	d, err := xRefTable.DereferenceDict(in.r.resource(resources, "ExtGState", name))
+	if err != nil || d == nil {
+		return
+	}
+
+	num := func(k string) (float64, bool) {
+		o, found := d.Find(k)
+		if !found {
+			return 0, false
+		}
+		f, err := xRefTable.DereferenceNumber(o)
+		return f, err == nil
+	}
+
+	if f, ok := num("LW"); ok {
+		in.gs.lineWidth = f
+	}
+	if f, ok := num("LC"); ok {
+		in.gs.lineCap = int(f)
+	}
+	if f, ok := num("LJ"); ok {
+		in.gs.lineJoin = int(f)
+	}
+	if f, ok := num("ML"); ok {
+		in.gs.miterLimit = f
+	}
+	if f, ok := num("CA"); ok {
+		in.gs.strokeAlpha = clip(f, 0, 1)
+	}
+	if f, ok := num("ca"); ok {
+		in.gs.fillAlpha = clip(f, 0, 1)
+	}
+	if a, err := xRefTable.DereferenceArray(d["D"]); err == nil && len(a) == 2 {
+		phase, _ := xRefTable.DereferenceNumber(a[1])
+		in.setDash(a[0], phase)
+	}
+	if a, err := xRefTable.DereferenceArray(d["Font"]); err == nil && len(a) == 2 {
+		in.gs.font = in.r.font(a[0])
+		in.gs.fontSize, _ = xRefTable.DereferenceNumber(a[1])
+	}
+}
+
+func (in *interpreter) processStateOp(op string, oo []types.Object, resources types.Dict) {
+	switch op {
+
+	case "q":
+		in.stack = append(in.stack, in.gs)
+
+	case "Q":
+		if n := len(in.stack); n > 0 {
+			in.gs = in.stack[n-1]
+			in.stack = in.stack[:n-1]
+		}
+
+	case "cm":
+		if f, ok := numberOperands(oo, 6); ok {
+			in.gs.ctm = newMatrix(f).Multiply(in.gs.ctm)
+		}
+
+	case "w":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.lineWidth = f[0]
+		}
+
+	case "J":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.lineCap = int(f[0])
+		}
+
+	case "j":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.lineJoin = int(f[0])
+		}
+
+	case "M":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.miterLimit = f[0]
+		}
+
+	case "d":
+		if len(oo) >= 2 {
+			in.setDash(oo[len(oo)-2], number(oo[len(oo)-1]))
+		}
+
+	case "gs":
+		if n, ok := lastName(oo); ok {
+			in.setExtGState(resources, n)
+		}
+	}
+}
+
+func (in *interpreter) beginMarkedContent(op string, oo []types.Object, resources types.Dict) {
+	hidden := false
+	if op == "BDC" && len(oo) >= 2 {
+		if tag, ok := oo[len(oo)-2].(types.Name); ok && tag.Value() == "OC" {
+			o := oo[len(oo)-1]
+			if n, ok := o.(types.Name); ok {
+				o = in.r.resource(resources, "Properties", n.Value())
+			}
+			hidden = o != nil && in.r.hiddenContent(o)
+		}
+	}
+	in.mc = append(in.mc, hidden)
+	if hidden {
+		in.hidden++
+	}
+}
+
+func (in *interpreter) endMarkedContent() {
+	n := len(in.mc)
+	if n == 0 {
+		return
+	}
+	if in.mc[n-1] {
+		in.hidden--
+	}
+	in.mc = in.mc[:n-1]
+}
+
+// process interprets the content stream bb using resources.
+func (in *interpreter) process(bb []byte, resources types.Dict, depth int) error {
+	ops, err := content.Parse(bb)
+	if err != nil {
+		return errors.Wrap(err, "pdfcpu: render")
+	}
+
+	for _, o := range ops {
+		op, oo := o.Operator, o.Operands
+
+		switch op {
+
+		case "q", "Q", "cm", "w", "J", "j", "M", "d", "gs":
+			in.processStateOp(op, oo, resources)
+
+		case "m", "l", "c", "v", "y", "h", "re":
+			in.processPathOp(op, oo)
+
+		case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*", "n":
+			in.processPaintOp(op, resources)
+
+		case "d1":
+			// Glyph procedures of uncolored Type 3 glyphs must not change colors.
+			in.uncolored = true
+
+		case "W":
+			in.clipMode = 1
+
+		case "W*":
+			in.clipMode = 2
+
+		case "CS", "cs", "SC", "SCN", "sc", "scn", "G", "g", "RG", "rg", "K", "k":
+			in.processColorOp(op, oo, resources)
+
+		case "sh":
+			if n, ok := lastName(oo); ok && in.hidden == 0 {
+				in.shade(in.r.resource(resources, "Shading", n))
+			}
+
+		case "Do":
+			if n, ok := lastName(oo); ok && in.hidden == 0 {
+				in.doXObject(resources, n, depth)
+			}
+
+		case "BI":
+			if o.Image != nil && in.hidden == 0 {
+				in.inlineImage(o.Image, resources)
+			}
+
+		case "BMC", "BDC":
+			in.beginMarkedContent(op, oo, resources)
+
+		case "EMC":
+			in.endMarkedContent()
+
+		case "Tf", "Tc", "Tw", "Tz", "TL", "Ts", "Tr":
+			in.processTextStateOp(op, oo, resources)
+
+		case "BT", "ET", "Td", "TD", "Tm", "T*", "Tj", "'", "\"", "TJ":
+			in.processTextOp(op, oo, resources, depth)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,385 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+const (
+	patchSteps   = 16      // subdivisions per patch dimension
+	maxTriangles = 1 << 20 // max. number of triangles of a mesh
+)
+
+type meshVertex struct {
+	p point
+	c []float64
+}
+
+// mesh is a free-form, lattice-form, Coons or tensor-product patch mesh shading
+// approximated by Gouraud-shaded triangles in shading space.
+type mesh struct {
+	triangles [][3]meshVertex
+}
+
+// bitReader reads the big-endian bit fields of mesh data.
+type bitReader struct {
+	bb   []byte
+	pos  int // bit position
+	fail bool
+}
+
+func (r *bitReader) read(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		byteNr := r.pos / 8
+		if byteNr >= len(r.bb) {
+			r.fail = true
+			return 0
+		}
+		bit := (r.bb[byteNr] >> (7 - uint(r.pos%8))) & 1
+		v = v<<1 | uint64(bit)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) align() {
+	r.pos = (r.pos + 7) &^ 7
+}
+
+func (r *bitReader) done() bool {
+	return r.fail || r.pos/8 >= len(r.bb)
+}
+
+// meshReader decodes vertices according to the mesh shading dict.
+type meshReader struct {
+	bitReader
+	bpc, bpf, bpcomp int
+	decode           []float64
+	n                int // color components
+}
+
+func (r *meshReader) value(bits int, min, max float64) float64 {
+	v := float64(r.read(bits))
+	return min + v*(max-min)/(math.Exp2(float64(bits))-1)
+}
+
+func (r *meshReader) flag() int {
+	return int(r.read(r.bpf))
+}
+
+func (r *meshReader) point() point {
+	x := r.value(r.bpc, r.decode[0], r.decode[1])
+	y := r.value(r.bpc, r.decode[2], r.decode[3])
+	return point{x, y}
+}
+
+func (r *meshReader) color() []float64 {
+	c := make([]float64, r.n)
+	for i := range c {
+		c[i] = r.value(r.bpcomp, r.decode[4+2*i], r.decode[5+2*i])
+	}
+	return c
+}
+
+func (r *meshReader) vertex() meshVertex {
+	p := r.point()
+	return meshVertex{p: p, c: r.color()}
+}
+
+func newMeshReader(xRefTable *model.XRefTable, sd *types.StreamDict, n int) *meshReader {
+	if err := sd.Decode(); err != nil {
+		return nil
+	}
+
+	r := &meshReader{bitReader: bitReader{bb: sd.Content}, n: n}
+	for k, p := range map[string]*int{"BitsPerCoordinate": &r.bpc, "BitsPerComponent": &r.bpcomp, "BitsPerFlag": &r.bpf} {
+		if i := sd.IntEntry(k); i != nil {
+			*p = *i
+		}
+	}
+	if r.bpc < 1 || r.bpc > 32 || r.bpcomp < 1 || r.bpcomp > 16 {
+		return nil
+	}
+
+	r.decode = numberArray(xRefTable, sd.Dict, "Decode")
+	if len(r.decode) < 4+2*n {
+		return nil
+	}
+
+	return r
+}
+
+func (m *mesh) add(a, b, c meshVertex) {
+	if len(m.triangles) < maxTriangles {
+		m.triangles = append(m.triangles, [3]meshVertex{a, b, c})
+	}
+}
+
+func (m *mesh) freeForm(r *meshReader) {
+	var va, vb, vc meshVertex
+	for !r.done() {
+		f := r.flag()
+		v := r.vertex()
+		r.align()
+		if r.fail {
+			return
+		}
+		switch f {
+		case 0:
+			va = v
+			r.flag()
+			vb = r.vertex()
+			r.align()
+			r.flag()
+			vc = r.vertex()
+			r.align()
+			if r.fail {
+				return
+			}
+		case 1:
+			va, vb, vc = vb, vc, v
+		case 2:
+			vb, vc = vc, v
+		}
+		m.add(va, vb, vc)
+	}
+}
+
+func (m *mesh) lattice(r *meshReader, perRow int) {
+	if perRow < 2 {
+		return
+	}
+	var prev []meshVertex
+	for !r.done() {
+		row := make([]meshVertex, perRow)
+		for i := range row {
+			row[i] = r.vertex()
+		}
+		if r.fail {
+			return
+		}
+		for i := 0; prev != nil && i+1 < perRow; i++ {
+			m.add(prev[i], prev[i+1], row[i])
+			m.add(prev[i+1], row[i+1], row[i])
+		}
+		prev = row
+	}
+}
+
+// patch is a tensor-product patch, p[i][j] and the corner colors c00, c03, c33, c30.
+type patch struct {
+	p [4][4]point
+	c [4][]float64
+}
+
+// boundary returns the control points in the order used by the shading data.
+func (pt *patch) boundary() [12]point {
+	p := pt.p
+	return [12]point{p[0][0], p[0][1], p[0][2], p[0][3], p[1][3], p[2][3], p[3][3], p[3][2], p[3][1], p[3][0], p[2][0], p[1][0]}
+}
+
+func (pt *patch) setBoundary(b [12]point) {
+	p := &pt.p
+	p[0][0], p[0][1], p[0][2], p[0][3], p[1][3], p[2][3] = b[0], b[1], b[2], b[3], b[4], b[5]
+	p[3][3], p[3][2], p[3][1], p[3][0], p[2][0], p[1][0] = b[6], b[7], b[8], b[9], b[10], b[11]
+}
+
+// coonsInterior sets the interior control points of a Coons patch.
+func (pt *patch) coonsInterior() {
+	p := &pt.p
+	in := func(a, b1, b2, c1, c2, d1, d2, e point) point {
+		return a.mul(-4).add(b1.add(b2).mul(6)).add(c1.add(c2).mul(-2)).add(d1.add(d2).mul(3)).add(e.mul(-1)).mul(1. / 9)
+	}
+	p[1][1] = in(p[0][0], p[0][1], p[1][0], p[0][3], p[3][0], p[3][1], p[1][3], p[3][3])
+	p[1][2] = in(p[0][3], p[0][2], p[1][3], p[0][0], p[3][3], p[3][2], p[1][0], p[3][0])
+	p[2][1] = in(p[3][0], p[3][1], p[2][0], p[3][3], p[0][0], p[0][1], p[2][3], p[0][3])
+	p[2][2] = in(p[3][3], p[3][2], p[2][3], p[3][0], p[0][3], p[0][2], p[2][0], p[0][0])
+}
+
+func bernstein(t float64) [4]float64 {
+	s := 1 - t
+	return [4]float64{s * s * s, 3 * t * s * s, 3 * t * t * s, t * t * t}
+}
+
+func (pt *patch) vertex(u, v float64) meshVertex {
+	bu, bv := bernstein(u), bernstein(v)
+	var q point
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			q = q.add(pt.p[i][j].mul(bu[i] * bv[j]))
+		}
+	}
+	c := make([]float64, len(pt.c[0]))
+	for k := range c {
+		c[k] = (1-u)*(1-v)*pt.c[0][k] + (1-u)*v*pt.c[1][k] + u*v*pt.c[2][k] + u*(1-v)*pt.c[3][k]
+	}
+	return meshVertex{p: q, c: c}
+}
+
+func (m *mesh) addPatch(pt *patch) {
+	var grid [patchSteps + 1][patchSteps + 1]meshVertex
+	for i := 0; i <= patchSteps; i++ {
+		for j := 0; j <= patchSteps; j++ {
+			grid[i][j] = pt.vertex(float64(i)/patchSteps, float64(j)/patchSteps)
+		}
+	}
+	for i := 0; i < patchSteps; i++ {
+		for j := 0; j < patchSteps; j++ {
+			m.add(grid[i][j], grid[i+1][j], grid[i][j+1])
+			m.add(grid[i+1][j], grid[i+1][j+1], grid[i][j+1])
+		}
+	}
+}
+
+func (m *mesh) patches(r *meshReader, tensor bool) {
+	var prev *patch
+	for !r.done() {
+		f := r.flag()
+		pt := &patch{}
+		var b [12]point
+		first := 0
+
+		if f != 0 && prev != nil {
+			pb := prev.boundary()
+			switch f {
+			case 1:
+				copy(b[:4], pb[3:7])
+				pt.c[0], pt.c[1] = prev.c[1], prev.c[2]
+			case 2:
+				copy(b[:4], pb[6:10])
+				pt.c[0], pt.c[1] = prev.c[2], prev.c[3]
+			default:
+				b[0], b[1], b[2], b[3] = pb[9], pb[10], pb[11], pb[0]
+				pt.c[0], pt.c[1] = prev.c[3], prev.c[0]
+			}
+			first = 4
+		}
+
+		for i := first; i < 12; i++ {
+			b[i] = r.point()
+		}
+		pt.setBoundary(b)
+		if tensor {
+			pt.p[1][1], pt.p[1][2], pt.p[2][2], pt.p[2][1] = r.point(), r.point(), r.point(), r.point()
+		}
+		for i := first / 2; i < 4; i++ {
+			pt.c[i] = r.color()
+		}
+		if r.fail {
+			return
+		}
+		if !tensor {
+			pt.coonsInterior()
+		}
+
+		m.addPatch(pt)
+		prev = pt
+	}
+}
+
+func loadMesh(xRefTable *model.XRefTable, sd *types.StreamDict, kind, n int) *mesh {
+	r := newMeshReader(xRefTable, sd, n)
+	if r == nil {
+		return nil
+	}
+
+	m := &mesh{}
+	switch kind {
+	case 4:
+		m.freeForm(r)
+	case 5:
+		perRow := 0
+		if i := sd.IntEntry("VerticesPerRow"); i != nil {
+			perRow = *i
+		}
+		m.lattice(r, perRow)
+	case 6, 7:
+		m.patches(r, kind == 7)
+	}
+	return m
+}
+
+// render paints the mesh of sh transformed by ctm into a layer covering rect.
+func (m *mesh) render(sh *shading, ctm matrix.Matrix, rect image.Rectangle) *image.RGBA {
+	layer := image.NewRGBA(rect)
+
+	for _, t := range m.triangles {
+		var v [3]meshVertex
+		for i := range t {
+			v[i] = meshVertex{p: transform(ctm, t[i].p), c: t[i].c}
+		}
+		sh.fillTriangle(layer, v)
+	}
+
+	return layer
+}
+
+// fillTriangle paints a Gouraud-shaded triangle sampled at pixel centers.
+func (sh *shading) fillTriangle(dst *image.RGBA, v [3]meshVertex) {
+	p0, p1, p2 := v[0].p, v[1].p, v[2].p
+	det := (p1.x-p0.x)*(p2.y-p0.y) - (p2.x-p0.x)*(p1.y-p0.y)
+	if math.Abs(det) < 1e-12 {
+		return
+	}
+
+	r := image.Rect(
+		clampInt(math.Floor(math.Min(p0.x, math.Min(p1.x, p2.x)))),
+		clampInt(math.Floor(math.Min(p0.y, math.Min(p1.y, p2.y)))),
+		clampInt(math.Ceil(math.Max(p0.x, math.Max(p1.x, p2.x))))+1,
+		clampInt(math.Ceil(math.Max(p0.y, math.Max(p1.y, p2.y))))+1,
+	).Intersect(dst.Rect)
+
+	n := len(v[0].c)
+	c := make([]float64, n)
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		py := float64(y) + 0.5
+		for x := r.Min.X; x < r.Max.X; x++ {
+			px := float64(x) + 0.5
+			l1 := ((px-p0.x)*(p2.y-p0.y) - (p2.x-p0.x)*(py-p0.y)) / det
+			l2 := ((p1.x-p0.x)*(py-p0.y) - (px-p0.x)*(p1.y-p0.y)) / det
+			l0 := 1 - l1 - l2
+			if l0 < -1e-9 || l1 < -1e-9 || l2 < -1e-9 {
+				continue
+			}
+			for k := 0; k < n && k < len(v[1].c) && k < len(v[2].c); k++ {
+				c[k] = l0*v[0].c[k] + l1*v[1].c[k] + l2*v[2].c[k]
+			}
+			dst.SetRGBA(x, y, sh.meshColor(c))
+		}
+	}
+}
+
+// meshColor returns the color for interpolated vertex colors c.
+func (sh *shading) meshColor(c []float64) color.RGBA {
+	if sh.fn == nil || len(sh.lut) == 0 || len(c) == 0 {
+		return sh.color(c)
+	}
+	t := 0.
+	if d := sh.domain[1] - sh.domain[0]; d != 0 {
+		t = clip((c[0]-sh.domain[0])/d, 0, 1)
+	}
+	return sh.lut[int(t*float64(shadingSteps-1)+0.5)]
+}
@@ -0,0 +1,755 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+const (
+	subSamples  = 4    // sub scanlines per pixel row
+	flatness    = 0.1  // max. deviation of flattened curves in device pixels
+	maxCurveSeg = 500  // max. line segments per flattened curve
+	minStroke   = 1.0  // min. stroke width in device pixels
+	circleSteps = 0.75 // max. deviation of circle polygons in device pixels
+)
+
+// Path construction operations.
+const (
+	moveTo byte = iota
+	lineTo
+	curveTo
+	closePath
+)
+
+type point struct {
+	x, y float64
+}
+
+func (p point) add(q point) point {
+	return point{p.x + q.x, p.y + q.y}
+}
+
+func (p point) sub(q point) point {
+	return point{p.x - q.x, p.y - q.y}
+}
+
+func (p point) mul(f float64) point {
+	return point{p.x * f, p.y * f}
+}
+
+func (p point) len() float64 {
+	return math.Hypot(p.x, p.y)
+}
+
+func transform(m matrix.Matrix, p point) point {
+	q := m.Transform(types.Point{X: p.x, Y: p.y})
+	return point{q.X, q.Y}
+}
+
+// path is a sequence of path construction operations.
+type path struct {
+	ops []byte
+	pts []point // one point per moveTo and lineTo, three points per curveTo
+}
+
+func (p *path) empty() bool {
+	return len(p.ops) == 0
+}
+
+func (p *path) moveTo(pt point) {
+	p.ops = append(p.ops, moveTo)
+	p.pts = append(p.pts, pt)
+}
+
+func (p *path) lineTo(pt point) {
+	if len(p.ops) == 0 {
+		p.moveTo(pt)
+		return
+	}
+	p.ops = append(p.ops, lineTo)
+	p.pts = append(p.pts, pt)
+}
+
+func (p *path) curveTo(p1, p2, p3 point) {
+	if len(p.ops) == 0 {
+		p.moveTo(p1)
+	}
+	p.ops = append(p.ops, curveTo)
+	p.pts = append(p.pts, p1, p2, p3)
+}
+
+// quadTo appends a quadratic Bézier curve as the equivalent cubic curve.
+func (p *path) quadTo(p1, p2 point) {
+	p0, ok := p.currentPoint()
+	if !ok {
+		p.moveTo(p1)
+		p0 = p1
+	}
+	c1 := p0.add(p1.sub(p0).mul(2. / 3))
+	c2 := p2.add(p1.sub(p2).mul(2. / 3))
+	p.curveTo(c1, c2, p2)
+}
+
+func (p *path) close() {
+	if len(p.ops) > 0 && p.ops[len(p.ops)-1] != closePath {
+		p.ops = append(p.ops, closePath)
+	}
+}
+
+func (p *path) rect(m matrix.Matrix, x, y, w, h float64) {
+	p.moveTo(transform(m, point{x, y}))
+	p.lineTo(transform(m, point{x + w, y}))
+	p.lineTo(transform(m, point{x + w, y + h}))
+	p.lineTo(transform(m, point{x, y + h}))
+	p.close()
+}
+
+// currentPoint returns the last point of p taking closed subpaths into account.
+func (p *path) currentPoint() (point, bool) {
+	if len(p.pts) == 0 {
+		return point{}, false
+	}
+	if p.ops[len(p.ops)-1] == closePath {
+		return p.subpathStart(), true
+	}
+	return p.pts[len(p.pts)-1], true
+}
+
+func (p *path) subpathStart() point {
+	j := len(p.pts)
+	for i := len(p.ops) - 1; i >= 0; i-- {
+		switch p.ops[i] {
+		case moveTo:
+			return p.pts[j-1]
+		case lineTo:
+			j--
+		case curveTo:
+			j -= 3
+		}
+	}
+	return point{}
+}
+
+// append appends q transformed by m to p.
+func (p *path) append(q *path, m matrix.Matrix) {
+	p.ops = append(p.ops, q.ops...)
+	for _, pt := range q.pts {
+		p.pts = append(p.pts, transform(m, pt))
+	}
+}
+
+// polyline is a flattened subpath.
+type polyline struct {
+	pts    []point
+	closed bool
+}
+
+func flattenCurve(pts []point, p0, p1, p2, p3 point) []point {
+	d1 := p0.sub(p1.mul(2)).add(p2).len()
+	d2 := p1.sub(p2.mul(2)).add(p3).len()
+	n := int(math.Ceil(math.Sqrt(0.75 * math.Max(d1, d2) / flatness)))
+	if n < 1 {
+		n = 1
+	}
+	if n > maxCurveSeg {
+		n = maxCurveSeg
+	}
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		pts = append(pts, point{
+			a*p0.x + b*p1.x + c*p2.x + d*p3.x,
+			a*p0.y + b*p1.y + c*p2.y + d*p3.y,
+		})
+	}
+	return pts
+}
+
+// flatten returns the subpaths of p as polylines.
+func (p *path) flatten() []polyline {
+	var (
+		pls []polyline
+		cur *polyline
+	)
+
+	j := 0
+	for _, op := range p.ops {
+		switch op {
+
+		case moveTo:
+			pls = append(pls, polyline{pts: []point{p.pts[j]}})
+			cur = &pls[len(pls)-1]
+			j++
+
+		case lineTo:
+			cur.pts = append(cur.pts, p.pts[j])
+			j++
+
+		case curveTo:
+			p0 := cur.pts[len(cur.pts)-1]
+			cur.pts = flattenCurve(cur.pts, p0, p.pts[j], p.pts[j+1], p.pts[j+2])
+			j += 3
+
+		case closePath:
+			if cur != nil {
+				cur.closed = true
+				// A new subpath starts at the same point.
+				pls = append(pls, polyline{pts: []point{cur.pts[0]}})
+				cur = &pls[len(pls)-1]
+			}
+		}
+	}
+
+	// Drop single point subpaths resulting from closePath.
+	res := pls[:0]
+	for i, pl := range pls {
+		if len(pl.pts) == 1 && !pl.closed && i > 0 && pls[i-1].closed {
+			continue
+		}
+		res = append(res, pl)
+	}
+
+	return res
+}
+
+func bounds(pls []polyline) (point, point, bool) {
+	min := point{math.Inf(1), math.Inf(1)}
+	max := point{math.Inf(-1), math.Inf(-1)}
+	for _, pl := range pls {
+		for _, p := range pl.pts {
+			min.x, min.y = math.Min(min.x, p.x), math.Min(min.y, p.y)
+			max.x, max.y = math.Max(max.x, p.x), math.Max(max.y, p.y)
+		}
+	}
+	return min, max, min.x <= max.x
+}
+
+func clampInt(f float64) int {
+	if f < -1e6 {
+		return -1e6
+	}
+	if f > 1e6 {
+		return 1e6
+	}
+	return int(f)
+}
+
+type edge struct {
+	x0, y0, y1 float64
+	dxdy       float64
+	dir        int
+}
+
+type crossing struct {
+	x   float64
+	dir int
+}
+
+func edges(pls []polyline) []edge {
+	var ee []edge
+	for _, pl := range pls {
+		n := len(pl.pts)
+		for i := 0; i < n; i++ {
+			// Every subpath is implicitly closed for filling.
+			p, q := pl.pts[i], pl.pts[(i+1)%n]
+			if p.y == q.y || math.IsNaN(p.y) || math.IsNaN(q.y) {
+				continue
+			}
+			dir := 1
+			if p.y > q.y {
+				p, q, dir = q, p, -1
+			}
+			ee = append(ee, edge{x0: p.x, y0: p.y, y1: q.y, dxdy: (q.x - p.x) / (q.y - p.y), dir: dir})
+		}
+	}
+	sort.Slice(ee, func(i, j int) bool { return ee[i].y0 < ee[j].y0 })
+	return ee
+}
+
+// addSpan adds the coverage of the horizontal span x0..x1 of a sub scanline to acc.
+func addSpan(acc []float32, x0, x1 float64) {
+	const w = 1. / subSamples
+	n := float64(len(acc))
+	x0, x1 = math.Max(x0, 0), math.Min(x1, n)
+	if x0 >= x1 {
+		return
+	}
+	i0, i1 := int(x0), int(x1)
+	if i0 == i1 {
+		acc[i0] += float32((x1 - x0) * w)
+		return
+	}
+	acc[i0] += float32((float64(i0+1) - x0) * w)
+	for i := i0 + 1; i < i1; i++ {
+		acc[i] += w
+	}
+	if i1 < len(acc) {
+		acc[i1] += float32((x1 - float64(i1)) * w)
+	}
+}
+
+// rasterize returns the coverage of the area enclosed by pls within bounds r
+// according to the nonzero winding number or even-odd rule.
+func rasterize(pls []polyline, evenOdd bool, r image.Rectangle) *image.Alpha {
+	min, max, ok := bounds(pls)
+	if !ok {
+		return nil
+	}
+
+	r = r.Intersect(image.Rect(
+		clampInt(math.Floor(min.x)), clampInt(math.Floor(min.y)),
+		clampInt(math.Ceil(max.x)), clampInt(math.Ceil(max.y))))
+	if r.Empty() {
+		return nil
+	}
+
+	ee := edges(pls)
+	if len(ee) == 0 {
+		return nil
+	}
+
+	a := image.NewAlpha(r)
+	acc := make([]float32, r.Dx())
+
+	var (
+		active []*edge
+		xs     []crossing
+	)
+	next := 0
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for i := range acc {
+			acc[i] = 0
+		}
+
+		for s := 0; s < subSamples; s++ {
+			sy := float64(y) + (float64(s)+0.5)/subSamples
+
+			for next < len(ee) && ee[next].y0 <= sy {
+				active = append(active, &ee[next])
+				next++
+			}
+
+			xs = xs[:0]
+			j := 0
+			for _, e := range active {
+				if e.y1 <= sy {
+					continue
+				}
+				active[j] = e
+				j++
+				xs = append(xs, crossing{x: e.x0 + (sy-e.y0)*e.dxdy - float64(r.Min.X), dir: e.dir})
+			}
+			active = active[:j]
+
+			// Insertion sort, crossings are mostly in order.
+			for i := 1; i < len(xs); i++ {
+				for k := i; k > 0 && xs[k].x < xs[k-1].x; k-- {
+					xs[k], xs[k-1] = xs[k-1], xs[k]
+				}
+			}
+
+			wind := 0
+			for i := 0; i < len(xs)-1; i++ {
+				wind += xs[i].dir
+				inside := wind != 0
+				if evenOdd {
+					inside = wind%2 != 0
+				}
+				if inside {
+					addSpan(acc, xs[i].x, xs[i+1].x)
+				}
+			}
+		}
+
+		row := a.Pix[(y-r.Min.Y)*a.Stride:]
+		for i, v := range acc {
+			if v >= 1 {
+				row[i] = 0xFF
+				continue
+			}
+			row[i] = uint8(v*0xFF + 0.5)
+		}
+	}
+
+	return a
+}
+
+// clipRegion is the intersection of all clipping paths in device space.
+type clipRegion struct {
+	rect image.Rectangle
+	mask *image.Alpha // nil for rectangular regions, bounds equal rect otherwise
+}
+
+func (c *clipRegion) alphaAt(x, y int) uint32 {
+	if c.mask == nil {
+		return 0xFF
+	}
+	return uint32(c.mask.Pix[(y-c.rect.Min.Y)*c.mask.Stride+x-c.rect.Min.X])
+}
+
+// axisAlignedRect returns the device space rectangle for pls consisting of a single axis aligned rectangle.
+func axisAlignedRect(pls []polyline) (image.Rectangle, bool) {
+	if len(pls) != 1 {
+		return image.Rectangle{}, false
+	}
+	pts := pls[0].pts
+	if len(pts) == 5 && pts[4] == pts[0] {
+		pts = pts[:4]
+	}
+	if len(pts) != 4 {
+		return image.Rectangle{}, false
+	}
+	for i := range pts {
+		p, q := pts[i], pts[(i+1)%4]
+		if math.Abs(p.x-q.x) > 1e-3 && math.Abs(p.y-q.y) > 1e-3 {
+			return image.Rectangle{}, false
+		}
+	}
+	min, max, _ := bounds(pls)
+	return image.Rect(
+		clampInt(math.Round(min.x)), clampInt(math.Round(min.y)),
+		clampInt(math.Round(max.x)), clampInt(math.Round(max.y))), true
+}
+
+// intersect returns the intersection of c with the area enclosed by pls.
+func (c *clipRegion) intersect(pls []polyline, evenOdd bool) *clipRegion {
+	if r, ok := axisAlignedRect(pls); ok {
+		c1 := &clipRegion{rect: c.rect.Intersect(r)}
+		if c.mask != nil && !c1.rect.Empty() {
+			c1.mask = c.mask.SubImage(c1.rect).(*image.Alpha)
+		}
+		return c1
+	}
+
+	a := rasterize(pls, evenOdd, c.rect)
+	if a == nil {
+		return &clipRegion{}
+	}
+
+	if c.mask != nil {
+		r := a.Rect
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				i := a.PixOffset(x, y)
+				a.Pix[i] = uint8(uint32(a.Pix[i]) * c.alphaAt(x, y) / 0xFF)
+			}
+		}
+	}
+
+	return &clipRegion{rect: a.Rect, mask: a}
+}
+
+// paint provides the colors for compositing.
+type paint interface {
+	// at returns the premultiplied color for device pixel x,y.
+	at(x, y int) color.RGBA
+}
+
+type uniformPaint color.RGBA
+
+func (p uniformPaint) at(x, y int) color.RGBA {
+	return color.RGBA(p)
+}
+
+// composite blends p into dst using the coverage a, the clip region c and the constant alpha.
+func composite(dst *image.RGBA, a *image.Alpha, c *clipRegion, alpha float64, p paint) {
+	if a == nil {
+		return
+	}
+	r := a.Rect.Intersect(c.rect).Intersect(dst.Rect)
+	ca := uint32(alpha*0xFF + 0.5)
+	if ca == 0 {
+		return
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			m := uint32(a.Pix[a.PixOffset(x, y)])
+			if m == 0 {
+				continue
+			}
+			m = m * c.alphaAt(x, y) / 0xFF * ca / 0xFF
+			if m == 0 {
+				continue
+			}
+			s := p.at(x, y)
+			i := dst.PixOffset(x, y)
+			d := dst.Pix[i : i+4 : i+4]
+			sa := uint32(s.A) * m / 0xFF
+			inv := 0xFF - sa
+			d[0] = uint8((uint32(s.R)*m + uint32(d[0])*inv) / 0xFF)
+			d[1] = uint8((uint32(s.G)*m + uint32(d[1])*inv) / 0xFF)
+			d[2] = uint8((uint32(s.B)*m + uint32(d[2])*inv) / 0xFF)
+			d[3] = uint8((sa*0xFF + uint32(d[3])*inv) / 0xFF)
+		}
+	}
+}
+
+// Line cap styles.
+const (
+	buttCap = iota
+	roundCap
+	squareCap
+)
+
+// Line join styles.
+const (
+	miterJoin = iota
+	roundJoin
+	bevelJoin
+)
+
+type strokeStyle struct {
+	width      float64 // in device pixels
+	cap, join  int
+	miterLimit float64
+	dash       []float64 // in device pixels
+	phase      float64
+}
+
+// orient returns pts in counterclockwise order.
+func orient(pts []point) []point {
+	var area float64
+	for i := range pts {
+		p, q := pts[i], pts[(i+1)%len(pts)]
+		area += p.x*q.y - q.x*p.y
+	}
+	if area < 0 {
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	return pts
+}
+
+func circle(c point, r float64) polyline {
+	n := int(math.Ceil(math.Pi / math.Acos(math.Max(1-circleSteps/math.Max(r, circleSteps), -1))))
+	if n < 8 {
+		n = 8
+	}
+	if n > 256 {
+		n = 256
+	}
+	pts := make([]point, n)
+	for i := range pts {
+		s, c1 := math.Sincos(2 * math.Pi * float64(i) / float64(n))
+		pts[i] = point{c.x + r*c1, c.y + r*s}
+	}
+	return polyline{pts: pts, closed: true}
+}
+
+// dashed splits pl into its dashes.
+func dashed(pl polyline, dash []float64, phase float64) []polyline {
+	var total float64
+	for _, d := range dash {
+		total += d
+	}
+	if total <= 0 {
+		return []polyline{pl}
+	}
+
+	pts := pl.pts
+	if pl.closed {
+		pts = append(pts[:len(pts):len(pts)], pts[0])
+	}
+
+	// Position within the dash pattern.
+	i, on := 0, true
+	rest := dash[0]
+	phase = math.Mod(phase, total)
+	for phase > 0 {
+		if phase < rest {
+			rest -= phase
+			break
+		}
+		phase -= rest
+		i = (i + 1) % len(dash)
+		on = !on
+		rest = dash[i]
+	}
+
+	var (
+		pls []polyline
+		cur []point
+	)
+	if on {
+		cur = []point{pts[0]}
+	}
+
+	for j := 0; j+1 < len(pts); j++ {
+		p, q := pts[j], pts[j+1]
+		l := q.sub(p).len()
+		pos := 0.
+		for l-pos > rest {
+			pos += rest
+			pt := p.add(q.sub(p).mul(pos / l))
+			if on {
+				pls = append(pls, polyline{pts: append(cur, pt)})
+				cur = nil
+			} else {
+				cur = []point{pt}
+			}
+			on = !on
+			i = (i + 1) % len(dash)
+			rest = dash[i]
+		}
+		rest -= l - pos
+		if on {
+			cur = append(cur, q)
+		}
+	}
+
+	if on && len(cur) > 1 {
+		pls = append(pls, polyline{pts: cur})
+	}
+
+	return pls
+}
+
+func (st strokeStyle) cap1(pls []polyline, p, d point, hw float64) []polyline {
+	switch st.cap {
+	case roundCap:
+		pls = append(pls, circle(p, hw))
+	case squareCap:
+		n := point{-d.y, d.x}.mul(hw)
+		e := d.mul(hw)
+		pls = append(pls, polyline{pts: orient([]point{p.add(n), p.add(n).add(e), p.sub(n).add(e), p.sub(n)}), closed: true})
+	}
+	return pls
+}
+
+func (st strokeStyle) join1(pls []polyline, v, d1, d2 point, hw float64) []polyline {
+	cross := d1.x*d2.y - d1.y*d2.x
+	dot := d1.x*d2.x + d1.y*d2.y
+	if math.Abs(cross) < 1e-9 && dot > 0 {
+		return pls
+	}
+
+	if st.join == roundJoin {
+		return append(pls, circle(v, hw))
+	}
+
+	s := 1.
+	if cross > 0 {
+		s = -1
+	}
+	n1 := point{-d1.y, d1.x}.mul(s * hw)
+	n2 := point{-d2.y, d2.x}.mul(s * hw)
+
+	if st.join == miterJoin {
+		// The miter length relative to the line width is 1/sin(phi/2).
+		sinHalf := math.Sqrt(math.Max((1-(-dot))/2, 0))
+		if sinHalf > 1e-9 && 1/sinHalf <= st.miterLimit {
+			m := n1.add(n2)
+			if l := m.len(); l > 1e-9 {
+				tip := v.add(m.mul(hw / sinHalf / l))
+				return append(pls, polyline{pts: orient([]point{v, v.add(n1), tip, v.add(n2)}), closed: true})
+			}
+		}
+	}
+
+	return append(pls, polyline{pts: orient([]point{v, v.add(n1), v.add(n2)}), closed: true})
+}
+
+// strokePolyline returns the polygons making up the outline of the stroked polyline pl.
+func (st strokeStyle) strokePolyline(pls []polyline, pl polyline) []polyline {
+	hw := st.width / 2
+
+	if len(pl.pts) == 1 && !pl.closed {
+		// A lone moveTo paints nothing.
+		return pls
+	}
+
+	// Drop repeated points.
+	pts := make([]point, 0, len(pl.pts))
+	for _, p := range pl.pts {
+		if len(pts) == 0 || p.sub(pts[len(pts)-1]).len() > 1e-9 {
+			pts = append(pts, p)
+		}
+	}
+	closed := pl.closed
+	if closed && len(pts) > 1 && pts[0].sub(pts[len(pts)-1]).len() <= 1e-9 {
+		pts = pts[:len(pts)-1]
+	}
+
+	if len(pts) == 1 {
+		// Zero length subpath.
+		if st.cap == roundCap {
+			return append(pls, circle(pts[0], hw))
+		}
+		if st.cap == squareCap {
+			p := pts[0]
+			return append(pls, polyline{pts: orient([]point{{p.x - hw, p.y - hw}, {p.x + hw, p.y - hw}, {p.x + hw, p.y + hw}, {p.x - hw, p.y + hw}}), closed: true})
+		}
+		return pls
+	}
+
+	if closed {
+		pts = append(pts, pts[0])
+	}
+
+	dir := func(i int) point {
+		d := pts[i+1].sub(pts[i])
+		return d.mul(1 / d.len())
+	}
+
+	for i := 0; i+1 < len(pts); i++ {
+		d := dir(i)
+		n := point{-d.y, d.x}.mul(hw)
+		p, q := pts[i], pts[i+1]
+		pls = append(pls, polyline{pts: orient([]point{p.add(n), q.add(n), q.sub(n), p.sub(n)}), closed: true})
+		if i > 0 {
+			pls = st.join1(pls, p, dir(i-1), d, hw)
+		}
+	}
+
+	last := len(pts) - 2
+	if closed {
+		return st.join1(pls, pts[0], dir(last), dir(0), hw)
+	}
+
+	pls = st.cap1(pls, pts[0], dir(0).mul(-1), hw)
+	return st.cap1(pls, pts[last+1], dir(last), hw)
+}
+
+// stroke returns the polygons making up the outline of the stroked polylines pls.
+func (st strokeStyle) stroke(pls []polyline) []polyline {
+	if st.width < minStroke {
+		st.width = minStroke
+	}
+
+	var res []polyline
+	for _, pl := range pls {
+		if len(st.dash) == 0 {
+			res = st.strokePolyline(res, pl)
+			continue
+		}
+		for _, pl1 := range dashed(pl, st.dash, st.phase) {
+			res = st.strokePolyline(res, pl1)
+		}
+	}
+	return res
+}
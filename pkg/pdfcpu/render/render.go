@@ -0,0 +1,313 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+// Package render provides rasterization of PDF pages and their conversion to SVG.
+//
+// The renderer is written in pure Go and aims at previews and thumbnails:
+// It supports paths, clipping, images, shadings, tiling patterns, optional content,
+// annotation appearances and text using embedded TrueType, Type 1 and CFF fonts.
+// Fonts without embedded font program are substituted by the Go fonts.
+// Blend modes and soft masks of transparency groups are not supported.
+package render
+
+import (
+	"image"
+	"math"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// Max. number of pixels of a rendered page.
+const maxPixels = 1 << 28
+
+// Annotation flags.
+const (
+	annHidden = 1 << 1
+	annNoView = 1 << 5
+)
+
+// cellKey identifies a tiling pattern cell rendered for a pattern matrix.
+type cellKey struct {
+	objNr int
+	m     matrix.Matrix
+}
+
+// renderer holds the resources shared by the content streams of a page.
+type renderer struct {
+	ctx         *model.Context
+	fonts       map[int]*renderFont
+	colorSpaces map[int]colorSpace
+	shadings    map[int]*shading
+	images      map[int]*decodedImage
+	cells       map[cellKey]*image.RGBA
+	forms       map[int]bool // form XObjects and patterns currently processed
+	hidden      func(o types.Object) bool
+}
+
+func newRenderer(ctx *model.Context) (*renderer, error) {
+	hidden, err := pdfcpu.HiddenContent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &renderer{
+		ctx:         ctx,
+		fonts:       map[int]*renderFont{},
+		colorSpaces: map[int]colorSpace{},
+		shadings:    map[int]*shading{},
+		images:      map[int]*decodedImage{},
+		cells:       map[cellKey]*image.RGBA{},
+		forms:       map[int]bool{},
+		hidden:      hidden,
+	}, nil
+}
+
+// resource returns the named resource of category.
+func (r *renderer) resource(resources types.Dict, category, name string) types.Object {
+	if resources == nil {
+		return nil
+	}
+	d, err := r.ctx.DereferenceDict(resources[category])
+	if err != nil || d == nil {
+		return nil
+	}
+	o, _ := d.Find(name)
+	return o
+}
+
+// hiddenContent returns true if the optional content group or membership dict o is hidden.
+func (r *renderer) hiddenContent(o types.Object) bool {
+	return r.hidden(o)
+}
+
+func pageBox(inhPAttrs *model.InheritedPageAttrs) (*types.Rectangle, error) {
+	box := inhPAttrs.MediaBox
+	if box == nil {
+		return nil, errors.New("pdfcpu: render: missing mediaBox")
+	}
+	if cb := inhPAttrs.CropBox; cb != nil {
+		llx, lly := math.Max(box.LL.X, cb.LL.X), math.Max(box.LL.Y, cb.LL.Y)
+		urx, ury := math.Min(box.UR.X, cb.UR.X), math.Min(box.UR.Y, cb.UR.Y)
+		if urx > llx && ury > lly {
+			box = types.NewRectangle(llx, lly, urx, ury)
+		}
+	}
+	return box, nil
+}
+
+// baseMatrix maps default user space to device space.
+func baseMatrix(box *types.Rectangle, rot int, s float64) (matrix.Matrix, int, int) {
+	w, h := box.Width(), box.Height()
+
+	var m matrix.Matrix
+	switch rot {
+	case 90:
+		m = matrix.Matrix{{0, -1, 0}, {1, 0, 0}, {0, w, 1}}
+		w, h = h, w
+	case 180:
+		m = matrix.Matrix{{-1, 0, 0}, {0, -1, 0}, {w, h, 1}}
+	case 270:
+		m = matrix.Matrix{{0, 1, 0}, {-1, 0, 0}, {h, 0, 1}}
+		w, h = h, w
+	default:
+		m = matrix.IdentMatrix
+	}
+
+	m = translation(-box.LL.X, -box.LL.Y).Multiply(m)
+	m = m.Multiply(matrix.Matrix{{s, 0, 0}, {0, -s, 0}, {0, h * s, 1}})
+
+	return m, int(math.Round(w * s)), int(math.Round(h * s))
+}
+
+// annotationMatrix maps the form space of an appearance stream to the annotation rectangle.
+func annotationMatrix(bbox, rect, fm []float64) matrix.Matrix {
+	m := matrix.IdentMatrix
+	if len(fm) == 6 {
+		m = newMatrix(fm)
+	}
+
+	// Transformed appearance box.
+	pp := []point{{bbox[0], bbox[1]}, {bbox[2], bbox[1]}, {bbox[2], bbox[3]}, {bbox[0], bbox[3]}}
+	min, max := transform(m, pp[0]), transform(m, pp[0])
+	for _, p := range pp[1:] {
+		p = transform(m, p)
+		min.x, min.y = math.Min(min.x, p.x), math.Min(min.y, p.y)
+		max.x, max.y = math.Max(max.x, p.x), math.Max(max.y, p.y)
+	}
+
+	sx, sy := 1., 1.
+	if max.x > min.x {
+		sx = (rect[2] - rect[0]) / (max.x - min.x)
+	}
+	if max.y > min.y {
+		sy = (rect[3] - rect[1]) / (max.y - min.y)
+	}
+
+	a := matrix.Matrix{{sx, 0, 0}, {0, sy, 0}, {rect[0] - min.x*sx, rect[1] - min.y*sy, 1}}
+	return m.Multiply(a)
+}
+
+// appearance returns the normal appearance stream of an annotation.
+func (r *renderer) appearance(d types.Dict) (*types.StreamDict, int) {
+	xRefTable := r.ctx.XRefTable
+
+	ap, err := xRefTable.DereferenceDict(d["AP"])
+	if err != nil || ap == nil {
+		return nil, 0
+	}
+
+	o := ap["N"]
+	if n, err := xRefTable.DereferenceDict(o); err == nil && n != nil {
+		// Appearance subdictionary, select the appearance state.
+		as := d.NameEntry("AS")
+		if as == nil {
+			return nil, 0
+		}
+		o = n[*as]
+	}
+
+	indRef, ok := o.(types.IndirectRef)
+	if !ok {
+		return nil, 0
+	}
+	sd, _, err := xRefTable.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return nil, 0
+	}
+	return sd, indRef.ObjectNumber.Value()
+}
+
+func (in *interpreter) drawAnnotations(pageDict types.Dict, resources types.Dict) {
+	xRefTable := in.r.ctx.XRefTable
+
+	annots, err := xRefTable.DereferenceArray(pageDict["Annots"])
+	if err != nil {
+		return
+	}
+
+	for _, o := range annots {
+		d, err := xRefTable.DereferenceDict(o)
+		if err != nil || d == nil {
+			continue
+		}
+
+		if f := d.IntEntry("F"); f != nil && *f&(annHidden|annNoView) > 0 {
+			continue
+		}
+		if oc, found := d.Find("OC"); found && in.r.hiddenContent(oc) {
+			continue
+		}
+
+		sd, objNr := in.r.appearance(d)
+		if sd == nil {
+			continue
+		}
+
+		rect := numberArray(xRefTable, d, "Rect")
+		bbox := numberArray(xRefTable, sd.Dict, "BBox")
+		if len(rect) != 4 || len(bbox) != 4 {
+			continue
+		}
+		rect[0], rect[2] = math.Min(rect[0], rect[2]), math.Max(rect[0], rect[2])
+		rect[1], rect[3] = math.Min(rect[1], rect[3]), math.Max(rect[1], rect[3])
+
+		saved := in.gs
+		in.gs.ctm = annotationMatrix(bbox, rect, numberArray(xRefTable, sd.Dict, "Matrix")).Multiply(in.gs.ctm)
+
+		// The appearance matrix is already part of the ctm.
+		sd1 := *sd
+		sd1.Dict = sd.Dict.Clone().(types.Dict)
+		sd1.Dict.Delete("Matrix")
+		in.drawForm(&sd1, objNr, resources, 0)
+
+		in.gs = saved
+	}
+}
+
+// page returns the page dict, the inherited page attributes and the visible region of page pageNr.
+func page(ctx *model.Context, pageNr int) (types.Dict, *model.InheritedPageAttrs, *types.Rectangle, error) {
+	d, _, inhPAttrs, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if d == nil {
+		return nil, nil, nil, errors.Errorf("pdfcpu: render: unknown page %d", pageNr)
+	}
+
+	box, err := pageBox(inhPAttrs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return d, inhPAttrs, box, nil
+}
+
+// drawPage interprets the page content followed by the annotation appearances.
+func drawPage(ctx *model.Context, d types.Dict, inhPAttrs *model.InheritedPageAttrs, newInterpreter func() *interpreter) error {
+	bb, err := ctx.PageContent(d)
+	if err != nil && err != model.ErrNoContent {
+		return err
+	}
+	if len(bb) > 0 {
+		if err := newInterpreter().process(bb, inhPAttrs.Resources, 0); err != nil {
+			return err
+		}
+	}
+
+	newInterpreter().drawAnnotations(d, inhPAttrs.Resources)
+
+	return nil
+}
+
+// Page renders page pageNr of ctx at dpi dots per inch on a white background.
+func Page(ctx *model.Context, pageNr, dpi int) (*image.RGBA, error) {
+	if dpi <= 0 {
+		return nil, errors.Errorf("pdfcpu: render: invalid dpi %d", dpi)
+	}
+
+	d, inhPAttrs, box, err := page(ctx, pageNr)
+	if err != nil {
+		return nil, err
+	}
+
+	rot := (inhPAttrs.Rotate%360 + 360) % 360
+	m, w, h := baseMatrix(box, rot, float64(dpi)/72)
+	if w <= 0 || h <= 0 || w*h > maxPixels {
+		return nil, errors.Errorf("pdfcpu: render: invalid image size %dx%d for page %d", w, h, pageNr)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+
+	r, err := newRenderer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	newInterpreter := func() *interpreter {
+		return r.newInterpreter(img, m, &clipRegion{rect: img.Rect})
+	}
+	if err := drawPage(ctx, d, inhPAttrs, newInterpreter); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
@@ -0,0 +1,171 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestGlyphNameTables(t *testing.T) {
+	if len(macGlyphNames) != 258 {
+		t.Errorf("macGlyphNames: want 258 entries, got %d", len(macGlyphNames))
+	}
+	if len(cffStandardStrings) != 391 {
+		t.Errorf("cffStandardStrings: want 391 entries, got %d", len(cffStandardStrings))
+	}
+}
+
+func coverage(a *image.Alpha) float64 {
+	var sum float64
+	for _, v := range a.Pix {
+		sum += float64(v) / 0xFF
+	}
+	return sum
+}
+
+func TestRasterize(t *testing.T) {
+	r := image.Rect(0, 0, 20, 20)
+
+	// An axis aligned square covering 5.5 x 5.5 pixels.
+	p := &path{}
+	p.rect(matrix.IdentMatrix, 2.25, 2.25, 5.5, 5.5)
+	if got := coverage(rasterize(p.flatten(), false, r)); math.Abs(got-30.25) > 0.5 {
+		t.Errorf("square: want coverage 30.25, got %f", got)
+	}
+
+	// A circle of radius 8, slightly reduced by flattening.
+	p = &path{}
+	const k = 0.5523 * 8
+	p.moveTo(point{18, 10})
+	p.curveTo(point{18, 10 + k}, point{10 + k, 18}, point{10, 18})
+	p.curveTo(point{10 - k, 18}, point{2, 10 + k}, point{2, 10})
+	p.curveTo(point{2, 10 - k}, point{10 - k, 2}, point{10, 2})
+	p.curveTo(point{10 + k, 2}, point{18, 10 - k}, point{18, 10})
+	p.close()
+	if got, want := coverage(rasterize(p.flatten(), false, r)), math.Pi*64; math.Abs(got-want) > 3 {
+		t.Errorf("circle: want coverage %f, got %f", want, got)
+	}
+
+	// Nested squares: even-odd leaves a hole, nonzero winding does not.
+	p = &path{}
+	p.rect(matrix.IdentMatrix, 0, 0, 10, 10)
+	p.rect(matrix.IdentMatrix, 2, 2, 6, 6)
+	if got := coverage(rasterize(p.flatten(), true, r)); math.Abs(got-64) > 0.5 {
+		t.Errorf("even-odd: want coverage 64, got %f", got)
+	}
+	if got := coverage(rasterize(p.flatten(), false, r)); math.Abs(got-100) > 0.5 {
+		t.Errorf("nonzero: want coverage 100, got %f", got)
+	}
+}
+
+func TestStroke(t *testing.T) {
+	r := image.Rect(0, 0, 20, 20)
+
+	p := &path{}
+	p.moveTo(point{2, 10})
+	p.lineTo(point{18, 10})
+
+	st := strokeStyle{width: 2, miterLimit: 10}
+	if got := coverage(rasterize(st.stroke(p.flatten()), false, r)); math.Abs(got-32) > 0.5 {
+		t.Errorf("butt cap: want coverage 32, got %f", got)
+	}
+
+	st.cap = 2 // projecting square cap
+	if got := coverage(rasterize(st.stroke(p.flatten()), false, r)); math.Abs(got-36) > 0.5 {
+		t.Errorf("square cap: want coverage 36, got %f", got)
+	}
+
+	st.cap, st.dash = 0, []float64{4, 4}
+	if got := coverage(rasterize(st.stroke(p.flatten()), false, r)); math.Abs(got-16) > 0.5 {
+		t.Errorf("dashed: want coverage 16, got %f", got)
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	xRefTable := &model.XRefTable{}
+
+	exp := types.Dict{
+		"FunctionType": types.Integer(2),
+		"Domain":       types.NewNumberArray(0, 1),
+		"C0":           types.NewNumberArray(0, 0, 0),
+		"C1":           types.NewNumberArray(1, 0.5, 0),
+		"N":            types.Integer(1),
+	}
+	f, err := loadFunction(xRefTable, exp, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.eval([]float64{0.5}); math.Abs(got[0]-0.5) > 1e-9 || math.Abs(got[1]-0.25) > 1e-9 || got[2] != 0 {
+		t.Errorf("exponential: unexpected result %v", got)
+	}
+
+	sd := types.StreamDict{
+		Dict: types.Dict{
+			"FunctionType": types.Integer(4),
+			"Domain":       types.NewNumberArray(0, 1, 0, 1),
+			"Range":        types.NewNumberArray(0, 1),
+		},
+		Content: []byte("{ 2 copy gt { exch } if pop }"),
+	}
+	f, err = loadFunction(xRefTable, sd, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.eval([]float64{0.3, 0.7}); len(got) != 1 || got[0] != 0.3 {
+		t.Errorf("PostScript min: want [0.3], got %v", got)
+	}
+}
+
+func TestSVGPathData(t *testing.T) {
+	p := &path{}
+	p.moveTo(point{0, 0})
+	p.lineTo(point{10, 0})
+	p.curveTo(point{10, 5}, point{5, 10}, point{0.5, 10})
+	p.moveTo(point{20, 20})
+	p.lineTo(point{30, 20})
+
+	if got, want := svgPathData(p, false), "M0 0L10 0C10 5 5 10 0.5 10M20 20L30 20"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := svgPathData(p, true), "M0 0L10 0C10 5 5 10 0.5 10ZM20 20L30 20Z"; got != want {
+		t.Errorf("closed: want %q, got %q", want, got)
+	}
+}
+
+func TestFontFamily(t *testing.T) {
+	for _, tt := range []struct {
+		name                  string
+		flags                 int
+		family, weight, style string
+	}{
+		{"Helvetica-BoldOblique", 0, "'Helvetica', sans-serif", "bold", "italic"},
+		{"TimesNewRomanPSMT", flagSerif, "'Times New Roman', serif", "", ""},
+		{"Courier", flagFixedPitch, "'Courier', monospace", "", ""},
+		{"", 0, "sans-serif", "", ""},
+	} {
+		family, weight, style := fontFamily(&renderFont{name: tt.name, flags: tt.flags})
+		if family != tt.family || weight != tt.weight || style != tt.style {
+			t.Errorf("%s: want %s/%s/%s, got %s/%s/%s", tt.name, tt.family, tt.weight, tt.style, family, weight, style)
+		}
+	}
+}
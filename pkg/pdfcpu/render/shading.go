@@ -0,0 +1,457 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+const (
+	shadingSteps = 256     // color lookup table size for axial and radial shadings
+	maxCellArea  = 1 << 22 // max. size of tiling pattern cells in device pixels
+)
+
+// shading is a function based, axial, radial or mesh based shading.
+type shading struct {
+	kind   int
+	cs     colorSpace
+	fn     function
+	coords []float64
+	domain []float64
+	extend [2]bool
+	matrix matrix.Matrix // function based shadings only
+	lut    []color.RGBA
+	mesh   *mesh // mesh based shadings only
+}
+
+func (sh *shading) color(c []float64) color.RGBA {
+	if sh.fn != nil {
+		c = sh.fn.eval(c)
+	}
+	return rgba(sh.cs, c)
+}
+
+// colorAt returns the color for point p of shading space.
+func (sh *shading) colorAt(p point) (color.RGBA, bool) {
+	var s float64
+
+	switch sh.kind {
+
+	case 1:
+		q := transform(sh.matrix, p)
+		d := sh.domain
+		if q.x < d[0] || q.x > d[1] || q.y < d[2] || q.y > d[3] {
+			return color.RGBA{}, false
+		}
+		return sh.color([]float64{q.x, q.y}), true
+
+	case 2:
+		c := sh.coords
+		dx, dy := c[2]-c[0], c[3]-c[1]
+		l := dx*dx + dy*dy
+		if l == 0 {
+			return color.RGBA{}, false
+		}
+		s = ((p.x-c[0])*dx + (p.y-c[1])*dy) / l
+
+	case 3:
+		var ok bool
+		if s, ok = sh.radial(p); !ok {
+			return color.RGBA{}, false
+		}
+	}
+
+	switch {
+	case s < 0 && !sh.extend[0], s > 1 && !sh.extend[1]:
+		return color.RGBA{}, false
+	}
+	s = clip(s, 0, 1)
+
+	return sh.lut[int(s*float64(shadingSteps-1)+0.5)], true
+}
+
+// radial returns the parameter of the circle with max. parameter containing p.
+func (sh *shading) radial(p point) (float64, bool) {
+	c := sh.coords
+	cdx, cdy, dr := c[3]-c[0], c[4]-c[1], c[5]-c[2]
+	pdx, pdy, r0 := p.x-c[0], p.y-c[1], c[2]
+
+	a := cdx*cdx + cdy*cdy - dr*dr
+	b := pdx*cdx + pdy*cdy + r0*dr
+	cc := pdx*pdx + pdy*pdy - r0*r0
+
+	valid := func(s float64) bool {
+		if r0+s*dr < 0 {
+			return false
+		}
+		return (s >= 0 || sh.extend[0]) && (s <= 1 || sh.extend[1])
+	}
+
+	if math.Abs(a) < 1e-9 {
+		if b == 0 {
+			return 0, false
+		}
+		s := cc / (2 * b)
+		return s, valid(s)
+	}
+
+	disc := b*b - a*cc
+	if disc < 0 {
+		return 0, false
+	}
+	s1, s2 := (b+math.Sqrt(disc))/a, (b-math.Sqrt(disc))/a
+	if s1 < s2 {
+		s1, s2 = s2, s1
+	}
+	if valid(s1) {
+		return s1, true
+	}
+	return s2, valid(s2)
+}
+
+func (r *renderer) shading(o types.Object) *shading {
+	indRef, isIndRef := o.(types.IndirectRef)
+	if isIndRef {
+		if sh, ok := r.shadings[indRef.ObjectNumber.Value()]; ok {
+			return sh
+		}
+	}
+
+	sh := r.loadShading(o)
+	if isIndRef {
+		r.shadings[indRef.ObjectNumber.Value()] = sh
+	}
+	return sh
+}
+
+func (r *renderer) loadShading(o types.Object) *shading {
+	xRefTable := r.ctx.XRefTable
+
+	o, err := xRefTable.Dereference(o)
+	if err != nil {
+		return nil
+	}
+
+	var d types.Dict
+	switch o := o.(type) {
+	case types.Dict:
+		d = o
+	case types.StreamDict:
+		d = o.Dict
+	default:
+		return nil
+	}
+
+	t := d.IntEntry("ShadingType")
+	if t == nil || *t < 1 || *t > 7 {
+		return nil
+	}
+
+	sh := &shading{kind: *t, cs: r.colorSpace(d["ColorSpace"], nil, 0)}
+	if f, found := d.Find("Function"); found {
+		if sh.fn, err = loadFunction(xRefTable, f, 0); err != nil {
+			return nil
+		}
+	}
+
+	sh.domain = numberArray(xRefTable, d, "Domain")
+
+	if sh.kind >= 4 {
+		return r.loadMeshShading(sh, o)
+	}
+
+	if sh.kind == 1 {
+		if len(sh.domain) != 4 {
+			sh.domain = []float64{0, 1, 0, 1}
+		}
+		sh.matrix = matrix.IdentMatrix
+		if m := numberArray(xRefTable, d, "Matrix"); len(m) == 6 {
+			if inv, ok := invert(newMatrix(m)); ok {
+				sh.matrix = inv
+			}
+		}
+		return sh
+	}
+
+	sh.coords = numberArray(xRefTable, d, "Coords")
+	if sh.kind == 2 && len(sh.coords) != 4 || sh.kind == 3 && len(sh.coords) != 6 {
+		return nil
+	}
+	if len(sh.domain) != 2 {
+		sh.domain = []float64{0, 1}
+	}
+	if a, err := xRefTable.DereferenceArray(d["Extend"]); err == nil && len(a) == 2 {
+		for i, o := range a {
+			if b, ok := o.(types.Boolean); ok {
+				sh.extend[i] = b.Value()
+			}
+		}
+	}
+
+	sh.initLUT()
+
+	return sh
+}
+
+func (sh *shading) initLUT() {
+	sh.lut = make([]color.RGBA, shadingSteps)
+	for i := range sh.lut {
+		t := sh.domain[0] + float64(i)/float64(shadingSteps-1)*(sh.domain[1]-sh.domain[0])
+		sh.lut[i] = sh.color([]float64{t})
+	}
+}
+
+func (r *renderer) loadMeshShading(sh *shading, o types.Object) *shading {
+	sd, ok := o.(types.StreamDict)
+	if !ok || sh.cs == nil {
+		return nil
+	}
+
+	n := sh.cs.components()
+	if sh.fn != nil {
+		n = 1
+		if len(sh.domain) != 2 {
+			// The parametric variable is bounded by the Decode array.
+			if dec := numberArray(r.ctx.XRefTable, sd.Dict, "Decode"); len(dec) >= 6 {
+				sh.domain = dec[4:6]
+			} else {
+				sh.domain = []float64{0, 1}
+			}
+		}
+		sh.initLUT()
+	}
+
+	if sh.mesh = loadMesh(r.ctx.XRefTable, cloneStream(&sd), sh.kind, n); sh.mesh == nil {
+		return nil
+	}
+
+	return sh
+}
+
+// shadingPaint paints a shading mapped to device space.
+type shadingPaint struct {
+	sh    *shading
+	inv   matrix.Matrix // device space to shading space
+	layer *image.RGBA   // mesh based shadings only
+}
+
+func (p *shadingPaint) at(x, y int) color.RGBA {
+	if p.layer != nil {
+		return p.layer.RGBAAt(x, y)
+	}
+	c, ok := p.sh.colorAt(transform(p.inv, point{float64(x) + 0.5, float64(y) + 0.5}))
+	if !ok {
+		return color.RGBA{}
+	}
+	return c
+}
+
+// shadingPaint returns a paint for the shading o mapped to device space by m.
+// Mesh based shadings are rendered up front for the device region rect.
+func (r *renderer) shadingPaint(o types.Object, m matrix.Matrix, rect image.Rectangle) paint {
+	sh := r.shading(o)
+	if sh == nil {
+		return nil
+	}
+	if sh.mesh != nil {
+		return &shadingPaint{sh: sh, layer: sh.mesh.render(sh, m, rect)}
+	}
+	inv, ok := invert(m)
+	if !ok {
+		return nil
+	}
+	return &shadingPaint{sh: sh, inv: inv}
+}
+
+// shade paints a shading over the current clipping region (sh operator).
+func (in *interpreter) shade(o types.Object) {
+	r := in.gs.clip.rect
+	if r.Empty() {
+		return
+	}
+	p := in.r.shadingPaint(o, in.gs.ctm, r)
+	if p == nil {
+		return
+	}
+	a := image.NewAlpha(r)
+	for i := range a.Pix {
+		a.Pix[i] = 0xFF
+	}
+	if in.svg != nil {
+		in.svgRaster(a, in.gs.fillAlpha, p)
+		return
+	}
+	composite(in.dst, a, in.gs.clip, in.gs.fillAlpha, p)
+}
+
+// tilePaint repeats a rendered pattern cell.
+type tilePaint struct {
+	cell         *image.RGBA
+	inv          matrix.Matrix // device space to pattern space
+	x, y         float64       // lower left corner of the cell in pattern space
+	xStep, yStep float64
+	s            float64     // cell pixels per pattern space unit
+	color        *color.RGBA // uncolored patterns only
+}
+
+func (p *tilePaint) at(x, y int) color.RGBA {
+	q := transform(p.inv, point{float64(x) + 0.5, float64(y) + 0.5})
+	u := math.Mod(q.x-p.x, p.xStep)
+	if u < 0 {
+		u += p.xStep
+	}
+	v := math.Mod(q.y-p.y, p.yStep)
+	if v < 0 {
+		v += p.yStep
+	}
+
+	b := p.cell.Rect
+	px := int(math.Min(u*p.s, float64(b.Max.X-1)))
+	py := int(math.Min(float64(b.Max.Y)-v*p.s, float64(b.Max.Y-1)))
+	c := p.cell.RGBAAt(px, py)
+
+	if p.color == nil {
+		return c
+	}
+	a := uint32(c.A)
+	return color.RGBA{uint8(uint32(p.color.R) * a / 0xFF), uint8(uint32(p.color.G) * a / 0xFF), uint8(uint32(p.color.B) * a / 0xFF), c.A}
+}
+
+func (in *interpreter) tilingPaint(sd *types.StreamDict, objNr int, cs patternCS, c []float64, m matrix.Matrix) paint {
+	xRefTable := in.r.ctx.XRefTable
+
+	bbox := numberArray(xRefTable, sd.Dict, "BBox")
+	xStep, err1 := xRefTable.DereferenceNumber(sd.Dict["XStep"])
+	yStep, err2 := xRefTable.DereferenceNumber(sd.Dict["YStep"])
+	if len(bbox) != 4 || err1 != nil || err2 != nil || xStep == 0 || yStep == 0 {
+		return nil
+	}
+	xStep, yStep = math.Abs(xStep), math.Abs(yStep)
+
+	inv, ok := invert(m)
+	if !ok {
+		return nil
+	}
+
+	s := scale(m)
+	if a := xStep * yStep * s * s; a > maxCellArea {
+		s *= math.Sqrt(maxCellArea / a)
+	}
+	w, h := int(math.Ceil(xStep*s)), int(math.Ceil(yStep*s))
+	if w < 1 || h < 1 {
+		return nil
+	}
+
+	p := &tilePaint{inv: inv, x: bbox[0], y: bbox[1], xStep: xStep, yStep: yStep, s: s}
+
+	uncolored := false
+	if t := sd.IntEntry("PaintType"); t != nil && *t == 2 {
+		uncolored = true
+		if cs.base == nil {
+			return nil
+		}
+		c := rgba(cs.base, c)
+		p.color = &c
+	}
+
+	k := cellKey{objNr: objNr, m: m}
+	if cell, ok := in.r.cells[k]; ok {
+		if p.cell = cell; cell == nil {
+			return nil
+		}
+		return p
+	}
+	in.r.cells[k] = nil
+
+	if in.r.forms[objNr] || sd.Decode() != nil {
+		return nil
+	}
+
+	cell := image.NewRGBA(image.Rect(0, 0, w, h))
+	ctm := matrix.Matrix{{s, 0, 0}, {0, -s, 0}, {-bbox[0] * s, float64(h) + bbox[1]*s, 1}}
+
+	resources, _ := xRefTable.DereferenceDict(sd.Dict["Resources"])
+
+	in1 := in.r.newInterpreter(cell, ctm, &clipRegion{rect: cell.Rect})
+	in1.uncolored = uncolored
+
+	in.r.forms[objNr] = true
+	in1.process(sd.Content, resources, 0)
+	delete(in.r.forms, objNr)
+
+	in.r.cells[k] = cell
+	p.cell = cell
+	return p
+}
+
+// paint returns the paint for filling or stroking.
+func (in *interpreter) paint(stroke bool, resources types.Dict) paint {
+	cs, c, pattern := in.gs.fillCS, in.gs.fillColor, in.gs.fillPattern
+	if stroke {
+		cs, c, pattern = in.gs.strokeCS, in.gs.strokeColor, in.gs.strokePattern
+	}
+
+	pcs, ok := cs.(patternCS)
+	if !ok {
+		return uniformPaint(rgba(cs, c))
+	}
+	if pattern == nil {
+		return nil
+	}
+
+	xRefTable := in.r.ctx.XRefTable
+
+	objNr := 0
+	if indRef, ok := pattern.(types.IndirectRef); ok {
+		objNr = indRef.ObjectNumber.Value()
+	}
+
+	o, err := xRefTable.Dereference(pattern)
+	if err != nil {
+		return nil
+	}
+
+	var d types.Dict
+	var sd *types.StreamDict
+	switch o := o.(type) {
+	case types.Dict:
+		d = o
+	case types.StreamDict:
+		d, sd = o.Dict, &o
+	default:
+		return nil
+	}
+
+	m := in.gs.base
+	if pm := numberArray(xRefTable, d, "Matrix"); len(pm) == 6 {
+		m = newMatrix(pm).Multiply(m)
+	}
+
+	if t := d.IntEntry("PatternType"); t != nil && *t == 2 {
+		return in.r.shadingPaint(d["Shading"], m, in.gs.clip.rect)
+	}
+
+	if sd == nil {
+		return nil
+	}
+	return in.tilingPaint(sd, objNr, pcs, c, m)
+}
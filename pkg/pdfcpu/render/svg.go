@@ -0,0 +1,507 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// svgScale is the number of SVG user units per point.
+// Shadings, patterns and stencil masks are embedded as bitmaps of this resolution.
+const svgScale = 2
+
+// svgWriter collects the SVG markup of a page.
+type svgWriter struct {
+	defs, body strings.Builder
+	ids        int
+	images     map[*decodedImage]string // ids of embedded images
+}
+
+func (w *svgWriter) newID(prefix string) string {
+	w.ids++
+	return prefix + strconv.Itoa(w.ids)
+}
+
+func svgNumber(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 3, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		s = "0"
+	}
+	return s
+}
+
+func svgMatrix(m matrix.Matrix) string {
+	return fmt.Sprintf("matrix(%s %s %s %s %s %s)",
+		svgNumber(m[0][0]), svgNumber(m[0][1]), svgNumber(m[1][0]), svgNumber(m[1][1]), svgNumber(m[2][0]), svgNumber(m[2][1]))
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func svgOpacity(attr string, alpha float64) string {
+	if alpha >= 1 {
+		return ""
+	}
+	return fmt.Sprintf(` %s="%s"`, attr, svgNumber(alpha))
+}
+
+// svgPathData returns the SVG path data for p, closing all subpaths if closeAll is true.
+func svgPathData(p *path, closeAll bool) string {
+	var sb strings.Builder
+	pt := func(q point) {
+		sb.WriteString(svgNumber(q.x))
+		sb.WriteByte(' ')
+		sb.WriteString(svgNumber(q.y))
+	}
+	j := 0
+	for i, op := range p.ops {
+		switch op {
+		case moveTo:
+			if closeAll && i > 0 && p.ops[i-1] != closePath {
+				sb.WriteByte('Z')
+			}
+			sb.WriteByte('M')
+			pt(p.pts[j])
+			j++
+		case lineTo:
+			sb.WriteByte('L')
+			pt(p.pts[j])
+			j++
+		case curveTo:
+			sb.WriteByte('C')
+			pt(p.pts[j])
+			sb.WriteByte(' ')
+			pt(p.pts[j+1])
+			sb.WriteByte(' ')
+			pt(p.pts[j+2])
+			j += 3
+		case closePath:
+			sb.WriteByte('Z')
+		}
+	}
+	if closeAll && len(p.ops) > 0 && p.ops[len(p.ops)-1] != closePath {
+		sb.WriteByte('Z')
+	}
+	return sb.String()
+}
+
+func pngDataURI(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (in *interpreter) svgClipAttr() string {
+	if in.gs.svgClip == "" {
+		return ""
+	}
+	return ` clip-path="url(#` + in.gs.svgClip + `)"`
+}
+
+// svgIntersectClip adds a clipping path nested into the current one.
+// The clipping region of the graphics state keeps track of the bounding box.
+func (in *interpreter) svgIntersectClip(p *path, evenOdd bool) {
+	r := image.Rectangle{}
+	if min, max, ok := bounds(p.flatten()); ok {
+		r = in.gs.clip.rect.Intersect(image.Rect(
+			clampInt(math.Floor(min.x)), clampInt(math.Floor(min.y)),
+			clampInt(math.Ceil(max.x)), clampInt(math.Ceil(max.y))))
+	}
+	in.gs.clip = &clipRegion{rect: r}
+	if r.Empty() {
+		return
+	}
+
+	rule := ""
+	if evenOdd {
+		rule = ` clip-rule="evenodd"`
+	}
+	id := in.svg.newID("clip")
+	fmt.Fprintf(&in.svg.defs, "<clipPath id=\"%s\"%s><path d=\"%s\"%s/></clipPath>\n", id, in.svgClipAttr(), svgPathData(p, false), rule)
+	in.gs.svgClip = id
+}
+
+// svgRaster embeds the coverage a painted with p as bitmap.
+func (in *interpreter) svgRaster(a *image.Alpha, alpha float64, p paint) {
+	if a == nil || a.Rect.Empty() {
+		return
+	}
+
+	layer := image.NewRGBA(a.Rect)
+	composite(layer, a, &clipRegion{rect: a.Rect}, alpha, p)
+
+	blank := true
+	for i := 3; i < len(layer.Pix); i += 4 {
+		if layer.Pix[i] != 0 {
+			blank = false
+			break
+		}
+	}
+	if blank {
+		return
+	}
+
+	uri, err := pngDataURI(layer)
+	if err != nil {
+		return
+	}
+	r := a.Rect
+	fmt.Fprintf(&in.svg.body, "<image x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\"%s xlink:href=\"%s\"/>\n",
+		r.Min.X, r.Min.Y, r.Dx(), r.Dy(), in.svgClipAttr(), uri)
+}
+
+func (in *interpreter) svgStrokeAttrs() string {
+	st := in.strokeStyle()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, ` stroke-width="%s"`, svgNumber(math.Max(st.width, minStroke)))
+	if st.cap > 0 && st.cap < 3 {
+		sb.WriteString([]string{"", ` stroke-linecap="round"`, ` stroke-linecap="square"`}[st.cap])
+	}
+	switch st.join {
+	case 0:
+		if st.miterLimit != 4 {
+			fmt.Fprintf(&sb, ` stroke-miterlimit="%s"`, svgNumber(math.Max(st.miterLimit, 1)))
+		}
+	case 1:
+		sb.WriteString(` stroke-linejoin="round"`)
+	case 2:
+		sb.WriteString(` stroke-linejoin="bevel"`)
+	}
+	if len(st.dash) > 0 {
+		ss := make([]string, len(st.dash))
+		for i, d := range st.dash {
+			ss[i] = svgNumber(d)
+		}
+		fmt.Fprintf(&sb, ` stroke-dasharray="%s"`, strings.Join(ss, " "))
+		if st.phase != 0 {
+			fmt.Fprintf(&sb, ` stroke-dashoffset="%s"`, svgNumber(st.phase))
+		}
+	}
+	return sb.String()
+}
+
+// svgPaint writes the current path for a path painting operator.
+func (in *interpreter) svgPaint(op string, resources types.Dict) {
+	if in.hidden > 0 || in.gs.clip.rect.Empty() || in.path.empty() {
+		return
+	}
+
+	p := &in.path
+	closeAll := op == "s" || op == "b" || op == "b*"
+	evenOdd := op == "f*" || op == "B*" || op == "b*"
+
+	switch op {
+	case "f", "F", "f*", "B", "B*", "b", "b*":
+		in.svgFill(p, evenOdd, resources)
+	}
+
+	switch op {
+	case "S", "s", "B", "B*", "b", "b*":
+		in.svgStroke(p, closeAll, resources)
+	}
+}
+
+func (in *interpreter) svgFill(p *path, evenOdd bool, resources types.Dict) {
+	pt := in.paint(false, resources)
+	if pt == nil {
+		return
+	}
+
+	c, ok := pt.(uniformPaint)
+	if !ok {
+		in.svgRaster(rasterize(p.flatten(), evenOdd, in.gs.clip.rect), in.gs.fillAlpha, pt)
+		return
+	}
+
+	rule := ""
+	if evenOdd {
+		rule = ` fill-rule="evenodd"`
+	}
+	fmt.Fprintf(&in.svg.body, "<path d=\"%s\" fill=\"%s\"%s%s%s/>\n",
+		svgPathData(p, false), svgColor(color.RGBA(c)), rule, svgOpacity("fill-opacity", in.gs.fillAlpha), in.svgClipAttr())
+}
+
+func (in *interpreter) svgStroke(p *path, closeAll bool, resources types.Dict) {
+	pt := in.paint(true, resources)
+	if pt == nil {
+		return
+	}
+
+	c, ok := pt.(uniformPaint)
+	if !ok {
+		pls := p.flatten()
+		if closeAll {
+			for i := range pls {
+				pls[i].closed = true
+			}
+		}
+		in.svgRaster(rasterize(in.strokeStyle().stroke(pls), false, in.gs.clip.rect), in.gs.strokeAlpha, pt)
+		return
+	}
+
+	fmt.Fprintf(&in.svg.body, "<path d=\"%s\" fill=\"none\" stroke=\"%s\"%s%s%s/>\n",
+		svgPathData(p, closeAll), svgColor(color.RGBA(c)), in.svgStrokeAttrs(), svgOpacity("stroke-opacity", in.gs.strokeAlpha), in.svgClipAttr())
+}
+
+// svgImage writes an image defined once per page and placed by the current transformation matrix.
+func (in *interpreter) svgImage(di *decodedImage, interpolate bool) {
+	w := in.svg
+
+	id, ok := w.images[di]
+	if !ok {
+		uri, err := pngDataURI(di.img)
+		if err != nil {
+			return
+		}
+		b := di.img.Rect
+		id = w.newID("img")
+		rendering := ""
+		if !interpolate {
+			rendering = ` image-rendering="optimizeSpeed"`
+		}
+		fmt.Fprintf(&w.defs, "<image id=\"%s\" width=\"%d\" height=\"%d\" preserveAspectRatio=\"none\"%s xlink:href=\"%s\"/>\n",
+			id, b.Dx(), b.Dy(), rendering, uri)
+		w.images[di] = id
+	}
+
+	b := di.img.Rect
+	m := matrix.Matrix{{1 / float64(b.Dx()), 0, 0}, {0, -1 / float64(b.Dy()), 0}, {0, 1, 1}}.Multiply(in.gs.ctm)
+	fmt.Fprintf(&w.body, "<use xlink:href=\"#%s\" transform=\"%s\"%s%s/>\n",
+		id, svgMatrix(m), svgOpacity("opacity", in.gs.fillAlpha), in.svgClipAttr())
+}
+
+// svgTextRun collects the glyph positions of a shown string in device space.
+type svgTextRun struct {
+	trm    matrix.Matrix // text rendering matrix of the first glyph
+	glyphs []svgGlyph
+}
+
+type svgGlyph struct {
+	text       string
+	start, end point
+}
+
+func (run *svgTextRun) add(s string, trm matrix.Matrix) {
+	if len(run.glyphs) == 0 {
+		run.trm = trm
+	}
+	run.glyphs = append(run.glyphs, svgGlyph{text: s, start: transform(trm, point{})})
+}
+
+func (run *svgTextRun) end(trm matrix.Matrix) {
+	run.glyphs[len(run.glyphs)-1].end = transform(trm, point{})
+}
+
+// fontFamily returns the CSS font family of f along with the font weight and style.
+func fontFamily(f *renderFont) (string, string, string) {
+	name := f.name
+	lower := strings.ToLower(name)
+
+	weight, style := "", ""
+	if f.flags&flagForceBold > 0 || strings.Contains(lower, "bold") || strings.Contains(lower, "black") || strings.Contains(lower, "heavy") {
+		weight = "bold"
+	}
+	if f.flags&flagItalic > 0 || strings.Contains(lower, "italic") || strings.Contains(lower, "oblique") {
+		style = "italic"
+	}
+
+	generic := "sans-serif"
+	switch {
+	case f.flags&flagFixedPitch > 0 || strings.Contains(lower, "courier") || strings.Contains(lower, "mono"):
+		generic = "monospace"
+	case f.flags&flagSerif > 0 || strings.Contains(lower, "times") || strings.Contains(lower, "serif") && !strings.Contains(lower, "sans"):
+		generic = "serif"
+	}
+
+	// Drop the style suffix and PostScript decorations, split camel case.
+	if i := strings.IndexAny(name, "-,"); i > 0 {
+		name = name[:i]
+	}
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "MT"), "PS")
+
+	var sb strings.Builder
+	var prev rune
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' {
+			continue
+		}
+		if unicode.IsUpper(r) && unicode.IsLower(prev) {
+			sb.WriteByte(' ')
+		}
+		sb.WriteRune(r)
+		prev = r
+	}
+
+	family := generic
+	if s := sb.String(); s != "" {
+		family = "'" + s + "', " + generic
+	}
+	return family, weight, style
+}
+
+// svgText writes a shown string as text element or as bitmap of the glyph outlines p for non uniform paint.
+func (in *interpreter) svgText(run *svgTextRun, p *path, resources types.Dict) {
+	gs := in.gs
+	mode := gs.renderMode
+	if len(run.glyphs) == 0 || gs.clip.rect.Empty() {
+		return
+	}
+
+	fill := mode == textFill || mode == textFillStroke || mode == textFillClip || mode == textFillStrokeClip
+	stroke := mode == textStroke || mode == textFillStroke || mode == textStrokeClip || mode == textFillStrokeClip
+
+	var attrs strings.Builder
+
+	if fill || mode == textInvisible {
+		pt := in.paint(false, resources)
+		if pt == nil {
+			return
+		}
+		c, ok := pt.(uniformPaint)
+		if !ok {
+			if !p.empty() {
+				in.svgRaster(rasterize(p.flatten(), false, gs.clip.rect), gs.fillAlpha, pt)
+			}
+			return
+		}
+		fmt.Fprintf(&attrs, ` fill="%s"`, svgColor(color.RGBA(c)))
+		if mode == textInvisible {
+			attrs.WriteString(` fill-opacity="0"`)
+		} else {
+			attrs.WriteString(svgOpacity("fill-opacity", gs.fillAlpha))
+		}
+	} else {
+		attrs.WriteString(` fill="none"`)
+	}
+
+	if stroke {
+		pt := in.paint(true, resources)
+		if c, ok := pt.(uniformPaint); ok {
+			fmt.Fprintf(&attrs, ` stroke="%s"%s%s`, svgColor(color.RGBA(c)), in.svgStrokeAttrs(), svgOpacity("stroke-opacity", gs.strokeAlpha))
+		}
+	}
+
+	// Text space of the element: the glyph space of the first glyph flipped and normalized to unit scale.
+	size := scale(run.trm)
+	if size <= 0 {
+		return
+	}
+	m := matrix.Matrix{{1 / size, 0, 0}, {0, -1 / size, 0}, {0, 0, 1}}.Multiply(run.trm)
+	inv, ok := invert(m)
+	if !ok {
+		return
+	}
+
+	var xs, ys []string
+	var sb strings.Builder
+	for _, g := range run.glyphs {
+		rr := []rune(g.text)
+		for i, r := range rr {
+			if unicode.IsControl(r) {
+				r = ' '
+			}
+			q := g.start.add(g.end.sub(g.start).mul(float64(i) / float64(len(rr))))
+			q = transform(inv, q)
+			xs, ys = append(xs, svgNumber(q.x)), append(ys, svgNumber(q.y))
+			sb.WriteRune(r)
+		}
+	}
+	if sb.Len() == 0 {
+		return
+	}
+
+	family, weight, style := fontFamily(gs.font)
+	if weight != "" {
+		fmt.Fprintf(&attrs, ` font-weight="%s"`, weight)
+	}
+	if style != "" {
+		fmt.Fprintf(&attrs, ` font-style="%s"`, style)
+	}
+
+	w := in.svg
+	fmt.Fprintf(&w.body, "<text xml:space=\"preserve\" transform=\"%s\" x=\"%s\" y=\"%s\" font-family=\"%s\" font-size=\"%s\"%s%s>",
+		svgMatrix(m), strings.Join(xs, " "), strings.Join(ys, " "), family, svgNumber(size), attrs.String(), in.svgClipAttr())
+	xml.EscapeText(&w.body, []byte(sb.String()))
+	w.body.WriteString("</text>\n")
+}
+
+// SVG writes page pageNr of ctx as SVG to w.
+// Paths, images and text are written as SVG elements,
+// shadings, patterns and stencil masks are embedded as bitmaps.
+func SVG(ctx *model.Context, pageNr int, w io.Writer) error {
+	d, inhPAttrs, box, err := page(ctx, pageNr)
+	if err != nil {
+		return err
+	}
+
+	rot := (inhPAttrs.Rotate%360 + 360) % 360
+	m, width, height := baseMatrix(box, rot, svgScale)
+	if width <= 0 || height <= 0 || width*height > maxPixels {
+		return errors.Errorf("pdfcpu: render: invalid page size for page %d", pageNr)
+	}
+
+	r, err := newRenderer(ctx)
+	if err != nil {
+		return err
+	}
+
+	sw := &svgWriter{images: map[*decodedImage]string{}}
+	rect := image.Rect(0, 0, width, height)
+
+	newInterpreter := func() *interpreter {
+		in := r.newInterpreter(nil, m, &clipRegion{rect: rect})
+		in.svg = sw
+		return in
+	}
+	if err := drawPage(ctx, d, inhPAttrs, newInterpreter); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" xmlns:xlink=\"http://www.w3.org/1999/xlink\" version=\"1.1\" width=\"%spt\" height=\"%spt\" viewBox=\"0 0 %d %d\">\n",
+		svgNumber(float64(width)/svgScale), svgNumber(float64(height)/svgScale), width, height)
+	if sw.defs.Len() > 0 {
+		fmt.Fprintf(w, "<defs>\n%s</defs>\n", sw.defs.String())
+	}
+	fmt.Fprintf(w, "<rect width=\"%d\" height=\"%d\" fill=\"#ffffff\"/>\n", width, height)
+	if _, err := io.WriteString(w, sw.body.String()); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "</svg>\n")
+	return err
+}
@@ -0,0 +1,262 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/text"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func (in *interpreter) nextLine(tx, ty float64) {
+	in.tlm = translation(tx, ty).Multiply(in.tlm)
+	in.tm = in.tlm
+}
+
+func (in *interpreter) processTextStateOp(op string, oo []types.Object, resources types.Dict) {
+	switch op {
+
+	case "Tf":
+		if len(oo) >= 2 {
+			in.gs.font = nil
+			if n, ok := oo[len(oo)-2].(types.Name); ok {
+				if o := in.r.resource(resources, "Font", n.Value()); o != nil {
+					in.gs.font = in.r.font(o)
+				}
+			}
+			in.gs.fontSize = number(oo[len(oo)-1])
+		}
+
+	case "Tc":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.charSpace = f[0]
+		}
+
+	case "Tw":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.wordSpace = f[0]
+		}
+
+	case "Tz":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.hScale = f[0] / 100
+		}
+
+	case "TL":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.leading = f[0]
+		}
+
+	case "Ts":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.rise = f[0]
+		}
+
+	case "Tr":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.renderMode = int(f[0])
+		}
+	}
+}
+
+func (in *interpreter) processTextOp(op string, oo []types.Object, resources types.Dict, depth int) {
+	switch op {
+
+	case "BT":
+		in.tm, in.tlm = matrix.IdentMatrix, matrix.IdentMatrix
+		in.textClip = path{}
+
+	case "ET":
+		if in.gs.renderMode >= textFillClip {
+			// Glyph outlines of the text object make up the clipping path.
+			in.intersectClip(&in.textClip, false)
+		}
+		in.textClip = path{}
+
+	case "Td":
+		if f, ok := numberOperands(oo, 2); ok {
+			in.nextLine(f[0], f[1])
+		}
+
+	case "TD":
+		if f, ok := numberOperands(oo, 2); ok {
+			in.gs.leading = -f[1]
+			in.nextLine(f[0], f[1])
+		}
+
+	case "Tm":
+		if f, ok := numberOperands(oo, 6); ok {
+			in.tlm = newMatrix(f)
+			in.tm = in.tlm
+		}
+
+	case "T*":
+		in.nextLine(0, -in.gs.leading)
+
+	case "Tj":
+		if len(oo) > 0 {
+			in.showText(stringBytes(oo[len(oo)-1]), resources, depth)
+		}
+
+	case "'":
+		in.nextLine(0, -in.gs.leading)
+		if len(oo) > 0 {
+			in.showText(stringBytes(oo[len(oo)-1]), resources, depth)
+		}
+
+	case "\"":
+		if len(oo) >= 3 {
+			in.gs.wordSpace = number(oo[len(oo)-3])
+			in.gs.charSpace = number(oo[len(oo)-2])
+		}
+		in.nextLine(0, -in.gs.leading)
+		if len(oo) > 0 {
+			in.showText(stringBytes(oo[len(oo)-1]), resources, depth)
+		}
+
+	case "TJ":
+		if len(oo) > 0 {
+			if a, ok := oo[len(oo)-1].(types.Array); ok {
+				in.showTextArray(a, resources, depth)
+			}
+		}
+	}
+}
+
+func (in *interpreter) showTextArray(a types.Array, resources types.Dict, depth int) {
+	for _, o := range a {
+		switch o.(type) {
+		case types.Integer, types.Float:
+			adj := number(o) / 1000 * in.gs.fontSize
+			if in.gs.font != nil && in.gs.font.dec.Vertical() {
+				in.tm = translation(0, -adj).Multiply(in.tm)
+				continue
+			}
+			in.tm = translation(-adj*in.gs.hScale, 0).Multiply(in.tm)
+		default:
+			in.showText(stringBytes(o), resources, depth)
+		}
+	}
+}
+
+// showText paints the glyphs of a string and advances the text matrix.
+func (in *interpreter) showText(bb []byte, resources types.Dict, depth int) {
+	gs := in.gs
+	f := gs.font
+	if f == nil {
+		return
+	}
+
+	mode := gs.renderMode
+	paint := in.hidden == 0 && mode != textInvisible && mode != textClip
+	vertical := f.dec.Vertical()
+
+	// SVG output keeps invisible text for searching and selection.
+	var run *svgTextRun
+	if in.svg != nil && f.type3 == nil && in.hidden == 0 && mode != textClip {
+		run = &svgTextRun{}
+	}
+
+	var p path
+
+	for _, c := range f.dec.Decode(bb) {
+		trm := matrix.Matrix{{gs.fontSize * gs.hScale, 0, 0}, {0, gs.fontSize, 0}, {0, gs.rise, 1}}.Multiply(in.tm).Multiply(gs.ctm)
+
+		if f.type3 != nil {
+			if paint {
+				in.showType3Glyph(f.type3, c, trm, resources, depth)
+			}
+		} else if g := f.glyph(c); g != nil {
+			p.append(g, trm)
+		}
+		if run != nil {
+			run.add(c.Text, trm)
+		}
+
+		var tx, ty float64
+		if vertical {
+			ty = -gs.fontSize + gs.charSpace
+			if c.Space {
+				ty += gs.wordSpace
+			}
+		} else {
+			tx = (c.Width/1000*gs.fontSize + gs.charSpace) * gs.hScale
+			if c.Space {
+				tx += gs.wordSpace * gs.hScale
+			}
+		}
+		in.tm = translation(tx, ty).Multiply(in.tm)
+		if run != nil {
+			run.end(matrix.Matrix{{gs.fontSize * gs.hScale, 0, 0}, {0, gs.fontSize, 0}, {0, gs.rise, 1}}.Multiply(in.tm).Multiply(gs.ctm))
+		}
+	}
+
+	if run != nil {
+		in.svgText(run, &p, resources)
+		if mode >= textFillClip {
+			in.textClip.append(&p, matrix.IdentMatrix)
+		}
+		return
+	}
+
+	if p.empty() {
+		return
+	}
+
+	pls := p.flatten()
+
+	if paint {
+		switch mode {
+		case textFill, textFillClip:
+			in.fill(pls, false, resources)
+		case textStroke, textStrokeClip:
+			in.stroke(pls, resources)
+		case textFillStroke, textFillStrokeClip:
+			in.fill(pls, false, resources)
+			in.stroke(pls, resources)
+		}
+	}
+
+	if mode >= textFillClip {
+		in.textClip.append(&p, matrix.IdentMatrix)
+	}
+}
+
+// showType3Glyph paints a Type 3 glyph by executing its glyph procedure.
+func (in *interpreter) showType3Glyph(f *type3Font, c text.Code, trm matrix.Matrix, resources types.Dict, depth int) {
+	if f.charProcs == nil || c.Name == "" || depth >= maxFormDepth {
+		return
+	}
+
+	o, found := f.charProcs.Find(c.Name)
+	if !found {
+		return
+	}
+	sd, _, err := in.r.ctx.DereferenceStreamDict(o)
+	if err != nil || sd == nil || sd.Decode() != nil {
+		return
+	}
+
+	if f.resources != nil {
+		resources = f.resources
+	}
+
+	in1 := &interpreter{r: in.r, dst: in.dst, svg: in.svg, gs: in.gs, tm: matrix.IdentMatrix, tlm: matrix.IdentMatrix}
+	in1.gs.ctm = f.matrix.Multiply(trm)
+	in1.gs.renderMode = textFill
+	in1.process(sd.Content, resources, depth+1)
+}
@@ -0,0 +1,600 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"encoding/binary"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pkg/errors"
+)
+
+const maxCompositeDepth = 8
+
+var errCorruptTrueType = errors.New("pdfcpu: corrupt TrueType font")
+
+// fontProgram provides glyph outlines of an embedded font program.
+type fontProgram interface {
+	// outline returns the outline of glyph gid in glyph space.
+	outline(gid int) *path
+	// fontMatrix maps glyph space to text space.
+	fontMatrix() matrix.Matrix
+	// gidForName returns the glyph for a glyph name.
+	gidForName(name string) (int, bool)
+	// gidForCode returns the glyph for a code according to the built-in encoding or the symbol cmap.
+	gidForCode(code byte) (int, bool)
+	// gidForRune returns the glyph for a Unicode character.
+	gidForRune(r rune) (int, bool)
+	// gidForCID returns the glyph for a CID of a CID-keyed font.
+	gidForCID(cid int) (int, bool)
+	// advance returns the advance width of glyph gid in text space units.
+	advance(gid int) float64
+}
+
+// trueType is a parsed sfnt font with TrueType or CFF outlines.
+type trueType struct {
+	glyf       []byte
+	loca       []uint32
+	unitsPerEm float64
+	numGlyphs  int
+	advances   []uint16
+	cmapSymbol map[uint32]int // (3,0)
+	cmapMac    map[uint32]int // (1,0)
+	cmapUni    map[uint32]int // (3,1), (3,10), (0,x)
+	names      map[string]int // post table
+	cff        *cff           // OpenType CFF outlines
+}
+
+func u16(bb []byte, off int) int {
+	if off < 0 || off+2 > len(bb) {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(bb[off:]))
+}
+
+func i16(bb []byte, off int) int {
+	return int(int16(u16(bb, off)))
+}
+
+func u32(bb []byte, off int) uint32 {
+	if off < 0 || off+4 > len(bb) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(bb[off:])
+}
+
+func sfntTables(bb []byte) (map[string][]byte, error) {
+	if len(bb) < 12 {
+		return nil, errCorruptTrueType
+	}
+	if string(bb[:4]) == "ttcf" {
+		// Use the first font of a collection.
+		off := int(u32(bb, 12))
+		if off <= 0 || off+12 > len(bb) {
+			return nil, errCorruptTrueType
+		}
+		return sfntTablesAt(bb, off)
+	}
+	return sfntTablesAt(bb, 0)
+}
+
+func sfntTablesAt(bb []byte, off int) (map[string][]byte, error) {
+	n := u16(bb, off+4)
+	m := map[string][]byte{}
+	for i := 0; i < n; i++ {
+		rec := off + 12 + 16*i
+		if rec+16 > len(bb) {
+			return nil, errCorruptTrueType
+		}
+		tag := string(bb[rec : rec+4])
+		o, l := int(u32(bb, rec+8)), int(u32(bb, rec+12))
+		if o < 0 || o > len(bb) {
+			continue
+		}
+		if l < 0 || o+l > len(bb) {
+			// Be lenient with truncated tables.
+			l = len(bb) - o
+		}
+		m[tag] = bb[o : o+l]
+	}
+	return m, nil
+}
+
+func parseCmapSubtable(bb []byte) map[uint32]int {
+	m := map[uint32]int{}
+	switch u16(bb, 0) {
+
+	case 0:
+		for c := 0; c < 256 && 6+c < len(bb); c++ {
+			if gid := int(bb[6+c]); gid != 0 {
+				m[uint32(c)] = gid
+			}
+		}
+
+	case 4:
+		segX2 := u16(bb, 6)
+		ends, starts := 14, 16+segX2
+		deltas, ranges := starts+segX2, starts+2*segX2
+		for i := 0; i < segX2; i += 2 {
+			end, start := u16(bb, ends+i), u16(bb, starts+i)
+			delta, ro := u16(bb, deltas+i), u16(bb, ranges+i)
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				var gid int
+				if ro == 0 {
+					gid = (c + delta) & 0xFFFF
+				} else {
+					off := ranges + i + ro + 2*(c-start)
+					if gid = u16(bb, off); gid != 0 {
+						gid = (gid + delta) & 0xFFFF
+					}
+				}
+				if gid != 0 {
+					m[uint32(c)] = gid
+				}
+			}
+		}
+
+	case 6:
+		first, count := u16(bb, 6), u16(bb, 8)
+		for i := 0; i < count; i++ {
+			if gid := u16(bb, 10+2*i); gid != 0 {
+				m[uint32(first+i)] = gid
+			}
+		}
+
+	case 12:
+		n := int(u32(bb, 12))
+		for i := 0; i < n && 16+12*i+12 <= len(bb); i++ {
+			g := 16 + 12*i
+			start, end, gid := u32(bb, g), u32(bb, g+4), int(u32(bb, g+8))
+			if end < start || end-start > 0x10000 {
+				continue
+			}
+			for c := start; c <= end; c++ {
+				m[c] = gid + int(c-start)
+			}
+		}
+	}
+	return m
+}
+
+func (tt *trueType) parseCmap(bb []byte) {
+	n := u16(bb, 2)
+	for i := 0; i < n; i++ {
+		rec := 4 + 8*i
+		pid, eid, off := u16(bb, rec), u16(bb, rec+2), int(u32(bb, rec+4))
+		if off <= 0 || off >= len(bb) {
+			continue
+		}
+		sub := bb[off:]
+		switch {
+		case pid == 3 && eid == 0:
+			tt.cmapSymbol = parseCmapSubtable(sub)
+		case pid == 1 && eid == 0:
+			tt.cmapMac = parseCmapSubtable(sub)
+		case pid == 3 && (eid == 1 || eid == 10), pid == 0:
+			if tt.cmapUni == nil || eid == 10 {
+				tt.cmapUni = parseCmapSubtable(sub)
+			}
+		}
+	}
+}
+
+// parsePost parses glyph names of a version 2 post table.
+func (tt *trueType) parsePost(bb []byte) {
+	if u32(bb, 0) != 0x00020000 {
+		return
+	}
+	n := u16(bb, 32)
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = u16(bb, 34+2*i)
+	}
+
+	var custom []string
+	for off := 34 + 2*n; off < len(bb); {
+		l := int(bb[off])
+		if off+1+l > len(bb) {
+			break
+		}
+		custom = append(custom, string(bb[off+1:off+1+l]))
+		off += 1 + l
+	}
+
+	std := macGlyphNames
+	tt.names = map[string]int{}
+	for gid, i := range idx {
+		var name string
+		switch {
+		case i < len(std):
+			name = std[i]
+		case i-len(std) < len(custom):
+			name = custom[i-len(std)]
+		}
+		if _, ok := tt.names[name]; !ok && name != "" {
+			tt.names[name] = gid
+		}
+	}
+}
+
+// parseTrueType parses an sfnt font program.
+func parseTrueType(bb []byte) (*trueType, error) {
+	tables, err := sfntTables(bb)
+	if err != nil {
+		return nil, err
+	}
+
+	tt := &trueType{unitsPerEm: 1000}
+
+	head := tables["head"]
+	if upem := u16(head, 18); upem > 0 {
+		tt.unitsPerEm = float64(upem)
+	}
+	locaLong := i16(head, 50) == 1
+	tt.numGlyphs = u16(tables["maxp"], 4)
+
+	if cmap, ok := tables["cmap"]; ok {
+		tt.parseCmap(cmap)
+	}
+	if post, ok := tables["post"]; ok {
+		tt.parsePost(post)
+	}
+
+	if hmtx, ok := tables["hmtx"]; ok {
+		n := u16(tables["hhea"], 34)
+		for i := 0; i < n && 4*i+2 <= len(hmtx); i++ {
+			tt.advances = append(tt.advances, uint16(u16(hmtx, 4*i)))
+		}
+	}
+
+	if cff1, ok := tables["CFF "]; ok {
+		if tt.cff, err = parseCFF(cff1); err != nil {
+			return nil, err
+		}
+		if tt.numGlyphs == 0 {
+			tt.numGlyphs = len(tt.cff.charStrings)
+		}
+		return tt, nil
+	}
+
+	tt.glyf = tables["glyf"]
+	loca := tables["loca"]
+	if tt.glyf == nil || loca == nil {
+		return nil, errCorruptTrueType
+	}
+
+	if tt.numGlyphs == 0 {
+		tt.numGlyphs = len(loca)/2 - 1
+		if locaLong {
+			tt.numGlyphs = len(loca)/4 - 1
+		}
+	}
+
+	for i := 0; i <= tt.numGlyphs; i++ {
+		if locaLong {
+			if 4*i+4 > len(loca) {
+				break
+			}
+			tt.loca = append(tt.loca, u32(loca, 4*i))
+			continue
+		}
+		if 2*i+2 > len(loca) {
+			break
+		}
+		tt.loca = append(tt.loca, uint32(u16(loca, 2*i))*2)
+	}
+
+	return tt, nil
+}
+
+func (tt *trueType) fontMatrix() matrix.Matrix {
+	if tt.cff != nil {
+		return tt.cff.fontMatrix()
+	}
+	s := 1 / tt.unitsPerEm
+	return matrix.Matrix{{s, 0, 0}, {0, s, 0}, {0, 0, 1}}
+}
+
+func (tt *trueType) validGID(gid int) bool {
+	return gid >= 0 && (tt.numGlyphs == 0 || gid < tt.numGlyphs)
+}
+
+func (tt *trueType) gidForName(name string) (int, bool) {
+	if tt.cff != nil {
+		if gid, ok := tt.cff.gidForName(name); ok {
+			return gid, true
+		}
+	}
+	gid, ok := tt.names[name]
+	return gid, ok
+}
+
+func (tt *trueType) gidForCode(code byte) (int, bool) {
+	if tt.cmapSymbol != nil {
+		for _, base := range []uint32{0, 0xF000, 0xF100, 0xF200} {
+			if gid, ok := tt.cmapSymbol[base+uint32(code)]; ok {
+				return gid, true
+			}
+		}
+	}
+	if tt.cmapMac != nil {
+		if gid, ok := tt.cmapMac[uint32(code)]; ok {
+			return gid, true
+		}
+	}
+	if tt.cff != nil {
+		return tt.cff.gidForCode(code)
+	}
+	return 0, false
+}
+
+func (tt *trueType) gidForRune(r rune) (int, bool) {
+	if gid, ok := tt.cmapUni[uint32(r)]; ok {
+		return gid, true
+	}
+	return 0, false
+}
+
+func (tt *trueType) gidForCID(cid int) (int, bool) {
+	if tt.cff != nil {
+		return tt.cff.gidForCID(cid)
+	}
+	return cid, tt.validGID(cid)
+}
+
+func (tt *trueType) advance(gid int) float64 {
+	if len(tt.advances) == 0 || gid < 0 {
+		return 0
+	}
+	if gid >= len(tt.advances) {
+		gid = len(tt.advances) - 1
+	}
+	return float64(tt.advances[gid]) / tt.unitsPerEm
+}
+
+func (tt *trueType) outline(gid int) *path {
+	if tt.cff != nil {
+		return tt.cff.outline(gid)
+	}
+	p := &path{}
+	tt.appendGlyph(p, gid, matrix.IdentMatrix, 0)
+	return p
+}
+
+func (tt *trueType) glyphData(gid int) []byte {
+	if gid < 0 || gid+1 >= len(tt.loca) {
+		return nil
+	}
+	start, end := tt.loca[gid], tt.loca[gid+1]
+	if start >= end || int(end) > len(tt.glyf) {
+		return nil
+	}
+	return tt.glyf[start:end]
+}
+
+func (tt *trueType) appendGlyph(p *path, gid int, m matrix.Matrix, depth int) {
+	bb := tt.glyphData(gid)
+	if len(bb) < 10 || depth > maxCompositeDepth {
+		return
+	}
+	if n := i16(bb, 0); n >= 0 {
+		tt.appendSimpleGlyph(p, bb, n, m)
+		return
+	}
+	tt.appendCompositeGlyph(p, bb, m, depth)
+}
+
+type ttPoint struct {
+	x, y    float64
+	onCurve bool
+}
+
+func (tt *trueType) appendSimpleGlyph(p *path, bb []byte, contours int, m matrix.Matrix) {
+	ends := make([]int, contours)
+	for i := range ends {
+		ends[i] = u16(bb, 10+2*i)
+	}
+	if contours == 0 {
+		return
+	}
+	n := ends[contours-1] + 1
+
+	off := 10 + 2*contours
+	off += 2 + u16(bb, off) // instructions
+
+	// Flags
+	flags := make([]byte, 0, n)
+	for len(flags) < n && off < len(bb) {
+		f := bb[off]
+		off++
+		flags = append(flags, f)
+		if f&8 != 0 && off < len(bb) {
+			r := int(bb[off])
+			off++
+			for ; r > 0 && len(flags) < n; r-- {
+				flags = append(flags, f)
+			}
+		}
+	}
+	if len(flags) < n {
+		return
+	}
+
+	coords := func(short, same byte) []float64 {
+		cc := make([]float64, n)
+		v := 0
+		for i, f := range flags {
+			switch {
+			case f&short != 0:
+				if off >= len(bb) {
+					return cc
+				}
+				d := int(bb[off])
+				off++
+				if f&same == 0 {
+					d = -d
+				}
+				v += d
+			case f&same == 0:
+				v += i16(bb, off)
+				off += 2
+			}
+			cc[i] = float64(v)
+		}
+		return cc
+	}
+
+	xs := coords(2, 16)
+	ys := coords(4, 32)
+
+	start := 0
+	for _, end := range ends {
+		if end < start || end >= n {
+			return
+		}
+		pts := make([]ttPoint, 0, end-start+1)
+		for i := start; i <= end; i++ {
+			pts = append(pts, ttPoint{xs[i], ys[i], flags[i]&1 != 0})
+		}
+		appendContour(p, pts, m)
+		start = end + 1
+	}
+}
+
+// appendContour appends a quadratic B-spline contour to p.
+func appendContour(p *path, pts []ttPoint, m matrix.Matrix) {
+	if len(pts) == 0 {
+		return
+	}
+
+	pt := func(q ttPoint) point {
+		return transform(m, point{q.x, q.y})
+	}
+	mid := func(a, b ttPoint) ttPoint {
+		return ttPoint{(a.x + b.x) / 2, (a.y + b.y) / 2, true}
+	}
+
+	// Start at an on curve point.
+	first := -1
+	for i, q := range pts {
+		if q.onCurve {
+			first = i
+			break
+		}
+	}
+
+	var (
+		start ttPoint
+		rest  []ttPoint
+	)
+
+	if first < 0 {
+		// All points are off curve.
+		start = mid(pts[0], pts[1%len(pts)])
+		rest = append(append(rest, pts[1:]...), pts[0])
+	} else {
+		start = pts[first]
+		rest = append(append(rest, pts[first+1:]...), pts[:first]...)
+	}
+
+	p.moveTo(pt(start))
+
+	var ctrl *ttPoint
+	for i := range rest {
+		q := rest[i]
+		if q.onCurve {
+			if ctrl != nil {
+				p.quadTo(pt(*ctrl), pt(q))
+				ctrl = nil
+				continue
+			}
+			p.lineTo(pt(q))
+			continue
+		}
+		if ctrl != nil {
+			p.quadTo(pt(*ctrl), pt(mid(*ctrl, q)))
+		}
+		ctrl = &rest[i]
+	}
+
+	if ctrl != nil {
+		p.quadTo(pt(*ctrl), pt(start))
+	}
+	p.close()
+}
+
+func f2dot14(bb []byte, off int) float64 {
+	return float64(i16(bb, off)) / 16384
+}
+
+func (tt *trueType) appendCompositeGlyph(p *path, bb []byte, m matrix.Matrix, depth int) {
+	const (
+		argsAreWords  = 0x0001
+		argsAreXY     = 0x0002
+		haveScale     = 0x0008
+		moreComps     = 0x0020
+		haveXYScale   = 0x0040
+		haveTwoByTwo  = 0x0080
+		maxComponents = 256
+	)
+
+	off := 10
+	for i := 0; i < maxComponents; i++ {
+		if off+4 > len(bb) {
+			return
+		}
+		flags, gid := u16(bb, off), u16(bb, off+2)
+		off += 4
+
+		var dx, dy float64
+		if flags&argsAreWords != 0 {
+			dx, dy = float64(i16(bb, off)), float64(i16(bb, off+2))
+			off += 4
+		} else {
+			if off+2 > len(bb) {
+				return
+			}
+			dx, dy = float64(int8(bb[off])), float64(int8(bb[off+1]))
+			off += 2
+		}
+		if flags&argsAreXY == 0 {
+			// Point matching is not supported.
+			dx, dy = 0, 0
+		}
+
+		a, b, c, d := 1., 0., 0., 1.
+		switch {
+		case flags&haveScale != 0:
+			a = f2dot14(bb, off)
+			d = a
+			off += 2
+		case flags&haveXYScale != 0:
+			a, d = f2dot14(bb, off), f2dot14(bb, off+2)
+			off += 4
+		case flags&haveTwoByTwo != 0:
+			a, b, c, d = f2dot14(bb, off), f2dot14(bb, off+2), f2dot14(bb, off+4), f2dot14(bb, off+6)
+			off += 8
+		}
+
+		cm := matrix.Matrix{{a, b, 0}, {c, d, 0}, {dx, dy, 1}}
+		tt.appendGlyph(p, gid, cm.Multiply(m), depth+1)
+
+		if flags&moreComps == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,590 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package render
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/text"
+	"github.com/pkg/errors"
+)
+
+var errCorruptType1 = errors.New("pdfcpu: corrupt Type1 font")
+
+// type1 is a parsed Type 1 font program (see Adobe Type 1 Font Format).
+type type1 struct {
+	charStrings [][]byte
+	subrs       [][]byte
+	names       map[string]int // glyph name -> gid
+	encoding    [256]string    // built-in encoding
+	matrix      matrix.Matrix
+}
+
+func decrypt(bb []byte, r uint16, skip int) []byte {
+	res := make([]byte, 0, len(bb))
+	for i, c := range bb {
+		if i >= skip {
+			res = append(res, c^byte(r>>8))
+		}
+		r = (uint16(c)+r)*52845 + 22719
+	}
+	return res
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func hexDecode(bb []byte) []byte {
+	res := make([]byte, 0, len(bb)/2)
+	var c byte
+	odd := false
+	for _, b := range bb {
+		if !isHexDigit(b) {
+			continue
+		}
+		v := b - '0'
+		switch {
+		case b >= 'a':
+			v = b - 'a' + 10
+		case b >= 'A':
+			v = b - 'A' + 10
+		}
+		if odd {
+			res = append(res, c<<4|v)
+		} else {
+			c = v
+		}
+		odd = !odd
+	}
+	return res
+}
+
+// stripPFB removes the segment headers of fonts in PFB format.
+func stripPFB(bb []byte) []byte {
+	if len(bb) < 6 || bb[0] != 0x80 {
+		return bb
+	}
+	var res []byte
+	for len(bb) >= 6 && bb[0] == 0x80 && bb[1] != 3 {
+		n := int(bb[2]) | int(bb[3])<<8 | int(bb[4])<<16 | int(bb[5])<<24
+		bb = bb[6:]
+		if n > len(bb) || n < 0 {
+			n = len(bb)
+		}
+		res = append(res, bb[:n]...)
+		bb = bb[n:]
+	}
+	return res
+}
+
+// ps1Scanner splits PostScript code into tokens.
+type ps1Scanner struct {
+	bb []byte
+	i  int
+}
+
+func (s *ps1Scanner) token() string {
+	for s.i < len(s.bb) {
+		c := s.bb[s.i]
+		if isWhitespace(c) {
+			s.i++
+			continue
+		}
+		if c == '%' {
+			for s.i < len(s.bb) && s.bb[s.i] != '\n' && s.bb[s.i] != '\r' {
+				s.i++
+			}
+			continue
+		}
+		break
+	}
+	if s.i >= len(s.bb) {
+		return ""
+	}
+
+	start := s.i
+	if c := s.bb[s.i]; bytes.IndexByte([]byte("{}[]()<>"), c) >= 0 {
+		s.i++
+		return string(c)
+	}
+	s.i++
+	for s.i < len(s.bb) {
+		c := s.bb[s.i]
+		if isWhitespace(c) || bytes.IndexByte([]byte("{}[]()<>/%"), c) >= 0 {
+			break
+		}
+		s.i++
+	}
+	return string(s.bb[start:s.i])
+}
+
+func (f *type1) parseCleartext(bb []byte) {
+	s := &ps1Scanner{bb: bb}
+	for t := s.token(); t != ""; t = s.token() {
+		switch t {
+
+		case "/FontMatrix":
+			var fm []float64
+			if s.token() != "[" {
+				continue
+			}
+			for t := s.token(); t != "]" && t != ""; t = s.token() {
+				v, err := strconv.ParseFloat(t, 64)
+				if err != nil {
+					break
+				}
+				fm = append(fm, v)
+			}
+			if len(fm) == 6 {
+				f.matrix = matrix.Matrix{{fm[0], fm[1], 0}, {fm[2], fm[3], 0}, {fm[4], fm[5], 1}}
+			}
+
+		case "/Encoding":
+			t := s.token()
+			if t == "StandardEncoding" {
+				f.encoding = text.EncodingNames("StandardEncoding")
+				continue
+			}
+			for t != "" && t != "readonly" && t != "def" {
+				if t == "dup" {
+					code, err := strconv.Atoi(s.token())
+					name := s.token()
+					if err == nil && code >= 0 && code < 256 && len(name) > 1 && name[0] == '/' {
+						f.encoding[code] = name[1:]
+					}
+				}
+				t = s.token()
+			}
+		}
+	}
+}
+
+// binary returns n bytes following the RD token at the scanner position.
+func (s *ps1Scanner) binary(n int) []byte {
+	start := s.i + 1
+	if n < 0 || start+n > len(s.bb) {
+		s.i = len(s.bb)
+		return nil
+	}
+	s.i = start + n
+	return s.bb[start : start+n]
+}
+
+func (f *type1) parsePrivate(bb []byte) {
+	lenIV := 4
+	s := &ps1Scanner{bb: bb}
+
+	var prev1, prev2 string
+	inCharStrings := false
+
+	for t := s.token(); t != ""; t = s.token() {
+		switch t {
+
+		case "/lenIV":
+			if i, err := strconv.Atoi(s.token()); err == nil {
+				lenIV = i
+			}
+
+		case "/Subrs":
+			if n, err := strconv.Atoi(s.token()); err == nil && n > 0 && n < 1<<16 {
+				f.subrs = make([][]byte, n)
+			}
+
+		case "/CharStrings":
+			inCharStrings = true
+
+		case "RD", "-|":
+			n, err := strconv.Atoi(prev1)
+			if err != nil {
+				break
+			}
+			cs := s.binary(n)
+			if lenIV >= 0 {
+				cs = decrypt(cs, 4330, lenIV)
+			}
+			if inCharStrings {
+				if len(prev2) > 1 && prev2[0] == '/' {
+					f.names[prev2[1:]] = len(f.charStrings)
+					f.charStrings = append(f.charStrings, cs)
+				}
+			} else if i, err := strconv.Atoi(prev2); err == nil && i >= 0 && i < len(f.subrs) {
+				f.subrs[i] = cs
+			}
+		}
+
+		prev2, prev1 = prev1, t
+	}
+}
+
+// parseType1 parses the Type 1 font program bb.
+func parseType1(bb []byte) (*type1, error) {
+	bb = stripPFB(bb)
+
+	i := bytes.Index(bb, []byte("eexec"))
+	if i < 0 {
+		return nil, errCorruptType1
+	}
+
+	f := &type1{names: map[string]int{}, matrix: matrix.Matrix{{0.001, 0, 0}, {0, 0.001, 0}, {0, 0, 1}}}
+	f.parseCleartext(bb[:i])
+
+	j := i + 5
+	for j < len(bb) && isWhitespace(bb[j]) {
+		j++
+	}
+	enc := bb[j:]
+	if len(enc) >= 4 && isHexDigit(enc[0]) && isHexDigit(enc[1]) && isHexDigit(enc[2]) && isHexDigit(enc[3]) {
+		enc = hexDecode(enc)
+	}
+	f.parsePrivate(decrypt(enc, 55665, 4))
+
+	if len(f.charStrings) == 0 {
+		return nil, errCorruptType1
+	}
+
+	return f, nil
+}
+
+func (f *type1) fontMatrix() matrix.Matrix {
+	return f.matrix
+}
+
+func (f *type1) gidForName(name string) (int, bool) {
+	gid, ok := f.names[name]
+	return gid, ok
+}
+
+func (f *type1) gidForCode(code byte) (int, bool) {
+	if name := f.encoding[code]; name != "" {
+		return f.gidForName(name)
+	}
+	return 0, false
+}
+
+func (f *type1) gidForRune(r rune) (int, bool) {
+	return 0, false
+}
+
+func (f *type1) gidForCID(cid int) (int, bool) {
+	return 0, false
+}
+
+func (f *type1) advance(gid int) float64 {
+	return 0
+}
+
+func (f *type1) outline(gid int) *path {
+	p := &path{}
+	if gid < 0 || gid >= len(f.charStrings) {
+		return p
+	}
+	cs := &type1Interpreter{f: f, p: p}
+	cs.run(f.charStrings[gid], 0)
+	p.close()
+	return p
+}
+
+// type1Interpreter executes Type 1 charstrings.
+type type1Interpreter struct {
+	f       *type1
+	p       *path
+	stack   []float64
+	psStack []float64
+	x, y    float64
+	open    bool
+	flex    bool
+	flexPts []point
+	ops     int
+	seac    bool
+}
+
+func (cs *type1Interpreter) push(f float64) {
+	if len(cs.stack) < maxCharStringStack {
+		cs.stack = append(cs.stack, f)
+	}
+}
+
+func (cs *type1Interpreter) pop() float64 {
+	n := len(cs.stack)
+	if n == 0 {
+		return 0
+	}
+	f := cs.stack[n-1]
+	cs.stack = cs.stack[:n-1]
+	return f
+}
+
+func (cs *type1Interpreter) arg(i int) float64 {
+	if i < len(cs.stack) {
+		return cs.stack[i]
+	}
+	return 0
+}
+
+func (cs *type1Interpreter) moveTo(dx, dy float64) {
+	cs.x += dx
+	cs.y += dy
+	if cs.flex {
+		cs.flexPts = append(cs.flexPts, point{cs.x, cs.y})
+		return
+	}
+	if cs.open {
+		cs.p.close()
+	}
+	cs.p.moveTo(point{cs.x, cs.y})
+	cs.open = true
+}
+
+func (cs *type1Interpreter) lineTo(dx, dy float64) {
+	cs.x += dx
+	cs.y += dy
+	cs.p.lineTo(point{cs.x, cs.y})
+}
+
+func (cs *type1Interpreter) curveTo(dx1, dy1, dx2, dy2, dx3, dy3 float64) {
+	x1, y1 := cs.x+dx1, cs.y+dy1
+	x2, y2 := x1+dx2, y1+dy2
+	cs.x, cs.y = x2+dx3, y2+dy3
+	cs.p.curveTo(point{x1, y1}, point{x2, y2}, point{cs.x, cs.y})
+}
+
+// run executes the charstring bb and returns true on endchar.
+func (cs *type1Interpreter) run(bb []byte, depth int) bool {
+	if depth > maxCharStringDepth {
+		return true
+	}
+
+	for i := 0; i < len(bb); {
+		cs.ops++
+		if cs.ops > maxCharStringOps {
+			return true
+		}
+
+		b := int(bb[i])
+		i++
+
+		switch {
+		case b >= 32 && b <= 246:
+			cs.push(float64(b - 139))
+			continue
+		case b >= 247 && b <= 250:
+			if i < len(bb) {
+				cs.push(float64((b-247)*256 + int(bb[i]) + 108))
+			}
+			i++
+			continue
+		case b >= 251 && b <= 254:
+			if i < len(bb) {
+				cs.push(float64(-(b-251)*256 - int(bb[i]) - 108))
+			}
+			i++
+			continue
+		case b == 255:
+			cs.push(float64(int32(u32(bb, i))))
+			i += 4
+			continue
+		}
+
+		switch b {
+
+		case 13: // hsbw
+			cs.x, cs.y = cs.arg(0), 0
+
+		case 21: // rmoveto
+			cs.moveTo(cs.arg(0), cs.arg(1))
+
+		case 22: // hmoveto
+			cs.moveTo(cs.arg(0), 0)
+
+		case 4: // vmoveto
+			cs.moveTo(0, cs.arg(0))
+
+		case 5: // rlineto
+			cs.lineTo(cs.arg(0), cs.arg(1))
+
+		case 6: // hlineto
+			cs.lineTo(cs.arg(0), 0)
+
+		case 7: // vlineto
+			cs.lineTo(0, cs.arg(0))
+
+		case 8: // rrcurveto
+			cs.curveTo(cs.arg(0), cs.arg(1), cs.arg(2), cs.arg(3), cs.arg(4), cs.arg(5))
+
+		case 30: // vhcurveto
+			cs.curveTo(0, cs.arg(0), cs.arg(1), cs.arg(2), cs.arg(3), 0)
+
+		case 31: // hvcurveto
+			cs.curveTo(cs.arg(0), 0, cs.arg(1), cs.arg(2), 0, cs.arg(3))
+
+		case 9: // closepath
+			if cs.open {
+				cs.p.close()
+				cs.open = false
+			}
+
+		case 10: // callsubr
+			idx := int(cs.pop())
+			if idx >= 0 && idx < len(cs.f.subrs) {
+				if cs.run(cs.f.subrs[idx], depth+1) {
+					return true
+				}
+			}
+			continue
+
+		case 11: // return
+			return false
+
+		case 14: // endchar
+			if cs.open {
+				cs.p.close()
+				cs.open = false
+			}
+			return true
+
+		case 12:
+			if i >= len(bb) {
+				return true
+			}
+			b2 := int(bb[i])
+			i++
+			if cs.escape(b2) {
+				return true
+			}
+			continue
+		}
+
+		cs.stack = cs.stack[:0]
+	}
+
+	return false
+}
+
+// escape executes an escaped operator and returns true on seac.
+func (cs *type1Interpreter) escape(op int) bool {
+	switch op {
+
+	case 7: // sbw
+		cs.x, cs.y = cs.arg(0), cs.arg(1)
+
+	case 6: // seac
+		if !cs.seac {
+			cs.accented()
+			return true
+		}
+
+	case 12: // div
+		b, a := cs.pop(), cs.pop()
+		if b != 0 {
+			cs.push(a / b)
+		} else {
+			cs.push(0)
+		}
+		return false
+
+	case 16: // callothersubr
+		cs.callOtherSubr()
+		return false
+
+	case 17: // pop
+		if n := len(cs.psStack); n > 0 {
+			cs.push(cs.psStack[n-1])
+			cs.psStack = cs.psStack[:n-1]
+		}
+		return false
+
+	case 33: // setcurrentpoint
+		cs.x, cs.y = cs.arg(0), cs.arg(1)
+	}
+
+	cs.stack = cs.stack[:0]
+	return false
+}
+
+func (cs *type1Interpreter) callOtherSubr() {
+	nr, n := int(cs.pop()), int(cs.pop())
+	if n < 0 || n > len(cs.stack) {
+		n = len(cs.stack)
+	}
+	args := append([]float64{}, cs.stack[len(cs.stack)-n:]...)
+	cs.stack = cs.stack[:len(cs.stack)-n]
+
+	switch nr {
+
+	case 0: // end flex
+		cs.flex = false
+		pts := cs.flexPts
+		if len(pts) == 7 {
+			cs.p.curveTo(pts[1], pts[2], pts[3])
+			cs.p.curveTo(pts[4], pts[5], pts[6])
+		}
+		if len(pts) > 0 {
+			cs.x, cs.y = pts[len(pts)-1].x, pts[len(pts)-1].y
+		}
+		cs.psStack = []float64{cs.y, cs.x}
+		return
+
+	case 1: // start flex
+		cs.flex = true
+		cs.flexPts = nil
+		cs.psStack = nil
+		return
+	}
+
+	// Results of other subroutines are their arguments.
+	cs.psStack = cs.psStack[:0]
+	for i := len(args) - 1; i >= 0; i-- {
+		cs.psStack = append(cs.psStack, args[i])
+	}
+}
+
+// accented composes a glyph from two StandardEncoding glyphs (seac).
+func (cs *type1Interpreter) accented() {
+	asb, adx, ady := cs.arg(0), cs.arg(1), cs.arg(2)
+	bchar, achar := int(cs.arg(3)), int(cs.arg(4))
+	cs.stack = cs.stack[:0]
+
+	if bchar < 0 || bchar > 255 || achar < 0 || achar > 255 {
+		return
+	}
+	std := text.EncodingNames("StandardEncoding")
+
+	compose := func(name string, dx, dy float64) {
+		gid, ok := cs.f.gidForName(name)
+		if !ok {
+			return
+		}
+		cs1 := &type1Interpreter{f: cs.f, p: &path{}, seac: true}
+		cs1.run(cs.f.charStrings[gid], 0)
+		cs1.p.close()
+		cs.p.append(cs1.p, translation(dx, dy))
+	}
+
+	if cs.open {
+		cs.p.close()
+		cs.open = false
+	}
+	compose(std[bchar], 0, 0)
+	compose(std[achar], adx-asb, ady)
+}
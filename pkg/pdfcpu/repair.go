@@ -0,0 +1,91 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+func isPageTreeNode(d types.Dict) bool {
+	if t := d.Type(); t != nil {
+		return *t == "Pages"
+	}
+	_, found := d.Find("Kids")
+	return found
+}
+
+func repairPageTreeNode(ctx *model.Context, indRef types.IndirectRef, visited types.IntSet) (int, error) {
+	objNr := indRef.ObjectNumber.Value()
+	if visited[objNr] {
+		// Circular page tree.
+		return 0, nil
+	}
+	visited[objNr] = true
+
+	d, err := ctx.DereferenceDict(indRef)
+	if err != nil || d == nil {
+		return 0, err
+	}
+
+	if !isPageTreeNode(d) {
+		// Page
+		return 1, nil
+	}
+
+	kids, err := ctx.DereferenceArray(d["Kids"])
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, o := range kids {
+		ir, ok := o.(types.IndirectRef)
+		if !ok {
+			continue
+		}
+		i, err := repairPageTreeNode(ctx, ir, visited)
+		if err != nil {
+			return 0, err
+		}
+		count += i
+	}
+
+	if c := d.IntEntry("Count"); c == nil || *c != count {
+		d["Count"] = types.Integer(count)
+		ctx.RecordRepair(objNr, model.RepairPageCount, fmt.Sprintf("page tree node obj#%d: Count %d", objNr, count))
+	}
+
+	return count, nil
+}
+
+// RepairPageTree ensures a correct "Count" for all nodes of the page tree.
+func RepairPageTree(ctx *model.Context) error {
+	indRef, err := ctx.Pages()
+	if err != nil {
+		return err
+	}
+	if indRef == nil {
+		return errors.New("pdfcpu: RepairPageTree: missing \"Pages\"")
+	}
+
+	_, err = repairPageTreeNode(ctx, *indRef, types.IntSet{})
+	return err
+}
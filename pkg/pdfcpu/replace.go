@@ -0,0 +1,600 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/content"
+	pdffont "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/text"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// Max. nesting level of form XObjects subject to text replacement.
+const maxReplaceDepth = 16
+
+// UnreplacedText describes an occurrence of the search text which could not be replaced.
+type UnreplacedText struct {
+	PageNr int    `json:"page"`
+	Font   string `json:"font,omitempty"` // font resource name
+	Reason string `json:"reason"`
+}
+
+func (u UnreplacedText) String() string {
+	if u.Font == "" {
+		return fmt.Sprintf("page %d: %s", u.PageNr, u.Reason)
+	}
+	return fmt.Sprintf("page %d: font %s: %s", u.PageNr, u.Font, u.Reason)
+}
+
+// replaceFont is a font used for showing text subject to replacement.
+type replaceFont struct {
+	f        *text.Font
+	d        types.Dict
+	name     string // PostScript name without subset tag
+	subset   bool
+	userFont bool   // embedded pdfcpu user font encoded using glyph ids
+	reason   string // why text shown using this font can't be replaced
+}
+
+type textReplacer struct {
+	ctx       *model.Context
+	old, new  string
+	fonts     map[int]*replaceFont  // keyed by font dict object number
+	forms     map[int]bool          // form XObjects already processed
+	updates   map[*replaceFont]bool // user font subsets in need of new glyphs
+	count     int
+	failures  []UnreplacedText
+	pageNr    int
+	fontName  string
+	font      *replaceFont
+	fontStack []textFont
+}
+
+type textFont struct {
+	name string
+	f    *replaceFont
+}
+
+func (tr *textReplacer) resource(res types.Dict, category, name string) types.Object {
+	if res == nil {
+		return nil
+	}
+	d, err := tr.ctx.DereferenceDict(res[category])
+	if err != nil || d == nil {
+		return nil
+	}
+	o, _ := d.Find(name)
+	return o
+}
+
+func (tr *textReplacer) loadFont(o types.Object) (*replaceFont, error) {
+	indRef, ok := o.(types.IndirectRef)
+	if ok {
+		if f, ok := tr.fonts[indRef.ObjectNumber.Value()]; ok {
+			return f, nil
+		}
+	}
+
+	d, err := tr.ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	f := &replaceFont{d: d}
+
+	switch st := d.Subtype(); {
+	case st == nil:
+		f.reason = "corrupt font dict"
+	case *st == "Type3":
+		f.reason = "unsupported Type3 font"
+	case *st == "Type0":
+		if enc := d.NameEntry("Encoding"); enc == nil || *enc != "Identity-H" {
+			f.reason = "unsupported composite font encoding"
+		}
+	}
+
+	// Text shown using unsupported fonts gets decoded for reporting.
+	if f.f, err = text.LoadFont(tr.ctx.XRefTable, d); err != nil {
+		return nil, err
+	}
+
+	if f.reason == "" {
+		objNr := 0
+		if ok {
+			objNr = indRef.ObjectNumber.Value()
+		}
+		prefix, name, err := pdffont.Name(tr.ctx.XRefTable, d, objNr)
+		if err != nil {
+			return nil, err
+		}
+		f.name, f.subset = name, prefix != ""
+		if st := d.Subtype(); *st == "Type0" && font.IsUserFont(name) {
+			f.userFont = embeddedFontFile(tr.ctx.XRefTable, d)
+		}
+	}
+
+	if ok {
+		tr.fonts[indRef.ObjectNumber.Value()] = f
+	}
+
+	return f, nil
+}
+
+// embeddedFontFile returns true if the CIDFont of the Type0 font dict d comes with a TrueType font program.
+func embeddedFontFile(xRefTable *model.XRefTable, d types.Dict) bool {
+	a, err := xRefTable.DereferenceArray(d["DescendantFonts"])
+	if err != nil || len(a) != 1 {
+		return false
+	}
+	df, err := xRefTable.DereferenceDict(a[0])
+	if err != nil || df == nil {
+		return false
+	}
+	fd, err := xRefTable.DereferenceDict(df["FontDescriptor"])
+	if err != nil || fd == nil {
+		return false
+	}
+	_, found := fd.Find("FontFile2")
+	return found
+}
+
+func (tr *textReplacer) setFont(res types.Dict, name string) error {
+	tr.fontName, tr.font = name, nil
+	o := tr.resource(res, "Font", name)
+	if o == nil {
+		return nil
+	}
+	f, err := tr.loadFont(o)
+	if err != nil {
+		return err
+	}
+	tr.font = f
+	return nil
+}
+
+func (tr *textReplacer) fail(reason string) {
+	tr.failures = append(tr.failures, UnreplacedText{PageNr: tr.pageNr, Font: tr.fontName, Reason: reason})
+}
+
+// encode returns the character codes showing s using the current font
+// or the reason why s can't be shown.
+// New glyphs of user font subsets are recorded for a later font update.
+func (tr *textReplacer) encode(s string) ([]byte, string) {
+	f := tr.font
+
+	var (
+		bb   []byte
+		gids []uint16
+	)
+
+	for _, r := range s {
+		if code, ok := f.f.Code(r); ok {
+			bb = append(bb, code...)
+			continue
+		}
+		if !f.userFont {
+			return nil, fmt.Sprintf("no glyph for %q", r)
+		}
+		font.UserFontMetricsLock.RLock()
+		ttf, ok := font.UserFontMetrics[f.name]
+		font.UserFontMetricsLock.RUnlock()
+		if !ok {
+			return nil, fmt.Sprintf("no glyph for %q, user font %s not installed", r, f.name)
+		}
+		gid, ok := ttf.Chars[uint32(r)]
+		if !ok {
+			return nil, fmt.Sprintf("no glyph for %q", r)
+		}
+		bb = binary.BigEndian.AppendUint16(bb, gid)
+		gids = append(gids, gid)
+	}
+
+	if len(gids) > 0 && f.subset {
+		usedGIDs, ok := tr.ctx.UsedGIDs[f.name]
+		if !ok {
+			usedGIDs = map[uint16]bool{}
+			tr.ctx.UsedGIDs[f.name] = usedGIDs
+		}
+		for _, gid := range gids {
+			usedGIDs[gid] = true
+		}
+		tr.updates[f] = true
+	}
+
+	return bb, ""
+}
+
+// replaceStrings replaces all occurrences of the search text in the strings of a text showing operation.
+// Occurrences spanning several strings of a TJ array get reported but remain untouched.
+// It returns the new strings and true if anything changed.
+func (tr *textReplacer) replaceStrings(ss [][]byte) ([][]byte, bool) {
+	var (
+		codes []text.Code
+		elems []int           // string index for each code
+		at    = map[int]int{} // text offset -> first code starting there
+		end   = map[int]int{} // text offset -> index following the last code ending there
+		sb    strings.Builder
+	)
+
+	for i, bb := range ss {
+		for _, c := range tr.font.f.Decode(bb) {
+			if c.Text != "" {
+				if _, ok := at[sb.Len()]; !ok {
+					at[sb.Len()] = len(codes)
+				}
+				sb.WriteString(c.Text)
+				end[sb.Len()] = len(codes) + 1
+			}
+			codes = append(codes, c)
+			elems = append(elems, i)
+		}
+	}
+
+	s := sb.String()
+	n := strings.Count(s, tr.old)
+	if n == 0 {
+		return nil, false
+	}
+
+	if tr.font.reason != "" {
+		for ; n > 0; n-- {
+			tr.fail(tr.font.reason)
+		}
+		return nil, false
+	}
+
+	repl := map[int][]byte{} // first code of an occurrence -> replacement codes
+	skip := map[int]bool{}   // codes replaced
+
+	var (
+		enc    []byte
+		reason string
+	)
+
+	for from := 0; ; {
+		i := strings.Index(s[from:], tr.old)
+		if i < 0 {
+			break
+		}
+		i += from
+		from = i + len(tr.old)
+
+		j, ok1 := at[i]
+		k, ok2 := end[from]
+		if !ok1 || !ok2 {
+			tr.fail("occurrence not aligned with glyph boundaries")
+			continue
+		}
+		if elems[j] != elems[k-1] {
+			tr.fail("occurrence spans several strings")
+			continue
+		}
+		if enc == nil && reason == "" {
+			if enc, reason = tr.encode(tr.new); enc == nil && reason == "" {
+				enc = []byte{}
+			}
+		}
+		if reason != "" {
+			tr.fail(reason)
+			continue
+		}
+		repl[j] = enc
+		for ; j < k; j++ {
+			skip[j] = true
+		}
+		tr.count++
+	}
+
+	if len(repl) == 0 {
+		return nil, false
+	}
+
+	ss1 := make([][]byte, len(ss))
+	for i := range ss {
+		ss1[i] = []byte{}
+	}
+	for i, c := range codes {
+		e := elems[i]
+		if bb, ok := repl[i]; ok {
+			ss1[e] = append(ss1[e], bb...)
+		}
+		if !skip[i] {
+			ss1[e] = append(ss1[e], c.Bytes...)
+		}
+	}
+
+	return ss1, true
+}
+
+func stringBytes(o types.Object) ([]byte, bool) {
+	switch o := o.(type) {
+	case types.StringLiteral:
+		bb, err := types.Unescape(o.Value())
+		return bb, err == nil
+	case types.HexLiteral:
+		bb, err := o.Bytes()
+		return bb, err == nil
+	}
+	return nil, false
+}
+
+// stringObject returns bb as an object of the same kind as o.
+func stringObject(o types.Object, bb []byte) types.Object {
+	if _, ok := o.(types.HexLiteral); ok {
+		return types.NewHexLiteral(bb)
+	}
+	s, _ := types.Escape(string(bb))
+	return types.StringLiteral(*s)
+}
+
+// replaceText returns op with all occurrences of the search text replaced and true if anything changed.
+func (tr *textReplacer) replaceText(op content.Operation) (content.Operation, bool) {
+	if tr.font == nil {
+		return op, false
+	}
+
+	var (
+		oo  []types.Object // string operands
+		idx []int          // their positions
+	)
+
+	if op.Operator == "TJ" {
+		a, _ := op.Operand(0).(types.Array)
+		for i, o := range a {
+			if _, ok := stringBytes(o); ok {
+				oo, idx = append(oo, o), append(idx, i)
+			}
+		}
+	} else if n := len(op.Operands); n > 0 {
+		oo, idx = []types.Object{op.Operands[n-1]}, []int{n - 1}
+	}
+
+	ss := make([][]byte, len(oo))
+	for i, o := range oo {
+		ss[i], _ = stringBytes(o)
+	}
+
+	ss1, ok := tr.replaceStrings(ss)
+	if !ok {
+		return op, false
+	}
+
+	if op.Operator == "TJ" {
+		a := append(types.Array{}, op.Operand(0).(types.Array)...)
+		for i, j := range idx {
+			a[j] = stringObject(oo[i], ss1[i])
+		}
+		return content.Operation{Operator: op.Operator, Operands: []types.Object{a}}, true
+	}
+
+	operands := append([]types.Object{}, op.Operands...)
+	operands[idx[0]] = stringObject(oo[0], ss1[0])
+	return content.Operation{Operator: op.Operator, Operands: operands}, true
+}
+
+// replaceForm replaces text within the form XObject indRef.
+func (tr *textReplacer) replaceForm(indRef types.IndirectRef, parentRes types.Dict, depth int) error {
+	objNr := indRef.ObjectNumber.Value()
+	if tr.forms[objNr] || depth >= maxReplaceDepth {
+		return nil
+	}
+	tr.forms[objNr] = true
+
+	entry, ok := tr.ctx.FindTableEntryForIndRef(&indRef)
+	if !ok {
+		return nil
+	}
+	sd, ok := entry.Object.(types.StreamDict)
+	if !ok {
+		return nil
+	}
+
+	if err := sd.Decode(); err != nil {
+		return err
+	}
+
+	ops, err := content.Parse(sd.Content)
+	if err != nil {
+		return err
+	}
+
+	res := parentRes
+	if o, found := sd.Find("Resources"); found {
+		if res, err = tr.ctx.DereferenceDict(o); err != nil {
+			return err
+		}
+	}
+
+	ops, changed, err := tr.replaceOps(ops, res, depth+1)
+	if err != nil || !changed {
+		return err
+	}
+
+	sd.Content = content.Bytes(ops)
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	entry.Object = sd
+
+	return nil
+}
+
+// replaceOps replaces text shown by ops including form XObjects used.
+func (tr *textReplacer) replaceOps(ops []content.Operation, res types.Dict, depth int) ([]content.Operation, bool, error) {
+	var changed bool
+
+	// Forms inherit the text font but restore it when done.
+	fontName, f, stack := tr.fontName, tr.font, tr.fontStack
+	tr.fontStack = nil
+	defer func() {
+		tr.fontName, tr.font, tr.fontStack = fontName, f, stack
+	}()
+
+	out := make([]content.Operation, 0, len(ops))
+
+	for _, op := range ops {
+
+		switch op.Operator {
+
+		case "q":
+			tr.fontStack = append(tr.fontStack, textFont{name: tr.fontName, f: tr.font})
+
+		case "Q":
+			if n := len(tr.fontStack); n > 0 {
+				tf := tr.fontStack[n-1]
+				tr.fontName, tr.font, tr.fontStack = tf.name, tf.f, tr.fontStack[:n-1]
+			}
+
+		case "Tf":
+			if n, ok := op.Operand(0).(types.Name); ok {
+				if err := tr.setFont(res, n.Value()); err != nil {
+					return nil, false, err
+				}
+			}
+
+		case "Tj", "TJ", "'", "\"":
+			var ok bool
+			if op, ok = tr.replaceText(op); ok {
+				changed = true
+			}
+
+		case "Do":
+			n, ok := op.Operand(0).(types.Name)
+			if !ok {
+				break
+			}
+			indRef, ok := tr.resource(res, "XObject", n.Value()).(types.IndirectRef)
+			if !ok {
+				break
+			}
+			sd, _, err := tr.ctx.DereferenceStreamDict(indRef)
+			if err != nil || sd == nil {
+				return nil, false, err
+			}
+			if st := sd.Subtype(); st != nil && *st == "Form" {
+				if err := tr.replaceForm(indRef, res, depth); err != nil {
+					return nil, false, err
+				}
+			}
+		}
+
+		out = append(out, op)
+	}
+
+	return out, changed, nil
+}
+
+func (tr *textReplacer) replacePage(pageNr int) error {
+	d, _, inhPAttrs, err := tr.ctx.PageDict(pageNr, false)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		return errors.Errorf("pdfcpu: replace text: unknown page %d", pageNr)
+	}
+
+	bb, err := tr.ctx.PageContent(d)
+	if err == model.ErrNoContent {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ops, err := content.Parse(bb)
+	if err != nil {
+		return errors.Wrapf(err, "pdfcpu: replace text: page %d", pageNr)
+	}
+
+	tr.pageNr = pageNr
+
+	ops, changed, err := tr.replaceOps(ops, inhPAttrs.Resources, 0)
+	if err != nil || !changed {
+		return err
+	}
+
+	indRef, err := tr.ctx.StreamDictIndRef(content.Bytes(ops))
+	if err != nil {
+		return err
+	}
+	d.Update("Contents", *indRef)
+
+	return nil
+}
+
+// updateUserFonts adds glyphs introduced by replacement text to the affected user font subsets.
+func (tr *textReplacer) updateUserFonts() error {
+	for f := range tr.updates {
+		fr := model.FontResource{}
+		if err := pdffont.IndRefsForUserfontUpdate(tr.ctx.XRefTable, f.d, "", &fr); err != nil {
+			return pdffont.ErrCorruptFontDict
+		}
+		if err := pdffont.UpdateUserfont(tr.ctx.XRefTable, f.name, fr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceText replaces all occurrences of old by new in text shown by selected pages including form XObjects used by them
+// like the ones created for stamps and watermarks.
+// Replacement text is encoded using the font of the string containing an occurrence.
+// Supported are simple fonts and Identity-H encoded composite fonts.
+// Glyphs missing in the subset of an embedded user font get added to the subset.
+// Occurrences must be contained within a single string operand of a text showing operator.
+// Form XObjects shared with other pages are updated in place.
+// ReplaceText returns the number of replacements along with all occurrences which could not be replaced.
+func ReplaceText(ctx *model.Context, selectedPages types.IntSet, old, new string) (int, []UnreplacedText, error) {
+	if old == "" {
+		return 0, nil, errors.New("pdfcpu: replace text: missing search text")
+	}
+	if !utf8.ValidString(old) || !utf8.ValidString(new) {
+		return 0, nil, errors.New("pdfcpu: replace text: invalid UTF-8")
+	}
+
+	tr := &textReplacer{
+		ctx:     ctx,
+		old:     old,
+		new:     new,
+		fonts:   map[int]*replaceFont{},
+		forms:   map[int]bool{},
+		updates: map[*replaceFont]bool{},
+	}
+
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		if selectedPages != nil && !selectedPages[pageNr] {
+			continue
+		}
+		if err := tr.replacePage(pageNr); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if err := tr.updateUserFonts(); err != nil {
+		return 0, nil, err
+	}
+
+	return tr.count, tr.failures, nil
+}
@@ -0,0 +1,1006 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package pdfcpu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/content"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// SanitizeCategory represents a set of content categories to be removed by Sanitize.
+type SanitizeCategory int
+
+// Content categories removed by Sanitize.
+const (
+	SanitizeJavaScript   SanitizeCategory = 1 << iota // document, page, annotation and form field JavaScript
+	SanitizeActions                                   // Launch, ImportData and SubmitForm actions
+	SanitizeAttachments                               // embedded files and file attachment annotations
+	SanitizeXFA                                       // XFA forms
+	SanitizeThumbnails                                // page thumbnails
+	SanitizeHiddenLayers                              // optional content hidden by default
+	SanitizePieceInfo                                 // private application data
+	SanitizeMetadata                                  // XMP metadata streams and the document information dict
+
+	SanitizeAll = SanitizeJavaScript | SanitizeActions | SanitizeAttachments | SanitizeXFA |
+		SanitizeThumbnails | SanitizeHiddenLayers | SanitizePieceInfo | SanitizeMetadata
+)
+
+var sanitizeCategoryNames = []struct {
+	name string
+	cat  SanitizeCategory
+}{
+	{"javascript", SanitizeJavaScript},
+	{"actions", SanitizeActions},
+	{"attachments", SanitizeAttachments},
+	{"xfa", SanitizeXFA},
+	{"thumbnails", SanitizeThumbnails},
+	{"layers", SanitizeHiddenLayers},
+	{"pieceinfo", SanitizePieceInfo},
+	{"metadata", SanitizeMetadata},
+}
+
+// ParseSanitizeCategories parses a comma separated list of categories, eg. "javascript,actions".
+// "all" selects all categories.
+func ParseSanitizeCategories(s string) (SanitizeCategory, error) {
+	var c SanitizeCategory
+	for _, s := range strings.Split(s, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "all" {
+			c |= SanitizeAll
+			continue
+		}
+		found := false
+		for _, cn := range sanitizeCategoryNames {
+			if cn.name == s {
+				c |= cn.cat
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, errors.Errorf("pdfcpu: unknown sanitize category: %q", s)
+		}
+	}
+	return c, nil
+}
+
+func (c SanitizeCategory) String() string {
+	var ss []string
+	for _, cn := range sanitizeCategoryNames {
+		if c&cn.cat != 0 {
+			ss = append(ss, cn.name)
+		}
+	}
+	return strings.Join(ss, ",")
+}
+
+// SanitizeItem describes a removed structure.
+type SanitizeItem struct {
+	Category string `json:"category"`
+	Location string `json:"location"` // catalog, page n or obj#n
+	Detail   string `json:"detail"`
+}
+
+type sanitizer struct {
+	ctx     *model.Context
+	cats    SanitizeCategory
+	pages   map[int]int // page dict obj# -> page number
+	items   []SanitizeItem
+	hidden  map[int]bool // obj# of optional content groups hidden by default
+	forms   map[int]bool // obj# of processed form XObjects
+	visited map[int]bool // obj# of processed actions
+}
+
+func (s *sanitizer) location(objNr int) string {
+	if s.ctx.Root != nil && s.ctx.Root.ObjectNumber.Value() == objNr {
+		return "catalog"
+	}
+	if pageNr, ok := s.pages[objNr]; ok {
+		return fmt.Sprintf("page %d", pageNr)
+	}
+	return fmt.Sprintf("obj#%d", objNr)
+}
+
+func (s *sanitizer) report(c SanitizeCategory, location, format string, args ...interface{}) {
+	s.items = append(s.items, SanitizeItem{Category: c.String(), Location: location, Detail: fmt.Sprintf(format, args...)})
+}
+
+// actionCategory returns the category of action d or 0 if d is to be retained.
+func (s *sanitizer) actionCategory(d types.Dict) SanitizeCategory {
+	st := d.NameEntry("S")
+	if st == nil {
+		return 0
+	}
+	switch *st {
+	case "JavaScript":
+		return SanitizeJavaScript & s.cats
+	case "URI":
+		if uri, err := s.ctx.DereferenceStringOrHexLiteral(d["URI"], model.V10, nil); err == nil &&
+			strings.HasPrefix(strings.ToLower(strings.TrimSpace(uri)), "javascript:") {
+			return SanitizeJavaScript & s.cats
+		}
+	case "Launch", "ImportData", "SubmitForm":
+		return SanitizeActions & s.cats
+	}
+	return 0
+}
+
+// action returns the replacement for action o along with its sequel of actions.
+// nil signals an action to be removed as a whole.
+func (s *sanitizer) action(o types.Object, location string) (types.Object, error) {
+	if indRef, ok := o.(types.IndirectRef); ok {
+		objNr := indRef.ObjectNumber.Value()
+		if s.visited[objNr] {
+			// Cyclic action sequence.
+			return nil, nil
+		}
+		s.visited[objNr] = true
+		defer delete(s.visited, objNr)
+	}
+
+	d, err := s.ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		// Not an action dict, eg. a destination.
+		return o, nil
+	}
+	if _, found := d.Find("S"); !found {
+		return o, nil
+	}
+
+	if next, found := d.Find("Next"); found {
+		next, err = s.actions(next, location)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			d.Delete("Next")
+		} else {
+			d["Next"] = next
+		}
+	}
+
+	if st := d.NameEntry("S"); st != nil && *st == "Rendition" && s.cats&SanitizeJavaScript != 0 {
+		if _, found := d.Find("JS"); found {
+			d.Delete("JS")
+			s.report(SanitizeJavaScript, location, "Rendition action JavaScript")
+		}
+	}
+
+	c := s.actionCategory(d)
+	if c == 0 {
+		return o, nil
+	}
+
+	s.report(c, location, "%s action", *d.NameEntry("S"))
+
+	// Splice in the sequel.
+	next, _ := d.Find("Next")
+	if a, ok := next.(types.Array); ok && len(a) == 1 {
+		next = a[0]
+	}
+	return next, nil
+}
+
+// actions returns the replacement for an action or an array of actions.
+func (s *sanitizer) actions(o types.Object, location string) (types.Object, error) {
+	a, ok := o.(types.Array)
+	if !ok {
+		return s.action(o, location)
+	}
+
+	a1 := types.Array{}
+	for _, o := range a {
+		o1, err := s.action(o, location)
+		if err != nil {
+			return nil, err
+		}
+		if a2, ok := o1.(types.Array); ok {
+			a1 = append(a1, a2...)
+			continue
+		}
+		if o1 != nil {
+			a1 = append(a1, o1)
+		}
+	}
+
+	if len(a1) == 0 {
+		return nil, nil
+	}
+	return a1, nil
+}
+
+// sanitizeDict removes unwanted actions, private data and metadata from d and its direct children.
+func (s *sanitizer) sanitizeDict(d types.Dict, location string) error {
+	if s.cats&(SanitizeJavaScript|SanitizeActions) != 0 {
+		for _, k := range []string{"OpenAction", "A"} {
+			o, found := d.Find(k)
+			if !found {
+				continue
+			}
+			o, err := s.action(o, location)
+			if err != nil {
+				return err
+			}
+			if o == nil {
+				d.Delete(k)
+				continue
+			}
+			d[k] = o
+		}
+
+		if o, found := d.Find("AA"); found {
+			aa, err := s.ctx.DereferenceDict(o)
+			if err != nil {
+				return err
+			}
+			for k, o := range aa {
+				o, err := s.action(o, fmt.Sprintf("%s /AA /%s", location, k))
+				if err != nil {
+					return err
+				}
+				if o == nil {
+					aa.Delete(k)
+					continue
+				}
+				aa[k] = o
+			}
+			if aa.Len() == 0 {
+				d.Delete("AA")
+			}
+		}
+	}
+
+	if s.cats&SanitizePieceInfo != 0 {
+		if _, found := d.Find("PieceInfo"); found {
+			d.Delete("PieceInfo")
+			s.report(SanitizePieceInfo, location, "PieceInfo")
+		}
+	}
+
+	if s.cats&SanitizeMetadata != 0 {
+		if _, found := d.Find("Metadata"); found {
+			if t := d.Type(); t == nil || *t != "EmbeddedFile" {
+				d.Delete("Metadata")
+				s.report(SanitizeMetadata, location, "XMP metadata")
+			}
+		}
+	}
+
+	for _, o := range d {
+		if err := s.sanitizeObject(o, location); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sanitizer) sanitizeObject(o types.Object, location string) error {
+	switch o := o.(type) {
+	case types.Dict:
+		return s.sanitizeDict(o, location)
+	case types.StreamDict:
+		return s.sanitizeDict(o.Dict, location)
+	case types.Array:
+		for _, o := range o {
+			if err := s.sanitizeObject(o, location); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sanitizeObjects walks all objects of the cross reference table.
+func (s *sanitizer) sanitizeObjects() error {
+	var objNrs []int
+	for objNr, entry := range s.ctx.Table {
+		if entry != nil && !entry.Free && entry.Object != nil {
+			objNrs = append(objNrs, objNr)
+		}
+	}
+	sort.Ints(objNrs)
+
+	for _, objNr := range objNrs {
+		if err := s.sanitizeObject(s.ctx.Table[objNr].Object, s.location(objNr)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sanitizer) removeNameTree(name string, c SanitizeCategory) error {
+	namesDict, err := s.ctx.NamesDict()
+	if err != nil {
+		return err
+	}
+	delete(s.ctx.Names, name)
+	if namesDict == nil {
+		return nil
+	}
+	if _, found := namesDict.Find(name); !found {
+		return nil
+	}
+	if err := s.ctx.RemoveNameTree(name); err != nil {
+		return err
+	}
+	s.report(c, "catalog", "%s name tree", name)
+	return nil
+}
+
+// filterAnnots removes all annotations of page pageNr for which remove returns true.
+func (s *sanitizer) filterAnnots(pageNr int, remove func(d types.Dict) (bool, error)) error {
+	pageDict, _, _, err := s.ctx.PageDict(pageNr, false)
+	if err != nil || pageDict == nil {
+		return err
+	}
+
+	o, found := pageDict.Find("Annots")
+	if !found {
+		return nil
+	}
+
+	annots, err := s.ctx.DereferenceArray(o)
+	if err != nil || annots == nil {
+		return err
+	}
+
+	a := types.Array{}
+	for _, o := range annots {
+		d, err := s.ctx.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		if d != nil {
+			ok, err := remove(d)
+			if err != nil {
+				return err
+			}
+			if ok {
+				continue
+			}
+		}
+		a = append(a, o)
+	}
+
+	if len(a) == len(annots) {
+		return nil
+	}
+
+	if len(a) == 0 {
+		pageDict.Delete("Annots")
+		return nil
+	}
+
+	if indRef, ok := o.(types.IndirectRef); ok {
+		if entry, ok := s.ctx.FindTableEntryForIndRef(&indRef); ok {
+			entry.Object = a
+			return nil
+		}
+	}
+
+	pageDict.Update("Annots", a)
+	return nil
+}
+
+func (s *sanitizer) removeAttachments() error {
+	if err := s.removeNameTree("EmbeddedFiles", SanitizeAttachments); err != nil {
+		return err
+	}
+
+	if err := s.ctx.RemoveCollection(); err != nil {
+		return err
+	}
+
+	for pageNr := 1; pageNr <= s.ctx.PageCount; pageNr++ {
+		if err := s.filterAnnots(pageNr, func(d types.Dict) (bool, error) {
+			st := d.Subtype()
+			if st == nil || *st != "FileAttachment" {
+				return false, nil
+			}
+			s.report(SanitizeAttachments, fmt.Sprintf("page %d", pageNr), "file attachment annotation")
+			return true, nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sanitizer) removeXFA() error {
+	rootDict, err := s.ctx.Catalog()
+	if err != nil {
+		return err
+	}
+
+	if _, found := rootDict.Find("NeedsRendering"); found {
+		rootDict.Delete("NeedsRendering")
+	}
+
+	o, found := rootDict.Find("AcroForm")
+	if !found {
+		return nil
+	}
+
+	d, err := s.ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return err
+	}
+
+	if _, found := d.Find("XFA"); found {
+		d.Delete("XFA")
+		s.report(SanitizeXFA, "catalog", "XFA form")
+	}
+
+	return nil
+}
+
+func (s *sanitizer) removeThumbnails() error {
+	for pageNr := 1; pageNr <= s.ctx.PageCount; pageNr++ {
+		d, _, _, err := s.ctx.PageDict(pageNr, false)
+		if err != nil {
+			return err
+		}
+		if _, found := d.Find("Thumb"); found {
+			d.Delete("Thumb")
+			s.report(SanitizeThumbnails, fmt.Sprintf("page %d", pageNr), "thumbnail image")
+		}
+	}
+	return nil
+}
+
+func (s *sanitizer) removeMetadata() {
+	if s.ctx.Info != nil {
+		// A new info dict only holding the producer and dates gets created on writing.
+		s.ctx.Info = nil
+		s.report(SanitizeMetadata, "trailer", "document information dict")
+	}
+}
+
+// Sanitize removes content of categories cats from ctx and returns a report listing all removed structures.
+func Sanitize(ctx *model.Context, cats SanitizeCategory) ([]SanitizeItem, error) {
+	s := &sanitizer{
+		ctx:     ctx,
+		cats:    cats,
+		pages:   map[int]int{},
+		forms:   map[int]bool{},
+		visited: map[int]bool{},
+	}
+
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		indRef, err := ctx.PageDictIndRef(pageNr)
+		if err != nil {
+			return nil, err
+		}
+		if indRef != nil {
+			s.pages[indRef.ObjectNumber.Value()] = pageNr
+		}
+	}
+
+	if cats&SanitizeHiddenLayers != 0 {
+		if err := s.removeHiddenLayers(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.sanitizeObjects(); err != nil {
+		return nil, err
+	}
+
+	if cats&SanitizeJavaScript != 0 {
+		if err := s.removeNameTree("JavaScript", SanitizeJavaScript); err != nil {
+			return nil, err
+		}
+	}
+
+	if cats&SanitizeAttachments != 0 {
+		if err := s.removeAttachments(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cats&SanitizeXFA != 0 {
+		if err := s.removeXFA(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cats&SanitizeThumbnails != 0 {
+		if err := s.removeThumbnails(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cats&SanitizeMetadata != 0 {
+		s.removeMetadata()
+	}
+
+	return s.items, nil
+}
+
+// hiddenLayers returns the optional content groups hidden in the default configuration.
+func (s *sanitizer) hiddenLayers(ocProps types.Dict) (map[int]bool, error) {
+	ocgs, err := s.ctx.DereferenceArray(ocProps["OCGs"])
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := s.ctx.DereferenceDict(ocProps["D"])
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	objNrs := func(key string) (map[int]bool, error) {
+		m := map[int]bool{}
+		a, err := s.ctx.DereferenceArray(d[key])
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range a {
+			if indRef, ok := o.(types.IndirectRef); ok {
+				m[indRef.ObjectNumber.Value()] = true
+			}
+		}
+		return m, nil
+	}
+
+	on, err := objNrs("ON")
+	if err != nil {
+		return nil, err
+	}
+
+	off, err := objNrs("OFF")
+	if err != nil {
+		return nil, err
+	}
+
+	if bs := d.NameEntry("BaseState"); bs != nil && *bs == "OFF" {
+		off = map[int]bool{}
+		for _, o := range ocgs {
+			if indRef, ok := o.(types.IndirectRef); ok && !on[indRef.ObjectNumber.Value()] {
+				off[indRef.ObjectNumber.Value()] = true
+			}
+		}
+	}
+
+	return off, nil
+}
+
+// HiddenContent returns a function reporting whether an optional content group or membership dict
+// is hidden in the default configuration of ctx.
+// It shares the evaluation of hidden layers with sanitizing so that the page renderer
+// skips exactly the content sanitizing would strip.
+func HiddenContent(ctx *model.Context) (func(o types.Object) bool, error) {
+	s := &sanitizer{ctx: ctx, hidden: map[int]bool{}}
+
+	rootDict, err := ctx.Catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	if o, found := rootDict.Find("OCProperties"); found {
+		ocProps, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+		if ocProps != nil {
+			if s.hidden, err = s.hiddenLayers(ocProps); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return s.ocHidden, nil
+}
+
+// ocgHidden returns true if the optional content group or membership dict o is hidden.
+func (s *sanitizer) ocHidden(o types.Object) bool {
+	if indRef, ok := o.(types.IndirectRef); ok && s.hidden[indRef.ObjectNumber.Value()] {
+		return true
+	}
+
+	d, err := s.ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return false
+	}
+
+	if t := d.Type(); t == nil || *t != "OCMD" {
+		return false
+	}
+
+	if ve, found := d.Find("VE"); found {
+		return !s.visibilityExpression(ve, 0)
+	}
+
+	var ocgs types.Array
+	switch o := d["OCGs"].(type) {
+	case types.IndirectRef:
+		if d1, err := s.ctx.DereferenceDict(o); err == nil && d1 != nil && d1.Type() != nil && *d1.Type() == "OCG" {
+			ocgs = types.Array{o}
+		} else {
+			ocgs, _ = s.ctx.DereferenceArray(o)
+		}
+	case types.Array:
+		ocgs = o
+	}
+	if len(ocgs) == 0 {
+		return false
+	}
+
+	var on, off int
+	for _, o := range ocgs {
+		if indRef, ok := o.(types.IndirectRef); ok && s.hidden[indRef.ObjectNumber.Value()] {
+			off++
+			continue
+		}
+		on++
+	}
+
+	p := "AnyOn"
+	if n := d.NameEntry("P"); n != nil {
+		p = *n
+	}
+
+	switch p {
+	case "AllOn":
+		return off > 0
+	case "AnyOff":
+		return off == 0
+	case "AllOff":
+		return on > 0
+	}
+
+	// AnyOn
+	return on == 0
+}
+
+// visibilityExpression evaluates the visibility expression o.
+func (s *sanitizer) visibilityExpression(o types.Object, depth int) bool {
+	if indRef, ok := o.(types.IndirectRef); ok {
+		if d, err := s.ctx.DereferenceDict(indRef); err == nil && d != nil {
+			return !s.hidden[indRef.ObjectNumber.Value()]
+		}
+	}
+
+	a, err := s.ctx.DereferenceArray(o)
+	if err != nil || len(a) < 2 || depth > 16 {
+		return true
+	}
+
+	op, _ := a[0].(types.Name)
+	switch op {
+	case "Not":
+		return !s.visibilityExpression(a[1], depth+1)
+	case "And":
+		for _, o := range a[1:] {
+			if !s.visibilityExpression(o, depth+1) {
+				return false
+			}
+		}
+		return true
+	case "Or":
+		for _, o := range a[1:] {
+			if s.visibilityExpression(o, depth+1) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+func (s *sanitizer) resource(res types.Dict, category, name string) types.Object {
+	if res == nil {
+		return nil
+	}
+	d, err := s.ctx.DereferenceDict(res[category])
+	if err != nil || d == nil {
+		return nil
+	}
+	o, _ := d.Find(name)
+	return o
+}
+
+// stripHiddenContent removes marked content and XObjects belonging to hidden optional content from ops.
+func (s *sanitizer) stripHiddenContent(ops []content.Operation, res types.Dict) ([]content.Operation, bool, error) {
+	var (
+		out     []content.Operation
+		changed bool
+		skip    int // nesting level within hidden marked content, 0 if visible
+	)
+
+	for _, op := range ops {
+		switch op.Operator {
+
+		case "BMC", "BDC":
+			if skip > 0 {
+				skip++
+				continue
+			}
+			if op.Operator == "BDC" && op.Operand(0) == types.Name("OC") {
+				o := op.Operand(1)
+				if n, ok := o.(types.Name); ok {
+					o = s.resource(res, "Properties", n.Value())
+				}
+				if o != nil && s.ocHidden(o) {
+					skip, changed = 1, true
+					continue
+				}
+			}
+
+		case "EMC":
+			if skip > 0 {
+				skip--
+				continue
+			}
+
+		case "Do":
+			if skip > 0 {
+				continue
+			}
+			n, ok := op.Operand(0).(types.Name)
+			if !ok {
+				break
+			}
+			indRef, ok := s.resource(res, "XObject", n.Value()).(types.IndirectRef)
+			if !ok {
+				break
+			}
+			sd, _, err := s.ctx.DereferenceStreamDict(indRef)
+			if err != nil || sd == nil {
+				return nil, false, err
+			}
+			if oc, found := sd.Find("OC"); found && s.ocHidden(oc) {
+				changed = true
+				continue
+			}
+			if st := sd.Subtype(); st != nil && *st == "Form" {
+				if err := s.stripHiddenForm(indRef, res); err != nil {
+					return nil, false, err
+				}
+			}
+		}
+
+		if skip > 0 {
+			continue
+		}
+
+		out = append(out, op)
+	}
+
+	return out, changed, nil
+}
+
+// stripHiddenForm removes hidden optional content from the form XObject indRef.
+func (s *sanitizer) stripHiddenForm(indRef types.IndirectRef, parentRes types.Dict) error {
+	objNr := indRef.ObjectNumber.Value()
+	if s.forms[objNr] {
+		return nil
+	}
+	s.forms[objNr] = true
+
+	entry, ok := s.ctx.FindTableEntryForIndRef(&indRef)
+	if !ok {
+		return nil
+	}
+	sd, ok := entry.Object.(types.StreamDict)
+	if !ok {
+		return nil
+	}
+
+	if err := sd.Decode(); err != nil {
+		return err
+	}
+
+	ops, err := content.Parse(sd.Content)
+	if err != nil {
+		return err
+	}
+
+	res := parentRes
+	if o, found := sd.Find("Resources"); found {
+		if res, err = s.ctx.DereferenceDict(o); err != nil {
+			return err
+		}
+	}
+
+	ops, changed, err := s.stripHiddenContent(ops, res)
+	if err != nil || !changed {
+		return err
+	}
+
+	sd.Content = content.Bytes(ops)
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	entry.Object = sd
+
+	s.report(SanitizeHiddenLayers, s.location(objNr), "hidden content")
+
+	return nil
+}
+
+// appearanceStreams returns the appearance streams of annotation d.
+func (s *sanitizer) appearanceStreams(d types.Dict) []types.IndirectRef {
+	ap, err := s.ctx.DereferenceDict(d["AP"])
+	if err != nil || ap == nil {
+		return nil
+	}
+
+	var ir []types.IndirectRef
+	for _, o := range ap {
+		if indRef, ok := o.(types.IndirectRef); ok {
+			if sd, _, err := s.ctx.DereferenceStreamDict(indRef); err == nil && sd != nil {
+				ir = append(ir, indRef)
+				continue
+			}
+		}
+		d1, err := s.ctx.DereferenceDict(o)
+		if err != nil || d1 == nil {
+			continue
+		}
+		for _, o := range d1 {
+			if indRef, ok := o.(types.IndirectRef); ok {
+				ir = append(ir, indRef)
+			}
+		}
+	}
+
+	return ir
+}
+
+func (s *sanitizer) stripHiddenPage(pageNr int) error {
+	d, _, inhPAttrs, err := s.ctx.PageDict(pageNr, false)
+	if err != nil || d == nil {
+		return err
+	}
+
+	bb, err := s.ctx.PageContent(d)
+	if err != nil && err != model.ErrNoContent {
+		return err
+	}
+
+	ops, err := content.Parse(bb)
+	if err != nil {
+		return err
+	}
+
+	ops, changed, err := s.stripHiddenContent(ops, inhPAttrs.Resources)
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		indRef, err := s.ctx.StreamDictIndRef(content.Bytes(ops))
+		if err != nil {
+			return err
+		}
+		d.Update("Contents", *indRef)
+		s.report(SanitizeHiddenLayers, fmt.Sprintf("page %d", pageNr), "hidden content")
+	}
+
+	return s.filterAnnots(pageNr, func(d types.Dict) (bool, error) {
+		if oc, found := d.Find("OC"); found && s.ocHidden(oc) {
+			s.report(SanitizeHiddenLayers, fmt.Sprintf("page %d", pageNr), "hidden annotation")
+			return true, nil
+		}
+		for _, indRef := range s.appearanceStreams(d) {
+			if err := s.stripHiddenForm(indRef, nil); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	})
+}
+
+// withoutHidden returns a without references to hidden optional content groups.
+// Nested arrays as used for the order of layers in the user interface are filtered recursively.
+func (s *sanitizer) withoutHidden(a types.Array) types.Array {
+	a1 := types.Array{}
+	for _, o := range a {
+		if indRef, ok := o.(types.IndirectRef); ok && s.hidden[indRef.ObjectNumber.Value()] {
+			continue
+		}
+		if a2, ok := o.(types.Array); ok {
+			o = s.withoutHidden(a2)
+		}
+		a1 = append(a1, o)
+	}
+	return a1
+}
+
+func (s *sanitizer) removeHiddenLayers() error {
+	rootDict, err := s.ctx.Catalog()
+	if err != nil {
+		return err
+	}
+
+	o, found := rootDict.Find("OCProperties")
+	if !found {
+		return nil
+	}
+
+	ocProps, err := s.ctx.DereferenceDict(o)
+	if err != nil || ocProps == nil {
+		return err
+	}
+
+	if s.hidden, err = s.hiddenLayers(ocProps); err != nil || len(s.hidden) == 0 {
+		return err
+	}
+
+	for pageNr := 1; pageNr <= s.ctx.PageCount; pageNr++ {
+		if err := s.stripHiddenPage(pageNr); err != nil {
+			return err
+		}
+	}
+
+	// Remove the hidden groups from all configurations.
+	ocgs, err := s.ctx.DereferenceArray(ocProps["OCGs"])
+	if err != nil {
+		return err
+	}
+	for _, o := range ocgs {
+		indRef, ok := o.(types.IndirectRef)
+		if !ok || !s.hidden[indRef.ObjectNumber.Value()] {
+			continue
+		}
+		name := ""
+		if d, err := s.ctx.DereferenceDict(indRef); err == nil && d != nil {
+			name, _ = s.ctx.DereferenceStringOrHexLiteral(d["Name"], model.V10, nil)
+		}
+		s.report(SanitizeHiddenLayers, "catalog", "hidden layer %q", name)
+	}
+	ocProps["OCGs"] = s.withoutHidden(ocgs)
+
+	configs := []types.Object{ocProps["D"]}
+	if a, err := s.ctx.DereferenceArray(ocProps["Configs"]); err == nil {
+		configs = append(configs, a...)
+	}
+
+	for _, o := range configs {
+		d, err := s.ctx.DereferenceDict(o)
+		if err != nil || d == nil {
+			continue
+		}
+		for _, k := range []string{"ON", "OFF", "Order", "RBGroups", "Locked"} {
+			if a, err := s.ctx.DereferenceArray(d[k]); err == nil && a != nil {
+				d[k] = s.withoutHidden(a)
+			}
+		}
+		if a, err := s.ctx.DereferenceArray(d["AS"]); err == nil {
+			for _, o := range a {
+				if d1, err := s.ctx.DereferenceDict(o); err == nil && d1 != nil {
+					if a1, err := s.ctx.DereferenceArray(d1["OCGs"]); err == nil && a1 != nil {
+						d1["OCGs"] = s.withoutHidden(a1)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pkg/errors"
+)
+
+var (
+	oidData                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidAttrContentType        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigest      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttrSigningTime        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidAttrSigningCertV2      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidAttrTimeStampToken     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+	oidTSTInfo                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidDigestSHA256           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSignatureRSA           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSignatureECDSAWithSHA2 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue // [0] EXPLICIT
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"optional"` // [0] EXPLICIT OCTET STRING, missing for detached signatures
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue // IssuerAndSerialNumber or [0] SubjectKeyIdentifier
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+type essCertIDv2 struct {
+	// HashAlgorithm defaults to SHA-256.
+	CertHash []byte
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+func newAttribute(oid asn1.ObjectIdentifier, val interface{}) (attribute, error) {
+	bb, err := asn1.Marshal(val)
+	if err != nil {
+		return attribute{}, err
+	}
+	return attribute{Type: oid, Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bb}}, nil
+}
+
+// marshalAttributes returns the DER encoding of attrs as SET OF Attribute.
+func marshalAttributes(attrs []attribute) ([]byte, error) {
+	ss := make([][]byte, len(attrs))
+	for i, attr := range attrs {
+		bb, err := asn1.Marshal(attr)
+		if err != nil {
+			return nil, err
+		}
+		ss[i] = bb
+	}
+
+	// DER requires the elements of a SET OF to be sorted.
+	sort.Slice(ss, func(i, j int) bool { return bytes.Compare(ss[i], ss[j]) < 0 })
+
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(ss, nil)})
+}
+
+// implicitAttributes returns the DER encoding of attrs as [tag] IMPLICIT SET OF Attribute.
+func implicitAttributes(attrs []attribute, tag int) (asn1.RawValue, error) {
+	bb, err := marshalAttributes(attrs)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	var raw asn1.RawValue
+	if _, err := asn1.Unmarshal(bb, &raw); err != nil {
+		return asn1.RawValue{}, err
+	}
+	raw.Class, raw.Tag, raw.FullBytes = asn1.ClassContextSpecific, tag, nil
+
+	return raw, nil
+}
+
+func signingCertificateAttribute(cert *x509.Certificate) (attribute, error) {
+	h := sha256.Sum256(cert.Raw)
+	return newAttribute(oidAttrSigningCertV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: h[:]}}})
+}
+
+func signedAttributes(contentType asn1.ObjectIdentifier, digest []byte, cert *x509.Certificate, subFilter string, signingTime time.Time) ([]attribute, error) {
+	var attrs []attribute
+
+	attr, err := newAttribute(oidAttrContentType, contentType)
+	if err != nil {
+		return nil, err
+	}
+	attrs = append(attrs, attr)
+
+	if attr, err = newAttribute(oidAttrMessageDigest, digest); err != nil {
+		return nil, err
+	}
+	attrs = append(attrs, attr)
+
+	if subFilter == model.SubFilterPKCS7Detached {
+		// PAdES signatures carry the signing time in the signature dict only (M).
+		if attr, err = newAttribute(oidAttrSigningTime, signingTime.UTC()); err != nil {
+			return nil, err
+		}
+		return append(attrs, attr), nil
+	}
+
+	// PAdES signatures and timestamp tokens need to protect the signing certificate.
+	if attr, err = signingCertificateAttribute(cert); err != nil {
+		return nil, err
+	}
+
+	return append(attrs, attr), nil
+}
+
+func signatureAlgorithm(signer crypto.Signer) (pkix.AlgorithmIdentifier, error) {
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSignatureRSA, Parameters: asn1.NullRawValue}, nil
+	case *ecdsa.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA2}, nil
+	}
+	return pkix.AlgorithmIdentifier{}, errors.Errorf("pdfcpu: unsupported signing key type: %T", signer.Public())
+}
+
+func marshalCertificates(chain []*x509.Certificate) asn1.RawValue {
+	var bb []byte
+	for _, cert := range chain {
+		bb = append(bb, cert.Raw...)
+	}
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: bb}
+}
+
+func newSignerInfo(signer crypto.Signer, cert *x509.Certificate, attrs []attribute) (signerInfo, error) {
+	sigAlg, err := signatureAlgorithm(signer)
+	if err != nil {
+		return signerInfo{}, err
+	}
+
+	bb, err := marshalAttributes(attrs)
+	if err != nil {
+		return signerInfo{}, err
+	}
+
+	// The signature covers the DER encoded signed attributes.
+	digest := sha256.Sum256(bb)
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return signerInfo{}, errors.Wrap(err, "pdfcpu: CreateCMS")
+	}
+
+	signedAttrs, err := implicitAttributes(attrs, 0)
+	if err != nil {
+		return signerInfo{}, err
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, SerialNumber: cert.SerialNumber})
+	if err != nil {
+		return signerInfo{}, err
+	}
+
+	return signerInfo{
+		Version:            1,
+		SID:                asn1.RawValue{FullBytes: sid},
+		DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256, Parameters: asn1.NullRawValue},
+		SignedAttrs:        signedAttrs,
+		SignatureAlgorithm: sigAlg,
+		Signature:          sig,
+	}, nil
+}
+
+func marshalSignedData(eci encapsulatedContentInfo, chain []*x509.Certificate, si signerInfo) ([]byte, error) {
+	version := 1
+	if !eci.EContentType.Equal(oidData) {
+		version = 3
+	}
+
+	sd := signedData{
+		Version:          version,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{si.DigestAlgorithm},
+		EncapContentInfo: eci,
+		Certificates:     marshalCertificates(chain),
+		SignerInfos:      []signerInfo{si},
+	}
+
+	bb, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: bb},
+	})
+}
+
+// addSignatureTimestamp adds a signature timestamp token created by tsa to the unsigned attributes of si.
+func addSignatureTimestamp(si *signerInfo, tsa model.TimestampAuthority) error {
+	digest := sha256.Sum256(si.Signature)
+
+	token, err := tsa.Timestamp(digest[:], crypto.SHA256)
+	if err != nil {
+		return err
+	}
+
+	attr := attribute{Type: oidAttrTimeStampToken, Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: token}}
+
+	si.UnsignedAttrs, err = implicitAttributes([]attribute{attr}, 1)
+
+	return err
+}
+
+// CreateCMS returns a detached CMS signature of type SignedData for content.
+// The first certificate of chain is the signing certificate.
+// signer may be backed by an external signing device like a HSM.
+// If tsa is not nil the signature gets timestamped.
+func CreateCMS(content io.Reader, signer crypto.Signer, chain []*x509.Certificate, subFilter string, signingTime time.Time, tsa model.TimestampAuthority) ([]byte, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("pdfcpu: CreateCMS: missing signing certificate")
+	}
+	cert := chain[0]
+
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return nil, err
+	}
+
+	attrs, err := signedAttributes(oidData, h.Sum(nil), cert, subFilter, signingTime)
+	if err != nil {
+		return nil, err
+	}
+
+	si, err := newSignerInfo(signer, cert, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	if tsa != nil {
+		if err := addSignatureTimestamp(&si, tsa); err != nil {
+			return nil, err
+		}
+	}
+
+	return marshalSignedData(encapsulatedContentInfo{EContentType: oidData}, chain, si)
+}
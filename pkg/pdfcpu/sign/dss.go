@@ -0,0 +1,349 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+var oidOCSPBasic = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+type ocspResponse struct {
+	Status        asn1.Enumerated
+	ResponseBytes ocspResponseBytes `asn1:"explicit,tag:0"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+// DSS represents validation related data for a Document Security Store.
+type DSS struct {
+	Certs [][]byte // DER encoded certificates
+	CRLs  [][]byte // DER encoded certificate revocation lists
+	OCSPs [][]byte // DER encoded OCSP responses
+}
+
+func isOCSPResponse(bb []byte) bool {
+	var resp ocspResponse
+	rest, err := asn1.Unmarshal(bb, &resp)
+	return err == nil && len(rest) == 0 && resp.ResponseBytes.ResponseType.Equal(oidOCSPBasic)
+}
+
+func (dss *DSS) addDER(bb []byte) error {
+	if _, err := x509.ParseCertificate(bb); err == nil {
+		dss.Certs = append(dss.Certs, bb)
+		return nil
+	}
+
+	if _, err := x509.ParseRevocationList(bb); err == nil {
+		dss.CRLs = append(dss.CRLs, bb)
+		return nil
+	}
+
+	if isOCSPResponse(bb) {
+		dss.OCSPs = append(dss.OCSPs, bb)
+		return nil
+	}
+
+	return errors.New("pdfcpu: expected certificate, CRL or OCSP response")
+}
+
+// Add adds a certificate, CRL or OCSP response to dss.
+// bb is either DER encoded or contains PEM encoded certificates or CRLs.
+func (dss *DSS) Add(bb []byte) error {
+	found := false
+
+	for {
+		var block *pem.Block
+		if block, bb = pem.Decode(bb); block == nil {
+			break
+		}
+		if err := dss.addDER(block.Bytes); err != nil {
+			return err
+		}
+		found = true
+	}
+
+	if found {
+		return nil
+	}
+
+	return dss.addDER(bb)
+}
+
+// Empty returns true if dss contains no validation data.
+func (dss *DSS) Empty() bool {
+	return len(dss.Certs) == 0 && len(dss.CRLs) == 0 && len(dss.OCSPs) == 0
+}
+
+// dssWriter adds streams to a Document Security Store avoiding duplicates.
+type dssWriter struct {
+	ctx    *model.Context
+	d      types.Dict
+	hashes map[[sha256.Size]byte]types.IndirectRef
+}
+
+func (w *dssWriter) registerStreams(arr types.Array) error {
+	for _, o := range arr {
+		ir, ok := o.(types.IndirectRef)
+		if !ok {
+			continue
+		}
+		sd, _, err := w.ctx.DereferenceStreamDict(ir)
+		if err != nil {
+			return err
+		}
+		if sd == nil {
+			continue
+		}
+		if err := sd.Decode(); err != nil {
+			return err
+		}
+		w.hashes[sha256.Sum256(sd.Content)] = ir
+	}
+	return nil
+}
+
+// addStreams adds the validation data ss to the DSS array entry k and returns the corresponding stream references.
+func (w *dssWriter) addStreams(k string, ss [][]byte) (types.Array, error) {
+	arr, err := w.ctx.DereferenceArray(w.d[k])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.registerStreams(arr); err != nil {
+		return nil, err
+	}
+
+	var refs types.Array
+	n := len(arr)
+
+	for _, bb := range ss {
+		h := sha256.Sum256(bb)
+		if ir, ok := w.hashes[h]; ok {
+			refs = append(refs, ir)
+			continue
+		}
+
+		sd, err := w.ctx.NewStreamDictForBuf(bb)
+		if err != nil {
+			return nil, err
+		}
+		if err := sd.Encode(); err != nil {
+			return nil, err
+		}
+
+		ir, err := w.ctx.IndRefForNewObject(*sd)
+		if err != nil {
+			return nil, err
+		}
+
+		w.hashes[h] = *ir
+		arr = append(arr, *ir)
+		refs = append(refs, *ir)
+	}
+
+	if len(arr) > n {
+		if err := updateArray(w.ctx, w.d, k, arr); err != nil {
+			return nil, err
+		}
+	}
+
+	return refs, nil
+}
+
+// mergeRefs appends all refs missing in the array entry k of d.
+func mergeRefs(ctx *model.Context, d types.Dict, k string, refs types.Array) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	arr, err := ctx.DereferenceArray(d[k])
+	if err != nil {
+		return err
+	}
+
+	n := len(arr)
+
+	for _, o := range refs {
+		found := false
+		for _, o1 := range arr {
+			if o1 == o {
+				found = true
+				break
+			}
+		}
+		if !found {
+			arr = append(arr, o)
+		}
+	}
+
+	if len(arr) == n {
+		return nil
+	}
+
+	return updateArray(ctx, d, k, arr)
+}
+
+// vriKey returns the key of the validation related information for a signature with the given Contents.
+func vriKey(contents []byte) string {
+	h := sha1.Sum(contents)
+	return strings.ToUpper(hex.EncodeToString(h[:]))
+}
+
+func ensureDSSDict(ctx *model.Context) (types.Dict, error) {
+	if o, found := ctx.RootDict.Find("DSS"); found {
+		d, err := ctx.DereferenceDict(o)
+		if err != nil || d == nil {
+			return nil, errors.Errorf("pdfcpu: corrupt DSS dict: %v", err)
+		}
+		return d, nil
+	}
+
+	d := types.Dict(map[string]types.Object{"Type": types.Name("DSS")})
+
+	ir, err := ctx.IndRefForNewObject(d)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.RootDict["DSS"] = *ir
+
+	return d, nil
+}
+
+// ensureESICExtension declares the ETSI extension introducing the DSS for PDF 1.7.
+func ensureESICExtension(ctx *model.Context) error {
+	if ctx.Version() >= model.V20 {
+		return nil
+	}
+
+	d, err := ctx.DereferenceDict(ctx.RootDict["Extensions"])
+	if err != nil {
+		return err
+	}
+
+	if d == nil {
+		d = types.NewDict()
+		ctx.RootDict["Extensions"] = d
+	}
+
+	if _, found := d.Find("ESIC"); !found {
+		d["ESIC"] = types.Dict(map[string]types.Object{
+			"BaseVersion":    types.Name("1.7"),
+			"ExtensionLevel": types.Integer(5),
+		})
+	}
+
+	return nil
+}
+
+func vriDict(ctx *model.Context, vri types.Dict, key string) (types.Dict, error) {
+	if o, found := vri.Find(key); found {
+		d, err := ctx.DereferenceDict(o)
+		if err != nil || d == nil {
+			return nil, errors.Errorf("pdfcpu: corrupt VRI dict %s: %v", key, err)
+		}
+		return d, nil
+	}
+
+	d := types.NewDict()
+	vri[key] = d
+
+	return d, nil
+}
+
+// AddDSS adds dss to the Document Security Store of ctx
+// and registers all of its data as validation related information (VRI) for every signature of ctx.
+func AddDSS(ctx *model.Context, dss *DSS, t time.Time) error {
+	if dss == nil || dss.Empty() {
+		return errors.New("pdfcpu: missing validation data")
+	}
+
+	c, err := collect(ctx)
+	if err != nil {
+		return err
+	}
+
+	d, err := ensureDSSDict(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := &dssWriter{ctx: ctx, d: d, hashes: map[[sha256.Size]byte]types.IndirectRef{}}
+
+	refs := map[string]types.Array{}
+
+	for _, e := range []struct {
+		dssKey, vriKey string
+		ss             [][]byte
+	}{
+		{"Certs", "Cert", dss.Certs},
+		{"CRLs", "CRL", dss.CRLs},
+		{"OCSPs", "OCSP", dss.OCSPs},
+	} {
+		if len(e.ss) == 0 {
+			continue
+		}
+		if refs[e.vriKey], err = w.addStreams(e.dssKey, e.ss); err != nil {
+			return err
+		}
+	}
+
+	vri, err := ctx.DereferenceDict(d["VRI"])
+	if err != nil {
+		return err
+	}
+	if vri == nil {
+		vri = types.NewDict()
+		d["VRI"] = vri
+	}
+
+	for _, sd := range c.sigs {
+		if len(sd.contents) == 0 {
+			continue
+		}
+
+		d1, err := vriDict(ctx, vri, vriKey(sd.contents))
+		if err != nil {
+			return err
+		}
+
+		for k, arr := range refs {
+			if err := mergeRefs(ctx, d1, k, arr); err != nil {
+				return err
+			}
+		}
+
+		d1["TU"] = types.StringLiteral(types.DateString(t))
+	}
+
+	return ensureESICExtension(ctx)
+}
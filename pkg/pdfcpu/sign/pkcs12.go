@@ -0,0 +1,47 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"crypto"
+	"crypto/x509"
+	"io"
+
+	"github.com/pkg/errors"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ReadPKCS12 reads a PKCS#12 key store from r and returns its private key
+// together with the certificate chain starting with the signing certificate.
+func ReadPKCS12(r io.Reader, password string) (crypto.Signer, []*x509.Certificate, error) {
+	bb, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, cert, caCerts, err := pkcs12.DecodeChain(bb, password)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "pdfcpu: PKCS#12")
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.Errorf("pdfcpu: PKCS#12: unsupported private key type: %T", key)
+	}
+
+	return signer, append([]*x509.Certificate{cert}, caCerts...), nil
+}
@@ -0,0 +1,480 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sign provides support for digital signatures.
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/log"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/color"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/draw"
+	pdffont "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// The ByteRange of a signature dict gets patched in place after writing.
+const byteRangePlaceholder = "[0 9999999999 9999999999 9999999999]"
+
+// timestampSize is the number of bytes reserved for a RFC 3161 timestamp token.
+const timestampSize = 16384
+
+// ContentsSize returns the number of bytes reserved for the CMS signature
+// of a signature dict for a signing certificate chain.
+func ContentsSize(chain []*x509.Certificate) int {
+	n := 8192
+	for _, cert := range chain {
+		n += len(cert.Raw)
+	}
+	return n
+}
+
+func contentsSize(sig *model.Signature, chain []*x509.Certificate) int {
+	if sig.SubFilter == model.SubFilterRFC3161 {
+		return timestampSize
+	}
+	n := ContentsSize(chain)
+	if sig.TSA != nil {
+		n += timestampSize
+	}
+	return n
+}
+
+func byteRangePlaceholderArray() types.Array {
+	return types.Array{types.Integer(0), types.Integer(9999999999), types.Integer(9999999999), types.Integer(9999999999)}
+}
+
+func contentsPlaceholder(size int) string {
+	return "<" + strings.Repeat("0", 2*size) + ">"
+}
+
+func signerName(sig *model.Signature, cert *x509.Certificate) string {
+	if sig.Name != "" {
+		return sig.Name
+	}
+	return cert.Subject.CommonName
+}
+
+func stringLiteral(s string) (types.StringLiteral, error) {
+	s1, err := types.EscapeUTF16String(s)
+	if err != nil {
+		return "", err
+	}
+	return types.StringLiteral(*s1), nil
+}
+
+func signatureDict(sig *model.Signature, contentsSize int, t time.Time) (types.Dict, error) {
+	d := types.Dict(map[string]types.Object{
+		"Type":      types.Name("Sig"),
+		"Filter":    types.Name("Adobe.PPKLite"),
+		"SubFilter": types.Name(sig.SubFilter),
+		"ByteRange": byteRangePlaceholderArray(),
+		"Contents":  types.HexLiteral(strings.Repeat("0", 2*contentsSize)),
+	})
+
+	if sig.SubFilter == model.SubFilterRFC3161 {
+		// The signing time is part of the timestamp token.
+		d["Type"] = types.Name("DocTimeStamp")
+	} else {
+		d["M"] = types.StringLiteral(types.DateString(t))
+	}
+
+	for k, v := range map[string]string{
+		"Name":        sig.Name,
+		"Reason":      sig.Reason,
+		"Location":    sig.Location,
+		"ContactInfo": sig.ContactInfo,
+	} {
+		if v == "" {
+			continue
+		}
+		sl, err := stringLiteral(v)
+		if err != nil {
+			return nil, err
+		}
+		d[k] = sl
+	}
+
+	return d, nil
+}
+
+func appearanceText(sig *model.Signature, cert *x509.Certificate, t time.Time) string {
+	ss := []string{
+		"Digitally signed by " + signerName(sig, cert),
+		"Date: " + t.Format("2006-01-02 15:04:05 -07:00"),
+	}
+	if sig.Reason != "" {
+		ss = append(ss, "Reason: "+sig.Reason)
+	}
+	if sig.Location != "" {
+		ss = append(ss, "Location: "+sig.Location)
+	}
+	return strings.Join(ss, "\n")
+}
+
+func createAppearance(ctx *model.Context, sig *model.Signature, cert *x509.Certificate, t time.Time) (*types.IndirectRef, error) {
+	r := types.RectForDim(sig.Rect.Width(), sig.Rect.Height())
+
+	td := model.TextDescriptor{
+		Text:      appearanceText(sig, cert, t),
+		FontName:  sig.FontName,
+		FontKey:   "F1",
+		FontSize:  sig.FontSize,
+		Embed:     true,
+		MLeft:     2,
+		MTop:      2,
+		Scale:     1,
+		ScaleAbs:  true,
+		RMode:     draw.RMFill,
+		StrokeCol: color.Black,
+		FillCol:   color.Black,
+	}
+
+	// Rendering the text also registers the glyphs needed for user font subsetting.
+	var b bytes.Buffer
+	model.WriteMultiLineAnchored(ctx.XRefTable, &b, r, nil, td, types.TopLeft)
+
+	fontIndRef, err := pdffont.EnsureFontDict(ctx.XRefTable, sig.FontName, "", "", false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sd := types.StreamDict{
+		Dict: types.Dict(
+			map[string]types.Object{
+				"Type":    types.Name("XObject"),
+				"Subtype": types.Name("Form"),
+				"BBox":    r.Array(),
+				"Resources": types.Dict(map[string]types.Object{
+					"Font": types.Dict(map[string]types.Object{"F1": *fontIndRef}),
+				}),
+			},
+		),
+		Content:        b.Bytes(),
+		FilterPipeline: []types.PDFFilter{{Name: filter.Flate, DecodeParms: nil}},
+	}
+
+	sd.InsertName("Filter", filter.Flate)
+
+	if err := sd.Encode(); err != nil {
+		return nil, err
+	}
+
+	return ctx.IndRefForNewObject(sd)
+}
+
+func fieldName(d types.Dict) (string, error) {
+	o, found := d.Find("T")
+	if !found {
+		return "", nil
+	}
+	s, err := types.StringOrHexLiteral(o)
+	if err != nil {
+		return "", err
+	}
+	return *s, nil
+}
+
+// findField returns the field named name, which may be a partial or fully qualified field name.
+func findField(ctx *model.Context, fields types.Array, parent, name string) (types.Dict, error) {
+	for _, o := range fields {
+		d, err := ctx.DereferenceDict(o)
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			continue
+		}
+
+		t, err := fieldName(d)
+		if err != nil {
+			return nil, err
+		}
+
+		fullName := t
+		if parent != "" {
+			fullName = parent + "." + t
+		}
+
+		if t != "" && (t == name || fullName == name) {
+			return d, nil
+		}
+
+		kids, err := ctx.DereferenceArray(d["Kids"])
+		if err != nil {
+			return nil, err
+		}
+
+		if d, err = findField(ctx, kids, fullName, name); err != nil || d != nil {
+			return d, err
+		}
+	}
+
+	return nil, nil
+}
+
+func ensureAcroForm(ctx *model.Context) (types.Dict, types.Array, error) {
+	o, found := ctx.RootDict.Find("AcroForm")
+	if !found {
+		d := types.Dict(map[string]types.Object{"Fields": types.Array{}})
+		ctx.RootDict["AcroForm"] = d
+		return d, types.Array{}, nil
+	}
+
+	d, err := ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return nil, nil, errors.Errorf("pdfcpu: corrupt form dict: %v", err)
+	}
+
+	fields, err := ctx.DereferenceArray(d["Fields"])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d, fields, nil
+}
+
+// updateArray sets the array referenced by entry k of d to arr.
+func updateArray(ctx *model.Context, d types.Dict, k string, arr types.Array) error {
+	ir, ok := d[k].(types.IndirectRef)
+	if !ok {
+		d[k] = arr
+		return nil
+	}
+
+	entry, found := ctx.FindTableEntryForIndRef(&ir)
+	if !found {
+		return errors.Errorf("pdfcpu: missing obj#%d for %s", ir.ObjectNumber, k)
+	}
+	entry.Object = arr
+
+	return nil
+}
+
+func uniqueFieldName(ctx *model.Context, fields types.Array) (string, error) {
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("Signature%d", i)
+		d, err := findField(ctx, fields, "", name)
+		if err != nil {
+			return "", err
+		}
+		if d == nil {
+			return name, nil
+		}
+	}
+}
+
+func addSignatureWidget(ctx *model.Context, sig *model.Signature, cert *x509.Certificate, sigIndRef types.IndirectRef, t time.Time) (*types.IndirectRef, error) {
+	if sig.PageNr < 1 || sig.PageNr > ctx.PageCount {
+		return nil, errors.Errorf("pdfcpu: invalid signature page number: %d", sig.PageNr)
+	}
+
+	pageDict, pageIndRef, _, err := ctx.PageDict(sig.PageNr, false)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := stringLiteral(sig.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	rect := types.NewRectangle(0, 0, 0, 0)
+	if sig.Visible() {
+		rect = sig.Rect
+	}
+
+	d := types.Dict(map[string]types.Object{
+		"Type":    types.Name("Annot"),
+		"Subtype": types.Name("Widget"),
+		"FT":      types.Name("Sig"),
+		"T":       name,
+		"V":       sigIndRef,
+		"F":       types.Integer(model.AnnPrint + model.AnnLocked),
+		"Rect":    rect.Array(),
+		"P":       *pageIndRef,
+	})
+
+	if sig.Visible() {
+		apIndRef, err := createAppearance(ctx, sig, cert, t)
+		if err != nil {
+			return nil, err
+		}
+		d["AP"] = types.Dict(map[string]types.Object{"N": *apIndRef})
+	}
+
+	ir, err := ctx.IndRefForNewObject(d)
+	if err != nil {
+		return nil, err
+	}
+
+	annots, err := ctx.DereferenceArray(pageDict["Annots"])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := updateArray(ctx, pageDict, "Annots", append(annots, *ir)); err != nil {
+		return nil, err
+	}
+
+	return ir, nil
+}
+
+// PrepareSignature adds a signature dict with placeholders for ByteRange and Contents to ctx
+// and references it from the signature field sig.Field, which gets created unless present.
+// The signature needs to be finalized by Finalize after writing ctx.
+// For document timestamps (SubFilterRFC3161) chain is not needed and sig.TSA is required.
+func PrepareSignature(ctx *model.Context, sig *model.Signature, chain []*x509.Certificate, t time.Time) error {
+	if sig.SubFilter == "" {
+		sig.SubFilter = model.SubFilterCAdESDetached
+	}
+
+	var cert *x509.Certificate
+
+	if sig.SubFilter == model.SubFilterRFC3161 {
+		if sig.TSA == nil {
+			return errors.New("pdfcpu: missing time stamping authority")
+		}
+		if sig.Visible() {
+			return errors.New("pdfcpu: document timestamps are invisible")
+		}
+	} else {
+		if len(chain) == 0 {
+			return errors.New("pdfcpu: missing signing certificate")
+		}
+		cert = chain[0]
+	}
+
+	acroForm, fields, err := ensureAcroForm(ctx)
+	if err != nil {
+		return err
+	}
+
+	var fd types.Dict
+
+	if sig.Field == "" {
+		if sig.Field, err = uniqueFieldName(ctx, fields); err != nil {
+			return err
+		}
+	} else if fd, err = findField(ctx, fields, "", sig.Field); err != nil {
+		return err
+	}
+
+	if fd != nil {
+		if ft := fd.NameEntry("FT"); ft == nil || *ft != "Sig" {
+			return errors.Errorf("pdfcpu: field %s is not a signature field", sig.Field)
+		}
+		if _, found := fd.Find("V"); found {
+			return errors.Errorf("pdfcpu: signature field %s is already signed", sig.Field)
+		}
+	}
+
+	d, err := signatureDict(sig, contentsSize(sig, chain), t)
+	if err != nil {
+		return err
+	}
+
+	sigIndRef, err := ctx.IndRefForNewObject(d)
+	if err != nil {
+		return err
+	}
+
+	if fd != nil {
+		if log.CLIEnabled() {
+			log.CLI.Printf("signing field %s\n", sig.Field)
+		}
+		fd["V"] = *sigIndRef
+	} else {
+		if log.CLIEnabled() {
+			log.CLI.Printf("adding signature field %s\n", sig.Field)
+		}
+		ir, err := addSignatureWidget(ctx, sig, cert, *sigIndRef, t)
+		if err != nil {
+			return err
+		}
+		if err := updateArray(ctx, acroForm, "Fields", append(fields, *ir)); err != nil {
+			return err
+		}
+	}
+
+	// SignaturesExist, AppendOnly
+	acroForm["SigFlags"] = types.Integer(3)
+
+	return nil
+}
+
+// Finalize patches the ByteRange and Contents placeholders of the signature dict
+// of the written PDF file bb and signs or timestamps the covered byte ranges.
+func Finalize(bb []byte, signer crypto.Signer, chain []*x509.Certificate, sig *model.Signature, t time.Time) error {
+	size := contentsSize(sig, chain)
+	ph := contentsPlaceholder(size)
+
+	i := bytes.LastIndex(bb, []byte(byteRangePlaceholder))
+	if i < 0 {
+		return errors.New("pdfcpu: missing ByteRange placeholder")
+	}
+
+	j := bytes.LastIndex(bb, []byte(ph))
+	if j < 0 {
+		return errors.New("pdfcpu: missing Contents placeholder")
+	}
+
+	// The signature covers everything except the Contents hex string.
+	k := j + len(ph)
+	br := fmt.Sprintf("[0 %d %d %d]", j, k, len(bb)-k)
+	copy(bb[i:], br+strings.Repeat(" ", len(byteRangePlaceholder)-len(br)))
+
+	content := io.MultiReader(bytes.NewReader(bb[:j]), bytes.NewReader(bb[k:]))
+
+	var (
+		cms []byte
+		err error
+	)
+
+	if sig.SubFilter == model.SubFilterRFC3161 {
+		h := sha256.New()
+		if _, err := io.Copy(h, content); err != nil {
+			return err
+		}
+		cms, err = sig.TSA.Timestamp(h.Sum(nil), crypto.SHA256)
+	} else {
+		cms, err = CreateCMS(content, signer, chain, sig.SubFilter, t, sig.TSA)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(cms) > size {
+		return errors.Errorf("pdfcpu: signature too large: %d > %d bytes", len(cms), size)
+	}
+
+	hex.Encode(bb[j+1:], cms)
+
+	return nil
+}
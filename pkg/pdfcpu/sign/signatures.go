@@ -0,0 +1,598 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// Signature types
+const (
+	SignatureTypeApproval      = "approval"
+	SignatureTypeCertification = "certification"
+	SignatureTypeUsageRights   = "usage rights"
+	SignatureTypeDocTimestamp  = "document timestamp"
+)
+
+// SubFilterX509RSASHA1 identifies the deprecated PKCS#1 signature format.
+const SubFilterX509RSASHA1 = "adbe.x509.rsa_sha1"
+
+// SignatureDetails represents a signature field or a signature referenced by the document permissions.
+type SignatureDetails struct {
+	Field          string        `json:"field,omitempty"`
+	Type           string        `json:"type"`
+	Signed         bool          `json:"signed"`
+	PageNr         int           `json:"page,omitempty"`
+	Visible        bool          `json:"visible"`
+	SubFilter      string        `json:"subFilter,omitempty"`
+	Signer         string        `json:"signer,omitempty"`
+	Issuer         string        `json:"issuer,omitempty"`
+	SigningTime    *time.Time    `json:"signingTime,omitempty"` // the time claimed by the signer
+	Timestamp      *time.Time    `json:"timestamp,omitempty"`   // the time of the signature timestamp
+	Name           string        `json:"name,omitempty"`
+	Reason         string        `json:"reason,omitempty"`
+	Location       string        `json:"location,omitempty"`
+	ContactInfo    string        `json:"contactInfo,omitempty"`
+	ByteRange      []int64       `json:"byteRange,omitempty"`
+	WholeDocument  bool          `json:"wholeDocument"`  // the byte ranges cover the whole file
+	LaterRevisions int           `json:"laterRevisions"` // the number of incremental updates written after signing
+	Problems       []string      `json:"problems,omitempty"`
+	Verification   *Verification `json:"verification,omitempty"`
+
+	sigObjNr int
+	contents []byte
+	cms      *cms
+	tst      *timestamp // the document timestamp or signature timestamp
+}
+
+// Modified returns true if the document has been changed after signing.
+func (sd SignatureDetails) Modified() bool {
+	return sd.Signed && !sd.WholeDocument
+}
+
+type collector struct {
+	ctx  *model.Context
+	bb   []byte // The input file.
+	sigs []SignatureDetails
+}
+
+func (c *collector) text(d types.Dict, key string) string {
+	o, found := d.Find(key)
+	if !found {
+		return ""
+	}
+	s, err := c.ctx.DereferenceText(o)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+func (c *collector) byteRange(d types.Dict, sd *SignatureDetails) {
+	arr, err := c.ctx.DereferenceArray(d["ByteRange"])
+	if err != nil || len(arr) != 4 {
+		sd.Problems = append(sd.Problems, "pdfcpu: corrupt ByteRange")
+		return
+	}
+
+	for _, o := range arr {
+		i, err := c.ctx.DereferenceInteger(o)
+		if err != nil || i == nil {
+			sd.Problems = append(sd.Problems, "pdfcpu: corrupt ByteRange")
+			sd.ByteRange = nil
+			return
+		}
+		sd.ByteRange = append(sd.ByteRange, int64(i.Value()))
+	}
+
+	br, size := sd.ByteRange, int64(len(c.bb))
+	for i := 0; i < len(br); i += 2 {
+		if br[i] < 0 || br[i+1] < 0 || br[i]+br[i+1] > size {
+			sd.Problems = append(sd.Problems, "pdfcpu: ByteRange exceeds file size")
+			sd.ByteRange = nil
+			return
+		}
+	}
+
+	if !c.excludesContents(d, sd) {
+		sd.Problems = append(sd.Problems, "pdfcpu: ByteRange does not exclude exactly the signature contents")
+		sd.ByteRange = nil
+		return
+	}
+
+	end := br[2] + br[3]
+	sd.WholeDocument = br[0] == 0 && end == size
+	sd.LaterRevisions = bytes.Count(c.bb[end:], []byte("%%EOF"))
+}
+
+// excludesContents returns true if the gap between the byte ranges of sd
+// is the hex string holding the Contents of the signature dict d.
+func (c *collector) excludesContents(d types.Dict, sd *SignatureDetails) bool {
+	br := sd.ByteRange
+	from, to := br[0]+br[1], br[2]
+	if from >= to-1 || c.bb[from] != '<' || c.bb[to-1] != '>' {
+		return false
+	}
+
+	contents, err := d.StringEntryBytes("Contents")
+	if err != nil || contents == nil {
+		return false
+	}
+
+	bb, err := hex.DecodeString(string(bytes.Join(bytes.Fields(c.bb[from+1:to-1]), nil)))
+	if err != nil || !bytes.Equal(bb, contents) {
+		return false
+	}
+
+	if !bytes.HasSuffix(bytes.TrimRight(c.bb[:from], " \t\r\n"), []byte("/Contents")) {
+		return false
+	}
+
+	if sd.sigObjNr == 0 {
+		return true
+	}
+
+	// The hex string has to be part of the signature dict.
+	entry, found := c.ctx.FindTableEntryLight(sd.sigObjNr)
+	if !found || entry.Compressed || entry.Offset == nil || *entry.Offset >= from {
+		return false
+	}
+
+	return !bytes.Contains(c.bb[*entry.Offset:from], []byte("endobj"))
+}
+
+// signedContent returns the bytes covered by the byte ranges of sd.
+func (c *collector) signedContent(sd *SignatureDetails) []byte {
+	var bb []byte
+	for i := 0; i < len(sd.ByteRange); i += 2 {
+		bb = append(bb, c.bb[sd.ByteRange[i]:sd.ByteRange[i]+sd.ByteRange[i+1]]...)
+	}
+	return bb
+}
+
+func (c *collector) signature(d types.Dict, sd *SignatureDetails) {
+	sd.Signed = true
+
+	if s := d.NameEntry("SubFilter"); s != nil {
+		sd.SubFilter = *s
+	}
+
+	sd.Name = c.text(d, "Name")
+	sd.Reason = c.text(d, "Reason")
+	sd.Location = c.text(d, "Location")
+	sd.ContactInfo = c.text(d, "ContactInfo")
+
+	if t, ok := types.DateTime(c.text(d, "M"), true); ok {
+		sd.SigningTime = &t
+	}
+
+	c.byteRange(d, sd)
+
+	if sd.SubFilter == SubFilterX509RSASHA1 {
+		sd.Problems = append(sd.Problems, "pdfcpu: unsupported signature format: "+SubFilterX509RSASHA1)
+		return
+	}
+
+	contents, err := d.StringEntryBytes("Contents")
+	if err != nil || len(contents) == 0 {
+		sd.Problems = append(sd.Problems, "pdfcpu: missing signature contents")
+		return
+	}
+
+	sd.contents = contents
+
+	if sd.SubFilter == model.SubFilterRFC3161 || d.Type() != nil && *d.Type() == "DocTimeStamp" {
+		c.docTimestamp(sd)
+		return
+	}
+
+	if sd.cms, err = parseCMS(contents); err != nil {
+		sd.Problems = append(sd.Problems, err.Error())
+		return
+	}
+
+	sd.Signer = sd.cms.signer.Subject.String()
+	sd.Issuer = sd.cms.signer.Issuer.String()
+
+	// Prefer the signed signing time over M.
+	if t := sd.cms.signingTime(); t != nil {
+		sd.SigningTime = t
+	}
+
+	if bb, ok := sd.cms.unsignedAttribute(oidAttrTimeStampToken); ok {
+		if sd.tst, err = newTimestamp(bb); err != nil {
+			sd.Problems = append(sd.Problems, "signature timestamp: "+err.Error())
+			return
+		}
+		sd.Timestamp = &sd.tst.info.GenTime
+	}
+}
+
+func (c *collector) docTimestamp(sd *SignatureDetails) {
+	sd.Type = SignatureTypeDocTimestamp
+
+	ts, err := newTimestamp(sd.contents)
+	if err != nil {
+		sd.Problems = append(sd.Problems, err.Error())
+		return
+	}
+
+	sd.cms, sd.tst = ts.cms, ts
+	sd.Signer = ts.cms.signer.Subject.String()
+	sd.Issuer = ts.cms.signer.Issuer.String()
+	sd.SigningTime = &ts.info.GenTime
+}
+
+func (c *collector) widget(d types.Dict, sd *SignatureDetails) {
+	if ir := d.IndirectRefEntry("P"); ir != nil {
+		if pageNr, err := c.ctx.PageNumber(ir.ObjectNumber.Value()); err == nil {
+			sd.PageNr = pageNr
+		}
+	}
+
+	arr, err := c.ctx.DereferenceArray(d["Rect"])
+	if err != nil || len(arr) != 4 {
+		return
+	}
+
+	r, err := c.ctx.RectForArray(arr)
+	if err != nil {
+		return
+	}
+
+	f := d.IntEntry("F")
+	hidden := f != nil && *f&(int(model.AnnHidden)|int(model.AnnNoView)) > 0
+
+	sd.Visible = !hidden && r.Width() > 0 && r.Height() > 0
+}
+
+func (c *collector) field(o types.Object, parent, ft string) error {
+	d, err := c.ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return err
+	}
+
+	t, err := fieldName(d)
+	if err != nil {
+		return err
+	}
+
+	fullName := t
+	if parent != "" && t != "" {
+		fullName = parent + "." + t
+	} else if t == "" {
+		fullName = parent
+	}
+
+	if s := d.NameEntry("FT"); s != nil {
+		ft = *s
+	}
+
+	kids, err := c.ctx.DereferenceArray(d["Kids"])
+	if err != nil {
+		return err
+	}
+
+	// The kids of a terminal field are its widgets.
+	var widget types.Dict
+	if len(kids) > 0 {
+		if widget, err = c.ctx.DereferenceDict(kids[0]); err != nil {
+			return err
+		}
+		if widget != nil && widget["T"] != nil {
+			for _, kid := range kids {
+				if err := c.field(kid, fullName, ft); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	} else {
+		// Merged field and widget
+		widget = d
+	}
+
+	if ft != "Sig" {
+		return nil
+	}
+
+	sd := SignatureDetails{Field: fullName, Type: SignatureTypeApproval}
+
+	if widget != nil {
+		c.widget(widget, &sd)
+	}
+
+	if ir, ok := d["V"].(types.IndirectRef); ok {
+		sd.sigObjNr = ir.ObjectNumber.Value()
+	}
+
+	v, err := c.ctx.DereferenceDict(d["V"])
+	if err != nil {
+		return err
+	}
+
+	if v != nil {
+		c.signature(v, &sd)
+	}
+
+	c.sigs = append(c.sigs, sd)
+
+	return nil
+}
+
+func (c *collector) fields() error {
+	o, found := c.ctx.RootDict.Find("AcroForm")
+	if !found {
+		return nil
+	}
+
+	d, err := c.ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return err
+	}
+
+	fields, err := c.ctx.DereferenceArray(d["Fields"])
+	if err != nil {
+		return err
+	}
+
+	for _, o := range fields {
+		if err := c.field(o, "", ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// signatureForIndRef returns the signature field whose value is ir.
+func (c *collector) signatureForIndRef(ir *types.IndirectRef) *SignatureDetails {
+	if ir == nil {
+		return nil
+	}
+	for i := range c.sigs {
+		if c.sigs[i].sigObjNr == ir.ObjectNumber.Value() {
+			return &c.sigs[i]
+		}
+	}
+	return nil
+}
+
+// perms processes the signatures referenced by the document permissions.
+func (c *collector) perms() error {
+	d, err := c.ctx.DereferenceDict(c.ctx.RootDict["Perms"])
+	if err != nil || d == nil {
+		return err
+	}
+
+	for _, k := range []string{"DocMDP", "UR3"} {
+		typ := SignatureTypeCertification
+		if k == "UR3" {
+			typ = SignatureTypeUsageRights
+		}
+
+		if sd := c.signatureForIndRef(d.IndirectRefEntry(k)); sd != nil {
+			sd.Type = typ
+			continue
+		}
+
+		v, err := c.ctx.DereferenceDict(d[k])
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			continue
+		}
+
+		sd := SignatureDetails{Type: typ}
+		if ir := d.IndirectRefEntry(k); ir != nil {
+			sd.sigObjNr = ir.ObjectNumber.Value()
+		}
+		c.signature(v, &sd)
+		c.sigs = append(c.sigs, sd)
+	}
+
+	return nil
+}
+
+func readFile(ctx *model.Context) ([]byte, error) {
+	rs := ctx.Read.RS
+	if rs == nil {
+		return nil, errors.New("pdfcpu: missing input file")
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(rs)
+}
+
+func collect(ctx *model.Context) (*collector, error) {
+	bb, err := readFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &collector{ctx: ctx, bb: bb}
+
+	if err := c.fields(); err != nil {
+		return nil, err
+	}
+
+	if err := c.perms(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Signatures returns all signature fields and signatures referenced by the document permissions of ctx.
+func Signatures(ctx *model.Context) ([]SignatureDetails, error) {
+	c, err := collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.sigs, nil
+}
+
+// Verify returns all signatures of ctx including their verification results.
+// A signer certificate is trusted if it chains up to a certificate in roots.
+func Verify(ctx *model.Context, roots *x509.CertPool) ([]SignatureDetails, error) {
+	c, err := collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range c.sigs {
+		sd := &c.sigs[i]
+		if !sd.Signed {
+			continue
+		}
+
+		v := &Verification{Problems: append([]string(nil), sd.Problems...)}
+		sd.Verification = v
+
+		if sd.cms == nil || sd.ByteRange == nil {
+			continue
+		}
+
+		if sd.Type == SignatureTypeDocTimestamp {
+			sd.tst.verify(c.signedContent(sd), roots, v)
+			continue
+		}
+
+		sd.cms.verify(c.signedContent(sd), v)
+
+		if _, found := sd.cms.unsignedAttribute(oidAttrTimeStampToken); found {
+			ok := false
+			if sd.tst != nil {
+				// The signature timestamp covers the signature value.
+				tv := &Verification{}
+				sd.tst.verify(sd.cms.signerInfo.Signature, roots, tv)
+				ok = tv.Valid()
+				for _, p := range tv.Problems {
+					v.Problems = append(v.Problems, "signature timestamp: "+p)
+				}
+			}
+			v.TimestampOK = &ok
+		}
+
+		// Only a verified timestamp attests the time of signing,
+		// the signing time claimed by the signer is informational only.
+		t := time.Now()
+		if v.TimestampOK != nil && *v.TimestampOK {
+			t = *sd.Timestamp
+		}
+		sd.cms.verifyChain(roots, t, v)
+	}
+
+	return c.sigs, nil
+}
+
+// ListSignatures returns a formatted list of signatures.
+func ListSignatures(sigs []SignatureDetails) []string {
+	if len(sigs) == 0 {
+		return []string{"no signatures available"}
+	}
+
+	var ss []string
+
+	for i, sd := range sigs {
+		if i > 0 {
+			ss = append(ss, "")
+		}
+
+		s := "unsigned"
+		if sd.Signed {
+			s = "signed"
+		}
+		if sd.Field != "" {
+			ss = append(ss, fmt.Sprintf("%s signature field %s (%s):", sd.Type, sd.Field, s))
+		} else {
+			ss = append(ss, fmt.Sprintf("%s signature (%s):", sd.Type, s))
+		}
+
+		if sd.PageNr > 0 {
+			vis := "invisible"
+			if sd.Visible {
+				vis = "visible"
+			}
+			ss = append(ss, fmt.Sprintf("%15s: %d (%s)", "page", sd.PageNr, vis))
+		}
+
+		if !sd.Signed {
+			continue
+		}
+
+		for _, kv := range [][2]string{
+			{"subFilter", sd.SubFilter},
+			{"signer", sd.Signer},
+			{"issuer", sd.Issuer},
+			{"name", sd.Name},
+			{"reason", sd.Reason},
+			{"location", sd.Location},
+			{"contact", sd.ContactInfo},
+		} {
+			if kv[1] != "" {
+				ss = append(ss, fmt.Sprintf("%15s: %s", kv[0], kv[1]))
+			}
+		}
+
+		if sd.SigningTime != nil {
+			ss = append(ss, fmt.Sprintf("%15s: %s", "signing time", sd.SigningTime.Format(time.RFC3339)))
+		}
+
+		if sd.Timestamp != nil {
+			ss = append(ss, fmt.Sprintf("%15s: %s", "timestamp", sd.Timestamp.Format(time.RFC3339)))
+		}
+
+		if sd.ByteRange != nil {
+			ss = append(ss, fmt.Sprintf("%15s: %v", "byte range", sd.ByteRange))
+			ss = append(ss, fmt.Sprintf("%15s: %t (%d later revisions)", "modified", sd.Modified(), sd.LaterRevisions))
+		}
+
+		if v := sd.Verification; v != nil {
+			status := "invalid"
+			if v.Valid() {
+				status = "valid"
+			}
+			s := fmt.Sprintf("%s (digest ok: %t, signature ok: %t, trusted: %t", status, v.DigestOK, v.SignatureOK, v.Trusted)
+			if v.TimestampOK != nil {
+				s += fmt.Sprintf(", timestamp ok: %t", *v.TimestampOK)
+			}
+			ss = append(ss, fmt.Sprintf("%15s: %s)", "verification", s))
+			for _, p := range v.Problems {
+				ss = append(ss, fmt.Sprintf("%15s  %s", "", p))
+			}
+			continue
+		}
+
+		for _, p := range sd.Problems {
+			ss = append(ss, fmt.Sprintf("%15s: %s", "problem", p))
+		}
+	}
+
+	return ss
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pkg/errors"
+)
+
+// RFC 3161 Time-Stamp Protocol
+
+const (
+	timestampQueryContentType = "application/timestamp-query"
+	timestampReplyContentType = "application/timestamp-reply"
+
+	maxTimestampReplySize = 1 << 20
+)
+
+// defaultTSAClient gives up on unresponsive time stamping authorities.
+var defaultTSAClient = &http.Client{Timeout: 30 * time.Second}
+
+// PKIStatus
+const (
+	pkiStatusGranted         = 0
+	pkiStatusGrantedWithMods = 1
+)
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional,default:false"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional,utf8"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       accuracy  `asn1:"optional"`
+	Ordering       bool      `asn1:"optional,default:false"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+func digestAlgorithmOID(h crypto.Hash) (asn1.ObjectIdentifier, error) {
+	switch h {
+	case crypto.SHA1:
+		return oidDigestSHA1, nil
+	case crypto.SHA256:
+		return oidDigestSHA256, nil
+	case crypto.SHA384:
+		return oidDigestSHA384, nil
+	case crypto.SHA512:
+		return oidDigestSHA512, nil
+	}
+	return nil, errors.Errorf("pdfcpu: unsupported digest algorithm: %s", h)
+}
+
+func newMessageImprint(digest []byte, h crypto.Hash) (messageImprint, error) {
+	oid, err := digestAlgorithmOID(h)
+	if err != nil {
+		return messageImprint{}, err
+	}
+	if len(digest) != h.Size() {
+		return messageImprint{}, errors.Errorf("pdfcpu: invalid %s digest length: %d", h, len(digest))
+	}
+	return messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oid, Parameters: asn1.NullRawValue}, HashedMessage: digest}, nil
+}
+
+// parseTimestampToken parses a timestamp token, a CMS SignedData encapsulating a TSTInfo.
+func parseTimestampToken(token []byte) (*cms, *tstInfo, error) {
+	c, err := parseCMS(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !c.eContentType.Equal(oidTSTInfo) {
+		return nil, nil, errors.Errorf("pdfcpu: unexpected timestamp token content type: %s", c.eContentType)
+	}
+
+	var info tstInfo
+	if _, err := asn1.Unmarshal(c.eContent, &info); err != nil {
+		return nil, nil, errors.Wrap(err, "pdfcpu: corrupt timestamp token")
+	}
+
+	return c, &info, nil
+}
+
+// verifyImprint checks if the message imprint of info matches content.
+func (info *tstInfo) verifyImprint(content []byte) (bool, error) {
+	h, err := digestAlgorithm(info.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return false, err
+	}
+	digest := h.New()
+	digest.Write(content)
+	return bytes.Equal(digest.Sum(nil), info.MessageImprint.HashedMessage), nil
+}
+
+// timestamp represents a parsed timestamp token.
+type timestamp struct {
+	cms  *cms
+	info *tstInfo
+}
+
+func newTimestamp(token []byte) (*timestamp, error) {
+	c, info, err := parseTimestampToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return &timestamp{cms: c, info: info}, nil
+}
+
+// verify checks the integrity of the timestamp token, if it timestamps content and if the TSA is trusted.
+func (ts *timestamp) verify(content []byte, roots *x509.CertPool, v *Verification) {
+	ts.cms.verify(ts.cms.eContent, v)
+
+	ok, err := ts.info.verifyImprint(content)
+	if err != nil {
+		v.addProblem(err)
+	} else if !ok {
+		v.addProblem(errors.New("pdfcpu: timestamp message imprint mismatch, the timestamped data has been altered"))
+	}
+	v.DigestOK = v.DigestOK && ok
+
+	ts.cms.verifyChain(roots, ts.info.GenTime, v)
+}
+
+// HTTPTimestampAuthority requests RFC 3161 timestamp tokens from a time stamping authority via HTTP.
+type HTTPTimestampAuthority struct {
+	URL      string                // TSA endpoint
+	Client   *http.Client          // defaults to a client timing out after 30s
+	Username string                // optional basic authentication
+	Password string                // optional basic authentication
+	Policy   asn1.ObjectIdentifier // optional TSA policy
+}
+
+// NewHTTPTimestampAuthority returns a client for the time stamping authority at url.
+func NewHTTPTimestampAuthority(url string) *HTTPTimestampAuthority {
+	return &HTTPTimestampAuthority{URL: url}
+}
+
+func (tsa *HTTPTimestampAuthority) request(req []byte) ([]byte, error) {
+	r, err := http.NewRequest(http.MethodPost, tsa.URL, bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header.Set("Content-Type", timestampQueryContentType)
+	if tsa.Username != "" {
+		r.SetBasicAuth(tsa.Username, tsa.Password)
+	}
+
+	client := tsa.Client
+	if client == nil {
+		client = defaultTSAClient
+	}
+
+	resp, err := client.Do(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: timestamp request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("pdfcpu: timestamp request: %s", resp.Status)
+	}
+
+	if ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); ct != timestampReplyContentType {
+		return nil, errors.Errorf("pdfcpu: timestamp request: unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+
+	bb, err := io.ReadAll(io.LimitReader(resp.Body, maxTimestampReplySize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: timestamp request")
+	}
+
+	if len(bb) > maxTimestampReplySize {
+		return nil, errors.Errorf("pdfcpu: timestamp request: response exceeds %d bytes", maxTimestampReplySize)
+	}
+
+	return bb, nil
+}
+
+// Timestamp returns a DER encoded RFC 3161 timestamp token for digest created using h.
+func (tsa *HTTPTimestampAuthority) Timestamp(digest []byte, h crypto.Hash) ([]byte, error) {
+	imprint, err := newMessageImprint(digest, h)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: imprint,
+		ReqPolicy:      tsa.Policy,
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bb, err := tsa.request(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp timeStampResp
+	if _, err := asn1.Unmarshal(bb, &resp); err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: corrupt timestamp response")
+	}
+
+	if st := resp.Status.Status; st != pkiStatusGranted && st != pkiStatusGrantedWithMods {
+		return nil, errors.Errorf("pdfcpu: timestamp request rejected: status=%d %v", st, resp.Status.StatusString)
+	}
+
+	token := resp.TimeStampToken.FullBytes
+
+	_, info, err := parseTimestampToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(info.MessageImprint.HashedMessage, digest) {
+		return nil, errors.New("pdfcpu: timestamp token for wrong message imprint")
+	}
+
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("pdfcpu: timestamp token nonce mismatch")
+	}
+
+	return token, nil
+}
+
+var _ model.TimestampAuthority = (*HTTPTimestampAuthority)(nil)
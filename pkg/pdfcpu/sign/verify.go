@@ -0,0 +1,363 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"time"
+
+	// Register the supported digest algorithms.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	oidDigestSHA1      = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSHA384    = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidDigestSHA512    = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidSignatureRSAPSS = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+)
+
+// Verification represents the result of verifying a digital signature.
+type Verification struct {
+	DigestOK    bool     `json:"digestOK"`              // the signed message digest matches the signed byte ranges
+	SignatureOK bool     `json:"signatureOK"`           // the signature was created by the private key of the signer certificate
+	Trusted     bool     `json:"trusted"`               // the signer certificate chains up to a trusted root certificate
+	TimestampOK *bool    `json:"timestampOK,omitempty"` // the signature timestamp is intact and trusted
+	Problems    []string `json:"problems,omitempty"`
+}
+
+// Valid returns true if the signature is intact and trusted.
+func (v Verification) Valid() bool {
+	return v.DigestOK && v.SignatureOK && v.Trusted && (v.TimestampOK == nil || *v.TimestampOK)
+}
+
+func (v *Verification) addProblem(err error) {
+	v.Problems = append(v.Problems, err.Error())
+}
+
+// cms represents a parsed detached CMS signature.
+type cms struct {
+	signerInfo   signerInfo
+	certs        []*x509.Certificate
+	signer       *x509.Certificate
+	eContentType asn1.ObjectIdentifier
+	eContent     []byte // encapsulated content, nil for detached signatures
+}
+
+func parseCMS(der []byte) (*cms, error) {
+	var ci contentInfo
+	// Ignore the zero padding of the Contents placeholder.
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: corrupt CMS signature")
+	}
+
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, errors.Errorf("pdfcpu: unsupported CMS content type: %s", ci.ContentType)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: corrupt CMS signed data")
+	}
+
+	if len(sd.SignerInfos) != 1 {
+		return nil, errors.Errorf("pdfcpu: expected 1 CMS signer info, got %d", len(sd.SignerInfos))
+	}
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: corrupt CMS certificates")
+	}
+
+	c := &cms{signerInfo: sd.SignerInfos[0], certs: certs, eContentType: sd.EncapContentInfo.EContentType}
+
+	if eci := sd.EncapContentInfo.EContent; len(eci.Bytes) > 0 {
+		if _, err := asn1.Unmarshal(eci.Bytes, &c.eContent); err != nil {
+			return nil, errors.Wrap(err, "pdfcpu: corrupt CMS encapsulated content")
+		}
+	}
+
+	if c.signer, err = signerCertificate(c.signerInfo.SID, certs); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func signerCertificate(sid asn1.RawValue, certs []*x509.Certificate) (*x509.Certificate, error) {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		// SubjectKeyIdentifier
+		for _, cert := range certs {
+			if bytes.Equal(cert.SubjectKeyId, sid.Bytes) {
+				return cert, nil
+			}
+		}
+		return nil, errors.New("pdfcpu: missing CMS signer certificate")
+	}
+
+	var ias issuerAndSerialNumber
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil, errors.Wrap(err, "pdfcpu: corrupt CMS signer identifier")
+	}
+
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) && cert.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+			return cert, nil
+		}
+	}
+
+	return nil, errors.New("pdfcpu: missing CMS signer certificate")
+}
+
+func digestAlgorithm(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidDigestSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidDigestSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidDigestSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidDigestSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, errors.Errorf("pdfcpu: unsupported digest algorithm: %s", oid)
+}
+
+func x509SignatureAlgorithm(cert *x509.Certificate, h crypto.Hash, sigAlg asn1.ObjectIdentifier) x509.SignatureAlgorithm {
+	switch cert.PublicKeyAlgorithm {
+
+	case x509.RSA:
+		if sigAlg.Equal(oidSignatureRSAPSS) {
+			return map[crypto.Hash]x509.SignatureAlgorithm{
+				crypto.SHA256: x509.SHA256WithRSAPSS,
+				crypto.SHA384: x509.SHA384WithRSAPSS,
+				crypto.SHA512: x509.SHA512WithRSAPSS,
+			}[h]
+		}
+		return map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA1:   x509.SHA1WithRSA,
+			crypto.SHA256: x509.SHA256WithRSA,
+			crypto.SHA384: x509.SHA384WithRSA,
+			crypto.SHA512: x509.SHA512WithRSA,
+		}[h]
+
+	case x509.ECDSA:
+		return map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA1:   x509.ECDSAWithSHA1,
+			crypto.SHA256: x509.ECDSAWithSHA256,
+			crypto.SHA384: x509.ECDSAWithSHA384,
+			crypto.SHA512: x509.ECDSAWithSHA512,
+		}[h]
+
+	case x509.Ed25519:
+		return x509.PureEd25519
+	}
+
+	return x509.UnknownSignatureAlgorithm
+}
+
+func attributeValue(attrs asn1.RawValue, oid asn1.ObjectIdentifier) ([]byte, bool) {
+	rest := attrs.Bytes
+	for len(rest) > 0 {
+		var attr attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return nil, false
+		}
+		if attr.Type.Equal(oid) {
+			return attr.Values.Bytes, true
+		}
+	}
+	return nil, false
+}
+
+// signedAttribute returns the value of the signed attribute oid.
+func (c *cms) signedAttribute(oid asn1.ObjectIdentifier) ([]byte, bool) {
+	return attributeValue(c.signerInfo.SignedAttrs, oid)
+}
+
+// unsignedAttribute returns the value of the unsigned attribute oid.
+func (c *cms) unsignedAttribute(oid asn1.ObjectIdentifier) ([]byte, bool) {
+	return attributeValue(c.signerInfo.UnsignedAttrs, oid)
+}
+
+// signingTime returns the signing time claimed by the signer, if available.
+func (c *cms) signingTime() *time.Time {
+	bb, ok := c.signedAttribute(oidAttrSigningTime)
+	if !ok {
+		return nil
+	}
+	var t time.Time
+	if _, err := asn1.Unmarshal(bb, &t); err != nil {
+		return nil
+	}
+	return &t
+}
+
+func (c *cms) verifyDigest(h crypto.Hash, content []byte, v *Verification) {
+	digest := h.New()
+	digest.Write(content)
+
+	bb, ok := c.signedAttribute(oidAttrMessageDigest)
+	if !ok {
+		v.addProblem(errors.New("pdfcpu: missing CMS message digest"))
+		return
+	}
+
+	var md []byte
+	if _, err := asn1.Unmarshal(bb, &md); err != nil {
+		v.addProblem(errors.Wrap(err, "pdfcpu: corrupt CMS message digest"))
+		return
+	}
+
+	if v.DigestOK = bytes.Equal(md, digest.Sum(nil)); !v.DigestOK {
+		v.addProblem(errors.New("pdfcpu: message digest mismatch, the document has been altered"))
+	}
+}
+
+// verify checks the integrity of content and the signature of its signed attributes.
+func (c *cms) verify(content []byte, v *Verification) {
+	si := c.signerInfo
+
+	h, err := digestAlgorithm(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		v.addProblem(err)
+		return
+	}
+
+	alg := x509SignatureAlgorithm(c.signer, h, si.SignatureAlgorithm.Algorithm)
+
+	// Without signed attributes the signature covers the content.
+	signed := content
+	if len(si.SignedAttrs.FullBytes) > 0 {
+		c.verifyDigest(h, content, v)
+		// The signature covers the DER encoded signed attributes using the SET OF tag.
+		signed = append([]byte{0x31}, si.SignedAttrs.FullBytes[1:]...)
+	}
+
+	if err := c.signer.CheckSignature(alg, signed, si.Signature); err != nil {
+		v.addProblem(errors.Wrap(err, "pdfcpu: invalid signature"))
+		return
+	}
+
+	v.SignatureOK = true
+	if len(si.SignedAttrs.FullBytes) == 0 {
+		v.DigestOK = true
+	}
+}
+
+// verifyChain checks if the signer certificate chains up to a trusted root certificate at time t.
+func (c *cms) verifyChain(roots *x509.CertPool, t time.Time, v *Verification) {
+	intermediates := x509.NewCertPool()
+	for _, cert := range c.certs {
+		intermediates.AddCert(cert)
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   t,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+
+	if _, err := c.signer.Verify(opts); err != nil {
+		v.addProblem(errors.Wrap(err, "pdfcpu: untrusted signer certificate"))
+		return
+	}
+
+	v.Trusted = true
+}
+
+func parseCertificates(bb []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	for {
+		var block *pem.Block
+		if block, bb = pem.Decode(bb); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) > 0 {
+		return certs, nil
+	}
+
+	// DER
+	return x509.ParseCertificates(bb)
+}
+
+// LoadTrustStore returns a pool containing all PEM or DER encoded certificates found in dir.
+// The system certificate pool is used for an empty dir.
+func LoadTrustStore(dir string) (*x509.CertPool, error) {
+	if dir == "" {
+		return x509.SystemCertPool()
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	n := 0
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		bb, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		certs, err := parseCertificates(bb)
+		if err != nil {
+			// Skip files not containing certificates.
+			continue
+		}
+
+		for _, cert := range certs {
+			pool.AddCert(cert)
+			n++
+		}
+	}
+
+	if n == 0 {
+		return nil, errors.Errorf("pdfcpu: no certificates found in trust store %s", dir)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,348 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package text
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/content"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// codeRange is a range of character codes of equal byte length.
+type codeRange struct {
+	lo, hi []byte
+}
+
+func (r codeRange) contains(code []byte) bool {
+	if len(code) != len(r.lo) {
+		return false
+	}
+	for i, b := range code {
+		if b < r.lo[i] || b > r.hi[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// offset returns the distance of code from r.lo treating both as big endian integers.
+func (r codeRange) offset(code []byte) int {
+	return codeValue(code) - codeValue(r.lo)
+}
+
+// bfRange maps a code range to Unicode.
+type bfRange struct {
+	codeRange
+	dst  []rune   // start value, incremented by offset
+	dsts []string // explicit values
+}
+
+// cidRange maps a code range to CIDs.
+type cidRange struct {
+	codeRange
+	cid int
+}
+
+// cmap represents a CMap mapping character codes to CIDs or Unicode values (ToUnicode).
+// See 9.7.5 and 9.10.3
+type cmap struct {
+	name       string
+	vertical   bool
+	identity   bool // Identity-H, Identity-V: 2 byte codes = CIDs
+	unicode    bool // predefined UCS2/UTF16 CMaps: codes are UTF-16BE
+	codespaces []codeRange
+	cidChars   map[string]int
+	cidRanges  []cidRange
+	bfChars    map[string]string
+	bfRanges   []bfRange
+}
+
+func codeValue(bb []byte) int {
+	v := 0
+	for _, b := range bb {
+		v = v<<8 | int(b)
+	}
+	return v
+}
+
+func utf16BEToString(bb []byte) string {
+	if len(bb)%2 == 1 {
+		// Single byte destinations are not valid UTF-16BE but are used by some producers.
+		bb = append([]byte{0x00}, bb...)
+	}
+	uu := make([]uint16, len(bb)/2)
+	for i := range uu {
+		uu[i] = uint16(bb[2*i])<<8 | uint16(bb[2*i+1])
+	}
+	return string(utf16.Decode(uu))
+}
+
+// predefinedCMap returns the predefined CMap for name as far as supported.
+func predefinedCMap(name string) *cmap {
+	cm := &cmap{name: name, vertical: strings.HasSuffix(name, "-V")}
+
+	switch {
+
+	case name == "Identity-H" || name == "Identity-V":
+		cm.identity = true
+		cm.codespaces = []codeRange{{lo: []byte{0x00, 0x00}, hi: []byte{0xFF, 0xFF}}}
+
+	case strings.Contains(name, "UCS2") || strings.Contains(name, "UTF16"):
+		cm.unicode = true
+		cm.codespaces = []codeRange{{lo: []byte{0x00, 0x00}, hi: []byte{0xFF, 0xFF}}}
+
+	default:
+		// Without the CMap resource we assume 2 byte codes.
+		cm.codespaces = []codeRange{{lo: []byte{0x00, 0x00}, hi: []byte{0xFF, 0xFF}}}
+	}
+
+	return cm
+}
+
+// nextCode returns the length of the leading character code of bb according to the codespace ranges of cm.
+func (cm *cmap) nextCode(bb []byte) int {
+	if cm == nil || len(cm.codespaces) == 0 {
+		return 1
+	}
+
+	min := 4
+	for n := 1; n <= 4 && n <= len(bb); n++ {
+		for _, r := range cm.codespaces {
+			if len(r.lo) == n && r.contains(bb[:n]) {
+				return n
+			}
+		}
+	}
+
+	for _, r := range cm.codespaces {
+		if len(r.lo) < min {
+			min = len(r.lo)
+		}
+	}
+	if min > len(bb) {
+		min = len(bb)
+	}
+	return min
+}
+
+// cid returns the CID for code.
+func (cm *cmap) cid(code []byte) int {
+	if cm.identity || cm.unicode {
+		return codeValue(code)
+	}
+	if cid, ok := cm.cidChars[string(code)]; ok {
+		return cid
+	}
+	for _, r := range cm.cidRanges {
+		if r.contains(code) {
+			return r.cid + r.offset(code)
+		}
+	}
+	return codeValue(code)
+}
+
+// lookup returns the Unicode value for code.
+func (cm *cmap) lookup(code []byte) (string, bool) {
+	if cm.unicode {
+		return utf16BEToString(code), true
+	}
+	if s, ok := cm.bfChars[string(code)]; ok {
+		return s, true
+	}
+	for _, r := range cm.bfRanges {
+		if !r.contains(code) {
+			continue
+		}
+		i := r.offset(code)
+		if r.dsts != nil {
+			if i < len(r.dsts) {
+				return r.dsts[i], true
+			}
+			return "", false
+		}
+		rr := append([]rune{}, r.dst...)
+		if len(rr) > 0 {
+			rr[len(rr)-1] += rune(i)
+		}
+		return string(rr), true
+	}
+	return "", false
+}
+
+func hexOperand(o types.Object) ([]byte, bool) {
+	switch o := o.(type) {
+	case types.HexLiteral:
+		bb, err := o.Bytes()
+		return bb, err == nil
+	case types.StringLiteral:
+		bb, err := types.Unescape(o.Value())
+		return bb, err == nil
+	}
+	return nil, false
+}
+
+func intOperand(o types.Object) (int, bool) {
+	switch o := o.(type) {
+	case types.Integer:
+		return o.Value(), true
+	case types.Float:
+		return int(o.Value()), true
+	}
+	return 0, false
+}
+
+func (cm *cmap) parseCodespaceRanges(oo []types.Object) {
+	for i := 0; i+1 < len(oo); i += 2 {
+		lo, ok1 := hexOperand(oo[i])
+		hi, ok2 := hexOperand(oo[i+1])
+		if ok1 && ok2 && len(lo) == len(hi) && len(lo) > 0 {
+			cm.codespaces = append(cm.codespaces, codeRange{lo: lo, hi: hi})
+		}
+	}
+}
+
+func (cm *cmap) parseBFChars(oo []types.Object) {
+	for i := 0; i+1 < len(oo); i += 2 {
+		code, ok := hexOperand(oo[i])
+		if !ok {
+			continue
+		}
+		switch dst := oo[i+1].(type) {
+		case types.Name:
+			// Glyph names are allowed as destination.
+			cm.bfChars[string(code)] = glyphText(dst.Value())
+		default:
+			if bb, ok := hexOperand(dst); ok {
+				cm.bfChars[string(code)] = utf16BEToString(bb)
+			}
+		}
+	}
+}
+
+func (cm *cmap) parseBFRanges(oo []types.Object) {
+	for i := 0; i+2 < len(oo); i += 3 {
+		lo, ok1 := hexOperand(oo[i])
+		hi, ok2 := hexOperand(oo[i+1])
+		if !ok1 || !ok2 || len(lo) != len(hi) {
+			continue
+		}
+		r := bfRange{codeRange: codeRange{lo: lo, hi: hi}}
+		switch dst := oo[i+2].(type) {
+		case types.Array:
+			r.dsts = make([]string, len(dst))
+			for j, o := range dst {
+				if bb, ok := hexOperand(o); ok {
+					r.dsts[j] = utf16BEToString(bb)
+				}
+			}
+		default:
+			bb, ok := hexOperand(dst)
+			if !ok {
+				continue
+			}
+			r.dst = []rune(utf16BEToString(bb))
+		}
+		cm.bfRanges = append(cm.bfRanges, r)
+	}
+}
+
+func (cm *cmap) parseCIDChars(oo []types.Object) {
+	for i := 0; i+1 < len(oo); i += 2 {
+		code, ok1 := hexOperand(oo[i])
+		cid, ok2 := intOperand(oo[i+1])
+		if ok1 && ok2 {
+			cm.cidChars[string(code)] = cid
+		}
+	}
+}
+
+func (cm *cmap) parseCIDRanges(oo []types.Object) {
+	for i := 0; i+2 < len(oo); i += 3 {
+		lo, ok1 := hexOperand(oo[i])
+		hi, ok2 := hexOperand(oo[i+1])
+		cid, ok3 := intOperand(oo[i+2])
+		if ok1 && ok2 && ok3 && len(lo) == len(hi) {
+			cm.cidRanges = append(cm.cidRanges, cidRange{codeRange: codeRange{lo: lo, hi: hi}, cid: cid})
+		}
+	}
+}
+
+// parseCMap parses an embedded CMap or ToUnicode CMap stream.
+func parseCMap(bb []byte) *cmap {
+	cm := &cmap{cidChars: map[string]int{}, bfChars: map[string]string{}}
+
+	p := content.NewParser(bb)
+
+	for {
+		o, err := p.Next()
+		if err != nil || o.Operator == "" {
+			break
+		}
+
+		op, oo := o.Operator, o.Operands
+
+		// Mappings are the operands of the corresponding end operator.
+		switch op {
+
+		case "endcodespacerange":
+			cm.parseCodespaceRanges(oo)
+
+		case "endbfchar":
+			cm.parseBFChars(oo)
+
+		case "endbfrange":
+			cm.parseBFRanges(oo)
+
+		case "endcidchar":
+			cm.parseCIDChars(oo)
+
+		case "endcidrange":
+			cm.parseCIDRanges(oo)
+
+		case "usecmap":
+			if len(oo) > 0 {
+				if n, ok := oo[len(oo)-1].(types.Name); ok {
+					base := predefinedCMap(n.Value())
+					cm.identity = base.identity && len(cm.cidChars) == 0 && len(cm.cidRanges) == 0
+					cm.unicode = base.unicode
+					if len(cm.codespaces) == 0 {
+						cm.codespaces = base.codespaces
+					}
+				}
+			}
+
+		case "def":
+			if len(oo) == 2 {
+				k, _ := oo[0].(types.Name)
+				switch k {
+				case "CMapName":
+					if n, ok := oo[1].(types.Name); ok {
+						cm.name = n.Value()
+					}
+				case "WMode":
+					if i, ok := intOperand(oo[1]); ok {
+						cm.vertical = i == 1
+					}
+				}
+			}
+		}
+	}
+
+	return cm
+}
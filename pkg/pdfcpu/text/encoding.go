@@ -0,0 +1,235 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package text
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/internal/corefont/metrics"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// glyphRunes maps glyph names not covered by WinAnsiEncoding to Unicode (see Adobe Glyph List).
+var glyphRunes = map[string]rune{
+	// Latin Extended-A
+	"Amacron": 0x0100, "amacron": 0x0101, "Abreve": 0x0102, "abreve": 0x0103, "Aogonek": 0x0104, "aogonek": 0x0105,
+	"Cacute": 0x0106, "cacute": 0x0107, "Ccaron": 0x010C, "ccaron": 0x010D, "Dcaron": 0x010E, "dcaron": 0x010F,
+	"Dcroat": 0x0110, "dcroat": 0x0111, "Emacron": 0x0112, "emacron": 0x0113, "Edotaccent": 0x0116, "edotaccent": 0x0117,
+	"Eogonek": 0x0118, "eogonek": 0x0119, "Ecaron": 0x011A, "ecaron": 0x011B, "Gbreve": 0x011E, "gbreve": 0x011F,
+	"Gcommaaccent": 0x0122, "gcommaaccent": 0x0123, "Imacron": 0x012A, "imacron": 0x012B, "Iogonek": 0x012E, "iogonek": 0x012F,
+	"Idotaccent": 0x0130, "dotlessi": 0x0131, "Kcommaaccent": 0x0136, "kcommaaccent": 0x0137, "Lacute": 0x0139, "lacute": 0x013A,
+	"Lcommaaccent": 0x013B, "lcommaaccent": 0x013C, "Lcaron": 0x013D, "lcaron": 0x013E, "Lslash": 0x0141, "lslash": 0x0142,
+	"Nacute": 0x0143, "nacute": 0x0144, "Ncommaaccent": 0x0145, "ncommaaccent": 0x0146, "Ncaron": 0x0147, "ncaron": 0x0148,
+	"Omacron": 0x014C, "omacron": 0x014D, "Ohungarumlaut": 0x0150, "ohungarumlaut": 0x0151, "Racute": 0x0154, "racute": 0x0155,
+	"Rcommaaccent": 0x0156, "rcommaaccent": 0x0157, "Rcaron": 0x0158, "rcaron": 0x0159, "Sacute": 0x015A, "sacute": 0x015B,
+	"Scedilla": 0x015E, "scedilla": 0x015F, "Tcommaaccent": 0x0162, "tcommaaccent": 0x0163, "Tcaron": 0x0164, "tcaron": 0x0165,
+	"Umacron": 0x016A, "umacron": 0x016B, "Uring": 0x016E, "uring": 0x016F, "Uhungarumlaut": 0x0170, "uhungarumlaut": 0x0171,
+	"Uogonek": 0x0172, "uogonek": 0x0173, "Zacute": 0x0179, "zacute": 0x017A, "Zdotaccent": 0x017B, "zdotaccent": 0x017C,
+	"Scommaaccent": 0x0218, "scommaaccent": 0x0219, "dotlessj": 0x0237, "commaaccent": 0x0326,
+
+	// Accents
+	"caron": 0x02C7, "breve": 0x02D8, "dotaccent": 0x02D9, "ring": 0x02DA, "ogonek": 0x02DB, "hungarumlaut": 0x02DD,
+
+	// Punctuation and ligatures
+	"nbspace": 0x00A0, "sfthyphen": 0x00AD, "figuredash": 0x2012, "minute": 0x2032, "second": 0x2033, "fraction": 0x2044,
+	"ff": 0xFB00, "fi": 0xFB01, "fl": 0xFB02, "ffi": 0xFB03, "ffl": 0xFB04,
+
+	// Greek
+	"Alpha": 0x0391, "Beta": 0x0392, "Gamma": 0x0393, "Delta": 0x0394, "Epsilon": 0x0395, "Zeta": 0x0396, "Eta": 0x0397,
+	"Theta": 0x0398, "Iota": 0x0399, "Kappa": 0x039A, "Lambda": 0x039B, "Mu": 0x039C, "Nu": 0x039D, "Xi": 0x039E,
+	"Omicron": 0x039F, "Pi": 0x03A0, "Rho": 0x03A1, "Sigma": 0x03A3, "Tau": 0x03A4, "Upsilon": 0x03A5, "Phi": 0x03A6,
+	"Chi": 0x03A7, "Psi": 0x03A8, "Omega": 0x03A9,
+	"alpha": 0x03B1, "beta": 0x03B2, "gamma": 0x03B3, "delta": 0x03B4, "epsilon": 0x03B5, "zeta": 0x03B6, "eta": 0x03B7,
+	"theta": 0x03B8, "iota": 0x03B9, "kappa": 0x03BA, "lambda": 0x03BB, "nu": 0x03BD, "xi": 0x03BE,
+	"omicron": 0x03BF, "pi": 0x03C0, "rho": 0x03C1, "sigma1": 0x03C2, "sigma": 0x03C3, "tau": 0x03C4, "upsilon": 0x03C5,
+	"phi": 0x03C6, "chi": 0x03C7, "psi": 0x03C8, "omega": 0x03C9, "theta1": 0x03D1, "Upsilon1": 0x03D2, "phi1": 0x03D5,
+	"omega1": 0x03D6,
+
+	// Symbols
+	"Ifraktur": 0x2111, "weierstrass": 0x2118, "Rfraktur": 0x211C, "aleph": 0x2135,
+	"arrowleft": 0x2190, "arrowup": 0x2191, "arrowright": 0x2192, "arrowdown": 0x2193, "arrowboth": 0x2194, "carriagereturn": 0x21B5,
+	"arrowdblleft": 0x21D0, "arrowdblup": 0x21D1, "arrowdblright": 0x21D2, "arrowdbldown": 0x21D3, "arrowdblboth": 0x21D4,
+	"universal": 0x2200, "partialdiff": 0x2202, "existential": 0x2203, "emptyset": 0x2205, "gradient": 0x2207,
+	"element": 0x2208, "notelement": 0x2209, "suchthat": 0x220B, "product": 0x220F, "summation": 0x2211, "minus": 0x2212,
+	"asteriskmath": 0x2217, "radical": 0x221A, "proportional": 0x221D, "infinity": 0x221E, "angle": 0x2220,
+	"logicaland": 0x2227, "logicalor": 0x2228, "intersection": 0x2229, "union": 0x222A, "integral": 0x222B,
+	"therefore": 0x2234, "similar": 0x223C, "congruent": 0x2245, "approxequal": 0x2248, "notequal": 0x2260,
+	"equivalence": 0x2261, "lessequal": 0x2264, "greaterequal": 0x2265, "propersubset": 0x2282, "propersuperset": 0x2283,
+	"notsubset": 0x2284, "reflexsubset": 0x2286, "reflexsuperset": 0x2287, "circleplus": 0x2295, "circlemultiply": 0x2297,
+	"perpendicular": 0x22A5, "dotmath": 0x22C5, "integraltp": 0x2320, "integralbt": 0x2321, "angleleft": 0x2329,
+	"angleright": 0x232A, "lozenge": 0x25CA, "spade": 0x2660, "club": 0x2663, "heart": 0x2665, "diamond": 0x2666,
+	"registerserif": 0x00AE, "copyrightserif": 0x00A9, "trademarkserif": 0x2122,
+	"registersans": 0x00AE, "copyrightsans": 0x00A9, "trademarksans": 0x2122,
+	"parenlefttp": 0x239B, "parenleftex": 0x239C, "parenleftbt": 0x239D, "parenrighttp": 0x239E, "parenrightex": 0x239F,
+	"parenrightbt": 0x23A0, "bracketlefttp": 0x23A1, "bracketleftex": 0x23A2, "bracketleftbt": 0x23A3,
+	"bracketrighttp": 0x23A4, "bracketrightex": 0x23A5, "bracketrightbt": 0x23A6, "bracelefttp": 0x23A7,
+	"braceleftmid": 0x23A8, "braceleftbt": 0x23A9, "braceex": 0x23AA, "bracerighttp": 0x23AB, "bracerightmid": 0x23AC,
+	"bracerightbt": 0x23AD, "integralex": 0x23AE, "arrowhorizex": 0x23AF, "arrowvertex": 0x23D0,
+}
+
+// standardEncoding holds the codes of StandardEncoding not matching ASCII.
+var standardEncoding = map[byte]string{
+	0x27: "quoteright", 0x60: "quoteleft",
+	0xA1: "exclamdown", 0xA2: "cent", 0xA3: "sterling", 0xA4: "fraction", 0xA5: "yen", 0xA6: "florin", 0xA7: "section",
+	0xA8: "currency", 0xA9: "quotesingle", 0xAA: "quotedblleft", 0xAB: "guillemotleft", 0xAC: "guilsinglleft",
+	0xAD: "guilsinglright", 0xAE: "fi", 0xAF: "fl", 0xB1: "endash", 0xB2: "dagger", 0xB3: "daggerdbl",
+	0xB4: "periodcentered", 0xB6: "paragraph", 0xB7: "bullet", 0xB8: "quotesinglbase", 0xB9: "quotedblbase",
+	0xBA: "quotedblright", 0xBB: "guillemotright", 0xBC: "ellipsis", 0xBD: "perthousand", 0xBF: "questiondown",
+	0xC1: "grave", 0xC2: "acute", 0xC3: "circumflex", 0xC4: "tilde", 0xC5: "macron", 0xC6: "breve", 0xC7: "dotaccent",
+	0xC8: "dieresis", 0xCA: "ring", 0xCB: "cedilla", 0xCD: "hungarumlaut", 0xCE: "ogonek", 0xCF: "caron",
+	0xD0: "emdash", 0xE1: "AE", 0xE3: "ordfeminine", 0xE8: "Lslash", 0xE9: "Oslash", 0xEA: "OE", 0xEB: "ordmasculine",
+	0xF1: "ae", 0xF5: "dotlessi", 0xF8: "lslash", 0xF9: "oslash", 0xFA: "oe", 0xFB: "germandbls",
+}
+
+var (
+	glyphNamesOnce sync.Once
+	glyphNames     map[rune]string
+)
+
+func init() {
+	// All glyph names of WinAnsiEncoding.
+	for c := 0x20; c < 256; c++ {
+		name, ok := metrics.WinAnsiGlyphMap[c]
+		if !ok {
+			continue
+		}
+		if _, ok := glyphRunes[name]; ok {
+			continue
+		}
+		r := charmap.Windows1252.DecodeByte(byte(c))
+		if r != 0xFFFD {
+			glyphRunes[name] = r
+		}
+	}
+}
+
+// glyphName returns the glyph name for r.
+func glyphName(r rune) string {
+	glyphNamesOnce.Do(func() {
+		glyphNames = map[rune]string{}
+		for name, r := range glyphRunes {
+			if n, ok := glyphNames[r]; !ok || name < n {
+				glyphNames[r] = name
+			}
+		}
+		for c := 0x20; c < 256; c++ {
+			if name, ok := metrics.WinAnsiGlyphMap[c]; ok && glyphRunes[name] == charmap.Windows1252.DecodeByte(byte(c)) {
+				glyphNames[glyphRunes[name]] = name
+			}
+		}
+	})
+	return glyphNames[r]
+}
+
+func hexRunes(s string, n int) string {
+	if len(s) == 0 || len(s)%n != 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i += n {
+		u, err := strconv.ParseUint(s[i:i+n], 16, 32)
+		if err != nil || u >= 0xD800 && u <= 0xDFFF || u > 0x10FFFF {
+			return ""
+		}
+		sb.WriteRune(rune(u))
+	}
+	return sb.String()
+}
+
+// glyphText returns the Unicode text for a glyph name (see Adobe Glyph List Specification).
+func glyphText(name string) string {
+	if r, ok := glyphRunes[name]; ok {
+		return string(r)
+	}
+
+	// Drop suffixes like in "a.sc" or "one.oldstyle".
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		if i == 0 {
+			return ""
+		}
+		return glyphText(name[:i])
+	}
+
+	// Ligatures like "f_f_i".
+	if strings.IndexByte(name, '_') > 0 {
+		var sb strings.Builder
+		for _, s := range strings.Split(name, "_") {
+			sb.WriteString(glyphText(s))
+		}
+		return sb.String()
+	}
+
+	if strings.HasPrefix(name, "uni") {
+		return hexRunes(name[3:], 4)
+	}
+
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		return hexRunes(name[1:], len(name)-1)
+	}
+
+	return ""
+}
+
+// baseEncoding returns the glyph names and Unicode values for the codes of a simple font encoding.
+func baseEncoding(name string) (names [256]string, text [256]string) {
+	switch name {
+
+	case "WinAnsiEncoding":
+		for c := 0x20; c < 256; c++ {
+			names[c] = metrics.WinAnsiGlyphMap[c]
+			if r := charmap.Windows1252.DecodeByte(byte(c)); r != 0xFFFD {
+				text[c] = string(r)
+			}
+		}
+		return names, text
+
+	case "MacRomanEncoding":
+		for c := 0x20; c < 256; c++ {
+			r := charmap.Macintosh.DecodeByte(byte(c))
+			names[c] = glyphName(r)
+			text[c] = string(r)
+		}
+		return names, text
+
+	case "Symbol":
+		for c, n := range metrics.SymbolGlyphMap {
+			names[c] = n
+			text[c] = glyphText(n)
+		}
+		return names, text
+
+	case "ZapfDingbats":
+		for c, n := range metrics.ZapfDingbatsGlyphMap {
+			names[c] = n
+			text[c] = glyphText(n)
+		}
+		return names, text
+	}
+
+	// StandardEncoding
+	for c := 0x20; c < 0x7F; c++ {
+		names[c] = glyphName(rune(c))
+		text[c] = string(rune(c))
+	}
+	for c, n := range standardEncoding {
+		names[c] = n
+		text[c] = glyphText(n)
+	}
+	return names, text
+}
@@ -0,0 +1,538 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package text
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/internal/corefont/metrics"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// font holds everything needed to decode strings shown using a PDF font.
+type font struct {
+	name      string
+	composite bool
+	cmap      *cmap // composite fonts: code -> CID
+	toUnicode *cmap
+	names     [256]string     // simple fonts: code -> glyph name
+	text      [256]string     // simple fonts: code -> Unicode
+	widths    map[int]float64 // code (simple) or CID (composite) -> width in glyph space units
+	defWidth  float64
+	ascent    float64
+	descent   float64
+	vertical  bool
+	core      string // name of the corresponding core font for simple fonts without widths
+}
+
+// char is a decoded character code.
+type char struct {
+	code  []byte
+	cid   int    // composite fonts only
+	name  string // glyph name, simple fonts only
+	text  string
+	width float64 // horizontal displacement in thousandths of text space units
+	space bool    // single byte code 32, subject to word spacing
+}
+
+// fontName returns the PostScript name of a font without subset tag.
+func fontName(s string) string {
+	if i := strings.IndexByte(s, '+'); i == 6 {
+		return s[7:]
+	}
+	return s
+}
+
+func dictNumber(xRefTable *model.XRefTable, d types.Dict, key string) (float64, bool) {
+	o, found := d.Find(key)
+	if !found {
+		return 0, false
+	}
+	f, err := xRefTable.DereferenceNumber(o)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+func dictName(xRefTable *model.XRefTable, d types.Dict, key string) string {
+	o, found := d.Find(key)
+	if !found {
+		return ""
+	}
+	o, err := xRefTable.Dereference(o)
+	if err != nil {
+		return ""
+	}
+	if n, ok := o.(types.Name); ok {
+		return n.Value()
+	}
+	return ""
+}
+
+func dictArray(xRefTable *model.XRefTable, d types.Dict, key string) types.Array {
+	o, found := d.Find(key)
+	if !found {
+		return nil
+	}
+	a, err := xRefTable.DereferenceArray(o)
+	if err != nil {
+		return nil
+	}
+	return a
+}
+
+func dictDict(xRefTable *model.XRefTable, d types.Dict, key string) types.Dict {
+	o, found := d.Find(key)
+	if !found {
+		return nil
+	}
+	d1, err := xRefTable.DereferenceDict(o)
+	if err != nil {
+		return nil
+	}
+	return d1
+}
+
+func numbers(xRefTable *model.XRefTable, a types.Array) []float64 {
+	ff := make([]float64, 0, len(a))
+	for _, o := range a {
+		f, err := xRefTable.DereferenceNumber(o)
+		if err != nil {
+			f = 0
+		}
+		ff = append(ff, f)
+	}
+	return ff
+}
+
+// streamCMap parses the CMap stream o.
+func streamCMap(xRefTable *model.XRefTable, o types.Object) *cmap {
+	sd, _, err := xRefTable.DereferenceStreamDict(o)
+	if err != nil || sd == nil {
+		return nil
+	}
+	if err := sd.Decode(); err != nil {
+		return nil
+	}
+	cm := parseCMap(sd.Content)
+	if n := dictName(xRefTable, sd.Dict, "UseCMap"); n != "" && len(cm.cidChars) == 0 && len(cm.cidRanges) == 0 {
+		base := predefinedCMap(n)
+		cm.identity, cm.unicode = base.identity, base.unicode
+		if len(cm.codespaces) == 0 {
+			cm.codespaces = base.codespaces
+		}
+	}
+	return cm
+}
+
+func (f *font) loadFontDescriptor(xRefTable *model.XRefTable, d types.Dict) {
+	f.ascent, f.descent = 800, -200
+	fd := dictDict(xRefTable, d, "FontDescriptor")
+	if fd == nil {
+		return
+	}
+	if a, ok := dictNumber(xRefTable, fd, "Ascent"); ok && a > 0 {
+		f.ascent = a
+	}
+	if dsc, ok := dictNumber(xRefTable, fd, "Descent"); ok && dsc < 0 {
+		f.descent = dsc
+	}
+	if w, ok := dictNumber(xRefTable, fd, "MissingWidth"); ok {
+		f.defWidth = w
+	}
+}
+
+func coreFont(name string) string {
+	if _, ok := metrics.CoreFontMetrics[name]; ok {
+		return name
+	}
+	return ""
+}
+
+func (f *font) loadEncoding(xRefTable *model.XRefTable, d types.Dict, subtype string) {
+	base := "StandardEncoding"
+	switch {
+	case f.core == "Symbol" || f.core == "ZapfDingbats":
+		base = f.core
+	case subtype == "TrueType":
+		base = "WinAnsiEncoding"
+	}
+
+	var diffs types.Array
+
+	o, _ := d.Find("Encoding")
+	if o, _ = xRefTable.Dereference(o); o != nil {
+		switch o := o.(type) {
+		case types.Name:
+			base = o.Value()
+		case types.Dict:
+			if n := dictName(xRefTable, o, "BaseEncoding"); n != "" {
+				base = n
+			}
+			diffs = dictArray(xRefTable, o, "Differences")
+		}
+	}
+
+	f.names, f.text = baseEncoding(base)
+
+	code := 0
+	for _, o := range diffs {
+		o, _ = xRefTable.Dereference(o)
+		switch o := o.(type) {
+		case types.Integer:
+			code = o.Value()
+		case types.Float:
+			code = int(o.Value())
+		case types.Name:
+			if code >= 0 && code < 256 {
+				f.names[code] = o.Value()
+				f.text[code] = glyphText(o.Value())
+			}
+			code++
+		}
+	}
+}
+
+func (f *font) loadSimpleWidths(xRefTable *model.XRefTable, d types.Dict, subtype string) {
+	scale := 1.
+	if subtype == "Type3" {
+		// Type 3 glyph widths are expressed in glyph space as defined by the font matrix.
+		if fm := numbers(xRefTable, dictArray(xRefTable, d, "FontMatrix")); len(fm) == 6 {
+			scale = 1000 * fm[0]
+		}
+	}
+
+	first := 0
+	if fc, ok := dictNumber(xRefTable, d, "FirstChar"); ok {
+		first = int(fc)
+	}
+
+	ww := numbers(xRefTable, dictArray(xRefTable, d, "Widths"))
+	for i, w := range ww {
+		f.widths[first+i] = w * scale
+	}
+
+	if len(ww) == 0 && f.core == "" {
+		// Neither widths nor metrics available.
+		f.defWidth = 500
+	}
+}
+
+func loadSimpleFont(xRefTable *model.XRefTable, d types.Dict, subtype string) *font {
+	f := &font{widths: map[int]float64{}}
+	f.name = fontName(dictName(xRefTable, d, "BaseFont"))
+	if subtype == "Type3" {
+		f.name = "Type3"
+	}
+	f.core = coreFont(f.name)
+
+	f.loadFontDescriptor(xRefTable, d)
+	f.loadEncoding(xRefTable, d, subtype)
+	f.loadSimpleWidths(xRefTable, d, subtype)
+
+	return f
+}
+
+// loadCIDWidths processes the W array of a CIDFont.
+func (f *font) loadCIDWidths(xRefTable *model.XRefTable, a types.Array) {
+	for i := 0; i < len(a); {
+		first, err := xRefTable.DereferenceNumber(a[i])
+		if err != nil || i+1 >= len(a) {
+			return
+		}
+		o, _ := xRefTable.Dereference(a[i+1])
+		if ww, ok := o.(types.Array); ok {
+			for j, w := range numbers(xRefTable, ww) {
+				f.widths[int(first)+j] = w
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(a) {
+			return
+		}
+		last, err1 := xRefTable.DereferenceNumber(a[i+1])
+		w, err2 := xRefTable.DereferenceNumber(a[i+2])
+		if err1 != nil || err2 != nil || last-first > 0xFFFF {
+			return
+		}
+		for cid := int(first); cid <= int(last); cid++ {
+			f.widths[cid] = w
+		}
+		i += 3
+	}
+}
+
+func loadCompositeFont(xRefTable *model.XRefTable, d types.Dict) *font {
+	f := &font{composite: true, widths: map[int]float64{}, defWidth: 1000}
+	f.name = fontName(dictName(xRefTable, d, "BaseFont"))
+
+	o, _ := d.Find("Encoding")
+	if o, _ = xRefTable.Dereference(o); o != nil {
+		switch o1 := o.(type) {
+		case types.Name:
+			f.cmap = predefinedCMap(o1.Value())
+		case types.StreamDict:
+			f.cmap = streamCMap(xRefTable, o)
+		}
+	}
+	if f.cmap == nil {
+		f.cmap = predefinedCMap("Identity-H")
+	}
+	f.vertical = f.cmap.vertical
+
+	var cidFont types.Dict
+	if a := dictArray(xRefTable, d, "DescendantFonts"); len(a) > 0 {
+		cidFont, _ = xRefTable.DereferenceDict(a[0])
+	}
+	if cidFont == nil {
+		f.ascent, f.descent = 800, -200
+		return f
+	}
+
+	f.loadFontDescriptor(xRefTable, cidFont)
+	f.defWidth = 1000
+	if dw, ok := dictNumber(xRefTable, cidFont, "DW"); ok {
+		f.defWidth = dw
+	}
+	f.loadCIDWidths(xRefTable, dictArray(xRefTable, cidFont, "W"))
+
+	return f
+}
+
+// loadFont processes the font dict o.
+func loadFont(xRefTable *model.XRefTable, o types.Object) (*font, error) {
+	d, err := xRefTable.DereferenceDict(o)
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	var f *font
+
+	subtype := dictName(xRefTable, d, "Subtype")
+	if subtype == "Type0" {
+		f = loadCompositeFont(xRefTable, d)
+	} else {
+		f = loadSimpleFont(xRefTable, d, subtype)
+	}
+
+	if o, found := d.Find("ToUnicode"); found {
+		f.toUnicode = streamCMap(xRefTable, o)
+	}
+
+	return f, nil
+}
+
+func (f *font) unicode(code []byte, fallback string) string {
+	if f.toUnicode != nil {
+		if s, ok := f.toUnicode.lookup(code); ok && s != "" && s != "\x00" {
+			return s
+		}
+	}
+	return fallback
+}
+
+func (f *font) simpleWidth(c byte) float64 {
+	if w, ok := f.widths[int(c)]; ok {
+		return w
+	}
+	if f.core != "" {
+		if w, ok := metrics.CoreFontMetrics[f.core].W[f.names[c]]; ok {
+			return float64(w)
+		}
+	}
+	return f.defWidth
+}
+
+// chars decodes a string shown using f into character codes.
+func (f *font) chars(bb []byte) []char {
+	var cc []char
+
+	if !f.composite {
+		for i, c := range bb {
+			cc = append(cc, char{
+				code:  bb[i : i+1],
+				name:  f.names[c],
+				text:  f.unicode(bb[i:i+1], f.text[c]),
+				width: f.simpleWidth(c),
+				space: c == 0x20,
+			})
+		}
+		return cc
+	}
+
+	for i := 0; i < len(bb); {
+		n := f.cmap.nextCode(bb[i:])
+		if n <= 0 {
+			break
+		}
+		code := bb[i : i+n]
+		cid := f.cmap.cid(code)
+
+		var fallback string
+		if f.cmap.unicode {
+			fallback = utf16BEToString(code)
+		}
+
+		w, ok := f.widths[cid]
+		if !ok {
+			w = f.defWidth
+		}
+
+		cc = append(cc, char{
+			code:  code,
+			cid:   cid,
+			text:  f.unicode(code, fallback),
+			width: w,
+			space: n == 1 && code[0] == 0x20,
+		})
+		i += n
+	}
+
+	return cc
+}
+
+// The font decoding below is exported for the page renderer (pkg/pdfcpu/render) and for text replacement
+// (pkg/pdfcpu/replace.go), which need glyph level access to shown strings beyond text extraction.
+
+// Code is a character code of a string shown using a font.
+type Code struct {
+	Bytes []byte
+	CID   int     // composite fonts only
+	Name  string  // glyph name according to the font dict encoding, simple fonts only
+	Text  string  // Unicode text, empty if unmappable
+	Width float64 // horizontal displacement in thousandths of text space units
+	Space bool    // single byte code 32, subject to word spacing
+}
+
+// Font decodes strings shown using a font dict.
+type Font struct {
+	f     *font
+	codes map[rune][]byte // Unicode -> code, built on demand
+}
+
+// LoadFont returns a decoder for strings shown using the font dict o.
+func LoadFont(xRefTable *model.XRefTable, o types.Object) (*Font, error) {
+	f, err := loadFont(xRefTable, o)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	return &Font{f: f}, nil
+}
+
+// Vertical returns true for composite fonts using vertical writing.
+func (f *Font) Vertical() bool {
+	return f.f.vertical
+}
+
+// Decode splits the string bb into character codes.
+func (f *Font) Decode(bb []byte) []Code {
+	cc := f.f.chars(bb)
+	codes := make([]Code, len(cc))
+	for i, c := range cc {
+		codes[i] = Code{Bytes: c.code, CID: c.cid, Name: c.name, Text: c.text, Width: c.width, Space: c.space}
+	}
+	return codes
+}
+
+// encodings returns all codes of f mapping to a single rune.
+func (f *font) encodings() map[rune][]byte {
+	m := map[rune][]byte{}
+
+	add := func(code []byte, s string) {
+		rr := []rune(s)
+		if len(rr) != 1 {
+			return
+		}
+		if _, ok := m[rr[0]]; !ok {
+			m[rr[0]] = append([]byte{}, code...)
+		}
+	}
+
+	if !f.composite {
+		for c := 0; c < 256; c++ {
+			code := []byte{byte(c)}
+			add(code, f.unicode(code, f.text[c]))
+		}
+		return m
+	}
+
+	if f.toUnicode == nil {
+		return m
+	}
+
+	codes := make([]string, 0, len(f.toUnicode.bfChars))
+	for code := range f.toUnicode.bfChars {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		add([]byte(code), f.toUnicode.bfChars[code])
+	}
+
+	for _, r := range f.toUnicode.bfRanges {
+		lo, hi := codeValue(r.lo), codeValue(r.hi)
+		if hi-lo > 0xFFFF {
+			hi = lo + 0xFFFF
+		}
+		code := make([]byte, len(r.lo))
+		for v := lo; v <= hi; v++ {
+			for i, w := len(code)-1, v; i >= 0; i, w = i-1, w>>8 {
+				code[i] = byte(w)
+			}
+			if !r.contains(code) {
+				continue
+			}
+			if s, ok := f.toUnicode.lookup(code); ok {
+				add(code, s)
+			}
+		}
+	}
+
+	return m
+}
+
+// Code returns a character code showing r using f.
+// Only glyphs mapped to Unicode by the encoding or the ToUnicode CMap of the font dict are taken into account.
+func (f *Font) Code(r rune) ([]byte, bool) {
+	if f.f.composite && f.f.toUnicode == nil && f.f.cmap.unicode {
+		if r > 0xFFFF {
+			return nil, false
+		}
+		return []byte{byte(r >> 8), byte(r)}, true
+	}
+	if f.codes == nil {
+		f.codes = f.f.encodings()
+	}
+	code, ok := f.codes[r]
+	return code, ok
+}
+
+// GlyphText returns the Unicode text for a glyph name (see Adobe Glyph List Specification).
+func GlyphText(name string) string {
+	return glyphText(name)
+}
+
+// EncodingNames returns the glyph names for the codes of a simple font encoding
+// like StandardEncoding or WinAnsiEncoding.
+func EncodingNames(name string) [256]string {
+	names, _ := baseEncoding(name)
+	return names
+}
@@ -0,0 +1,447 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package text
+
+import (
+	"math"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/content"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// Max. nesting level for form XObjects.
+const maxFormDepth = 16
+
+type graphicsState struct {
+	ctm       matrix.Matrix
+	font      *font
+	fontSize  float64
+	charSpace float64
+	wordSpace float64
+	hScale    float64
+	leading   float64
+	rise      float64
+}
+
+// interpreter processes content streams and collects the glyphs shown.
+type interpreter struct {
+	xRefTable *model.XRefTable
+	fonts     map[int]*font // cache for indirect font dicts
+	gs        graphicsState
+	stack     []graphicsState
+	tm, tlm   matrix.Matrix
+	forms     map[int]bool // form XObjects currently processed
+	glyphs    []Glyph
+
+	chars map[int][]Char // characters shown by operation, nil unless requested
+	op    int            // index of the current operation
+	elem  int            // index of the current TJ array element
+}
+
+func newInterpreter(xRefTable *model.XRefTable) *interpreter {
+	return &interpreter{
+		xRefTable: xRefTable,
+		fonts:     map[int]*font{},
+		forms:     map[int]bool{},
+		gs:        graphicsState{ctm: matrix.IdentMatrix, hScale: 1},
+		tm:        matrix.IdentMatrix,
+		tlm:       matrix.IdentMatrix,
+	}
+}
+
+func number(o types.Object) float64 {
+	switch o := o.(type) {
+	case types.Integer:
+		return float64(o.Value())
+	case types.Float:
+		return o.Value()
+	}
+	return 0
+}
+
+func numberOperands(oo []types.Object, n int) ([]float64, bool) {
+	if len(oo) < n {
+		return nil, false
+	}
+	ff := make([]float64, n)
+	for i, o := range oo[len(oo)-n:] {
+		ff[i] = number(o)
+	}
+	return ff, true
+}
+
+func newMatrix(f []float64) matrix.Matrix {
+	return matrix.Matrix{{f[0], f[1], 0}, {f[2], f[3], 0}, {f[4], f[5], 1}}
+}
+
+func translation(tx, ty float64) matrix.Matrix {
+	m := matrix.IdentMatrix
+	m[2][0], m[2][1] = tx, ty
+	return m
+}
+
+func stringBytes(o types.Object) []byte {
+	switch o := o.(type) {
+	case types.StringLiteral:
+		bb, err := types.Unescape(o.Value())
+		if err != nil {
+			return nil
+		}
+		return bb
+	case types.HexLiteral:
+		bb, err := o.Bytes()
+		if err != nil {
+			return nil
+		}
+		return bb
+	}
+	return nil
+}
+
+func (in *interpreter) resourceEntry(resources types.Dict, category, name string) types.Object {
+	if resources == nil {
+		return nil
+	}
+	d := dictDict(in.xRefTable, resources, category)
+	if d == nil {
+		return nil
+	}
+	o, _ := d.Find(name)
+	return o
+}
+
+func (in *interpreter) setFont(resources types.Dict, name string) {
+	in.gs.font = nil
+
+	o := in.resourceEntry(resources, "Font", name)
+	if o == nil {
+		return
+	}
+
+	indRef, ok := o.(types.IndirectRef)
+	if ok {
+		if f, ok := in.fonts[indRef.ObjectNumber.Value()]; ok {
+			in.gs.font = f
+			return
+		}
+	}
+
+	f, err := loadFont(in.xRefTable, o)
+	if err != nil {
+		return
+	}
+	if ok {
+		in.fonts[indRef.ObjectNumber.Value()] = f
+	}
+	in.gs.font = f
+}
+
+func (in *interpreter) nextLine(tx, ty float64) {
+	in.tlm = translation(tx, ty).Multiply(in.tlm)
+	in.tm = in.tlm
+}
+
+func transformRect(m matrix.Matrix, x0, y0, x1, y1 float64) types.Rectangle {
+	pp := []types.Point{
+		m.Transform(types.Point{X: x0, Y: y0}),
+		m.Transform(types.Point{X: x1, Y: y0}),
+		m.Transform(types.Point{X: x1, Y: y1}),
+		m.Transform(types.Point{X: x0, Y: y1}),
+	}
+	r := types.Rectangle{LL: pp[0], UR: pp[0]}
+	for _, p := range pp[1:] {
+		r.LL.X, r.LL.Y = math.Min(r.LL.X, p.X), math.Min(r.LL.Y, p.Y)
+		r.UR.X, r.UR.Y = math.Max(r.UR.X, p.X), math.Max(r.UR.Y, p.Y)
+	}
+	return r
+}
+
+// showText processes the string operand of a text showing operator.
+func (in *interpreter) showText(bb []byte) {
+	gs := in.gs
+	f := gs.font
+	if f == nil {
+		return
+	}
+
+	for _, c := range f.chars(bb) {
+		m := in.tm.Multiply(gs.ctm)
+		trm := matrix.Matrix{{gs.fontSize * gs.hScale, 0, 0}, {0, gs.fontSize, 0}, {0, gs.rise, 1}}.Multiply(m)
+
+		w0 := c.width / 1000
+
+		var tx, ty float64
+		var bbox types.Rectangle
+		var dir types.Point
+
+		if f.vertical {
+			// Vertical writing: the origin is at the top center of the glyph.
+			ty = -gs.fontSize + gs.charSpace
+			if c.space {
+				ty += gs.wordSpace
+			}
+			bbox = transformRect(trm, -w0/2, -1, w0/2, 0)
+			dir = types.Point{X: -m[1][0], Y: -m[1][1]}
+		} else {
+			tx = (w0*gs.fontSize + gs.charSpace) * gs.hScale
+			if c.space {
+				tx += gs.wordSpace * gs.hScale
+			}
+			bbox = transformRect(trm, 0, f.descent/1000, w0, f.ascent/1000)
+			dir = types.Point{X: m[0][0], Y: m[0][1]}
+		}
+
+		origin := trm.Transform(types.Point{})
+
+		if in.chars != nil {
+			// The TJ adjustment resulting in the same displacement.
+			var adj float64
+			switch {
+			case gs.fontSize == 0:
+			case f.vertical:
+				adj = -ty * 1000 / gs.fontSize
+			case gs.hScale != 0:
+				adj = -tx / gs.hScale * 1000 / gs.fontSize
+			}
+			in.chars[in.op] = append(in.chars[in.op], Char{Code: c.code, Text: c.text, BBox: bbox, Adjust: adj, Elem: in.elem})
+		}
+
+		in.glyphs = append(in.glyphs, Glyph{
+			Text:   c.text,
+			BBox:   bbox,
+			font:   f.name,
+			size:   gs.fontSize * math.Hypot(m[1][0], m[1][1]),
+			origin: origin,
+			dir:    dir,
+		})
+
+		in.tm = translation(tx, ty).Multiply(in.tm)
+	}
+}
+
+func (in *interpreter) showTextArray(a types.Array) {
+	for i, o := range a {
+		in.elem = i
+		switch o.(type) {
+		case types.Integer, types.Float:
+			adj := number(o) / 1000 * in.gs.fontSize
+			if in.gs.font != nil && in.gs.font.vertical {
+				in.tm = translation(0, -adj).Multiply(in.tm)
+				continue
+			}
+			in.tm = translation(-adj*in.gs.hScale, 0).Multiply(in.tm)
+		default:
+			in.showText(stringBytes(o))
+		}
+	}
+}
+
+func (in *interpreter) doXObject(resources types.Dict, name string, depth int) error {
+	o := in.resourceEntry(resources, "XObject", name)
+	indRef, ok := o.(types.IndirectRef)
+	if !ok {
+		return nil
+	}
+
+	objNr := indRef.ObjectNumber.Value()
+	if in.forms[objNr] || depth >= maxFormDepth {
+		return nil
+	}
+
+	sd, _, err := in.xRefTable.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return err
+	}
+	if dictName(in.xRefTable, sd.Dict, "Subtype") != "Form" {
+		return nil
+	}
+	if err := sd.Decode(); err != nil {
+		return nil
+	}
+
+	formResources := dictDict(in.xRefTable, sd.Dict, "Resources")
+	if formResources == nil {
+		formResources = resources
+	}
+
+	saved, n := in.gs, len(in.stack)
+	if m := numbers(in.xRefTable, dictArray(in.xRefTable, sd.Dict, "Matrix")); len(m) == 6 {
+		in.gs.ctm = newMatrix(m).Multiply(in.gs.ctm)
+	}
+
+	in.forms[objNr] = true
+	err = in.process(sd.Content, formResources, depth+1)
+	delete(in.forms, objNr)
+
+	in.gs = saved
+	if len(in.stack) > n {
+		in.stack = in.stack[:n]
+	}
+
+	return err
+}
+
+func (in *interpreter) processTextStateOp(op string, oo []types.Object, resources types.Dict) {
+	switch op {
+
+	case "Tf":
+		if len(oo) >= 2 {
+			if n, ok := oo[len(oo)-2].(types.Name); ok {
+				in.setFont(resources, n.Value())
+			}
+			in.gs.fontSize = number(oo[len(oo)-1])
+		}
+
+	case "Tc":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.charSpace = f[0]
+		}
+
+	case "Tw":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.wordSpace = f[0]
+		}
+
+	case "Tz":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.hScale = f[0] / 100
+		}
+
+	case "TL":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.leading = f[0]
+		}
+
+	case "Ts":
+		if f, ok := numberOperands(oo, 1); ok {
+			in.gs.rise = f[0]
+		}
+	}
+}
+
+func (in *interpreter) processTextOp(op string, oo []types.Object) {
+	switch op {
+
+	case "BT":
+		in.tm, in.tlm = matrix.IdentMatrix, matrix.IdentMatrix
+
+	case "Td":
+		if f, ok := numberOperands(oo, 2); ok {
+			in.nextLine(f[0], f[1])
+		}
+
+	case "TD":
+		if f, ok := numberOperands(oo, 2); ok {
+			in.gs.leading = -f[1]
+			in.nextLine(f[0], f[1])
+		}
+
+	case "Tm":
+		if f, ok := numberOperands(oo, 6); ok {
+			in.tlm = newMatrix(f)
+			in.tm = in.tlm
+		}
+
+	case "T*":
+		in.nextLine(0, -in.gs.leading)
+
+	case "Tj":
+		if len(oo) > 0 {
+			in.showText(stringBytes(oo[len(oo)-1]))
+		}
+
+	case "'":
+		in.nextLine(0, -in.gs.leading)
+		if len(oo) > 0 {
+			in.showText(stringBytes(oo[len(oo)-1]))
+		}
+
+	case "\"":
+		if len(oo) >= 3 {
+			in.gs.wordSpace = number(oo[len(oo)-3])
+			in.gs.charSpace = number(oo[len(oo)-2])
+		}
+		in.nextLine(0, -in.gs.leading)
+		if len(oo) > 0 {
+			in.showText(stringBytes(oo[len(oo)-1]))
+		}
+
+	case "TJ":
+		if len(oo) > 0 {
+			if a, ok := oo[len(oo)-1].(types.Array); ok {
+				in.showTextArray(a)
+			}
+		}
+	}
+}
+
+// process interprets the content stream bb using resources.
+func (in *interpreter) process(bb []byte, resources types.Dict, depth int) error {
+	ops, err := content.Parse(bb)
+	if err != nil {
+		return errors.Wrap(err, "pdfcpu: text")
+	}
+	return in.processOps(ops, resources, depth)
+}
+
+func (in *interpreter) processOps(ops []content.Operation, resources types.Dict, depth int) error {
+	for i, o := range ops {
+		in.op, in.elem = i, 0
+		op, oo := o.Operator, o.Operands
+
+		switch op {
+
+		case "q":
+			in.stack = append(in.stack, in.gs)
+
+		case "Q":
+			if n := len(in.stack); n > 0 {
+				in.gs = in.stack[n-1]
+				in.stack = in.stack[:n-1]
+			}
+
+		case "cm":
+			if f, ok := numberOperands(oo, 6); ok {
+				in.gs.ctm = newMatrix(f).Multiply(in.gs.ctm)
+			}
+
+		case "Do":
+			if in.chars != nil {
+				// Form XObjects are left to the caller.
+				continue
+			}
+			if len(oo) > 0 {
+				if n, ok := oo[len(oo)-1].(types.Name); ok {
+					if err := in.doXObject(resources, n.Value(), depth); err != nil {
+						return err
+					}
+				}
+			}
+
+		case "Tf", "Tc", "Tw", "Tz", "TL", "Ts":
+			in.processTextStateOp(op, oo, resources)
+
+		case "BT", "Td", "TD", "Tm", "T*", "Tj", "'", "\"", "TJ":
+			in.processTextOp(op, oo)
+		}
+	}
+
+	return nil
+}
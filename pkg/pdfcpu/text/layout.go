@@ -0,0 +1,331 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package text
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Layout thresholds relative to the font size.
+const (
+	wordGap       = 0.15 // min. gap between words
+	baselineDelta = 0.2  // max. baseline deviation within a run
+	lineDelta     = 0.5  // max. baseline deviation within a line
+	maxRunGap     = 3.0  // max. gap within a run
+	blockGap      = 0.5  // min. vertical gap between blocks
+	columnGap     = 1.0  // min. gap between columns
+	columnWidth   = 3.0  // min. column width
+	maxDepth      = 32   // max. nesting level of blocks
+)
+
+// frame holds the position of a glyph or run relative to its writing direction.
+type frame struct {
+	quadrant int     // writing direction in multiples of 90 degrees
+	x0, x1   float64 // extent along the writing direction
+	y0, y1   float64 // extent across the writing direction
+	base     float64 // baseline, larger values are read first
+	size     float64
+}
+
+type run struct {
+	Run
+	frame
+	offsets []int // byte offsets of glyphs within Text
+}
+
+type line struct {
+	frame
+	runs []*run
+}
+
+func quadrant(dir types.Point) int {
+	a := math.Atan2(dir.Y, dir.X)
+	return (int(math.Round(a/(math.Pi/2))) + 4) % 4
+}
+
+// rotate maps p into the frame of quadrant q.
+func rotate(p types.Point, q int) types.Point {
+	switch q {
+	case 1:
+		return types.Point{X: p.Y, Y: -p.X}
+	case 2:
+		return types.Point{X: -p.X, Y: -p.Y}
+	case 3:
+		return types.Point{X: -p.Y, Y: p.X}
+	}
+	return p
+}
+
+func glyphFrame(g Glyph) frame {
+	q := quadrant(g.dir)
+	f := frame{quadrant: q, base: rotate(g.origin, q).Y, size: g.size}
+	r := g.BBox
+	for i, p := range []types.Point{r.LL, r.UR, {X: r.LL.X, Y: r.UR.Y}, {X: r.UR.X, Y: r.LL.Y}} {
+		p = rotate(p, q)
+		if i == 0 {
+			f.x0, f.x1, f.y0, f.y1 = p.X, p.X, p.Y, p.Y
+			continue
+		}
+		f.x0, f.x1 = math.Min(f.x0, p.X), math.Max(f.x1, p.X)
+		f.y0, f.y1 = math.Min(f.y0, p.Y), math.Max(f.y1, p.Y)
+	}
+	return f
+}
+
+func union(r1, r2 types.Rectangle) types.Rectangle {
+	return types.Rectangle{
+		LL: types.Point{X: math.Min(r1.LL.X, r2.LL.X), Y: math.Min(r1.LL.Y, r2.LL.Y)},
+		UR: types.Point{X: math.Max(r1.UR.X, r2.UR.X), Y: math.Max(r1.UR.Y, r2.UR.Y)},
+	}
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+func roundRect(r types.Rectangle) types.Rectangle {
+	return types.Rectangle{
+		LL: types.Point{X: round(r.LL.X), Y: round(r.LL.Y)},
+		UR: types.Point{X: round(r.UR.X), Y: round(r.UR.Y)},
+	}
+}
+
+func needsSpace(s1, s2 string, gap, size float64) bool {
+	return gap > wordGap*size && !strings.HasSuffix(s1, " ") && !strings.HasPrefix(s2, " ")
+}
+
+func (r *run) accepts(g Glyph, f frame) bool {
+	return f.quadrant == r.quadrant &&
+		g.font == r.Font &&
+		math.Abs(f.size-r.size) < 0.01 &&
+		math.Abs(f.base-r.base) <= baselineDelta*r.size &&
+		f.x0 >= r.x1-lineDelta*r.size &&
+		f.x0-r.x1 <= maxRunGap*r.size
+}
+
+func (r *run) add(g Glyph, f frame) {
+	if needsSpace(r.Text, g.Text, f.x0-r.x1, r.size) {
+		r.Text += " "
+	}
+	r.offsets = append(r.offsets, len(r.Text))
+	r.Text += g.Text
+	r.Glyphs = append(r.Glyphs, g)
+	r.BBox = union(r.BBox, g.BBox)
+	r.x1 = math.Max(r.x1, f.x1)
+	r.y0, r.y1 = math.Min(r.y0, f.y0), math.Max(r.y1, f.y1)
+}
+
+// runs combines consecutive glyphs into runs.
+func runs(gg []Glyph) []*run {
+	var rr []*run
+	var r *run
+
+	for _, g := range gg {
+		// Spaces are derived from glyph positions.
+		if strings.TrimSpace(g.Text) == "" || g.size <= 0 {
+			continue
+		}
+		f := glyphFrame(g)
+		if r != nil && r.accepts(g, f) {
+			r.add(g, f)
+			continue
+		}
+		r = &run{Run: Run{Text: g.Text, Font: g.font, FontSize: g.size, BBox: g.BBox, Glyphs: []Glyph{g}}, frame: f, offsets: []int{0}}
+		rr = append(rr, r)
+	}
+
+	return rr
+}
+
+// duplicate returns true if r is a copy of a run already part of l, eg. for simulated bold text.
+func (l *line) duplicate(r *run) bool {
+	for _, r1 := range l.runs {
+		if r1.Text == r.Text && math.Abs(r1.x0-r.x0) < wordGap*r.size {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *line) text() Line {
+	sort.SliceStable(l.runs, func(i, j int) bool { return l.runs[i].x0 < l.runs[j].x0 })
+
+	var sb strings.Builder
+	ln := Line{BBox: l.runs[0].BBox}
+	for i, r := range l.runs {
+		if i > 0 {
+			prev := l.runs[i-1]
+			if needsSpace(prev.Text, r.Text, r.x0-prev.x1, math.Min(prev.size, r.size)) {
+				sb.WriteString(" ")
+			}
+		}
+		for j, g := range r.Glyphs {
+			ln.glyphs = append(ln.glyphs, g)
+			ln.offsets = append(ln.offsets, sb.Len()+r.offsets[j])
+		}
+		sb.WriteString(r.Text)
+		ln.BBox = union(ln.BBox, r.BBox)
+		r.BBox = roundRect(r.BBox)
+		r.FontSize = round(r.FontSize)
+		ln.Runs = append(ln.Runs, r.Run)
+	}
+	ln.Text = strings.TrimRight(sb.String(), " ")
+	ln.BBox = roundRect(ln.BBox)
+	return ln
+}
+
+func medianSize(rr []*run) float64 {
+	ss := make([]float64, len(rr))
+	for i, r := range rr {
+		ss[i] = r.size
+	}
+	sort.Float64s(ss)
+	return ss[len(ss)/2]
+}
+
+// rows splits rr at vertical gaps of at least minGap.
+func rows(rr []*run, minGap float64) [][]*run {
+	sort.SliceStable(rr, func(i, j int) bool { return rr[i].y1 > rr[j].y1 })
+	var bb [][]*run
+	var y0 float64
+	for i, r := range rr {
+		if i == 0 || r.y1 < y0-minGap {
+			bb = append(bb, []*run{r})
+			y0 = r.y0
+			continue
+		}
+		bb[len(bb)-1] = append(bb[len(bb)-1], r)
+		y0 = math.Min(y0, r.y0)
+	}
+	return bb
+}
+
+// columns splits rr at horizontal gaps of at least minGap.
+func columns(rr []*run, minGap float64) [][]*run {
+	sort.SliceStable(rr, func(i, j int) bool { return rr[i].x0 < rr[j].x0 })
+	var bb [][]*run
+	var x1 float64
+	for i, r := range rr {
+		if i == 0 || r.x0 > x1+minGap {
+			bb = append(bb, []*run{r})
+			x1 = r.x1
+			continue
+		}
+		bb[len(bb)-1] = append(bb[len(bb)-1], r)
+		x1 = math.Max(x1, r.x1)
+	}
+	return bb
+}
+
+// isColumn returns true if rr qualifies as text column.
+func isColumn(rr []*run, size float64) bool {
+	x0, x1 := rr[0].x0, rr[0].x1
+	multiLine := false
+	for _, r := range rr[1:] {
+		x0, x1 = math.Min(x0, r.x0), math.Max(x1, r.x1)
+		if math.Abs(r.base-rr[0].base) > lineDelta*size {
+			multiLine = true
+		}
+	}
+	return multiLine && x1-x0 >= columnWidth*size
+}
+
+// blocks recursively partitions rr into blocks of text by cutting along whitespace (XY-cut)
+// and returns them in reading order.
+func blocks(rr []*run, depth int) [][]*run {
+	if len(rr) <= 1 || depth >= maxDepth {
+		return [][]*run{rr}
+	}
+
+	size := medianSize(rr)
+
+	if bb := rows(rr, blockGap*size); len(bb) > 1 {
+		var res [][]*run
+		for _, b := range bb {
+			res = append(res, blocks(b, depth+1)...)
+		}
+		return res
+	}
+
+	bb := columns(rr, columnGap*size)
+	if len(bb) == 1 {
+		return bb
+	}
+	for _, b := range bb {
+		if !isColumn(b, size) {
+			return [][]*run{rr}
+		}
+	}
+
+	var res [][]*run
+	for _, b := range bb {
+		res = append(res, blocks(b, depth+1)...)
+	}
+	return res
+}
+
+// lines groups the runs of a block into lines.
+func lines(rr []*run) []Line {
+	sort.SliceStable(rr, func(i, j int) bool { return rr[i].base > rr[j].base })
+
+	var ll []*line
+	var l *line
+
+	for _, r := range rr {
+		if l != nil && math.Abs(l.base-r.base) <= lineDelta*math.Max(l.size, r.size) {
+			if !l.duplicate(r) {
+				l.runs = append(l.runs, r)
+			}
+			continue
+		}
+		l = &line{frame: r.frame, runs: []*run{r}}
+		ll = append(ll, l)
+	}
+
+	var res []Line
+	for _, l := range ll {
+		if ln := l.text(); strings.TrimSpace(ln.Text) != "" {
+			res = append(res, ln)
+		}
+	}
+	return res
+}
+
+// layout groups glyphs into lines and returns them in reading order:
+// Text blocks top to bottom and columns left to right, each relative to the writing direction.
+func layout(gg []Glyph) []Line {
+	var rr [4][]*run
+	for _, r := range runs(gg) {
+		rr[r.quadrant] = append(rr[r.quadrant], r)
+	}
+
+	res := []Line{}
+	for q := range rr {
+		if len(rr[q]) == 0 {
+			continue
+		}
+		for _, b := range blocks(rr[q], 0) {
+			res = append(res, lines(b)...)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,112 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package text
+
+import (
+	"math"
+	"regexp"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Match represents an occurrence of a search pattern on a page.
+type Match struct {
+	Page  int               `json:"page"`
+	Text  string            `json:"text"`
+	Rects []types.Rectangle `json:"rects"` // one per line covered
+	Quads types.QuadPoints  `json:"-"`     // one per line covered, aligned with the writing direction
+}
+
+// quad returns the quadrilateral covering gg in writing direction.
+func quad(gg []Glyph) types.QuadLiteral {
+	var f frame
+	for i, g := range gg {
+		f1 := glyphFrame(g)
+		if i == 0 {
+			f = f1
+			continue
+		}
+		f.x0, f.x1 = math.Min(f.x0, f1.x0), math.Max(f.x1, f1.x1)
+		f.y0, f.y1 = math.Min(f.y0, f1.y0), math.Max(f.y1, f1.y1)
+	}
+
+	// Map back into user space.
+	q := (4 - f.quadrant) % 4
+	p := func(x, y float64) types.Point {
+		p := rotate(types.Point{X: x, Y: y}, q)
+		return types.Point{X: round(p.X), Y: round(p.Y)}
+	}
+
+	// Upper left, upper right, lower left, lower right as expected by viewers.
+	return types.QuadLiteral{P1: p(f.x0, f.y1), P2: p(f.x1, f.y1), P3: p(f.x0, f.y0), P4: p(f.x1, f.y0)}
+}
+
+// match returns the glyphs of l overlapping the byte range [from, to) of l.Text.
+func (l Line) match(from, to int) []Glyph {
+	var gg []Glyph
+	for i, g := range l.glyphs {
+		if off := l.offsets[i]; off < to && off+len(g.Text) > from {
+			gg = append(gg, g)
+		}
+	}
+	return gg
+}
+
+// Search returns all matches of re within the text of p.
+// Matches may span lines, lines are separated by a single newline.
+func (p Page) Search(re *regexp.Regexp) []Match {
+	s := p.Text()
+
+	// Byte offsets of lines within s.
+	starts := make([]int, len(p.Lines))
+	off := 0
+	for i, l := range p.Lines {
+		starts[i] = off
+		off += len(l.Text) + 1
+	}
+
+	var mm []Match
+
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		from, to := loc[0], loc[1]
+		if from == to {
+			continue
+		}
+
+		m := Match{Page: p.Number, Text: s[from:to], Rects: []types.Rectangle{}}
+
+		for i, l := range p.Lines {
+			start, end := starts[i], starts[i]+len(l.Text)
+			if to <= start || from >= end {
+				continue
+			}
+			gg := l.match(from-start, to-start)
+			if len(gg) == 0 {
+				continue
+			}
+			ql := quad(gg)
+			m.Quads = append(m.Quads, ql)
+			m.Rects = append(m.Rects, *ql.EnclosingRectangle(0))
+		}
+
+		if len(m.Quads) > 0 {
+			mm = append(mm, m)
+		}
+	}
+
+	return mm
+}
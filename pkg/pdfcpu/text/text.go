@@ -0,0 +1,126 @@
+/*
+	Copyright 2024 The pdfcpu Authors.
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+// Package text provides text extraction based on content stream interpretation.
+package text
+
+import (
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/content"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/matrix"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Glyph represents a single shown glyph.
+type Glyph struct {
+	Text string          // Unicode text, empty for unmappable glyphs
+	BBox types.Rectangle // in default user space
+
+	font   string
+	size   float64
+	origin types.Point
+	dir    types.Point // writing direction in user space
+}
+
+// Char represents a character code shown by a text showing operator.
+type Char struct {
+	Code   []byte
+	Text   string
+	BBox   types.Rectangle // in default user space
+	Adjust float64         // TJ adjustment equivalent to the displacement caused by showing the character
+	Elem   int             // index of the string within the operands of TJ
+}
+
+// Run is a sequence of glyphs on a line sharing font and size.
+type Run struct {
+	Text     string          `json:"text"`
+	Font     string          `json:"font"`
+	FontSize float64         `json:"size"`
+	BBox     types.Rectangle `json:"bbox"`
+	Glyphs   []Glyph         `json:"-"`
+}
+
+// Line is a sequence of runs sharing a baseline.
+type Line struct {
+	Text string          `json:"text"`
+	BBox types.Rectangle `json:"bbox"`
+	Runs []Run           `json:"runs"`
+
+	glyphs  []Glyph
+	offsets []int // byte offsets of glyphs within Text
+}
+
+// Page represents the text of a page in reading order.
+type Page struct {
+	Number   int             `json:"page"`
+	MediaBox types.Rectangle `json:"mediaBox"`
+	Lines    []Line          `json:"lines"`
+}
+
+// Text returns the plain text of p.
+func (p Page) Text() string {
+	ss := make([]string, len(p.Lines))
+	for i, l := range p.Lines {
+		ss[i] = l.Text
+	}
+	return strings.Join(ss, "\n")
+}
+
+// ExtractPage returns the text of page pageNr including positions and fonts.
+// All coordinates are expressed in default user space.
+func ExtractPage(xRefTable *model.XRefTable, pageNr int) (*Page, error) {
+	d, _, inhPAttrs, err := xRefTable.PageDict(pageNr, false)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Page{Number: pageNr, Lines: []Line{}}
+	if inhPAttrs.MediaBox != nil {
+		p.MediaBox = *inhPAttrs.MediaBox
+	}
+
+	bb, err := xRefTable.PageContent(d)
+	if err == model.ErrNoContent {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	in := newInterpreter(xRefTable)
+	if err := in.process(bb, inhPAttrs.Resources, 0); err != nil {
+		return nil, err
+	}
+
+	p.Lines = layout(in.glyphs)
+
+	return p, nil
+}
+
+// Chars interprets ops using resources and the initial transformation matrix ctm
+// and returns the characters shown by each text showing operator keyed by operation index.
+// Form XObjects are not processed.
+func Chars(xRefTable *model.XRefTable, ops []content.Operation, resources types.Dict, ctm matrix.Matrix) (map[int][]Char, error) {
+	in := newInterpreter(xRefTable)
+	in.gs.ctm = ctm
+	in.chars = map[int][]Char{}
+	if err := in.processOps(ops, resources, 0); err != nil {
+		return nil, err
+	}
+	return in.chars, nil
+}
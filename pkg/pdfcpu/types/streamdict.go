@@ -32,6 +32,7 @@ import (
 type PDFFilter struct {
 	Name        string
 	DecodeParms Dict
+	Streams     map[string][]byte // Decoded content of stream valued decode parameters, eg. JBIG2Globals
 }
 
 // StreamDict represents a PDF stream dict object.
@@ -75,6 +76,7 @@ func (sd StreamDict) Clone() Object {
 		if v.DecodeParms != nil {
 			f.DecodeParms = v.DecodeParms.Clone().(Dict)
 		}
+		f.Streams = v.Streams
 		pl[k] = f
 	}
 	sd1.FilterPipeline = pl
@@ -91,6 +93,44 @@ func (sd StreamDict) HasSoleFilterNamed(filterName string) bool {
 	return fpl[0].Name == filterName
 }
 
+// EncodeFlatePredicted encodes sd using a sole Flate filter and PNG prediction (Predictor 15)
+// for rows of columns samples with colors components of bpc bits each.
+// Prediction is dropped if it does not result in a smaller stream.
+func (sd *StreamDict) EncodeFlatePredicted(colors, bpc, columns int) error {
+	if err := sd.Encode(); err != nil || !sd.HasSoleFilterNamed(filter.Flate) {
+		return err
+	}
+
+	raw := sd.Raw
+
+	parms := Dict(
+		map[string]Object{
+			"Predictor":        Integer(filter.PredictorOptimum),
+			"Colors":           Integer(colors),
+			"BitsPerComponent": Integer(bpc),
+			"Columns":          Integer(columns),
+		},
+	)
+
+	sd.FilterPipeline[0].DecodeParms = parms
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+
+	if len(sd.Raw) < len(raw) {
+		sd.Insert("DecodeParms", parms)
+		return nil
+	}
+
+	sd.FilterPipeline[0].DecodeParms = nil
+	sd.Raw = raw
+	streamLength := int64(len(raw))
+	sd.StreamLength = &streamLength
+	sd.Update("Length", Integer(streamLength))
+
+	return nil
+}
+
 func (sd StreamDict) Image() bool {
 	s := sd.Type()
 	if s == nil || *s != "XObject" {
@@ -272,7 +312,7 @@ func (sd *StreamDict) Encode() error {
 		// Make parms map[string]int
 		parms := parmsForFilter(f.DecodeParms)
 
-		fi, err := filter.NewFilter(f.Name, parms)
+		fi, err := filter.NewFilterWithStreams(f.Name, parms, f.Streams)
 		if err != nil {
 			return err
 		}
@@ -358,7 +398,7 @@ func (sd *StreamDict) decodeLength(maxLen int64) ([]byte, error) {
 			return nil, err
 		}
 
-		fi, err := filter.NewFilter(f.Name, parms)
+		fi, err := filter.NewFilterWithStreams(f.Name, parms, f.Streams)
 		if err != nil {
 			return nil, err
 		}
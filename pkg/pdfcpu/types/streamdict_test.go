@@ -0,0 +1,73 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+)
+
+func TestEncodeFlatePredicted(t *testing.T) {
+	w, h := 200, 100
+
+	// A smooth gradient benefits from prediction, a constant image does not.
+	gradient := make([]byte, 3*w*h)
+	for i := range gradient {
+		gradient[i] = byte(i%(3*w)/3 + i/(3*w))
+	}
+	constant := bytes.Repeat([]byte{0x80}, 3*w*h)
+
+	for _, tt := range []struct {
+		name      string
+		content   []byte
+		predicted bool
+	}{
+		{"gradient", gradient, true},
+		{"constant", constant, false},
+	} {
+		sd := StreamDict{
+			Dict:           NewDict(),
+			Content:        tt.content,
+			FilterPipeline: []PDFFilter{{Name: filter.Flate}},
+		}
+		sd.InsertName("Filter", filter.Flate)
+
+		if err := sd.EncodeFlatePredicted(3, 8, w); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		_, found := sd.Find("DecodeParms")
+		if found != tt.predicted {
+			t.Errorf("%s: predicted: %t, want %t", tt.name, found, tt.predicted)
+		}
+
+		if l := sd.Int64Entry("Length"); l == nil || *l != int64(len(sd.Raw)) {
+			t.Errorf("%s: invalid Length", tt.name)
+		}
+
+		sd.Content = nil
+		if err := sd.Decode(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if !bytes.Equal(sd.Content, tt.content) {
+			t.Errorf("%s: decoded content differs", tt.name)
+		}
+	}
+}
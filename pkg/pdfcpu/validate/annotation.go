@@ -17,6 +17,7 @@ limitations under the License.
 package validate
 
 import (
+	"fmt"
 	"strconv"
 
 	"github.com/pdfcpu/pdfcpu/pkg/log"
@@ -1725,12 +1726,69 @@ func validateAnnotationDict(xRefTable *model.XRefTable, d types.Dict) (isTrapNet
 	return *subtype == "TrapNet", nil
 }
 
+func validateAnnotation(xRefTable *model.XRefTable, pgAnnots model.PgAnnots, i int, v types.Object) (isTrapNet bool, err error) {
+	var (
+		annotDict     types.Dict
+		ok, hasIndRef bool
+		indRef        types.IndirectRef
+	)
+
+	if indRef, ok = v.(types.IndirectRef); ok {
+		hasIndRef = true
+		if log.ValidateEnabled() {
+			log.Validate.Printf("processing annotDict %d\n", indRef.ObjectNumber)
+		}
+		annotDict, err = xRefTable.DereferenceDict(indRef)
+		if err != nil {
+			return false, err
+		}
+		if len(annotDict) == 0 {
+			return false, nil
+		}
+	} else if xRefTable.ValidationMode != model.ValidationRelaxed {
+		return false, errInvalidPageAnnotArray
+	} else if annotDict, ok = v.(types.Dict); !ok {
+		return false, errInvalidPageAnnotArray
+	} else {
+		if log.ValidateEnabled() {
+			log.Validate.Println("digesting page annotation array w/o indirect references")
+		}
+	}
+
+	isTrapNet, err = validateAnnotationDict(xRefTable, annotDict)
+	if err != nil {
+		return false, err
+	}
+
+	// Collect annotation.
+
+	ann, err := pdfcpu.Annotation(xRefTable, annotDict)
+	if err != nil {
+		return false, err
+	}
+
+	annots, ok := pgAnnots[ann.Type()]
+	if !ok {
+		annots = model.Annot{}
+		annots.IndRefs = &[]types.IndirectRef{}
+		annots.Map = model.AnnotMap{}
+		pgAnnots[ann.Type()] = annots
+	}
+
+	objNr := -i
+	if hasIndRef {
+		objNr = indRef.ObjectNumber.Value()
+		*(annots.IndRefs) = append(*(annots.IndRefs), indRef)
+	}
+	annots.Map[objNr] = ann
+
+	return isTrapNet, nil
+}
+
 func validateAnnotationsArray(xRefTable *model.XRefTable, a types.Array) error {
 
 	// a ... array of indrefs to annotation dicts.
 
-	var annotDict types.Dict
-
 	pgAnnots := model.PgAnnots{}
 	xRefTable.PageAnnots[xRefTable.CurPage] = pgAnnots
 
@@ -1739,64 +1797,22 @@ func validateAnnotationsArray(xRefTable *model.XRefTable, a types.Array) error {
 
 	for i, v := range a {
 
-		if hasTrapNet {
-			return errors.New("pdfcpu: validatePageAnnotations: corrupted page annotation list, \"TrapNet\" has to be the last entry")
+		objNr := 0
+		if indRef, ok := v.(types.IndirectRef); ok {
+			objNr = indRef.ObjectNumber.Value()
 		}
 
-		var (
-			ok, hasIndRef bool
-			indRef        types.IndirectRef
-			err           error
-		)
-
-		if indRef, ok = v.(types.IndirectRef); ok {
-			hasIndRef = true
-			if log.ValidateEnabled() {
-				log.Validate.Printf("processing annotDict %d\n", indRef.ObjectNumber)
-			}
-			annotDict, err = xRefTable.DereferenceDict(indRef)
-			if err != nil {
-				return err
+		err := validateAt(xRefTable, fmt.Sprintf("Annots[%d]", i), objNr, func() error {
+			if hasTrapNet {
+				return errors.New("pdfcpu: validatePageAnnotations: corrupted page annotation list, \"TrapNet\" has to be the last entry")
 			}
-			if len(annotDict) == 0 {
-				continue
-			}
-		} else if xRefTable.ValidationMode != model.ValidationRelaxed {
-			return errInvalidPageAnnotArray
-		} else if annotDict, ok = v.(types.Dict); !ok {
-			return errInvalidPageAnnotArray
-		} else {
-			if log.ValidateEnabled() {
-				log.Validate.Println("digesting page annotation array w/o indirect references")
-			}
-		}
-
-		hasTrapNet, err = validateAnnotationDict(xRefTable, annotDict)
-		if err != nil {
+			isTrapNet, err := validateAnnotation(xRefTable, pgAnnots, i, v)
+			hasTrapNet = isTrapNet
 			return err
-		}
-
-		// Collect annotation.
-
-		ann, err := pdfcpu.Annotation(xRefTable, annotDict)
+		})
 		if err != nil {
 			return err
 		}
-
-		annots, ok := pgAnnots[ann.Type()]
-		if !ok {
-			annots = model.Annot{}
-			annots.IndRefs = &[]types.IndirectRef{}
-			annots.Map = model.AnnotMap{}
-			pgAnnots[ann.Type()] = annots
-		}
-
-		objNr := -i
-		if hasIndRef {
-			objNr = indRef.ObjectNumber.Value()
-			*(annots.IndRefs) = append(*(annots.IndRefs), indRef)
-		}
-		annots.Map[objNr] = ann
 	}
 
 	return nil
@@ -1830,7 +1846,7 @@ func validatePagesAnnotations(xRefTable *model.XRefTable, d types.Dict, curPage
 	// Iterate over page tree.
 	kidsArray := d.ArrayEntry("Kids")
 
-	for _, v := range kidsArray {
+	for i, v := range kidsArray {
 
 		if v == nil {
 			if log.ValidateEnabled() {
@@ -1839,41 +1855,43 @@ func validatePagesAnnotations(xRefTable *model.XRefTable, d types.Dict, curPage
 			continue
 		}
 
-		d, err := xRefTable.DereferenceDict(v)
+		err := validateAt(xRefTable, fmt.Sprintf("Kids[%d]", i), 0, func() (err error) {
+			curPage, err = validatePagesKidAnnotations(xRefTable, v, curPage)
+			return err
+		})
 		if err != nil {
 			return curPage, err
 		}
-		if d == nil {
-			return curPage, errors.New("pdfcpu: validatePagesAnnotations: pageNodeDict is null")
-		}
-		dictType := d.Type()
-		if dictType == nil {
-			return curPage, errors.New("pdfcpu: validatePagesAnnotations: missing pageNodeDict type")
-		}
+	}
 
-		switch *dictType {
+	return curPage, nil
+}
 
-		case "Pages":
-			// Recurse over pagetree
-			curPage, err = validatePagesAnnotations(xRefTable, d, curPage)
-			if err != nil {
-				return curPage, err
-			}
+func validatePagesKidAnnotations(xRefTable *model.XRefTable, v types.Object, curPage int) (int, error) {
+	d, err := xRefTable.DereferenceDict(v)
+	if err != nil {
+		return curPage, err
+	}
+	if d == nil {
+		return curPage, errors.New("pdfcpu: validatePagesAnnotations: pageNodeDict is null")
+	}
+	dictType := d.Type()
+	if dictType == nil {
+		return curPage, errors.New("pdfcpu: validatePagesAnnotations: missing pageNodeDict type")
+	}
 
-		case "Page":
-			curPage++
-			xRefTable.CurPage = curPage
-			err = validatePageAnnotations(xRefTable, d)
-			if err != nil {
-				return curPage, err
-			}
+	switch *dictType {
 
-		default:
-			return curPage, errors.Errorf("validatePagesAnnotations: expected dict type: %s\n", *dictType)
+	case "Pages":
+		// Recurse over pagetree
+		return validatePagesAnnotations(xRefTable, d, curPage)
 
-		}
+	case "Page":
+		curPage++
+		xRefTable.CurPage = curPage
+		return curPage, validatePageAnnotations(xRefTable, d)
 
 	}
 
-	return curPage, nil
+	return curPage, errors.Errorf("validatePagesAnnotations: expected dict type: %s\n", *dictType)
 }
@@ -0,0 +1,131 @@
+/*
+Copyright 2024 The pdfcpu Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validate
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+func validateStreamArrayEntry(xRefTable *model.XRefTable, d types.Dict, dictName, entryName string, required bool, sinceVersion model.Version) error {
+	a, err := validateIndRefArrayEntry(xRefTable, d, dictName, entryName, required, sinceVersion, nil)
+	if err != nil || a == nil {
+		return err
+	}
+
+	for _, o := range a {
+		if _, err := validateStreamDict(xRefTable, o); err != nil {
+			return errors.Wrapf(err, "dict=%s entry=%s", dictName, entryName)
+		}
+	}
+
+	return nil
+}
+
+func validateVRIKey(xRefTable *model.XRefTable, k string) bool {
+	// The uppercase hex encoded SHA-1 digest of a signature.
+	if xRefTable.ValidationMode == model.ValidationRelaxed {
+		k = strings.ToUpper(k)
+	}
+	if len(k) != 40 || strings.ToUpper(k) != k {
+		return false
+	}
+	_, err := hex.DecodeString(k)
+	return err == nil
+}
+
+func validateVRIDict(xRefTable *model.XRefTable, d types.Dict, sinceVersion model.Version) error {
+	dictName := "vriDict"
+
+	// Type, optional, name
+	_, err := validateNameEntry(xRefTable, d, dictName, "Type", OPTIONAL, sinceVersion, func(s string) bool { return s == "VRI" })
+	if err != nil {
+		return err
+	}
+
+	// Cert, CRL, OCSP, optional, array of streams
+	for _, k := range []string{"Cert", "CRL", "OCSP"} {
+		if err := validateStreamArrayEntry(xRefTable, d, dictName, k, OPTIONAL, sinceVersion); err != nil {
+			return err
+		}
+	}
+
+	// TU, optional, date
+	if _, err := validateDateEntry(xRefTable, d, dictName, "TU", OPTIONAL, sinceVersion); err != nil {
+		return err
+	}
+
+	// TS, optional, stream
+	_, err = validateStreamDictEntry(xRefTable, d, dictName, "TS", OPTIONAL, sinceVersion, nil)
+
+	return err
+}
+
+func validateDSS(xRefTable *model.XRefTable, rootDict types.Dict, required bool, sinceVersion model.Version) error {
+	// => 12.8.4.3 Document Security Store
+	// The DSS has been introduced for PDF 1.7 by the ETSI extension ESIC (PAdES).
+
+	dictName := "dssDict"
+
+	d, err := validateDictEntry(xRefTable, rootDict, "rootDict", "DSS", required, sinceVersion, nil)
+	if err != nil || d == nil {
+		return err
+	}
+
+	// Type, optional, name
+	_, err = validateNameEntry(xRefTable, d, dictName, "Type", OPTIONAL, sinceVersion, func(s string) bool { return s == "DSS" })
+	if err != nil {
+		return err
+	}
+
+	// Certs, CRLs, OCSPs, optional, array of streams
+	for _, k := range []string{"Certs", "CRLs", "OCSPs"} {
+		if err := validateStreamArrayEntry(xRefTable, d, dictName, k, OPTIONAL, sinceVersion); err != nil {
+			return err
+		}
+	}
+
+	// VRI, optional, dict of VRI dicts
+	vri, err := validateDictEntry(xRefTable, d, dictName, "VRI", OPTIONAL, sinceVersion, nil)
+	if err != nil || vri == nil {
+		return err
+	}
+
+	for k, o := range vri {
+		if !validateVRIKey(xRefTable, k) {
+			return errors.Errorf("pdfcpu: validateDSS: invalid VRI key: %s", k)
+		}
+
+		d1, err := xRefTable.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		if d1 == nil {
+			return errors.Errorf("pdfcpu: validateDSS: missing VRI dict for key: %s", k)
+		}
+
+		if err := validateVRIDict(xRefTable, d1, sinceVersion); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
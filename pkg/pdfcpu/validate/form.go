@@ -17,6 +17,7 @@ limitations under the License.
 package validate
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -355,7 +356,7 @@ func validateFormFieldKids(xRefTable *model.XRefTable, d types.Dict, o types.Obj
 		return err
 	}
 
-	for _, value := range a {
+	for i, value := range a {
 		ir, ok := value.(types.IndirectRef)
 		if !ok {
 			return errors.New("pdfcpu: validateFormFieldKids: corrupt kids array: entries must be indirect reference")
@@ -366,7 +367,10 @@ func validateFormFieldKids(xRefTable *model.XRefTable, d types.Dict, o types.Obj
 		}
 
 		if !valid {
-			if err = validateFormFieldDict(xRefTable, ir, xInFieldType, requiresDA); err != nil {
+			err = validateAt(xRefTable, fmt.Sprintf("Kids[%d]", i), ir.ObjectNumber.Value(), func() error {
+				return validateFormFieldDict(xRefTable, ir, xInFieldType, requiresDA)
+			})
+			if err != nil {
 				return err
 			}
 		}
@@ -405,7 +409,7 @@ func validateFormFields(xRefTable *model.XRefTable, o types.Object, requiresDA b
 		return err
 	}
 
-	for _, value := range a {
+	for i, value := range a {
 
 		ir, ok := value.(types.IndirectRef)
 		if !ok {
@@ -418,7 +422,10 @@ func validateFormFields(xRefTable *model.XRefTable, o types.Object, requiresDA b
 		}
 
 		if !valid {
-			if err = validateFormFieldDict(xRefTable, ir, nil, requiresDA); err != nil {
+			err = validateAt(xRefTable, fmt.Sprintf("Fields[%d]", i), ir.ObjectNumber.Value(), func() error {
+				return validateFormFieldDict(xRefTable, ir, nil, requiresDA)
+			})
+			if err != nil {
 				return err
 			}
 		}
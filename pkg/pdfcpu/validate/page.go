@@ -17,6 +17,7 @@ limitations under the License.
 package validate
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/pdfcpu/pdfcpu/pkg/log"
@@ -114,7 +115,7 @@ func validatePageContents(xRefTable *model.XRefTable, d types.Dict) (hasContents
 
 		// Digest empty array.
 		d["Contents"] = nil
-		model.ShowRepaired("corrupt page dict \"Contents\"")
+		xRefTable.RecordRepair(0, model.RepairObject, "corrupt page dict \"Contents\"")
 
 	case types.StringLiteral:
 
@@ -126,7 +127,7 @@ func validatePageContents(xRefTable *model.XRefTable, d types.Dict) (hasContents
 
 		// Digest empty string literal.
 		d["Contents"] = nil
-		model.ShowRepaired("corrupt page dict \"Contents\"")
+		xRefTable.RecordRepair(0, model.RepairObject, "corrupt page dict \"Contents\"")
 
 	default:
 		return false, errors.Errorf("validatePageContents: page content must be stream dict or array, got: %T", o)
@@ -794,86 +795,87 @@ func validatePageDict(xRefTable *model.XRefTable, d types.Dict, objNumber int, h
 	dictName := "pageDict"
 
 	if ir := d.IndirectRefEntry("Parent"); ir == nil {
-		return errors.New("pdfcpu: validatePageDict: missing parent")
+		if err := xRefTable.RecordViolation(objNumber, errors.New("pdfcpu: validatePageDict: missing parent")); err != nil {
+			return err
+		}
 	}
 
 	// Contents
-	hasContents, err := validatePageContents(xRefTable, d)
+	var hasContents bool
+	err := validateAt(xRefTable, "Contents", 0, func() (err error) {
+		hasContents, err = validatePageContents(xRefTable, d)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
 	// Resources
-	err = validatePageResources(xRefTable, d, hasResources, hasContents)
+	err = validateAt(xRefTable, "Resources", 0, func() error {
+		return validatePageResources(xRefTable, d, hasResources, hasContents)
+	})
 	if err != nil {
 		return err
 	}
 
 	// MediaBox
-	_, err = validatePageEntryMediaBox(xRefTable, d, !hasMediaBox, model.V10)
+	err = validateAt(xRefTable, "MediaBox", objNumber, func() error {
+		_, err := validatePageEntryMediaBox(xRefTable, d, !hasMediaBox, model.V10)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
 	// PieceInfo
 	if xRefTable.ValidationMode != model.ValidationRelaxed {
-		sinceVersion := model.V13
-		if xRefTable.ValidationMode == model.ValidationRelaxed {
-			sinceVersion = model.V10
-		}
-
-		hasPieceInfo, err := validatePieceInfo(xRefTable, d, dictName, "PieceInfo", OPTIONAL, sinceVersion)
-		if err != nil {
-			return err
-		}
-
-		// LastModified
-		lm, err := validateDateEntry(xRefTable, d, dictName, "LastModified", OPTIONAL, model.V13)
+		err = validateAt(xRefTable, "PieceInfo", objNumber, func() error {
+			return validatePageDictPieceInfo(xRefTable, d, dictName)
+		})
 		if err != nil {
 			return err
 		}
-
-		if hasPieceInfo && lm == nil && xRefTable.ValidationMode == model.ValidationStrict {
-			return errors.New("pdfcpu: validatePageDict: missing \"LastModified\" (required by \"PieceInfo\")")
-		}
 	}
 
 	// AA
-	err = validateAdditionalActions(xRefTable, d, dictName, "AA", OPTIONAL, model.V14, "page")
+	err = validateAt(xRefTable, "AA", objNumber, func() error {
+		return validateAdditionalActions(xRefTable, d, dictName, "AA", OPTIONAL, model.V14, "page")
+	})
 	if err != nil {
 		return err
 	}
 
 	type v struct {
+		entry        string
 		validate     func(xRefTable *model.XRefTable, d types.Dict, required bool, sinceVersion model.Version) (err error)
 		required     bool
 		sinceVersion model.Version
 	}
 
 	for _, f := range []v{
-		{validatePageEntryCropBox, OPTIONAL, model.V10},
-		{validatePageEntryBleedBox, OPTIONAL, model.V13},
-		{validatePageEntryTrimBox, OPTIONAL, model.V13},
-		{validatePageEntryArtBox, OPTIONAL, model.V13},
-		{validatePageBoxColorInfo, OPTIONAL, model.V14},
-		{validatePageEntryRotate, OPTIONAL, model.V10},
-		{validatePageEntryGroup, OPTIONAL, model.V14},
-		{validatePageEntryThumb, OPTIONAL, model.V10},
-		{validatePageEntryB, OPTIONAL, model.V11},
-		{validatePageEntryDur, OPTIONAL, model.V11},
-		{validatePageEntryTrans, OPTIONAL, model.V11},
-		{validateMetadata, OPTIONAL, model.V14},
-		{validatePageEntryStructParents, OPTIONAL, model.V10},
-		{validatePageEntryID, OPTIONAL, model.V13},
-		{validatePageEntryPZ, OPTIONAL, model.V13},
-		{validatePageEntrySeparationInfo, OPTIONAL, model.V13},
-		{validatePageEntryTabs, OPTIONAL, model.V15},
-		{validatePageEntryTemplateInstantiated, OPTIONAL, model.V15},
-		{validatePageEntryPresSteps, OPTIONAL, model.V15},
-		{validatePageEntryUserUnit, OPTIONAL, model.V16},
-		{validatePageEntryVP, OPTIONAL, model.V16},
+		{"CropBox", validatePageEntryCropBox, OPTIONAL, model.V10},
+		{"BleedBox", validatePageEntryBleedBox, OPTIONAL, model.V13},
+		{"TrimBox", validatePageEntryTrimBox, OPTIONAL, model.V13},
+		{"ArtBox", validatePageEntryArtBox, OPTIONAL, model.V13},
+		{"BoxColorInfo", validatePageBoxColorInfo, OPTIONAL, model.V14},
+		{"Rotate", validatePageEntryRotate, OPTIONAL, model.V10},
+		{"Group", validatePageEntryGroup, OPTIONAL, model.V14},
+		{"Thumb", validatePageEntryThumb, OPTIONAL, model.V10},
+		{"B", validatePageEntryB, OPTIONAL, model.V11},
+		{"Dur", validatePageEntryDur, OPTIONAL, model.V11},
+		{"Trans", validatePageEntryTrans, OPTIONAL, model.V11},
+		{"Metadata", validateMetadata, OPTIONAL, model.V14},
+		{"StructParents", validatePageEntryStructParents, OPTIONAL, model.V10},
+		{"ID", validatePageEntryID, OPTIONAL, model.V13},
+		{"PZ", validatePageEntryPZ, OPTIONAL, model.V13},
+		{"SeparationInfo", validatePageEntrySeparationInfo, OPTIONAL, model.V13},
+		{"Tabs", validatePageEntryTabs, OPTIONAL, model.V15},
+		{"TemplateInstantiated", validatePageEntryTemplateInstantiated, OPTIONAL, model.V15},
+		{"PresSteps", validatePageEntryPresSteps, OPTIONAL, model.V15},
+		{"UserUnit", validatePageEntryUserUnit, OPTIONAL, model.V16},
+		{"VP", validatePageEntryVP, OPTIONAL, model.V16},
 	} {
-		err = f.validate(xRefTable, d, f.required, f.sinceVersion)
+		err = validateAt(xRefTable, f.entry, objNumber, func() error { return f.validate(xRefTable, d, f.required, f.sinceVersion) })
 		if err != nil {
 			return err
 		}
@@ -882,6 +884,30 @@ func validatePageDict(xRefTable *model.XRefTable, d types.Dict, objNumber int, h
 	return nil
 }
 
+func validatePageDictPieceInfo(xRefTable *model.XRefTable, d types.Dict, dictName string) error {
+	sinceVersion := model.V13
+	if xRefTable.ValidationMode == model.ValidationRelaxed {
+		sinceVersion = model.V10
+	}
+
+	hasPieceInfo, err := validatePieceInfo(xRefTable, d, dictName, "PieceInfo", OPTIONAL, sinceVersion)
+	if err != nil {
+		return err
+	}
+
+	// LastModified
+	lm, err := validateDateEntry(xRefTable, d, dictName, "LastModified", OPTIONAL, model.V13)
+	if err != nil {
+		return err
+	}
+
+	if hasPieceInfo && lm == nil && xRefTable.ValidationMode == model.ValidationStrict {
+		return errors.New("pdfcpu: validatePageDict: missing \"LastModified\" (required by \"PieceInfo\")")
+	}
+
+	return nil
+}
+
 func validatePagesDictGeneralEntries(xRefTable *model.XRefTable, d types.Dict) (pageCount int, hasResources, hasMediaBox bool, err error) {
 
 	// PageCount of this sub page tree
@@ -983,69 +1009,88 @@ func validateParent(pageNodeDict types.Dict, objNr int) error {
 	return nil
 }
 
-func processPagesKids(xRefTable *model.XRefTable, kids types.Array, objNr int, hasResources, hasMediaBox bool, curPage *int) (types.Array, error) {
-	var a types.Array
+func validatePagesKid(xRefTable *model.XRefTable, o types.Object, objNr int, hasResources, hasMediaBox bool, curPage *int) (*types.IndirectRef, error) {
+	ir, ok := o.(types.IndirectRef)
+	if !ok {
+		return nil, errors.New("pdfcpu: validatePagesDict: missing indirect reference for kid")
+	}
 
-	for _, o := range kids {
+	if log.ValidateEnabled() {
+		log.Validate.Printf("validatePagesDict: PageNode: %s\n", ir)
+	}
 
-		if o == nil {
-			continue
-		}
+	objNumber := ir.ObjectNumber.Value()
+	if objNumber == 0 {
+		return nil, nil
+	}
 
-		ir, ok := o.(types.IndirectRef)
-		if !ok {
-			return nil, errors.New("pdfcpu: validatePagesDict: missing indirect reference for kid")
-		}
+	pageNodeDict, err := xRefTable.DereferenceDict(ir)
+	if err != nil {
+		return &ir, err
+	}
+	if pageNodeDict == nil {
+		return &ir, errors.New("pdfcpu: validatePagesDict: corrupt page node")
+	}
 
-		if log.ValidateEnabled() {
-			log.Validate.Printf("validatePagesDict: PageNode: %s\n", ir)
+	if err := validateParent(pageNodeDict, objNr); err != nil {
+		return &ir, err
+	}
+
+	dictType, err := dictTypeForPageNodeDict(pageNodeDict)
+	if err != nil {
+		return &ir, err
+	}
+
+	switch dictType {
+
+	case "Pages":
+		if err = validatePagesDict(xRefTable, pageNodeDict, objNumber, hasResources, hasMediaBox, curPage); err != nil {
+			return &ir, err
 		}
 
-		objNumber := ir.ObjectNumber.Value()
-		if objNumber == 0 {
-			continue
+	case "Page":
+		*curPage++
+		xRefTable.CurPage = *curPage
+		if err = validatePageDict(xRefTable, pageNodeDict, objNumber, hasResources, hasMediaBox); err != nil {
+			return &ir, err
+		}
+		if err := xRefTable.SetValid(ir); err != nil {
+			return &ir, err
 		}
 
-		a = append(a, ir)
+	default:
+		return &ir, errors.Errorf("pdfcpu: validatePagesDict: Unexpected dict type: %s", dictType)
+	}
 
-		pageNodeDict, err := xRefTable.DereferenceDict(ir)
-		if err != nil {
-			return nil, err
-		}
-		if pageNodeDict == nil {
-			return nil, errors.New("pdfcpu: validatePagesDict: corrupt page node")
+	return &ir, nil
+}
+
+func processPagesKids(xRefTable *model.XRefTable, kids types.Array, objNr int, hasResources, hasMediaBox bool, curPage *int) (types.Array, error) {
+	var a types.Array
+
+	for i, o := range kids {
+
+		if o == nil {
+			continue
 		}
 
-		if err := validateParent(pageNodeDict, objNr); err != nil {
-			return nil, err
+		kidObjNr := 0
+		if ir, ok := o.(types.IndirectRef); ok {
+			kidObjNr = ir.ObjectNumber.Value()
 		}
 
-		dictType, err := dictTypeForPageNodeDict(pageNodeDict)
+		var ir *types.IndirectRef
+		err := validateAt(xRefTable, fmt.Sprintf("Kids[%d]", i), kidObjNr, func() (err error) {
+			ir, err = validatePagesKid(xRefTable, o, objNr, hasResources, hasMediaBox, curPage)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
 
-		switch dictType {
-
-		case "Pages":
-			if err = validatePagesDict(xRefTable, pageNodeDict, objNumber, hasResources, hasMediaBox, curPage); err != nil {
-				return nil, err
-			}
-
-		case "Page":
-			*curPage++
-			xRefTable.CurPage = *curPage
-			if err = validatePageDict(xRefTable, pageNodeDict, objNumber, hasResources, hasMediaBox); err != nil {
-				return nil, err
-			}
-			if err := xRefTable.SetValid(ir); err != nil {
-				return nil, err
-			}
-
-		default:
-			return nil, errors.Errorf("pdfcpu: validatePagesDict: Unexpected dict type: %s", dictType)
+		if ir != nil {
+			a = append(a, *ir)
 		}
-
 	}
 
 	return a, nil
@@ -1162,13 +1207,7 @@ func validatePages(xRefTable *model.XRefTable, rootDict types.Dict) (types.Dict,
 		if err != nil {
 			return nil, err
 		}
-		msg := "repaired: missing \"Pages\" indirect reference"
-		if log.DebugEnabled() {
-			log.Debug.Println("pdfcpu " + msg)
-		}
-		if log.CLIEnabled() {
-			log.CLI.Println(msg)
-		}
+		xRefTable.RecordRepair(objNr, model.RepairObject, "missing \"Pages\" indirect reference")
 	}
 
 	if ok {
@@ -1196,7 +1235,7 @@ func validatePages(xRefTable *model.XRefTable, rootDict types.Dict) (types.Dict,
 	}
 
 	if i != *pageCount {
-		return nil, errors.New("pdfcpu: validatePages: page tree corrupted")
+		return pageRoot, errors.New("pdfcpu: validatePages: page tree corrupted")
 	}
 
 	return pageRoot, err
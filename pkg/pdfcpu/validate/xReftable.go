@@ -40,7 +40,11 @@ func XRefTable(xRefTable *model.XRefTable) error {
 		log.Validate.Println("*** validateXRefTable begin ***")
 	}
 
-	metaDataAuthoritative, err := metaDataModifiedAfterInfoDict(xRefTable)
+	var metaDataAuthoritative bool
+	err := validateAt(xRefTable, "Root/Metadata", 0, func() (err error) {
+		metaDataAuthoritative, err = metaDataModifiedAfterInfoDict(xRefTable)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -48,21 +52,21 @@ func XRefTable(xRefTable *model.XRefTable) error {
 	if metaDataAuthoritative {
 		// if both info dict and catalog metadata present and metadata modification date after infodict modification date
 		// validate document information dictionary before catalog metadata.
-		err := validateDocumentInfoObject(xRefTable)
+		err := validateAt(xRefTable, "Info", 0, func() error { return validateDocumentInfoObject(xRefTable) })
 		if err != nil {
 			return err
 		}
 	}
 
 	// Validate root object(aka the document catalog) and page tree.
-	err = validateRootObject(xRefTable)
+	err = validateAt(xRefTable, "Root", 0, func() error { return validateRootObject(xRefTable) })
 	if err != nil {
 		return err
 	}
 
 	if !metaDataAuthoritative {
 		// Validate document information dictionary after catalog metadata.
-		err = validateDocumentInfoObject(xRefTable)
+		err = validateAt(xRefTable, "Info", 0, func() error { return validateDocumentInfoObject(xRefTable) })
 		if err != nil {
 			return err
 		}
@@ -74,6 +78,13 @@ func XRefTable(xRefTable *model.XRefTable) error {
 		return err
 	}
 
+	if xRefTable.CollectDiagnostics && len(xRefTable.Diagnostics) > 0 {
+		if log.ValidateEnabled() {
+			log.Validate.Printf("*** validateXRefTable end: %d violations ***\n", len(xRefTable.Diagnostics))
+		}
+		return nil
+	}
+
 	xRefTable.Valid = true
 
 	if log.ValidateEnabled() {
@@ -83,6 +94,51 @@ func XRefTable(xRefTable *model.XRefTable) error {
 	return nil
 }
 
+// XRefTableLight validates the document information dictionary of a lazily read xRefTable
+// and binds page count and form without touching the bulk of the remaining objects.
+func XRefTableLight(xRefTable *model.XRefTable) error {
+	if log.ValidateEnabled() {
+		log.Validate.Println("*** validateXRefTableLight begin ***")
+	}
+
+	if err := validateDocumentInfoObject(xRefTable); err != nil {
+		return err
+	}
+
+	if err := xRefTable.EnsurePageCount(); err != nil {
+		return err
+	}
+
+	rootDict, err := xRefTable.Catalog()
+	if err != nil {
+		return err
+	}
+
+	if o, found := rootDict.Find("AcroForm"); found {
+		d, err := xRefTable.DereferenceDict(o)
+		if err != nil {
+			return err
+		}
+		if len(d) > 0 {
+			xRefTable.Form = d
+		}
+	}
+
+	if log.ValidateEnabled() {
+		log.Validate.Println("*** validateXRefTableLight end ***")
+	}
+
+	return nil
+}
+
+// validateAt validates the dictionary path element elem.
+// When collecting diagnostics any violation for obj#objNr gets recorded and validation goes on.
+func validateAt(xRefTable *model.XRefTable, elem string, objNr int, validate func() error) error {
+	xRefTable.PushPath(elem)
+	defer xRefTable.PopPath()
+	return xRefTable.RecordViolation(objNr, validate())
+}
+
 func metaDataModifiedAfterInfoDict(xRefTable *model.XRefTable) (bool, error) {
 	rootDict, err := xRefTable.Catalog()
 	if err != nil {
@@ -936,7 +992,7 @@ func validateRootObject(xRefTable *model.XRefTable) error {
 	// Collection           y   1.7         dict            => 12.3.5 Collections
 	// NeedsRendering       y   1.7         boolean         => XML Forms Architecture (XFA) Spec.
 
-	// DSS					y	2.0			dict			=> 12.8.4.3 Document Security Store	TODO
+	// DSS					y	2.0			dict			=> 12.8.4.3 Document Security Store (1.7 + ESIC extension)
 	// AF					y	2.0			array of dicts	=> 14.3 Associated Files			TODO
 	// DPartRoot			y	2.0			dict			=> 14.12 Document parts				TODO
 
@@ -946,63 +1002,80 @@ func validateRootObject(xRefTable *model.XRefTable) error {
 	}
 
 	// Type
-	_, err = validateNameEntry(xRefTable, d, "rootDict", "Type", REQUIRED, model.V10, func(s string) bool { return s == "Catalog" })
+	err = validateAt(xRefTable, "Type", 0, func() error {
+		_, err := validateNameEntry(xRefTable, d, "rootDict", "Type", REQUIRED, model.V10, func(s string) bool { return s == "Catalog" })
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
 	// Pages
-	rootPageNodeDict, err := validatePages(xRefTable, d)
+	var rootPageNodeDict types.Dict
+	err = validateAt(xRefTable, "Pages", 0, func() (err error) {
+		rootPageNodeDict, err = validatePages(xRefTable, d)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
 	for _, f := range []struct {
+		entry        string
 		validate     func(xRefTable *model.XRefTable, d types.Dict, required bool, sinceVersion model.Version) (err error)
 		required     bool
 		sinceVersion model.Version
 	}{
-		{validateRootVersion, OPTIONAL, model.V14},
-		{validateExtensions, OPTIONAL, model.V10},
-		{validatePageLabels, OPTIONAL, model.V13},
-		{validateNames, OPTIONAL, model.V12},
-		{validateNamedDestinations, OPTIONAL, model.V11},
-		{validateViewerPreferences, OPTIONAL, model.V12},
-		{validatePageLayout, OPTIONAL, model.V10},
-		{validatePageMode, OPTIONAL, model.V10},
-		{validateOutlines, OPTIONAL, model.V10},
-		{validateThreads, OPTIONAL, model.V11},
-		{validateOpenAction, OPTIONAL, model.V11},
-		{validateRootAdditionalActions, OPTIONAL, model.V14},
-		{validateURI, OPTIONAL, model.V11},
-		{validateForm, OPTIONAL, model.V12},
-		{validateRootMetadata, OPTIONAL, model.V14},
-		{validateStructTree, OPTIONAL, model.V13},
-		{validateMarkInfo, OPTIONAL, model.V14},
-		{validateLang, OPTIONAL, model.V10},
-		{validateSpiderInfo, OPTIONAL, model.V13},
-		{validateOutputIntents, OPTIONAL, model.V14},
-		{validateRootPieceInfo, OPTIONAL, model.V14},
-		{validateOCProperties, OPTIONAL, model.V15},
-		{validatePermissions, OPTIONAL, model.V15},
-		{validateLegal, OPTIONAL, model.V17},
-		{validateRequirements, OPTIONAL, model.V17},
-		{validateCollection, OPTIONAL, model.V17},
-		{validateNeedsRendering, OPTIONAL, model.V17},
+		{"Version", validateRootVersion, OPTIONAL, model.V14},
+		{"Extensions", validateExtensions, OPTIONAL, model.V10},
+		{"PageLabels", validatePageLabels, OPTIONAL, model.V13},
+		{"Names", validateNames, OPTIONAL, model.V12},
+		{"Dests", validateNamedDestinations, OPTIONAL, model.V11},
+		{"ViewerPreferences", validateViewerPreferences, OPTIONAL, model.V12},
+		{"PageLayout", validatePageLayout, OPTIONAL, model.V10},
+		{"PageMode", validatePageMode, OPTIONAL, model.V10},
+		{"Outlines", validateOutlines, OPTIONAL, model.V10},
+		{"Threads", validateThreads, OPTIONAL, model.V11},
+		{"OpenAction", validateOpenAction, OPTIONAL, model.V11},
+		{"AA", validateRootAdditionalActions, OPTIONAL, model.V14},
+		{"URI", validateURI, OPTIONAL, model.V11},
+		{"AcroForm", validateForm, OPTIONAL, model.V12},
+		{"Metadata", validateRootMetadata, OPTIONAL, model.V14},
+		{"StructTreeRoot", validateStructTree, OPTIONAL, model.V13},
+		{"MarkInfo", validateMarkInfo, OPTIONAL, model.V14},
+		{"Lang", validateLang, OPTIONAL, model.V10},
+		{"SpiderInfo", validateSpiderInfo, OPTIONAL, model.V13},
+		{"OutputIntents", validateOutputIntents, OPTIONAL, model.V14},
+		{"PieceInfo", validateRootPieceInfo, OPTIONAL, model.V14},
+		{"OCProperties", validateOCProperties, OPTIONAL, model.V15},
+		{"Perms", validatePermissions, OPTIONAL, model.V15},
+		{"Legal", validateLegal, OPTIONAL, model.V17},
+		{"Requirements", validateRequirements, OPTIONAL, model.V17},
+		{"Collection", validateCollection, OPTIONAL, model.V17},
+		{"NeedsRendering", validateNeedsRendering, OPTIONAL, model.V17},
+		{"DSS", validateDSS, OPTIONAL, model.V17},
 	} {
 		if !f.required && xRefTable.Version() < f.sinceVersion {
 			// Ignore optional fields if currentVersion < sinceVersion
 			// This is really a workaround for explicitly extending relaxed validation.
 			continue
 		}
-		err = f.validate(xRefTable, d, f.required, f.sinceVersion)
+		err = validateAt(xRefTable, f.entry, 0, func() error { return f.validate(xRefTable, d, f.required, f.sinceVersion) })
 		if err != nil {
 			return err
 		}
 	}
 
 	// Validate remainder of annotations after AcroForm validation only.
-	_, err = validatePagesAnnotations(xRefTable, rootPageNodeDict, 0)
+	if rootPageNodeDict != nil {
+		err = validateAt(xRefTable, "Pages", 0, func() error {
+			_, err := validatePagesAnnotations(xRefTable, rootPageNodeDict, 0)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
 
 	if xRefTable.ValidateLinks && len(xRefTable.URIs) > 0 {
 		err = checkForBrokenLinks(xRefTable)
@@ -62,6 +62,8 @@ func writeObjects(ctx *model.Context) error {
 
 // Write generates a PDF file for the cross reference table contained in Context.
 func Write(ctx *model.Context) (err error) {
+	ctx.KeepLoadedObjects()
+
 	// Create a writer for dirname and filename if not already supplied.
 	if ctx.Write.Writer == nil {
 
@@ -155,6 +157,8 @@ func Write(ctx *model.Context) (err error) {
 
 // WriteIncrement writes a PDF increment..
 func WriteIncrement(ctx *model.Context) error {
+	ctx.KeepLoadedObjects()
+
 	// Write all modified objects that are part of this increment.
 	for _, i := range ctx.Write.ObjNrs {
 		if err := writeFlatObject(ctx, i); err != nil {
@@ -420,7 +424,7 @@ func writeTrailerDict(ctx *model.Context) error {
 		d.Insert("Info", *xRefTable.Info)
 	}
 
-	if ctx.Encrypt != nil && ctx.EncKey != nil {
+	if writeEncrypted(ctx) {
 		d.Insert("Encrypt", *ctx.Encrypt)
 	}
 
@@ -793,7 +797,7 @@ func newXRefStreamDict(ctx *model.Context) *types.XRefStreamDict {
 	if ctx.ID != nil {
 		sd.Insert("ID", ctx.ID)
 	}
-	if ctx.Encrypt != nil && ctx.EncKey != nil {
+	if writeEncrypted(ctx) {
 		sd.Insert("Encrypt", *ctx.Encrypt)
 	}
 	if ctx.Write.Increment {
@@ -854,7 +858,12 @@ func writeXRefStream(ctx *model.Context) error {
 	xRefStreamDict.Insert("Index", *indArr)
 
 	// Encode xRefStreamDict.Content -> xRefStreamDict.Raw
-	if err = xRefStreamDict.StreamDict.Encode(); err != nil {
+	if ctx.FlatePredictor {
+		err = xRefStreamDict.StreamDict.EncodeFlatePredicted(1, 8, i1+i2+i3)
+	} else {
+		err = xRefStreamDict.StreamDict.Encode()
+	}
+	if err != nil {
 		return err
 	}
 
@@ -891,9 +900,15 @@ func writeXRefStream(ctx *model.Context) error {
 	return nil
 }
 
+// writeEncrypted returns true if ctx gets written encrypted.
+// Locked embedded files keep their encryption.
+func writeEncrypted(ctx *model.Context) bool {
+	return ctx.Encrypt != nil && (ctx.EncKey != nil || ctx.EmbeddedFilesLocked)
+}
+
 func writeEncryptDict(ctx *model.Context) error {
 	// Bail out unless we really have to write encrypted.
-	if ctx.Encrypt == nil || ctx.EncKey == nil {
+	if !writeEncrypted(ctx) {
 		return nil
 	}
 
@@ -909,37 +924,68 @@ func writeEncryptDict(ctx *model.Context) error {
 	return writeObject(ctx, objNumber, genNumber, d.PDFString())
 }
 
-func setupEncryption(ctx *model.Context) error {
-	var err error
-
-	if ok := validateAlgorithm(ctx); !ok {
-		return errors.New("pdfcpu: unsupported encryption algorithm (PDF 2.0 assumes AES/256)")
-	}
-
+func setupStandardEncryption(ctx *model.Context) (types.Dict, error) {
 	d := newEncryptDict(
 		ctx.Version(),
 		ctx.EncryptUsingAES,
 		ctx.EncryptKeyLength,
 		int16(ctx.Permissions),
+		ctx.EncryptAttachmentsOnly,
+		ctx.EncryptMetadata,
 	)
 
+	var err error
+
 	if ctx.E, err = supportedEncryption(ctx, d); err != nil {
-		return err
+		return nil, err
 	}
 
 	if ctx.ID == nil {
-		return errors.New("pdfcpu: encrypt: missing ID")
+		return nil, errors.New("pdfcpu: encrypt: missing ID")
 	}
 
 	if ctx.E.ID, err = ctx.IDFirstElement(); err != nil {
-		return err
+		return nil, err
 	}
 
 	if err = calcOAndU(ctx, d); err != nil {
-		return err
+		return nil, err
 	}
 
 	if err = writePermissions(ctx, d); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func setupEncryption(ctx *model.Context) error {
+	var err error
+
+	if ok := validateAlgorithm(ctx); !ok {
+		return errors.New("pdfcpu: unsupported encryption algorithm (PDF 2.0 assumes AES/256)")
+	}
+
+	if ctx.EncryptAttachmentsOnly {
+		if !ctx.EncryptUsingAES || ctx.EncryptKeyLength < 128 {
+			return errors.New("pdfcpu: encrypting attachments only requires AES/128 or AES/256")
+		}
+		if len(ctx.EncryptRecipients) > 0 {
+			return errors.New("pdfcpu: encrypting attachments only is not supported for public-key encryption")
+		}
+	}
+
+	if !ctx.EncryptMetadata && ctx.EncryptKeyLength < 128 {
+		return errors.New("pdfcpu: unencrypted metadata requires a key length of 128 or 256")
+	}
+
+	var d types.Dict
+
+	if len(ctx.EncryptRecipients) > 0 {
+		if d, err = setupPubSecEncryption(ctx); err != nil {
+			return err
+		}
+	} else if d, err = setupStandardEncryption(ctx); err != nil {
 		return err
 	}
 
@@ -961,6 +1007,10 @@ func updateEncryption(ctx *model.Context) error {
 		return errors.New("pdfcpu: This file is not encrypted - nothing written.")
 	}
 
+	if ctx.E.PubSec {
+		return errors.New("pdfcpu: passwords and permissions of files using public-key encryption are fixed")
+	}
+
 	d, err := ctx.EncryptDict()
 	if err != nil {
 		return err
@@ -1033,7 +1083,13 @@ func handleEncryption(ctx *model.Context) error {
 				alg = "AES"
 			}
 			if log.CLIEnabled() {
-				log.CLI.Printf("using %s-%d\n", alg, ctx.EncryptKeyLength)
+				if ctx.E.PubSec {
+					log.CLI.Printf("using %s-%d for %d recipients\n", alg, ctx.EncryptKeyLength, len(ctx.EncryptRecipients))
+				} else if ctx.EncryptAttachmentsOnly {
+					log.CLI.Printf("using %s-%d for attachments\n", alg, ctx.EncryptKeyLength)
+				} else {
+					log.CLI.Printf("using %s-%d\n", alg, ctx.EncryptKeyLength)
+				}
 			}
 		}
 
@@ -1045,6 +1101,14 @@ func handleEncryption(ctx *model.Context) error {
 
 	}
 
+	if ctx.EncKey != nil && ownEmbeddedFileCryptFilter(ctx) {
+		objNrs, err := embeddedFileStreams(ctx.XRefTable)
+		if err != nil {
+			return err
+		}
+		ctx.EmbeddedFileStreams = objNrs
+	}
+
 	// write xrefstream if using xrefstream only.
 	if ctx.Encrypt != nil && ctx.EncKey != nil && !ctx.Read.UsingXRefStreams {
 		ctx.WriteObjectStream = false
@@ -192,14 +192,21 @@ func streamBytes(sd *types.StreamDict) ([]byte, error) {
 
 	switch f {
 
-	case filter.DCT, filter.Flate, filter.CCITTFax, filter.ASCII85, filter.RunLength:
+	case filter.DCT, filter.Flate, filter.CCITTFax, filter.JBIG2, filter.ASCII85, filter.RunLength:
 		// If color space is CMYK then write .tif else write .png
 		if err := sd.Decode(); err != nil {
 			return nil, err
 		}
 
 	case filter.JPX:
-		//imageObj.Extension = "jpx"
+		if err := sd.Decode(); err != nil {
+			return nil, err
+		}
+		img, err := filter.DecodeJPX(bytes.NewReader(sd.Content))
+		if err != nil {
+			return nil, err
+		}
+		return img.Pix, nil
 
 	default:
 		if log.DebugEnabled() {
@@ -235,6 +242,11 @@ func softMask(xRefTable *model.XRefTable, d *types.StreamDict, w, h, objNr int)
 	}
 
 	bpc := sd.IntEntry("BitsPerComponent")
+	if bpc == nil && sd.HasSoleFilterNamed(filter.JPX) {
+		// Decoded JPX samples are 8 bit.
+		i := 8
+		bpc = &i
+	}
 	if bpc == nil {
 		if log.InfoEnabled() {
 			log.Info.Printf("softMask: obj#%d - ignoring soft mask without bpc\n%s\n", objNr, sd)
@@ -829,14 +841,20 @@ func renderDeviceN(xRefTable *model.XRefTable, im *PDFImage, resourceName string
 }
 
 func renderImage(xRefTable *model.XRefTable, sd *types.StreamDict, thumb bool, resourceName string, objNr int) (io.Reader, string, error) {
-	// If color space is CMYK then write .tif else write .png
-
 	pdfImage, err := pdfImage(xRefTable, sd, thumb, objNr)
 	if err != nil {
 		return nil, "", err
 	}
 
-	o, err := xRefTable.DereferenceDictEntry(sd.Dict, "ColorSpace")
+	return renderPDFImage(xRefTable, pdfImage, resourceName)
+}
+
+func renderPDFImage(xRefTable *model.XRefTable, pdfImage *PDFImage, resourceName string) (io.Reader, string, error) {
+	// If color space is CMYK then write .tif else write .png
+
+	objNr := pdfImage.objNr
+
+	o, err := xRefTable.DereferenceDictEntry(pdfImage.sd.Dict, "ColorSpace")
 	if err != nil {
 		return nil, "", err
 	}
@@ -892,6 +910,47 @@ func renderImage(xRefTable *model.XRefTable, sd *types.StreamDict, thumb bool, r
 	return nil, "", nil
 }
 
+// renderJPX renders the decoded JPEG 2000 data of sd using the color space of the image dict
+// or, if missing, the color space of the JPEG 2000 data.
+func renderJPX(xRefTable *model.XRefTable, sd *types.StreamDict, thumb bool, resourceName string, objNr int) (io.Reader, string, error) {
+	if err := sd.Decode(); err != nil {
+		return nil, "", err
+	}
+
+	jpx, err := filter.DecodeJPX(bytes.NewReader(sd.Content))
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Render the decoded 8 bit samples.
+	d := sd.Dict.Clone().(types.Dict)
+	d["Width"] = types.Integer(jpx.Width)
+	d["Height"] = types.Integer(jpx.Height)
+	d["BitsPerComponent"] = types.Integer(8)
+	if _, found := d.Find("ColorSpace"); !found {
+		if jpx.ColorSpace == "" {
+			return nil, "", errors.Errorf("pdfcpu: unsupported JPX color space obj#%d", objNr)
+		}
+		d.InsertName("ColorSpace", jpx.ColorSpace)
+	}
+
+	im, err := pdfImage(xRefTable, &types.StreamDict{Dict: d, Content: jpx.Pix}, thumb, objNr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if im.comp != jpx.Comps {
+		return nil, "", errors.Errorf("pdfcpu: JPX color components mismatch obj#%d", objNr)
+	}
+
+	// SMaskInData is ignored if the image has a soft mask.
+	if i := sd.IntEntry("SMaskInData"); i != nil && *i > 0 && im.softMask == nil {
+		im.softMask = jpx.Alpha
+	}
+
+	return renderPDFImage(xRefTable, im, resourceName)
+}
+
 func decodeCMYK(c, m, y, k uint8, decode []colValRange) (uint8, uint8, uint8, uint8) {
 	if len(decode) == 0 {
 		return c, m, y, k
@@ -953,7 +1012,7 @@ func RenderImage(xRefTable *model.XRefTable, sd *types.StreamDict, thumb bool, r
 
 	switch f {
 
-	case filter.Flate, filter.CCITTFax, filter.RunLength:
+	case filter.Flate, filter.CCITTFax, filter.JBIG2, filter.RunLength:
 		return renderImage(xRefTable, sd, thumb, resourceName, objNr)
 
 	case filter.DCT:
@@ -963,7 +1022,7 @@ func RenderImage(xRefTable *model.XRefTable, sd *types.StreamDict, thumb bool, r
 		return bytes.NewReader(sd.Content), "jpg", nil
 
 	case filter.JPX:
-		return bytes.NewReader(sd.Content), "jpx", nil
+		return renderJPX(xRefTable, sd, thumb, resourceName, objNr)
 	}
 
 	return nil, "", nil
@@ -294,7 +294,7 @@ func writeStringLiteralObject(ctx *model.Context, objNumber, genNumber int, sl t
 		return nil
 	}
 
-	if ctx.EncKey != nil {
+	if encryptStrings(ctx) {
 		sl1, err := encryptStringLiteral(sl, objNumber, genNumber, ctx.EncKey, ctx.AES4Strings, ctx.E.R)
 		if err != nil {
 			return err
@@ -316,7 +316,7 @@ func writeHexLiteralObject(ctx *model.Context, objNumber, genNumber int, hl type
 		return nil
 	}
 
-	if ctx.EncKey != nil {
+	if encryptStrings(ctx) {
 		hl1, err := encryptHexLiteral(hl, objNumber, genNumber, ctx.EncKey, ctx.AES4Strings, ctx.E.R)
 		if err != nil {
 			return err
@@ -364,7 +364,7 @@ func writeDictObject(ctx *model.Context, objNumber, genNumber int, d types.Dict)
 		return nil
 	}
 
-	if ctx.EncKey != nil {
+	if encryptStrings(ctx) {
 		_, err := encryptDeepObject(d, objNumber, genNumber, ctx.EncKey, ctx.AES4Strings, ctx.E.R)
 		if err != nil {
 			return err
@@ -384,7 +384,7 @@ func writeArrayObject(ctx *model.Context, objNumber, genNumber int, a types.Arra
 		return nil
 	}
 
-	if ctx.EncKey != nil {
+	if encryptStrings(ctx) {
 		if _, err := encryptDeepObject(a, objNumber, genNumber, ctx.EncKey, ctx.AES4Strings, ctx.E.R); err != nil {
 			return err
 		}
@@ -481,15 +481,14 @@ func writeStreamDictObject(ctx *model.Context, objNr, genNr int, sd types.Stream
 		}
 	}
 
-	var err error
-
 	// Unless the "Identity" crypt filter is used we have to encrypt.
-	isXRefStreamDict := sd.Type() != nil && *sd.Type() == "XRef"
-	if ctx.EncKey != nil &&
-		!isXRefStreamDict &&
-		!(len(sd.FilterPipeline) == 1 && sd.FilterPipeline[0].Name == "Crypt") {
+	encrypt, aes, err := streamCryptFilter(ctx, &sd, objNr)
+	if err != nil {
+		return err
+	}
 
-		if sd.Raw, err = encryptStream(sd.Raw, objNr, genNr, ctx.EncKey, ctx.AES4Streams, ctx.E.R); err != nil {
+	if encrypt {
+		if sd.Raw, err = encryptStream(sd.Raw, objNr, genNr, ctx.EncKey, aes, ctx.E.R); err != nil {
 			return err
 		}
 
@@ -602,8 +601,18 @@ func writeDeepDict(ctx *model.Context, d types.Dict, objNr, genNr int) error {
 	return nil
 }
 
+func writeFlatStreamDict(ctx *model.Context, sd *types.StreamDict, objNr, genNr int) error {
+	if encryptStrings(ctx) {
+		if _, err := encryptDeepObject(*sd, objNr, genNr, ctx.EncKey, ctx.AES4Strings, ctx.E.R); err != nil {
+			return err
+		}
+	}
+
+	return writeStreamDictObject(ctx, objNr, genNr, *sd)
+}
+
 func writeDeepStreamDict(ctx *model.Context, sd *types.StreamDict, objNr, genNr int) error {
-	if ctx.EncKey != nil {
+	if encryptStrings(ctx) {
 		if _, err := encryptDeepObject(*sd, objNr, genNr, ctx.EncKey, ctx.AES4Strings, ctx.E.R); err != nil {
 			return err
 		}
@@ -791,9 +800,12 @@ func writeFlatObject(ctx *model.Context, objNr int) error {
 		return nil
 	}
 
-	o := e.Object
+	o, err := ctx.FindObject(objNr)
+	if err != nil {
+		return err
+	}
+
 	genNr := *e.Generation
-	var err error
 
 	switch o := o.(type) {
 
@@ -801,7 +813,7 @@ func writeFlatObject(ctx *model.Context, objNr int) error {
 		err = writeDictObject(ctx, objNr, genNr, o)
 
 	case types.StreamDict:
-		err = writeDeepStreamDict(ctx, &o, objNr, genNr)
+		err = writeFlatStreamDict(ctx, &o, objNr, genNr)
 
 	case types.Array:
 		err = writeArrayObject(ctx, objNr, genNr, o)